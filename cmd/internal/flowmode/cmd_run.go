@@ -9,7 +9,6 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
@@ -18,6 +17,7 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/converter"
 	convert_diag "github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/boringcrypto"
 	"github.com/grafana/agent/pkg/config/instrumentation"
 	"github.com/grafana/agent/pkg/flow"
@@ -52,9 +52,12 @@ func runCommand() *cobra.Command {
 		disableReporting:      false,
 		enablePprof:           true,
 		configFormat:          "flow",
+		logFormat:             string(logging.FormatDefault),
 		clusterAdvInterfaces:  advertise.DefaultInterfaces,
 		ClusterMaxJoinPeers:   5,
 		clusterRejoinInterval: 60 * time.Second,
+		minStability:          featuregate.StabilityBeta,
+		shutdownGracePeriod:   30 * time.Second,
 	}
 
 	cmd := &cobra.Command{
@@ -82,9 +85,20 @@ Additionally, the HTTP server exposes the following debug endpoints:
 
   /debug/pprof   Go performance profiling tools
 
+If --print-resolved-config is provided, run prints the components of the config
+with their evaluated arguments, including the default values of unset
+attributes, and exits once the config has been loaded.
+
 If reloading the config dir/file-path fails, Grafana Agent Flow will continue running in
 its last valid state. Components which failed may be be listed as unhealthy,
 depending on the nature of the reload error.
+
+When an interrupt is received, components buffering data, such as
+prometheus.remote_write, loki.write and otelcol exporters, are asked to send it
+before all components are stopped. Components stop sending data once
+--shutdown.grace-period has elapsed, and Grafana Agent Flow exits once they're
+stopped. Setting --shutdown.grace-period to 0 stops components right away
+without sending the buffered data.
 `,
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
@@ -123,6 +137,12 @@ depending on the nature of the reload error.
 		BoolVar(&r.disableReporting, "disable-reporting", r.disableReporting, "Disable reporting of enabled components to Grafana.")
 	cmd.Flags().StringVar(&r.configFormat, "config.format", r.configFormat, fmt.Sprintf("The format of the source file. Supported formats: %s.", supportedFormatsList()))
 	cmd.Flags().BoolVar(&r.configBypassConversionErrors, "config.bypass-conversion-errors", r.configBypassConversionErrors, "Enable bypassing errors when converting")
+	cmd.Flags().StringVar(&r.logFormat, "log.format", r.logFormat, fmt.Sprintf("Format to use for writing log lines when not set in the logging block. Supported formats: %q, %q.", logging.FormatLogfmt, logging.FormatJSON))
+	cmd.Flags().BoolVar(&r.printResolvedConfig, "print-resolved-config", r.printResolvedConfig, "Print the config with the evaluated arguments of every component, including defaults, and exit")
+	cmd.Flags().IntVar(&r.componentsSoftLimit, "components.soft-limit", r.componentsSoftLimit, "Number of components above which a warning is logged, 0 to disable")
+	cmd.Flags().IntVar(&r.componentsHardLimit, "components.hard-limit", r.componentsHardLimit, "Maximum number of components; configurations exceeding it fail to load, 0 to disable")
+	cmd.Flags().DurationVar(&r.shutdownGracePeriod, "shutdown.grace-period", r.shutdownGracePeriod, "Maximum time to wait for components to send buffered data on shutdown")
+	cmd.Flags().Var(&r.minStability, "stability.level", fmt.Sprintf("Minimum stability level of the components which can be used. Supported levels: %s.", strings.Join(featuregate.AllowedStabilities(), ", ")))
 	return cmd
 }
 
@@ -144,20 +164,31 @@ type flowRun struct {
 	clusterName                  string
 	configFormat                 string
 	configBypassConversionErrors bool
+	logFormat                    string
+	minStability                 featuregate.Stability
+	printResolvedConfig          bool
+	shutdownGracePeriod          time.Duration
+	componentsSoftLimit          int
+	componentsHardLimit          int
 }
 
 func (fr *flowRun) Run(configPath string) error {
-	var wg sync.WaitGroup
-	defer wg.Wait()
-
 	ctx, cancel := interruptContext()
 	defer cancel()
 
 	if configPath == "" {
 		return fmt.Errorf("path argument not provided")
 	}
+	if fr.componentsSoftLimit < 0 || fr.componentsHardLimit < 0 {
+		return fmt.Errorf("--components.soft-limit and --components.hard-limit must not be negative")
+	}
 
-	l, err := logging.New(os.Stderr, logging.DefaultOptions)
+	logOpts := logging.DefaultOptions
+	if err := logOpts.Format.UnmarshalText([]byte(fr.logFormat)); err != nil {
+		return fmt.Errorf("invalid --log.format: %w", err)
+	}
+
+	l, err := logging.New(os.Stderr, logOpts)
 	if err != nil {
 		return fmt.Errorf("building logger: %w", err)
 	}
@@ -248,10 +279,15 @@ func (fr *flowRun) Run(configPath string) error {
 	labelService := labelstore.New(l)
 
 	f := flow.New(flow.Options{
-		Logger:   l,
-		Tracer:   t,
-		DataPath: fr.storagePath,
-		Reg:      reg,
+		Logger:       l,
+		Tracer:       t,
+		DataPath:     fr.storagePath,
+		Reg:          reg,
+		MinStability: fr.minStability,
+
+		ComponentsSoftLimit: fr.componentsSoftLimit,
+		ComponentsHardLimit: fr.componentsHardLimit,
+
 		Services: []service.Service{
 			httpService,
 			uiService,
@@ -277,15 +313,54 @@ func (fr *flowRun) Run(configPath string) error {
 		return flowSource, nil
 	}
 
-	// Flow controller
-	{
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			f.Run(ctx)
-		}()
+	initialLoad := func() error {
+		source, err := reload()
+		if err == nil {
+			return nil
+		}
+		var diags diag.Diagnostics
+		if errors.As(err, &diags) {
+			p := diag.NewPrinter(diag.PrinterConfig{
+				Color:              !color.NoColor,
+				ContextLinesBefore: 1,
+				ContextLinesAfter:  1,
+			})
+			_ = p.Fprint(os.Stderr, source.RawConfigs(), diags)
+
+			// Print newline after the diagnostics.
+			fmt.Println()
+
+			return fmt.Errorf("could not perform the initial load successfully")
+		}
+
+		// Exit if the initial load fails.
+		return err
 	}
 
+	// The resolved config is printed once the config is loaded, without running
+	// the Flow controller, so that neither the components nor the HTTP server
+	// and clustering are started.
+	if fr.printResolvedConfig {
+		if err := initialLoad(); err != nil {
+			return err
+		}
+		return writeResolvedConfig(os.Stdout, f.ListLoadedComponents(component.InfoOptions{GetArguments: true}))
+	}
+
+	// Flow controller. The controller isn't stopped by interrupts directly so
+	// that its components are drained first.
+	flowCtx, stopFlow := context.WithCancel(context.Background())
+	flowDone := make(chan struct{})
+	go func() {
+		defer close(flowDone)
+		f.Run(flowCtx)
+	}()
+	defer func() {
+		if err := shutdown(l, f, stopFlow, flowDone, fr.shutdownGracePeriod); err != nil {
+			level.Warn(l).Log("msg", "exiting before all buffered data was sent", "err", err)
+		}
+	}()
+
 	// Report usage of enabled components
 	if !fr.disableReporting {
 		reporter, err := usagestats.NewReporter(l)
@@ -303,23 +378,7 @@ func (fr *flowRun) Run(configPath string) error {
 	// Perform the initial reload. This is done after starting the HTTP server so
 	// that /metric and pprof endpoints are available while the Flow controller
 	// is loading.
-	if source, err := reload(); err != nil {
-		var diags diag.Diagnostics
-		if errors.As(err, &diags) {
-			p := diag.NewPrinter(diag.PrinterConfig{
-				Color:              !color.NoColor,
-				ContextLinesBefore: 1,
-				ContextLinesAfter:  1,
-			})
-			_ = p.Fprint(os.Stderr, source.RawConfigs(), diags)
-
-			// Print newline after the diagnostics.
-			fmt.Println()
-
-			return fmt.Errorf("could not perform the initial load successfully")
-		}
-
-		// Exit if the initial load fails.
+	if err := initialLoad(); err != nil {
 		return err
 	}
 
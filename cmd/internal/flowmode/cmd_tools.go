@@ -16,6 +16,8 @@ func toolsCommand() *cobra.Command {
 
 	cmd.AddCommand(
 		getTools("prometheus.remote_write", remotewrite.InstallTools),
+		diffCommand(),
+		schemaCommand(),
 	)
 
 	return cmd
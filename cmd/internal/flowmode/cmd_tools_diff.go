@@ -0,0 +1,292 @@
+package flowmode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/token"
+	"github.com/spf13/cobra"
+)
+
+func diffCommand() *cobra.Command {
+	d := &flowDiff{
+		format: "text",
+	}
+
+	cmd := &cobra.Command{
+		Use:   "diff [flags] old-file new-file",
+		Short: "Compare the components of two River files",
+		Long: `The diff subcommand reports the components which are added, removed, or
+changed between two River configuration files, along with the arguments and
+blocks which changed in each changed component.
+
+Formatting and comments are ignored when comparing components.
+
+The -f flag can be used to print the result as JSON.`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, args []string) error {
+			err := d.Run(os.Stdout, args[0], args[1])
+
+			var diags diag.Diagnostics
+			if errors.As(err, &diags) {
+				for _, diagnostic := range diags {
+					fmt.Fprintln(os.Stderr, diagnostic)
+				}
+				return fmt.Errorf("encountered errors during parsing")
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&d.format, "format", "f", d.format, "Output format. Supported formats: text, json.")
+	return cmd
+}
+
+type flowDiff struct {
+	format string
+}
+
+func (fd *flowDiff) Run(w io.Writer, oldFile, newFile string) error {
+	if fd.format != "text" && fd.format != "json" {
+		return fmt.Errorf("unsupported format %q", fd.format)
+	}
+
+	oldBytes, err := os.ReadFile(oldFile)
+	if err != nil {
+		return err
+	}
+	newBytes, err := os.ReadFile(newFile)
+	if err != nil {
+		return err
+	}
+
+	diff, err := diffConfigs(oldFile, oldBytes, newFile, newBytes)
+	if err != nil {
+		return err
+	}
+
+	if fd.format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return diff.WriteText(w)
+}
+
+// configDiff is the difference between the components of two River files.
+type configDiff struct {
+	Added   []string           `json:"added"`
+	Removed []string           `json:"removed"`
+	Changed []changedComponent `json:"changed"`
+}
+
+// changedComponent is a component whose arguments changed.
+type changedComponent struct {
+	ID string `json:"id"`
+	// Names of the arguments and blocks which were added, removed, or changed.
+	Fields []string `json:"fields"`
+}
+
+// WriteText writes the diff in a human-readable format.
+func (d configDiff) WriteText(w io.Writer) error {
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	for _, id := range d.Added {
+		if _, err := fmt.Fprintf(w, "+ %s\n", id); err != nil {
+			return err
+		}
+	}
+	for _, id := range d.Removed {
+		if _, err := fmt.Fprintf(w, "- %s\n", id); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.Changed {
+		if _, err := fmt.Fprintf(w, "~ %s: %s\n", c.ID, strings.Join(c.Fields, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffConfigs computes the difference between the components of two River
+// files.
+func diffConfigs(oldName string, oldBytes []byte, newName string, newBytes []byte) (configDiff, error) {
+	oldBlocks, err := parseTopLevelBlocks(oldName, oldBytes)
+	if err != nil {
+		return configDiff{}, err
+	}
+	newBlocks, err := parseTopLevelBlocks(newName, newBytes)
+	if err != nil {
+		return configDiff{}, err
+	}
+
+	diff := configDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []changedComponent{},
+	}
+	for id, newBlock := range newBlocks {
+		oldBlock, ok := oldBlocks[id]
+		if !ok {
+			diff.Added = append(diff.Added, id)
+			continue
+		}
+		if fields := diffBodies(oldBlock.Body, newBlock.Body); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, changedComponent{ID: id, Fields: fields})
+		}
+	}
+	for id := range oldBlocks {
+		if _, ok := newBlocks[id]; !ok {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].ID < diff.Changed[j].ID })
+	return diff, nil
+}
+
+// parseTopLevelBlocks parses a River file and returns its top-level blocks by
+// ID, such as "prometheus.scrape.default" or "logging".
+func parseTopLevelBlocks(name string, bb []byte) (map[string]*ast.BlockStmt, error) {
+	f, err := parser.ParseFile(name, bb)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[string]*ast.BlockStmt, len(f.Body))
+	for _, stmt := range f.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected attribute at the top level", name)
+		}
+
+		id := blockID(block)
+		if _, exists := blocks[id]; exists {
+			return nil, fmt.Errorf("%s: block %s is declared multiple times", name, id)
+		}
+		blocks[id] = block
+	}
+	return blocks, nil
+}
+
+func blockID(b *ast.BlockStmt) string {
+	id := strings.Join(b.Name, ".")
+	if b.Label != "" {
+		id += "." + b.Label
+	}
+	return id
+}
+
+// diffBodies returns the sorted names of the attributes and blocks which
+// differ between two block bodies. Blocks which can be set multiple times are
+// compared in order.
+func diffBodies(oldBody, newBody ast.Body) []string {
+	var (
+		oldStmts = groupStatements(oldBody)
+		newStmts = groupStatements(newBody)
+		changed  []string
+	)
+	for name, newList := range newStmts {
+		if oldList, ok := oldStmts[name]; !ok || !equalNodes(oldList, newList) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range oldStmts {
+		if _, ok := newStmts[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// groupStatements groups the statements of a body by the name of the
+// attribute or block.
+func groupStatements(body ast.Body) map[string][]ast.Stmt {
+	res := make(map[string][]ast.Stmt, len(body))
+	for _, stmt := range body {
+		var name string
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			name = stmt.Name.Name
+		case *ast.BlockStmt:
+			name = blockID(stmt)
+		}
+		res[name] = append(res[name], stmt)
+	}
+	return res
+}
+
+var goTokenPos = reflect.TypeOf(token.Pos{})
+
+// equalNodes reports whether two values of the River AST are equal, ignoring
+// the positions of their nodes.
+func equalNodes(a, b interface{}) bool {
+	return equalValues(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func equalValues(a, b reflect.Value) bool {
+	if a.IsValid() != b.IsValid() {
+		return false
+	} else if !a.IsValid() {
+		return true
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	if a.Type() == goTokenPos {
+		return true
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalValues(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equalValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() == b.Uint()
+	default:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+}
@@ -0,0 +1,202 @@
+package flowmode
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expect   configDiff
+	}{
+		{
+			name: "no changes with different formatting and comments",
+			old: `
+				prometheus.scrape "default" {
+					targets = [{"__address__" = "localhost:12345"}]
+					forward_to = [prometheus.remote_write.default.receiver]
+				}
+			`,
+			new: `
+				// Scrape the agent itself.
+				prometheus.scrape "default" {
+					targets    = [
+						{"__address__" = "localhost:12345"},
+					]
+					forward_to = [prometheus.remote_write.default.receiver]
+				}
+			`,
+			expect: configDiff{Added: []string{}, Removed: []string{}, Changed: []changedComponent{}},
+		},
+		{
+			name: "added and removed components",
+			old: `
+				logging {
+					level = "info"
+				}
+
+				prometheus.exporter.unix "default" { }
+				prometheus.remote_write "default" { }
+			`,
+			new: `
+				logging {
+					level = "info"
+				}
+
+				prometheus.exporter.agent "default" { }
+				prometheus.remote_write "default" { }
+				prometheus.remote_write "backup" { }
+			`,
+			expect: configDiff{
+				Added:   []string{"prometheus.exporter.agent.default", "prometheus.remote_write.backup"},
+				Removed: []string{"prometheus.exporter.unix.default"},
+				Changed: []changedComponent{},
+			},
+		},
+		{
+			name: "changed arguments and blocks",
+			old: `
+				prometheus.remote_write "default" {
+					external_labels = { cluster = "eu-west" }
+
+					endpoint {
+						url = "http://mimir:9009/api/v1/push"
+					}
+				}
+
+				prometheus.relabel "default" {
+					forward_to = []
+
+					rule {
+						action = "drop"
+						regex  = "debug"
+					}
+					rule {
+						action = "keep"
+					}
+				}
+
+				loki.write "default" {
+					endpoint {
+						url = "http://loki:3100/loki/api/v1/push"
+					}
+				}
+			`,
+			new: `
+				prometheus.remote_write "default" {
+					external_labels = { cluster = "eu-west" }
+
+					endpoint {
+						url = "http://mimir:9009/api/v1/push"
+
+						queue_config {
+							capacity = 20000
+						}
+					}
+
+					wal {
+						truncate_frequency = "1h"
+					}
+				}
+
+				prometheus.relabel "default" {
+					forward_to = []
+
+					rule {
+						action = "keep"
+					}
+					rule {
+						action = "drop"
+						regex  = "debug"
+					}
+				}
+
+				loki.write "default" {
+					external_labels = { cluster = "eu-west" }
+
+					endpoint {
+						url = "http://loki:3100/loki/api/v1/push"
+					}
+				}
+			`,
+			expect: configDiff{
+				Added:   []string{},
+				Removed: []string{},
+				Changed: []changedComponent{
+					{ID: "loki.write.default", Fields: []string{"external_labels"}},
+					{ID: "prometheus.relabel.default", Fields: []string{"rule"}},
+					{ID: "prometheus.remote_write.default", Fields: []string{"endpoint", "wal"}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := diffConfigs("old.river", []byte(tc.old), "new.river", []byte(tc.new))
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, diff)
+		})
+	}
+}
+
+func TestDiffConfigs_Errors(t *testing.T) {
+	_, err := diffConfigs("old.river", []byte(`
+		prometheus.remote_write "default" { }
+		prometheus.remote_write "default" { }
+	`), "new.river", nil)
+	require.EqualError(t, err, "old.river: block prometheus.remote_write.default is declared multiple times")
+
+	_, err = diffConfigs("old.river", nil, "new.river", []byte(`foo = "bar"`))
+	require.EqualError(t, err, "new.river: unexpected attribute at the top level")
+
+	_, err = diffConfigs("old.river", []byte(`prometheus.remote_write "default" {`), "new.river", nil)
+	require.Error(t, err)
+}
+
+func TestDiffRun(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		oldFile = filepath.Join(dir, "old.river")
+		newFile = filepath.Join(dir, "new.river")
+	)
+	require.NoError(t, os.WriteFile(oldFile, []byte(`
+		local.file "token" {
+			filename = "/etc/token"
+		}
+
+		prometheus.remote_write "default" { }
+	`), 0o644))
+	require.NoError(t, os.WriteFile(newFile, []byte(`
+		local.file "token" {
+			filename  = "/etc/token"
+			is_secret = true
+		}
+
+		loki.write "default" { }
+	`), 0o644))
+
+	var buf bytes.Buffer
+	require.NoError(t, (&flowDiff{format: "text"}).Run(&buf, oldFile, newFile))
+	require.Equal(t, "+ loki.write.default\n- prometheus.remote_write.default\n~ local.file.token: is_secret\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, (&flowDiff{format: "json"}).Run(&buf, oldFile, newFile))
+	require.JSONEq(t, `{
+		"added": ["loki.write.default"],
+		"removed": ["prometheus.remote_write.default"],
+		"changed": [{"id": "local.file.token", "fields": ["is_secret"]}]
+	}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, (&flowDiff{format: "text"}).Run(&buf, oldFile, oldFile))
+	require.Equal(t, "No changes.\n", buf.String())
+
+	require.EqualError(t, (&flowDiff{format: "yaml"}).Run(&buf, oldFile, newFile), `unsupported format "yaml"`)
+}
@@ -0,0 +1,344 @@
+package flowmode
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river"
+	"github.com/grafana/river/encoding/riverjson"
+	"github.com/grafana/river/rivertypes"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+)
+
+func schemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema [component ...]",
+		Short: "Print the schema of Flow components as JSON",
+		Long: `The schema subcommand prints a JSON schema of the arguments and exports of
+the registered components, meant to be used by editors for autocompletion and
+validation.
+
+By default, the schema of every component is printed. Component names can be
+passed to only print the schema of these components.`,
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, args []string) error {
+			return writeSchema(os.Stdout, args)
+		},
+	}
+}
+
+// componentsSchema is the schema of a set of components.
+type componentsSchema struct {
+	Components []componentSchema `json:"components"`
+}
+
+// componentSchema is the schema of a single component.
+type componentSchema struct {
+	Name      string        `json:"name"`
+	Arguments []fieldSchema `json:"arguments"`
+	Exports   []fieldSchema `json:"exports"`
+}
+
+// fieldSchema is the schema of an attribute or block.
+type fieldSchema struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // "attr" or "block"
+
+	// Type of an attribute.
+	Type string `json:"type,omitempty"`
+	// Default value of an optional attribute, encoded like the arguments of
+	// components in the API.
+	Default json.RawMessage `json:"default,omitempty"`
+
+	Required bool `json:"required"`
+
+	// Whether a block can be set multiple times, and whether it has a label.
+	Multiple bool `json:"multiple,omitempty"`
+	Labeled  bool `json:"labeled,omitempty"`
+	// Fields of a block.
+	Fields []fieldSchema `json:"fields,omitempty"`
+}
+
+// writeSchema writes the schema of the components with the given names to w,
+// or of all components if names is empty.
+func writeSchema(w io.Writer, names []string) error {
+	if len(names) == 0 {
+		names = component.AllNames()
+	}
+
+	schema := componentsSchema{Components: make([]componentSchema, 0, len(names))}
+	for _, name := range names {
+		reg, ok := component.Get(name)
+		if !ok {
+			return fmt.Errorf("unknown component %q", name)
+		}
+		schema.Components = append(schema.Components, buildComponentSchema(reg))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
+
+func buildComponentSchema(reg component.Registration) componentSchema {
+	res := componentSchema{
+		Name:      reg.Name,
+		Arguments: []fieldSchema{},
+		Exports:   []fieldSchema{},
+	}
+	if reg.Args != nil {
+		b := schemaBuilder{withDefaults: true, visiting: make(map[reflect.Type]bool)}
+		res.Arguments = b.structFields(reflect.TypeOf(reg.Args), defaultValue(reflect.TypeOf(reg.Args)))
+	}
+	if reg.Exports != nil {
+		b := schemaBuilder{visiting: make(map[reflect.Type]bool)}
+		res.Exports = b.structFields(reflect.TypeOf(reg.Exports), reflect.Value{})
+	}
+	return res
+}
+
+var (
+	goCapsule        = reflect.TypeOf((*river.Capsule)(nil)).Elem()
+	goDefaulter      = reflect.TypeOf((*river.Defaulter)(nil)).Elem()
+	goTextMarshaler  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	goDuration       = reflect.TypeOf(time.Duration(0))
+	goModelDuration  = reflect.TypeOf(model.Duration(0))
+	goSecret         = reflect.TypeOf(rivertypes.Secret(""))
+	goOptionalSecret = reflect.TypeOf(rivertypes.OptionalSecret{})
+)
+
+// defaultValue returns the default value of t if it implements
+// river.Defaulter, or an invalid value otherwise.
+func defaultValue(t reflect.Type) reflect.Value {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	d, ok := v.Interface().(river.Defaulter)
+	if !ok {
+		return reflect.Value{}
+	}
+	d.SetToDefault()
+	return v.Elem()
+}
+
+// schemaBuilder builds the schema of the fields of a struct.
+type schemaBuilder struct {
+	withDefaults bool
+	// Struct types being visited, to stop at recursive blocks.
+	visiting map[reflect.Type]bool
+}
+
+// structFields returns the schema of the river-tagged fields of the struct t.
+// defaults holds the default value of the struct, if any.
+func (b *schemaBuilder) structFields(t reflect.Type, defaults reflect.Value) []fieldSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	defaults = deref(defaults)
+
+	fields := []fieldSchema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("river")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+
+		var fieldDefault reflect.Value
+		if defaults.IsValid() {
+			fieldDefault = defaults.Field(i)
+		}
+
+		opts := strings.Split(flags, ",")
+		optional := hasOption(opts, "optional")
+		switch {
+		case hasOption(opts, "squash"):
+			fields = append(fields, b.structFields(f.Type, fieldDefault)...)
+
+		case hasOption(opts, "attr"):
+			fs := fieldSchema{
+				Name:     name,
+				Kind:     "attr",
+				Type:     riverTypeName(f.Type),
+				Required: !optional,
+			}
+			if b.withDefaults && optional {
+				fs.Default = encodeDefault(fieldDefault)
+			}
+			fields = append(fields, fs)
+
+		case hasOption(opts, "block"):
+			fields = append(fields, b.blockSchema(name, f.Type, fieldDefault, !optional))
+
+		case hasOption(opts, "enum"):
+			// Each field of the elements of an enum is a block which can be set
+			// multiple times.
+			elem := f.Type.Elem()
+			for elem.Kind() == reflect.Pointer {
+				elem = elem.Elem()
+			}
+			for _, inner := range b.structFields(elem, reflect.Value{}) {
+				inner.Name = name + "." + inner.Name
+				inner.Required = false
+				inner.Multiple = true
+				fields = append(fields, inner)
+			}
+		}
+	}
+	return fields
+}
+
+func (b *schemaBuilder) blockSchema(name string, t reflect.Type, defaults reflect.Value, required bool) fieldSchema {
+	fs := fieldSchema{
+		Name:     name,
+		Kind:     "block",
+		Required: required,
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		fs.Multiple = true
+		t = t.Elem()
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		// Each element of a block slice has its own default value.
+		defaults = reflect.Value{}
+	}
+	if t.Kind() != reflect.Struct {
+		return fs
+	}
+
+	// Fields of recursive blocks are only described at the outermost level.
+	if b.visiting[t] {
+		return fs
+	}
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	if d := deref(defaults); !d.IsValid() || d.IsZero() {
+		defaults = defaultValue(t)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if _, flags, _ := strings.Cut(t.Field(i).Tag.Get("river"), ","); hasOption(strings.Split(flags, ","), "label") {
+			fs.Labeled = true
+		}
+	}
+	fs.Fields = b.structFields(t, defaults)
+	return fs
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// encodeDefault encodes a non-zero default value, or returns nil if v has no
+// meaningful default.
+func encodeDefault(v reflect.Value) json.RawMessage {
+	if !v.IsValid() || v.IsZero() || riverTypeName(v.Type()) == "function" || strings.HasPrefix(riverTypeName(v.Type()), "capsule") {
+		return nil
+	}
+	bb, err := riverjson.MarshalValue(v.Interface())
+	if err != nil {
+		return nil
+	}
+	return bb
+}
+
+// riverTypeName returns a description of the River type of values of t, such
+// as "list(map(string))".
+func riverTypeName(t reflect.Type) string {
+	elem := t
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	switch elem {
+	case goDuration, goModelDuration:
+		return "duration"
+	case goSecret, goOptionalSecret:
+		return "secret"
+	}
+
+	for t.Kind() == reflect.Pointer {
+		if t.Implements(goCapsule) {
+			return capsuleName(t)
+		} else if t.Implements(goTextMarshaler) {
+			return "string"
+		}
+		t = t.Elem()
+	}
+	switch {
+	case t.Implements(goCapsule):
+		return capsuleName(t)
+	case t.Implements(goTextMarshaler), reflect.PointerTo(t).Implements(goTextMarshaler) && t.Kind() != reflect.Struct:
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice, reflect.Array:
+		return fmt.Sprintf("list(%s)", riverTypeName(t.Elem()))
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return capsuleName(t)
+		}
+		return fmt.Sprintf("map(%s)", riverTypeName(t.Elem()))
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if _, ok := t.Field(i).Tag.Lookup("river"); ok {
+				return "object"
+			}
+		}
+		return capsuleName(t)
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any"
+		}
+		return capsuleName(t)
+	case reflect.Func:
+		return "function"
+	default:
+		return capsuleName(t)
+	}
+}
+
+// capsuleName returns the name of a capsule type, including the Go type it
+// holds, such as "capsule(storage.Appendable)".
+func capsuleName(t reflect.Type) string {
+	return fmt.Sprintf("capsule(%s)", strings.TrimLeft(t.String(), "*"))
+}
@@ -0,0 +1,70 @@
+package flowmode
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeSchema(&buf, []string{"prometheus.scrape", "prometheus.relabel"}))
+
+	var schema componentsSchema
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &schema))
+	require.Len(t, schema.Components, 2)
+
+	scrape := schema.Components[0]
+	require.Equal(t, "prometheus.scrape", scrape.Name)
+	require.Equal(t, fieldSchema{
+		Name:     "targets",
+		Kind:     "attr",
+		Type:     "list(map(string))",
+		Required: true,
+	}, findField(t, scrape.Arguments, "targets"))
+	require.Equal(t, fieldSchema{
+		Name:     "forward_to",
+		Kind:     "attr",
+		Type:     "list(capsule(storage.Appendable))",
+		Required: true,
+	}, findField(t, scrape.Arguments, "forward_to"))
+
+	interval := findField(t, scrape.Arguments, "scrape_interval")
+	require.Equal(t, "duration", interval.Type)
+	require.False(t, interval.Required)
+	require.JSONEq(t, `{"type": "string", "value": "1m0s"}`, string(interval.Default))
+
+	basicAuth := findField(t, scrape.Arguments, "basic_auth")
+	require.Equal(t, "block", basicAuth.Kind)
+	require.Equal(t, "secret", findField(t, basicAuth.Fields, "password").Type)
+	require.Empty(t, scrape.Exports)
+
+	relabel := schema.Components[1]
+	rule := findField(t, relabel.Arguments, "rule")
+	require.Equal(t, "block", rule.Kind)
+	require.True(t, rule.Multiple)
+	require.NotEmpty(t, rule.Fields)
+	require.Equal(t, "capsule(storage.Appendable)", findField(t, relabel.Exports, "receiver").Type)
+}
+
+func TestSchema_AllComponents(t *testing.T) {
+	require.NoError(t, writeSchema(io.Discard, nil))
+}
+
+func TestSchema_UnknownComponent(t *testing.T) {
+	require.EqualError(t, writeSchema(io.Discard, []string{"prometheus.unknown"}), `unknown component "prometheus.unknown"`)
+}
+
+func findField(t *testing.T, fields []fieldSchema, name string) fieldSchema {
+	t.Helper()
+	for _, f := range fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	require.FailNow(t, "field not found", name)
+	return fieldSchema{}
+}
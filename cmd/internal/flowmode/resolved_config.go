@@ -0,0 +1,163 @@
+package flowmode
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/printer"
+	"github.com/grafana/river/token/builder"
+)
+
+// writeResolvedConfig writes the evaluated arguments of the components in
+// infos to w as River blocks. The components of modules are written after the
+// components of the root config, grouped by module under a comment naming the
+// module.
+//
+// Unlike the River encoder, which omits the optional attributes set to their
+// default value, every attribute is written so that the resolved config shows
+// the values actually used by the components. Secrets are redacted by the
+// encoder.
+func writeResolvedConfig(w io.Writer, infos []*component.Info) error {
+	var (
+		moduleIDs []string
+		byModule  = map[string][]*component.Info{}
+	)
+	for _, info := range infos {
+		id := info.ID.ModuleID
+		if _, ok := byModule[id]; !ok && id != "" {
+			moduleIDs = append(moduleIDs, id)
+		}
+		byModule[id] = append(byModule[id], info)
+	}
+
+	if err := writeResolvedBlocks(w, byModule[""]); err != nil {
+		return err
+	}
+	for _, id := range moduleIDs {
+		if _, err := fmt.Fprintf(w, "\n// Module %s.\n", id); err != nil {
+			return err
+		}
+		if err := writeResolvedBlocks(w, byModule[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeResolvedBlocks writes the evaluated arguments of the components in
+// infos to w as River blocks.
+func writeResolvedBlocks(w io.Writer, infos []*component.Info) error {
+	if len(infos) == 0 {
+		return nil
+	}
+
+	f := builder.NewFile()
+	for _, info := range infos {
+		block := builder.NewBlock(strings.Split(info.Registration.Name, "."), info.Label)
+		encodeResolvedBody(block.Body(), reflect.ValueOf(info.Arguments))
+		f.Body().AppendBlock(block)
+	}
+
+	// The builder doesn't align the attributes of a body consistently, so the
+	// result is formatted like river fmt does.
+	parsed, err := parser.ParseFile("resolved.river", f.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := printer.Fprint(w, parsed); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// encodeResolvedBody writes the river-tagged fields of the struct v to body.
+func encodeResolvedBody(body *builder.Body, v reflect.Value) {
+	v = deref(v)
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("river")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+		opts := strings.Split(flags, ",")
+		fv := v.Field(i)
+
+		switch {
+		case hasOption(opts, "squash"):
+			encodeResolvedBody(body, fv)
+
+		case hasOption(opts, "attr"):
+			// Unset optional pointers and interfaces don't have a resolved value.
+			if hasOption(opts, "optional") && isNil(fv) {
+				continue
+			}
+			body.SetAttributeValue(name, fv.Interface())
+
+		case hasOption(opts, "block"):
+			encodeResolvedBlocks(body, strings.Split(name, "."), fv)
+
+		case hasOption(opts, "enum"):
+			// Only the set field of each element of an enum is written, as a
+			// block named after the enum and the field.
+			for j := 0; j < fv.Len(); j++ {
+				elem := deref(fv.Index(j))
+				if !elem.IsValid() {
+					continue
+				}
+				for k := 0; k < elem.NumField(); k++ {
+					elemName, _, _ := strings.Cut(elem.Type().Field(k).Tag.Get("river"), ",")
+					if elemName == "" || elem.Field(k).IsZero() {
+						continue
+					}
+					encodeResolvedBlocks(body, strings.Split(name+"."+elemName, "."), elem.Field(k))
+				}
+			}
+		}
+	}
+}
+
+// encodeResolvedBlocks appends the block v, or one block per element if v is
+// a slice, to body.
+func encodeResolvedBlocks(body *builder.Body, name []string, v reflect.Value) {
+	v = deref(v)
+	if !v.IsValid() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			encodeResolvedBlocks(body, name, v.Index(i))
+		}
+	case reflect.Struct:
+		block := builder.NewBlock(name, blockLabel(v))
+		encodeResolvedBody(block.Body(), v)
+		body.AppendBlock(block)
+	}
+}
+
+// blockLabel returns the value of the label field of the struct v, if any.
+func blockLabel(v reflect.Value) string {
+	for i := 0; i < v.NumField(); i++ {
+		_, flags, _ := strings.Cut(v.Type().Field(i).Tag.Get("river"), ",")
+		if hasOption(strings.Split(flags, ","), "label") && v.Field(i).Kind() == reflect.String {
+			return v.Field(i).String()
+		}
+	}
+	return ""
+}
+
+func isNil(v reflect.Value) bool {
+	return (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()
+}
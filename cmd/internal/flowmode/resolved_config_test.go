@@ -0,0 +1,84 @@
+package flowmode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/loki/process"
+	"github.com/grafana/agent/component/prometheus/scrape"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvedConfig(t *testing.T) {
+	var scrapeArgs scrape.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		targets    = [{"__address__" = "localhost:12345"}]
+		forward_to = []
+
+		basic_auth {
+			username = "user"
+			password = "secret"
+		}
+	`), &scrapeArgs))
+
+	var processArgs process.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		forward_to = []
+
+		stage.static_labels {
+			values = { "env" = "prod" }
+		}
+	`), &processArgs))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeResolvedConfig(&buf, []*component.Info{
+		{Registration: mustGetRegistration(t, "prometheus.scrape"), Label: "default", Arguments: scrapeArgs},
+		{Registration: mustGetRegistration(t, "loki.process"), Label: "default", Arguments: &processArgs},
+	}))
+	out := buf.String()
+
+	require.Contains(t, out, `prometheus.scrape "default" {`)
+	// Defaults of unset attributes are written explicitly.
+	require.Regexp(t, `scrape_interval += "1m0s"`, out)
+	require.Regexp(t, `scrape_timeout += "10s"`, out)
+	require.Regexp(t, `metrics_path += "/metrics"`, out)
+	require.Regexp(t, `honor_timestamps += true`, out)
+	// Blocks are written along with their defaults, and secrets are redacted.
+	require.Contains(t, out, "basic_auth {")
+	require.Regexp(t, `username += "user"`, out)
+	require.Regexp(t, `password += \(secret\)`, out)
+	require.NotContains(t, out, `"secret"`)
+
+	require.Contains(t, out, `loki.process "default" {`)
+	require.Contains(t, out, "stage.static_labels {")
+}
+
+func mustGetRegistration(t *testing.T, name string) component.Registration {
+	t.Helper()
+	reg, ok := component.Get(name)
+	require.True(t, ok)
+	return reg
+}
+
+// TestResolvedConfig_Modules ensures that the components of modules are
+// written after the components of the root config, grouped by module.
+func TestResolvedConfig_Modules(t *testing.T) {
+	var processArgs process.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`forward_to = []`), &processArgs))
+
+	reg := mustGetRegistration(t, "loki.process")
+	var buf bytes.Buffer
+	require.NoError(t, writeResolvedConfig(&buf, []*component.Info{
+		{ID: component.ID{ModuleID: "module.file.a", LocalID: "loki.process.inner"}, Registration: reg, Label: "inner", Arguments: processArgs},
+		{ID: component.ID{LocalID: "loki.process.root"}, Registration: reg, Label: "root", Arguments: processArgs},
+	}))
+	out := buf.String()
+
+	root := strings.Index(out, `loki.process "root" {`)
+	header := strings.Index(out, "// Module module.file.a.")
+	inner := strings.Index(out, `loki.process "inner" {`)
+	require.True(t, root >= 0 && root < header && header < inner, out)
+}
@@ -0,0 +1,39 @@
+package flowmode
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+)
+
+// drainer is implemented by the Flow controller.
+type drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// shutdown gracefully stops the Flow controller f, which stops running once
+// stop is called and has exited once done is closed.
+//
+// The components of f are drained first so that they send the data they
+// buffer, and are then stopped. Draining is given up once gracePeriod has
+// elapsed, losing the data components still buffer, and is skipped if
+// gracePeriod is 0. shutdown always waits for the components to stop, so that
+// they release their resources, such as their WAL, before exiting.
+func shutdown(l log.Logger, f drainer, stop context.CancelFunc, done <-chan struct{}, gracePeriod time.Duration) error {
+	var err error
+	if gracePeriod > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+		level.Info(l).Log("msg", "draining components", "grace_period", gracePeriod)
+		if drainErr := f.Drain(ctx); drainErr != nil {
+			err = fmt.Errorf("components weren't drained within the shutdown grace period of %s: %w", gracePeriod, drainErr)
+		}
+		cancel()
+	}
+
+	stop()
+	<-done
+	return err
+}
@@ -0,0 +1,212 @@
+package flowmode
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/component/loki/write"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/prometheus/remotewrite"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/agent/service"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+)
+
+// TestShutdown ensures that the data buffered by components right before
+// shutdown is sent within the grace period, and lost without one.
+func TestShutdown(t *testing.T) {
+	t.Run("with grace period", func(t *testing.T) {
+		received := runShutdown(t, 20*time.Second)
+		require.Equal(t, sinkCounts{samples: 1, entries: 1, spans: 1}, received)
+	})
+
+	t.Run("without grace period", func(t *testing.T) {
+		received := runShutdown(t, 0)
+		require.Equal(t, sinkCounts{}, received)
+	})
+}
+
+// sinkCounts holds the data received by the fake sinks.
+type sinkCounts struct {
+	samples, entries, spans int64
+}
+
+// runShutdown sends data to pipelines buffering it until they're drained,
+// shuts them down with gracePeriod, and returns the data received by the
+// sinks once shutdown returns.
+func runShutdown(t *testing.T, gracePeriod time.Duration) sinkCounts {
+	var samples, entries, spans atomic.Int64
+
+	promSink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := remote.DecodeWriteRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, ts := range req.Timeseries {
+			samples.Add(int64(len(ts.Samples)))
+		}
+	}))
+	t.Cleanup(promSink.Close)
+
+	lokiSink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entries.Inc()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(lokiSink.Close)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	otlpSink := grpc.NewServer()
+	ptraceotlp.RegisterGRPCServer(otlpSink, &fakeTracesSink{spans: &spans})
+	go func() { _ = otlpSink.Serve(lis) }()
+	t.Cleanup(otlpSink.Stop)
+
+	// Every pipeline buffers data for longer than the test runs unless it's
+	// drained.
+	source, err := flow.ParseSource(t.Name(), []byte(fmt.Sprintf(`
+		prometheus.remote_write "default" {
+			endpoint {
+				url = "%s/api/v1/write"
+			}
+		}
+
+		loki.write "default" {
+			endpoint {
+				url        = "%s/loki/api/v1/push"
+				batch_wait = "1h"
+			}
+		}
+
+		otelcol.processor.batch "default" {
+			timeout = "1h"
+
+			output {
+				traces = [otelcol.exporter.otlp.default.input]
+			}
+		}
+
+		otelcol.exporter.otlp "default" {
+			client {
+				endpoint = "%s"
+				tls {
+					insecure = true
+				}
+			}
+		}
+	`, promSink.URL, lokiSink.URL, lis.Addr())))
+	require.NoError(t, err)
+
+	l, err := logging.New(os.Stderr, logging.DefaultOptions)
+	require.NoError(t, err)
+	f := flow.New(flow.Options{
+		Logger:   l,
+		DataPath: t.TempDir(),
+		Reg:      prometheus.NewRegistry(),
+		Services: []service.Service{labelstore.New(nil)},
+	})
+	require.NoError(t, f.LoadSource(source, nil))
+
+	flowCtx, stopFlow := context.WithCancel(context.Background())
+	flowDone := make(chan struct{})
+	go func() {
+		defer close(flowDone)
+		f.Run(flowCtx)
+	}()
+	t.Cleanup(func() { <-flowDone })
+
+	exports := func(id string) component.Exports {
+		info, err := f.GetComponent(component.ID{LocalID: id}, component.InfoOptions{GetExports: true})
+		require.NoError(t, err)
+		return info.Exports
+	}
+
+	// Samples older than the start of remote_write are ignored.
+	app := exports("prometheus.remote_write.default").(remotewrite.Exports).Receiver.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "test_metric"), time.Now().Add(time.Minute).UnixMilli(), 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	exports("loki.write.default").(write.Exports).Receiver.Chan() <- loki.Entry{
+		Labels: model.LabelSet{"foo": "bar"},
+		Entry:  logproto.Entry{Timestamp: time.Now(), Line: "very important log"},
+	}
+
+	// The processor only accepts data once it's running.
+	traces := ptrace.NewTraces()
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("test")
+	input := exports("otelcol.processor.batch.default").(otelcol.ConsumerExports).Input
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(context.Background(), traces) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, shutdown(l, f, stopFlow, flowDone, gracePeriod))
+	return sinkCounts{samples: samples.Load(), entries: entries.Load(), spans: spans.Load()}
+}
+
+type fakeTracesSink struct {
+	ptraceotlp.UnimplementedGRPCServer
+	spans *atomic.Int64
+}
+
+func (s *fakeTracesSink) Export(_ context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	s.spans.Add(int64(req.Traces().SpanCount()))
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+// TestShutdownWaitsForComponents ensures that shutdown waits for the
+// components to stop once they're drained, regardless of the grace period.
+func TestShutdownWaitsForComponents(t *testing.T) {
+	for _, gracePeriod := range []time.Duration{0, time.Millisecond} {
+		var (
+			stopped = make(chan struct{})
+			done    = make(chan struct{})
+		)
+		go func() {
+			<-stopped
+			// Components take longer than the grace period to stop.
+			time.Sleep(50 * time.Millisecond)
+			close(done)
+		}()
+
+		err := shutdown(log.NewNopLogger(), blockingDrainer{}, func() { close(stopped) }, done, gracePeriod)
+		select {
+		case <-done:
+		default:
+			require.FailNow(t, "shutdown returned before the components stopped", "grace period %s", gracePeriod)
+		}
+		if gracePeriod > 0 {
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+// blockingDrainer is a drainer which is never done draining.
+type blockingDrainer struct{}
+
+func (blockingDrainer) Drain(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
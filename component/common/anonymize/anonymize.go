@@ -0,0 +1,137 @@
+// Package anonymize hashes or drops the values of sensitive labels. It is
+// shared by the prometheus.anonymize and loki.anonymize components.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/grafana/regexp"
+	"github.com/grafana/river/rivertypes"
+)
+
+// Actions applied to the matching label values.
+const (
+	// ActionHash replaces the value with its salted hash.
+	ActionHash = "hash"
+	// ActionDrop removes the label.
+	ActionDrop = "drop"
+)
+
+// Arguments holds the settings shared by the anonymize components.
+type Arguments struct {
+	// Secret mixed into the hashes, so that they can't be reversed by hashing
+	// guessed values.
+	Salt rivertypes.Secret `river:"salt,attr,optional"`
+	// Rules applied to the labels, in order.
+	Rules []Rule `river:"rule,block,optional"`
+}
+
+// Rule anonymizes the values of a set of labels.
+type Rule struct {
+	// Names of the labels to anonymize.
+	Labels []string `river:"labels,attr"`
+	// Only values fully matching Regex are anonymized.
+	Regex string `river:"regex,attr,optional"`
+	// Action applied to the matching values.
+	Action string `river:"action,attr,optional"`
+}
+
+// DefaultRule holds default settings for Rule.
+var DefaultRule = Rule{
+	Regex:  ".*",
+	Action: ActionHash,
+}
+
+// SetToDefault implements river.Defaulter.
+func (r *Rule) SetToDefault() {
+	*r = DefaultRule
+}
+
+// Validate implements river.Validator.
+func (r *Rule) Validate() error {
+	if len(r.Labels) == 0 {
+		return fmt.Errorf("labels must not be empty")
+	}
+	if r.Action != ActionHash && r.Action != ActionDrop {
+		return fmt.Errorf("action must be %q or %q, got %q", ActionHash, ActionDrop, r.Action)
+	}
+	if _, err := compile(r.Regex); err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	return nil
+}
+
+// compile compiles expr anchored on both ends, to match the behavior of
+// relabel rules.
+func compile(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// Anonymizer applies a set of rules to label values. Anonymizer is immutable
+// and safe for concurrent use.
+type Anonymizer struct {
+	salt []byte
+	// Rules indexed by the name of the label they apply to.
+	rules map[string][]compiledRule
+}
+
+type compiledRule struct {
+	regex  *regexp.Regexp
+	action string
+}
+
+// New returns an Anonymizer applying the rules of args.
+func New(args Arguments) (*Anonymizer, error) {
+	a := &Anonymizer{
+		salt:  []byte(args.Salt),
+		rules: make(map[string][]compiledRule),
+	}
+	for _, r := range args.Rules {
+		re, err := compile(r.Regex)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range r.Labels {
+			a.rules[name] = append(a.rules[name], compiledRule{regex: re, action: r.Action})
+		}
+	}
+	return a, nil
+}
+
+// Applies reports whether the value of the label name can be changed by a.
+func (a *Anonymizer) Applies(name string) bool {
+	_, ok := a.rules[name]
+	return ok
+}
+
+// Value returns the anonymized value of the label name. It returns false if
+// the label must be dropped. Empty values are kept as is, since they are the
+// same as missing labels.
+//
+// The first rule whose regex matches the value is applied.
+func (a *Anonymizer) Value(name, value string) (string, bool) {
+	if value == "" {
+		return value, true
+	}
+	for _, r := range a.rules[name] {
+		if !r.regex.MatchString(value) {
+			continue
+		}
+		if r.action == ActionDrop {
+			return "", false
+		}
+		return a.hash(value), true
+	}
+	return value, true
+}
+
+// hash returns the hex-encoded HMAC-SHA256 of value keyed with the salt,
+// truncated to 128 bits.
+func (a *Anonymizer) hash(value string) string {
+	h := hmac.New(sha256.New, a.salt)
+	_, _ = h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
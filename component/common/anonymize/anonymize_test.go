@@ -0,0 +1,83 @@
+package anonymize
+
+import (
+	"testing"
+
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	salt = "s3cr3t"
+
+	rule {
+		labels = ["user_id"]
+	}
+	rule {
+		labels = ["email"]
+		regex  = ".*@example.com"
+		action = "drop"
+	}
+`), &args))
+	require.Equal(t, []Rule{
+		{Labels: []string{"user_id"}, Regex: ".*", Action: ActionHash},
+		{Labels: []string{"email"}, Regex: ".*@example.com", Action: ActionDrop},
+	}, args.Rules)
+
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	rule {
+		labels = ["user_id"]
+		action = "mask"
+	}
+`), &args), `action must be "hash" or "drop", got "mask"`)
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	rule {
+		labels = []
+	}
+`), &args), "labels must not be empty")
+}
+
+func TestAnonymizer(t *testing.T) {
+	newAnonymizer := func(salt string) *Anonymizer {
+		a, err := New(Arguments{
+			Salt: rivertypes.Secret(salt),
+			Rules: []Rule{
+				{Labels: []string{"email"}, Regex: ".*@example.com", Action: ActionDrop},
+				{Labels: []string{"user_id", "email"}, Regex: ".*", Action: ActionHash},
+			},
+		})
+		require.NoError(t, err)
+		return a
+	}
+	a := newAnonymizer("salt")
+
+	hashed, ok := a.Value("user_id", "42")
+	require.True(t, ok)
+	require.Len(t, hashed, 32)
+	require.NotEqual(t, "42", hashed)
+
+	// Hashes are deterministic, and depend on the salt.
+	again, _ := a.Value("user_id", "42")
+	require.Equal(t, hashed, again)
+	other, _ := newAnonymizer("other").Value("user_id", "42")
+	require.NotEqual(t, hashed, other)
+
+	// The first matching rule is applied.
+	_, ok = a.Value("email", "jane@example.com")
+	require.False(t, ok)
+	v, ok := a.Value("email", "jane@example.org")
+	require.True(t, ok)
+	require.NotEqual(t, "jane@example.org", v)
+
+	// Other labels and empty values are kept.
+	require.False(t, a.Applies("job"))
+	v, ok = a.Value("job", "api")
+	require.True(t, ok)
+	require.Equal(t, "api", v)
+	v, ok = a.Value("user_id", "")
+	require.True(t, ok)
+	require.Empty(t, v)
+}
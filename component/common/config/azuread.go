@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/google/uuid"
+	"github.com/prometheus/prometheus/storage/remote/azuread"
+	"golang.org/x/oauth2"
+)
+
+// ManagedIdentityConfig is used to store managed identity config values
+type ManagedIdentityConfig struct {
+	// ClientID is the clientId of the managed identity that is being used to authenticate.
+	ClientID string `river:"client_id,attr"`
+}
+
+// Convert converts our type to the native prometheus type
+func (m ManagedIdentityConfig) Convert() azuread.ManagedIdentityConfig {
+	return azuread.ManagedIdentityConfig{
+		ClientID: m.ClientID,
+	}
+}
+
+// AzureADConfig configures authentication with Azure AD.
+type AzureADConfig struct {
+	// ManagedIdentity is the managed identity that is being used to authenticate.
+	ManagedIdentity ManagedIdentityConfig `river:"managed_identity,block"`
+
+	// Cloud is the Azure cloud in which the service is running. Example: AzurePublic/AzureGovernment/AzureChina.
+	Cloud string `river:"cloud,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (a *AzureADConfig) Validate() error {
+	if a.Cloud != azuread.AzureChina && a.Cloud != azuread.AzureGovernment && a.Cloud != azuread.AzurePublic {
+		return fmt.Errorf("must provide a cloud in the Azure AD config")
+	}
+
+	_, err := uuid.Parse(a.ManagedIdentity.ClientID)
+	if err != nil {
+		return fmt.Errorf("the provided Azure Managed Identity client_id provided is invalid")
+	}
+
+	return nil
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *AzureADConfig) SetToDefault() {
+	*a = AzureADConfig{
+		Cloud: azuread.AzurePublic,
+	}
+}
+
+// Convert converts our type to the native prometheus type
+func (a *AzureADConfig) Convert() *azuread.AzureADConfig {
+	if a == nil {
+		return nil
+	}
+
+	mangedIdentity := a.ManagedIdentity.Convert()
+	return &azuread.AzureADConfig{
+		ManagedIdentity: &mangedIdentity,
+		Cloud:           a.Cloud,
+	}
+}
+
+// TokenSource returns a source of access tokens for the Azure Monitor
+// ingestion audience of the cloud, obtained with the managed identity. Tokens
+// are cached and refreshed shortly before they expire.
+func (a *AzureADConfig) TokenSource() (oauth2.TokenSource, error) {
+	var audience string
+	switch strings.ToLower(a.Cloud) {
+	case strings.ToLower(azuread.AzureChina):
+		audience = azuread.IngestionChinaAudience
+	case strings.ToLower(azuread.AzureGovernment):
+		audience = azuread.IngestionGovernmentAudience
+	case strings.ToLower(azuread.AzurePublic):
+		audience = azuread.IngestionPublicAudience
+	default:
+		return nil, fmt.Errorf("unsupported Azure cloud %q", a.Cloud)
+	}
+
+	cred, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+		ID: azidentity.ClientID(a.ManagedIdentity.ClientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.ReuseTokenSource(nil, &azureTokenSource{cred: cred, audience: audience}), nil
+}
+
+// azureTokenSource adapts an Azure credential to an oauth2.TokenSource.
+type azureTokenSource struct {
+	cred     azcore.TokenCredential
+	audience string
+}
+
+// Token implements oauth2.TokenSource.
+func (s *azureTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{s.audience}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Azure AD access token: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: tok.Token,
+		TokenType:   "Bearer",
+		Expiry:      tok.ExpiresOn,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/storage/remote/azuread"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestAzureADTokenSource(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-IDENTITY-HEADER") != "fake-identity-header":
+			http.Error(w, "invalid identity header", http.StatusUnauthorized)
+			return
+		case r.URL.Query().Get("resource") != "https://monitor.azure.com/":
+			http.Error(w, "unexpected resource", http.StatusBadRequest)
+			return
+		case r.URL.Query().Get("client_id") != "00000000-0000-0000-0000-000000000000":
+			http.Error(w, "unexpected client id", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_on": "%d"}`, requests.Inc(), time.Now().Add(time.Hour).Unix())
+	}))
+	defer srv.Close()
+
+	// Fake the managed identity endpoint of Azure App Service.
+	t.Setenv("IDENTITY_ENDPOINT", srv.URL)
+	t.Setenv("IDENTITY_HEADER", "fake-identity-header")
+
+	args := AzureADConfig{
+		ManagedIdentity: ManagedIdentityConfig{ClientID: "00000000-0000-0000-0000-000000000000"},
+		Cloud:           azuread.AzurePublic,
+	}
+	ts, err := args.TokenSource()
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		tok, err := ts.Token()
+		require.NoError(t, err)
+		require.Equal(t, "token-1", tok.AccessToken, "valid tokens should be cached")
+		require.Equal(t, "Bearer", tok.Type())
+	}
+}
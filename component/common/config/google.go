@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/grafana/river/rivertypes"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultGoogleCredentials holds default settings for GoogleCredentials.
+var DefaultGoogleCredentials = GoogleCredentials{
+	Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+}
+
+// GoogleCredentials configures how to obtain OAuth2 access tokens for Google
+// Cloud. When neither Credentials nor CredentialsFile is set, Google
+// Application Default Credentials are used.
+type GoogleCredentials struct {
+	Credentials     rivertypes.Secret `river:"credentials,attr,optional"`
+	CredentialsFile string            `river:"credentials_file,attr,optional"`
+	Scopes          []string          `river:"scopes,attr,optional"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (g *GoogleCredentials) SetToDefault() {
+	*g = GoogleCredentials{
+		Scopes: append([]string{}, DefaultGoogleCredentials.Scopes...),
+	}
+}
+
+// Validate implements river.Validator.
+func (g *GoogleCredentials) Validate() error {
+	if g.Credentials != "" && g.CredentialsFile != "" {
+		return fmt.Errorf("at most one of credentials & credentials_file must be configured")
+	}
+	if len(g.Scopes) == 0 {
+		return fmt.Errorf("at least one scope must be configured")
+	}
+	return nil
+}
+
+// TokenSource returns a source of access tokens for the credentials. Tokens
+// are cached and refreshed shortly before they expire. ctx is used to
+// retrieve the tokens and must remain valid as long as the token source is
+// used.
+func (g *GoogleCredentials) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	var data []byte
+	switch {
+	case g.Credentials != "":
+		data = []byte(g.Credentials)
+	case g.CredentialsFile != "":
+		bb, err := os.ReadFile(g.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read google credentials file: %w", err)
+		}
+		data = bb
+	default:
+		creds, err := google.FindDefaultCredentials(ctx, g.Scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find google application default credentials: %w", err)
+		}
+		return creds.TokenSource, nil
+	}
+
+	creds, err := google.CredentialsFromJSON(ctx, data, g.Scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load google credentials: %w", err)
+	}
+	return creds.TokenSource, nil
+}
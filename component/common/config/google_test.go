@@ -0,0 +1,137 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestGoogleCredentials(t *testing.T) {
+	var (
+		exampleRiverConfig = `
+	credentials_file = "/path/to/credentials.json"
+	scopes           = ["https://www.googleapis.com/auth/monitoring.write"]
+`
+		args GoogleCredentials
+	)
+	require.NoError(t, river.Unmarshal([]byte(exampleRiverConfig), &args))
+	require.Equal(t, GoogleCredentials{
+		CredentialsFile: "/path/to/credentials.json",
+		Scopes:          []string{"https://www.googleapis.com/auth/monitoring.write"},
+	}, args)
+
+	var defaults GoogleCredentials
+	require.NoError(t, river.Unmarshal([]byte(``), &defaults))
+	require.Equal(t, DefaultGoogleCredentials, defaults)
+}
+
+func TestBadGoogleCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "credentials and credentials_file",
+			config: `
+	credentials      = "{}"
+	credentials_file = "/path/to/credentials.json"
+`,
+			err: "at most one of credentials & credentials_file must be configured",
+		},
+		{
+			name:   "no scopes",
+			config: `scopes = []`,
+			err:    "at least one scope must be configured",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args GoogleCredentials
+			require.EqualError(t, river.Unmarshal([]byte(tc.config), &args), tc.err)
+		})
+	}
+}
+
+func TestGoogleCredentialsTokenSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn int
+		expected  []string
+	}{
+		// Tokens are cached until shortly before they expire.
+		{name: "valid token", expiresIn: 3600, expected: []string{"token-1", "token-1"}},
+		{name: "expiring token", expiresIn: 5, expected: []string{"token-1", "token-2"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, requests := newFakeGoogleTokenServer(t, tc.expiresIn)
+
+			creds := fakeGoogleCredentials(srv.URL)
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			require.NoError(t, os.WriteFile(path, []byte(creds), 0600))
+			// Application Default Credentials are found through the
+			// environment.
+			t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+
+			for _, args := range []GoogleCredentials{
+				{Credentials: rivertypes.Secret(creds), Scopes: DefaultGoogleCredentials.Scopes},
+				{CredentialsFile: path, Scopes: DefaultGoogleCredentials.Scopes},
+				{Scopes: DefaultGoogleCredentials.Scopes},
+			} {
+				requests.Store(0)
+
+				ts, err := args.TokenSource(context.Background())
+				require.NoError(t, err)
+
+				var tokens []string
+				for range tc.expected {
+					tok, err := ts.Token()
+					require.NoError(t, err)
+					require.Equal(t, "Bearer", tok.Type())
+					tokens = append(tokens, tok.AccessToken)
+				}
+				require.Equal(t, tc.expected, tokens)
+			}
+		})
+	}
+}
+
+// newFakeGoogleTokenServer returns a server which issues tokens named after
+// the number of requests it received.
+func newFakeGoogleTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("refresh_token") != "fake-refresh-token" {
+			http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, requests.Inc(), expiresIn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// fakeGoogleCredentials returns user credentials which are exchanged for
+// tokens at the token endpoint of url.
+func fakeGoogleCredentials(url string) string {
+	return fmt.Sprintf(`{
+	"type": "authorized_user",
+	"client_id": "fake-client-id",
+	"client_secret": "fake-client-secret",
+	"refresh_token": "fake-refresh-token",
+	"token_uri": "%s/token"
+}`, url)
+}
@@ -1,10 +1,18 @@
 package config
 
 import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/grafana/river"
+	"github.com/prometheus/common/config"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"gopkg.in/yaml.v2"
 )
 
 func TestHTTPClientConfigBearerToken(t *testing.T) {
@@ -230,3 +238,136 @@ func TestHTTPClientBadConfig(t *testing.T) {
 	err := river.Unmarshal([]byte(exampleRiverConfig), &httpClientConfig)
 	require.ErrorContains(t, err, "at most one of bearer_token & bearer_token_file must be configured")
 }
+
+// TestOAuth2ClientCredentials ensures that clients built from an oauth2 block
+// fetch tokens with the client credentials grant, cache them, send them as
+// bearer tokens, and refresh them before they expire.
+func TestOAuth2ClientCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn int
+		expected  []string
+	}{
+		{name: "valid token", expiresIn: 3600, expected: []string{"Bearer token-1", "Bearer token-1"}},
+		// Tokens are refreshed 10 seconds before they expire.
+		{name: "expiring token", expiresIn: 5, expected: []string{"Bearer token-1", "Bearer token-2"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var tokens atomic.Int64
+			tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id, secret, ok := r.BasicAuth()
+				if err := r.ParseForm(); err != nil || !ok || id != "client_id" || secret != "client_secret" ||
+					r.PostForm.Get("grant_type") != "client_credentials" ||
+					r.PostForm.Get("scope") != "scope1 scope2" ||
+					r.PostForm.Get("audience") != "agent" {
+
+					http.Error(w, "invalid client", http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, tokens.Inc(), tc.expiresIn)
+			}))
+			defer tokenSrv.Close()
+
+			authorizations := make(chan string, len(tc.expected))
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorizations <- r.Header.Get("Authorization")
+			}))
+			defer srv.Close()
+
+			var httpClientConfig HTTPClientConfig
+			require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	oauth2 {
+		client_id       = "client_id"
+		client_secret   = "client_secret"
+		scopes          = ["scope1", "scope2"]
+		token_url       = "%s/token"
+		endpoint_params = {"audience" = "agent"}
+	}
+`, tokenSrv.URL)), &httpClientConfig))
+
+			client, err := config.NewClientFromConfig(*httpClientConfig.Convert(), "test")
+			require.NoError(t, err)
+
+			for _, expect := range tc.expected {
+				resp, err := client.Get(srv.URL)
+				require.NoError(t, err)
+				resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.Equal(t, expect, <-authorizations)
+			}
+		})
+	}
+}
+
+func TestOAuth2ClientSecretRedacted(t *testing.T) {
+	var httpClientConfig HTTPClientConfig
+	require.NoError(t, river.Unmarshal([]byte(`
+	oauth2 {
+		client_id     = "client_id"
+		client_secret = "sup3r_s3cr3t"
+		token_url     = "http://localhost/token"
+	}
+`), &httpClientConfig))
+
+	bb, err := river.Marshal(httpClientConfig)
+	require.NoError(t, err)
+	require.NotContains(t, string(bb), "sup3r_s3cr3t")
+
+	bb, err = yaml.Marshal(httpClientConfig.Convert())
+	require.NoError(t, err)
+	require.NotContains(t, string(bb), "sup3r_s3cr3t")
+}
+
+func TestHTTPClientConfigEnableHTTP2(t *testing.T) {
+	tests := []struct {
+		enableHTTP2 bool
+		expected    string
+	}{
+		{enableHTTP2: true, expected: "HTTP/2.0"},
+		{enableHTTP2: false, expected: "HTTP/1.1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			var (
+				protos = make(chan string, 10)
+				conns  atomic.Int64
+			)
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				protos <- r.Proto
+			}))
+			srv.EnableHTTP2 = true
+			srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					conns.Inc()
+				}
+			}
+			srv.StartTLS()
+			defer srv.Close()
+
+			var args HTTPClientConfig
+			require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	enable_http2 = %t
+
+	tls_config {
+		insecure_skip_verify = true
+	}`, tc.enableHTTP2)), &args))
+
+			client, err := config.NewClientFromConfig(*args.Convert(), "test")
+			require.NoError(t, err)
+			defer client.CloseIdleConnections()
+
+			for i := 0; i < 3; i++ {
+				resp, err := client.Get(srv.URL)
+				require.NoError(t, err)
+				_, _ = io.Copy(io.Discard, resp.Body)
+				require.NoError(t, resp.Body.Close())
+				require.Equal(t, tc.expected, <-protos)
+			}
+
+			// Both protocols reuse the connection across requests.
+			require.Equal(t, int64(1), conns.Load())
+		})
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
-	"github.com/golang/snappy"
 	"github.com/prometheus/common/model"
 	"golang.org/x/exp/slices"
 
@@ -154,20 +152,28 @@ func (b *batch) sizeBytesAfter(line string) int {
 	return b.totalBytes + len(line)
 }
 
+// entryCount returns the number of entries in the batch
+func (b *batch) entryCount() int {
+	count := 0
+	for _, stream := range b.streams {
+		count += len(stream.Entries)
+	}
+	return count
+}
+
 // age of the batch since its creation
 func (b *batch) age() time.Duration {
 	return time.Since(b.createdAt)
 }
 
-// encode the batch as snappy-compressed push request, and returns
+// encode the batch as push request with the given encoding, and returns
 // the encoded bytes and the number of encoded entries
-func (b *batch) encode() ([]byte, int, error) {
+func (b *batch) encode(encoding string) ([]byte, int, error) {
 	req, entriesCount := b.createPushRequest()
-	buf, err := proto.Marshal(req)
+	buf, err := encodePushRequest(req, encoding)
 	if err != nil {
 		return nil, 0, err
 	}
-	buf = snappy.Encode(nil, buf)
 	return buf, entriesCount, nil
 }
 
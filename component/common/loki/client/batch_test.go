@@ -126,7 +126,7 @@ func TestBatch_encode(t *testing.T) {
 		t.Run(testName, func(t *testing.T) {
 			t.Parallel()
 
-			_, entriesCount, err := testData.inputBatch.encode()
+			_, entriesCount, err := testData.inputBatch.encode(EncodingSnappy)
 			require.NoError(t, err)
 			assert.Equal(t, testData.expectedEntriesCount, entriesCount)
 		})
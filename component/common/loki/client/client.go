@@ -33,18 +33,24 @@ const (
 	// pipeline stages
 	ReservedLabelTenantID = "__tenant_id__"
 
-	LatencyLabel = "filename"
-	HostLabel    = "host"
-	ClientLabel  = "client"
-	TenantLabel  = "tenant"
-	ReasonLabel  = "reason"
+	LatencyLabel  = "filename"
+	HostLabel     = "host"
+	ClientLabel   = "client"
+	TenantLabel   = "tenant"
+	EncodingLabel = "encoding"
+	ReasonLabel   = "reason"
 
 	ReasonGeneric       = "ingester_error"
 	ReasonRateLimited   = "rate_limited"
 	ReasonStreamLimited = "stream_limited"
 	ReasonLineTooLong   = "line_too_long"
+	ReasonTooOld        = "too_old"
+	ReasonTooNew        = "too_far_in_future"
 )
 
+// Reasons are the reasons for which the dropped and mutated counters are
+// initialized. Entries are only dropped for the ReasonTooOld and ReasonTooNew
+// reasons when Config.MaxAge is set.
 var Reasons = []string{ReasonGeneric, ReasonRateLimited, ReasonStreamLimited, ReasonLineTooLong}
 
 var userAgent = useragent.Get()
@@ -59,6 +65,7 @@ type Metrics struct {
 	mutatedBytes                 *prometheus.CounterVec
 	requestDuration              *prometheus.HistogramVec
 	batchRetries                 *prometheus.CounterVec
+	pendingEntries               *prometheus.GaugeVec
 	countersWithHost             []*prometheus.CounterVec
 	countersWithHostTenant       []*prometheus.CounterVec
 	countersWithHostTenantReason []*prometheus.CounterVec
@@ -70,11 +77,11 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 	m.encodedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "loki_write_encoded_bytes_total",
 		Help: "Number of bytes encoded and ready to send.",
-	}, []string{HostLabel})
+	}, []string{HostLabel, EncodingLabel})
 	m.sentBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "loki_write_sent_bytes_total",
 		Help: "Number of bytes sent.",
-	}, []string{HostLabel})
+	}, []string{HostLabel, EncodingLabel})
 	m.droppedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "loki_write_dropped_bytes_total",
 		Help: "Number of bytes dropped because failed to be sent to the ingester after all retries.",
@@ -103,9 +110,13 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Name: "loki_write_batch_retries_total",
 		Help: "Number of times batches has had to be retried.",
 	}, []string{HostLabel, TenantLabel})
+	m.pendingEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loki_write_pending_entries",
+		Help: "Number of log entries held in memory waiting to be sent.",
+	}, []string{HostLabel})
 
 	m.countersWithHost = []*prometheus.CounterVec{
-		m.encodedBytes, m.sentBytes, m.sentEntries,
+		m.sentEntries,
 	}
 
 	m.countersWithHostTenant = []*prometheus.CounterVec{
@@ -126,6 +137,7 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		m.mutatedBytes = util.MustRegisterOrGet(reg, m.mutatedBytes).(*prometheus.CounterVec)
 		m.requestDuration = util.MustRegisterOrGet(reg, m.requestDuration).(*prometheus.HistogramVec)
 		m.batchRetries = util.MustRegisterOrGet(reg, m.batchRetries).(*prometheus.CounterVec)
+		m.pendingEntries = util.MustRegisterOrGet(reg, m.pendingEntries).(*prometheus.GaugeVec)
 	}
 
 	return &m
@@ -136,6 +148,9 @@ type Client interface {
 	loki.EntryHandler
 	// Stop goroutine sending batch of entries without retries.
 	StopNow()
+	// Drain sends the pending batches of entries right away, blocking until
+	// they're sent or ctx is canceled.
+	Drain(ctx context.Context) error
 	Name() string
 }
 
@@ -147,6 +162,7 @@ type client struct {
 	cfg     Config
 	client  *http.Client
 	entries chan loki.Entry
+	drain   chan chan struct{} // Requests to send the pending batches.
 
 	once sync.Once
 	wg   sync.WaitGroup
@@ -183,6 +199,7 @@ func newClient(metrics *Metrics, cfg Config, maxStreams, maxLineSize int, maxLin
 		logger:  log.With(logger, "component", "client", "host", cfg.URL.Host),
 		cfg:     cfg,
 		entries: make(chan loki.Entry),
+		drain:   make(chan chan struct{}),
 		metrics: metrics,
 		name:    GetClientName(cfg),
 
@@ -214,6 +231,9 @@ func newClient(metrics *Metrics, cfg Config, maxStreams, maxLineSize int, maxLin
 	for _, counter := range c.metrics.countersWithHost {
 		counter.WithLabelValues(c.cfg.URL.Host).Add(0)
 	}
+	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(0)
+	c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(0)
+	c.metrics.pendingEntries.WithLabelValues(c.cfg.URL.Host).Add(0)
 
 	c.wg.Add(1)
 	go c.run()
@@ -284,6 +304,12 @@ func (c *client) run() {
 
 			e, tenantID := c.processEntry(e)
 
+			if reason := c.cfg.ageDropReason(e.Timestamp, time.Now()); reason != "" {
+				c.metrics.droppedEntries.WithLabelValues(c.cfg.URL.Host, tenantID, reason).Inc()
+				c.metrics.droppedBytes.WithLabelValues(c.cfg.URL.Host, tenantID, reason).Add(float64(len(e.Line)))
+				break
+			}
+
 			// Either drop or mutate the log entry because its length is greater than maxLineSize. maxLineSize == 0 means disabled.
 			if c.maxLineSize != 0 && len(e.Line) > c.maxLineSize {
 				if !c.maxLineSizeTruncate {
@@ -302,6 +328,7 @@ func (c *client) run() {
 			// If the batch doesn't exist yet, we create a new one with the entry
 			if !ok {
 				batches[tenantID] = newBatch(c.maxStreams, e)
+				c.metrics.pendingEntries.WithLabelValues(c.cfg.URL.Host).Inc()
 				c.initBatchMetrics(tenantID)
 				break
 			}
@@ -312,6 +339,7 @@ func (c *client) run() {
 				c.sendBatch(tenantID, batch)
 
 				batches[tenantID] = newBatch(c.maxStreams, e)
+				c.metrics.pendingEntries.WithLabelValues(c.cfg.URL.Host).Inc()
 				break
 			}
 
@@ -327,6 +355,7 @@ func (c *client) run() {
 				c.metrics.droppedEntries.WithLabelValues(c.cfg.URL.Host, tenantID, reason).Inc()
 				return
 			}
+			c.metrics.pendingEntries.WithLabelValues(c.cfg.URL.Host).Inc()
 		case <-maxWaitCheck.C:
 			// Send all batches whose max wait time has been reached
 			for tenantID, batch := range batches {
@@ -337,6 +366,12 @@ func (c *client) run() {
 				c.sendBatch(tenantID, batch)
 				delete(batches, tenantID)
 			}
+		case done := <-c.drain:
+			for tenantID, batch := range batches {
+				c.sendBatch(tenantID, batch)
+				delete(batches, tenantID)
+			}
+			close(done)
 		}
 	}
 }
@@ -345,18 +380,42 @@ func (c *client) Chan() chan<- loki.Entry {
 	return c.entries
 }
 
+// Drain sends the pending batches without waiting for them to be full or old
+// enough, blocking until they're sent or ctx is canceled. Entries sent to the
+// client before Drain is called are part of the pending batches.
+func (c *client) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case c.drain <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func batchIsRateLimited(status int) bool {
 	return status == 429
 }
 
 func (c *client) sendBatch(tenantID string, batch *batch) {
-	buf, entriesCount, err := batch.encode()
+	// The entries of the batch are no longer pending once the batch is either
+	// sent or dropped. While a batch is being sent, the run loop doesn't accept
+	// new entries, which applies backpressure to the components sending logs
+	// to this client.
+	defer c.metrics.pendingEntries.WithLabelValues(c.cfg.URL.Host).Sub(float64(batch.entryCount()))
+
+	buf, entriesCount, err := batch.encode(c.cfg.Encoding)
 	if err != nil {
 		level.Error(c.logger).Log("msg", "error encoding batch", "error", err)
 		return
 	}
 	bufBytes := float64(len(buf))
-	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
+	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(bufBytes)
 
 	backoff := backoff.New(c.ctx, c.cfg.BackoffConfig)
 	var status int
@@ -376,7 +435,7 @@ func (c *client) sendBatch(tenantID string, batch *batch) {
 		}
 
 		if err == nil {
-			c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
+			c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(bufBytes)
 			c.metrics.sentEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
 
 			return
@@ -418,7 +477,7 @@ func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, er
 		return -1, err
 	}
 	req = req.WithContext(ctx)
-	req.Header.Set("Content-Type", contentType)
+	setEncodingHeaders(req, c.cfg.Encoding)
 	req.Header.Set("User-Agent", userAgent)
 
 	// If the tenant ID is not empty promtail is running in multi-tenant mode, so
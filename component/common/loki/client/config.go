@@ -19,6 +19,10 @@ const (
 	MaxBackoff     = 5 * time.Minute
 	MaxRetries int = 10
 	Timeout        = 10 * time.Second
+
+	// FutureTolerance is how far in the future entries can be when MaxAge is
+	// set, matching the default creation grace period of Loki.
+	FutureTolerance = 10 * time.Minute
 )
 
 // Config describes configuration for an HTTP pusher client.
@@ -45,6 +49,16 @@ type Config struct {
 	// prevent HOL blocking in multitenant deployments.
 	DropRateLimitedBatches bool `yaml:"drop_rate_limited_batches"`
 
+	// Encoding of the push requests, one of Encodings. Requests are
+	// snappy-compressed if empty.
+	Encoding string `yaml:"encoding,omitempty"`
+
+	// Entries older than MaxAge, or more than FutureTolerance in the future,
+	// are dropped instead of being sent, since Loki rejects them. Entries
+	// aren't dropped based on their timestamp if MaxAge is 0.
+	MaxAge          time.Duration `yaml:"max_age,omitempty"`
+	FutureTolerance time.Duration `yaml:"future_tolerance,omitempty"`
+
 	StreamLagLabels flagext.StringSliceCSV `yaml:"stream_lag_labels" doc:"deprecated"`
 
 	// Queue controls configuration parameters specific to the queue client
@@ -65,6 +79,21 @@ type QueueConfig struct {
 	DrainTimeout time.Duration
 }
 
+// ageDropReason returns the reason to drop an entry with timestamp ts at
+// time now, or an empty string if it must be sent.
+func (c *Config) ageDropReason(ts, now time.Time) string {
+	switch {
+	case c.MaxAge <= 0:
+		return ""
+	case ts.Before(now.Add(-c.MaxAge)):
+		return ReasonTooOld
+	case ts.After(now.Add(c.FutureTolerance)):
+		return ReasonTooNew
+	default:
+		return ""
+	}
+}
+
 // RegisterFlags with prefix registers flags where every name is prefixed by
 // prefix. If prefix is a non-empty string, prefix should end with a period.
 func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/loki/pkg/logproto"
+	promql_parser "github.com/prometheus/prometheus/promql/parser"
+)
+
+// Encodings of the push requests sent to Loki.
+const (
+	// EncodingSnappy sends snappy-compressed protobuf requests.
+	EncodingSnappy = "snappy"
+	// EncodingGzip sends gzip-compressed JSON requests.
+	EncodingGzip = "gzip"
+	// EncodingNone sends uncompressed JSON requests.
+	EncodingNone = "none"
+)
+
+// Encodings lists the supported encodings of the push requests.
+var Encodings = []string{EncodingSnappy, EncodingGzip, EncodingNone}
+
+const jsonContentType = "application/json"
+
+// encodingLabel returns the value of the encoding label of the metrics for
+// encoding.
+func encodingLabel(encoding string) string {
+	if encoding == "" {
+		return EncodingSnappy
+	}
+	return encoding
+}
+
+// encodePushRequest encodes req with encoding. The JSON encodings use the
+// format of the JSON push API of Loki, since Loki expects protobuf requests
+// to always be snappy-compressed.
+func encodePushRequest(req *logproto.PushRequest, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingSnappy, "":
+		buf, err := proto.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		return snappy.Encode(nil, buf), nil
+	case EncodingNone:
+		return marshalPushRequestJSON(req)
+	case EncodingGzip:
+		buf, err := marshalPushRequestJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		if _, err := w.Write(buf); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return compressed.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// setEncodingHeaders sets the headers describing the body of a push request
+// encoded with encoding.
+func setEncodingHeaders(req *http.Request, encoding string) {
+	switch encoding {
+	case EncodingNone:
+		req.Header.Set("Content-Type", jsonContentType)
+	case EncodingGzip:
+		req.Header.Set("Content-Type", jsonContentType)
+		req.Header.Set("Content-Encoding", "gzip")
+	default:
+		req.Header.Set("Content-Type", contentType)
+	}
+}
+
+type jsonPushRequest struct {
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	// Every value holds the timestamp in nanoseconds, the line, and optionally
+	// the structured metadata of an entry.
+	Values [][]any `json:"values"`
+}
+
+func marshalPushRequestJSON(req *logproto.PushRequest) ([]byte, error) {
+	res := jsonPushRequest{Streams: make([]jsonStream, 0, len(req.Streams))}
+	for _, s := range req.Streams {
+		lbls, err := promql_parser.ParseMetric(s.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stream labels %s: %w", s.Labels, err)
+		}
+		stream := jsonStream{
+			Stream: lbls.Map(),
+			Values: make([][]any, 0, len(s.Entries)),
+		}
+		for _, e := range s.Entries {
+			value := []any{strconv.FormatInt(e.Timestamp.UnixNano(), 10), e.Line}
+			if len(e.StructuredMetadata) > 0 {
+				metadata := make(map[string]string, len(e.StructuredMetadata))
+				for _, l := range e.StructuredMetadata {
+					metadata[l.Name] = l.Value
+				}
+				value = append(value, metadata)
+			}
+			stream.Values = append(stream.Values, value)
+		}
+		res.Streams = append(res.Streams, stream)
+	}
+	return json.Marshal(res)
+}
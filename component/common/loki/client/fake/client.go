@@ -1,6 +1,7 @@
 package fake
 
 import (
+	"context"
 	"sync"
 
 	"github.com/grafana/agent/component/common/loki"
@@ -57,6 +58,11 @@ func (c *Client) StopNow() {
 	c.Stop()
 }
 
+// Drain implements client.Client
+func (c *Client) Drain(_ context.Context) error {
+	return nil
+}
+
 func (c *Client) Name() string {
 	return "fake"
 }
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
@@ -85,6 +86,9 @@ func (l *logger) run() {
 }
 func (l *logger) StopNow() { l.Stop() }
 
+// Drain does nothing since entries are written as soon as they're received.
+func (l *logger) Drain(_ context.Context) error { return nil }
+
 func (l *logger) Name() string {
 	return ""
 }
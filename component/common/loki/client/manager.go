@@ -1,7 +1,9 @@
 package client
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -42,6 +44,13 @@ type Stoppable interface {
 type StoppableClient interface {
 	Stop()
 	StopNow()
+	Drain(ctx context.Context) error
+}
+
+// drainRequest is a request to drain the clients of a Manager.
+type drainRequest struct {
+	ctx  context.Context
+	done chan error
 }
 
 // Manager manages remote write client instantiation, and connects the related components to orchestrate the flow of loki.Entry
@@ -59,6 +68,7 @@ type Manager struct {
 	stoppableClients []StoppableClient
 
 	entries chan loki.Entry
+	drain   chan drainRequest
 	once    sync.Once
 
 	wg sync.WaitGroup
@@ -132,6 +142,7 @@ func NewManager(metrics *Metrics, logger log.Logger, limits limit.Config, reg pr
 		stoppableClients: stoppableClients,
 		walWatchers:      watchers,
 		entries:          make(chan loki.Entry),
+		drain:            make(chan drainRequest),
 	}
 	if walCfg.Enabled {
 		manager.name = buildManagerName("wal", clientCfgs...)
@@ -152,9 +163,16 @@ func (m *Manager) startWithConsume() {
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
-		// discard read entries
-		//nolint:revive
-		for range m.entries {
+		for {
+			select {
+			case _, ok := <-m.entries:
+				// discard read entries
+				if !ok {
+					return
+				}
+			case req := <-m.drain:
+				req.done <- m.drainClients(req.ctx)
+			}
 		}
 	}()
 }
@@ -165,14 +183,50 @@ func (m *Manager) startWithForward() {
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
-		for e := range m.entries {
-			for _, c := range m.clients {
-				c.Chan() <- e
+		for {
+			select {
+			case e, ok := <-m.entries:
+				if !ok {
+					return
+				}
+				for _, c := range m.clients {
+					c.Chan() <- e
+				}
+			case req := <-m.drain:
+				// Entries received before are already forwarded to the clients.
+				req.done <- m.drainClients(req.ctx)
 			}
 		}
 	}()
 }
 
+// Drain sends the batches pending in the clients, blocking until they're sent
+// or ctx is canceled. Entries sent to the manager before Drain is called are
+// part of the pending batches, unless the WAL is enabled, in which case they're
+// sent once read from the WAL.
+func (m *Manager) Drain(ctx context.Context) error {
+	req := drainRequest{ctx: ctx, done: make(chan error, 1)}
+	select {
+	case m.drain <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) drainClients(ctx context.Context) error {
+	var errs error
+	for _, c := range m.stoppableClients {
+		errs = errors.Join(errs, c.Drain(ctx))
+	}
+	return errs
+}
+
 func (m *Manager) StopNow() {
 	for _, c := range m.stoppableClients {
 		c.StopNow()
@@ -31,6 +31,7 @@ type StoppableWriteTo interface {
 	agentWal.WriteTo
 	Stoppable
 	StopNow()
+	Drain(ctx context.Context) error
 }
 
 // MarkerHandler re-defines the interface of internal.MarkerHandler that the queue client interacts with, to contribute
@@ -239,6 +240,8 @@ func newQueueClient(metrics *Metrics, qcMetrics *QueueClientMetrics, cfg Config,
 	for _, counter := range c.metrics.countersWithHost {
 		counter.WithLabelValues(c.cfg.URL.Host).Add(0)
 	}
+	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(0)
+	c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(0)
 
 	c.wg.Add(1)
 	go c.runSendOldBatches()
@@ -310,6 +313,12 @@ func (c *queueClient) AppendEntries(entries wal.RefEntries, segment int) error {
 func (c *queueClient) appendSingleEntry(segmentNum int, lbs model.LabelSet, e logproto.Entry) {
 	lbs, tenantID := c.processLabels(lbs)
 
+	if reason := c.cfg.ageDropReason(e.Timestamp, time.Now()); reason != "" {
+		c.metrics.droppedEntries.WithLabelValues(c.cfg.URL.Host, tenantID, reason).Inc()
+		c.metrics.droppedBytes.WithLabelValues(c.cfg.URL.Host, tenantID, reason).Add(float64(len(e.Line)))
+		return
+	}
+
 	// Either drop or mutate the log entry because its length is greater than maxLineSize. maxLineSize == 0 means disabled.
 	if c.maxLineSize != 0 && len(e.Line) > c.maxLineSize {
 		if !c.maxLineSizeTruncate {
@@ -450,14 +459,32 @@ func (c *queueClient) enqueuePendingBatches(ctx context.Context) {
 	}
 }
 
+// Drain enqueues the pending batches to be sent without waiting for them to be
+// full or old enough, blocking until they're enqueued or ctx is canceled.
+func (c *queueClient) Drain(ctx context.Context) error {
+	c.batchesMtx.Lock()
+	defer c.batchesMtx.Unlock()
+
+	for tenantID, batch := range c.batches {
+		if !c.sendQueue.enqueueWithCancel(ctx, queuedBatch{
+			TenantID: tenantID,
+			Batch:    batch,
+		}) {
+			return ctx.Err()
+		}
+		delete(c.batches, tenantID)
+	}
+	return nil
+}
+
 func (c *queueClient) sendBatch(ctx context.Context, tenantID string, batch *batch) {
-	buf, entriesCount, err := batch.encode()
+	buf, entriesCount, err := batch.encode(c.cfg.Encoding)
 	if err != nil {
 		level.Error(c.logger).Log("msg", "error encoding batch", "error", err)
 		return
 	}
 	bufBytes := float64(len(buf))
-	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
+	c.metrics.encodedBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(bufBytes)
 
 	backoff := backoff.New(c.ctx, c.cfg.BackoffConfig)
 	var status int
@@ -477,7 +504,7 @@ func (c *queueClient) sendBatch(ctx context.Context, tenantID string, batch *bat
 		}
 
 		if err == nil {
-			c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
+			c.metrics.sentBytes.WithLabelValues(c.cfg.URL.Host, encodingLabel(c.cfg.Encoding)).Add(bufBytes)
 			c.metrics.sentEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
 
 			return
@@ -519,7 +546,7 @@ func (c *queueClient) send(ctx context.Context, tenantID string, buf []byte) (in
 		return -1, err
 	}
 	req = req.WithContext(ctx)
-	req.Header.Set("Content-Type", contentType)
+	setEncodingHeaders(req, c.cfg.Encoding)
 	req.Header.Set("User-Agent", userAgent)
 
 	// If the tenant ID is not empty promtail is running in multi-tenant mode, so
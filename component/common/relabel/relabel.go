@@ -3,6 +3,7 @@ package relabel
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/grafana/regexp"
 	"github.com/prometheus/common/model"
@@ -186,6 +187,69 @@ func (rc *Config) Validate() error {
 	return nil
 }
 
+// templateRef matches the references to capture groups in the templates of
+// the target_label and replacement arguments, or an escaped `$`.
+var templateRef = regexp.MustCompile(`\$(?:\$|\{(\w+)\}|(\w+))`)
+
+// ValidateNames checks that the rules don't produce invalid label or metric
+// names, as far as the configuration of the rules tells. The names produced
+// from the values of labels are only known once relabeling.
+func ValidateNames(rcs []*Config) error {
+	for i, rc := range rcs {
+		if err := rc.validateNames(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (rc *Config) validateNames() error {
+	switch rc.Action {
+	case Lowercase, Uppercase:
+		// The target label isn't expanded for these actions.
+		if !model.LabelName(rc.TargetLabel).IsValid() {
+			return fmt.Errorf("%q is not a valid label name for the 'target_label' of the %s action, which doesn't expand references to capture groups", rc.TargetLabel, rc.Action)
+		}
+	case Replace:
+		if err := rc.validateRefs("target_label", rc.TargetLabel); err != nil {
+			return err
+		}
+		if err := rc.validateRefs("replacement", rc.Replacement); err != nil {
+			return err
+		}
+		if rc.TargetLabel == model.MetricNameLabel && !templateRef.MatchString(rc.Replacement) && !model.IsValidMetricName(model.LabelValue(rc.Replacement)) {
+			return fmt.Errorf("%q is not a valid metric name for the 'replacement' of %s", rc.Replacement, model.MetricNameLabel)
+		}
+	case LabelMap:
+		if err := rc.validateRefs("replacement", rc.Replacement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRefs checks that the capture groups referenced by the template of
+// the argument name exist in the regex. References to missing capture groups
+// expand to an empty string, which often comes from a name like `$1_suffix`
+// which should be written `${1}_suffix`.
+func (rc *Config) validateRefs(name, template string) error {
+	for _, match := range templateRef.FindAllStringSubmatch(template, -1) {
+		group := match[1] + match[2]
+		if group == "" {
+			// Escaped `$`.
+			continue
+		}
+		if idx, err := strconv.Atoi(group); err == nil && idx <= rc.Regex.NumSubexp() {
+			continue
+		}
+		if rc.Regex.SubexpIndex(group) >= 0 {
+			continue
+		}
+		return fmt.Errorf("the '%s' %q references the capture group %q, which the regex %q doesn't have", name, template, group, rc.Regex.String())
+	}
+	return nil
+}
+
 // ComponentToPromRelabelConfigs bridges the Component-based configuration of
 // relabeling steps to the Prometheus implementation.
 func ComponentToPromRelabelConfigs(rcs []*Config) []*relabel.Config {
@@ -46,6 +46,60 @@ func TestParseConfig(t *testing.T) {
 			`,
 			expectErr: true,
 		},
+		{
+			name: "keepequal with regex",
+			cfg: `
+			action = "keepequal"
+			target_label = "foo"
+			source_labels = ["bar"]
+			regex = "baz"
+			`,
+			expectErr: true,
+		},
+		{
+			name: "dropequal with separator",
+			cfg: `
+			action = "dropequal"
+			target_label = "foo"
+			source_labels = ["bar", "baz"]
+			separator = "-"
+			`,
+			expectErr: true,
+		},
+		{
+			name: "valid lowercase config",
+			cfg: `
+			action = "lowercase"
+			target_label = "foo"
+			source_labels = ["bar"]
+			`,
+		},
+		{
+			name: "missing lowercase target",
+			cfg: `
+			action = "lowercase"
+			source_labels = ["bar"]
+			`,
+			expectErr: true,
+		},
+		{
+			name: "missing uppercase target",
+			cfg: `
+			action = "uppercase"
+			source_labels = ["bar"]
+			`,
+			expectErr: true,
+		},
+		{
+			name: "uppercase with replacement",
+			cfg: `
+			action = "uppercase"
+			target_label = "foo"
+			source_labels = ["bar"]
+			replacement = "baz"
+			`,
+			expectErr: true,
+		},
 		{
 			name: "unknown action",
 			cfg: `
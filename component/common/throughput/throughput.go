@@ -0,0 +1,59 @@
+// Package throughput provides the metrics components use to report the
+// telemetry data flowing through them, for capacity planning.
+package throughput
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Telemetry signals of the throughput metrics.
+const (
+	SignalMetrics = "metrics"
+	SignalLogs    = "logs"
+	SignalTraces  = "traces"
+)
+
+// Metrics counts the items of a telemetry signal flowing through a component:
+// samples or data points for metrics, entries or records for logs, and spans
+// for traces. The registerer of the component adds the component_id label.
+type Metrics struct {
+	items prometheus.Counter
+	bytes prometheus.Counter
+}
+
+// New registers the metrics counting the items of signal flowing through a
+// component to reg. The size of the items is also counted if countBytes is
+// true, for the components which know it.
+func New(reg prometheus.Registerer, signal string, countBytes bool) (*Metrics, error) {
+	m := &Metrics{
+		items: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "agent_component_throughput_items_total",
+			Help:        "Total number of telemetry items flowing through the component, by signal.",
+			ConstLabels: prometheus.Labels{"signal": signal},
+		}),
+	}
+	if err := reg.Register(m.items); err != nil {
+		return nil, err
+	}
+
+	if countBytes {
+		m.bytes = prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "agent_component_throughput_bytes_total",
+			Help:        "Total size in bytes of the telemetry items flowing through the component, by signal.",
+			ConstLabels: prometheus.Labels{"signal": signal},
+		})
+		if err := reg.Register(m.bytes); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Observe records items flowing through the component, totalling bytes in
+// size. bytes is ignored if the size of the items isn't counted.
+func (m *Metrics) Observe(items, bytes int) {
+	m.items.Add(float64(items))
+	if m.bytes != nil {
+		m.bytes.Add(float64(bytes))
+	}
+}
@@ -0,0 +1,36 @@
+package throughput
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	// The metrics of several signals can be registered by the same component.
+	logs, err := New(reg, SignalLogs, true)
+	require.NoError(t, err)
+	metrics, err := New(reg, SignalMetrics, false)
+	require.NoError(t, err)
+
+	logs.Observe(2, 100)
+	metrics.Observe(3, 100)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP agent_component_throughput_bytes_total Total size in bytes of the telemetry items flowing through the component, by signal.
+# TYPE agent_component_throughput_bytes_total counter
+agent_component_throughput_bytes_total{signal="logs"} 100
+# HELP agent_component_throughput_items_total Total number of telemetry items flowing through the component, by signal.
+# TYPE agent_component_throughput_items_total counter
+agent_component_throughput_items_total{signal="logs"} 2
+agent_component_throughput_items_total{signal="metrics"} 3
+`)))
+
+	_, err = New(reg, SignalLogs, true)
+	require.Error(t, err)
+}
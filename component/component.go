@@ -113,3 +113,34 @@ type DebugComponent interface {
 	// DebugInfo must be safe for calling concurrently.
 	DebugInfo() interface{}
 }
+
+// PausableComponent is an extension interface for components which can be
+// temporarily paused, for example during a maintenance window, without being
+// reloaded.
+type PausableComponent interface {
+	Component
+
+	// Pause stops the work of the component until Resume is called. The
+	// component keeps running and accepting updates while paused.
+	//
+	// Pause and Resume must be safe for calling concurrently, and calling
+	// either of them more than once in a row must have no further effect.
+	Pause()
+
+	// Resume restarts the work of a paused component.
+	Resume()
+}
+
+// DrainableComponent is an extension interface for components which buffer
+// data before sending it, and can send it right away before shutting down.
+type DrainableComponent interface {
+	Component
+
+	// Drain sends the data buffered by the component, blocking until it's sent
+	// or ctx is canceled. Drain is called on shutdown while the component is
+	// still running, before the context given to Run is canceled.
+	//
+	// Drain must be safe for calling concurrently with the other methods of
+	// the component.
+	Drain(ctx context.Context) error
+}
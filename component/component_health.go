@@ -59,6 +59,10 @@ const (
 
 	// HealthTypeExited represents a component which has stopped running.
 	HealthTypeExited
+
+	// HealthTypePaused represents a component which has been paused and
+	// doesn't do its work until it is resumed.
+	HealthTypePaused
 )
 
 // String returns the string representation of ht.
@@ -70,6 +74,8 @@ func (ht HealthType) String() string {
 		return "unhealthy"
 	case HealthTypeExited:
 		return "exited"
+	case HealthTypePaused:
+		return "paused"
 	default:
 		return "unknown"
 	}
@@ -91,6 +97,8 @@ func (ht *HealthType) UnmarshalText(text []byte) error {
 		*ht = HealthTypeUnknown
 	case "exited":
 		*ht = HealthTypeExited
+	case "paused":
+		*ht = HealthTypePaused
 	default:
 		return fmt.Errorf("invalid health type %q", string(text))
 	}
@@ -101,7 +109,9 @@ func (ht *HealthType) UnmarshalText(text []byte) error {
 // considered to be the least healthy.
 //
 // Health types are first prioritized by [HealthTypeExited], followed by
-// [HealthTypeUnhealthy], [HealthTypeUnknown], and [HealthTypeHealthy].
+// [HealthTypeUnhealthy], [HealthTypePaused], [HealthTypeUnknown], and
+// [HealthTypeHealthy]. Paused components failing to work are reported as
+// unhealthy, so that pausing a component doesn't hide its failures.
 //
 // If multiple arguments have the same Health type, the Health with the most
 // recent timestamp is returned.
@@ -136,6 +146,7 @@ func LeastHealthy(h Health, hh ...Health) Health {
 var healthPriority = [...]int{
 	HealthTypeHealthy:   0,
 	HealthTypeUnknown:   1,
-	HealthTypeUnhealthy: 2,
-	HealthTypeExited:    3,
+	HealthTypePaused:    2,
+	HealthTypeUnhealthy: 3,
+	HealthTypeExited:    4,
 }
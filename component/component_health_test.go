@@ -40,6 +40,28 @@ func TestMergeHealth(t *testing.T) {
 			}},
 			expectIndex: 1,
 		},
+		{
+			name: "exited > paused",
+			healths: []component.Health{{
+				Health:     component.HealthTypeExited,
+				UpdateTime: jan1,
+			}, {
+				Health:     component.HealthTypePaused,
+				UpdateTime: jan2,
+			}},
+			expectIndex: 0,
+		},
+		{
+			name: "unhealthy > paused",
+			healths: []component.Health{{
+				Health:     component.HealthTypePaused,
+				UpdateTime: jan2,
+			}, {
+				Health:     component.HealthTypeUnhealthy,
+				UpdateTime: jan1,
+			}},
+			expectIndex: 1,
+		},
 		{
 			name: "unhealthy > healthy",
 			healths: []component.Health{{
@@ -17,6 +17,11 @@ var (
 	// ErrModuleNotFound is returned by [Provider.ListComponents] when the
 	// specified module isn't found.
 	ErrModuleNotFound = errors.New("module not found")
+
+	// ErrComponentNotPausable is returned by [PauseProvider.PauseComponent]
+	// and [PauseProvider.ResumeComponent] when the specified component doesn't
+	// implement [PausableComponent].
+	ErrComponentNotPausable = errors.New("component can't be paused")
 )
 
 // A Provider is a system which exposes a list of running components.
@@ -36,6 +41,23 @@ type Provider interface {
 	ListComponents(moduleID string, opts InfoOptions) ([]*Info, error)
 }
 
+// A PauseProvider is a Provider which can pause and resume its components.
+type PauseProvider interface {
+	Provider
+
+	// PauseComponent pauses the component with the given global ID until
+	// ResumeComponent is called. Paused components report the
+	// [HealthTypePaused] health.
+	//
+	// PauseComponent returns ErrComponentNotFound if the component is not
+	// found, and ErrComponentNotPausable if it can't be paused.
+	PauseComponent(id ID) error
+
+	// ResumeComponent resumes the paused component with the given global ID.
+	// It returns the same errors as PauseComponent.
+	ResumeComponent(id ID) error
+}
+
 // ID is a globally unique identifier for a component.
 type ID struct {
 	ModuleID string // Unique ID of the module that the component is running in.
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river/rivertypes"
 	promcfg "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
@@ -16,9 +17,10 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "discovery.ec2",
-		Args:    EC2Arguments{},
-		Exports: discovery.Exports{},
+		Name:      "discovery.ec2",
+		Stability: featuregate.StabilityStable,
+		Args:      EC2Arguments{},
+		Exports:   discovery.Exports{},
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return NewEC2(opts, args.(EC2Arguments))
 		},
@@ -168,6 +168,11 @@ var MaxUpdateFrequency = 5 * time.Second
 
 // runDiscovery is a utility for consuming and forwarding target groups from a discoverer.
 // It will handle collating targets (and clearing), as well as time based throttling of updates.
+//
+// Discoverers don't send target groups when they fail to refresh them, so the
+// last targets sent are kept until the discoverer succeeds again. This also
+// holds for new discoverers: the targets of the previous discoverer are kept
+// until the new one sends its first target groups.
 func (c *Component) runDiscovery(ctx context.Context, d Discoverer) {
 	// all targets we have seen so far
 	cache := map[string]*targetgroup.Group{}
@@ -206,7 +211,12 @@ func (c *Component) runDiscovery(ctx context.Context, d Discoverer) {
 				haveUpdates = false
 			}
 		case <-ctx.Done():
-			send()
+			// Only send the pending updates: a discoverer which is replaced
+			// before sending any target groups, for example because it failed
+			// to refresh them, must not clear the targets of the previous one.
+			if haveUpdates {
+				send()
+			}
 			return
 		case groups := <-ch:
 			for _, group := range groups {
@@ -9,15 +9,17 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/common/config"
 	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/discovery/moby"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "discovery.docker",
-		Args:    Arguments{},
-		Exports: discovery.Exports{},
+		Name:      "discovery.docker",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   discovery.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
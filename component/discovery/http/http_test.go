@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -114,3 +115,87 @@ func TestComponent(t *testing.T) {
 	assert.Equal(t, true, endpointCalled)
 	assert.Equal(t, true, stateChanged.Load())
 }
+
+// TestComponentFailingRefresh ensures that the component keeps exporting the
+// last targets it found while it fails to refresh them, including when it's
+// updated in the meantime, so that the scrapers using them keep scraping
+// them.
+func TestComponentFailingRefresh(t *testing.T) {
+	discovery.MaxUpdateFrequency = 50 * time.Millisecond
+
+	var (
+		failing atomic.Bool
+		address = atomic.NewString("10.0.10.2:9100")
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `[{"targets": [%q]}]`, address.Load())
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	args := func(interval time.Duration) Arguments {
+		return Arguments{
+			RefreshInterval:  interval,
+			HTTPClientConfig: config.DefaultHTTPClientConfig,
+			URL:              config.URL{URL: u},
+		}
+	}
+	exports := make(chan discovery.Exports, 100)
+	c, err := New(component.Options{
+		OnStateChange: func(e component.Exports) {
+			exports <- e.(discovery.Exports)
+		},
+	}, args(100*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, c.Run(ctx))
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	addresses := func(e discovery.Exports) []string {
+		var res []string
+		for _, target := range e.Targets {
+			res = append(res, target[model.AddressLabel])
+		}
+		return res
+	}
+	requireTargets := func(expect ...string) {
+		select {
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "timed out waiting for targets")
+		case e := <-exports:
+			require.Equal(t, expect, addresses(e))
+		}
+	}
+	requireTargets("10.0.10.2:9100")
+
+	// The discoverers created by the updates fail to refresh the targets
+	// before they're replaced.
+	failing.Store(true)
+	require.NoError(t, c.Update(args(150*time.Millisecond)))
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, c.Update(args(200*time.Millisecond)))
+	time.Sleep(500 * time.Millisecond)
+	for len(exports) > 0 {
+		requireTargets("10.0.10.2:9100")
+	}
+
+	// The targets are updated once the refreshes succeed again.
+	address.Store("10.0.10.3:9100")
+	failing.Store(false)
+	requireTargets("10.0.10.3:9100")
+}
@@ -5,14 +5,16 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/common/config"
 	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/internal/featuregate"
 	promk8s "github.com/prometheus/prometheus/discovery/kubernetes"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "discovery.kubernetes",
-		Args:    Arguments{},
-		Exports: discovery.Exports{},
+		Name:      "discovery.kubernetes",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   discovery.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -74,6 +74,55 @@ rule {
 	require.NotNil(t, tc.Exports().(relabel.Exports).Rules)
 }
 
+func TestEqualActions(t *testing.T) {
+	targets := `
+targets = [
+	{ "__address__" = "localhost:9090", "__meta_port" = "9090", "instance" = "one" },
+	{ "__address__" = "localhost:9091", "__meta_port" = "9090", "instance" = "two" },
+	{ "__address__" = "localhost:9092", "__meta_port" = "9092", "instance" = "three" },
+]
+
+rule {
+	source_labels = ["__address__"]
+	regex         = ".*:(.*)"
+	target_label  = "__tmp_port"
+}
+`
+	tests := []struct {
+		action    string
+		instances []string
+	}{
+		{action: "keepequal", instances: []string{"one", "three"}},
+		{action: "dropequal", instances: []string{"two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			var args relabel.Arguments
+			require.NoError(t, river.Unmarshal([]byte(targets+`
+rule {
+	source_labels = ["__tmp_port"]
+	target_label  = "__meta_port"
+	action        = "`+tt.action+`"
+}
+`), &args))
+
+			tc, err := componenttest.NewControllerFromID(nil, "discovery.relabel")
+			require.NoError(t, err)
+			go func() {
+				err = tc.Run(componenttest.TestContext(t), args)
+				require.NoError(t, err)
+			}()
+			require.NoError(t, tc.WaitExports(time.Second))
+
+			var instances []string
+			for _, target := range tc.Exports().(relabel.Exports).Output {
+				instances = append(instances, target["instance"])
+			}
+			require.Equal(t, tt.instances, instances)
+		})
+	}
+}
+
 func TestRuleGetter(t *testing.T) {
 	originalCfg := `
 targets = []
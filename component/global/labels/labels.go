@@ -0,0 +1,254 @@
+// Package labels provides a global.labels component.
+package labels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "global.labels",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the global.labels
+// component.
+type Arguments struct {
+	Labels map[string]string `river:"labels,attr"`
+
+	Output OutputArguments `river:"output,block"`
+}
+
+// OutputArguments configures where to send the telemetry once the labels are
+// set.
+type OutputArguments struct {
+	Metrics []storage.Appendable `river:"metrics,attr,optional"`
+	Logs    []loki.LogsReceiver  `river:"logs,attr,optional"`
+	Traces  []otelcol.Consumer   `river:"traces,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if len(args.Labels) == 0 {
+		return errors.New("at least one label must be set")
+	}
+	for name, value := range args.Labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if value == "" {
+			return fmt.Errorf("value of label %q must not be empty", name)
+		}
+	}
+	return nil
+}
+
+// Exports holds the receivers of each signal.
+type Exports struct {
+	MetricsReceiver storage.Appendable `river:"metrics_receiver,attr"`
+	LogsReceiver    loki.LogsReceiver  `river:"logs_receiver,attr"`
+	TracesInput     otelcol.Consumer   `river:"traces_input,attr"`
+}
+
+// Component implements the global.labels component.
+type Component struct {
+	opts component.Options
+
+	metricsReceiver *prometheus.Interceptor
+	metricsFanout   *prometheus.Fanout
+	logsReceiver    loki.LogsReceiver
+
+	mut          sync.RWMutex
+	promLabels   labels.Labels
+	lokiLabels   model.LabelSet
+	logsFanout   []loki.LogsReceiver
+	tracesFanout []otelcol.Consumer
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ otelcol.Consumer    = (*tracesInput)(nil)
+)
+
+// New creates a new global.labels component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:         o,
+		logsReceiver: loki.NewLogsReceiver(),
+	}
+	c.metricsFanout = prometheus.NewFanout(args.Output.Metrics, o.ID, o.Registerer, ls)
+	c.metricsReceiver = prometheus.NewInterceptor(
+		c.metricsFanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			return next.Append(0, c.setPromLabels(l), t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			return next.AppendExemplar(0, c.setPromLabels(l), e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			return next.UpdateMetadata(0, c.setPromLabels(l), m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			return next.AppendHistogram(0, c.setPromLabels(l), t, h, fh)
+		}),
+	)
+
+	// The receivers remain the same for the component's lifetime, so they are
+	// exported once.
+	o.OnStateChange(Exports{
+		MetricsReceiver: c.metricsReceiver,
+		LogsReceiver:    c.logsReceiver,
+		TracesInput:     &tracesInput{c: c},
+	})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.logsReceiver.Chan():
+			c.mut.RLock()
+			entry.Labels = entry.Labels.Merge(c.lokiLabels)
+			fanout := c.logsFanout
+			c.mut.RUnlock()
+
+			for _, f := range fanout {
+				select {
+				case <-ctx.Done():
+					return nil
+				case f.Chan() <- entry:
+				}
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	var (
+		promLabels = labels.NewBuilder(labels.EmptyLabels())
+		lokiLabels = make(model.LabelSet, len(newArgs.Labels))
+	)
+	for name, value := range newArgs.Labels {
+		promLabels.Set(name, value)
+		lokiLabels[model.LabelName(name)] = model.LabelValue(value)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.promLabels = promLabels.Labels()
+	c.lokiLabels = lokiLabels
+	c.logsFanout = newArgs.Output.Logs
+	c.tracesFanout = newArgs.Output.Traces
+	c.metricsFanout.UpdateChildren(newArgs.Output.Metrics)
+	return nil
+}
+
+// setPromLabels returns l with the labels of the component set, overriding
+// labels of the same name.
+func (c *Component) setPromLabels(l labels.Labels) labels.Labels {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	b := labels.NewBuilder(l)
+	c.promLabels.Range(func(l labels.Label) {
+		b.Set(l.Name, l.Value)
+	})
+	return b.Labels()
+}
+
+// tracesInput sets the labels of the component as resource attributes of the
+// spans sent to it. Only traces are supported.
+type tracesInput struct {
+	c *Component
+}
+
+// Capabilities implements otelcol.Consumer. The attributes of the received
+// traces are updated in place.
+func (in *tracesInput) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{MutatesData: true}
+}
+
+// ConsumeTraces implements otelcol.Consumer.
+func (in *tracesInput) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	in.c.mut.RLock()
+	lokiLabels, fanout := in.c.lokiLabels, in.c.tracesFanout
+	in.c.mut.RUnlock()
+
+	resourceSpans := td.ResourceSpans()
+	for i := 0; i < resourceSpans.Len(); i++ {
+		attrs := resourceSpans.At(i).Resource().Attributes()
+		for name, value := range lokiLabels {
+			attrs.PutStr(string(name), string(value))
+		}
+	}
+
+	var errs []error
+	for i, consumer := range fanout {
+		// Every consumer but the last one gets its own copy, as consumers may
+		// mutate the traces.
+		data := td
+		if i < len(fanout)-1 {
+			data = ptrace.NewTraces()
+			td.CopyTo(data)
+		}
+		errs = append(errs, consumer.ConsumeTraces(ctx, data))
+	}
+	return errors.Join(errs...)
+}
+
+// ConsumeMetrics implements otelcol.Consumer.
+func (in *tracesInput) ConsumeMetrics(context.Context, pmetric.Metrics) error {
+	return otelcomponent.ErrDataTypeIsNotSupported
+}
+
+// ConsumeLogs implements otelcol.Consumer.
+func (in *tracesInput) ConsumeLogs(context.Context, plog.Logs) error {
+	return otelcomponent.ErrDataTypeIsNotSupported
+}
@@ -0,0 +1,177 @@
+package labels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/river"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestRiverConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			cfg: `
+				labels = { cluster = "eu-west", environment = "prod" }
+				output {}
+			`,
+		},
+		{
+			name:        "no labels",
+			cfg:         `labels = {}` + "\n" + `output {}`,
+			expectedErr: "at least one label must be set",
+		},
+		{
+			name:        "invalid label name",
+			cfg:         `labels = { "my-cluster" = "eu-west" }` + "\n" + `output {}`,
+			expectedErr: `invalid label name "my-cluster"`,
+		},
+		{
+			name:        "reserved label name",
+			cfg:         `labels = { "__name__" = "up" }` + "\n" + `output {}`,
+			expectedErr: `invalid label name "__name__"`,
+		},
+		{
+			name:        "empty value",
+			cfg:         `labels = { cluster = "" }` + "\n" + `output {}`,
+			expectedErr: `value of label "cluster" must not be empty`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "global.labels")
+	require.NoError(t, err)
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		labels = { cluster = "eu-west", environment = "prod" }
+		output {}
+	`), &args))
+
+	// Prometheus samples.
+	samplesCh := make(chan labels.Labels, 1)
+	args.Output.Metrics = []storage.Appendable{prometheus.NewInterceptor(nil, labelstore.New(nil),
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+			samplesCh <- l
+			return ref, nil
+		}),
+	)}
+
+	// Loki entries.
+	logsSink := loki.NewLogsReceiver()
+	args.Output.Logs = []loki.LogsReceiver{logsSink}
+
+	// OpenTelemetry spans.
+	tracesCh := make(chan ptrace.Traces, 1)
+	args.Output.Traces = []otelcol.Consumer{tracesConsumer(func(_ context.Context, td ptrace.Traces) error {
+		tracesCh <- td
+		return nil
+	})}
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitExports(time.Second))
+	exports := ctrl.Exports().(Exports)
+
+	t.Run("metrics", func(t *testing.T) {
+		app := exports.MetricsReceiver.Appender(ctx)
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "cluster", "us-east", "job", "node"), time.Now().UnixMilli(), 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+
+		select {
+		case l := <-samplesCh:
+			require.Equal(t, labels.FromStrings("__name__", "up", "cluster", "eu-west", "environment", "prod", "job", "node"), l)
+		case <-time.After(time.Second):
+			require.FailNow(t, "no sample received")
+		}
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		exports.LogsReceiver.Chan() <- loki.Entry{
+			Labels: model.LabelSet{"job": "varlogs"},
+			Entry:  logproto.Entry{Timestamp: time.Now(), Line: "hello"},
+		}
+
+		select {
+		case entry := <-logsSink.Chan():
+			require.Equal(t, model.LabelSet{"cluster": "eu-west", "environment": "prod", "job": "varlogs"}, entry.Labels)
+			require.Equal(t, "hello", entry.Line)
+		case <-time.After(time.Second):
+			require.FailNow(t, "no log entry received")
+		}
+	})
+
+	t.Run("traces", func(t *testing.T) {
+		td := ptrace.NewTraces()
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", "checkout")
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("GET /cart")
+		require.NoError(t, exports.TracesInput.ConsumeTraces(ctx, td))
+
+		select {
+		case td := <-tracesCh:
+			require.Equal(t, 1, td.SpanCount())
+			require.Equal(t, map[string]any{
+				"cluster":      "eu-west",
+				"environment":  "prod",
+				"service.name": "checkout",
+			}, td.ResourceSpans().At(0).Resource().Attributes().AsRaw())
+		case <-time.After(time.Second):
+			require.FailNow(t, "no traces received")
+		}
+
+		require.Error(t, exports.TracesInput.ConsumeMetrics(ctx, pmetric.NewMetrics()))
+		require.Error(t, exports.TracesInput.ConsumeLogs(ctx, plog.NewLogs()))
+	})
+}
+
+type tracesConsumer func(context.Context, ptrace.Traces) error
+
+func (f tracesConsumer) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{}
+}
+
+func (f tracesConsumer) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	return f(ctx, td)
+}
+
+func (f tracesConsumer) ConsumeMetrics(context.Context, pmetric.Metrics) error { return nil }
+
+func (f tracesConsumer) ConsumeLogs(context.Context, plog.Logs) error { return nil }
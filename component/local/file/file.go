@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/river/rivertypes"
 )
@@ -24,9 +25,10 @@ const waitReadPeriod time.Duration = 30 * time.Millisecond
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "local.file",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "local.file",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -0,0 +1,132 @@
+// Package anonymize provides the loki.anonymize component.
+package anonymize
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/anonymize"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.anonymize",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.anonymize
+// component.
+type Arguments struct {
+	// Where the anonymized log entries should be forwarded to.
+	ForwardTo []loki.LogsReceiver `river:"forward_to,attr"`
+
+	Anonymize anonymize.Arguments `river:",squash"`
+}
+
+// Exports holds values which are exported by the loki.anonymize component.
+type Exports struct {
+	Receiver loki.LogsReceiver `river:"receiver,attr"`
+}
+
+// Component implements the loki.anonymize component.
+type Component struct {
+	opts     component.Options
+	receiver loki.LogsReceiver
+
+	mut        sync.RWMutex
+	anonymizer *anonymize.Anonymizer
+	fanout     []loki.LogsReceiver
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new loki.anonymize component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{opts: o}
+
+	// Create and immediately export the receiver which remains the same for
+	// the component's lifetime.
+	c.receiver = loki.NewLogsReceiver()
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.mut.RLock()
+			anonymizer, fanout := c.anonymizer, c.fanout
+			c.mut.RUnlock()
+
+			entry.Labels = anonymizeLabels(anonymizer, entry.Labels)
+			for _, f := range fanout {
+				select {
+				case <-ctx.Done():
+					return nil
+				case f.Chan() <- entry:
+				}
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	anonymizer, err := anonymize.New(newArgs.Anonymize)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.anonymizer = anonymizer
+	c.fanout = newArgs.ForwardTo
+
+	return nil
+}
+
+// anonymizeLabels returns lbls with the values of the sensitive labels hashed
+// or dropped. lbls isn't modified since entries may be shared with other
+// receivers.
+func anonymizeLabels(a *anonymize.Anonymizer, lbls model.LabelSet) model.LabelSet {
+	var changed bool
+	for name := range lbls {
+		if a.Applies(string(name)) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return lbls
+	}
+
+	res := make(model.LabelSet, len(lbls))
+	for name, value := range lbls {
+		if v, ok := a.Value(string(name), string(value)); ok {
+			res[name] = model.LabelValue(v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,58 @@
+package anonymize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	commonanonymize "github.com/grafana/agent/component/common/anonymize"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymize(t *testing.T) {
+	out := loki.NewLogsReceiver()
+	c, err := New(component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+	}, Arguments{
+		ForwardTo: []loki.LogsReceiver{out},
+		Anonymize: commonanonymize.Arguments{
+			Salt:  "s3cr3t",
+			Rules: []commonanonymize.Rule{{Labels: []string{"user_id"}, Regex: ".*", Action: "hash"}},
+		},
+	})
+	require.NoError(t, err)
+	go func() { _ = c.Run(componenttest.TestContext(t)) }()
+
+	receive := func() loki.Entry {
+		entry := loki.Entry{
+			Labels: model.LabelSet{"job": "api", "user_id": "42"},
+			Entry:  logproto.Entry{Timestamp: time.Now(), Line: "user logged in"},
+		}
+		c.receiver.Chan() <- entry
+
+		select {
+		case got := <-out.Chan():
+			// The sent entry isn't modified.
+			require.Equal(t, model.LabelValue("42"), entry.Labels["user_id"])
+			return got
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+			return loki.Entry{}
+		}
+	}
+
+	first, second := receive(), receive()
+	require.Equal(t, "user logged in", first.Line)
+	require.Equal(t, model.LabelValue("api"), first.Labels["job"])
+	require.Len(t, first.Labels["user_id"], 32)
+	require.NotEqual(t, model.LabelValue("42"), first.Labels["user_id"])
+	require.Equal(t, first.Labels, second.Labels)
+}
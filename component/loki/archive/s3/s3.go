@@ -0,0 +1,565 @@
+// Package s3 provides the loki.archive.s3 component.
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	remote_s3 "github.com/grafana/agent/component/remote/s3"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.archive.s3",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Supported object formats.
+const (
+	FormatJSON = "json"
+	FormatRaw  = "raw"
+)
+
+// Supported object compressions.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// Arguments holds values which are used to configure the loki.archive.s3
+// component.
+type Arguments struct {
+	Bucket        string           `river:"bucket,attr"`
+	Prefix        string           `river:"prefix,attr,optional"`
+	PartitionBy   []string         `river:"partition_by,attr,optional"`
+	TimePartition string           `river:"time_partition,attr,optional"`
+	Format        string           `river:"format,attr,optional"`
+	Compression   string           `river:"compression,attr,optional"`
+	BatchSize     units.Base2Bytes `river:"batch_size,attr,optional"`
+	BatchWait     time.Duration    `river:"batch_wait,attr,optional"`
+
+	MaxPendingBatches int           `river:"max_pending_batches,attr,optional"`
+	MinBackoff        time.Duration `river:"min_backoff_period,attr,optional"`
+	MaxBackoff        time.Duration `river:"max_backoff_period,attr,optional"`
+	MaxBackoffRetries int           `river:"max_backoff_retries,attr,optional"`
+
+	Client remote_s3.Client `river:"client,block,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments. The backoff settings
+// are the same as the ones of loki.write.
+var DefaultArguments = Arguments{
+	TimePartition: "2006/01/02/15",
+	Format:        FormatJSON,
+	Compression:   CompressionGzip,
+	BatchSize:     5 * units.MiB,
+	BatchWait:     5 * time.Minute,
+
+	MaxPendingBatches: 10,
+	MinBackoff:        500 * time.Millisecond,
+	MaxBackoff:        5 * time.Minute,
+	MaxBackoffRetries: 10,
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.Bucket == "" {
+		return fmt.Errorf("bucket must not be empty")
+	}
+	for _, name := range a.PartitionBy {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("partition_by label %q is not a valid label name", name)
+		}
+	}
+	switch a.Format {
+	case FormatJSON, FormatRaw:
+	default:
+		return fmt.Errorf("format must be %q or %q, got %q", FormatJSON, FormatRaw, a.Format)
+	}
+	switch a.Compression {
+	case CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("compression must be %q or %q, got %q", CompressionNone, CompressionGzip, a.Compression)
+	}
+	if a.BatchSize <= 0 {
+		return fmt.Errorf("batch_size must be greater than 0")
+	}
+	if a.BatchWait <= 0 {
+		return fmt.Errorf("batch_wait must be greater than 0")
+	}
+	if a.MaxPendingBatches <= 0 {
+		return fmt.Errorf("max_pending_batches must be greater than 0")
+	}
+	if a.MaxBackoffRetries < 0 {
+		return fmt.Errorf("max_backoff_retries must not be negative")
+	}
+	return nil
+}
+
+// Exports holds the receiver that is used to send log entries to the
+// loki.archive.s3 component.
+type Exports struct {
+	Receiver loki.LogsReceiver `river:"receiver,attr"`
+}
+
+const (
+	// flushCheckInterval is how often batches are checked for being older
+	// than batch_wait.
+	flushCheckInterval = time.Second
+	// shutdownFlushTimeout bounds the time spent uploading the remaining
+	// batches when the component stops.
+	shutdownFlushTimeout = 30 * time.Second
+)
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// Component implements the loki.archive.s3 component.
+type Component struct {
+	opts     component.Options
+	metrics  *metrics
+	receiver loki.LogsReceiver
+
+	mut    sync.RWMutex
+	args   Arguments
+	client *s3.Client
+
+	// batches are only accessed by Run.
+	batches map[string]*batch
+
+	// pending holds the batches waiting to be uploaded.
+	pending *uploadQueue
+}
+
+// batch holds the encoded entries of a partition until they are uploaded.
+type batch struct {
+	partition string
+	created   time.Time // The time the batch was created at.
+	first     time.Time // The timestamp of the first entry.
+	entries   int
+	buf       bytes.Buffer
+}
+
+// New creates a new loki.archive.s3 component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m, err := newMetrics(o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:     o,
+		metrics:  m,
+		receiver: loki.NewLogsReceiver(),
+		batches:  make(map[string]*batch),
+		pending:  newUploadQueue(),
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	// The receiver remains the same for the component's lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	ticker := time.NewTicker(flushCheckInterval)
+	defer ticker.Stop()
+
+	// Batches are uploaded in the background, so that a slow bucket doesn't
+	// block the components sending entries to the receiver. The uploads
+	// outlive ctx to upload the remaining batches on shutdown.
+	uploadCtx, cancelUploads := context.WithCancel(context.Background())
+	defer cancelUploads()
+	uploadsDone := make(chan struct{})
+	go func() {
+		defer close(uploadsDone)
+		c.runUploads(uploadCtx)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Upload the partial batches so their entries aren't lost.
+			for key, b := range c.batches {
+				c.enqueue(b)
+				delete(c.batches, key)
+			}
+			c.pending.Close()
+
+			select {
+			case <-uploadsDone:
+			case <-time.After(shutdownFlushTimeout):
+				level.Warn(c.opts.Logger).Log("msg", "timed out uploading the remaining batches, dropping them")
+				cancelUploads()
+				<-uploadsDone
+			}
+			return nil
+
+		case entry := <-c.receiver.Chan():
+			c.append(entry)
+
+		case <-ticker.C:
+			c.mut.RLock()
+			batchWait := c.args.BatchWait
+			c.mut.RUnlock()
+
+			now := time.Now()
+			for key, b := range c.batches {
+				if now.Sub(b.created) >= batchWait {
+					c.enqueue(b)
+					delete(c.batches, key)
+				}
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	client, err := remote_s3.NewClient(newArgs.Client)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+	c.client = client
+	return nil
+}
+
+// append adds entry to the batch of its partition, and queues the batch for
+// upload once it's full.
+func (c *Component) append(entry loki.Entry) {
+	c.mut.RLock()
+	args := c.args
+	c.mut.RUnlock()
+
+	line, err := encodeEntry(args.Format, entry)
+	if err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to encode log entry, dropping it", "err", err)
+		c.metrics.droppedEntries.Inc()
+		return
+	}
+
+	partition := partitionPath(args, entry)
+	b, ok := c.batches[partition]
+	if !ok {
+		b = &batch{partition: partition, created: time.Now(), first: entry.Timestamp}
+		c.batches[partition] = b
+	}
+	b.buf.Write(line)
+	b.entries++
+
+	if b.buf.Len() >= int(args.BatchSize) {
+		c.enqueue(b)
+		delete(c.batches, partition)
+	}
+}
+
+// enqueue queues b for upload. b is dropped if max_pending_batches batches are
+// already waiting to be uploaded.
+func (c *Component) enqueue(b *batch) {
+	c.mut.RLock()
+	maxPending := c.args.MaxPendingBatches
+	c.mut.RUnlock()
+
+	if !c.pending.Push(b, maxPending) {
+		level.Warn(c.opts.Logger).Log("msg", "too many batches waiting to be uploaded, dropping batch", "partition", b.partition, "entries", b.entries)
+		c.drop(b)
+	}
+}
+
+// runUploads uploads the queued batches until the queue is closed and empty.
+// The batches which are still queued once ctx is canceled are dropped.
+func (c *Component) runUploads(ctx context.Context) {
+	for {
+		b, ok := c.pending.Pop(ctx)
+		if !ok {
+			return
+		}
+		if ctx.Err() != nil {
+			c.drop(b)
+			continue
+		}
+		c.upload(ctx, b)
+	}
+}
+
+// upload writes b to an object of the bucket, retrying with backoff. The
+// entries of b are dropped when all the attempts fail.
+func (c *Component) upload(ctx context.Context, b *batch) {
+	c.mut.RLock()
+	args, client := c.args, c.client
+	c.mut.RUnlock()
+
+	body := b.buf.Bytes()
+	if args.Compression == CompressionGzip {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		_, _ = gw.Write(body)
+		_ = gw.Close()
+		body = buf.Bytes()
+	}
+
+	key := objectKey(args, b)
+	bo := backoff.New(ctx, backoff.Config{
+		MinBackoff: args.MinBackoff,
+		MaxBackoff: args.MaxBackoff,
+		MaxRetries: args.MaxBackoffRetries,
+	})
+	for {
+		// Failed uploads are retried by the component rather than the client,
+		// so that the retries follow the backoff settings.
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(args.Bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(body),
+		}, func(o *s3.Options) { o.Retryer = aws.NopRetryer{} })
+		if err == nil {
+			break
+		}
+		c.metrics.failedUploads.Inc()
+
+		bo.Wait()
+		if !bo.Ongoing() {
+			level.Error(c.opts.Logger).Log("msg", "failed to upload log entries, dropping them", "bucket", args.Bucket, "key", key, "entries", b.entries, "err", err)
+			c.drop(b)
+			return
+		}
+		level.Warn(c.opts.Logger).Log("msg", "failed to upload log entries, will retry", "bucket", args.Bucket, "key", key, "entries", b.entries, "err", err)
+	}
+
+	level.Debug(c.opts.Logger).Log("msg", "uploaded log entries", "bucket", args.Bucket, "key", key, "entries", b.entries)
+	c.metrics.uploadedObjects.Inc()
+	c.metrics.uploadedBytes.Add(float64(len(body)))
+	c.metrics.uploadedEntries.Add(float64(b.entries))
+}
+
+// drop records that the entries of b are lost.
+func (c *Component) drop(b *batch) {
+	c.metrics.droppedBatches.Inc()
+	c.metrics.droppedEntries.Add(float64(b.entries))
+}
+
+// uploadQueue is a bounded FIFO queue of batches waiting to be uploaded.
+type uploadQueue struct {
+	mut     sync.Mutex
+	batches []*batch
+	closed  bool
+	notify  chan struct{}
+}
+
+func newUploadQueue() *uploadQueue {
+	return &uploadQueue{notify: make(chan struct{}, 1)}
+}
+
+// Push adds b to the queue, unless it already holds max batches.
+func (q *uploadQueue) Push(b *batch, max int) bool {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if len(q.batches) >= max {
+		return false
+	}
+	q.batches = append(q.batches, b)
+	q.wake()
+	return true
+}
+
+// Len returns the number of batches in the queue.
+func (q *uploadQueue) Len() int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return len(q.batches)
+}
+
+// Close makes Pop return false once the queue is empty.
+func (q *uploadQueue) Close() {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	q.closed = true
+	q.wake()
+}
+
+// Pop removes and returns the oldest batch of the queue, waiting for one to be
+// pushed if the queue is empty. It returns false once the queue is closed
+// and empty, or if ctx is canceled while waiting.
+func (q *uploadQueue) Pop(ctx context.Context) (*batch, bool) {
+	for {
+		q.mut.Lock()
+		if len(q.batches) > 0 {
+			b := q.batches[0]
+			q.batches[0] = nil
+			q.batches = q.batches[1:]
+			q.mut.Unlock()
+			return b, true
+		}
+		closed := q.closed
+		q.mut.Unlock()
+
+		if closed {
+			return nil, false
+		}
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// wake wakes up Pop. It must be called with q.mut held.
+func (q *uploadQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// partitionPath returns the path of the partition of entry, made of the
+// values of the partition_by labels and of the formatted timestamp of entry.
+func partitionPath(args Arguments, entry loki.Entry) string {
+	var elems []string
+	for _, name := range args.PartitionBy {
+		value := entry.Labels[model.LabelName(name)]
+		elems = append(elems, name+"="+url.PathEscape(string(value)))
+	}
+	if args.TimePartition != "" {
+		elems = append(elems, entry.Timestamp.UTC().Format(args.TimePartition))
+	}
+	return path.Join(elems...)
+}
+
+// objectKey returns a unique key for the object holding b.
+func objectKey(args Arguments, b *batch) string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+
+	name := fmt.Sprintf("%d-%s", b.first.UnixNano(), hex.EncodeToString(suffix[:]))
+	switch args.Format {
+	case FormatJSON:
+		name += ".jsonl"
+	default:
+		name += ".log"
+	}
+	if args.Compression == CompressionGzip {
+		name += ".gz"
+	}
+	return path.Join(args.Prefix, b.partition, name)
+}
+
+// jsonEntry is the representation of a log entry in JSON objects.
+type jsonEntry struct {
+	Timestamp          time.Time         `json:"timestamp"`
+	Labels             model.LabelSet    `json:"labels"`
+	StructuredMetadata map[string]string `json:"structured_metadata,omitempty"`
+	Line               string            `json:"line"`
+}
+
+// encodeEntry returns the encoding of entry in format, terminated by a
+// newline.
+func encodeEntry(format string, entry loki.Entry) ([]byte, error) {
+	if format == FormatRaw {
+		return []byte(entry.Line + "\n"), nil
+	}
+
+	je := jsonEntry{
+		Timestamp: entry.Timestamp.UTC(),
+		Labels:    entry.Labels,
+		Line:      entry.Line,
+	}
+	if len(entry.StructuredMetadata) > 0 {
+		je.StructuredMetadata = make(map[string]string, len(entry.StructuredMetadata))
+		for _, l := range entry.StructuredMetadata {
+			je.StructuredMetadata[l.Name] = l.Value
+		}
+	}
+	bb, err := json.Marshal(je)
+	if err != nil {
+		return nil, err
+	}
+	return append(bb, '\n'), nil
+}
+
+type metrics struct {
+	uploadedObjects prometheus.Counter
+	uploadedBytes   prometheus.Counter
+	uploadedEntries prometheus.Counter
+	failedUploads   prometheus.Counter
+	droppedBatches  prometheus.Counter
+	droppedEntries  prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		uploadedObjects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_archive_s3_uploaded_objects_total",
+			Help: "Number of objects uploaded to the bucket.",
+		}),
+		uploadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_archive_s3_uploaded_bytes_total",
+			Help: "Number of bytes uploaded to the bucket, after compression.",
+		}),
+		uploadedEntries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_archive_s3_uploaded_entries_total",
+			Help: "Number of log entries uploaded to the bucket.",
+		}),
+		failedUploads: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_archive_s3_failed_uploads_total",
+			Help: "Number of failed attempts to upload an object to the bucket.",
+		}),
+		droppedBatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_archive_s3_dropped_batches_total",
+			Help: "Number of batches dropped because too many batches were waiting to be uploaded, or because they failed to be uploaded.",
+		}),
+		droppedEntries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_archive_s3_dropped_entries_total",
+			Help: "Number of log entries dropped because they couldn't be encoded or uploaded.",
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.uploadedObjects, m.uploadedBytes, m.uploadedEntries, m.failedUploads, m.droppedBatches, m.droppedEntries} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
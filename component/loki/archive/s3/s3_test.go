@@ -0,0 +1,305 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	remote_s3 "github.com/grafana/agent/component/remote/s3"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`bucket = "logs"`), &args))
+	expected := DefaultArguments
+	expected.Bucket = "logs"
+	require.Equal(t, expected, args)
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{`bucket = ""`, "bucket must not be empty"},
+		{"bucket = \"logs\"\npartition_by = [\"not-valid\"]", `partition_by label "not-valid" is not a valid label name`},
+		{"bucket = \"logs\"\nformat = \"csv\"", `format must be "json" or "raw", got "csv"`},
+		{"bucket = \"logs\"\ncompression = \"zstd\"", `compression must be "none" or "gzip", got "zstd"`},
+		{"bucket = \"logs\"\nbatch_size = \"0B\"", "batch_size must be greater than 0"},
+		{"bucket = \"logs\"\nbatch_wait = \"0s\"", "batch_wait must be greater than 0"},
+		{"bucket = \"logs\"\nmax_pending_batches = 0", "max_pending_batches must be greater than 0"},
+		{"bucket = \"logs\"\nmax_backoff_retries = -1", "max_backoff_retries must not be negative"},
+	}
+	for _, tc := range tests {
+		var args Arguments
+		require.EqualError(t, river.Unmarshal([]byte(tc.config), &args), tc.err)
+	}
+}
+
+func TestFlushOnShutdown(t *testing.T) {
+	srv := newFakeS3(t)
+
+	args := testArguments(srv.URL)
+	args.Prefix = "archive"
+	args.PartitionBy = []string{"app"}
+	args.Compression = CompressionNone
+
+	c := newTestComponent(t, args)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	ts := time.Date(2023, 10, 14, 9, 30, 0, 0, time.UTC)
+	c.receiver.Chan() <- newEntry(ts, model.LabelSet{"app": "api"}, "GET /users")
+	c.receiver.Chan() <- newEntry(ts.Add(time.Second), model.LabelSet{"app": "web"}, "rendered page")
+	c.receiver.Chan() <- newEntry(ts.Add(2*time.Second), model.LabelSet{"app": "api"}, "POST /users")
+	c.receiver.Chan() <- newEntry(ts.Add(time.Hour), model.LabelSet{"app": "api"}, "GET /health")
+
+	// Batches are neither full nor old enough to be uploaded.
+	time.Sleep(100 * time.Millisecond)
+	require.Empty(t, srv.Objects())
+
+	cancel()
+	require.NoError(t, <-done)
+
+	require.Equal(t, map[string]string{
+		"archive/app=api/2023/10/14/09/1697275800000000000-*.jsonl": `{"timestamp":"2023-10-14T09:30:00Z","labels":{"app":"api"},"line":"GET /users"}
+{"timestamp":"2023-10-14T09:30:02Z","labels":{"app":"api"},"line":"POST /users"}
+`,
+		"archive/app=api/2023/10/14/10/1697279400000000000-*.jsonl": `{"timestamp":"2023-10-14T10:30:00Z","labels":{"app":"api"},"line":"GET /health"}
+`,
+		"archive/app=web/2023/10/14/09/1697275801000000000-*.jsonl": `{"timestamp":"2023-10-14T09:30:01Z","labels":{"app":"web"},"line":"rendered page"}
+`,
+	}, srv.Objects())
+
+	require.Equal(t, 3.0, testutil.ToFloat64(c.metrics.uploadedObjects))
+	require.Equal(t, 4.0, testutil.ToFloat64(c.metrics.uploadedEntries))
+	require.Equal(t, 0.0, testutil.ToFloat64(c.metrics.failedUploads))
+}
+
+func TestFlushFullBatches(t *testing.T) {
+	srv := newFakeS3(t)
+
+	args := testArguments(srv.URL)
+	args.Format = FormatRaw
+	args.TimePartition = ""
+	args.BatchSize = 20
+
+	c := newTestComponent(t, args)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	ts := time.Date(2023, 10, 14, 9, 30, 0, 0, time.UTC)
+	c.receiver.Chan() <- newEntry(ts, nil, "first line")
+	c.receiver.Chan() <- newEntry(ts.Add(time.Second), nil, "second line")
+	c.receiver.Chan() <- newEntry(ts.Add(2*time.Second), nil, "third line")
+
+	// The first two lines fill up a batch, which is uploaded right away.
+	require.Eventually(t, func() bool { return len(srv.Objects()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]string{
+		"1697275800000000000-*.log.gz": "first line\nsecond line\n",
+	}, srv.Objects())
+}
+
+func TestFlushOldBatches(t *testing.T) {
+	srv := newFakeS3(t)
+
+	args := testArguments(srv.URL)
+	args.Format = FormatRaw
+	args.TimePartition = ""
+	args.BatchWait = 100 * time.Millisecond
+
+	c := newTestComponent(t, args)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	c.receiver.Chan() <- newEntry(time.Unix(0, 1), nil, "lonely line")
+
+	require.Eventually(t, func() bool { return len(srv.Objects()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]string{"1-*.log.gz": "lonely line\n"}, srv.Objects())
+}
+
+func TestRetryFailedUploads(t *testing.T) {
+	srv := newFakeS3(t)
+	srv.failures = 2
+
+	args := testArguments(srv.URL)
+	args.Format = FormatRaw
+	args.TimePartition = ""
+	args.BatchSize = 1
+
+	c := newTestComponent(t, args)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	c.receiver.Chan() <- newEntry(time.Unix(0, 1), nil, "retried line")
+
+	require.Eventually(t, func() bool { return len(srv.Objects()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]string{"1-*.log.gz": "retried line\n"}, srv.Objects())
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.failedUploads))
+	require.Equal(t, 0.0, testutil.ToFloat64(c.metrics.droppedBatches))
+}
+
+// TestDropPendingBatches ensures that entries keep being received while the
+// bucket doesn't respond, and that the batches which don't fit in the queue
+// are dropped.
+func TestDropPendingBatches(t *testing.T) {
+	srv := newFakeS3(t)
+	unblock := make(chan struct{})
+	srv.block = unblock
+
+	args := testArguments(srv.URL)
+	args.Format = FormatRaw
+	args.TimePartition = ""
+	args.BatchSize = 1
+	args.MaxPendingBatches = 1
+
+	c := newTestComponent(t, args)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	// The first batch is being uploaded, the second one is queued, and the
+	// next ones are dropped.
+	for i := 1; i <= 4; i++ {
+		select {
+		case c.receiver.Chan() <- newEntry(time.Unix(0, int64(i)), nil, "line"):
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "entries aren't received while batches are uploaded")
+		}
+		if i == 1 {
+			require.Eventually(t, func() bool { return c.pending.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+		}
+	}
+	require.Eventually(t, func() bool { return testutil.ToFloat64(c.metrics.droppedBatches) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.droppedEntries))
+
+	close(unblock)
+	cancel()
+	require.NoError(t, <-done)
+	require.Len(t, srv.Objects(), 2)
+}
+
+func testArguments(endpoint string) Arguments {
+	args := DefaultArguments
+	args.Bucket = "logs"
+	args.Client = remote_s3.Client{
+		AccessKey:    "access-key",
+		Secret:       "secret",
+		Endpoint:     endpoint,
+		UsePathStyle: true,
+		Region:       "us-east-1",
+	}
+	args.MinBackoff = 10 * time.Millisecond
+	args.MaxBackoff = 10 * time.Millisecond
+	return args
+}
+
+func newTestComponent(t *testing.T, args Arguments) *Component {
+	t.Helper()
+
+	c, err := New(component.Options{
+		ID:            "loki.archive.s3.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+	return c
+}
+
+func newEntry(ts time.Time, labels model.LabelSet, line string) loki.Entry {
+	return loki.Entry{
+		Labels: labels,
+		Entry:  logproto.Entry{Timestamp: ts, Line: line},
+	}
+}
+
+// fakeS3 stores the objects put in the logs bucket.
+type fakeS3 struct {
+	*httptest.Server
+
+	// block, if set, holds the requests until it's closed.
+	block chan struct{}
+
+	mut      sync.Mutex
+	failures int // The number of requests to fail before storing objects.
+	objects  map[string][]byte
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+
+	f := &fakeS3{objects: make(map[string][]byte)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.URL.Path, "/logs/")
+		if r.Method != http.MethodPut || !ok {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if f.block != nil {
+			<-f.block
+		}
+
+		f.mut.Lock()
+		defer f.mut.Unlock()
+		if f.failures > 0 {
+			f.failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		f.objects[key] = body
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Objects returns the decompressed content of the objects, by key. The random
+// part of the keys is replaced with a *.
+func (f *fakeS3) Objects() map[string]string {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	objects := make(map[string]string, len(f.objects))
+	for key, body := range f.objects {
+		if strings.HasSuffix(key, ".gz") {
+			gr, err := gzip.NewReader(bytes.NewReader(body))
+			if err == nil {
+				body, _ = io.ReadAll(gr)
+			}
+		}
+
+		// Keys end with <timestamp>-<8 hex characters>.<extension>
+		dir, name := "", key
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			dir, name = key[:i+1], key[i+1:]
+		}
+		if i := strings.Index(name, "-"); i >= 0 && len(name) > i+9 {
+			name = name[:i+1] + "*" + name[i+9:]
+		}
+		objects[dir+name] = string(body)
+	}
+	return objects
+}
@@ -0,0 +1,320 @@
+// Package buffer provides the loki.buffer component.
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/internal/featuregate"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.buffer",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Policies applied when an entry is received while the buffer is full.
+const (
+	PolicyDropOldest = "drop_oldest"
+	PolicyDropNewest = "drop_newest"
+	PolicyBlock      = "block"
+)
+
+// Arguments holds values which are used to configure the loki.buffer
+// component.
+type Arguments struct {
+	// Where the buffered log entries should be forwarded to.
+	ForwardTo []loki.LogsReceiver `river:"forward_to,attr"`
+
+	// Maximum number and size of the buffered entries. 0 means no limit.
+	MaxEntries int              `river:"max_entries,attr,optional"`
+	MaxBytes   units.Base2Bytes `river:"max_bytes,attr,optional"`
+	// What to do with the entries received while the buffer is full.
+	OverflowPolicy string `river:"overflow_policy,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for the loki.buffer component.
+var DefaultArguments = Arguments{
+	MaxEntries:     10000,
+	OverflowPolicy: PolicyDropOldest,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.MaxEntries < 0 {
+		return fmt.Errorf("max_entries must not be negative")
+	}
+	if args.MaxBytes < 0 {
+		return fmt.Errorf("max_bytes must not be negative")
+	}
+	if args.MaxEntries == 0 && args.MaxBytes == 0 {
+		return fmt.Errorf("at least one of max_entries and max_bytes must be set")
+	}
+	switch args.OverflowPolicy {
+	case PolicyDropOldest, PolicyDropNewest, PolicyBlock:
+		return nil
+	default:
+		return fmt.Errorf("overflow_policy must be %q, %q or %q, got %q", PolicyDropOldest, PolicyDropNewest, PolicyBlock, args.OverflowPolicy)
+	}
+}
+
+// Exports holds the values exported by the loki.buffer component.
+type Exports struct {
+	Receiver loki.LogsReceiver `river:"receiver,attr"`
+}
+
+// Component implements the loki.buffer component.
+type Component struct {
+	opts     component.Options
+	receiver loki.LogsReceiver
+	metrics  *metrics
+
+	// pushed is signaled when an entry is buffered, and popped when one is
+	// removed from the buffer or the limits change.
+	pushed chan struct{}
+	popped chan struct{}
+
+	mut      sync.Mutex
+	args     Arguments
+	entries  []loki.Entry
+	bytes    int
+	inflight bool
+}
+
+type metrics struct {
+	dropped prometheus_client.Counter
+	entries prometheus_client.Gauge
+	bytes   prometheus_client.Gauge
+}
+
+var (
+	_ component.Component          = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
+)
+
+// New creates a new loki.buffer component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m := &metrics{
+		dropped: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "loki_buffer_dropped_entries_total",
+			Help: "Number of log entries dropped because the buffer was full.",
+		}),
+		entries: prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+			Name: "loki_buffer_entries",
+			Help: "Number of log entries in the buffer.",
+		}),
+		bytes: prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+			Name: "loki_buffer_bytes",
+			Help: "Size of the log entries in the buffer.",
+		}),
+	}
+	for _, c := range []prometheus_client.Collector{m.dropped, m.entries, m.bytes} {
+		if err := o.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Component{
+		opts:    o,
+		metrics: m,
+		pushed:  make(chan struct{}, 1),
+		popped:  make(chan struct{}, 1),
+	}
+
+	// Create and immediately export the receiver which remains the same for
+	// the component's lifetime.
+	c.receiver = loki.NewLogsReceiver()
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.forward(ctx)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.push(ctx, entry)
+		}
+	}
+}
+
+// push buffers entry, applying the overflow policy while the buffer is full.
+// With the block policy, it waits for room in the buffer, which stops
+// receiving entries.
+func (c *Component) push(ctx context.Context, entry loki.Entry) {
+	size := entrySize(entry)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for c.full(size) {
+		switch {
+		case c.args.MaxBytes > 0 && size > int(c.args.MaxBytes):
+			// The entry can never fit in the buffer.
+			c.metrics.dropped.Inc()
+			return
+		case c.args.OverflowPolicy == PolicyDropNewest:
+			c.metrics.dropped.Inc()
+			return
+		case c.args.OverflowPolicy == PolicyDropOldest:
+			c.pop()
+			c.metrics.dropped.Inc()
+		default:
+			c.mut.Unlock()
+			select {
+			case <-ctx.Done():
+				c.mut.Lock()
+				return
+			case <-c.popped:
+			}
+			c.mut.Lock()
+		}
+	}
+
+	c.entries = append(c.entries, entry)
+	c.bytes += size
+	c.updateMetrics()
+	signal(c.pushed)
+}
+
+// full returns whether an entry of the given size doesn't fit in the buffer.
+func (c *Component) full(size int) bool {
+	if c.args.MaxEntries > 0 && len(c.entries) >= c.args.MaxEntries {
+		return true
+	}
+	return c.args.MaxBytes > 0 && c.bytes+size > int(c.args.MaxBytes)
+}
+
+// pop removes the oldest entry from the buffer. c.mut must be held.
+func (c *Component) pop() (loki.Entry, bool) {
+	if len(c.entries) == 0 {
+		return loki.Entry{}, false
+	}
+	entry := c.entries[0]
+	c.entries[0] = loki.Entry{}
+	c.entries = c.entries[1:]
+	c.bytes -= entrySize(entry)
+	c.updateMetrics()
+	signal(c.popped)
+	return entry, true
+}
+
+func (c *Component) updateMetrics() {
+	c.metrics.entries.Set(float64(len(c.entries)))
+	c.metrics.bytes.Set(float64(c.bytes))
+}
+
+// forward sends the buffered entries to the receivers in order until ctx is
+// canceled.
+func (c *Component) forward(ctx context.Context) {
+	for {
+		c.mut.Lock()
+		entry, ok := c.pop()
+		receivers := c.args.ForwardTo
+		c.inflight = ok
+		c.mut.Unlock()
+
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.pushed:
+				continue
+			}
+		}
+
+		for _, receiver := range receivers {
+			select {
+			case <-ctx.Done():
+				return
+			case receiver.Chan() <- entry:
+			}
+		}
+	}
+}
+
+// drainCheckFrequency is how often Drain checks whether the buffer is empty.
+var drainCheckFrequency = 100 * time.Millisecond
+
+// Drain implements component.DrainableComponent. It waits for the buffered
+// entries to be sent to the receivers.
+func (c *Component) Drain(ctx context.Context) error {
+	for {
+		c.mut.Lock()
+		empty := len(c.entries) == 0 && !c.inflight
+		c.mut.Unlock()
+		if empty {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(drainCheckFrequency):
+		}
+	}
+}
+
+// Update implements component.Component. Lowering the limits doesn't drop the
+// entries already buffered.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+
+	// Entries waiting for room in the buffer may fit with the new limits.
+	signal(c.popped)
+	return nil
+}
+
+// entrySize returns the size of the line and labels of entry.
+func entrySize(entry loki.Entry) int {
+	size := len(entry.Line)
+	for name, value := range entry.Labels {
+		size += len(name) + len(value)
+	}
+	return size
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
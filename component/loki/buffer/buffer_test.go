@@ -0,0 +1,166 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverflowPolicy(t *testing.T) {
+	tt := []struct {
+		policy  string
+		forward []string
+		dropped float64
+	}{
+		{policy: PolicyDropOldest, forward: []string{"0", "3", "4"}, dropped: 2},
+		{policy: PolicyDropNewest, forward: []string{"0", "1", "2"}, dropped: 2},
+		{policy: PolicyBlock, forward: []string{"0", "1", "2", "3", "4"}, dropped: 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.policy, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// The sink is stalled until the test reads from it.
+			sink := loki.NewLogsReceiver()
+			c := newTestComponent(t, fmt.Sprintf(`
+			forward_to      = []
+			max_entries     = 2
+			overflow_policy = %q
+			`, tc.policy), sink)
+			go c.Run(ctx)
+
+			// The first entry is taken from the buffer and stuck being sent.
+			c.receiver.Chan() <- newEntry("0")
+			require.Eventually(t, func() bool {
+				c.mut.Lock()
+				defer c.mut.Unlock()
+				return c.inflight
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// The blocked entry is received by the component, but the next one
+			// can't be.
+			pending := make(chan loki.Entry, 4)
+			for _, line := range []string{"1", "2", "3", "4"} {
+				pending <- newEntry(line)
+			}
+			close(pending)
+			sent := make(chan struct{})
+			go func() {
+				defer close(sent)
+				for entry := range pending {
+					c.receiver.Chan() <- entry
+				}
+			}()
+			if tc.policy == PolicyBlock {
+				require.Never(t, func() bool {
+					select {
+					case <-sent:
+						return true
+					default:
+						return false
+					}
+				}, 200*time.Millisecond, 10*time.Millisecond)
+			} else {
+				<-sent
+			}
+			require.Eventually(t, func() bool {
+				return testutil.ToFloat64(c.metrics.dropped) == tc.dropped
+			}, 5*time.Second, 10*time.Millisecond)
+
+			var forwarded []string
+			for len(forwarded) < len(tc.forward) {
+				select {
+				case entry := <-sink.Chan():
+					forwarded = append(forwarded, entry.Line)
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "entries weren't forwarded", "forwarded %v", forwarded)
+				}
+			}
+			require.Equal(t, tc.forward, forwarded)
+			<-sent
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := loki.NewLogsReceiver()
+	c := newTestComponent(t, `
+	forward_to  = []
+	max_entries = 0
+	max_bytes   = "10B"
+	`, sink)
+
+	// The entries are buffered before the component runs.
+	for _, line := range []string{"aaaa", "bbbb", "cccc", "entry larger than the buffer"} {
+		c.push(ctx, newEntry(line))
+	}
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.dropped))
+	require.Equal(t, 8.0, testutil.ToFloat64(c.metrics.bytes))
+
+	go c.Run(ctx)
+	for _, line := range []string{"bbbb", "cccc"} {
+		select {
+		case entry := <-sink.Chan():
+			require.Equal(t, line, entry.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "entries weren't forwarded")
+		}
+	}
+
+	drainCtx, drainCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer drainCancel()
+	require.NoError(t, c.Drain(drainCtx))
+	require.Equal(t, 0.0, testutil.ToFloat64(c.metrics.entries))
+}
+
+func TestArguments_Validate(t *testing.T) {
+	tt := []struct {
+		config string
+		err    string
+	}{
+		{config: `max_entries = -1`, err: "max_entries must not be negative"},
+		{config: `max_entries = 0`, err: "at least one of max_entries and max_bytes must be set"},
+		{config: `overflow_policy = "drop"`, err: `overflow_policy must be "drop_oldest", "drop_newest" or "block", got "drop"`},
+	}
+	for _, tc := range tt {
+		var args Arguments
+		err := river.Unmarshal([]byte("forward_to = []\n"+tc.config), &args)
+		require.EqualError(t, err, tc.err)
+	}
+}
+
+func newTestComponent(t *testing.T, config string, sink loki.LogsReceiver) *Component {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(config), &args))
+	args.ForwardTo = []loki.LogsReceiver{sink}
+
+	c, err := New(component.Options{
+		ID:            "loki.buffer.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+	return c
+}
+
+func newEntry(line string) loki.Entry {
+	return loki.Entry{
+		Entry: logproto.Entry{Timestamp: time.Now(), Line: line},
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -31,6 +32,14 @@ func Test_validateCounterConfig(t *testing.T) {
 			},
 			"the 'action' counter field must be either 'inc' or 'add'",
 		},
+		{"invalid max series",
+			CounterConfig{
+				Action:    "inc",
+				MaxIdle:   1 * time.Second,
+				MaxSeries: -1,
+			},
+			"max_series must not be negative",
+		},
 		{"invalid counter match all",
 			CounterConfig{
 				MatchAll: counterTestTrue,
@@ -138,6 +147,39 @@ func TestCounterExpiration(t *testing.T) {
 	assert.Contains(t, cnt.metrics, lbl2.Fingerprint())
 }
 
+func TestCounterMaxSeries(t *testing.T) {
+	t.Parallel()
+	cfg := &CounterConfig{
+		Action:    "inc",
+		MaxIdle:   1 * time.Second,
+		MaxSeries: 2,
+	}
+
+	cnt, err := NewCounters("test_max_series", cfg)
+	assert.Nil(t, err)
+
+	lbl1 := model.LabelSet{"path": "/one"}
+	lbl2 := model.LabelSet{"path": "/two"}
+	lbl3 := model.LabelSet{"path": "/three"}
+	cnt.With(lbl1).Inc()
+	cnt.With(lbl2).Inc()
+
+	// The limit is reached, so updates to new series are discarded while
+	// existing series keep being updated.
+	cnt.With(lbl3).Inc()
+	cnt.With(lbl1).Inc()
+	assert.Len(t, cnt.metrics, 2)
+	assert.NotContains(t, cnt.metrics, lbl3.Fingerprint())
+	assert.Equal(t, 2.0, testutil.ToFloat64(cnt.With(lbl1)))
+
+	time.Sleep(1100 * time.Millisecond) // Wait just past our max idle of 1 sec
+
+	// Once the existing series are idle, they're evicted to make room.
+	cnt.With(lbl3).Inc()
+	assert.Contains(t, cnt.metrics, lbl3.Fingerprint())
+	assert.Equal(t, 1.0, testutil.ToFloat64(cnt.With(lbl3)))
+}
+
 func collect(c prometheus.Collector) {
 	done := make(chan struct{})
 	collector := make(chan prometheus.Metric)
@@ -23,13 +23,17 @@ type metricVec struct {
 	mtx       sync.Mutex
 	metrics   map[model.Fingerprint]prometheus.Metric
 	maxAgeSec int64
+	// maxSeries is the maximum number of series tracked by the vector. Zero
+	// means no limit.
+	maxSeries int
 }
 
-func newMetricVec(factory func(labels map[string]string) prometheus.Metric, maxAgeSec int64) *metricVec {
+func newMetricVec(factory func(labels map[string]string) prometheus.Metric, maxAgeSec int64, maxSeries int) *metricVec {
 	return &metricVec{
 		metrics:   map[model.Fingerprint]prometheus.Metric{},
 		factory:   factory,
 		maxAgeSec: maxAgeSec,
+		maxSeries: maxSeries,
 	}
 }
 
@@ -48,6 +52,9 @@ func (c *metricVec) Collect(ch chan<- prometheus.Metric) {
 }
 
 // With returns the metric associated with the labelset.
+//
+// If the vector already tracks its maximum number of series, a new labelset
+// returns a metric which isn't tracked, so updates to it are discarded.
 func (c *metricVec) With(labels model.LabelSet) prometheus.Metric {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
@@ -56,6 +63,13 @@ func (c *metricVec) With(labels model.LabelSet) prometheus.Metric {
 	var metric prometheus.Metric
 	if metric, ok = c.metrics[fp]; !ok {
 		metric = c.factory(util.ModelLabelSetToMap(cleanLabels(labels)))
+		if c.maxSeries > 0 && len(c.metrics) >= c.maxSeries {
+			// Make room for the new series by removing idle ones first.
+			c.prune()
+			if len(c.metrics) >= c.maxSeries {
+				return metric
+			}
+		}
 		c.metrics[fp] = metric
 	}
 	return metric
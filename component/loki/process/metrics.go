@@ -0,0 +1,181 @@
+package process
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/grafana/agent/component/loki/process/metric"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// metricsRegisterer is a prometheus.Registerer which additionally registers
+// the collectors of stage.metrics blocks into a separate registry, so that
+// the metrics derived from log lines can be forwarded to other components.
+type metricsRegisterer struct {
+	prometheus.Registerer
+	forward *prometheus.Registry
+}
+
+var _ prometheus.Registerer = (*metricsRegisterer)(nil)
+
+// Register implements prometheus.Registerer.
+func (r *metricsRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+
+	switch c.(type) {
+	case *metric.Counters, *metric.Gauges, *metric.Histograms:
+		return r.forward.Register(c)
+	}
+	return nil
+}
+
+// MustRegister implements prometheus.Registerer.
+func (r *metricsRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister implements prometheus.Registerer.
+func (r *metricsRegisterer) Unregister(c prometheus.Collector) bool {
+	r.forward.Unregister(c)
+	return r.Registerer.Unregister(c)
+}
+
+// handleMetrics periodically forwards the metrics of stage.metrics blocks to
+// the receivers in metrics_forward_to.
+func (c *Component) handleMetrics(ctx context.Context) {
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	resetTicker := func() {
+		c.mut.RLock()
+		enabled := len(c.metricsForwardTo) > 0
+		interval := c.metricsInterval
+		c.mut.RUnlock()
+
+		switch {
+		case !enabled && ticker != nil:
+			ticker.Stop()
+			ticker, tick = nil, nil
+		case enabled && ticker == nil:
+			ticker = time.NewTicker(interval)
+			tick = ticker.C
+		case enabled:
+			ticker.Reset(interval)
+		}
+	}
+	resetTicker()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.metricsUpdated:
+			resetTicker()
+		case <-tick:
+			c.forwardMetrics(ctx)
+		}
+	}
+}
+
+// forwardMetrics appends the current value of every metric defined in
+// stage.metrics blocks to the receivers in metrics_forward_to.
+func (c *Component) forwardMetrics(ctx context.Context) {
+	c.mut.RLock()
+	registry, fanout := c.metricsRegistry, c.metricsFanout
+	c.mut.RUnlock()
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to gather metrics from stage.metrics blocks", "err", err)
+		return
+	}
+
+	app := fanout.Appender(ctx)
+	if err := appendMetricFamilies(app, mfs, time.Now().UnixMilli()); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to forward metrics from stage.metrics blocks", "err", err)
+		_ = app.Rollback()
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to forward metrics from stage.metrics blocks", "err", err)
+	}
+}
+
+// appendMetricFamilies appends the samples of mfs to app with the timestamp
+// ts. Histograms are appended as classic histograms, with a series for every
+// bucket and series for the sum and count of observations.
+func appendMetricFamilies(app storage.Appender, mfs []*dto.MetricFamily, ts int64) error {
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			lb := labels.NewScratchBuilder(len(m.GetLabel()))
+			for _, lp := range m.GetLabel() {
+				lb.Add(lp.GetName(), lp.GetValue())
+			}
+			lb.Sort()
+			base := lb.Labels()
+
+			appendSample := func(name string, value float64, extra ...string) error {
+				b := labels.NewBuilder(base)
+				b.Set(model.MetricNameLabel, name)
+				for i := 0; i+1 < len(extra); i += 2 {
+					b.Set(extra[i], extra[i+1])
+				}
+				_, err := app.Append(0, b.Labels(), ts, value)
+				return err
+			}
+
+			var err error
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				err = appendSample(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				err = appendSample(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				err = appendSample(name, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						continue
+					}
+					le := strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)
+					if err = appendSample(name+"_bucket", float64(b.GetCumulativeCount()), model.BucketLabel, le); err != nil {
+						return err
+					}
+				}
+				if err = appendSample(name+"_bucket", float64(h.GetSampleCount()), model.BucketLabel, "+Inf"); err != nil {
+					return err
+				}
+				if err = appendSample(name+"_sum", h.GetSampleSum()); err != nil {
+					return err
+				}
+				err = appendSample(name+"_count", float64(h.GetSampleCount()))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
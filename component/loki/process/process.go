@@ -5,12 +5,19 @@ package process
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/common/loki"
 	"github.com/grafana/agent/component/loki/process/stages"
+	agentprom "github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/storage"
 )
 
 // TODO(thampiotr): We should reconsider which parts of this component should be exported and which should
@@ -20,9 +27,10 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "loki.process",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "loki.process",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
 		},
@@ -32,8 +40,28 @@ func init() {
 // Arguments holds values which are used to configure the loki.process
 // component.
 type Arguments struct {
-	ForwardTo []loki.LogsReceiver  `river:"forward_to,attr"`
-	Stages    []stages.StageConfig `river:"stage,enum,optional"`
+	ForwardTo              []loki.LogsReceiver  `river:"forward_to,attr"`
+	MetricsForwardTo       []storage.Appendable `river:"metrics_forward_to,attr,optional"`
+	MetricsForwardInterval time.Duration        `river:"metrics_forward_interval,attr,optional"`
+	Stages                 []stages.StageConfig `river:"stage,enum,optional"`
+}
+
+// DefaultArguments holds the default settings for loki.process.
+var DefaultArguments = Arguments{
+	MetricsForwardInterval: time.Minute,
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.MetricsForwardInterval <= 0 {
+		return fmt.Errorf("metrics_forward_interval must be greater than 0")
+	}
+	return nil
 }
 
 // Exports exposes the receiver that can be used to send log entries to
@@ -59,12 +87,21 @@ type Component struct {
 
 	fanoutMut sync.RWMutex
 	fanout    []loki.LogsReceiver
+
+	// Metrics of stage.metrics blocks, forwarded to metrics_forward_to.
+	// Protected by mut.
+	metricsRegistry  *prometheus.Registry
+	metricsFanout    *agentprom.Fanout
+	metricsForwardTo []storage.Appendable
+	metricsInterval  time.Duration
+	metricsUpdated   chan struct{}
 }
 
 // New creates a new loki.process component.
 func New(o component.Options, args Arguments) (*Component, error) {
 	c := &Component{
-		opts: o,
+		opts:           o,
+		metricsUpdated: make(chan struct{}, 1),
 	}
 
 	// Create and immediately export the receiver which remains the same for
@@ -92,9 +129,13 @@ func (c *Component) Run(ctx context.Context) error {
 		c.mut.RUnlock()
 	}()
 	wg := &sync.WaitGroup{}
-	wg.Add(2)
+	wg.Add(3)
 	go c.handleIn(ctx, wg)
 	go c.handleOut(ctx, wg)
+	go func() {
+		defer wg.Done()
+		c.handleMetrics(ctx)
+	}()
 
 	wg.Wait()
 	return nil
@@ -121,15 +162,36 @@ func (c *Component) Update(args component.Arguments) error {
 			c.entryHandler.Stop()
 		}
 
-		pipeline, err := stages.NewPipeline(c.opts.Logger, newArgs.Stages, &c.opts.ID, c.opts.Registerer)
+		metricsRegistry := prometheus.NewRegistry()
+		registerer := &metricsRegisterer{Registerer: c.opts.Registerer, forward: metricsRegistry}
+		pipeline, err := stages.NewPipeline(c.opts.Logger, newArgs.Stages, &c.opts.ID, registerer)
 		if err != nil {
 			return err
 		}
 		c.entryHandler = loki.NewEntryHandler(c.processOut, func() {})
 		c.processIn = pipeline.Wrap(c.entryHandler).Chan()
 		c.stages = newArgs.Stages
+		c.metricsRegistry = metricsRegistry
+	}
+
+	// The label store is only needed once metrics are forwarded.
+	if len(newArgs.MetricsForwardTo) > 0 && c.metricsFanout == nil {
+		data, err := c.opts.GetServiceData(labelstore.ServiceName)
+		if err != nil {
+			return err
+		}
+		c.metricsFanout = agentprom.NewFanout(nil, c.opts.ID, c.opts.Registerer, data.(labelstore.LabelStore))
 	}
+	if c.metricsFanout != nil {
+		c.metricsFanout.UpdateChildren(newArgs.MetricsForwardTo)
+	}
+	c.metricsForwardTo = newArgs.MetricsForwardTo
+	c.metricsInterval = newArgs.MetricsForwardInterval
 
+	select {
+	case c.metricsUpdated <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
@@ -4,7 +4,10 @@ package process
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,12 +16,18 @@ import (
 	"github.com/grafana/agent/component/discovery"
 	"github.com/grafana/agent/component/loki/process/stages"
 	lsf "github.com/grafana/agent/component/loki/source/file"
+	agentprom "github.com/grafana/agent/component/prometheus"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/push"
 	"github.com/grafana/river"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 	"go.uber.org/goleak"
@@ -212,6 +221,69 @@ stage.label_keep {
 	}
 }
 
+func TestRegexLabelTemplate(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+
+	// The regex stage captures fields from the log line, and the
+	// label_template stage composes a single label from them. The user field
+	// is missing from the line and is rendered as empty.
+	stg := `
+stage.regex {
+    expression = "^(?P<cluster>\\S+) (?P<namespace>\\S+)( user=(?P<user>\\S+))?"
+}
+stage.label_template {
+    label    = "scope"
+    template = "{{ .cluster }}/{{ .namespace }}/{{ .user }}"
+}`
+
+	type cfg struct {
+		Stages []stages.StageConfig `river:"stage,enum"`
+	}
+	var stagesCfg cfg
+	err := river.Unmarshal([]byte(stg), &stagesCfg)
+	require.NoError(t, err)
+
+	ch1 := loki.NewLogsReceiver()
+
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+	}
+	args := Arguments{
+		ForwardTo: []loki.LogsReceiver{ch1},
+		Stages:    stagesCfg.Stages,
+	}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	logline := "prod-eu loki-write request received"
+	c.receiver.Chan() <- loki.Entry{
+		Labels: model.LabelSet{"filename": "/var/log/app.log"},
+		Entry: logproto.Entry{
+			Timestamp: time.Now(),
+			Line:      logline,
+		},
+	}
+
+	wantLabelSet := model.LabelSet{
+		"filename": "/var/log/app.log",
+		"scope":    "prod-eu/loki-write/",
+	}
+
+	select {
+	case logEntry := <-ch1.Chan():
+		require.Equal(t, logline, logEntry.Line)
+		require.Equal(t, wantLabelSet, logEntry.Labels)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for log line")
+	}
+}
+
 func TestRegexTimestampOutput(t *testing.T) {
 	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
 
@@ -311,6 +383,367 @@ stage.labels {
 	}
 }
 
+func TestMetricsForwarding(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+
+	// Lines containing "error" are counted by a stage.metrics block nested in
+	// a stage.match block, and the counter is forwarded to metrics_forward_to.
+	stg := `
+stage.match {
+    selector = "{job=\"app\"} |= \"error\""
+
+    stage.metrics {
+        metric.counter {
+            name      = "error_lines_total"
+            match_all = true
+            action    = "inc"
+        }
+    }
+}`
+
+	type cfg struct {
+		Stages []stages.StageConfig `river:"stage,enum"`
+	}
+	var stagesCfg cfg
+	err := river.Unmarshal([]byte(stg), &stagesCfg)
+	require.NoError(t, err)
+
+	var (
+		mut      sync.Mutex
+		received = map[string]float64{}
+	)
+	sink := agentprom.NewInterceptor(nil, labelstore.New(nil), agentprom.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		mut.Lock()
+		defer mut.Unlock()
+		received[l.String()] = v
+		return ref, nil
+	}))
+
+	ch1 := loki.NewLogsReceiver()
+	opts := component.Options{
+		ID:            "loki.process.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}
+	args := Arguments{
+		ForwardTo:              []loki.LogsReceiver{ch1},
+		MetricsForwardTo:       []storage.Appendable{sink},
+		MetricsForwardInterval: 10 * time.Millisecond,
+		Stages:                 stagesCfg.Stages,
+	}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		_ = c.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-runDone
+	}()
+
+	for _, line := range []string{"error: connection refused", "request served", "error: timeout"} {
+		c.receiver.Chan() <- loki.Entry{
+			Labels: model.LabelSet{"job": "app"},
+			Entry:  logproto.Entry{Timestamp: time.Now(), Line: line},
+		}
+		select {
+		case <-ch1.Chan():
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+
+	series := labels.FromStrings("__name__", "loki_process_custom_error_lines_total", "job", "app").String()
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return received[series] == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Only the metrics of stage.metrics blocks are forwarded.
+	mut.Lock()
+	defer mut.Unlock()
+	require.Len(t, received, 1)
+}
+
+func TestAppendMetricFamilies(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "request_duration_seconds",
+		Buckets: []float64{0.5, 1},
+	}, []string{"path"})
+	reg.MustRegister(h)
+	h.WithLabelValues("/").Observe(0.25)
+	h.WithLabelValues("/").Observe(0.75)
+	h.WithLabelValues("/").Observe(2)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	app := &sampleAppender{samples: map[string]float64{}}
+	require.NoError(t, appendMetricFamilies(app, mfs, 1000))
+	require.Equal(t, map[string]float64{
+		`{__name__="request_duration_seconds_bucket", le="0.5", path="/"}`:  1,
+		`{__name__="request_duration_seconds_bucket", le="1", path="/"}`:    2,
+		`{__name__="request_duration_seconds_bucket", le="+Inf", path="/"}`: 3,
+		`{__name__="request_duration_seconds_sum", path="/"}`:               3,
+		`{__name__="request_duration_seconds_count", path="/"}`:             3,
+	}, app.samples)
+}
+
+type sampleAppender struct {
+	storage.Appender
+	samples map[string]float64
+}
+
+func (app *sampleAppender) Append(_ storage.SeriesRef, l labels.Labels, _ int64, v float64) (storage.SeriesRef, error) {
+	app.samples[l.String()] = v
+	return 0, nil
+}
+
+func TestTimestampParseFailureFallback(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+
+	// Lines with a malformed timestamp must still be forwarded, keeping the
+	// time at which they were ingested.
+	stg := `
+stage.json {
+    expressions = { ts = "" }
+}
+stage.timestamp {
+    source            = "ts"
+    format            = "UnixMs"
+    fallback_formats  = ["RFC3339"]
+    action_on_failure = "skip"
+}`
+
+	type cfg struct {
+		Stages []stages.StageConfig `river:"stage,enum"`
+	}
+	var stagesCfg cfg
+	err := river.Unmarshal([]byte(stg), &stagesCfg)
+	require.NoError(t, err)
+
+	ch1 := loki.NewLogsReceiver()
+
+	reg := prometheus.NewRegistry()
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) {},
+	}
+	args := Arguments{
+		ForwardTo: []loki.LogsReceiver{ch1},
+		Stages:    stagesCfg.Stages,
+	}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	ingested := time.Unix(1700000000, 0)
+	tt := []struct {
+		line string
+		want time.Time
+	}{
+		{line: `{"ts": "1642432662000"}`, want: time.UnixMilli(1642432662000)},
+		{line: `{"ts": "2022-01-17T08:17:42-07:00"}`, want: time.Date(2022, 1, 17, 8, 17, 42, 0, time.FixedZone("", -7*60*60))},
+		{line: `{"ts": "yesterday"}`, want: ingested},
+	}
+
+	for _, tc := range tt {
+		c.receiver.Chan() <- loki.Entry{
+			Labels: model.LabelSet{"filename": "/var/log/app.log"},
+			Entry: logproto.Entry{
+				Timestamp: ingested,
+				Line:      tc.line,
+			},
+		}
+
+		select {
+		case logEntry := <-ch1.Chan():
+			require.Equal(t, tc.line, logEntry.Line)
+			require.True(t, tc.want.Equal(logEntry.Timestamp), "line %s: expected timestamp %s, got %s", tc.line, tc.want, logEntry.Timestamp)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line", tc.line)
+		}
+	}
+
+	expected := `
+# HELP loki_process_timestamp_failures_total A count of all log lines for which the timestamp stage failed to extract or parse a timestamp
+# TYPE loki_process_timestamp_failures_total counter
+loki_process_timestamp_failures_total{reason="parsing_failed"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "loki_process_timestamp_failures_total"))
+}
+
+func TestDedupStage(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+
+	stg := `
+stage.dedup {
+    window = "200ms"
+}`
+
+	type cfg struct {
+		Stages []stages.StageConfig `river:"stage,enum"`
+	}
+	var stagesCfg cfg
+	err := river.Unmarshal([]byte(stg), &stagesCfg)
+	require.NoError(t, err)
+
+	ch1 := loki.NewLogsReceiver()
+
+	reg := prometheus.NewRegistry()
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) {},
+	}
+	args := Arguments{
+		ForwardTo: []loki.LogsReceiver{ch1},
+		Stages:    stagesCfg.Stages,
+	}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	for i := 0; i < 5; i++ {
+		c.receiver.Chan() <- loki.Entry{
+			Labels: model.LabelSet{"filename": "/var/log/app.log"},
+			Entry: logproto.Entry{
+				Timestamp: time.Now(),
+				Line:      "connection refused",
+			},
+		}
+	}
+
+	// Only the first line and a summary of the other lines are forwarded,
+	// once the window expires.
+	for _, want := range []push.LabelsAdapter{
+		nil,
+		{{Name: "suppressed_count", Value: "4"}},
+	} {
+		select {
+		case logEntry := <-ch1.Chan():
+			require.Equal(t, "connection refused", logEntry.Line)
+			require.Equal(t, model.LabelSet{"filename": "/var/log/app.log"}, logEntry.Labels)
+			require.Equal(t, want, logEntry.StructuredMetadata)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+
+	select {
+	case logEntry := <-ch1.Chan():
+		require.FailNow(t, "unexpected log line", logEntry.Line)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	expected := `
+# HELP loki_process_dropped_lines_total A count of all log lines dropped as a result of a pipeline stage
+# TYPE loki_process_dropped_lines_total counter
+loki_process_dropped_lines_total{reason="dedup_stage"} 3
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "loki_process_dropped_lines_total"))
+}
+
+func TestSamplingStage(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+
+	stg := `
+stage.sampling {
+    rate          = 0.1
+    keep_selector = "{level=\"error\"}"
+}`
+
+	type cfg struct {
+		Stages []stages.StageConfig `river:"stage,enum"`
+	}
+	var stagesCfg cfg
+	err := river.Unmarshal([]byte(stg), &stagesCfg)
+	require.NoError(t, err)
+
+	ch1 := loki.NewLogsReceiver()
+
+	reg := prometheus.NewRegistry()
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) {},
+	}
+	args := Arguments{
+		ForwardTo: []loki.LogsReceiver{ch1},
+		Stages:    stagesCfg.Stages,
+	}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	const total = 5000
+	go func() {
+		for i := 0; i < total; i++ {
+			level := "info"
+			if i%100 == 0 {
+				level = "error"
+			}
+			c.receiver.Chan() <- loki.Entry{
+				Labels: model.LabelSet{"filename": "/var/log/app.log", "level": model.LabelValue(level)},
+				Entry: logproto.Entry{
+					Timestamp: time.Now(),
+					Line:      fmt.Sprintf("request %d", i),
+				},
+			}
+		}
+	}()
+
+	var info, errors int
+	for {
+		select {
+		case logEntry := <-ch1.Chan():
+			if logEntry.Labels["level"] == "error" {
+				errors++
+			} else {
+				info++
+			}
+			continue
+		case <-time.After(500 * time.Millisecond):
+		}
+		break
+	}
+
+	// All the error lines bypass sampling, roughly a tenth of the other lines
+	// are forwarded.
+	require.Equal(t, total/100, errors)
+	require.InDelta(t, 495, info, 100)
+
+	expected := fmt.Sprintf(`
+# HELP loki_process_dropped_lines_total A count of all log lines dropped as a result of a pipeline stage
+# TYPE loki_process_dropped_lines_total counter
+loki_process_dropped_lines_total{reason="sampling_stage"} %d
+# HELP loki_process_sampled_lines_total A count of all log lines kept by sampling stages
+# TYPE loki_process_sampled_lines_total counter
+loki_process_sampled_lines_total %d
+`, total-errors-info, info)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "loki_process_dropped_lines_total", "loki_process_sampled_lines_total"))
+}
+
 func TestEntrySentToTwoProcessComponents(t *testing.T) {
 	// Set up two different loki.process components.
 	stg1 := `
@@ -391,6 +824,64 @@ stage.static_labels {
 	}
 }
 
+func TestMultilineStackTraceFromFile(t *testing.T) {
+	// Lines starting with whitespace or "Caused by:" are continuations of the
+	// previous line, so every Java stack trace is collapsed into one entry.
+	stg := `
+forward_to = []
+stage.multiline {
+    continuation  = "^(\\s+|Caused by:)"
+    max_wait_time = "500ms"
+}
+`
+	ch := loki.NewLogsReceiver()
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(stg), &args))
+	args.ForwardTo = []loki.LogsReceiver{ch}
+
+	tc, err := componenttest.NewControllerFromID(util.TestLogger(t), "loki.process")
+	require.NoError(t, err)
+	go func() { require.NoError(t, tc.Run(componenttest.TestContext(t), args)) }()
+	require.NoError(t, tc.WaitExports(time.Second))
+
+	f, err := os.CreateTemp(t.TempDir(), "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "loki.source.file")
+	require.NoError(t, err)
+	go func() {
+		err := ctrl.Run(componenttest.TestContext(t), lsf.Arguments{
+			Targets:   []discovery.Target{{"__path__": f.Name()}},
+			ForwardTo: []loki.LogsReceiver{tc.Exports().(Exports).Receiver},
+		})
+		require.NoError(t, err)
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+
+	stackTrace := strings.Join([]string{
+		`Exception in thread "main" java.lang.IllegalStateException: boom`,
+		`	at com.example.App.run(App.java:42)`,
+		`	at com.example.App.main(App.java:12)`,
+		`Caused by: java.lang.NullPointerException`,
+		`	at com.example.App.load(App.java:99)`,
+	}, "\n")
+	_, err = f.WriteString("starting app\n" + stackTrace + "\n")
+	require.NoError(t, err)
+
+	// The first line is emitted once the stack trace starts. The stack trace
+	// is the last buffered block, so it's only emitted after max_wait_time.
+	for _, want := range []string{"starting app", stackTrace} {
+		select {
+		case logEntry := <-ch.Chan():
+			require.Equal(t, want, logEntry.Line)
+			require.Equal(t, model.LabelSet{"filename": model.LabelValue(f.Name())}, logEntry.Labels)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+}
+
 func TestDeadlockWithFrequentUpdates(t *testing.T) {
 	stg := `stage.json { 
 			    expressions    = {"output" = "log", stream = "stream", timestamp = "time", "extra" = "" }
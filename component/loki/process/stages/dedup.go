@@ -0,0 +1,151 @@
+package stages
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+var defaultDedupReason = "dedup_stage"
+
+// minDedupWindow is the shortest window supported by the dedup stage, which
+// checks for expired windows several times per window.
+const minDedupWindow = 100 * time.Millisecond
+
+// DedupConfig contains the configuration for a dedupStage.
+type DedupConfig struct {
+	Window     time.Duration `river:"window,attr,optional"`
+	CountLabel string        `river:"count_label,attr,optional"`
+	DropReason string        `river:"drop_counter_reason,attr,optional"`
+}
+
+// DefaultDedupConfig holds default settings for DedupConfig.
+var DefaultDedupConfig = DedupConfig{
+	Window:     10 * time.Second,
+	CountLabel: "suppressed_count",
+	DropReason: defaultDedupReason,
+}
+
+// SetToDefault implements river.Defaulter.
+func (c *DedupConfig) SetToDefault() {
+	*c = DefaultDedupConfig
+}
+
+// Validate implements river.Validator.
+func (c *DedupConfig) Validate() error {
+	if c.Window < minDedupWindow {
+		return fmt.Errorf("window must be at least %s", minDedupWindow)
+	}
+	if !model.LabelName(c.CountLabel).IsValid() {
+		return fmt.Errorf("count_label %q is not a valid label name", c.CountLabel)
+	}
+	return nil
+}
+
+// dedupStage suppresses the log lines which are identical to the previous
+// line of their stream, for a window of time starting at the first occurrence
+// of the line. When the window expires or a different line is received, the
+// last suppressed line is sent with structured metadata holding the number of
+// suppressed lines.
+type dedupStage struct {
+	logger    log.Logger
+	cfg       DedupConfig
+	dropCount *prometheus.CounterVec
+}
+
+// dedupState captures the internal state of a stream of a running dedup stage.
+type dedupState struct {
+	line       string    // The line of the current window.
+	start      time.Time // The time the window started at.
+	suppressed int       // The number of lines suppressed in the window.
+	last       Entry     // The last suppressed entry of the window.
+}
+
+func newDedupStage(logger log.Logger, cfg DedupConfig, registerer prometheus.Registerer) Stage {
+	return &dedupStage{
+		logger:    log.With(logger, "component", "stage", "type", "dedup"),
+		cfg:       cfg,
+		dropCount: getDropCountMetric(registerer),
+	}
+}
+
+func (m *dedupStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+
+		streams := make(map[model.Fingerprint]*dedupState)
+
+		// Expired windows are checked for several times per window, so that
+		// summaries are sent shortly after their window expires.
+		ticker := time.NewTicker(m.cfg.Window / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now()
+				for key, s := range streams {
+					if now.Sub(s.start) >= m.cfg.Window {
+						m.flush(out, s)
+						delete(streams, key)
+					}
+				}
+			case e, ok := <-in:
+				if !ok {
+					for _, s := range streams {
+						m.flush(out, s)
+					}
+					return
+				}
+
+				now := time.Now()
+				key := e.Labels.FastFingerprint()
+				s, found := streams[key]
+				if found && s.line == e.Line && now.Sub(s.start) < m.cfg.Window {
+					s.suppressed++
+					s.last = e
+					continue
+				}
+
+				if found {
+					m.flush(out, s)
+				}
+				streams[key] = &dedupState{line: e.Line, start: now}
+				out <- e
+			}
+		}
+	}()
+	return out
+}
+
+// flush sends the last suppressed entry of s, if any, with structured metadata
+// holding the number of suppressed entries it stands for. The other suppressed entries are
+// dropped.
+func (m *dedupStage) flush(out chan Entry, s *dedupState) {
+	if s.suppressed == 0 {
+		return
+	}
+	m.dropCount.WithLabelValues(m.cfg.DropReason).Add(float64(s.suppressed - 1))
+
+	// The count is sent as structured metadata rather than a label, so that
+	// summaries don't create a new stream for every count.
+	summary := s.last
+	summary.StructuredMetadata = append(append([]logproto.LabelAdapter(nil), summary.StructuredMetadata...), logproto.LabelAdapter{
+		Name:  m.cfg.CountLabel,
+		Value: strconv.Itoa(s.suppressed),
+	})
+	s.suppressed = 0
+
+	out <- summary
+}
+
+// Name implements Stage
+func (m *dedupStage) Name() string {
+	return StageTypeDedup
+}
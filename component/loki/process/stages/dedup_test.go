@@ -0,0 +1,99 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupConfig(t *testing.T) {
+	var cfg DedupConfig
+	require.NoError(t, river.Unmarshal([]byte(``), &cfg))
+	require.Equal(t, DefaultDedupConfig, cfg)
+
+	require.NoError(t, river.Unmarshal([]byte(`
+		window      = "1m"
+		count_label = "repeated"
+	`), &cfg))
+	require.Equal(t, time.Minute, cfg.Window)
+	require.Equal(t, "repeated", cfg.CountLabel)
+
+	require.EqualError(t, river.Unmarshal([]byte(`window = "0s"`), &cfg), "window must be at least 100ms")
+	require.EqualError(t, river.Unmarshal([]byte(`window = "3ns"`), &cfg), "window must be at least 100ms")
+	require.EqualError(t, river.Unmarshal([]byte(`count_label = "not-valid"`), &cfg), `count_label "not-valid" is not a valid label name`)
+}
+
+func TestDedupStage(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	stage := newDedupStage(util.TestFlowLogger(t), DefaultDedupConfig, reg)
+
+	out := processEntries(stage,
+		simpleEntry("connection refused", "one"),
+		simpleEntry("connection refused", "one"),
+		simpleEntry("connection refused", "two"),
+		simpleEntry("connection refused", "one"),
+		simpleEntry("connection refused", "one"),
+		simpleEntry("retrying", "one"),
+		simpleEntry("connection refused", "one"),
+	)
+
+	type line struct {
+		line     string
+		labels   model.LabelSet
+		metadata push.LabelsAdapter
+	}
+	var lines []line
+	for _, e := range out {
+		lines = append(lines, line{e.Line, e.Labels, e.StructuredMetadata})
+	}
+	require.Equal(t, []line{
+		{"connection refused", model.LabelSet{"value": "one"}, nil},
+		{"connection refused", model.LabelSet{"value": "two"}, nil},
+		// The suppressed lines are summarized when a different line is
+		// received, in the same stream.
+		{"connection refused", model.LabelSet{"value": "one"}, push.LabelsAdapter{{Name: "suppressed_count", Value: "3"}}},
+		{"retrying", model.LabelSet{"value": "one"}, nil},
+		{"connection refused", model.LabelSet{"value": "one"}, nil},
+	}, lines)
+
+	// Summaries stand for all but one of the suppressed lines.
+	require.Equal(t, 2.0, testutil.ToFloat64(getDropCountMetric(reg).WithLabelValues("dedup_stage")))
+}
+
+func TestDedupStageWindowExpiry(t *testing.T) {
+	cfg := DefaultDedupConfig
+	cfg.Window = 100 * time.Millisecond
+	stage := newDedupStage(util.TestFlowLogger(t), cfg, prometheus.NewRegistry())
+
+	in := make(chan Entry)
+	out := stage.Run(in)
+	defer close(in)
+
+	in <- simpleEntry("connection refused", "one")
+	require.Equal(t, "connection refused", (<-out).Line)
+	in <- simpleEntry("connection refused", "one")
+	last := simpleEntry("connection refused", "one")
+	in <- last
+
+	// The last suppressed line is summarized once the window expires, without
+	// waiting for another line.
+	select {
+	case e := <-out:
+		require.Equal(t, last.Timestamp, e.Timestamp)
+		require.Equal(t, model.LabelSet{"value": "one"}, e.Labels)
+		require.Equal(t, push.LabelsAdapter{{Name: "suppressed_count", Value: "2"}}, e.StructuredMetadata)
+	case <-time.After(time.Second):
+		require.FailNow(t, "the suppressed lines were never summarized")
+	}
+
+	// Identical lines start a new window once the previous one expired.
+	in <- simpleEntry("connection refused", "one")
+	require.Equal(t, model.LabelSet{"value": "one"}, (<-out).Labels)
+}
@@ -1,9 +1,21 @@
 package stages
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/grafana/loki/pkg/push"
+	util_log "github.com/grafana/loki/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 )
 
@@ -81,3 +93,270 @@ func Test_ValidateConfigs(t *testing.T) {
 		}
 	}
 }
+
+func TestGeoIPStageCity(t *testing.T) {
+	db := writeTestMMDB(t, "GeoIP2-City", map[string]any{
+		"34.120.177.0/24": map[string]any{
+			"city":      map[string]any{"names": map[string]any{"en": "Kansas City"}},
+			"continent": map[string]any{"code": "NA", "names": map[string]any{"en": "North America"}},
+			"country":   map[string]any{"iso_code": "US", "names": map[string]any{"en": "United States"}},
+			"location": map[string]any{
+				"latitude":  39.1027,
+				"longitude": -94.5778,
+				"time_zone": "America/Chicago",
+			},
+			"postal": map[string]any{"code": "64184"},
+			"subdivisions": []any{
+				map[string]any{"iso_code": "MO", "names": map[string]any{"en": "Missouri"}},
+			},
+		},
+	})
+
+	pl, err := NewPipeline(util_log.Logger, loadConfig(fmt.Sprintf(`
+stage.json {
+	expressions = {ip = "client_ip"}
+}
+stage.geoip {
+	source  = "ip"
+	db      = %q
+	db_type = "city"
+}
+stage.labels {
+	values = {
+		geoip_country_name = "",
+		geoip_city_name    = "",
+	}
+}
+stage.structured_metadata {
+	values = {
+		geoip_location_latitude  = "",
+		geoip_location_longitude = "",
+		geoip_timezone           = "",
+	}
+}`, db)), &plName, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	out := processEntries(pl,
+		newEntry(nil, nil, `{"client_ip": "34.120.177.193"}`, time.Now()),
+		// Private and unknown addresses aren't in the database.
+		newEntry(nil, nil, `{"client_ip": "10.0.0.1"}`, time.Now()),
+		newEntry(nil, nil, `{"client_ip": "8.8.8.8"}`, time.Now()),
+		// Lines without a valid address are forwarded as is.
+		newEntry(nil, nil, `{"client_ip": "-"}`, time.Now()),
+		newEntry(nil, nil, `{"msg": "no address"}`, time.Now()),
+	)
+	require.Len(t, out, 5)
+
+	require.Equal(t, model.LabelSet{
+		"geoip_country_name": "United States",
+		"geoip_city_name":    "Kansas City",
+	}, out[0].Labels)
+	require.ElementsMatch(t, push.LabelsAdapter{
+		{Name: "geoip_location_latitude", Value: "39.1027"},
+		{Name: "geoip_location_longitude", Value: "-94.5778"},
+		{Name: "geoip_timezone", Value: "America/Chicago"},
+	}, out[0].StructuredMetadata)
+	require.Equal(t, "Missouri", out[0].Extracted["geoip_subdivision_name"])
+	require.Equal(t, "MO", out[0].Extracted["geoip_subdivision_code"])
+	require.Equal(t, "64184", out[0].Extracted["geoip_postal_code"])
+	require.Equal(t, "NA", out[0].Extracted["geoip_continent_code"])
+
+	for _, e := range out[1:] {
+		require.Empty(t, e.Labels, e.Line)
+		require.Empty(t, e.StructuredMetadata, e.Line)
+	}
+}
+
+func TestGeoIPStageASN(t *testing.T) {
+	db := writeTestMMDB(t, "GeoLite2-ASN", map[string]any{
+		"34.120.0.0/14": map[string]any{
+			"autonomous_system_number":       uint32(396982),
+			"autonomous_system_organization": "GOOGLE-CLOUD-PLATFORM",
+		},
+	})
+
+	source := "ip"
+	stage, err := newGeoIPStage(util_log.Logger, GeoIPConfig{DB: db, Source: &source, DBType: "asn"})
+	require.NoError(t, err)
+
+	out := processEntries(stage,
+		newEntry(map[string]interface{}{"ip": "34.120.177.193"}, nil, "", time.Now()),
+		newEntry(map[string]interface{}{"ip": "192.168.1.1"}, nil, "", time.Now()),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, uint(396982), out[0].Extracted["geoip_autonomous_system_number"])
+	require.Equal(t, "GOOGLE-CLOUD-PLATFORM", out[0].Extracted["geoip_autonomous_system_organization"])
+	require.Equal(t, map[string]interface{}{"ip": "192.168.1.1"}, out[1].Extracted)
+}
+
+func TestGeoIPStageCustomLookups(t *testing.T) {
+	db := writeTestMMDB(t, "GeoIP2-City", map[string]any{
+		"34.120.177.0/24": map[string]any{
+			"country": map[string]any{"iso_code": "US"},
+		},
+	})
+
+	source := "ip"
+	stage, err := newGeoIPStage(util_log.Logger, GeoIPConfig{
+		DB:            db,
+		Source:        &source,
+		CustomLookups: map[string]string{"country_code": "country.iso_code"},
+	})
+	require.NoError(t, err)
+
+	out := processEntries(stage,
+		newEntry(map[string]interface{}{"ip": "34.120.177.193"}, nil, "", time.Now()),
+		newEntry(map[string]interface{}{"ip": "10.0.0.1"}, nil, "", time.Now()),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, "US", out[0].Extracted["country_code"])
+	require.NotContains(t, out[1].Extracted, "country_code")
+}
+
+// writeTestMMDB writes an IPv4 MaxMind database holding records for the
+// given networks, and returns the path to the database.
+func writeTestMMDB(t *testing.T, dbType string, records map[string]any) string {
+	t.Helper()
+
+	type node struct{ children [2]any } // *node, data offset (int) or nil.
+	var (
+		root = &node{}
+		data []byte
+	)
+	for cidr, record := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		ones, _ := network.Mask.Size()
+		require.Positive(t, ones)
+
+		offset := len(data)
+		data = append(data, encodeMMDB(t, record)...)
+
+		ip, n := network.IP.To4(), root
+		for i := 0; i < ones; i++ {
+			bit := (ip[i/8] >> (7 - i%8)) & 1
+			if i == ones-1 {
+				n.children[bit] = offset
+				break
+			}
+			if n.children[bit] == nil {
+				n.children[bit] = &node{}
+			}
+			n = n.children[bit].(*node)
+		}
+	}
+
+	// Number the nodes of the tree breadth first, the root being node 0.
+	nodes := []*node{root}
+	for i := 0; i < len(nodes); i++ {
+		for _, c := range nodes[i].children {
+			if c, ok := c.(*node); ok {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	ids := make(map[*node]int, len(nodes))
+	for i, n := range nodes {
+		ids[n] = i
+	}
+
+	// Records are 24 bits long. They point to another node, to the data
+	// section, or are equal to the number of nodes when there's no data.
+	var db []byte
+	for _, n := range nodes {
+		for _, c := range n.children {
+			value := len(nodes)
+			switch c := c.(type) {
+			case *node:
+				value = ids[c]
+			case int:
+				value = len(nodes) + 16 + c
+			}
+			db = append(db, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+	db = append(db, make([]byte, 16)...)
+	db = append(db, data...)
+	db = append(db, "\xab\xcd\xefMaxMind.com"...)
+	db = append(db, encodeMMDB(t, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               dbType,
+		"description":                 map[string]any{"en": "Test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+	})...)
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	require.NoError(t, os.WriteFile(path, db, 0644))
+	return path
+}
+
+// encodeMMDB encodes v in the MaxMind DB data section format.
+func encodeMMDB(t *testing.T, v any) []byte {
+	t.Helper()
+
+	control := func(typ, size int) []byte {
+		var b []byte
+		if typ <= 7 {
+			b = []byte{byte(typ << 5)}
+		} else {
+			b = []byte{0, byte(typ - 7)}
+		}
+		switch {
+		case size < 29:
+			b[0] |= byte(size)
+		case size < 29+256:
+			b[0] |= 29
+			b = append(b, byte(size-29))
+		default:
+			require.FailNow(t, "unsupported size", "%d", size)
+		}
+		return b
+	}
+	encodeUint := func(typ int, v uint64) []byte {
+		var b []byte
+		for ; v > 0; v >>= 8 {
+			b = append([]byte{byte(v)}, b...)
+		}
+		return append(control(typ, len(b)), b...)
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(control(2, len(v)), v...)
+	case float64:
+		b := control(3, 8)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case uint16:
+		return encodeUint(5, uint64(v))
+	case uint32:
+		return encodeUint(6, uint64(v))
+	case uint64:
+		return encodeUint(9, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b := control(7, len(v))
+		for _, k := range keys {
+			b = append(b, encodeMMDB(t, k)...)
+			b = append(b, encodeMMDB(t, v[k])...)
+		}
+		return b
+	case []any:
+		b := control(11, len(v))
+		for _, e := range v {
+			b = append(b, encodeMMDB(t, e)...)
+		}
+		return b
+	default:
+		require.FailNow(t, "unsupported type", "%T", v)
+		return nil
+	}
+}
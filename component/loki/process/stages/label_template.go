@@ -0,0 +1,131 @@
+package stages
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"text/template"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// Config Errors.
+var (
+	ErrLabelTemplateLabelRequired    = errors.New("label_template stage requires a label")
+	ErrLabelTemplateTemplateRequired = errors.New("label_template stage requires a template")
+)
+
+const defaultLabelTemplateDropReason = "label_template_error"
+
+// LabelTemplateConfig configures a label_template stage.
+type LabelTemplateConfig struct {
+	Label      string `river:"label,attr"`
+	Template   string `river:"template,attr"`
+	DropReason string `river:"drop_counter_reason,attr,optional"`
+}
+
+// validateLabelTemplateConfig validates the config for a labelTemplateStage
+// and returns the parsed template.
+func validateLabelTemplateConfig(cfg *LabelTemplateConfig) (*template.Template, error) {
+	if cfg.Label == "" {
+		return nil, ErrLabelTemplateLabelRequired
+	}
+	if !model.LabelName(cfg.Label).IsValid() {
+		return nil, fmt.Errorf(ErrInvalidLabelName, cfg.Label)
+	}
+	if cfg.Template == "" {
+		return nil, ErrLabelTemplateTemplateRequired
+	}
+	if cfg.DropReason == "" {
+		cfg.DropReason = defaultLabelTemplateDropReason
+	}
+
+	// Extracted values are passed to the template as a map of strings, so
+	// missingkey=zero renders missing values as an empty string.
+	return template.New("label_template").Option("missingkey=zero").Funcs(functionMap).Parse(cfg.Template)
+}
+
+// newLabelTemplateStage creates a new labelTemplateStage.
+func newLabelTemplateStage(logger log.Logger, cfg LabelTemplateConfig, registerer prometheus.Registerer) (Stage, error) {
+	t, err := validateLabelTemplateConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &labelTemplateStage{
+		cfg:       cfg,
+		logger:    logger,
+		template:  t,
+		dropCount: getDropCountMetric(registerer),
+	}, nil
+}
+
+// labelTemplateStage sets a label to the result of executing a template
+// against the extracted map. Entries for which the template fails to execute
+// are dropped.
+type labelTemplateStage struct {
+	cfg       LabelTemplateConfig
+	logger    log.Logger
+	template  *template.Template
+	dropCount *prometheus.CounterVec
+}
+
+// Run implements Stage.
+func (s *labelTemplateStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			if err := s.process(&e); err != nil {
+				if Debug {
+					level.Debug(s.logger).Log("msg", "failed to execute label template, dropping entry", "label", s.cfg.Label, "err", err)
+				}
+				s.dropCount.WithLabelValues(s.cfg.DropReason).Inc()
+				continue
+			}
+			out <- e
+		}
+	}()
+	return out
+}
+
+func (s *labelTemplateStage) process(e *Entry) error {
+	td := make(map[string]string, len(e.Extracted)+1)
+	for k, v := range e.Extracted {
+		str, err := getString(v)
+		if err != nil {
+			if Debug {
+				level.Debug(s.logger).Log("msg", "extracted value could not be converted to a string", "err", err, "type", reflect.TypeOf(v))
+			}
+			continue
+		}
+		td[k] = str
+	}
+	td["Entry"] = e.Line
+
+	var buf bytes.Buffer
+	if err := s.template.Execute(&buf, td); err != nil {
+		return err
+	}
+
+	value := model.LabelValue(buf.String())
+	if value == "" {
+		// Labels with empty values are equivalent to unset labels.
+		delete(e.Labels, model.LabelName(s.cfg.Label))
+		return nil
+	}
+	if !value.IsValid() {
+		return fmt.Errorf("label value %q is not valid UTF-8", value)
+	}
+	e.Labels[model.LabelName(s.cfg.Label)] = value
+	return nil
+}
+
+// Name implements Stage.
+func (s *labelTemplateStage) Name() string {
+	return StageTypeLabelTemplate
+}
@@ -0,0 +1,120 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	util_log "github.com/grafana/loki/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testLabelTemplateRiver = `
+stage.regex {
+	expression = "^(?P<method>\\S+) (?P<path>\\S+)( (?P<status>\\d+))?"
+}
+stage.label_template {
+	label    = "route"
+	template = "{{ .method | ToLower }}:{{ .path }}:{{ .status }}"
+}
+`
+
+func TestPipeline_LabelTemplate(t *testing.T) {
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testLabelTemplateRiver), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		line   string
+		expect model.LabelSet
+	}{
+		{
+			name:   "all captures present",
+			line:   "GET /api/v1/push 200",
+			expect: model.LabelSet{"route": "get:/api/v1/push:200"},
+		},
+		{
+			name:   "missing capture renders empty",
+			line:   "POST /ready",
+			expect: model.LabelSet{"route": "post:/ready:"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			out := processEntries(pl, newEntry(nil, nil, tc.line, time.Now()))
+			require.Len(t, out, 1)
+			require.Equal(t, tc.expect, out[0].Labels)
+		})
+	}
+}
+
+func TestLabelTemplate_MissingValues(t *testing.T) {
+	s, err := newLabelTemplateStage(util_log.Logger, LabelTemplateConfig{
+		Label:    "composed",
+		Template: "{{ .first }}{{ .second }}",
+	}, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	// Fields missing from the extracted map render as empty strings. If the
+	// whole label is empty, it's removed.
+	out := processEntries(s, newEntry(nil, model.LabelSet{"composed": "old", "foo": "bar"}, "line", time.Now()))
+	require.Len(t, out, 1)
+	require.Equal(t, model.LabelSet{"foo": "bar"}, out[0].Labels)
+}
+
+func TestLabelTemplate_ErrorDropsEntry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, err := newLabelTemplateStage(util_log.Logger, LabelTemplateConfig{
+		Label:    "composed",
+		Template: `{{ fail "bad entry" }}`,
+	}, reg)
+	require.NoError(t, err)
+
+	out := processEntries(s,
+		newEntry(nil, nil, "first", time.Now()),
+		newEntry(nil, nil, "second", time.Now()),
+	)
+	require.Empty(t, out)
+
+	dropCount := getDropCountMetric(reg)
+	require.Equal(t, 2.0, testutil.ToFloat64(dropCount.WithLabelValues(defaultLabelTemplateDropReason)))
+}
+
+func TestLabelTemplate_Validation(t *testing.T) {
+	tt := []struct {
+		name   string
+		cfg    LabelTemplateConfig
+		errMsg string
+	}{
+		{
+			name:   "missing label",
+			cfg:    LabelTemplateConfig{Template: "{{ .foo }}"},
+			errMsg: ErrLabelTemplateLabelRequired.Error(),
+		},
+		{
+			name:   "invalid label",
+			cfg:    LabelTemplateConfig{Label: "not-valid", Template: "{{ .foo }}"},
+			errMsg: "invalid label name",
+		},
+		{
+			name:   "missing template",
+			cfg:    LabelTemplateConfig{Label: "foo"},
+			errMsg: ErrLabelTemplateTemplateRequired.Error(),
+		},
+		{
+			name:   "unparseable template",
+			cfg:    LabelTemplateConfig{Label: "foo", Template: "{{ .foo "},
+			errMsg: "unclosed action",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validateLabelTemplateConfig(&tc.cfg)
+			require.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
@@ -17,17 +17,21 @@ import (
 
 // Configuration errors.
 var (
-	ErrMultilineStageEmptyConfig        = errors.New("multiline stage config must define `firstline` regular expression")
-	ErrMultilineStageInvalidRegex       = errors.New("multiline stage first line regex compilation error")
-	ErrMultilineStageInvalidMaxWaitTime = errors.New("multiline stage `max_wait_time` parse error")
+	ErrMultilineStageEmptyConfig         = errors.New("multiline stage config must define `firstline` or `continuation` regular expression")
+	ErrMultilineStageConflictingConfig   = errors.New("multiline stage config can only define one of `firstline` or `continuation`")
+	ErrMultilineStageInvalidRegex        = errors.New("multiline stage first line regex compilation error")
+	ErrMultilineStageInvalidContinuation = errors.New("multiline stage continuation regex compilation error")
+	ErrMultilineStageInvalidMaxWaitTime  = errors.New("multiline stage `max_wait_time` parse error")
 )
 
 // MultilineConfig contains the configuration for a Multiline stage.
 type MultilineConfig struct {
-	Expression  string        `river:"firstline,attr"`
-	MaxLines    uint64        `river:"max_lines,attr,optional"`
-	MaxWaitTime time.Duration `river:"max_wait_time,attr,optional"`
-	regex       *regexp.Regexp
+	Expression   string        `river:"firstline,attr,optional"`
+	Continuation string        `river:"continuation,attr,optional"`
+	MaxLines     uint64        `river:"max_lines,attr,optional"`
+	MaxWaitTime  time.Duration `river:"max_wait_time,attr,optional"`
+	regex        *regexp.Regexp
+	continuation *regexp.Regexp
 }
 
 // DefaultMultilineConfig applies the default values on
@@ -51,15 +55,28 @@ func (args *MultilineConfig) Validate() error {
 }
 
 func validateMultilineConfig(cfg *MultilineConfig) error {
-	if cfg.Expression == "" {
+	switch {
+	case cfg.Expression == "" && cfg.Continuation == "":
 		return ErrMultilineStageEmptyConfig
+	case cfg.Expression != "" && cfg.Continuation != "":
+		return ErrMultilineStageConflictingConfig
 	}
 
-	expr, err := regexp.Compile(cfg.Expression)
-	if err != nil {
-		return fmt.Errorf("%v: %w", ErrMultilineStageInvalidRegex, err)
+	if cfg.Expression != "" {
+		expr, err := regexp.Compile(cfg.Expression)
+		if err != nil {
+			return fmt.Errorf("%v: %w", ErrMultilineStageInvalidRegex, err)
+		}
+		cfg.regex = expr
+	}
+
+	if cfg.Continuation != "" {
+		expr, err := regexp.Compile(cfg.Continuation)
+		if err != nil {
+			return fmt.Errorf("%v: %w", ErrMultilineStageInvalidContinuation, err)
+		}
+		cfg.continuation = expr
 	}
-	cfg.regex = expr
 
 	return nil
 }
@@ -103,7 +120,7 @@ func (m *multilineStage) Run(in chan Entry) chan Entry {
 			s, ok := streams[key]
 			if !ok {
 				// Pass through entries until we hit first start line.
-				if !m.cfg.regex.MatchString(e.Line) {
+				if !m.isStartLine(e.Line) {
 					level.Debug(m.logger).Log("msg", "pass through entry", "stream", key)
 					out <- e
 					continue
@@ -151,7 +168,7 @@ func (m *multilineStage) runMultiline(in chan Entry, out chan Entry, wg *sync.Wa
 				return
 			}
 
-			isFirstLine := m.cfg.regex.MatchString(e.Line)
+			isFirstLine := m.isStartLine(e.Line)
 			if isFirstLine {
 				level.Debug(m.logger).Log("msg", "flush multiline block because new start line", "block", state.buffer.String(), "stream", e.Labels.FastFingerprint())
 				m.flush(out, state)
@@ -159,6 +176,11 @@ func (m *multilineStage) runMultiline(in chan Entry, out chan Entry, wg *sync.Wa
 				// The start line entry is used to set timestamp and labels in the flush method.
 				// The timestamps for following lines are ignored for now.
 				state.startLineEntry = e
+			} else if state.buffer.Len() == 0 {
+				// The previous block was already flushed (e.g., because max_wait_time
+				// elapsed), so this line starts a new block on its own. Use its own
+				// entry rather than the stale start line of the flushed block.
+				state.startLineEntry = e
 			}
 
 			// Append block line
@@ -175,6 +197,14 @@ func (m *multilineStage) runMultiline(in chan Entry, out chan Entry, wg *sync.Wa
 	}
 }
 
+// isStartLine returns true if line starts a new multiline block.
+func (m *multilineStage) isStartLine(line string) bool {
+	if m.cfg.continuation != nil {
+		return !m.cfg.continuation.MatchString(line)
+	}
+	return m.cfg.regex.MatchString(line)
+}
+
 func (m *multilineStage) flush(out chan Entry, s *multilineState) {
 	if s.buffer.Len() == 0 {
 		level.Debug(m.logger).Log("msg", "nothing to flush", "buffer_len", s.buffer.Len())
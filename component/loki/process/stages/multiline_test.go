@@ -122,6 +122,48 @@ func TestMultilineStageMaxWaitTime(t *testing.T) {
 	require.Eventually(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(res) == 2 }, 2*time.Second, 200*time.Millisecond)
 	require.Equal(t, "START line", res[0].Line)
 	require.Equal(t, "not a start line hitting timeout", res[1].Line)
+
+	// Lines arriving after a block was flushed by the timeout must not reuse
+	// the timestamp of the flushed start line.
+	require.True(t, res[1].Timestamp.After(res[0].Timestamp))
+}
+
+func TestMultilineStageContinuation(t *testing.T) {
+	logger := util.TestFlowLogger(t)
+	mcfg := MultilineConfig{Continuation: `^\s+at `, MaxWaitTime: 3 * time.Second, MaxLines: 128}
+	err := validateMultilineConfig(&mcfg)
+	require.NoError(t, err)
+
+	stage := &multilineStage{
+		cfg:    mcfg,
+		logger: logger,
+	}
+
+	out := processEntries(stage,
+		simpleEntry("  at orphaned continuation", "label"),
+		simpleEntry("java.lang.RuntimeException: one", "label"),
+		simpleEntry("  at com.example.Foo.bar(Foo.java:10)", "label"),
+		simpleEntry("  at com.example.Foo.main(Foo.java:5)", "label"),
+		simpleEntry("regular line", "label"),
+		simpleEntry("java.lang.RuntimeException: two", "label"),
+		simpleEntry("  at com.example.Foo.baz(Foo.java:20)", "label"))
+
+	require.Len(t, out, 4)
+	require.Equal(t, "  at orphaned continuation", out[0].Line)
+	require.Equal(t, "java.lang.RuntimeException: one\n  at com.example.Foo.bar(Foo.java:10)\n  at com.example.Foo.main(Foo.java:5)", out[1].Line)
+	require.Equal(t, "regular line", out[2].Line)
+	require.Equal(t, "java.lang.RuntimeException: two\n  at com.example.Foo.baz(Foo.java:20)", out[3].Line)
+}
+
+func TestMultilineStageConfigValidation(t *testing.T) {
+	err := validateMultilineConfig(&MultilineConfig{})
+	require.ErrorIs(t, err, ErrMultilineStageEmptyConfig)
+
+	err = validateMultilineConfig(&MultilineConfig{Expression: "^START", Continuation: "^\\s"})
+	require.ErrorIs(t, err, ErrMultilineStageConflictingConfig)
+
+	err = validateMultilineConfig(&MultilineConfig{Continuation: "("})
+	require.ErrorContains(t, err, ErrMultilineStageInvalidContinuation.Error())
 }
 
 func simpleEntry(line, label string) Entry {
@@ -18,6 +18,7 @@ type StageConfig struct {
 	//TODO(thampiotr): sync these with new stages
 	CRIConfig             *CRIConfig             `river:"cri,block,optional"`
 	DecolorizeConfig      *DecolorizeConfig      `river:"decolorize,block,optional"`
+	DedupConfig           *DedupConfig           `river:"dedup,block,optional"`
 	DockerConfig          *DockerConfig          `river:"docker,block,optional"`
 	DropConfig            *DropConfig            `river:"drop,block,optional"`
 	EventLogMessageConfig *EventLogMessageConfig `river:"eventlogmessage,block,optional"`
@@ -25,6 +26,7 @@ type StageConfig struct {
 	JSONConfig            *JSONConfig            `river:"json,block,optional"`
 	LabelAllowConfig      *LabelAllowConfig      `river:"label_keep,block,optional"`
 	LabelDropConfig       *LabelDropConfig       `river:"label_drop,block,optional"`
+	LabelTemplateConfig   *LabelTemplateConfig   `river:"label_template,block,optional"`
 	LabelsConfig          *LabelsConfig          `river:"labels,block,optional"`
 	LimitConfig           *LimitConfig           `river:"limit,block,optional"`
 	LogfmtConfig          *LogfmtConfig          `river:"logfmt,block,optional"`
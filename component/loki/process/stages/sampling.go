@@ -1,18 +1,30 @@
 package stages
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/rand"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/go-kit/log"
+	"github.com/grafana/loki/clients/pkg/logentry/logql"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/uber/jaeger-client-go/utils"
 )
 
 const (
-	ErrSamplingStageInvalidRate = "sampling stage failed to parse rate,Sampling Rate must be between 0.0 and 1.0, received %f"
+	ErrSamplingStageInvalidRate   = "sampling stage failed to parse rate,Sampling Rate must be between 0.0 and 1.0, received %f"
+	ErrSamplingStageInvalidMethod = "sampling stage method must be %q or %q, received %q"
+)
+
+// Sampling methods.
+const (
+	SamplingMethodRandom = "random"
+	SamplingMethodHash   = "hash"
 )
 const maxRandomNumber = ^(uint64(1) << 63) // i.e. 0x7fffffffffffffff
 
@@ -24,44 +36,101 @@ var (
 type SamplingConfig struct {
 	DropReason   *string `river:"drop_counter_reason,attr,optional"`
 	SamplingRate float64 `river:"rate,attr"`
+	Method       string  `river:"method,attr,optional"`
+	KeepSelector string  `river:"keep_selector,attr,optional"`
 }
 
 func (s *SamplingConfig) SetToDefault() {
 	if s.DropReason == nil || *s.DropReason == "" {
 		s.DropReason = &defaultSamplingpReason
 	}
+	if s.Method == "" {
+		s.Method = SamplingMethodRandom
+	}
 }
 
 func (s *SamplingConfig) Validate() error {
 	if s.SamplingRate < 0.0 || s.SamplingRate > 1.0 {
 		return fmt.Errorf(ErrSamplingStageInvalidRate, s.SamplingRate)
 	}
+	if s.Method != SamplingMethodRandom && s.Method != SamplingMethodHash {
+		return fmt.Errorf(ErrSamplingStageInvalidMethod, SamplingMethodRandom, SamplingMethodHash, s.Method)
+	}
+	if s.KeepSelector != "" {
+		if _, err := logql.ParseExpr(s.KeepSelector); err != nil {
+			return fmt.Errorf("invalid keep_selector: %w", err)
+		}
+	}
 	return nil
 }
 
 // newSamplingStage creates a SamplingStage from config
 // code from jaeger project.
 // github.com/uber/jaeger-client-go@v2.30.0+incompatible/tracer.go:126
-func newSamplingStage(logger log.Logger, cfg SamplingConfig, registerer prometheus.Registerer) Stage {
+func newSamplingStage(logger log.Logger, cfg SamplingConfig, registerer prometheus.Registerer) (Stage, error) {
 	samplingRate := math.Max(0.0, math.Min(cfg.SamplingRate, 1.0))
 	samplingBoundary := uint64(float64(maxRandomNumber) * samplingRate)
 	seedGenerator := utils.NewRand(time.Now().UnixNano())
 	source := rand.NewSource(seedGenerator.Int63())
+
+	var (
+		keepMatchers []*labels.Matcher
+		keepFilter   logql.Filter
+	)
+	if cfg.KeepSelector != "" {
+		selector, err := logql.ParseExpr(cfg.KeepSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep_selector: %w", err)
+		}
+		keepFilter, err = selector.Filter()
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep_selector: %w", err)
+		}
+		keepMatchers = selector.Matchers()
+	}
+
 	return &samplingStage{
 		logger:           log.With(logger, "component", "stage", "type", "sampling"),
 		cfg:              cfg,
 		dropCount:        getDropCountMetric(registerer),
+		sampledCount:     getSampledCountMetric(registerer),
 		samplingBoundary: samplingBoundary,
 		source:           source,
+		keepMatchers:     keepMatchers,
+		keepFilter:       keepFilter,
+	}, nil
+}
+
+// getSampledCountMetric returns the counter of the lines kept by sampling
+// stages, shared by all the sampling stages of the registerer.
+func getSampledCountMetric(registerer prometheus.Registerer) prometheus.Counter {
+	sampledCount := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_process_sampled_lines_total",
+		Help: "A count of all log lines kept by sampling stages",
+	})
+	err := registerer.Register(sampledCount)
+	if err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			sampledCount = existing.ExistingCollector.(prometheus.Counter)
+		} else {
+			// Same behavior as MustRegister if the error is not for AlreadyRegistered
+			panic(err)
+		}
 	}
+	return sampledCount
 }
 
 type samplingStage struct {
 	logger           log.Logger
 	cfg              SamplingConfig
 	dropCount        *prometheus.CounterVec
+	sampledCount     prometheus.Counter
 	samplingBoundary uint64
 	source           rand.Source
+
+	// Lines matching keepMatchers and keepFilter bypass sampling.
+	keepMatchers []*labels.Matcher
+	keepFilter   logql.Filter
 }
 
 func (m *samplingStage) Run(in chan Entry) chan Entry {
@@ -69,7 +138,12 @@ func (m *samplingStage) Run(in chan Entry) chan Entry {
 	go func() {
 		defer close(out)
 		for e := range in {
-			if m.isSampled() {
+			if m.alwaysKeep(e) {
+				out <- e
+				continue
+			}
+			if m.isSampled(e) {
+				m.sampledCount.Inc()
 				out <- e
 				continue
 			}
@@ -79,12 +153,40 @@ func (m *samplingStage) Run(in chan Entry) chan Entry {
 	return out
 }
 
+// alwaysKeep returns true if e matches the keep selector.
+func (m *samplingStage) alwaysKeep(e Entry) bool {
+	if m.cfg.KeepSelector == "" {
+		return false
+	}
+	for _, matcher := range m.keepMatchers {
+		if !matcher.Matches(string(e.Labels[model.LabelName(matcher.Name)])) {
+			return false
+		}
+	}
+	return m.keepFilter == nil || m.keepFilter([]byte(e.Line))
+}
+
 // code from jaeger project.
 // github.com/uber/jaeger-client-go@v2.30.0+incompatible/sampler.go:144
 // func (s *ProbabilisticSampler) IsSampled(id TraceID, operation string) (bool, []Tag)
-func (m *samplingStage) isSampled() bool {
+func (m *samplingStage) isSampled(e Entry) bool {
+	if m.cfg.Method == SamplingMethodHash {
+		return m.samplingBoundary >= hashEntry(e)&maxRandomNumber
+	}
 	return m.samplingBoundary >= m.randomID()&maxRandomNumber
 }
+
+// hashEntry returns a hash of the stream and the line of e, so that identical
+// lines of a stream are either all kept or all dropped, whichever agent
+// samples them.
+func hashEntry(e Entry) uint64 {
+	h := xxhash.New()
+	var fp [8]byte
+	binary.LittleEndian.PutUint64(fp[:], uint64(e.Labels.Fingerprint()))
+	_, _ = h.Write(fp[:])
+	_, _ = h.Write([]byte(e.Line))
+	return h.Sum64()
+}
 func (m *samplingStage) randomID() uint64 {
 	val := m.randomNumber()
 	for val == 0 {
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -37,6 +39,88 @@ func TestSamplingPipeline(t *testing.T) {
 	assert.LessOrEqual(t, len(out), 70)
 }
 
+func TestSamplingFraction(t *testing.T) {
+	for _, method := range []string{SamplingMethodRandom, SamplingMethodHash} {
+		t.Run(method, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			pl, err := NewPipeline(util_log.Logger, loadConfig(fmt.Sprintf(`
+stage.sampling {
+  rate   = 0.25
+  method = %q
+}`, method)), &plName, registry)
+			require.NoError(t, err)
+
+			total := 10000
+			entries := make([]Entry, 0, total)
+			for i := 0; i < total; i++ {
+				entries = append(entries, newEntry(nil, model.LabelSet{"app": "loki"}, fmt.Sprintf("line %d", i), time.Now()))
+			}
+
+			out := processEntries(pl, entries...)
+			// The theoretical sample size is 2500.
+			assert.InDelta(t, 2500, len(out), 250)
+
+			sampled := testutil.ToFloat64(getSampledCountMetric(registry))
+			dropped := testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(defaultSamplingpReason))
+			require.Equal(t, float64(len(out)), sampled)
+			require.Equal(t, float64(total-len(out)), dropped)
+		})
+	}
+}
+
+func TestSamplingHashDeterministic(t *testing.T) {
+	cfg := SamplingConfig{SamplingRate: 0.5, Method: SamplingMethodHash}
+	cfg.SetToDefault()
+
+	var entries []Entry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, newEntry(nil, model.LabelSet{"app": "loki"}, fmt.Sprintf("line %d", i), time.Now()))
+	}
+
+	kept := func() []string {
+		stage, err := newSamplingStage(util_log.Logger, cfg, prometheus.NewRegistry())
+		require.NoError(t, err)
+		var lines []string
+		for _, e := range processEntries(stage, entries...) {
+			lines = append(lines, e.Line)
+		}
+		return lines
+	}
+
+	// Separate stages, as run by separate agents, keep the same lines.
+	first := kept()
+	require.NotEmpty(t, first)
+	require.Less(t, len(first), len(entries))
+	require.Equal(t, first, kept())
+}
+
+func TestSamplingKeepSelector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pl, err := NewPipeline(util_log.Logger, loadConfig(`
+stage.sampling {
+  rate          = 0
+  keep_selector = "{level=\"error\"} |= \"payment\""
+}`), &plName, registry)
+	require.NoError(t, err)
+
+	out := processEntries(pl,
+		newEntry(nil, model.LabelSet{"level": "error"}, "payment failed", time.Now()),
+		newEntry(nil, model.LabelSet{"level": "error"}, "cache miss", time.Now()),
+		newEntry(nil, model.LabelSet{"level": "info"}, "payment received", time.Now()),
+		newEntry(nil, model.LabelSet{"level": "error"}, "payment timed out", time.Now()),
+	)
+
+	var lines []string
+	for _, e := range out {
+		lines = append(lines, e.Line)
+	}
+	// Only the lines matching the selector bypass sampling.
+	require.Equal(t, []string{"payment failed", "payment timed out"}, lines)
+	// Lines which bypass sampling aren't counted as sampled.
+	require.Equal(t, 0.0, testutil.ToFloat64(getSampledCountMetric(registry)))
+	require.Equal(t, 2.0, testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(defaultSamplingpReason)))
+}
+
 func Test_validateSamplingConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -50,6 +134,23 @@ func Test_validateSamplingConfig(t *testing.T) {
 			},
 			wantErr: fmt.Errorf(ErrSamplingStageInvalidRate, 12.0),
 		},
+		{
+			name: "Invalid method",
+			config: &SamplingConfig{
+				SamplingRate: 0.5,
+				Method:       "roundrobin",
+			},
+			wantErr: fmt.Errorf(ErrSamplingStageInvalidMethod, SamplingMethodRandom, SamplingMethodHash, "roundrobin"),
+		},
+		{
+			name: "Invalid keep_selector",
+			config: &SamplingConfig{
+				SamplingRate: 0.5,
+				Method:       SamplingMethodRandom,
+				KeepSelector: "{level=}",
+			},
+			wantErr: fmt.Errorf("invalid keep_selector: parse error at line 1, col 8: syntax error: unexpected }, expecting STRING"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -17,6 +17,7 @@ import (
 const (
 	StageTypeCRI        = "cri"
 	StageTypeDecolorize = "decolorize"
+	StageTypeDedup      = "dedup"
 	StageTypeDocker     = "docker"
 	StageTypeDrop       = "drop"
 	//TODO(thampiotr): Add support for eventlogmessage stage
@@ -26,6 +27,7 @@ const (
 	StageTypeLabel              = "labels"
 	StageTypeLabelAllow         = "labelallow"
 	StageTypeLabelDrop          = "labeldrop"
+	StageTypeLabelTemplate      = "label_template"
 	StageTypeLimit              = "limit"
 	StageTypeLogfmt             = "logfmt"
 	StageTypeMatch              = "match"
@@ -157,7 +159,7 @@ func New(logger log.Logger, jobName *string, cfg StageConfig, registerer prometh
 			return nil, err
 		}
 	case cfg.TimestampConfig != nil:
-		s, err = newTimestampStage(logger, *cfg.TimestampConfig)
+		s, err = newTimestampStage(logger, *cfg.TimestampConfig, registerer)
 		if err != nil {
 			return nil, err
 		}
@@ -213,6 +215,11 @@ func New(logger log.Logger, jobName *string, cfg StageConfig, registerer prometh
 		if err != nil {
 			return nil, err
 		}
+	case cfg.LabelTemplateConfig != nil:
+		s, err = newLabelTemplateStage(logger, *cfg.LabelTemplateConfig, registerer)
+		if err != nil {
+			return nil, err
+		}
 	case cfg.StaticLabelsConfig != nil:
 		s, err = newStaticLabelsStage(logger, *cfg.StaticLabelsConfig)
 		if err != nil {
@@ -229,7 +236,12 @@ func New(logger log.Logger, jobName *string, cfg StageConfig, registerer prometh
 			return nil, err
 		}
 	case cfg.SamplingConfig != nil:
-		s = newSamplingStage(logger, *cfg.SamplingConfig, registerer)
+		s, err = newSamplingStage(logger, *cfg.SamplingConfig, registerer)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.DedupConfig != nil:
+		s = newDedupStage(logger, *cfg.DedupConfig, registerer)
 	case cfg.EventLogMessageConfig != nil:
 		s = newEventLogMessageStage(logger, cfg.EventLogMessageConfig)
 	default:
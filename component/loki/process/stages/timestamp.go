@@ -9,6 +9,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 
 	_ "time/tzdata" // embed timezone data
@@ -38,6 +39,13 @@ var (
 	maxLastKnownTimestampsCacheSize = 10000
 )
 
+// Values of the reason label of the timestamp failures metric.
+const (
+	timestampFailureSourceMissing    = "source_missing"
+	timestampFailureConversionFailed = "conversion_failed"
+	timestampFailureParsingFailed    = "parsing_failed"
+)
+
 // TimestampActionOnFailureOptions defines the available options for the
 // `action_on_failure` field.
 var TimestampActionOnFailureOptions = []string{TimestampActionOnFailureSkip, TimestampActionOnFailureFudge}
@@ -98,7 +106,7 @@ func validateTimestampConfig(cfg TimestampConfig) (parser, error) {
 }
 
 // newTimestampStage creates a new timestamp extraction pipeline stage.
-func newTimestampStage(logger log.Logger, config TimestampConfig) (Stage, error) {
+func newTimestampStage(logger log.Logger, config TimestampConfig, registerer prometheus.Registerer) (Stage, error) {
 	parser, err := validateTimestampConfig(config)
 	if err != nil {
 		return nil, err
@@ -117,9 +125,27 @@ func newTimestampStage(logger log.Logger, config TimestampConfig) (Stage, error)
 		logger:              logger,
 		parser:              parser,
 		lastKnownTimestamps: lastKnownTimestamps,
+		failures:            getTimestampFailuresMetric(registerer),
 	}), nil
 }
 
+func getTimestampFailuresMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	failures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_process_timestamp_failures_total",
+		Help: "A count of all log lines for which the timestamp stage failed to extract or parse a timestamp",
+	}, []string{"reason"})
+	err := registerer.Register(failures)
+	if err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			failures = existing.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			// Same behavior as MustRegister if the error is not for AlreadyRegistered
+			panic(err)
+		}
+	}
+	return failures
+}
+
 type timestampStage struct {
 	config *TimestampConfig
 	logger log.Logger
@@ -128,6 +154,8 @@ type timestampStage struct {
 	// Stores the last known timestamp for a given "stream id" (guessed, since at this stage
 	// there's no reliable way to know it).
 	lastKnownTimestamps *lru.Cache
+
+	failures *prometheus.CounterVec
 }
 
 // Name implements Stage.
@@ -143,6 +171,7 @@ func (ts *timestampStage) Process(labels model.LabelSet, extracted map[string]in
 
 	parsedTs, err := ts.parseTimestampFromSource(extracted)
 	if err != nil {
+		ts.failures.WithLabelValues(timestampFailureReason(err)).Inc()
 		ts.processActionOnFailure(labels, t)
 		return
 	}
@@ -183,6 +212,19 @@ func (ts *timestampStage) parseTimestampFromSource(extracted map[string]interfac
 	return &parsedTs, nil
 }
 
+// timestampFailureReason returns the reason label value for an error
+// returned by parseTimestampFromSource.
+func timestampFailureReason(err error) string {
+	switch err {
+	case ErrTimestampSourceMissing:
+		return timestampFailureSourceMissing
+	case ErrTimestampConversionFailed:
+		return timestampFailureConversionFailed
+	default:
+		return timestampFailureParsingFailed
+	}
+}
+
 func (ts *timestampStage) processActionOnFailure(labels model.LabelSet, t *time.Time) {
 	switch ts.config.ActionOnFailure {
 	case TimestampActionOnFailureFudge:
@@ -10,6 +10,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -288,7 +289,7 @@ func TestTimestampStage_Process(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			logger := util.TestFlowLogger(t)
-			st, err := newTimestampStage(logger, test.config)
+			st, err := newTimestampStage(logger, test.config, prometheus.NewRegistry())
 			require.NoError(t, err)
 
 			out := processEntries(st, newEntry(test.extracted, nil, "hello world", time.Now()))[0]
@@ -429,7 +430,7 @@ func TestTimestampStage_ProcessActionOnFailure(t *testing.T) {
 			require.Equal(t, len(testData.inputEntries), len(testData.expectedTimestamps))
 
 			logger := util.TestFlowLogger(t)
-			s, err := newTimestampStage(logger, testData.config)
+			s, err := newTimestampStage(logger, testData.config, prometheus.NewRegistry())
 			require.NoError(t, err)
 
 			for i, inputEntry := range testData.inputEntries {
@@ -439,3 +440,38 @@ func TestTimestampStage_ProcessActionOnFailure(t *testing.T) {
 		})
 	}
 }
+
+func TestTimestampStage_FailuresMetric(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, err := newTimestampStage(util.TestFlowLogger(t), TimestampConfig{
+		Source:          "time",
+		Format:          time.RFC3339,
+		ActionOnFailure: TimestampActionOnFailureSkip,
+	}, reg)
+	require.NoError(t, err)
+
+	ingested := time.Unix(1, 0)
+	out := processEntries(s,
+		newEntry(map[string]interface{}{"time": "2019-10-01T01:02:03Z"}, nil, "valid", ingested),
+		newEntry(map[string]interface{}{"time": "not a timestamp"}, nil, "malformed", ingested),
+		newEntry(map[string]interface{}{"time": []string{"unsupported"}}, nil, "unconvertible", ingested),
+		newEntry(map[string]interface{}{}, nil, "missing", ingested),
+	)
+
+	// Entries for which the timestamp couldn't be parsed are kept with their
+	// original timestamp.
+	require.Len(t, out, 4)
+	assert.Equal(t, mustParseTime(time.RFC3339, "2019-10-01T01:02:03Z"), out[0].Timestamp)
+	for _, e := range out[1:] {
+		assert.Equal(t, ingested, e.Timestamp, "entry: %s", e.Line)
+	}
+
+	expected := `
+# HELP loki_process_timestamp_failures_total A count of all log lines for which the timestamp stage failed to extract or parse a timestamp
+# TYPE loki_process_timestamp_failures_total counter
+loki_process_timestamp_failures_total{reason="conversion_failed"} 1
+loki_process_timestamp_failures_total{reason="parsing_failed"} 1
+loki_process_timestamp_failures_total{reason="source_missing"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "loki_process_timestamp_failures_total"))
+}
@@ -16,13 +16,15 @@ import (
 	fnet "github.com/grafana/agent/component/common/net"
 	flow_relabel "github.com/grafana/agent/component/common/relabel"
 	"github.com/grafana/agent/component/loki/source/aws_firehose/internal"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/util"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name: "loki.source.awsfirehose",
-		Args: Arguments{},
+		Name:      "loki.source.awsfirehose",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -2,12 +2,28 @@ package docker
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	flow_relabel "github.com/grafana/agent/component/common/relabel"
+	"github.com/grafana/agent/component/discovery"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,3 +49,299 @@ func Test(t *testing.T) {
 
 	require.NoError(t, ctrl.WaitRunning(time.Minute))
 }
+
+func TestContainerRestart(t *testing.T) {
+	oldInterval := containerRetryInterval
+	containerRetryInterval = 10 * time.Millisecond
+	defer func() { containerRetryInterval = oldInterval }()
+
+	daemon := newFakeDaemon("e2b3c4d5")
+	daemon.log(stdcopy.Stdout, "first")
+	daemon.log(stdcopy.Stderr, "second")
+
+	srv := httptest.NewServer(daemon)
+	defer srv.Close()
+
+	rules, err := unmarshalRelabelRules(`
+		rule {
+			source_labels = ["__meta_docker_container_name"]
+			regex         = "/(.*)"
+			target_label  = "container"
+		}
+		rule {
+			source_labels = ["__meta_docker_container_id"]
+			target_label  = "container_id"
+		}
+		rule {
+			source_labels = ["__meta_docker_container_log_stream"]
+			target_label  = "stream"
+		}
+	`)
+	require.NoError(t, err)
+
+	lr := loki.NewLogsReceiver()
+	args := GetDefaultArguments()
+	args.Host = srv.URL
+	args.Targets = []discovery.Target{{
+		"__meta_docker_container_id":   daemon.id,
+		"__meta_docker_container_name": "/app",
+	}}
+	args.ForwardTo = []loki.LogsReceiver{lr}
+	args.Labels = map[string]string{"job": "docker"}
+	args.RelabelRules = rules
+
+	c, err := New(component.Options{
+		ID:            "loki.source.docker.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		DataPath:      t.TempDir(),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	entries := receiveEntries(t, lr, 2)
+	labels := func(stream string) model.LabelSet {
+		return model.LabelSet{
+			"job":          "docker",
+			"container":    "app",
+			"container_id": model.LabelValue(daemon.id),
+			"stream":       model.LabelValue(stream),
+		}
+	}
+	require.ElementsMatch(t, []loki.Entry{
+		daemon.entry(0, labels("stdout")),
+		daemon.entry(1, labels("stderr")),
+	}, entries)
+
+	// Once the container is stopped, its logs are read again when it starts,
+	// without repeating the entries which were already read.
+	daemon.setRunning(false)
+	daemon.log(stdcopy.Stdout, "third")
+	daemon.setRunning(true)
+
+	entries = receiveEntries(t, lr, 1)
+	require.Equal(t, []loki.Entry{daemon.entry(2, labels("stdout"))}, entries)
+	// Reading resumes from the exact timestamp of the last entry.
+	require.Equal(t, "1672531201.000000500", daemon.lastSince())
+
+	// When the daemon closes the log stream of a running container, for
+	// example when rotating its log file, reading resumes.
+	daemon.log(stdcopy.Stderr, "fourth")
+	daemon.endStream()
+
+	entries = receiveEntries(t, lr, 1)
+	require.Equal(t, []loki.Entry{daemon.entry(3, labels("stderr"))}, entries)
+
+	select {
+	case e := <-lr.Chan():
+		require.FailNow(t, "received unexpected entry", e.Line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func unmarshalRelabelRules(in string) (flow_relabel.Rules, error) {
+	var cfg struct {
+		Rules []*flow_relabel.Config `river:"rule,block,optional"`
+	}
+	if err := river.Unmarshal([]byte(in), &cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Rules, nil
+}
+
+func receiveEntries(t *testing.T, lr loki.LogsReceiver, count int) []loki.Entry {
+	t.Helper()
+
+	var entries []loki.Entry
+	for len(entries) < count {
+		select {
+		case e := <-lr.Chan():
+			entries = append(entries, e)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for log entries")
+		}
+	}
+	return entries
+}
+
+// fakeDaemon implements the subset of the Docker Engine API used to read the
+// logs of a single container.
+type fakeDaemon struct {
+	id string
+
+	mut     sync.Mutex
+	running bool
+	lines   []fakeLine
+	since   string
+	// events receive the events of the container sent to the events streams
+	// which are currently open.
+	events []chan events.Message
+	// streamDone is closed to end the log streams which are currently open.
+	streamDone chan struct{}
+}
+
+type fakeLine struct {
+	stream stdcopy.StdType
+	ts     time.Time
+	line   string
+}
+
+func newFakeDaemon(id string) *fakeDaemon {
+	return &fakeDaemon{
+		id:         id,
+		running:    true,
+		streamDone: make(chan struct{}),
+	}
+}
+
+// log appends a line to the logs of the container. Lines are only sent to
+// log streams opened after the line is appended.
+func (d *fakeDaemon) log(stream stdcopy.StdType, line string) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.lines = append(d.lines, fakeLine{
+		stream: stream,
+		ts:     time.Date(2023, time.January, 1, 0, 0, len(d.lines), 500, time.UTC),
+		line:   line,
+	})
+}
+
+// entry returns the entry expected to be read for the i-th line.
+func (d *fakeDaemon) entry(i int, labels model.LabelSet) loki.Entry {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	e := loki.Entry{Labels: labels}
+	e.Line = d.lines[i].line
+	e.Timestamp = d.lines[i].ts
+	return e
+}
+
+// lastSince returns the since parameter of the last request for the logs of
+// the container.
+func (d *fakeDaemon) lastSince() string {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.since
+}
+
+// setRunning sets the state of the container. Stopping the container ends its
+// log streams, and starting it sends a start event.
+func (d *fakeDaemon) setRunning(running bool) {
+	d.mut.Lock()
+	d.running = running
+	subscribers := d.events
+	d.mut.Unlock()
+
+	if !running {
+		d.endStream()
+		return
+	}
+	for _, ch := range subscribers {
+		select {
+		case ch <- events.Message{
+			Type:   events.ContainerEventType,
+			Action: "start",
+			Actor:  events.Actor{ID: d.id},
+		}:
+		case <-time.After(time.Second):
+			// The events stream was closed.
+		}
+	}
+}
+
+func (d *fakeDaemon) endStream() {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	close(d.streamDone)
+	d.streamDone = make(chan struct{})
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mut.Lock()
+	running := d.running
+	lines := append([]fakeLine(nil), d.lines...)
+	streamDone := d.streamDone
+	if strings.HasSuffix(r.URL.Path, "/logs") {
+		d.since = r.URL.Query().Get("since")
+	}
+	d.mut.Unlock()
+
+	switch path := r.URL.Path; {
+	case strings.HasSuffix(path, "/_ping"):
+		w.Header().Set("API-Version", "1.41")
+		w.WriteHeader(http.StatusOK)
+
+	case strings.HasSuffix(path, fmt.Sprintf("/containers/%s/json", d.id)):
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:    d.id,
+				Name:  "/app",
+				State: &types.ContainerState{Running: running},
+			},
+			Config: &container.Config{Tty: false},
+		})
+
+	case strings.HasSuffix(path, fmt.Sprintf("/containers/%s/logs", d.id)):
+		// The since parameter is ignored on purpose, so that the tailer must
+		// skip the lines it already read.
+		w.WriteHeader(http.StatusOK)
+		for _, l := range lines {
+			fmt.Fprintf(stdcopy.NewStdWriter(w, l.stream), "%s %s\n", l.ts.Format(time.RFC3339Nano), l.line)
+		}
+		w.(http.Flusher).Flush()
+
+		if running {
+			select {
+			case <-streamDone:
+			case <-r.Context().Done():
+			}
+		}
+
+	case strings.HasSuffix(path, "/events"):
+		ch := make(chan events.Message)
+		d.mut.Lock()
+		d.events = append(d.events, ch)
+		d.mut.Unlock()
+		defer func() {
+			d.mut.Lock()
+			defer d.mut.Unlock()
+			for i, sub := range d.events {
+				if sub == ch {
+					d.events = append(d.events[:i], d.events[i+1:]...)
+					break
+				}
+			}
+		}()
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case e := <-ch:
+				_ = enc.Encode(e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
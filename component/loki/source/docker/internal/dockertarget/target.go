@@ -39,7 +39,7 @@ const (
 type Target struct {
 	logger        log.Logger
 	handler       loki.EntryHandler
-	since         int64
+	since         time.Time
 	positions     positions.Positions
 	containerName string
 	labels        model.LabelSet
@@ -51,7 +51,13 @@ type Target struct {
 	client  client.APIClient
 	wg      sync.WaitGroup
 	running *atomic.Bool
+	stopped chan struct{}
 	err     error
+
+	// Timestamp of the last entry read from the container, used to skip
+	// entries which were already read when the process loop restarts.
+	lastMut sync.Mutex
+	last    time.Time
 }
 
 // NewTarget starts a new target to read logs from a given container ID.
@@ -61,9 +67,16 @@ func NewTarget(metrics *Metrics, logger log.Logger, handler loki.EntryHandler, p
 	if err != nil {
 		return nil, err
 	}
-	var since int64
-	if pos != 0 {
-		since = pos
+	// Positions used to be saved as seconds, in which case the entries of the
+	// second they were saved at are read again. They're now saved as
+	// nanoseconds, and the entries up to the position are skipped.
+	var since, last time.Time
+	switch {
+	case pos > maxPositionSeconds:
+		since = time.Unix(0, pos)
+		last = since
+	case pos != 0:
+		since = time.Unix(pos, 0)
 	}
 
 	t := &Target{
@@ -79,6 +92,8 @@ func NewTarget(metrics *Metrics, logger log.Logger, handler loki.EntryHandler, p
 
 		client:  client,
 		running: atomic.NewBool(false),
+		stopped: make(chan struct{}, 1),
+		last:    last,
 	}
 
 	// NOTE (@tpaschalis) The original Promtail implementation would call
@@ -89,17 +104,31 @@ func NewTarget(metrics *Metrics, logger log.Logger, handler loki.EntryHandler, p
 
 func (t *Target) processLoop(ctx context.Context) {
 	t.running.Store(true)
-	defer t.running.Store(false)
+	defer func() {
+		t.running.Store(false)
+		select {
+		case t.stopped <- struct{}{}:
+		default:
+		}
+	}()
 
 	t.wg.Add(1)
 	defer t.wg.Done()
 
+	// When restarting after the log stream ended, resume from the last entry
+	// which was read.
+	since := t.since
+	resumeAfter := t.lastTimestamp()
+	if !resumeAfter.IsZero() {
+		since = resumeAfter
+	}
+
 	opts := docker_types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
 		Timestamps: true,
-		Since:      strconv.FormatInt(t.since, 10),
+		Since:      formatSince(since),
 	}
 	inspectInfo, err := t.client.ContainerInspect(ctx, t.containerName)
 	if err != nil {
@@ -141,8 +170,8 @@ func (t *Target) processLoop(ctx context.Context) {
 
 	// Start processing
 	t.wg.Add(2)
-	go t.process(rstdout, t.getStreamLabels("stdout"))
-	go t.process(rstderr, t.getStreamLabels("stderr"))
+	go t.process(rstdout, t.getStreamLabels("stdout"), resumeAfter)
+	go t.process(rstderr, t.getStreamLabels("stderr"), resumeAfter)
 
 	// Wait until done
 	<-ctx.Done()
@@ -180,7 +209,9 @@ func readLine(r *bufio.Reader) (string, error) {
 	return string(ln), err
 }
 
-func (t *Target) process(r io.Reader, logStreamLset model.LabelSet) {
+// process reads entries from r, skipping entries which aren't newer than
+// resumeAfter.
+func (t *Target) process(r io.Reader, logStreamLset model.LabelSet, resumeAfter time.Time) {
 	defer func() {
 		t.wg.Done()
 	}()
@@ -202,6 +233,9 @@ func (t *Target) process(r io.Reader, logStreamLset model.LabelSet) {
 			t.metrics.dockerErrors.Inc()
 			continue
 		}
+		if !ts.After(resumeAfter) {
+			continue
+		}
 
 		t.handler.Chan() <- loki.Entry{
 			Labels: logStreamLset,
@@ -211,6 +245,7 @@ func (t *Target) process(r io.Reader, logStreamLset model.LabelSet) {
 			},
 		}
 		t.metrics.dockerEntries.Inc()
+		t.updateLastTimestamp(ts)
 
 		// NOTE(@tpaschalis) We don't save the positions entry with the
 		// filtered labels, but with the default label set, as this is the one
@@ -218,7 +253,34 @@ func (t *Target) process(r io.Reader, logStreamLset model.LabelSet) {
 		// problematic if we have the same container with a different set of
 		// labels (e.g. duplicated and relabeled), but this shouldn't be the
 		// case anyway.
-		t.positions.Put(positions.CursorKey(t.containerName), t.labelsStr, ts.Unix())
+		t.positions.Put(positions.CursorKey(t.containerName), t.labelsStr, ts.UnixNano())
+	}
+}
+
+// maxPositionSeconds is the largest position which is read as seconds rather
+// than nanoseconds, around the year 5000.
+const maxPositionSeconds = 100_000_000_000
+
+// formatSince formats ts as a Unix timestamp with nanoseconds accepted by the
+// since parameter of the Docker API.
+func formatSince(ts time.Time) string {
+	if ts.IsZero() {
+		return "0"
+	}
+	return fmt.Sprintf("%d.%09d", ts.Unix(), ts.Nanosecond())
+}
+
+func (t *Target) lastTimestamp() time.Time {
+	t.lastMut.Lock()
+	defer t.lastMut.Unlock()
+	return t.last
+}
+
+func (t *Target) updateLastTimestamp(ts time.Time) {
+	t.lastMut.Lock()
+	defer t.lastMut.Unlock()
+	if ts.After(t.last) {
+		t.last = ts
 	}
 }
 
@@ -241,6 +303,13 @@ func (t *Target) Stop() {
 	level.Debug(t.logger).Log("msg", "stopped Docker target", "container", t.containerName)
 }
 
+// Stopped returns a channel which receives a value when the target stops
+// reading logs, either because Stop was called or because the log stream from
+// the Docker daemon ended.
+func (t *Target) Stopped() <-chan struct{} {
+	return t.stopped
+}
+
 // Ready reports whether the target is running.
 func (t *Target) Ready() bool {
 	return t.running.Load()
@@ -5,8 +5,11 @@ package docker
 import (
 	"context"
 	"sync"
+	"time"
 
-	"github.com/docker/docker/api/types/container"
+	docker_types "github.com/docker/docker/api/types"
+	docker_events "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component/common/loki"
@@ -16,6 +19,12 @@ import (
 	"github.com/grafana/agent/pkg/runner"
 )
 
+// containerRetryInterval is how long tailers wait before checking whether
+// their container is still running after its log stream ended, and before
+// watching the events of their container again after the events stream
+// failed.
+var containerRetryInterval = 5 * time.Second
+
 // A manager manages a set of running tailers.
 type manager struct {
 	log log.Logger
@@ -52,6 +61,16 @@ type options struct {
 
 	// positions interface so tailers can save/restore offsets in log files.
 	positions positions.Positions
+
+	negotiateOnce sync.Once
+}
+
+// negotiateAPIVersion negotiates the API version with the Docker daemon. The
+// Docker client negotiates the version on its first request in a way which
+// isn't safe for concurrent use, so it must be done once before the client is
+// shared by tailers.
+func (o *options) negotiateAPIVersion(ctx context.Context) {
+	o.negotiateOnce.Do(func() { o.client.NegotiateAPIVersion(ctx) })
 }
 
 // tailerTask is the payload used to create tailers. It implements runner.Task.
@@ -95,24 +114,105 @@ func newTailer(l log.Logger, task *tailerTask) *tailer {
 }
 
 func (t *tailer) Run(ctx context.Context) {
-	ch, chErr := t.opts.client.ContainerWait(ctx, t.target.Name(), container.WaitConditionNextExit)
-
+	t.opts.negotiateAPIVersion(ctx)
 	t.target.StartIfNotRunning()
+	defer t.target.Stop()
+
+	for {
+		if !t.watchEvents(ctx) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(containerRetryInterval):
+		}
+	}
+}
+
+// watchEvents resumes reading the logs of the container when it starts, until
+// the events stream fails. It returns false once the tailer should exit.
+func (t *tailer) watchEvents(ctx context.Context) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, errs := t.opts.client.Events(ctx, docker_types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", docker_events.ContainerEventType),
+			filters.Arg("container", t.target.Name()),
+		),
+	})
+
+	// The target stops by itself once the log stream from the Docker daemon
+	// ends, which happens when the container stops or the daemon closes the
+	// stream, for example when rotating the log file of the container.
+	var retry <-chan time.Time
+	if !t.target.Ready() {
+		// Events may have been missed while the events stream was down.
+		retry = time.After(0)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return false
+			}
+			level.Warn(t.log).Log("msg", "could not watch container events", "err", err)
+			return true
+
+		case event := <-events:
+			switch event.Action {
+			case "start":
+				level.Debug(t.log).Log("msg", "container started, resuming")
+				t.target.StartIfNotRunning()
+			case "destroy":
+				level.Info(t.log).Log("msg", "container no longer exists, stopping tailer")
+				return false
+			}
+
+		case <-t.target.Stopped():
+			retry = time.After(containerRetryInterval)
+
+		case <-retry:
+			retry = nil
+			exists, err := t.resumeIfRunning(ctx)
+			switch {
+			case !exists:
+				return false
+			case err != nil:
+				if ctx.Err() == nil {
+					level.Warn(t.log).Log("msg", "could not inspect container", "err", err)
+				}
+				retry = time.After(containerRetryInterval)
+			}
+		}
+	}
+}
+
+// resumeIfRunning resumes reading the logs of the container if it's running
+// and its logs aren't being read. It returns false if the container no longer
+// exists.
+func (t *tailer) resumeIfRunning(ctx context.Context) (bool, error) {
+	info, err := t.opts.client.ContainerInspect(ctx, t.target.Name())
+	switch {
+	case client.IsErrNotFound(err):
+		level.Info(t.log).Log("msg", "container no longer exists, stopping tailer")
+		return false, nil
+	case err != nil:
+		return true, err
+	}
 
-	select {
-	case err := <-chErr:
-		// Error setting up the Wait request from the client; either failed to
-		// read from /containers/{containerID}/wait, or couldn't parse the
-		// response. Stop the target and exit the task after logging; if it was
-		// a transient error, the target will be retried on the next discovery
-		// refresh.
-		level.Error(t.log).Log("msg", "could not set up a wait request to the Docker client", "error", err)
-		t.target.Stop()
-		return
-	case <-ch:
-		t.target.Stop()
-		return
+	running := info.ContainerJSONBase != nil && info.State != nil && info.State.Running
+	if running && !t.target.Ready() {
+		level.Debug(t.log).Log("msg", "container is running but its logs are not being read, resuming")
+		t.target.StartIfNotRunning()
 	}
+	return true, nil
 }
 
 // syncTargets synchronizes the set of running tailers to the set specified by
@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -314,3 +315,120 @@ func TestEncoding(t *testing.T) {
 		"expected positions.yml file to be written eventually",
 	)
 }
+
+func TestResumeFromPositions(t *testing.T) {
+	dataPath := t.TempDir()
+	filename := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(filename, []byte("line 1\nline 2\n"), 0644))
+
+	// The first run reads the whole file and saves its position on shutdown.
+	src := runFileSource(t, dataPath, filename)
+	src.requireLines(t, "line 1", "line 2")
+	src.stop()
+
+	// Lines appended while the component isn't running are read after it
+	// restarts, without reading the previous lines again.
+	appendToFile(t, filename, "line 3\n")
+	src = runFileSource(t, dataPath, filename)
+	appendToFile(t, filename, "line 4\n")
+	src.requireLines(t, "line 3", "line 4")
+	src.stop()
+
+	// If the file shrinks while the component isn't running, it was truncated
+	// or replaced, and is read from the beginning.
+	require.NoError(t, os.WriteFile(filename, []byte("new 1\n"), 0644))
+	src = runFileSource(t, dataPath, filename)
+	appendToFile(t, filename, "new 2\n")
+	src.requireLines(t, "new 1", "new 2")
+
+	// A file truncated while it's being tailed is also read from the
+	// beginning.
+	require.NoError(t, os.Truncate(filename, 0))
+	time.Sleep(100 * time.Millisecond)
+	appendToFile(t, filename, "after truncation\n")
+	src.requireLines(t, "after truncation")
+	src.stop()
+
+	src = runFileSource(t, dataPath, filename)
+	defer src.stop()
+	appendToFile(t, filename, "last\n")
+	src.requireLines(t, "last")
+}
+
+// testFileSource is a loki.source.file component tailing a single file.
+type testFileSource struct {
+	ch   loki.LogsReceiver
+	stop func()
+}
+
+// runFileSource runs a loki.source.file component which tails filename and
+// stores its positions file in dataPath.
+func runFileSource(t *testing.T, dataPath, filename string) testFileSource {
+	t.Helper()
+
+	ch := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": filename}}
+	args.ForwardTo = []loki.LogsReceiver{ch}
+	args.FileWatch = FileWatch{
+		MinPollFrequency: 10 * time.Millisecond,
+		MaxPollFrequency: 10 * time.Millisecond,
+	}
+
+	c, err := New(component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      dataPath,
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	var once sync.Once
+	return testFileSource{
+		ch: ch,
+		stop: func() {
+			once.Do(func() {
+				cancel()
+				<-done
+			})
+		},
+	}
+}
+
+// requireLines asserts that the next log entries received from the component
+// are exactly the given lines, and that no other entry follows them.
+func (s testFileSource) requireLines(t *testing.T, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		select {
+		case e := <-s.ch.Chan():
+			require.Equal(t, line, e.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line", "expected %q", line)
+		}
+	}
+
+	select {
+	case e := <-s.ch.Chan():
+		require.FailNow(t, "unexpected log line", "got %q", e.Line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func appendToFile(t *testing.T, filename, content string) {
+	t.Helper()
+
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+}
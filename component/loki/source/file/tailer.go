@@ -39,6 +39,10 @@ type tailer struct {
 	posAndSizeMtx sync.Mutex
 	stopOnce      sync.Once
 
+	// sentOffset is the offset just past the last line which was accepted by
+	// the handler.
+	sentOffset *atomic.Int64
+
 	running *atomic.Bool
 	posquit chan struct{}
 	posdone chan struct{}
@@ -60,8 +64,11 @@ func newTailer(metrics *metrics, logger log.Logger, handler loki.EntryHandler, p
 		return nil, err
 	}
 
+	// If the file shrank while it wasn't being tailed, it was truncated or
+	// replaced, so it must be read from the beginning.
 	if fi.Size() < pos {
 		positions.Remove(path, labels)
+		pos = 0
 	}
 
 	// If no cached position is found and the tailFromEnd option is enabled.
@@ -93,17 +100,18 @@ func newTailer(metrics *metrics, logger log.Logger, handler loki.EntryHandler, p
 
 	logger = log.With(logger, "component", "tailer")
 	tailer := &tailer{
-		metrics:   metrics,
-		logger:    logger,
-		handler:   loki.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
-		positions: positions,
-		path:      path,
-		labels:    labels,
-		tail:      tail,
-		running:   atomic.NewBool(false),
-		posquit:   make(chan struct{}),
-		posdone:   make(chan struct{}),
-		done:      make(chan struct{}),
+		metrics:    metrics,
+		logger:     logger,
+		handler:    loki.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
+		positions:  positions,
+		path:       path,
+		labels:     labels,
+		tail:       tail,
+		sentOffset: atomic.NewInt64(pos),
+		running:    atomic.NewBool(false),
+		posquit:    make(chan struct{}),
+		posdone:    make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 
 	if encoding != "" {
@@ -260,13 +268,27 @@ func (t *tailer) readLines() {
 		}
 
 		t.metrics.readLines.WithLabelValues(t.path).Inc()
-		entries <- loki.Entry{
+		entry := loki.Entry{
 			Labels: model.LabelSet{},
 			Entry: logproto.Entry{
 				Timestamp: line.Time,
 				Line:      text,
 			},
 		}
+
+		// Sending blocks for as long as the components downstream are busy,
+		// which slows down tailing rather than buffering lines in memory. If
+		// the tailer is stopped in the meantime, the line is dropped and it's
+		// read again from the saved position once the file is tailed again.
+		select {
+		case entries <- entry:
+			// The tailer splits the raw bytes of the file on '\n' and only
+			// strips that byte, so a carriage return before it is part of
+			// the line, and with UTF-16 the second byte of the newline is
+			// read at the start of the next line.
+			t.sentOffset.Add(int64(len(line.Text)) + 1)
+		case <-t.tail.Dying():
+		}
 	}
 }
 
@@ -290,6 +312,16 @@ func (t *tailer) MarkPositionAndSize() error {
 		return err
 	}
 
+	// The offset of the underlying reader can be ahead of the lines which
+	// were accepted by the handler, so the offset of the last accepted line
+	// is saved instead. If the reader is behind, the file was truncated or
+	// reopened, and subsequent lines are accounted from the reader's offset.
+	if sent := t.sentOffset.Load(); sent <= pos {
+		pos = sent
+	} else {
+		t.sentOffset.CompareAndSwap(sent, pos)
+	}
+
 	// Update metrics and positions file all together to avoid race conditions when `t.tail` is stopped.
 	t.metrics.totalBytes.WithLabelValues(t.path).Set(float64(size))
 	t.metrics.readBytes.WithLabelValues(t.path).Set(float64(pos))
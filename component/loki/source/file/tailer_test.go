@@ -2,8 +2,20 @@ package file
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/component/common/loki/positions"
+	"github.com/grafana/tail/watch"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func createTempFileWithContent(t *testing.T, content []byte) string {
@@ -78,3 +90,133 @@ func TestGetLastLinePosition(t *testing.T) {
 		})
 	}
 }
+
+func TestTailerPositionWithBackpressure(t *testing.T) {
+	const linesCount = 10
+
+	var content bytes.Buffer
+	for i := 0; i < linesCount; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	lineLength := int64(len("line 0\n"))
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(filename, content.Bytes(), 0644))
+
+	logger := log.NewNopLogger()
+	pos, err := positions.New(logger, positions.Config{
+		SyncPeriod:    time.Hour,
+		PositionsFile: filepath.Join(dir, "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer pos.Stop()
+
+	// Nothing reads from the handler yet, like when the components downstream
+	// are unable to keep up.
+	ch := make(chan loki.Entry)
+	m := newMetrics(prometheus.NewRegistry())
+	tailer, err := newTailer(m, logger, loki.NewEntryHandler(ch, func() {}), pos, filename, "{}", "", watch.PollingFileWatcherOptions{
+		MinPollFrequency: 10 * time.Millisecond,
+		MaxPollFrequency: 10 * time.Millisecond,
+	}, false)
+	require.NoError(t, err)
+	defer tailer.Stop()
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, fmt.Sprintf("line %d", i), (<-ch).Line)
+	}
+
+	// The next line is accepted by the handler wrapping ch, and the one after
+	// it is read, but blocked on being handed to the handler.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.readLines.WithLabelValues(filename)) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, tailer.MarkPositionAndSize())
+	offset, err := pos.Get(filename, "{}")
+	require.NoError(t, err)
+	require.Equal(t, 4*lineLength, offset)
+
+	// Once the downstream catches up, no lines were lost and the position
+	// moves to the end of the file.
+	for i := 3; i < linesCount; i++ {
+		select {
+		case e := <-ch:
+			require.Equal(t, fmt.Sprintf("line %d", i), e.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+	require.Eventually(t, func() bool {
+		require.NoError(t, tailer.MarkPositionAndSize())
+		offset, err := pos.Get(filename, "{}")
+		require.NoError(t, err)
+		return offset == int64(content.Len())
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTailerPositionEncodings(t *testing.T) {
+	utf16 := func(s string) []byte {
+		b, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(s))
+		require.NoError(t, err)
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		content  []byte
+		lines    int
+	}{
+		{
+			name:    "CRLF",
+			content: []byte("line 1\r\nline 2\r\n"),
+			lines:   2,
+		},
+		{
+			name:     "UTF-16",
+			encoding: "UTF-16LE",
+			content:  utf16("line 1\nline 2\n"),
+			lines:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "app.log")
+			require.NoError(t, os.WriteFile(filename, tt.content, 0644))
+
+			logger := log.NewNopLogger()
+			pos, err := positions.New(logger, positions.Config{
+				SyncPeriod:    time.Hour,
+				PositionsFile: filepath.Join(dir, "positions.yml"),
+			})
+			require.NoError(t, err)
+			defer pos.Stop()
+
+			ch := make(chan loki.Entry)
+			tailer, err := newTailer(newMetrics(prometheus.NewRegistry()), logger, loki.NewEntryHandler(ch, func() {}), pos, filename, "{}", tt.encoding, watch.PollingFileWatcherOptions{
+				MinPollFrequency: 10 * time.Millisecond,
+				MaxPollFrequency: 10 * time.Millisecond,
+			}, false)
+			require.NoError(t, err)
+			defer tailer.Stop()
+
+			for i := 0; i < tt.lines; i++ {
+				select {
+				case <-ch:
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "failed waiting for log line")
+				}
+			}
+
+			// The offset is past the last newline byte which was read.
+			require.NoError(t, tailer.MarkPositionAndSize())
+			offset, err := pos.Get(filename, "{}")
+			require.NoError(t, err)
+			require.Equal(t, int64(bytes.LastIndexByte(tt.content, '\n')+1), offset)
+		})
+	}
+}
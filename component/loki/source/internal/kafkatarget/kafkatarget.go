@@ -74,33 +74,49 @@ const (
 func (t *KafkaTarget) run() {
 	defer t.client.Stop()
 	for message := range t.claim.Messages() {
-		mk := string(message.Key)
-		if len(mk) == 0 {
-			mk = defaultKafkaMessageKey
+		if !t.handleMessage(message) {
+			return
 		}
+	}
+}
 
-		// TODO: Possibly need to format after merging with discovered labels because we can specify multiple labels in source labels
-		// https://github.com/grafana/loki/pull/4745#discussion_r750022234
-		lbs := format([]labels.Label{
-			{Name: labelKeyKafkaMessageKey, Value: mk},
-			{Name: labelKeyKafkaOffset, Value: fmt.Sprintf("%v", message.Offset)},
-		}, t.relabelConfig)
+// handleMessage sends the entries parsed from message to the client, and
+// marks the message as consumed once they have all been accepted. If the
+// session ends before that, for example, because of a rebalance, the message
+// isn't marked so that it's consumed again by the next owner of the
+// partition, and false is returned.
+func (t *KafkaTarget) handleMessage(message *sarama.ConsumerMessage) bool {
+	mk := string(message.Key)
+	if len(mk) == 0 {
+		mk = defaultKafkaMessageKey
+	}
 
-		out := t.lbs.Clone()
-		if len(lbs) > 0 {
-			out = out.Merge(lbs)
-		}
-		entries, err := t.messageParser.Parse(message, out, t.relabelConfig, t.useIncomingTimestamp)
-		if err != nil {
-			level.Error(t.logger).Log("msg", "message parsing error", "err", err)
-		} else {
-			for _, entry := range entries {
-				t.client.Chan() <- entry
+	// TODO: Possibly need to format after merging with discovered labels because we can specify multiple labels in source labels
+	// https://github.com/grafana/loki/pull/4745#discussion_r750022234
+	lbs := format([]labels.Label{
+		{Name: labelKeyKafkaMessageKey, Value: mk},
+		{Name: labelKeyKafkaOffset, Value: fmt.Sprintf("%v", message.Offset)},
+	}, t.relabelConfig)
+
+	out := t.lbs.Clone()
+	if len(lbs) > 0 {
+		out = out.Merge(lbs)
+	}
+	entries, err := t.messageParser.Parse(message, out, t.relabelConfig, t.useIncomingTimestamp)
+	if err != nil {
+		level.Error(t.logger).Log("msg", "message parsing error", "err", err)
+	} else {
+		for _, entry := range entries {
+			select {
+			case <-t.session.Context().Done():
+				return false
+			case t.client.Chan() <- entry:
 			}
 		}
-
-		t.session.MarkMessage(message, "")
 	}
+
+	t.session.MarkMessage(message, "")
+	return true
 }
 
 func timestamp(useIncoming bool, incoming time.Time) time.Time {
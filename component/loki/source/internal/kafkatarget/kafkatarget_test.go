@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component/common/loki"
 	"github.com/grafana/agent/component/common/loki/client/fake"
 
 	"github.com/IBM/sarama"
@@ -72,6 +73,7 @@ func (c *testConsumerGroupHandler) PauseAll()                            {}
 func (c *testConsumerGroupHandler) ResumeAll()                           {}
 
 type testSession struct {
+	ctx           context.Context
 	markedMessage []*sarama.ConsumerMessage
 }
 
@@ -84,7 +86,12 @@ func (s *testSession) ResetOffset(topic string, partition int32, offset int64, m
 func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
 	s.markedMessage = append(s.markedMessage, msg)
 }
-func (s *testSession) Context() context.Context { return context.Background() }
+func (s *testSession) Context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
 
 type testClaim struct {
 	topic     string
@@ -243,3 +250,36 @@ func Test_TargetRun(t *testing.T) {
 		})
 	}
 }
+
+func Test_TargetRunSessionEnd(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Nothing reads the second entry from ch, like when the components
+	// downstream are unable to keep up.
+	ch := make(chan loki.Entry)
+	session, claim := &testSession{ctx: ctx}, newTestClaim("footopic", 10, 0)
+	tg := NewKafkaTarget(nil, session, claim, nil, model.LabelSet{"buzz": "bazz"}, nil, loki.NewEntryHandler(ch, func() {}), false, &KafkaTargetMessageParser{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tg.run()
+	}()
+
+	first := &sarama.ConsumerMessage{Value: []byte("first"), Offset: 0}
+	claim.Send(first)
+	require.Equal(t, "first", (<-ch).Line)
+	claim.Send(&sarama.ConsumerMessage{Value: []byte("second"), Offset: 1})
+
+	// The session ends, for example, because of a rebalance, while the second
+	// message is waiting to be sent. The target stops without marking it, so
+	// that it's consumed again by the next owner of the partition.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "target didn't stop after the session ended")
+	}
+	require.Equal(t, []*sarama.ConsumerMessage{first}, session.markedMessage)
+}
@@ -49,19 +49,21 @@ const (
 	emptyLabelsError = "empty_labels"
 )
 
-type journalReader interface {
+// JournalReader follows the journal, passing every entry to the Formatter of
+// the sdjournal.JournalReaderConfig it was created with.
+type JournalReader interface {
 	io.Closer
 	Follow(until <-chan time.Time, writer io.Writer) error
 }
 
 // Abstracted functions for interacting with the journal, used for mocking in tests:
 type (
-	journalReaderFunc func(sdjournal.JournalReaderConfig) (journalReader, error)
-	journalEntryFunc  func(cfg sdjournal.JournalReaderConfig, cursor string) (*sdjournal.JournalEntry, error)
+	JournalReaderFunc func(sdjournal.JournalReaderConfig) (JournalReader, error)
+	JournalEntryFunc  func(cfg sdjournal.JournalReaderConfig, cursor string) (*sdjournal.JournalEntry, error)
 )
 
 // Default implementations of abstracted functions:
-var defaultJournalReaderFunc = func(c sdjournal.JournalReaderConfig) (journalReader, error) {
+var defaultJournalReaderFunc = func(c sdjournal.JournalReaderConfig) (JournalReader, error) {
 	return sdjournal.NewJournalReader(c)
 }
 
@@ -112,7 +114,7 @@ type JournalTarget struct {
 	config        *scrapeconfig.JournalTargetConfig
 	labels        model.LabelSet
 
-	r     journalReader
+	r     JournalReader
 	until chan time.Time
 }
 
@@ -127,7 +129,7 @@ func NewJournalTarget(
 	targetConfig *scrapeconfig.JournalTargetConfig,
 ) (*JournalTarget, error) {
 
-	return journalTargetWithReader(
+	return NewJournalTargetWithReader(
 		metrics,
 		logger,
 		handler,
@@ -140,7 +142,10 @@ func NewJournalTarget(
 	)
 }
 
-func journalTargetWithReader(
+// NewJournalTargetWithReader configures a new JournalTarget which uses
+// readerFunc to open the journal and entryFunc to look up the entry of a
+// saved cursor. If either function is nil, the systemd journal is used.
+func NewJournalTargetWithReader(
 	metrics *Metrics,
 	logger log.Logger,
 	handler loki.EntryHandler,
@@ -148,8 +153,8 @@ func journalTargetWithReader(
 	jobName string,
 	relabelConfig []*relabel.Config,
 	targetConfig *scrapeconfig.JournalTargetConfig,
-	readerFunc journalReaderFunc,
-	entryFunc journalEntryFunc,
+	readerFunc JournalReaderFunc,
+	entryFunc JournalEntryFunc,
 ) (*JournalTarget, error) {
 
 	positionPath := positions.CursorKey(jobName)
@@ -237,7 +242,7 @@ type journalConfigBuilder struct {
 	Position    string
 	Matches     []sdjournal.Match
 	MaxAge      time.Duration
-	EntryFunc   journalEntryFunc
+	EntryFunc   JournalEntryFunc
 }
 
 // generateJournalConfig generates a journal config by trying to intelligently
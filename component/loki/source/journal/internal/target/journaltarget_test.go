@@ -35,7 +35,7 @@ type mockJournalReader struct {
 	t      *testing.T
 }
 
-func newMockJournalReader(c sdjournal.JournalReaderConfig) (journalReader, error) {
+func newMockJournalReader(c sdjournal.JournalReaderConfig) (JournalReader, error) {
 	return &mockJournalReader{config: c}, nil
 }
 
@@ -48,7 +48,7 @@ func (r *mockJournalReader) Follow(until <-chan time.Time, writer io.Writer) err
 	return nil
 }
 
-func newMockJournalEntry(entry *sdjournal.JournalEntry) journalEntryFunc {
+func newMockJournalEntry(entry *sdjournal.JournalEntry) JournalEntryFunc {
 	return func(c sdjournal.JournalReaderConfig, cursor string) (*sdjournal.JournalEntry, error) {
 		return entry, nil
 	}
@@ -103,7 +103,7 @@ func TestJournalTarget(t *testing.T) {
 	require.NoError(t, err)
 
 	registry := prometheus.NewRegistry()
-	jt, err := journalTargetWithReader(NewMetrics(registry), logger, client, ps, "test", relabels,
+	jt, err := NewJournalTargetWithReader(NewMetrics(registry), logger, client, ps, "test", relabels,
 		&scrapeconfig.JournalTargetConfig{}, newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
@@ -157,7 +157,7 @@ func TestJournalTargetParsingErrors(t *testing.T) {
 	var relabels []*relabel.Config
 
 	registry := prometheus.NewRegistry()
-	jt, err := journalTargetWithReader(NewMetrics(registry), logger, client, ps, "test", relabels,
+	jt, err := NewJournalTargetWithReader(NewMetrics(registry), logger, client, ps, "test", relabels,
 		&scrapeconfig.JournalTargetConfig{}, newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
@@ -234,7 +234,7 @@ func TestJournalTarget_JSON(t *testing.T) {
 
 	cfg := &scrapeconfig.JournalTargetConfig{JSON: true}
 
-	jt, err := journalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", relabels,
+	jt, err := NewJournalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", relabels,
 		cfg, newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
@@ -285,7 +285,7 @@ func TestJournalTarget_Since(t *testing.T) {
 		MaxAge: "4h",
 	}
 
-	jt, err := journalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
+	jt, err := NewJournalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
 		&cfg, newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
@@ -325,7 +325,7 @@ func TestJournalTarget_Cursor_TooOld(t *testing.T) {
 		RealtimeTimestamp: uint64(entryTs.UnixNano()),
 	})
 
-	jt, err := journalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
+	jt, err := NewJournalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
 		&cfg, newMockJournalReader, journalEntry)
 	require.NoError(t, err)
 
@@ -365,7 +365,7 @@ func TestJournalTarget_Cursor_NotTooOld(t *testing.T) {
 		RealtimeTimestamp: uint64(entryTs.UnixNano() / int64(time.Microsecond)),
 	})
 
-	jt, err := journalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
+	jt, err := NewJournalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
 		&cfg, newMockJournalReader, journalEntry)
 	require.NoError(t, err)
 
@@ -416,7 +416,7 @@ func TestJournalTarget_Matches(t *testing.T) {
 		Matches: "UNIT=foo.service PRIORITY=1",
 	}
 
-	jt, err := journalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
+	jt, err := NewJournalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
 		&cfg, newMockJournalReader, newMockJournalEntry(nil))
 	require.NoError(t, err)
 
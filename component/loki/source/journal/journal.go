@@ -17,12 +17,14 @@ import (
 	"github.com/prometheus/common/model"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name: "loki.source.journal",
-		Args: Arguments{},
+		Name:      "loki.source.journal",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -41,10 +43,19 @@ type Component struct {
 	handler   chan loki.Entry
 	positions positions.Positions
 	receivers []loki.LogsReceiver
+
+	// Functions used to read the journal. When nil, the systemd journal is
+	// read. Tests override them with a fake journal.
+	readerFunc target.JournalReaderFunc
+	entryFunc  target.JournalEntryFunc
 }
 
 // New creates a new  component.
 func New(o component.Options, args Arguments) (*Component, error) {
+	return newWithReader(o, args, nil, nil)
+}
+
+func newWithReader(o component.Options, args Arguments, readerFunc target.JournalReaderFunc, entryFunc target.JournalEntryFunc) (*Component, error) {
 	err := os.MkdirAll(o.DataPath, 0750)
 	if err != nil {
 		return nil, err
@@ -66,6 +77,9 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		handler:   make(chan loki.Entry),
 		positions: positionsFile,
 		receivers: args.Receivers,
+
+		readerFunc: readerFunc,
+		entryFunc:  entryFunc,
 	}
 	err = c.Update(args)
 	return c, err
@@ -80,6 +94,9 @@ func (c *Component) Run(ctx context.Context) error {
 		}
 		c.mut.RUnlock()
 
+		// Stopping the positions flushes the last read cursor to disk, so that
+		// reading resumes from it after a restart.
+		c.positions.Stop()
 	}()
 	for {
 		select {
@@ -113,7 +130,7 @@ func (c *Component) Update(args component.Arguments) error {
 	rcs := flow_relabel.ComponentToPromRelabelConfigs(newArgs.RelabelRules)
 	entryHandler := loki.NewEntryHandler(c.handler, func() {})
 
-	newTarget, err := target.NewJournalTarget(c.metrics, c.o.Logger, entryHandler, c.positions, c.o.ID, rcs, convertArgs(c.o.ID, newArgs))
+	newTarget, err := target.NewJournalTargetWithReader(c.metrics, c.o.Logger, entryHandler, c.positions, c.o.ID, rcs, convertArgs(c.o.ID, newArgs), c.readerFunc, c.entryFunc)
 	if err != nil {
 		return err
 	}
@@ -4,15 +4,22 @@ package journal
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/coreos/go-systemd/sdjournal"
 	"github.com/coreos/go-systemd/v22/journal"
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/common/loki"
+	flow_relabel "github.com/grafana/agent/component/common/relabel"
+	"github.com/grafana/agent/component/loki/source/journal/internal/target"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,3 +62,196 @@ func TestJournal(t *testing.T) {
 	}
 	require.True(t, found)
 }
+
+func TestJournalFakeReader(t *testing.T) {
+	tmp := t.TempDir()
+	fj := &fakeJournal{}
+	fj.add("cursor-1", "app.service", "6", "starting")
+	fj.add("cursor-2", "app.service", "3", "failed to connect")
+
+	args := Arguments{
+		MaxAge:  7 * time.Hour,
+		Matches: "_SYSTEMD_UNIT=app.service",
+		RelabelRules: flow_relabel.Rules{
+			{
+				SourceLabels: []string{"__journal__systemd_unit"},
+				Separator:    ";",
+				Regex:        flow_relabel.Regexp{Regexp: flow_relabel.DefaultRelabelConfig.Regex.Regexp},
+				TargetLabel:  "unit",
+				Replacement:  "$1",
+				Action:       flow_relabel.Replace,
+			},
+			{
+				SourceLabels: []string{"__journal_priority_keyword"},
+				Separator:    ";",
+				Regex:        flow_relabel.Regexp{Regexp: flow_relabel.DefaultRelabelConfig.Regex.Regexp},
+				TargetLabel:  "level",
+				Replacement:  "$1",
+				Action:       flow_relabel.Replace,
+			},
+		},
+	}
+
+	// The first run reads the whole journal.
+	received := runFakeJournal(t, tmp, args, fj, 2)
+	require.Equal(t, []string{"starting", "failed to connect"}, lines(received))
+	require.Equal(t, model.LabelSet{
+		"job":   "loki.source.journal.test",
+		"unit":  "app.service",
+		"level": "info",
+	}, received[0].Labels)
+	require.Equal(t, model.LabelValue("error"), received[1].Labels["level"])
+
+	cfg := fj.config(0)
+	require.Equal(t, []sdjournal.Match{{Field: "_SYSTEMD_UNIT", Value: "app.service"}}, cfg.Matches)
+	require.Empty(t, cfg.Cursor)
+	require.Equal(t, -7*time.Hour, cfg.Since)
+
+	// After a restart, reading resumes after the last cursor which was read.
+	fj.add("cursor-3", "app.service", "6", "connected")
+	received = runFakeJournal(t, tmp, args, fj, 1)
+	require.Equal(t, []string{"connected"}, lines(received))
+
+	cfg = fj.config(1)
+	require.Equal(t, "cursor-2", cfg.Cursor)
+	require.Zero(t, cfg.Since)
+}
+
+// runFakeJournal runs a component reading from fj until it has received
+// count entries, and then stops it.
+func runFakeJournal(t *testing.T, dataPath string, args Arguments, fj *fakeJournal, count int) []loki.Entry {
+	t.Helper()
+
+	lr := loki.NewLogsReceiver()
+	args.Receivers = []loki.LogsReceiver{lr}
+
+	c, err := newWithReader(component.Options{
+		ID:         "loki.source.journal.test",
+		Logger:     util.TestFlowLogger(t),
+		DataPath:   dataPath,
+		Registerer: prometheus.NewRegistry(),
+	}, args, fj.newReader, fj.entry)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	var received []loki.Entry
+	for len(received) < count {
+		select {
+		case e := <-lr.Chan():
+			received = append(received, e)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for journal entries")
+		}
+	}
+
+	select {
+	case e := <-lr.Chan():
+		require.FailNow(t, "received unexpected journal entry", e.Line)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	<-done
+	return received
+}
+
+func lines(entries []loki.Entry) []string {
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.Line)
+	}
+	return res
+}
+
+// fakeJournal is an in-memory journal. Readers created from it replay all
+// entries following the configured cursor.
+type fakeJournal struct {
+	mut     sync.Mutex
+	entries []*sdjournal.JournalEntry
+	configs []sdjournal.JournalReaderConfig
+}
+
+func (fj *fakeJournal) add(cursor, unit, priority, message string) {
+	fj.mut.Lock()
+	defer fj.mut.Unlock()
+
+	fj.entries = append(fj.entries, &sdjournal.JournalEntry{
+		Cursor:            cursor,
+		RealtimeTimestamp: uint64(time.Now().UnixMicro()),
+		Fields: map[string]string{
+			"MESSAGE":       message,
+			"PRIORITY":      priority,
+			"_SYSTEMD_UNIT": unit,
+		},
+	})
+}
+
+// config returns the config of the i-th reader created from the journal.
+func (fj *fakeJournal) config(i int) sdjournal.JournalReaderConfig {
+	fj.mut.Lock()
+	defer fj.mut.Unlock()
+	return fj.configs[i]
+}
+
+func (fj *fakeJournal) newReader(cfg sdjournal.JournalReaderConfig) (target.JournalReader, error) {
+	fj.mut.Lock()
+	defer fj.mut.Unlock()
+
+	fj.configs = append(fj.configs, cfg)
+
+	var start int
+	if cfg.Cursor != "" {
+		for i, e := range fj.entries {
+			if e.Cursor == cfg.Cursor {
+				start = i + 1
+			}
+		}
+	}
+	entries := make([]*sdjournal.JournalEntry, len(fj.entries)-start)
+	copy(entries, fj.entries[start:])
+	return &fakeJournalReader{config: cfg, entries: entries}, nil
+}
+
+func (fj *fakeJournal) entry(_ sdjournal.JournalReaderConfig, cursor string) (*sdjournal.JournalEntry, error) {
+	fj.mut.Lock()
+	defer fj.mut.Unlock()
+
+	for _, e := range fj.entries {
+		if e.Cursor == cursor {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("cursor %q not found", cursor)
+}
+
+type fakeJournalReader struct {
+	config  sdjournal.JournalReaderConfig
+	entries []*sdjournal.JournalEntry
+	once    sync.Once
+}
+
+func (r *fakeJournalReader) Close() error {
+	return nil
+}
+
+func (r *fakeJournalReader) Follow(until <-chan time.Time, _ io.Writer) error {
+	var err error
+	r.once.Do(func() {
+		for _, e := range r.entries {
+			if _, err = r.config.Formatter(e); err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	<-until
+	return nil
+}
@@ -1,9 +1,17 @@
 package kafka
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/IBM/sarama"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 )
 
@@ -84,3 +92,140 @@ func TestSASLOAuthRiverConfig(t *testing.T) {
 	err := river.Unmarshal([]byte(exampleRiverConfig), &args)
 	require.NoError(t, err)
 }
+
+func TestConsumeWithRebalance(t *testing.T) {
+	const (
+		topic   = "logs"
+		groupID = "loki.source.kafka"
+	)
+
+	broker := sarama.NewMockBroker(t, 0)
+	defer broker.Close()
+
+	// handlers returns the responses of the mock broker for a partition whose
+	// committed offset is committed and which holds messageCount messages.
+	// Once the heartbeat returns heartbeatErr, the consumer group needs to be
+	// rebalanced.
+	handlers := func(committed int64, messageCount int, heartbeatErr sarama.KError) map[string]sarama.MockResponse {
+		fetch := sarama.NewMockFetchResponse(t, 1)
+		for i := 0; i < messageCount; i++ {
+			fetch.SetMessage(topic, 0, int64(i), sarama.StringEncoder(fmt.Sprintf("message %d", i)))
+		}
+		fetch.SetHighWaterMark(topic, 0, int64(messageCount))
+
+		return map[string]sarama.MockResponse{
+			"MetadataRequest": sarama.NewMockMetadataResponse(t).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				SetLeader(topic, 0, broker.BrokerID()),
+			"OffsetRequest": sarama.NewMockOffsetResponse(t).
+				SetOffset(topic, 0, sarama.OffsetOldest, 0).
+				SetOffset(topic, 0, sarama.OffsetNewest, int64(messageCount)),
+			"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+				SetCoordinator(sarama.CoordinatorGroup, groupID, broker),
+			"JoinGroupRequest": sarama.NewMockJoinGroupResponse(t).
+				SetGroupProtocol(sarama.RangeBalanceStrategyName),
+			"SyncGroupRequest": sarama.NewMockSyncGroupResponse(t).
+				SetMemberAssignment(&sarama.ConsumerGroupMemberAssignment{
+					Topics: map[string][]int32{topic: {0}},
+				}),
+			"HeartbeatRequest": sarama.NewMockSequence(
+				sarama.NewMockWrapper(&sarama.HeartbeatResponse{Version: 2, Err: heartbeatErr}),
+				sarama.NewMockHeartbeatResponse(t),
+			),
+			"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+				SetOffset(groupID, topic, 0, committed, "", sarama.ErrNoError).
+				SetError(sarama.ErrNoError),
+			"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+			"FetchRequest":        fetch,
+			"LeaveGroupRequest":   sarama.NewMockLeaveGroupResponse(t),
+		}
+	}
+	// Without a committed offset, the partition is consumed from the oldest
+	// offset.
+	broker.SetHandlerByMap(handlers(-1, 5, sarama.ErrNoError))
+
+	receiver := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Brokers = []string{broker.Addr()}
+	args.Topics = []string{topic}
+	args.Labels = map[string]string{"job": "kafka"}
+	args.ForwardTo = []loki.LogsReceiver{receiver}
+
+	c, err := New(component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	requireMessages := func(from, to int) {
+		for i := from; i < to; i++ {
+			select {
+			case entry := <-receiver.Chan():
+				require.Equal(t, fmt.Sprintf("message %d", i), entry.Line)
+			case <-time.After(10 * time.Second):
+				require.FailNow(t, "timed out waiting for message", "message %d", i)
+			}
+		}
+	}
+	requireMessages(0, 5)
+
+	// Once the delivered messages are committed, the consumer group is
+	// rebalanced. The partition is assigned again and consumed from the
+	// committed offset.
+	require.Eventually(t, func() bool {
+		return lastCommittedOffset(broker, topic) == 5
+	}, 10*time.Second, 10*time.Millisecond)
+	broker.SetHandlerByMap(handlers(5, 5, sarama.ErrRebalanceInProgress))
+	require.Eventually(t, func() bool {
+		return joinGroupRequests(broker) >= 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	broker.SetHandlerByMap(handlers(5, 10, sarama.ErrNoError))
+	requireMessages(5, 10)
+
+	// The remaining offsets are committed when the component stops, and no
+	// message is consumed more than once.
+	cancel()
+	<-done
+	require.Equal(t, int64(10), lastCommittedOffset(broker, topic))
+	select {
+	case entry := <-receiver.Chan():
+		require.FailNow(t, "unexpected message", entry.Line)
+	default:
+	}
+}
+
+// lastCommittedOffset returns the last offset of partition 0 of topic
+// committed to broker, or -1 if no offset was committed.
+func lastCommittedOffset(broker *sarama.MockBroker, topic string) int64 {
+	offset := int64(-1)
+	for _, rr := range broker.History() {
+		req, ok := rr.Request.(*sarama.OffsetCommitRequest)
+		if !ok {
+			continue
+		}
+		if o, _, err := req.Offset(topic, 0); err == nil {
+			offset = o
+		}
+	}
+	return offset
+}
+
+// joinGroupRequests returns the number of JoinGroup requests sent to broker.
+func joinGroupRequests(broker *sarama.MockBroker) int {
+	var n int
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.JoinGroupRequest); ok {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,418 @@
+// Package metrics provides the loki.source.metrics component.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/loki/pkg/logproto"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.source.metrics",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.source.metrics
+// component.
+type Arguments struct {
+	ForwardTo []loki.LogsReceiver `river:"forward_to,attr"`
+	Labels    map[string]string   `river:"labels,attr,optional"`
+	Rules     []Rule              `river:"rule,block"`
+}
+
+// Rule describes a threshold which samples of the series matching Selector
+// cross when they are above Above, or below Below.
+type Rule struct {
+	Name     string   `river:"name,attr"`
+	Selector string   `river:"selector,attr"`
+	Above    *float64 `river:"above,attr,optional"`
+	Below    *float64 `river:"below,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	for name := range args.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("label %q is not a valid label name", name)
+		}
+	}
+	names := make(map[string]struct{}, len(args.Rules))
+	for _, r := range args.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule name must not be empty")
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("rule %q is configured more than once", r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		if _, err := parser.ParseMetricSelector(r.Selector); err != nil {
+			return fmt.Errorf("rule %q has an invalid selector: %w", r.Name, err)
+		}
+		if (r.Above == nil) == (r.Below == nil) {
+			return fmt.Errorf("rule %q must configure exactly one of above & below", r.Name)
+		}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the loki.source.metrics
+// component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Event states.
+const (
+	stateFiring   = "firing"
+	stateResolved = "resolved"
+)
+
+const (
+	// entriesBuffer is the number of log entries buffered for the receivers,
+	// beyond which the entries are dropped rather than blocking the
+	// components sending metrics.
+	entriesBuffer = 1000
+
+	// seriesTimeout is the duration after which a series without samples is
+	// forgotten, for the series which disappear without a stale marker.
+	seriesTimeout = 15 * time.Minute
+)
+
+// Component implements the loki.source.metrics component.
+type Component struct {
+	opts    component.Options
+	entries chan loki.Entry
+	exited  chan struct{}
+	events  *prometheus_client.CounterVec
+	dropped prometheus_client.Counter
+
+	mut       sync.RWMutex
+	receivers []loki.LogsReceiver
+	labels    model.LabelSet
+	rules     []*ruleState
+}
+
+// ruleState holds a rule with the series whose samples crossed its
+// threshold, and the time their last sample was received.
+type ruleState struct {
+	Rule
+	matchers []*labels.Matcher
+	crossed  map[uint64]time.Time
+}
+
+func (r *ruleState) matches(l labels.Labels) bool {
+	for _, m := range r.matchers {
+		if !m.Matches(l.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ruleState) crosses(v float64) bool {
+	if r.Above != nil {
+		return v > *r.Above
+	}
+	return v < *r.Below
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// New creates a new loki.source.metrics component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:    o,
+		entries: make(chan loki.Entry, entriesBuffer),
+		exited:  make(chan struct{}),
+		events: prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+			Name: "loki_source_metrics_events_total",
+			Help: "Number of log entries emitted for threshold crossings.",
+		}, []string{"rule", "state"}),
+		dropped: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "loki_source_metrics_dropped_entries_total",
+			Help: "Number of log entries dropped because the receivers were busy.",
+		}),
+	}
+	for _, m := range []prometheus_client.Collector{c.events, c.dropped} {
+		if err := o.Registerer.Register(m); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c})
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer close(c.exited)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			c.expireSeries(now)
+		case entry := <-c.entries:
+			c.mut.RLock()
+			receivers := c.receivers
+			c.mut.RUnlock()
+
+			for _, receiver := range receivers {
+				select {
+				case <-ctx.Done():
+					return nil
+				case receiver.Chan() <- entry:
+				}
+			}
+		}
+	}
+}
+
+// Update implements component.Component. The state of the rules which are
+// left unchanged is kept, so that their crossings aren't reported again.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	oldRules := make(map[string]*ruleState, len(c.rules))
+	for _, r := range c.rules {
+		oldRules[r.Name] = r
+	}
+
+	rules := make([]*ruleState, 0, len(newArgs.Rules))
+	for _, r := range newArgs.Rules {
+		if old, ok := oldRules[r.Name]; ok && reflect.DeepEqual(old.Rule, r) {
+			rules = append(rules, old)
+			continue
+		}
+		matchers, err := parser.ParseMetricSelector(r.Selector)
+		if err != nil {
+			return fmt.Errorf("rule %q has an invalid selector: %w", r.Name, err)
+		}
+		rules = append(rules, &ruleState{Rule: r, matchers: matchers, crossed: make(map[uint64]time.Time)})
+	}
+
+	ls := make(model.LabelSet, len(newArgs.Labels))
+	for k, v := range newArgs.Labels {
+		ls[model.LabelName(k)] = model.LabelValue(v)
+	}
+
+	c.receivers = newArgs.ForwardTo
+	c.labels = ls
+	c.rules = rules
+	return nil
+}
+
+// Appender implements storage.Appendable.
+func (c *Component) Appender(_ context.Context) storage.Appender {
+	return &appender{c: c}
+}
+
+// sample is a sample matching a rule.
+type sample struct {
+	rule *ruleState
+	l    labels.Labels
+	t    int64
+	v    float64
+}
+
+// evaluate updates the state of the rules with samples received at now, and
+// returns the log entries for the series which crossed the threshold of a
+// rule, or went back within it.
+func (c *Component) evaluate(samples []sample, now time.Time) []loki.Entry {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var entries []loki.Entry
+	for _, s := range samples {
+		hash := s.l.Hash()
+		if value.IsStaleNaN(s.v) {
+			// The series is gone, forget about it.
+			delete(s.rule.crossed, hash)
+			continue
+		}
+		if math.IsNaN(s.v) {
+			continue
+		}
+
+		crossed := s.rule.crosses(s.v)
+		_, wasCrossed := s.rule.crossed[hash]
+		if crossed {
+			s.rule.crossed[hash] = now
+		} else {
+			delete(s.rule.crossed, hash)
+		}
+		if crossed == wasCrossed {
+			continue
+		}
+
+		entry, err := c.newEntry(s, crossed)
+		if err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to encode threshold crossing", "rule", s.rule.Name, "series", s.l.String(), "err", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// expireSeries forgets about the series which had no samples for
+// seriesTimeout, as with a stale marker.
+func (c *Component) expireSeries(now time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, r := range c.rules {
+		for hash, seen := range r.crossed {
+			if now.Sub(seen) >= seriesTimeout {
+				delete(r.crossed, hash)
+			}
+		}
+	}
+}
+
+// newEntry returns the log entry for a crossing of the threshold of a rule by
+// s. The line is in logfmt and holds the rule, the state, the metric name, the
+// series, the value and the threshold.
+func (c *Component) newEntry(s sample, crossed bool) (loki.Entry, error) {
+	state := stateResolved
+	if crossed {
+		state = stateFiring
+	}
+
+	condition, threshold := "above", s.rule.Above
+	if threshold == nil {
+		condition, threshold = "below", s.rule.Below
+	}
+
+	line, err := logfmt.MarshalKeyvals(
+		"rule", s.rule.Name,
+		"state", state,
+		"metric", s.l.Get(labels.MetricName),
+		"series", s.l.String(),
+		"value", strconv.FormatFloat(s.v, 'g', -1, 64),
+		condition, strconv.FormatFloat(*threshold, 'g', -1, 64),
+	)
+	if err != nil {
+		return loki.Entry{}, err
+	}
+
+	ls := c.labels.Clone()
+	ls["rule"] = model.LabelValue(s.rule.Name)
+
+	c.events.WithLabelValues(s.rule.Name, state).Inc()
+	return loki.Entry{
+		Labels: ls,
+		Entry: logproto.Entry{
+			Timestamp: time.UnixMilli(s.t),
+			Line:      string(line),
+		},
+	}, nil
+}
+
+// appender records the samples matching a rule, which are evaluated on
+// commit.
+type appender struct {
+	c       *Component
+	samples []sample
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	a.c.mut.RLock()
+	defer a.c.mut.RUnlock()
+
+	for _, r := range a.c.rules {
+		if r.matches(l) {
+			a.samples = append(a.samples, sample{rule: r, l: l, t: t, v: v})
+		}
+	}
+	return ref, nil
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+// AppendHistogram implements storage.Appender. Histograms aren't compared to
+// thresholds.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, _ labels.Labels, _ int64, _ *histogram.Histogram, _ *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+// Commit implements storage.Appender. The log entries are dropped if the
+// buffer of entries for the receivers is full, so that slow receivers don't
+// block the components sending metrics.
+func (a *appender) Commit() error {
+	entries := a.c.evaluate(a.samples, time.Now())
+	a.samples = nil
+
+	for _, entry := range entries {
+		select {
+		case a.c.entries <- entry:
+		case <-a.c.exited:
+			return fmt.Errorf("%s has exited", a.c.opts.ID)
+		default:
+			a.c.dropped.Inc()
+			level.Warn(a.c.opts.Logger).Log("msg", "dropped threshold crossing because the receivers are busy", "line", entry.Line)
+		}
+	}
+	return nil
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	a.samples = nil
+	return nil
+}
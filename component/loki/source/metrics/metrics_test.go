@@ -0,0 +1,317 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var exampleRiverConfig = `
+	forward_to = []
+	labels     = {source = "metrics"}
+
+	rule {
+		name     = "errors_high"
+		selector = "http_errors_total{job=\"api\"}"
+		above    = 100
+	}
+
+	rule {
+		name     = "up_low"
+		selector = "up"
+		below    = 1
+	}
+`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(exampleRiverConfig), &args))
+	require.Equal(t, map[string]string{"source": "metrics"}, args.Labels)
+	require.Len(t, args.Rules, 2)
+	require.Equal(t, 100.0, *args.Rules[0].Above)
+	require.Nil(t, args.Rules[0].Below)
+	require.Equal(t, 1.0, *args.Rules[1].Below)
+}
+
+func TestBadRiverConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "no rules",
+			config: `forward_to = []`,
+			err:    `missing required block "rule"`,
+		},
+		{
+			name: "duplicate rules",
+			config: `
+	forward_to = []
+	rule {
+		name     = "up"
+		selector = "up"
+		below    = 1
+	}
+	rule {
+		name     = "up"
+		selector = "up"
+		below    = 1
+	}`,
+			err: `rule "up" is configured more than once`,
+		},
+		{
+			name: "invalid selector",
+			config: `
+	forward_to = []
+	rule {
+		name     = "up"
+		selector = "up{"
+		below    = 1
+	}`,
+			err: `rule "up" has an invalid selector`,
+		},
+		{
+			name: "both thresholds",
+			config: `
+	forward_to = []
+	rule {
+		name     = "up"
+		selector = "up"
+		above    = 1
+		below    = 0
+	}`,
+			err: `rule "up" must configure exactly one of above & below`,
+		},
+		{
+			name: "no threshold",
+			config: `
+	forward_to = []
+	rule {
+		name     = "up"
+		selector = "up"
+	}`,
+			err: `rule "up" must configure exactly one of above & below`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			require.ErrorContains(t, river.Unmarshal([]byte(tc.config), &args), tc.err)
+		})
+	}
+}
+
+func TestThresholdCrossing(t *testing.T) {
+	sink := loki.NewLogsReceiver()
+	above := 100.0
+	c, reg := newTestComponent(t, Arguments{
+		ForwardTo: []loki.LogsReceiver{sink},
+		Labels:    map[string]string{"source": "metrics"},
+		Rules: []Rule{{
+			Name:     "errors_high",
+			Selector: `http_errors_total{job="api"}`,
+			Above:    &above,
+		}},
+	})
+
+	api := labels.FromStrings(labels.MetricName, "http_errors_total", "job", "api")
+	web := labels.FromStrings(labels.MetricName, "http_errors_total", "job", "web")
+
+	// Samples below the threshold and samples of other series are ignored.
+	appendSamples(t, c, api, 1000, 50, 99, 100)
+	appendSamples(t, c, web, 1000, 500)
+	requireNoEntry(t, sink)
+
+	// Crossing the threshold is reported once.
+	appendSamples(t, c, api, 2000, 150, 200)
+	requireEntry(t, sink, time.UnixMilli(2000),
+		`rule=errors_high state=firing metric=http_errors_total series="{__name__=\"http_errors_total\", job=\"api\"}" value=150 above=100`)
+	requireNoEntry(t, sink)
+
+	// Going back within the threshold is reported once.
+	appendSamples(t, c, api, 3000, 80, 90)
+	requireEntry(t, sink, time.UnixMilli(3000),
+		`rule=errors_high state=resolved metric=http_errors_total series="{__name__=\"http_errors_total\", job=\"api\"}" value=80 above=100`)
+	requireNoEntry(t, sink)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(c.events.WithLabelValues("errors_high", stateFiring)))
+	require.Equal(t, 1.0, testutil.ToFloat64(c.events.WithLabelValues("errors_high", stateResolved)))
+	count, err := testutil.GatherAndCount(reg, "loki_source_metrics_events_total")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestStaleSeries(t *testing.T) {
+	sink := loki.NewLogsReceiver()
+	below := 1.0
+	c, _ := newTestComponent(t, Arguments{
+		ForwardTo: []loki.LogsReceiver{sink},
+		Rules:     []Rule{{Name: "down", Selector: "up", Below: &below}},
+	})
+
+	up := labels.FromStrings(labels.MetricName, "up", "job", "api")
+	appendSamples(t, c, up, 1000, 1, 0)
+	requireEntry(t, sink, time.UnixMilli(1000),
+		`rule=down state=firing metric=up series="{__name__=\"up\", job=\"api\"}" value=0 below=1`)
+
+	// Stale series are forgotten without being resolved, and NaN samples
+	// are ignored.
+	appendSamples(t, c, up, 2000, math.Float64frombits(value.StaleNaN), math.NaN())
+	requireNoEntry(t, sink)
+
+	appendSamples(t, c, up, 3000, 0)
+	requireEntry(t, sink, time.UnixMilli(3000),
+		`rule=down state=firing metric=up series="{__name__=\"up\", job=\"api\"}" value=0 below=1`)
+}
+
+func TestExpiredSeries(t *testing.T) {
+	sink := loki.NewLogsReceiver()
+	below := 1.0
+	c, _ := newTestComponent(t, Arguments{
+		ForwardTo: []loki.LogsReceiver{sink},
+		Rules:     []Rule{{Name: "down", Selector: "up", Below: &below}},
+	})
+
+	up := labels.FromStrings(labels.MetricName, "up", "job", "api")
+	appendSamples(t, c, up, 1000, 0)
+	requireEntry(t, sink, time.UnixMilli(1000),
+		`rule=down state=firing metric=up series="{__name__=\"up\", job=\"api\"}" value=0 below=1`)
+
+	// Series with recent samples are kept.
+	c.expireSeries(time.Now().Add(seriesTimeout / 2))
+	appendSamples(t, c, up, 2000, 0)
+	requireNoEntry(t, sink)
+
+	// Series without samples for seriesTimeout are forgotten without being
+	// resolved, like stale series.
+	c.expireSeries(time.Now().Add(seriesTimeout))
+	require.Empty(t, c.rules[0].crossed)
+	appendSamples(t, c, up, 3000, 0)
+	requireEntry(t, sink, time.UnixMilli(3000),
+		`rule=down state=firing metric=up series="{__name__=\"up\", job=\"api\"}" value=0 below=1`)
+}
+
+func TestBusyReceivers(t *testing.T) {
+	// Nothing reads from the receiver, so the entries are dropped once the
+	// buffer is full.
+	sink := loki.NewLogsReceiver()
+	below := 1.0
+	c, _ := newTestComponent(t, Arguments{
+		ForwardTo: []loki.LogsReceiver{sink},
+		Rules:     []Rule{{Name: "down", Selector: "up", Below: &below}},
+	})
+
+	const series = entriesBuffer + 10
+	app := c.Appender(context.Background())
+	for i := 0; i < series; i++ {
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, "up", "instance", strconv.Itoa(i)), 1000, 0)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	// Commit doesn't block, and the entries beyond the buffer are dropped,
+	// except for the one the component may already be sending.
+	require.InDelta(t, series-entriesBuffer, testutil.ToFloat64(c.dropped), 1)
+	require.Equal(t, float64(series), testutil.ToFloat64(c.events.WithLabelValues("down", stateFiring)))
+}
+
+func TestUpdateKeepsState(t *testing.T) {
+	sink := loki.NewLogsReceiver()
+	below := 1.0
+	args := Arguments{
+		ForwardTo: []loki.LogsReceiver{sink},
+		Rules:     []Rule{{Name: "down", Selector: "up", Below: &below}},
+	}
+	c, _ := newTestComponent(t, args)
+
+	up := labels.FromStrings(labels.MetricName, "up", "job", "api")
+	appendSamples(t, c, up, 1000, 0)
+	requireEntry(t, sink, time.UnixMilli(1000),
+		`rule=down state=firing metric=up series="{__name__=\"up\", job=\"api\"}" value=0 below=1`)
+
+	// Unchanged rules aren't reported again.
+	args.Labels = map[string]string{"source": "metrics"}
+	require.NoError(t, c.Update(args))
+	appendSamples(t, c, up, 2000, 0)
+	requireNoEntry(t, sink)
+
+	// Changed rules start over.
+	lower := 0.5
+	args.Rules = []Rule{{Name: "down", Selector: "up", Below: &lower}}
+	require.NoError(t, c.Update(args))
+	appendSamples(t, c, up, 3000, 0)
+	entry := requireEntry(t, sink, time.UnixMilli(3000),
+		`rule=down state=firing metric=up series="{__name__=\"up\", job=\"api\"}" value=0 below=0.5`)
+	require.Equal(t, model.LabelSet{"source": "metrics", "rule": "down"}, entry.Labels)
+}
+
+func newTestComponent(t *testing.T, args Arguments) (*Component, *prometheus.Registry) {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "loki.source.metrics.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = c.Run(ctx) }()
+	return c, reg
+}
+
+// appendSamples appends and commits samples of the series l at the
+// timestamp t.
+func appendSamples(t *testing.T, c *Component, l labels.Labels, ts int64, values ...float64) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	app := c.Appender(ctx)
+	for _, v := range values {
+		_, err := app.Append(0, l, ts, v)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
+
+func requireEntry(t *testing.T, sink loki.LogsReceiver, ts time.Time, line string) loki.Entry {
+	t.Helper()
+
+	select {
+	case entry := <-sink.Chan():
+		require.Equal(t, line, entry.Line)
+		require.True(t, ts.Equal(entry.Timestamp), "unexpected timestamp %s", entry.Timestamp)
+		return entry
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for log entry")
+		return loki.Entry{}
+	}
+}
+
+func requireNoEntry(t *testing.T, sink loki.LogsReceiver) {
+	t.Helper()
+
+	select {
+	case entry := <-sink.Chan():
+		require.FailNow(t, "unexpected log entry", entry.Line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
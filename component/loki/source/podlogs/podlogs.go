@@ -17,6 +17,7 @@ import (
 	"github.com/grafana/agent/component/common/loki/positions"
 	"github.com/grafana/agent/component/loki/source/kubernetes"
 	"github.com/grafana/agent/component/loki/source/kubernetes/kubetail"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/service/cluster"
 	"github.com/oklog/run"
@@ -26,8 +27,9 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name: "loki.source.podlogs",
-		Args: Arguments{},
+		Name:      "loki.source.podlogs",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
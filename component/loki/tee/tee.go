@@ -0,0 +1,152 @@
+// Package tee provides the loki.tee component.
+package tee
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.tee",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.tee component.
+type Arguments struct {
+	// Where every received log entry is forwarded to.
+	ForwardTo []loki.LogsReceiver `river:"forward_to,attr"`
+	// Where the log entries of a fraction of the streams are additionally
+	// forwarded to.
+	TeeTo []loki.LogsReceiver `river:"tee_to,attr"`
+	// Percentage of streams forwarded to TeeTo.
+	Percentage float64 `river:"percentage,attr"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Percentage < 0 || args.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, got %v", args.Percentage)
+	}
+	return nil
+}
+
+// Exports holds the values exported by the loki.tee component.
+type Exports struct {
+	Receiver loki.LogsReceiver `river:"receiver,attr"`
+}
+
+// Component implements the loki.tee component.
+type Component struct {
+	opts     component.Options
+	receiver loki.LogsReceiver
+	dropped  prometheus.Counter
+
+	mut       sync.RWMutex
+	forwardTo []loki.LogsReceiver
+	teeTo     []loki.LogsReceiver
+	threshold uint64
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new loki.tee component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:     o,
+		receiver: loki.NewLogsReceiver(),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_tee_dropped_entries_total",
+			Help: "Total number of log entries which weren't forwarded to tee_to because its receivers were busy.",
+		}),
+	}
+	if err := o.Registerer.Register(c.dropped); err != nil {
+		return nil, err
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.mut.RLock()
+			forwardTo, teeTo := c.forwardTo, c.teeTo
+			teed := c.teed(entry.Labels)
+			c.mut.RUnlock()
+
+			// The entry is only sent to the tee_to receivers which are ready,
+			// so that a slow secondary pipeline doesn't affect the primary one.
+			if teed {
+				for _, r := range teeTo {
+					select {
+					case r.Chan() <- entry:
+					default:
+						c.dropped.Inc()
+					}
+				}
+			}
+			for _, r := range forwardTo {
+				select {
+				case <-ctx.Done():
+					return nil
+				case r.Chan() <- entry:
+				}
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if newArgs.Percentage >= 100 {
+		c.threshold = math.MaxUint64
+	} else {
+		c.threshold = uint64(newArgs.Percentage / 100 * math.MaxUint64)
+	}
+	c.forwardTo = newArgs.ForwardTo
+	c.teeTo = newArgs.TeeTo
+	return nil
+}
+
+// teed reports whether the stream identified by ls is forwarded to tee_to.
+// The decision is based on the fingerprint of the labels, so that a stream is
+// always either forwarded or not. It must be called with mut held.
+func (c *Component) teed(ls model.LabelSet) bool {
+	if c.threshold == 0 {
+		return false
+	}
+	return uint64(ls.Fingerprint()) <= c.threshold
+}
@@ -0,0 +1,95 @@
+package tee
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to = []
+	tee_to     = []
+	percentage = 10
+`), &args))
+	require.Equal(t, 10.0, args.Percentage)
+
+	err := river.Unmarshal([]byte(`
+	forward_to = []
+	tee_to     = []
+	percentage = -1
+`), &args)
+	require.ErrorContains(t, err, "percentage must be between 0 and 100")
+}
+
+func TestTeesFraction(t *testing.T) {
+	const streams = 2000
+
+	// The tee_to receiver can hold every entry, so that none is dropped.
+	primary := loki.NewLogsReceiver()
+	secondary := loki.NewLogsReceiverWithChannel(make(chan loki.Entry, 2*streams))
+	c, err := New(component.Options{
+		ID:            "loki.tee.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prometheus.NewRegistry(),
+	}, Arguments{
+		ForwardTo:  []loki.LogsReceiver{primary},
+		TeeTo:      []loki.LogsReceiver{secondary},
+		Percentage: 10,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	// sendStreams sends an entry for every stream, and returns the streams
+	// received by the tee_to receiver.
+	sendStreams := func() map[string]struct{} {
+		go func() {
+			for i := 0; i < streams; i++ {
+				c.receiver.Chan() <- loki.Entry{
+					Labels: model.LabelSet{"job": "test", "stream": model.LabelValue(strconv.Itoa(i))},
+					Entry:  logproto.Entry{Timestamp: time.Now(), Line: "line"},
+				}
+			}
+		}()
+		for i := 0; i < streams; i++ {
+			select {
+			case <-primary.Chan():
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "entries never reached forward_to", "got %d", i)
+			}
+		}
+
+		teed := make(map[string]struct{})
+		for {
+			select {
+			case entry := <-secondary.Chan():
+				teed[entry.Labels.String()] = struct{}{}
+			default:
+				return teed
+			}
+		}
+	}
+
+	teed := sendStreams()
+	require.InDelta(t, streams/10, len(teed), streams/100*3, "unexpected number of teed streams")
+
+	// The same streams are teed every time.
+	require.Equal(t, teed, sendStreams())
+	require.Zero(t, testutil.ToFloat64(c.dropped))
+}
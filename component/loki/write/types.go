@@ -13,6 +13,7 @@ import (
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/flagext"
 	lokiflagext "github.com/grafana/loki/pkg/util/flagext"
+	"golang.org/x/exp/slices"
 )
 
 // EndpointOptions describes an individual location to send logs to.
@@ -28,6 +29,9 @@ type EndpointOptions struct {
 	MaxBackoffRetries int                     `river:"max_backoff_retries,attr,optional"` // give up after this many; zero means infinite retries
 	TenantID          string                  `river:"tenant_id,attr,optional"`
 	RetryOnHTTP429    bool                    `river:"retry_on_http_429,attr,optional"`
+	Encoding          string                  `river:"encoding,attr,optional"`
+	MaxAge            time.Duration           `river:"max_age,attr,optional"`
+	FutureTolerance   time.Duration           `river:"future_tolerance,attr,optional"`
 	HTTPClientConfig  *types.HTTPClientConfig `river:",squash"`
 	QueueConfig       QueueConfig             `river:"queue_config,block,optional"`
 }
@@ -47,6 +51,8 @@ func GetDefaultEndpointOptions() EndpointOptions {
 		MaxBackoffRetries: 10,
 		HTTPClientConfig:  types.CloneDefaultHTTPClientConfig(),
 		RetryOnHTTP429:    true,
+		Encoding:          client.EncodingSnappy,
+		FutureTolerance:   client.FutureTolerance,
 	}
 
 	return defaultEndpointOptions
@@ -62,6 +68,15 @@ func (r *EndpointOptions) Validate() error {
 	if _, err := url.Parse(r.URL); err != nil {
 		return fmt.Errorf("failed to parse remote url %q: %w", r.URL, err)
 	}
+	if !slices.Contains(client.Encodings, r.Encoding) {
+		return fmt.Errorf("encoding must be one of %q, got %q", client.Encodings, r.Encoding)
+	}
+	if r.MaxAge < 0 {
+		return fmt.Errorf("max_age must not be negative")
+	}
+	if r.FutureTolerance < 0 {
+		return fmt.Errorf("future_tolerance must not be negative")
+	}
 
 	// We must explicitly Validate because HTTPClientConfig is squashed and it won't run otherwise
 	if r.HTTPClientConfig != nil {
@@ -106,6 +121,9 @@ func (args Arguments) convertClientConfigs() []client.Config {
 			Timeout:                cfg.RemoteTimeout,
 			TenantID:               cfg.TenantID,
 			DropRateLimitedBatches: !cfg.RetryOnHTTP429,
+			Encoding:               cfg.Encoding,
+			MaxAge:                 cfg.MaxAge,
+			FutureTolerance:        cfg.FutureTolerance,
 			Queue: client.QueueConfig{
 				Capacity:     int(cfg.QueueConfig.Capacity),
 				DrainTimeout: cfg.QueueConfig.DrainTimeout,
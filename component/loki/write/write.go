@@ -12,13 +12,16 @@ import (
 	"github.com/grafana/agent/component/common/loki/client"
 	"github.com/grafana/agent/component/common/loki/limit"
 	"github.com/grafana/agent/component/common/loki/wal"
+	"github.com/grafana/agent/component/common/throughput"
+	"github.com/grafana/agent/internal/featuregate"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "loki.write",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "loki.write",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -68,17 +71,20 @@ type Exports struct {
 }
 
 var (
-	_ component.Component = (*Component)(nil)
+	_ component.Component          = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
 )
 
 // Component implements the loki.write component.
 type Component struct {
-	opts    component.Options
-	metrics *client.Metrics
+	opts       component.Options
+	metrics    *client.Metrics
+	throughput *throughput.Metrics
 
 	mut      sync.RWMutex
 	args     Arguments
 	receiver loki.LogsReceiver
+	drain    chan drainRequest
 
 	// remote write components
 	clientManger client.Client
@@ -89,11 +95,24 @@ type Component struct {
 	sink loki.EntryHandler
 }
 
+// drainRequest is a request to drain the clients of the component.
+type drainRequest struct {
+	ctx  context.Context
+	done chan error
+}
+
 // New creates a new loki.write component.
 func New(o component.Options, args Arguments) (*Component, error) {
 	c := &Component{
 		opts:    o,
 		metrics: client.NewMetrics(o.Registerer),
+		drain:   make(chan drainRequest),
+	}
+
+	var err error
+	c.throughput, err = throughput.New(o.Registerer, throughput.SignalLogs, true)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create and immediately export the receiver which remains the same for
@@ -122,12 +141,36 @@ func (c *Component) Run(ctx context.Context) error {
 				c.mut.RUnlock()
 				return nil
 			case c.sink.Chan() <- entry:
+				c.throughput.Observe(1, len(entry.Line))
 			}
 			c.mut.RUnlock()
+		case req := <-c.drain:
+			// Draining from the same goroutine ensures that the entries received
+			// before are in the pending batches of the clients.
+			c.mut.RLock()
+			req.done <- c.clientManger.Drain(req.ctx)
+			c.mut.RUnlock()
 		}
 	}
 }
 
+// Drain implements component.DrainableComponent. It sends the batches of log
+// entries which are pending in the clients.
+func (c *Component) Drain(ctx context.Context) error {
+	req := drainRequest{ctx: ctx, done: make(chan error, 1)}
+	select {
+	case c.drain <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Update implements component.Component.
 func (c *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
@@ -1,15 +1,21 @@
 package write
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/common/loki"
 	"github.com/grafana/agent/component/common/loki/wal"
 	"github.com/grafana/agent/component/discovery"
@@ -17,10 +23,12 @@ import (
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
+	"github.com/grafana/loki/pkg/loghttp/push"
 	"github.com/grafana/loki/pkg/logproto"
 	loki_util "github.com/grafana/loki/pkg/util"
 )
@@ -197,6 +205,258 @@ func testSingleEndpoint(t *testing.T, alterConfig func(arguments *Arguments)) {
 	}
 }
 
+// TestEncoding ensures that push requests are sent with the configured
+// encoding, and can be decoded by Loki.
+func TestEncoding(t *testing.T) {
+	tt := []struct {
+		encoding        string
+		contentType     string
+		contentEncoding string
+	}{
+		{encoding: "snappy", contentType: "application/x-protobuf"},
+		{encoding: "gzip", contentType: "application/json", contentEncoding: "gzip"},
+		{encoding: "none", contentType: "application/json"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.encoding, func(t *testing.T) {
+			receiver, requests := newFakeLoki(t)
+			exports, reg := startWrite(t, fmt.Sprintf(`
+				endpoint {
+					url        = "%s/loki/api/v1/push"
+					batch_wait = "10ms"
+					encoding   = %q
+				}
+			`, receiver.URL, tc.encoding))
+
+			entry := loki.Entry{
+				Labels: model.LabelSet{"foo": "bar"},
+				Entry: logproto.Entry{
+					Timestamp: time.Unix(0, 1700000000123456789),
+					Line:      "very important log",
+				},
+			}
+			exports.Receiver.Chan() <- entry
+
+			req := waitPushRequest(t, requests)
+			require.Equal(t, tc.contentType, req.contentType)
+			require.Equal(t, tc.contentEncoding, req.contentEncoding)
+			require.Len(t, req.Streams, 1)
+			require.Equal(t, `{foo="bar"}`, req.Streams[0].Labels)
+			require.Len(t, req.Streams[0].Entries, 1)
+			require.True(t, entry.Timestamp.Equal(req.Streams[0].Entries[0].Timestamp))
+			require.Equal(t, entry.Line, req.Streams[0].Entries[0].Line)
+
+			host := strings.TrimPrefix(receiver.URL, "http://")
+			require.Eventually(t, func() bool {
+				return counterValue(reg, "loki_write_sent_bytes_total", map[string]string{"host": host, "encoding": tc.encoding}) == float64(req.bodySize)
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+// TestBatchBoundaries ensures that batches are sent once the next entry would
+// make them exceed batch_size, or once they're older than batch_wait.
+func TestBatchBoundaries(t *testing.T) {
+	line := strings.Repeat("x", 60)
+	newEntry := func() loki.Entry {
+		return loki.Entry{
+			Labels: model.LabelSet{"foo": "bar"},
+			Entry:  logproto.Entry{Timestamp: time.Now(), Line: line},
+		}
+	}
+
+	t.Run("size", func(t *testing.T) {
+		receiver, requests := newFakeLoki(t)
+		exports, _ := startWrite(t, fmt.Sprintf(`
+			endpoint {
+				url        = "%s"
+				batch_wait = "1h"
+				batch_size = "100B"
+			}
+		`, receiver.URL))
+
+		// The second entry doesn't fit in the batch of the first, so the batch
+		// of the first is sent. The batch of the last entry is only sent once
+		// it's older than batch_wait.
+		for i := 0; i < 3; i++ {
+			exports.Receiver.Chan() <- newEntry()
+		}
+		for i := 0; i < 2; i++ {
+			req := waitPushRequest(t, requests)
+			require.Len(t, req.Streams, 1)
+			require.Len(t, req.Streams[0].Entries, 1)
+		}
+		select {
+		case <-requests:
+			require.FailNow(t, "batch was sent before it was full or old enough")
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+
+	t.Run("age", func(t *testing.T) {
+		receiver, requests := newFakeLoki(t)
+		exports, _ := startWrite(t, fmt.Sprintf(`
+			endpoint {
+				url        = "%s"
+				batch_wait = "200ms"
+				batch_size = "1MiB"
+			}
+		`, receiver.URL))
+
+		start := time.Now()
+		exports.Receiver.Chan() <- newEntry()
+		exports.Receiver.Chan() <- newEntry()
+
+		req := waitPushRequest(t, requests)
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		require.Len(t, req.Streams, 1)
+		require.Len(t, req.Streams[0].Entries, 2)
+	})
+}
+
+// TestMaxAge ensures that entries older than max_age, or further in the future
+// than future_tolerance, are dropped and counted, while the others are sent.
+func TestMaxAge(t *testing.T) {
+	receiver, requests := newFakeLoki(t)
+	exports, reg := startWrite(t, fmt.Sprintf(`
+		endpoint {
+			url              = "%s"
+			batch_wait       = "100ms"
+			max_age          = "1h"
+			future_tolerance = "5m"
+		}
+	`, receiver.URL))
+
+	now := time.Now()
+	for _, tc := range []struct {
+		line string
+		ts   time.Time
+	}{
+		{line: "too old", ts: now.Add(-2 * time.Hour)},
+		{line: "recent", ts: now.Add(-30 * time.Minute)},
+		{line: "skewed", ts: now.Add(time.Minute)},
+		{line: "too far in future", ts: now.Add(time.Hour)},
+	} {
+		exports.Receiver.Chan() <- loki.Entry{
+			Labels: model.LabelSet{"foo": "bar"},
+			Entry:  logproto.Entry{Timestamp: tc.ts, Line: tc.line},
+		}
+	}
+
+	req := waitPushRequest(t, requests)
+	require.Len(t, req.Streams, 1)
+	var lines []string
+	for _, e := range req.Streams[0].Entries {
+		lines = append(lines, e.Line)
+	}
+	require.Equal(t, []string{"recent", "skewed"}, lines)
+
+	host := strings.TrimPrefix(receiver.URL, "http://")
+	require.Equal(t, 1.0, counterValue(reg, "loki_write_dropped_entries_total", map[string]string{"host": host, "reason": "too_old"}))
+	require.Equal(t, 1.0, counterValue(reg, "loki_write_dropped_entries_total", map[string]string{"host": host, "reason": "too_far_in_future"}))
+	require.Equal(t, float64(len("too old")), counterValue(reg, "loki_write_dropped_bytes_total", map[string]string{"host": host, "reason": "too_old"}))
+}
+
+func TestBadMaxAge(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+	endpoint {
+		url     = "http://0.0.0.0:11111/loki/api/v1/push"
+		max_age = "-1h"
+	}
+	`), &args)
+	require.ErrorContains(t, err, "max_age must not be negative")
+}
+
+// receivedPushRequest is a push request received by the fake Loki, along with
+// how it was encoded.
+type receivedPushRequest struct {
+	*logproto.PushRequest
+	contentType     string
+	contentEncoding string
+	bodySize        int
+}
+
+// newFakeLoki starts a server decoding push requests like Loki does, and
+// exposing them on the returned channel.
+func newFakeLoki(t *testing.T) (*httptest.Server, chan receivedPushRequest) {
+	requests := make(chan receivedPushRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		req, err := push.ParseRequest(util.TestLogger(t), "", r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- receivedPushRequest{
+			PushRequest:     req,
+			contentType:     r.Header.Get("Content-Type"),
+			contentEncoding: r.Header.Get("Content-Encoding"),
+			bodySize:        len(body),
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+// counterValue returns the value of the counter name of reg with the labels
+// lbls, or -1 if there is none.
+func counterValue(reg *prometheus.Registry, name string, lbls map[string]string) float64 {
+	families, _ := reg.Gather()
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if v, ok := lbls[l.GetName()]; ok && v != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return -1
+}
+
+func startWrite(t *testing.T, config string) (Exports, *prometheus.Registry) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(config), &args))
+
+	reg := prometheus.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "loki.write.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = c.Run(ctx) }()
+	return Exports{Receiver: c.receiver}, reg
+}
+
+func waitPushRequest(t *testing.T, requests chan receivedPushRequest) receivedPushRequest {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for logs")
+		return receivedPushRequest{}
+	}
+}
+
 func TestEntrySentToTwoWriteComponents(t *testing.T) {
 	t.Run("wal disabled", func(t *testing.T) {
 		testMultipleEndpoint(t, func(arguments *Arguments) {})
@@ -306,6 +566,154 @@ func testMultipleEndpoint(t *testing.T, alterArgs func(arguments *Arguments)) {
 	}
 }
 
+func TestBackpressure(t *testing.T) {
+	const (
+		linesCount = 1000
+		batchSize  = 100
+	)
+
+	// Set up a Loki server which doesn't answer push requests until release is
+	// closed, simulating a slow or unavailable downstream.
+	var (
+		mut      sync.Mutex
+		received []string
+		release  = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushReq logproto.PushRequest
+		if err := loki_util.ParseProtoReader(context.Background(), r.Body, int(r.ContentLength), math.MaxInt32, &pushReq, loki_util.RawSnappy); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		<-release
+
+		mut.Lock()
+		defer mut.Unlock()
+		for _, stream := range pushReq.Streams {
+			for _, entry := range stream.Entries {
+				received = append(received, entry.Line)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	var releaseOnce sync.Once
+	releaseServer := func() { releaseOnce.Do(func() { close(release) }) }
+	defer releaseServer()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(componenttest.TestContext(t))
+	defer cancel()
+
+	writeReg := prometheus.NewRegistry()
+	var writeArgs Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		endpoint {
+			url        = "%s"
+			batch_wait = "10ms"
+			batch_size = "%dB"
+		}
+	`, srv.URL, batchSize)), &writeArgs))
+
+	var writeExports Exports
+	writeComp, err := New(component.Options{
+		Logger:        util.TestLogger(t),
+		Registerer:    writeReg,
+		OnStateChange: func(e component.Exports) { writeExports = e.(Exports) },
+		DataPath:      t.TempDir(),
+	}, writeArgs)
+	require.NoError(t, err)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, writeComp.Run(ctx))
+	}()
+
+	// Write all lines to the file before it starts being tailed.
+	var content strings.Builder
+	for i := 0; i < linesCount; i++ {
+		fmt.Fprintf(&content, "line %04d\n", i)
+	}
+	lineLength := len("line 0000")
+	filename := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(filename, []byte(content.String()), 0644))
+
+	sourceReg := prometheus.NewRegistry()
+	sourceArgs := lsf.DefaultArguments
+	sourceArgs.Targets = []discovery.Target{{"__path__": filename}}
+	sourceArgs.ForwardTo = []loki.LogsReceiver{writeExports.Receiver}
+	sourceComp, err := lsf.New(component.Options{
+		Logger:        util.TestLogger(t),
+		Registerer:    sourceReg,
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}, sourceArgs)
+	require.NoError(t, err)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, sourceComp.Run(ctx))
+	}()
+
+	pendingEntries := func() float64 {
+		return metricValue(t, writeReg, "loki_write_pending_entries")
+	}
+	readLines := func() float64 {
+		return metricValue(t, sourceReg, "loki_source_file_read_lines_total")
+	}
+
+	// Wait for the first batch to be stuck on being sent, and for the
+	// backpressure to reach the tailer.
+	require.Eventually(t, func() bool { return pendingEntries() > 0 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+
+	// Only one batch worth of entries is held in memory, and tailing stops
+	// shortly after, rather than the whole file being read.
+	maxPending := float64(batchSize/lineLength + 1)
+	require.LessOrEqual(t, pendingEntries(), maxPending)
+	require.Less(t, readLines(), float64(linesCount/10))
+
+	releaseServer()
+
+	// Once the server recovers, every line is received exactly once and in
+	// order.
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(received) >= linesCount
+	}, 10*time.Second, 10*time.Millisecond)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Len(t, received, linesCount)
+	for i, line := range received {
+		require.Equal(t, fmt.Sprintf("line %04d", i), line)
+	}
+	require.Eventually(t, func() bool { return pendingEntries() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+// metricValue returns the sum of the values of the gauge or counter with the
+// given name gathered from reg.
+func metricValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	var sum float64
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			sum += m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
+
 type testCase struct {
 	linesCount  int
 	seriesCount int
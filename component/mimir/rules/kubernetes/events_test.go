@@ -11,6 +11,7 @@ import (
 	mimirClient "github.com/grafana/agent/pkg/mimir/client"
 	v1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	promListers "github.com/prometheus-operator/prometheus-operator/pkg/client/listers/monitoring/v1"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -183,3 +184,33 @@ func TestEventLoop(t *testing.T) {
 		return len(rules) == 0
 	}, time.Second, 10*time.Millisecond)
 }
+
+func TestConvertCRDRuleGroup_KeepFiringFor(t *testing.T) {
+	var (
+		forDuration   = v1.Duration("1m")
+		keepFiringFor = v1.NonEmptyDuration("5m")
+	)
+	crd := v1.PrometheusRuleSpec{
+		Groups: []v1.RuleGroup{{
+			Name: "alerts",
+			Rules: []v1.Rule{{
+				Alert:         "HighErrorRate",
+				Expr:          intstr.FromString("rate(errors_total[5m]) > 1"),
+				For:           &forDuration,
+				KeepFiringFor: &keepFiringFor,
+			}},
+		}},
+	}
+
+	groups, err := convertCRDRuleGroupToRuleGroup(crd)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0].Rules, 1)
+	require.Equal(t, model.Duration(5*time.Minute), groups[0].Rules[0].KeepFiringFor)
+
+	// Changing keep_firing_for must be synced to Mimir.
+	crd.Groups[0].Rules[0].KeepFiringFor = nil
+	withoutKeepFiringFor, err := convertCRDRuleGroupToRuleGroup(crd)
+	require.NoError(t, err)
+	require.False(t, equalRuleGroups(groups[0], withoutKeepFiringFor[0]))
+}
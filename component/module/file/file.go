@@ -9,14 +9,16 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/local/file"
 	"github.com/grafana/agent/component/module"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river/rivertypes"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "module.file",
-		Args:    Arguments{},
-		Exports: module.Exports{},
+		Name:      "module.file",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   module.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -52,8 +54,9 @@ type Component struct {
 }
 
 var (
-	_ component.Component       = (*Component)(nil)
-	_ component.HealthComponent = (*Component)(nil)
+	_ component.Component          = (*Component)(nil)
+	_ component.HealthComponent    = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
 )
 
 // New creates a new module.file component.
@@ -138,6 +141,11 @@ func (c *Component) Update(args component.Arguments) error {
 	return c.mod.LoadFlowSource(newArgs.Arguments, c.getContent().Value)
 }
 
+// Drain implements component.DrainableComponent.
+func (c *Component) Drain(ctx context.Context) error {
+	return c.mod.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (c *Component) CurrentHealth() component.Health {
 	leastHealthy := component.LeastHealthy(
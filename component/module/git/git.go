@@ -13,14 +13,16 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/module"
 	"github.com/grafana/agent/component/module/git/internal/vcs"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "module.git",
-		Args:    Arguments{},
-		Exports: module.Exports{},
+		Name:      "module.git",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   module.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -68,8 +70,9 @@ type Component struct {
 }
 
 var (
-	_ component.Component       = (*Component)(nil)
-	_ component.HealthComponent = (*Component)(nil)
+	_ component.Component          = (*Component)(nil)
+	_ component.HealthComponent    = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
 )
 
 // New creates a new module.git component.
@@ -242,6 +245,11 @@ func (c *Component) pollFile(ctx context.Context, args Arguments) error {
 	return c.mod.LoadFlowSource(args.Arguments, string(bb))
 }
 
+// Drain implements component.DrainableComponent.
+func (c *Component) Drain(ctx context.Context) error {
+	return c.mod.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (c *Component) CurrentHealth() component.Health {
 	c.healthMut.RLock()
@@ -9,14 +9,16 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/module"
 	remote_http "github.com/grafana/agent/component/remote/http"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river/rivertypes"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "module.http",
-		Args:    Arguments{},
-		Exports: module.Exports{},
+		Name:      "module.http",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   module.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -51,8 +53,9 @@ type Component struct {
 }
 
 var (
-	_ component.Component       = (*Component)(nil)
-	_ component.HealthComponent = (*Component)(nil)
+	_ component.Component          = (*Component)(nil)
+	_ component.HealthComponent    = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
 )
 
 // New creates a new module.http component.
@@ -137,6 +140,11 @@ func (c *Component) Update(args component.Arguments) error {
 	return c.mod.LoadFlowSource(newArgs.Arguments, c.getContent().Value)
 }
 
+// Drain implements component.DrainableComponent.
+func (c *Component) Drain(ctx context.Context) error {
+	return c.mod.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (c *Component) CurrentHealth() component.Health {
 	leastHealthy := component.LeastHealthy(
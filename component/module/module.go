@@ -78,6 +78,11 @@ func (c *ModuleComponent) RunFlowController(ctx context.Context) {
 	}
 }
 
+// Drain contains the implementation details for Drain in a module component.
+func (c *ModuleComponent) Drain(ctx context.Context) error {
+	return c.mod.Drain(ctx)
+}
+
 // CurrentHealth contains the implementation details for CurrentHealth in a module component.
 func (c *ModuleComponent) CurrentHealth() component.Health {
 	c.mut.RLock()
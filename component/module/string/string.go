@@ -5,14 +5,16 @@ import (
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/module"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river/rivertypes"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "module.string",
-		Args:    Arguments{},
-		Exports: module.Exports{},
+		Name:      "module.string",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   module.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -36,8 +38,9 @@ type Component struct {
 }
 
 var (
-	_ component.Component       = (*Component)(nil)
-	_ component.HealthComponent = (*Component)(nil)
+	_ component.Component          = (*Component)(nil)
+	_ component.HealthComponent    = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
 )
 
 // New creates a new module.string component.
@@ -69,6 +72,11 @@ func (c *Component) Update(args component.Arguments) error {
 	return c.mod.LoadFlowSource(newArgs.Arguments, newArgs.Content.Value)
 }
 
+// Drain implements component.DrainableComponent.
+func (c *Component) Drain(ctx context.Context) error {
+	return c.mod.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (c *Component) CurrentHealth() component.Health {
 	return c.mod.CurrentHealth()
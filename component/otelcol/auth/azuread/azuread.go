@@ -0,0 +1,61 @@
+// Package azuread provides an otelcol.auth.azuread component.
+package azuread
+
+import (
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/component/otelcol/auth"
+	"github.com/grafana/agent/internal/featuregate"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.auth.azuread",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   auth.Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			fact := auth.NewTokenSourceFactory("azuread")
+			return auth.New(opts, fact, args.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the otelcol.auth.azuread component.
+type Arguments struct {
+	AzureAD config.AzureADConfig `river:",squash"`
+}
+
+var _ auth.Arguments = Arguments{}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	args.AzureAD.SetToDefault()
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	return args.AzureAD.Validate()
+}
+
+// Convert implements auth.Arguments.
+func (args Arguments) Convert() (otelcomponent.Config, error) {
+	ts, err := args.AzureAD.TokenSource()
+	if err != nil {
+		return nil, err
+	}
+	return &auth.TokenSourceConfig{TokenSource: ts}, nil
+}
+
+// Extensions implements auth.Arguments.
+func (args Arguments) Extensions() map[otelcomponent.ID]otelextension.Extension {
+	return nil
+}
+
+// Exporters implements auth.Arguments.
+func (args Arguments) Exporters() map[otelcomponent.DataType]map[otelcomponent.ID]otelcomponent.Component {
+	return nil
+}
@@ -0,0 +1,97 @@
+package azuread_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol/auth"
+	"github.com/grafana/agent/component/otelcol/auth/azuread"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+	extauth "go.opentelemetry.io/collector/extension/auth"
+)
+
+// Test performs a basic integration test which runs the otelcol.auth.azuread
+// component and ensures that requests carry access tokens obtained with the
+// managed identity.
+func Test(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-IDENTITY-HEADER") != "fake-identity-header" {
+			http.Error(w, "invalid identity header", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "azure-token", "token_type": "Bearer", "expires_on": "%d"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer tokenSrv.Close()
+
+	// Fake the managed identity endpoint of Azure App Service.
+	t.Setenv("IDENTITY_ENDPOINT", tokenSrv.URL)
+	t.Setenv("IDENTITY_HEADER", "fake-identity-header")
+
+	authorizations := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ctx := componenttest.TestContext(t)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	// Create and run our component
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.auth.azuread")
+	require.NoError(t, err)
+
+	var args azuread.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		managed_identity {
+			client_id = "00000000-0000-0000-0000-000000000000"
+		}
+	`), &args))
+
+	go func() {
+		err := ctrl.Run(ctx, args)
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Second), "component never started")
+	require.NoError(t, ctrl.WaitExports(time.Second), "component never exported anything")
+
+	// Get the authentication extension from our component and use it to make a
+	// request to our test server.
+	exports := ctrl.Exports().(auth.Exports)
+	require.NotNil(t, exports.Handler.Extension, "handler extension is nil")
+
+	clientAuth, ok := exports.Handler.Extension.(extauth.Client)
+	require.True(t, ok, "handler does not implement configauth.ClientAuthenticator")
+
+	rt, err := clientAuth.RoundTripper(http.DefaultTransport)
+	require.NoError(t, err)
+	cli := &http.Client{Transport: rt}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := cli.Do(req)
+	require.NoError(t, err, "HTTP request failed")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer azure-token", <-authorizations)
+}
+
+func TestBadArguments(t *testing.T) {
+	var args azuread.Arguments
+	err := river.Unmarshal([]byte(`
+		managed_identity {
+			client_id = "bad_client_id"
+		}
+	`), &args)
+	require.EqualError(t, err, "the provided Azure Managed Identity client_id provided is invalid")
+}
@@ -0,0 +1,63 @@
+// Package google provides an otelcol.auth.google component.
+package google
+
+import (
+	"context"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/component/otelcol/auth"
+	"github.com/grafana/agent/internal/featuregate"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.auth.google",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   auth.Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			fact := auth.NewTokenSourceFactory("google")
+			return auth.New(opts, fact, args.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the otelcol.auth.google component.
+type Arguments struct {
+	Credentials config.GoogleCredentials `river:",squash"`
+}
+
+var _ auth.Arguments = Arguments{}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	args.Credentials.SetToDefault()
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	return args.Credentials.Validate()
+}
+
+// Convert implements auth.Arguments.
+func (args Arguments) Convert() (otelcomponent.Config, error) {
+	ts, err := args.Credentials.TokenSource(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &auth.TokenSourceConfig{TokenSource: ts}, nil
+}
+
+// Extensions implements auth.Arguments.
+func (args Arguments) Extensions() map[otelcomponent.ID]otelextension.Extension {
+	return nil
+}
+
+// Exporters implements auth.Arguments.
+func (args Arguments) Exporters() map[otelcomponent.DataType]map[otelcomponent.ID]otelcomponent.Component {
+	return nil
+}
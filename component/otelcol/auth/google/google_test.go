@@ -0,0 +1,98 @@
+package google_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol/auth"
+	"github.com/grafana/agent/component/otelcol/auth/google"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+	extauth "go.opentelemetry.io/collector/extension/auth"
+	"go.uber.org/atomic"
+)
+
+// Test performs a basic integration test which runs the otelcol.auth.google
+// component and ensures that requests carry access tokens obtained with the
+// Google credentials, refreshed before they expire.
+func Test(t *testing.T) {
+	var tokens atomic.Int64
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// The token expires within the early refresh window of the token
+		// source, so a new token is requested for each request.
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 5}`, tokens.Inc())
+	}))
+	defer tokenSrv.Close()
+
+	creds := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(creds, []byte(fmt.Sprintf(`{
+		"type": "authorized_user",
+		"client_id": "fake-client-id",
+		"client_secret": "fake-client-secret",
+		"refresh_token": "fake-refresh-token",
+		"token_uri": "%s/token"
+	}`, tokenSrv.URL)), 0600))
+
+	authorizations := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ctx := componenttest.TestContext(t)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	// Create and run our component
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.auth.google")
+	require.NoError(t, err)
+
+	var args google.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`credentials_file = %q`, creds)), &args))
+
+	go func() {
+		err := ctrl.Run(ctx, args)
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Second), "component never started")
+	require.NoError(t, ctrl.WaitExports(time.Second), "component never exported anything")
+
+	// Get the authentication extension from our component and use it to make
+	// requests to our test server.
+	exports := ctrl.Exports().(auth.Exports)
+	require.NotNil(t, exports.Handler.Extension, "handler extension is nil")
+
+	clientAuth, ok := exports.Handler.Extension.(extauth.Client)
+	require.True(t, ok, "handler does not implement configauth.ClientAuthenticator")
+
+	rt, err := clientAuth.RoundTripper(http.DefaultTransport)
+	require.NoError(t, err)
+	cli := &http.Client{Transport: rt}
+
+	for _, expect := range []string{"Bearer token-1", "Bearer token-2"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		resp, err := cli.Do(req)
+		require.NoError(t, err, "HTTP request failed")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, expect, <-authorizations)
+	}
+
+	// gRPC calls carry the same tokens as metadata, which are only sent over
+	// secure connections.
+	perRPC, err := clientAuth.PerRPCCredentials()
+	require.NoError(t, err)
+	require.True(t, perRPC.RequireTransportSecurity())
+}
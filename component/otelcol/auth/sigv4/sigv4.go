@@ -3,6 +3,7 @@ package sigv4
 import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol/auth"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/sigv4authextension"
 	otelcomponent "go.opentelemetry.io/collector/component"
 	otelextension "go.opentelemetry.io/collector/extension"
@@ -10,9 +11,10 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.auth.sigv4",
-		Args:    Arguments{},
-		Exports: auth.Exports{},
+		Name:      "otelcol.auth.sigv4",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   auth.Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := sigv4authextension.NewFactory()
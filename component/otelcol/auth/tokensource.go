@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+	extauth "go.opentelemetry.io/collector/extension/auth"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/credentials"
+	grpcoauth "google.golang.org/grpc/credentials/oauth"
+)
+
+// TokenSourceConfig configures the extensions created by factories returned
+// by NewTokenSourceFactory.
+type TokenSourceConfig struct {
+	// TokenSource provides the access tokens attached to requests.
+	TokenSource oauth2.TokenSource
+}
+
+// NewTokenSourceFactory returns a factory of client authentication extensions
+// which attach the OAuth2 access tokens of a token source to HTTP requests and
+// gRPC calls. It's used by components which obtain tokens themselves instead
+// of wrapping an upstream extension.
+func NewTokenSourceFactory(typ otelcomponent.Type) otelextension.Factory {
+	return otelextension.NewFactory(
+		typ,
+		func() otelcomponent.Config { return &TokenSourceConfig{} },
+		createTokenSourceExtension,
+		otelcomponent.StabilityLevelBeta,
+	)
+}
+
+func createTokenSourceExtension(_ context.Context, _ otelextension.CreateSettings, cfg otelcomponent.Config) (otelextension.Extension, error) {
+	ts := cfg.(*TokenSourceConfig).TokenSource
+	if ts == nil {
+		return nil, errors.New("no token source configured")
+	}
+
+	return extauth.NewClient(
+		extauth.WithClientRoundTripper(func(base http.RoundTripper) (http.RoundTripper, error) {
+			return &oauth2.Transport{Source: ts, Base: base}, nil
+		}),
+		extauth.WithClientPerRPCCredentials(func() (credentials.PerRPCCredentials, error) {
+			return grpcoauth.TokenSource{TokenSource: ts}, nil
+		}),
+	), nil
+}
@@ -3,6 +3,7 @@ package otelcol
 import (
 	"fmt"
 
+	otelcomponent "go.opentelemetry.io/collector/component"
 	otelexporterhelper "go.opentelemetry.io/collector/exporter/exporterhelper"
 )
 
@@ -13,9 +14,15 @@ type QueueArguments struct {
 	NumConsumers int  `river:"num_consumers,attr,optional"`
 	QueueSize    int  `river:"queue_size,attr,optional"`
 
-	// TODO(rfratto): queues can send to persistent storage through an extension.
+	// Persistent buffers the queue on disk, in the data directory of the
+	// component, so that queued data survives restarts.
+	Persistent bool `river:"persistent,attr,optional"`
 }
 
+// QueueStorageID is the ID of the storage extension which components
+// provide to persistent queues.
+var QueueStorageID = otelcomponent.NewID("file_storage")
+
 // DefaultQueueArguments holds default settings for QueueArguments.
 var DefaultQueueArguments = QueueArguments{
 	Enabled:      true,
@@ -44,11 +51,16 @@ func (args *QueueArguments) Convert() *otelexporterhelper.QueueSettings {
 		return nil
 	}
 
-	return &otelexporterhelper.QueueSettings{
+	settings := &otelexporterhelper.QueueSettings{
 		Enabled:      args.Enabled,
 		NumConsumers: args.NumConsumers,
 		QueueSize:    args.QueueSize,
 	}
+	if args.Persistent {
+		storageID := QueueStorageID
+		settings.StorageID = &storageID
+	}
+	return settings
 }
 
 // Validate returns an error if args is invalid.
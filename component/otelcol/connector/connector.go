@@ -12,6 +12,7 @@ import (
 	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
 	"github.com/grafana/agent/component/otelcol/internal/lazycollector"
 	"github.com/grafana/agent/component/otelcol/internal/lazyconsumer"
+	"github.com/grafana/agent/component/otelcol/internal/pipelinestats"
 	"github.com/grafana/agent/component/otelcol/internal/scheduler"
 	"github.com/grafana/agent/pkg/build"
 	"github.com/grafana/agent/pkg/util/zapadapter"
@@ -67,14 +68,17 @@ type Connector struct {
 	opts     component.Options
 	factory  otelconnector.Factory
 	consumer *lazyconsumer.Consumer
+	stats    *pipelinestats.Stats
 
 	sched     *scheduler.Scheduler
 	collector *lazycollector.Collector
 }
 
 var (
-	_ component.Component       = (*Connector)(nil)
-	_ component.HealthComponent = (*Connector)(nil)
+	_ component.Component          = (*Connector)(nil)
+	_ component.HealthComponent    = (*Connector)(nil)
+	_ component.DebugComponent     = (*Connector)(nil)
+	_ component.DrainableComponent = (*Connector)(nil)
 )
 
 // New creates a new Flow component which encapsulates an OpenTelemetry
@@ -107,10 +111,14 @@ func New(opts component.Options, f otelconnector.Factory, args Arguments) (*Conn
 		opts:     opts,
 		factory:  f,
 		consumer: consumer,
+		stats:    pipelinestats.New(),
 
 		sched:     scheduler.New(opts.Logger),
 		collector: collector,
 	}
+	if err := p.stats.CountThroughput(opts.Registerer, false); err != nil {
+		return nil, err
+	}
 	if err := p.Update(args); err != nil {
 		return nil, err
 	}
@@ -184,7 +192,7 @@ func (p *Connector) Update(args component.Arguments) error {
 		}
 
 		if len(next.Metrics) > 0 {
-			nextMetrics := fanoutconsumer.Metrics(next.Metrics)
+			nextMetrics := p.stats.OutputMetrics(fanoutconsumer.Metrics(next.Metrics))
 			tracesConnector, err = p.factory.CreateTracesToMetrics(p.ctx, settings, connectorConfig, nextMetrics)
 			if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
 				return err
@@ -196,13 +204,31 @@ func (p *Connector) Update(args component.Arguments) error {
 		return errors.New("unsupported connector type")
 	}
 
-	// Schedule the components to run once our component is running.
-	p.sched.Schedule(host, components...)
-	p.consumer.SetConsumers(tracesConnector, metricsConnector, logsConnector)
+	// Schedule the components to run once our component is running. Data is
+	// sent to them rather than to the components they replace right away, but
+	// it's held back until they've been started.
+	ready := p.consumer.SetPendingConsumers(
+		p.stats.InputTraces(tracesConnector),
+		p.stats.InputMetrics(metricsConnector),
+		p.stats.InputLogs(logsConnector),
+	)
+	p.sched.ScheduleWithCallback(host, ready, components...)
 	return nil
 }
 
+// Drain implements component.DrainableComponent. The upstream connectors are
+// shut down, sending the data they buffer, and no longer accept data.
+func (p *Connector) Drain(ctx context.Context) error {
+	p.consumer.SetConsumers(nil, nil, nil)
+	return p.sched.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (p *Connector) CurrentHealth() component.Health {
 	return p.sched.CurrentHealth()
 }
+
+// DebugInfo implements component.DebugComponent.
+func (p *Connector) DebugInfo() interface{} {
+	return p.stats.DebugInfo()
+}
@@ -0,0 +1,267 @@
+package routing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
+	"github.com/grafana/agent/pkg/util/zapadapter"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlresource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
+)
+
+// route sends the data matching it to its consumers.
+type route struct {
+	attributes map[string]string
+	conditions *ottl.Statements[ottlresource.TransformContext] // nil if there are no conditions.
+
+	traces  otelconsumer.Traces
+	metrics otelconsumer.Metrics
+	logs    otelconsumer.Logs
+}
+
+func newRoute(output *otelcol.ConsumerArguments) *route {
+	return &route{
+		traces:  fanoutconsumer.Traces(output.Traces),
+		metrics: fanoutconsumer.Metrics(output.Metrics),
+		logs:    fanoutconsumer.Logs(output.Logs),
+	}
+}
+
+// matches returns whether data with the given resource matches the route.
+func (r *route) matches(ctx context.Context, res pcommon.Resource) (bool, error) {
+	for name, want := range r.attributes {
+		got, ok := res.Attributes().Get(name)
+		if !ok || got.AsString() != want {
+			return false, nil
+		}
+	}
+	if r.conditions == nil {
+		return true, nil
+	}
+	return r.conditions.Eval(ctx, ottlresource.NewTransformContext(res))
+}
+
+// router finds the routes of resources.
+type router struct {
+	routes    []*route
+	fallback  *route
+	matchOnce bool
+}
+
+func newRouter(args Arguments, l log.Logger) (*router, error) {
+	settings := otelcomponent.TelemetrySettings{Logger: zapadapter.New(l)}
+
+	r := &router{
+		routes:    make([]*route, 0, len(args.Routes)),
+		fallback:  newRoute(args.Output),
+		matchOnce: args.MatchOnce,
+	}
+	for _, routeArgs := range args.Routes {
+		route := newRoute(routeArgs.Output)
+		route.attributes = routeArgs.ResourceAttributes
+		if len(routeArgs.Conditions) > 0 {
+			conditions, err := parseConditions(routeArgs.Conditions, args.ErrorMode, settings)
+			if err != nil {
+				return nil, err
+			}
+			route.conditions = conditions
+		}
+		r.routes = append(r.routes, route)
+	}
+	return r, nil
+}
+
+// parseConditions parses OTTL conditions in the resource context.
+func parseConditions(conditions []string, errorMode ottl.ErrorMode, settings otelcomponent.TelemetrySettings) (*ottl.Statements[ottlresource.TransformContext], error) {
+	parser, err := ottlresource.NewParser(conditionFunctions(), settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTTL only parses conditions as part of statements, so every condition
+	// becomes a statement calling a function which does nothing.
+	rawStatements := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		rawStatements = append(rawStatements, "route() where "+condition)
+	}
+	statements, err := parser.ParseStatements(rawStatements)
+	if err != nil {
+		return nil, err
+	}
+
+	res := ottlresource.NewStatements(statements, settings, ottlresource.WithErrorMode(errorMode))
+	return &res, nil
+}
+
+// conditionFunctions returns the functions which can be used in conditions.
+func conditionFunctions() map[string]ottl.Factory[ottlresource.TransformContext] {
+	functions := ottlfuncs.StandardConverters[ottlresource.TransformContext]()
+	route := ottl.NewFactory("route", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[ottlresource.TransformContext], error) {
+		return func(context.Context, ottlresource.TransformContext) (any, error) {
+			return true, nil
+		}, nil
+	})
+	functions[route.Name()] = route
+	return functions
+}
+
+// find returns the routes of data with the given resource, which is the
+// fallback route if no other route matches.
+func (r *router) find(ctx context.Context, res pcommon.Resource) ([]*route, error) {
+	var found []*route
+	for _, route := range r.routes {
+		ok, err := route.matches(ctx, res)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		found = append(found, route)
+		if r.matchOnce {
+			break
+		}
+	}
+	if len(found) == 0 {
+		found = append(found, r.fallback)
+	}
+	return found, nil
+}
+
+// consumer splits incoming data by resource and sends every resource to the
+// routes it matches.
+type consumer struct {
+	mut    sync.RWMutex
+	router *router
+}
+
+var _ otelcol.Consumer = (*consumer)(nil)
+
+// SetRouter sets the routes of the data consumed afterwards.
+func (c *consumer) SetRouter(r *router) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.router = r
+}
+
+func (c *consumer) getRouter() *router {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.router
+}
+
+// Capabilities implements otelcol.Consumer.
+func (c *consumer) Capabilities() otelconsumer.Capabilities {
+	// Data is copied for every route, so it's never mutated.
+	return otelconsumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeTraces implements otelcol.ConsumeTraces.
+func (c *consumer) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	router := c.getRouter()
+
+	var (
+		routes  []*route
+		batches = make(map[*route]ptrace.Traces)
+	)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		found, err := router.find(ctx, rs.Resource())
+		if err != nil {
+			return err
+		}
+		for _, route := range found {
+			batch, ok := batches[route]
+			if !ok {
+				batch = ptrace.NewTraces()
+				batches[route] = batch
+				routes = append(routes, route)
+			}
+			rs.CopyTo(batch.ResourceSpans().AppendEmpty())
+		}
+	}
+
+	var errs error
+	for _, route := range routes {
+		errs = multierr.Append(errs, route.traces.ConsumeTraces(ctx, batches[route]))
+	}
+	return errs
+}
+
+// ConsumeMetrics implements otelcol.ConsumeMetrics.
+func (c *consumer) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	router := c.getRouter()
+
+	var (
+		routes  []*route
+		batches = make(map[*route]pmetric.Metrics)
+	)
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		found, err := router.find(ctx, rm.Resource())
+		if err != nil {
+			return err
+		}
+		for _, route := range found {
+			batch, ok := batches[route]
+			if !ok {
+				batch = pmetric.NewMetrics()
+				batches[route] = batch
+				routes = append(routes, route)
+			}
+			rm.CopyTo(batch.ResourceMetrics().AppendEmpty())
+		}
+	}
+
+	var errs error
+	for _, route := range routes {
+		errs = multierr.Append(errs, route.metrics.ConsumeMetrics(ctx, batches[route]))
+	}
+	return errs
+}
+
+// ConsumeLogs implements otelcol.ConsumeLogs.
+func (c *consumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	router := c.getRouter()
+
+	var (
+		routes  []*route
+		batches = make(map[*route]plog.Logs)
+	)
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		rl := rls.At(i)
+		found, err := router.find(ctx, rl.Resource())
+		if err != nil {
+			return err
+		}
+		for _, route := range found {
+			batch, ok := batches[route]
+			if !ok {
+				batch = plog.NewLogs()
+				batches[route] = batch
+				routes = append(routes, route)
+			}
+			rl.CopyTo(batch.ResourceLogs().AppendEmpty())
+		}
+	}
+
+	var errs error
+	for _, route := range routes {
+		errs = multierr.Append(errs, route.logs.ConsumeLogs(ctx, batches[route]))
+	}
+	return errs
+}
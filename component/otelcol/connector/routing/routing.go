@@ -0,0 +1,135 @@
+// Package routing provides an otelcol.connector.routing component.
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/lazyconsumer"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/river"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.connector.routing",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
+
+		Build: func(o component.Options, a component.Arguments) (component.Component, error) {
+			return New(o, a.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the otelcol.connector.routing component.
+type Arguments struct {
+	ErrorMode ottl.ErrorMode `river:"error_mode,attr,optional"`
+	MatchOnce bool           `river:"match_once,attr,optional"`
+	Routes    []Route        `river:"route,block,optional"`
+
+	// Output configures where to send data which doesn't match any route.
+	// Required.
+	Output *otelcol.ConsumerArguments `river:"output,block"`
+}
+
+// Route configures which data to send to an output.
+type Route struct {
+	// ResourceAttributes must all be set to the given values on the resource
+	// of the data.
+	ResourceAttributes map[string]string `river:"resource_attributes,attr,optional"`
+	// Conditions are OTTL conditions in the resource context, one of which must
+	// match the resource of the data if any are set.
+	Conditions []string `river:"conditions,attr,optional"`
+
+	// Output configures where to send the matching data. Required.
+	Output *otelcol.ConsumerArguments `river:"output,block"`
+}
+
+var (
+	_ river.Defaulter = (*Arguments)(nil)
+	_ river.Validator = (*Arguments)(nil)
+)
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	ErrorMode: ottl.PropagateError,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	var errs []error
+	for i, route := range args.Routes {
+		if len(route.ResourceAttributes) == 0 && len(route.Conditions) == 0 {
+			errs = append(errs, fmt.Errorf("route[%d] must set resource_attributes or conditions", i))
+			continue
+		}
+
+		// Parse every condition on its own, so that an invalid condition is
+		// reported along with the route it's set in.
+		for j, condition := range route.Conditions {
+			if _, err := parseConditions([]string{condition}, args.ErrorMode, nopSettings); err != nil {
+				errs = append(errs, fmt.Errorf("invalid condition route[%d].conditions[%d] %q: %w", i, j, condition, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// nopSettings are the telemetry settings used to validate conditions.
+var nopSettings = otelcomponent.TelemetrySettings{Logger: zap.NewNop()}
+
+// Component is the otelcol.connector.routing component.
+type Component struct {
+	opts     component.Options
+	consumer *consumer
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new otelcol.connector.routing component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	res := &Component{
+		opts:     o,
+		consumer: &consumer{},
+	}
+	if err := res.Update(args); err != nil {
+		return nil, err
+	}
+
+	// Export the consumer. This will remain the same throughout the
+	// component's lifetime, so we do this during component construction.
+	export := lazyconsumer.New(context.Background())
+	export.SetConsumers(res.consumer, res.consumer, res.consumer)
+	o.OnStateChange(otelcol.ConsumerExports{Input: export})
+
+	return res, nil
+}
+
+// Run implements Component.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements Component.
+func (c *Component) Update(newConfig component.Arguments) error {
+	r, err := newRouter(newConfig.(Arguments), c.opts.Logger)
+	if err != nil {
+		return err
+	}
+	c.consumer.SetRouter(r)
+	return nil
+}
@@ -0,0 +1,249 @@
+package routing_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/connector/routing"
+	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// sink records the names of the spans and log records it receives.
+type sink struct {
+	mut   sync.Mutex
+	names []string
+}
+
+func (s *sink) output() *otelcol.ConsumerArguments {
+	c := &fakeconsumer.Consumer{
+		ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			rss := td.ResourceSpans()
+			for i := 0; i < rss.Len(); i++ {
+				spans := rss.At(i).ScopeSpans().At(0).Spans()
+				for j := 0; j < spans.Len(); j++ {
+					s.names = append(s.names, spans.At(j).Name())
+				}
+			}
+			return nil
+		},
+		ConsumeLogsFunc: func(_ context.Context, ld plog.Logs) error {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			rls := ld.ResourceLogs()
+			for i := 0; i < rls.Len(); i++ {
+				records := rls.At(i).ScopeLogs().At(0).LogRecords()
+				for j := 0; j < records.Len(); j++ {
+					s.names = append(s.names, records.At(j).Body().AsString())
+				}
+			}
+			return nil
+		},
+	}
+	return &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{c},
+		Logs:   []otelcol.Consumer{c},
+	}
+}
+
+func (s *sink) received() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	res := append([]string{}, s.names...)
+	sort.Strings(res)
+	return res
+}
+
+// startRouting runs otelcol.connector.routing with cfg, sending every route
+// and the default output to their own sink, and returns its input.
+func startRouting(t *testing.T, cfg string) (otelcol.Consumer, []*sink, *sink) {
+	t.Helper()
+
+	var args routing.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	routes := make([]*sink, len(args.Routes))
+	for i := range args.Routes {
+		routes[i] = &sink{}
+		args.Routes[i].Output = routes[i].output()
+	}
+	fallback := &sink{}
+	args.Output = fallback.output()
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.connector.routing")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, ctrl.Run(componenttest.TestContext(t), args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second))
+	require.NoError(t, ctrl.WaitExports(time.Second))
+
+	return ctrl.Exports().(otelcol.ConsumerExports).Input, routes, fallback
+}
+
+// newTraces creates traces with a resource for every service, holding a span
+// named after the service.
+func newTraces(services ...map[string]string) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, attrs := range services {
+		rs := td.ResourceSpans().AppendEmpty()
+		for k, v := range attrs {
+			rs.Resource().Attributes().PutStr(k, v)
+		}
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName(attrs["service.name"])
+	}
+	return td
+}
+
+func TestRouting(t *testing.T) {
+	input, routes, fallback := startRouting(t, `
+		route {
+			resource_attributes = { "service.namespace" = "payments" }
+			output {}
+		}
+		route {
+			conditions = [
+				`+"`"+`attributes["service.name"] == "frontend"`+"`"+`,
+				`+"`"+`IsMatch(attributes["service.name"], "^web-.*")`+"`"+`,
+			]
+			output {}
+		}
+		output {}
+	`)
+
+	// The input only accepts data once the component is running.
+	td := newTraces(
+		map[string]string{"service.name": "checkout", "service.namespace": "payments"},
+		map[string]string{"service.name": "frontend", "service.namespace": "shop"},
+		map[string]string{"service.name": "web-cart"},
+		map[string]string{"service.name": "inventory", "service.namespace": "shop"},
+	)
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(context.Background(), td) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, []string{"checkout"}, routes[0].received())
+	require.Equal(t, []string{"frontend", "web-cart"}, routes[1].received())
+	require.Equal(t, []string{"inventory"}, fallback.received())
+}
+
+func TestRouting_MatchOnce(t *testing.T) {
+	cfg := `
+		route {
+			resource_attributes = { "service.namespace" = "shop" }
+			output {}
+		}
+		route {
+			conditions = [` + "`" + `attributes["service.name"] == "frontend"` + "`" + `]
+			output {}
+		}
+		output {}
+	`
+
+	tt := []struct {
+		name      string
+		matchOnce bool
+		expect    [][]string
+	}{
+		{"all matching routes", false, [][]string{{"frontend"}, {"frontend"}}},
+		{"first matching route", true, [][]string{{"frontend"}, {}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			extra := ""
+			if tc.matchOnce {
+				extra = "match_once = true\n"
+			}
+			input, routes, fallback := startRouting(t, extra+cfg)
+
+			td := newTraces(map[string]string{"service.name": "frontend", "service.namespace": "shop"})
+			require.Eventually(t, func() bool {
+				return input.ConsumeTraces(context.Background(), td) == nil
+			}, time.Second, 10*time.Millisecond)
+
+			for i, route := range routes {
+				require.Equal(t, tc.expect[i], route.received(), "route %d", i)
+			}
+			require.Empty(t, fallback.received())
+		})
+	}
+}
+
+func TestRouting_Logs(t *testing.T) {
+	input, routes, fallback := startRouting(t, `
+		route {
+			resource_attributes = { "service.namespace" = "payments" }
+			output {}
+		}
+		output {}
+	`)
+
+	ld := plog.NewLogs()
+	for _, namespace := range []string{"payments", "shop"} {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.namespace", namespace)
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr(namespace + " log")
+	}
+	require.Eventually(t, func() bool {
+		return input.ConsumeLogs(context.Background(), ld) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, []string{"payments log"}, routes[0].received())
+	require.Equal(t, []string{"shop log"}, fallback.received())
+}
+
+func TestArguments_Validate(t *testing.T) {
+	tt := []struct {
+		name      string
+		cfg       string
+		expectErr string
+	}{
+		{
+			name: "route without match rules",
+			cfg: `
+				route {
+					output {}
+				}
+				output {}
+			`,
+			expectErr: "route[0] must set resource_attributes or conditions",
+		},
+		{
+			name: "invalid condition",
+			cfg: `
+				route {
+					conditions = [` + "`" + `attributes["a"] == "b"` + "`, `" + `attributes["a"] ==` + "`" + `]
+					output {}
+				}
+				output {}
+			`,
+			expectErr: `invalid condition route[0].conditions[1] "attributes[\"a\"] =="`,
+		},
+		{
+			name: "invalid error mode",
+			cfg: `
+				error_mode = "fail"
+				output {}
+			`,
+			expectErr: "fail",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var args routing.Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			require.ErrorContains(t, err, tc.expectErr)
+		})
+	}
+}
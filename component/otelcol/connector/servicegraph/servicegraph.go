@@ -7,6 +7,7 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/connector"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/connector/servicegraphconnector"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/servicegraphprocessor"
@@ -16,13 +17,21 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.connector.servicegraph",
-		Args:    Arguments{},
-		Exports: otelcol.ConsumerExports{},
+		Name:      "otelcol.connector.servicegraph",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := servicegraphconnector.NewFactory()
-			return connector.New(opts, fact, args.(Arguments))
+			c, err := connector.New(opts, fact, args.(Arguments))
+			if err != nil {
+				return nil, err
+			}
+			if err := opts.Registerer.Register(newStatsCollector()); err != nil {
+				return nil, err
+			}
+			return c, nil
 		},
 	})
 }
@@ -1,13 +1,23 @@
 package servicegraph_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/connector/servicegraph"
+	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/servicegraphprocessor"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 func TestArguments_UnmarshalRiver(t *testing.T) {
@@ -154,3 +164,165 @@ func TestArguments_UnmarshalRiver(t *testing.T) {
 		})
 	}
 }
+
+// runServiceGraph runs an otelcol.connector.servicegraph component configured
+// by cfg, which forwards metrics to the returned channel. Metrics of the
+// component are registered to registerer.
+func runServiceGraph(t *testing.T, cfg string, registerer prometheus.Registerer) (otelcol.Consumer, chan pmetric.Metrics) {
+	var args servicegraph.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	metricsCh := make(chan pmetric.Metrics, 10)
+	args.Output = &otelcol.ConsumerArguments{
+		Metrics: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeMetricsFunc: func(_ context.Context, md pmetric.Metrics) error {
+				metricsCh <- md
+				return nil
+			},
+		}},
+	}
+
+	reg, ok := component.Get("otelcol.connector.servicegraph")
+	require.True(t, ok)
+
+	exportsCh := make(chan otelcol.ConsumerExports, 1)
+	c, err := reg.Build(component.Options{
+		ID:            "otelcol.connector.servicegraph.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    registerer,
+		OnStateChange: func(e component.Exports) { exportsCh <- e.(otelcol.ConsumerExports) },
+	}, args)
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// The upstream connector creates its store when it's started, so spans
+	// can only be sent once the scheduled components are running.
+	require.Eventually(t, func() bool {
+		return c.(component.HealthComponent).CurrentHealth().Health == component.HealthTypeHealthy
+	}, 5*time.Second, 10*time.Millisecond)
+	return (<-exportsCh).Input, metricsCh
+}
+
+// spans returns traces with a span of the given kind for every service in
+// services. Every span has the previous one as its parent.
+func spans(traceID pcommon.TraceID, kinds []ptrace.SpanKind, services ...string) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	var parent pcommon.SpanID
+	for i, service := range services {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetName("request")
+		span.SetKind(kinds[i])
+		span.SetTraceID(traceID)
+		span.SetSpanID(pcommon.SpanID{traceID[0], byte(i + 1)})
+		span.SetParentSpanID(parent)
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Unix(0, 0)))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(time.Unix(0, 0).Add(10 * time.Millisecond)))
+		parent = span.SpanID()
+	}
+	return traces
+}
+
+// requestTotal returns the value of traces_service_graph_request_total for
+// the edge between client and server in md.
+func requestTotal(md pmetric.Metrics, client, server string) (float64, bool) {
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				m := ms.At(k)
+				if m.Name() != "traces_service_graph_request_total" {
+					continue
+				}
+				dps := m.Sum().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					dp := dps.At(l)
+					c, _ := dp.Attributes().Get("client")
+					s, _ := dp.Attributes().Get("server")
+					if c.Str() == client && s.Str() == server {
+						return dp.DoubleValue() + float64(dp.IntValue()), true
+					}
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func Test_ServiceGraphEdges(t *testing.T) {
+	cfg := `
+	dimensions = ["http.method"]
+	store {
+		ttl = "1m"
+	}
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+	input, metricsCh := runServiceGraph(t, cfg, prometheus.NewRegistry())
+
+	// The client and the server spans of the request from frontend to
+	// checkout are paired into an edge.
+	traces := spans(pcommon.TraceID{1}, []ptrace.SpanKind{ptrace.SpanKindClient, ptrace.SpanKindServer}, "frontend", "checkout")
+	require.NoError(t, input.ConsumeTraces(context.Background(), traces))
+
+	select {
+	case md := <-metricsCh:
+		v, ok := requestTotal(md, "frontend", "checkout")
+		require.True(t, ok, "edge from frontend to checkout not found")
+		require.Equal(t, 1.0, v)
+
+		_, ok = requestTotal(md, "checkout", "frontend")
+		require.False(t, ok, "unexpected edge from checkout to frontend")
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for metrics")
+	}
+}
+
+func Test_ServiceGraphExpiredEdges(t *testing.T) {
+	cfg := `
+	store {
+		ttl = "10ms"
+	}
+	store_expiration_loop = "10ms"
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+	reg := prometheus.NewRegistry()
+	input, _ := runServiceGraph(t, cfg, reg)
+
+	// The counters are shared by all servicegraph connectors of the process,
+	// so only their increase is checked.
+	before := expiredEdges(t, reg)
+
+	// The client span never gets a matching server span, so its edge expires
+	// from the store.
+	traces := spans(pcommon.TraceID{2}, []ptrace.SpanKind{ptrace.SpanKindClient}, "frontend")
+	require.NoError(t, input.ConsumeTraces(context.Background(), traces))
+
+	require.Eventually(t, func() bool {
+		return expiredEdges(t, reg) > before
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func expiredEdges(t *testing.T, reg prometheus.Gatherer) float64 {
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() == "otelcol_connector_servicegraph_expired_edges_total" {
+			return mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	require.FailNow(t, "otelcol_connector_servicegraph_expired_edges_total not found")
+	return 0
+}
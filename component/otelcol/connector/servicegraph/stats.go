@@ -0,0 +1,66 @@
+package servicegraph
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/stats/view"
+)
+
+// statsCollector exposes the internal metrics of the upstream servicegraph
+// connector as Prometheus metrics.
+//
+// The upstream connector records its internal metrics with OpenCensus, which
+// aggregates them for the whole process, so every component reports the
+// totals across all otelcol.connector.servicegraph components.
+type statsCollector struct {
+	metrics []statsMetric
+}
+
+type statsMetric struct {
+	view string
+	desc *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*statsCollector)(nil)
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		metrics: []statsMetric{
+			{
+				view: "processor/servicegraph/dropped_spans",
+				desc: prometheus.NewDesc("otelcol_connector_servicegraph_dropped_spans_total", "Number of spans dropped when trying to add edges to the store.", nil, nil),
+			},
+			{
+				view: "processor/servicegraph/total_edges",
+				desc: prometheus.NewDesc("otelcol_connector_servicegraph_edges_total", "Number of unique edges added to the store.", nil, nil),
+			},
+			{
+				view: "processor/servicegraph/expired_edges",
+				desc: prometheus.NewDesc("otelcol_connector_servicegraph_expired_edges_total", "Number of edges which expired from the store before their matching span was found.", nil, nil),
+			},
+		},
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics {
+		ch <- m.desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics {
+		var total int64
+
+		// The views are registered by the upstream factory. An error means
+		// nothing was recorded yet.
+		rows, _ := view.RetrieveData(m.view)
+		for _, row := range rows {
+			if data, ok := row.Data.(*view.CountData); ok {
+				total += data.Value
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(m.desc, prometheus.CounterValue, float64(total))
+	}
+}
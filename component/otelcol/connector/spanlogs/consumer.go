@@ -19,6 +19,7 @@ const (
 	typeSpan    = "span"
 	typeRoot    = "root"
 	typeProcess = "process"
+	typeEvent   = "event"
 )
 
 type consumer struct {
@@ -30,8 +31,11 @@ type options struct {
 	spans             bool
 	roots             bool
 	processes         bool
+	events            bool
+	errorsOnly        bool
 	spanAttributes    []string
 	processAttributes []string
+	eventAttributes   []string
 	overrides         OverrideConfig
 	labels            map[string]struct{}
 	nextConsumer      otelconsumer.Logs
@@ -67,8 +71,11 @@ func (c *consumer) UpdateOptions(args Arguments, nextConsumer otelconsumer.Logs)
 		spans:             args.Spans,
 		roots:             args.Roots,
 		processes:         args.Processes,
+		events:            args.Events,
+		errorsOnly:        args.ErrorsOnly,
 		spanAttributes:    args.SpanAttributes,
 		processAttributes: args.ProcessAttributes,
+		eventAttributes:   args.EventAttributes,
 		overrides:         args.Overrides,
 		labels:            labels,
 		nextConsumer:      nextConsumer,
@@ -126,12 +133,17 @@ func (c *consumer) consumeSpans(serviceName string, ss ptrace.ScopeSpans, rs pco
 		span := ss.Spans().At(k)
 		traceID := span.TraceID().String()
 
+		if c.opts.errorsOnly && span.Status().Code() != ptrace.StatusCodeError {
+			continue
+		}
+
 		logSpans := c.opts.spans
 		logRoots := c.opts.roots && span.ParentSpanID().IsEmpty()
 		logProcesses := c.opts.processes && lastTraceID != traceID
+		logEvents := c.opts.events && span.Events().Len() > 0
 
-		if !logSpans && !logRoots && !logProcesses {
-			return nil
+		if !logSpans && !logRoots && !logProcesses && !logEvents {
+			continue
 		}
 
 		//TODO: This code uses pcommon.Map a extensively. Should we use map[string]pcommon.Value instead?
@@ -175,6 +187,38 @@ func (c *consumer) consumeSpans(serviceName string, ss ptrace.ScopeSpans, rs pco
 				return err
 			}
 		}
+
+		if logEvents {
+			err := c.appendEventLogRecords(span, rs, serviceName, logRecords)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendEventLogRecords appends a log record for each event of the span,
+// timestamped with the time of the event.
+func (c *consumer) appendEventLogRecords(span ptrace.Span, rs pcommon.Resource, serviceName string, logRecords plog.LogRecordSlice) error {
+	eventsLen := span.Events().Len()
+	for i := 0; i < eventsLen; i++ {
+		event := span.Events().At(i)
+
+		keyValues := pcommon.NewMap()
+
+		c.eventKeyVals(keyValues, span, event)
+		c.processKeyVals(keyValues, rs, serviceName)
+
+		// Add the trace and span IDs to the key values
+		keyValues.PutStr(c.opts.overrides.TraceIDKey, span.TraceID().String())
+		keyValues.PutStr(c.opts.overrides.SpanIDKey, span.SpanID().String())
+
+		err := c.appendLogRecord(typeEvent, keyValues, logRecords)
+		if err != nil {
+			return err
+		}
+		logRecords.At(logRecords.Len() - 1).SetTimestamp(event.Timestamp())
 	}
 	return nil
 }
@@ -276,6 +320,19 @@ func (c *consumer) spanKeyVals(output pcommon.Map, span ptrace.Span) {
 	}
 }
 
+func (c *consumer) eventKeyVals(output pcommon.Map, span ptrace.Span, event ptrace.SpanEvent) {
+	output.PutStr(c.opts.overrides.SpanNameKey, span.Name())
+	output.PutStr(c.opts.overrides.EventNameKey, event.Name())
+
+	for _, name := range c.opts.eventAttributes {
+		att, ok := event.Attributes().Get(name)
+		if ok {
+			val := output.PutEmpty(name)
+			att.CopyTo(val)
+		}
+	}
+}
+
 func spanDuration(span ptrace.Span) string {
 	dur := int64(span.EndTimestamp() - span.StartTimestamp())
 	return strconv.FormatInt(dur, 10) + "ns"
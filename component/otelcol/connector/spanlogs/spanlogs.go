@@ -9,15 +9,17 @@ import (
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
 	"github.com/grafana/agent/component/otelcol/internal/lazyconsumer"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/river"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.connector.spanlogs",
-		Args:    Arguments{},
-		Exports: otelcol.ConsumerExports{},
+		Name:      "otelcol.connector.spanlogs",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
 
 		Build: func(o component.Options, a component.Arguments) (component.Component, error) {
 			return New(o, a.(Arguments))
@@ -30,8 +32,11 @@ type Arguments struct {
 	Spans             bool           `river:"spans,attr,optional"`
 	Roots             bool           `river:"roots,attr,optional"`
 	Processes         bool           `river:"processes,attr,optional"`
+	Events            bool           `river:"events,attr,optional"`
+	ErrorsOnly        bool           `river:"errors_only,attr,optional"`
 	SpanAttributes    []string       `river:"span_attributes,attr,optional"`
 	ProcessAttributes []string       `river:"process_attributes,attr,optional"`
+	EventAttributes   []string       `river:"event_attributes,attr,optional"`
 	Overrides         OverrideConfig `river:"overrides,block,optional"`
 	Labels            []string       `river:"labels,attr,optional"`
 
@@ -40,12 +45,14 @@ type Arguments struct {
 }
 
 type OverrideConfig struct {
-	LogsTag     string `river:"logs_instance_tag,attr,optional"`
-	ServiceKey  string `river:"service_key,attr,optional"`
-	SpanNameKey string `river:"span_name_key,attr,optional"`
-	StatusKey   string `river:"status_key,attr,optional"`
-	DurationKey string `river:"duration_key,attr,optional"`
-	TraceIDKey  string `river:"trace_id_key,attr,optional"`
+	LogsTag      string `river:"logs_instance_tag,attr,optional"`
+	ServiceKey   string `river:"service_key,attr,optional"`
+	SpanNameKey  string `river:"span_name_key,attr,optional"`
+	StatusKey    string `river:"status_key,attr,optional"`
+	DurationKey  string `river:"duration_key,attr,optional"`
+	TraceIDKey   string `river:"trace_id_key,attr,optional"`
+	SpanIDKey    string `river:"span_id_key,attr,optional"`
+	EventNameKey string `river:"event_name_key,attr,optional"`
 }
 
 var (
@@ -55,12 +62,14 @@ var (
 // DefaultArguments holds default settings for Arguments.
 var DefaultArguments = Arguments{
 	Overrides: OverrideConfig{
-		LogsTag:     "traces",
-		ServiceKey:  "svc",
-		SpanNameKey: "span",
-		StatusKey:   "status",
-		DurationKey: "dur",
-		TraceIDKey:  "tid",
+		LogsTag:      "traces",
+		ServiceKey:   "svc",
+		SpanNameKey:  "span",
+		StatusKey:    "status",
+		DurationKey:  "dur",
+		TraceIDKey:   "tid",
+		SpanIDKey:    "sid",
+		EventNameKey: "event",
 	},
 }
 
@@ -2,15 +2,30 @@ package spanlogs_test
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	lokiwrite "github.com/grafana/agent/component/loki/write"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/connector/spanlogs"
+	lokiexporter "github.com/grafana/agent/component/otelcol/exporter/loki"
+	"github.com/grafana/agent/component/otelcol/processor/attributes"
 	"github.com/grafana/agent/component/otelcol/processor/processortest"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/logproto"
+	loki_util "github.com/grafana/loki/pkg/util"
 	"github.com/grafana/river"
+	promql_parser "github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 func testRunProcessor(t *testing.T, processorConfig string, testSignal processortest.Signal) {
@@ -93,12 +108,14 @@ func Test_ComponentIO(t *testing.T) {
 	}`
 
 	defaultOverrides := spanlogs.OverrideConfig{
-		LogsTag:     "traces",
-		ServiceKey:  "svc",
-		SpanNameKey: "span",
-		StatusKey:   "status",
-		DurationKey: "dur",
-		TraceIDKey:  "tid",
+		LogsTag:      "traces",
+		ServiceKey:   "svc",
+		SpanNameKey:  "span",
+		StatusKey:    "status",
+		DurationKey:  "dur",
+		TraceIDKey:   "tid",
+		SpanIDKey:    "sid",
+		EventNameKey: "event",
 	}
 
 	tests := []struct {
@@ -198,6 +215,8 @@ func Test_ComponentIO(t *testing.T) {
 				status_key = "override_status"
 				duration_key = "override_dur"
 				trace_id_key = "override_tid"
+				span_id_key = "override_sid"
+				event_name_key = "override_event"
 			}
 
 			output {
@@ -211,12 +230,14 @@ func Test_ComponentIO(t *testing.T) {
 				SpanAttributes:    []string{"attribute1"},
 				ProcessAttributes: []string{"res_attribute1"},
 				Overrides: spanlogs.OverrideConfig{
-					LogsTag:     "override_traces",
-					ServiceKey:  "override_svc",
-					SpanNameKey: "override_span",
-					StatusKey:   "override_status",
-					DurationKey: "override_dur",
-					TraceIDKey:  "override_tid",
+					LogsTag:      "override_traces",
+					ServiceKey:   "override_svc",
+					SpanNameKey:  "override_span",
+					StatusKey:    "override_status",
+					DurationKey:  "override_dur",
+					TraceIDKey:   "override_tid",
+					SpanIDKey:    "override_sid",
+					EventNameKey: "override_event",
 				},
 				Labels: []string{"attribute1", "res_attribute1"},
 				Output: &otelcol.ConsumerArguments{},
@@ -695,6 +716,173 @@ func Test_ComponentIO(t *testing.T) {
 				}]
 			}`,
 		},
+		{
+			testName: "Events",
+			cfg: `
+			events = true
+			labels = ["tid", "sid"]
+			event_attributes = ["exception.type"]
+
+			output {
+				// no-op: will be overridden by test code.
+			}
+		`,
+			expectedUnmarshaledCfg: spanlogs.Arguments{
+				Events:          true,
+				EventAttributes: []string{"exception.type"},
+				Overrides:       defaultOverrides,
+				Labels:          []string{"tid", "sid"},
+				Output:          &otelcol.ConsumerArguments{},
+			},
+			inputTraceJson: `{
+				"resourceSpans": [{
+					"resource": {
+						"attributes": [{
+							"key": "service.name",
+							"value": { "stringValue": "TestSvcName" }
+						}]
+					},
+					"scopeSpans": [{
+						"spans": [{
+							"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+							"span_id": "086e83747d0e381e",
+							"name": "TestSpan",
+							"events": [{
+								"time_unix_nano": "1000000000",
+								"name": "exception",
+								"attributes": [{
+									"key": "exception.type",
+									"value": { "stringValue": "TimeoutError" }
+								},
+								{
+									"key": "unused_attribute1",
+									"value": { "intValue": "78" }
+								}]
+							},
+							{
+								"time_unix_nano": "2000000000",
+								"name": "retry"
+							}]
+						},
+						{
+							"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+							"span_id": "186e83747d0e381e",
+							"name": "SpanWithoutEvents"
+						}]
+					}]
+				}]
+			}`,
+			expectedOutputLogJson: `{
+				"resourceLogs": [{
+					"scopeLogs": [{
+						"log_records": [{
+							"time_unix_nano": "1000000000",
+							"body": { "stringValue": "span=TestSpan event=exception exception.type=TimeoutError svc=TestSvcName tid=7bba9f33312b3dbb8b2c2c62bb7abe2d sid=086e83747d0e381e" },
+							"attributes": [{
+								"key": "traces",
+								"value": { "stringValue": "event" }
+							},
+							{
+								"key": "tid",
+								"value": { "stringValue": "7bba9f33312b3dbb8b2c2c62bb7abe2d" }
+							},
+							{
+								"key": "sid",
+								"value": { "stringValue": "086e83747d0e381e" }
+							}]
+						},
+						{
+							"time_unix_nano": "2000000000",
+							"body": { "stringValue": "span=TestSpan event=retry svc=TestSvcName tid=7bba9f33312b3dbb8b2c2c62bb7abe2d sid=086e83747d0e381e" },
+							"attributes": [{
+								"key": "traces",
+								"value": { "stringValue": "event" }
+							},
+							{
+								"key": "tid",
+								"value": { "stringValue": "7bba9f33312b3dbb8b2c2c62bb7abe2d" }
+							},
+							{
+								"key": "sid",
+								"value": { "stringValue": "086e83747d0e381e" }
+							}]
+						}]
+					}]
+				}]
+			}`,
+		},
+		{
+			testName: "ErrorsOnly",
+			cfg: `
+			spans = true
+			processes = true
+			errors_only = true
+
+			output {
+				// no-op: will be overridden by test code.
+			}
+		`,
+			expectedUnmarshaledCfg: spanlogs.Arguments{
+				Spans:      true,
+				Processes:  true,
+				ErrorsOnly: true,
+				Overrides:  defaultOverrides,
+				Output:     &otelcol.ConsumerArguments{},
+			},
+			inputTraceJson: `{
+				"resourceSpans": [{
+					"resource": {
+						"attributes": [{
+							"key": "service.name",
+							"value": { "stringValue": "TestSvcName" }
+						}]
+					},
+					"scopeSpans": [{
+						"spans": [{
+							"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+							"span_id": "086e83747d0e381e",
+							"name": "OkSpan",
+							"status": {
+								"code": 1
+							}
+						},
+						{
+							"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+							"span_id": "186e83747d0e381e",
+							"name": "ErrorSpan",
+							"status": {
+								"code": 2
+							}
+						},
+						{
+							"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+							"span_id": "286e83747d0e381e",
+							"name": "UnsetSpan"
+						}]
+					}]
+				}]
+			}`,
+			expectedOutputLogJson: `{
+				"resourceLogs": [{
+					"scopeLogs": [{
+						"log_records": [{
+							"body": { "stringValue": "svc=TestSvcName tid=7bba9f33312b3dbb8b2c2c62bb7abe2d" },
+							"attributes": [{
+								"key": "traces",
+								"value": { "stringValue": "process" }
+							}]
+						},
+						{
+							"body": { "stringValue": "span=ErrorSpan dur=0ns status=Error svc=TestSvcName tid=7bba9f33312b3dbb8b2c2c62bb7abe2d" },
+							"attributes": [{
+								"key": "traces",
+								"value": { "stringValue": "span" }
+							}]
+						}]
+					}]
+				}]
+			}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -707,3 +895,99 @@ func Test_ComponentIO(t *testing.T) {
 		})
 	}
 }
+
+// TestErroredSpansToLoki sends spans through otelcol.exporter.loki and
+// loki.write, and checks that the logs of errored spans reach Loki with their
+// trace ID as a label.
+func TestErroredSpansToLoki(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+
+	pushes := make(chan logproto.PushRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushReq logproto.PushRequest
+		err := loki_util.ParseProtoReader(context.Background(), r.Body, int(r.ContentLength), math.MaxInt32, &pushReq, loki_util.RawSnappy)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		pushes <- pushReq
+	}))
+	defer srv.Close()
+
+	var writeArgs lokiwrite.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		endpoint {
+			url        = "%s"
+			batch_wait = "10ms"
+		}
+	`, srv.URL)), &writeArgs))
+	writeExports := startComponent(ctx, t, "loki.write", writeArgs).(lokiwrite.Exports)
+
+	exporterExports := startComponent(ctx, t, "otelcol.exporter.loki", lokiexporter.Arguments{
+		ForwardTo: []loki.LogsReceiver{writeExports.Receiver},
+	}).(otelcol.ConsumerExports)
+
+	// Hint otelcol.exporter.loki to use the trace ID as a label.
+	var attributesArgs attributes.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		action {
+			key    = "loki.attribute.labels"
+			action = "insert"
+			value  = "tid"
+		}
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`), &attributesArgs))
+	attributesArgs.Output = &otelcol.ConsumerArguments{Logs: []otelcol.Consumer{exporterExports.Input}}
+	attributesExports := startComponent(ctx, t, "otelcol.processor.attributes", attributesArgs).(otelcol.ConsumerExports)
+
+	var spanlogsArgs spanlogs.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		spans       = true
+		errors_only = true
+		labels      = ["tid"]
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`), &spanlogsArgs))
+	spanlogsArgs.Output = &otelcol.ConsumerArguments{Logs: []otelcol.Consumer{attributesExports.Input}}
+	spanlogsExports := startComponent(ctx, t, "otelcol.connector.spanlogs", spanlogsArgs).(otelcol.ConsumerExports)
+
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i, code := range []ptrace.StatusCode{ptrace.StatusCodeOk, ptrace.StatusCodeError} {
+		span := spans.AppendEmpty()
+		span.SetName(code.String())
+		span.SetTraceID(pcommon.TraceID{byte(i + 1)})
+		span.Status().SetCode(code)
+	}
+	require.NoError(t, spanlogsExports.Input.ConsumeTraces(ctx, traces))
+
+	select {
+	case pushReq := <-pushes:
+		require.Len(t, pushReq.Streams, 1)
+		stream := pushReq.Streams[0]
+		lbls, err := promql_parser.ParseMetric(stream.Labels)
+		require.NoError(t, err)
+		require.Equal(t, "02000000000000000000000000000000", lbls.Get("tid"))
+		require.Len(t, stream.Entries, 1)
+		require.Contains(t, stream.Entries[0].Line, "span=Error")
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "failed to receive the logs in time")
+	}
+}
+
+// startComponent runs the component with the given name and arguments until
+// ctx is canceled, and returns its exports.
+func startComponent(ctx context.Context, t *testing.T, name string, args component.Arguments) component.Exports {
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), name)
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitExports(time.Second))
+	return ctrl.Exports()
+}
@@ -0,0 +1,147 @@
+package spanmetrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
+	"github.com/prometheus/client_golang/prometheus"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// seriesLimiter is an otelcol.Consumer which forwards metrics to the next
+// consumers, dropping the data points of series which are seen after the
+// number of distinct series reached a limit, and counting them in dropped.
+//
+// The connector reports every series it knows of on each flush, so the series
+// which were accepted first keep being forwarded once the limit is reached.
+// Series which weren't forwarded for longer than ttl stop counting against
+// the limit, for example when the connector no longer reports them.
+type seriesLimiter struct {
+	limit   int
+	ttl     time.Duration
+	dropped prometheus.Counter
+	next    otelconsumer.Metrics
+	now     func() time.Time
+
+	mut       sync.Mutex
+	seen      map[string]time.Time // When every series was last forwarded.
+	lastPrune time.Time
+}
+
+var _ otelcol.Consumer = (*seriesLimiter)(nil)
+
+func newSeriesLimiter(limit int, ttl time.Duration, dropped prometheus.Counter, next []otelcol.Consumer) *seriesLimiter {
+	return &seriesLimiter{
+		limit:   limit,
+		ttl:     ttl,
+		dropped: dropped,
+		next:    fanoutconsumer.Metrics(next),
+		now:     time.Now,
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// Capabilities implements otelcol.Consumer.
+func (l *seriesLimiter) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{MutatesData: true}
+}
+
+// ConsumeMetrics implements otelcol.Consumer.
+func (l *seriesLimiter) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	l.mut.Lock()
+	now := l.now()
+	l.prune(now)
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		resource := attributesKey(rm.Resource().Attributes())
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(m pmetric.Metric) bool {
+				return l.filterDataPoints(resource, m, now)
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	l.mut.Unlock()
+
+	if md.ResourceMetrics().Len() == 0 {
+		return nil
+	}
+	return l.next.ConsumeMetrics(ctx, md)
+}
+
+// filterDataPoints removes the data points of m which belong to series over
+// the limit. It returns true if m has no data points left.
+func (l *seriesLimiter) filterDataPoints(resource string, m pmetric.Metric, now time.Time) bool {
+	prefix := resource + "\xfe" + m.Name() + "\xfe"
+	over := func(attrs pcommon.Map) bool {
+		return !l.accept(prefix+attributesKey(attrs), now)
+	}
+
+	switch m.Type() {
+	case pmetric.MetricTypeSum:
+		m.Sum().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool { return over(dp.Attributes()) })
+		return m.Sum().DataPoints().Len() == 0
+	case pmetric.MetricTypeGauge:
+		m.Gauge().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool { return over(dp.Attributes()) })
+		return m.Gauge().DataPoints().Len() == 0
+	case pmetric.MetricTypeHistogram:
+		m.Histogram().DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool { return over(dp.Attributes()) })
+		return m.Histogram().DataPoints().Len() == 0
+	case pmetric.MetricTypeExponentialHistogram:
+		m.ExponentialHistogram().DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool { return over(dp.Attributes()) })
+		return m.ExponentialHistogram().DataPoints().Len() == 0
+	}
+	return false
+}
+
+// accept reports whether the series identified by key is within the limit,
+// recording that it's forwarded at now.
+func (l *seriesLimiter) accept(key string, now time.Time) bool {
+	if _, ok := l.seen[key]; !ok && len(l.seen) >= l.limit {
+		l.dropped.Inc()
+		return false
+	}
+	l.seen[key] = now
+	return true
+}
+
+// prune forgets the series which weren't forwarded for longer than the ttl.
+// The series are checked at most once per ttl.
+func (l *seriesLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.ttl {
+		return
+	}
+	l.lastPrune = now
+	for key, seen := range l.seen {
+		if now.Sub(seen) > l.ttl {
+			delete(l.seen, key)
+		}
+	}
+}
+
+// ConsumeTraces implements otelcol.Consumer.
+func (l *seriesLimiter) ConsumeTraces(context.Context, ptrace.Traces) error { return nil }
+
+// ConsumeLogs implements otelcol.Consumer.
+func (l *seriesLimiter) ConsumeLogs(context.Context, plog.Logs) error { return nil }
+
+// attributesKey returns a string which uniquely identifies attrs, regardless
+// of the order of the attributes.
+func attributesKey(attrs pcommon.Map) string {
+	pairs := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		pairs = append(pairs, k+"\xfd"+v.AsString())
+		return true
+	})
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\xff")
+}
@@ -0,0 +1,61 @@
+package spanmetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestSeriesLimiterExpiration(t *testing.T) {
+	var forwarded []string
+	next := &fakeconsumer.Consumer{
+		ConsumeMetricsFunc: func(_ context.Context, md pmetric.Metrics) error {
+			sm := md.ResourceMetrics().At(0).ScopeMetrics().At(0)
+			for i := 0; i < sm.Metrics().Len(); i++ {
+				forwarded = append(forwarded, sm.Metrics().At(i).Name())
+			}
+			return nil
+		},
+	}
+
+	now := time.Unix(0, 0)
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{})
+	l := newSeriesLimiter(1, time.Minute, dropped, []otelcol.Consumer{next})
+	l.now = func() time.Time { return now }
+
+	consume := func(names ...string) {
+		md := pmetric.NewMetrics()
+		sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+		for _, name := range names {
+			m := sm.Metrics().AppendEmpty()
+			m.SetName(name)
+			m.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+		}
+		forwarded = nil
+		require.NoError(t, l.ConsumeMetrics(context.Background(), md))
+	}
+
+	consume("first", "second")
+	require.Equal(t, []string{"first"}, forwarded)
+	require.Equal(t, 1.0, testutil.ToFloat64(dropped))
+
+	// A series which keeps being forwarded doesn't expire.
+	now = now.Add(50 * time.Second)
+	consume("first", "second")
+	require.Equal(t, []string{"first"}, forwarded)
+	now = now.Add(50 * time.Second)
+	consume("second")
+	require.Empty(t, forwarded)
+
+	// Once it's no longer forwarded, it stops counting against the limit.
+	now = now.Add(2 * time.Minute)
+	consume("second")
+	require.Equal(t, []string{"second"}, forwarded)
+}
@@ -8,25 +8,61 @@ import (
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/connector"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/connector/spanmetricsconnector"
+	"github.com/prometheus/client_golang/prometheus"
 	otelcomponent "go.opentelemetry.io/collector/component"
 	otelextension "go.opentelemetry.io/collector/extension"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.connector.spanmetrics",
-		Args:    Arguments{},
-		Exports: otelcol.ConsumerExports{},
+		Name:      "otelcol.connector.spanmetrics",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
-			fact := spanmetricsconnector.NewFactory()
-			return connector.New(opts, fact, args.(Arguments))
+			return New(opts, args.(Arguments))
 		},
 	})
 }
 
+// Component is the otelcol.connector.spanmetrics component. It wraps the
+// upstream connector to count the data points dropped by max_series.
+type Component struct {
+	*connector.Connector
+	droppedPoints prometheus.Counter
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new otelcol.connector.spanmetrics component.
+func New(opts component.Options, args Arguments) (*Component, error) {
+	droppedPoints := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "otelcol_connector_spanmetrics_dropped_data_points_total",
+		Help: "Number of data points dropped because their series were over max_series.",
+	})
+	if err := opts.Registerer.Register(droppedPoints); err != nil {
+		return nil, err
+	}
+
+	args.droppedPoints = droppedPoints
+	c, err := connector.New(opts, spanmetricsconnector.NewFactory(), args)
+	if err != nil {
+		return nil, err
+	}
+	return &Component{Connector: c, droppedPoints: droppedPoints}, nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+	newArgs.droppedPoints = c.droppedPoints
+	return c.Connector.Update(newArgs)
+}
+
 // Arguments configures the otelcol.connector.spanmetrics component.
 type Arguments struct {
 	// Dimensions defines the list of additional dimensions on top of the provided:
@@ -43,6 +79,11 @@ type Arguments struct {
 	// indefinitely over the lifetime of the collector.
 	DimensionsCacheSize int `river:"dimensions_cache_size,attr,optional"`
 
+	// MaxSeries limits the number of distinct series forwarded to the
+	// components in Output. Data points of series seen after the limit is
+	// reached are dropped. Zero means no limit.
+	MaxSeries int `river:"max_series,attr,optional"`
+
 	AggregationTemporality string `river:"aggregation_temporality,attr,optional"`
 
 	Histogram HistogramConfig `river:"histogram,block"`
@@ -56,6 +97,10 @@ type Arguments struct {
 	// Exemplars defines the configuration for exemplars.
 	Exemplars ExemplarsConfig `river:"exemplars,block,optional"`
 
+	// droppedPoints counts the data points dropped by MaxSeries. It's set by
+	// the component.
+	droppedPoints prometheus.Counter
+
 	// Output configures where to send processed data. Required.
 	Output *otelcol.ConsumerArguments `river:"output,block"`
 }
@@ -91,6 +136,10 @@ func (args *Arguments) Validate() error {
 			args.DimensionsCacheSize)
 	}
 
+	if args.MaxSeries < 0 {
+		return fmt.Errorf("max_series must not be negative")
+	}
+
 	if args.MetricsFlushInterval <= 0 {
 		return fmt.Errorf("metrics_flush_interval must be greater than 0")
 	}
@@ -157,9 +206,20 @@ func (args Arguments) Exporters() map[otelcomponent.DataType]map[otelcomponent.I
 	return nil
 }
 
+// seriesExpirationFlushes is the number of flushes after which a series which
+// wasn't forwarded no longer counts against max_series.
+const seriesExpirationFlushes = 3
+
 // NextConsumers implements connector.Arguments.
 func (args Arguments) NextConsumers() *otelcol.ConsumerArguments {
-	return args.Output
+	if args.MaxSeries == 0 || args.Output == nil {
+		return args.Output
+	}
+
+	next := *args.Output
+	ttl := seriesExpirationFlushes * args.MetricsFlushInterval
+	next.Metrics = []otelcol.Consumer{newSeriesLimiter(args.MaxSeries, ttl, args.droppedPoints, args.Output.Metrics)}
+	return &next
 }
 
 // ConnectorType() int implements connector.Arguments.
@@ -1,13 +1,26 @@
 package spanmetrics_test
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/connector/spanmetrics"
+	otelprometheus "github.com/grafana/agent/component/otelcol/exporter/prometheus"
+	"github.com/grafana/agent/component/otelcol/processor/processortest"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/connector/spanmetricsconnector"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 func getStringPtr(str string) *string {
@@ -331,3 +344,200 @@ func TestArguments_UnmarshalRiver(t *testing.T) {
 		})
 	}
 }
+
+// seriesRecorder records the series appended through it, along with their
+// last value.
+type seriesRecorder struct {
+	mut    sync.Mutex
+	series map[string]float64
+}
+
+func (r *seriesRecorder) append(_ storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.series[l.String()] = v
+	return 0, nil
+}
+
+func (r *seriesRecorder) get(series string) (float64, bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	v, ok := r.series[series]
+	return v, ok
+}
+
+func (r *seriesRecorder) count(name string) int {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	var n int
+	for series := range r.series {
+		lbls, err := parser.ParseMetric(series)
+		if err == nil && lbls.Get(labels.MetricName) == name {
+			n++
+		}
+	}
+	return n
+}
+
+// runSpanMetrics runs an otelcol.connector.spanmetrics component configured
+// by cfg, which forwards metrics through otelcol.exporter.prometheus to a
+// seriesRecorder. It returns the input of the connector.
+func runSpanMetrics(t *testing.T, cfg string) (otelcol.Consumer, *seriesRecorder) {
+	ctx := componenttest.TestContext(t)
+	l := util.TestLogger(t)
+
+	recorder := &seriesRecorder{series: make(map[string]float64)}
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(recorder.append))
+
+	exporter, err := componenttest.NewControllerFromID(l, "otelcol.exporter.prometheus")
+	require.NoError(t, err)
+	exporterArgs := otelprometheus.DefaultArguments
+	exporterArgs.IncludeTargetInfo = false
+	exporterArgs.ForwardTo = []storage.Appendable{sink}
+	go func() {
+		require.NoError(t, exporter.Run(ctx, exporterArgs))
+	}()
+	require.NoError(t, exporter.WaitExports(time.Second))
+
+	var args spanmetrics.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	args.Output = &otelcol.ConsumerArguments{
+		Metrics: []otelcol.Consumer{exporter.Exports().(otelcol.ConsumerExports).Input},
+	}
+
+	connector, err := componenttest.NewControllerFromID(l, "otelcol.connector.spanmetrics")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, connector.Run(ctx, args))
+	}()
+	require.NoError(t, connector.WaitExports(time.Second))
+
+	return connector.Exports().(otelcol.ConsumerExports).Input, recorder
+}
+
+// consumeTraces sends traces to input, retrying until the component behind
+// input started.
+func consumeTraces(t *testing.T, input otelcol.Consumer, traces ptrace.Traces) {
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(context.Background(), traces) == nil
+	}, 5*time.Second, 10*time.Millisecond, "failed to send traces")
+}
+
+func Test_SpanMetricsToPrometheus(t *testing.T) {
+	cfg := `
+	dimension {
+		name = "http.method"
+	}
+	histogram {
+		explicit {
+			buckets = ["100ms", "1s"]
+		}
+	}
+	metrics_flush_interval = "50ms"
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+	input, recorder := runSpanMetrics(t, cfg)
+
+	traces := processortest.CreateTestTraces(`{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "service.name",
+					"value": { "stringValue": "checkout" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /cart",
+					"kind": 2,
+					"startTimeUnixNano": "1000000000",
+					"endTimeUnixNano": "1050000000",
+					"attributes": [{
+						"key": "http.method",
+						"value": { "stringValue": "GET" }
+					}]
+				},{
+					"name": "GET /cart",
+					"kind": 2,
+					"startTimeUnixNano": "2000000000",
+					"endTimeUnixNano": "2500000000",
+					"attributes": [{
+						"key": "http.method",
+						"value": { "stringValue": "GET" }
+					}],
+					"status": { "code": 2 }
+				}]
+			}]
+		}]
+	}`)
+	consumeTraces(t, input, traces)
+
+	const labelsOK = `job="checkout", service_name="checkout", span_kind="SPAN_KIND_SERVER", span_name="GET /cart", status_code="STATUS_CODE_UNSET", http_method="GET"`
+	const labelsError = `job="checkout", service_name="checkout", span_kind="SPAN_KIND_SERVER", span_name="GET /cart", status_code="STATUS_CODE_ERROR", http_method="GET"`
+	expect := map[string]float64{
+		`calls_total{` + labelsOK + `}`:                                1,
+		`calls_total{` + labelsError + `}`:                             1,
+		`duration_milliseconds_bucket{` + labelsOK + `, le="100"}`:     1,
+		`duration_milliseconds_bucket{` + labelsError + `, le="100"}`:  0,
+		`duration_milliseconds_bucket{` + labelsError + `, le="1000"}`: 1,
+		`duration_milliseconds_count{` + labelsError + `}`:             1,
+		`duration_milliseconds_sum{` + labelsError + `}`:               500,
+		`duration_milliseconds_sum{` + labelsOK + `}`:                  50,
+	}
+
+	for series, value := range expect {
+		series, err := parser.ParseMetric(series)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			v, ok := recorder.get(series.String())
+			return ok && v == value
+		}, 5*time.Second, 10*time.Millisecond, "series %s with value %v not found", series, value)
+	}
+}
+
+func Test_SpanMetricsMaxSeries(t *testing.T) {
+	cfg := `
+	histogram {
+		disable = true
+		explicit {}
+	}
+	metrics_flush_interval = "50ms"
+	max_series             = 2
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+	input, recorder := runSpanMetrics(t, cfg)
+
+	traces := processortest.CreateTestTraces(`{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "service.name",
+					"value": { "stringValue": "checkout" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [
+					{ "name": "first" },
+					{ "name": "second" },
+					{ "name": "third" }
+				]
+			}]
+		}]
+	}`)
+	consumeTraces(t, input, traces)
+
+	require.Eventually(t, func() bool {
+		return recorder.count("calls_total") == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The series over the limit are never forwarded, even once the connector
+	// flushed its metrics several times.
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, 2, recorder.count("calls_total"))
+}
@@ -0,0 +1,162 @@
+package tee
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// tee sends all the data to its output, and a fraction of it to its tee
+// consumers.
+type tee struct {
+	threshold uint64
+
+	traces, teeTraces   otelconsumer.Traces
+	metrics, teeMetrics otelconsumer.Metrics
+	logs, teeLogs       otelconsumer.Logs
+}
+
+func newTee(args Arguments) *tee {
+	t := &tee{
+		traces:     fanoutconsumer.Traces(args.Output.Traces),
+		metrics:    fanoutconsumer.Metrics(args.Output.Metrics),
+		logs:       fanoutconsumer.Logs(args.Output.Logs),
+		teeTraces:  fanoutconsumer.Traces(args.Tee.Traces),
+		teeMetrics: fanoutconsumer.Metrics(args.Tee.Metrics),
+		teeLogs:    fanoutconsumer.Logs(args.Tee.Logs),
+	}
+	if args.Percentage >= 100 {
+		t.threshold = math.MaxUint64
+	} else {
+		t.threshold = uint64(args.Percentage / 100 * math.MaxUint64)
+	}
+	return t
+}
+
+// teed reports whether the data with the given hash is sent to the tee
+// consumers.
+func (t *tee) teed(hash uint64) bool {
+	return t.threshold != 0 && hash <= t.threshold
+}
+
+// hashResource returns the hash of the attributes of res.
+func hashResource(res pcommon.Resource) uint64 {
+	attrs := res.Attributes()
+	keys := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, _ pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+
+	h := xxhash.New()
+	for _, k := range keys {
+		v, _ := attrs.Get(k)
+		_, _ = h.WriteString(k)
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.WriteString(v.AsString())
+		_, _ = h.Write([]byte{0xff})
+	}
+	return h.Sum64()
+}
+
+// consumer sends all the data to the output of its tee, and a copy of the
+// selected data to the tee consumers. Traces are selected by their trace ID,
+// so that all the spans of a trace are sent together, and metrics and logs by
+// their resource.
+type consumer struct {
+	mut sync.RWMutex
+	tee *tee
+}
+
+var _ otelcol.Consumer = (*consumer)(nil)
+
+// SetTee sets the tee of the data consumed afterwards.
+func (c *consumer) SetTee(t *tee) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.tee = t
+}
+
+func (c *consumer) getTee() *tee {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.tee
+}
+
+// Capabilities implements otelcol.Consumer.
+func (c *consumer) Capabilities() otelconsumer.Capabilities {
+	// The teed data is copied, so it's never mutated.
+	return otelconsumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeTraces implements otelcol.ConsumeTraces. Errors from the tee
+// consumers are ignored, so that they don't affect the output.
+func (c *consumer) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	t := c.getTee()
+
+	if t.threshold != 0 {
+		teed := ptrace.NewTraces()
+		td.CopyTo(teed)
+		teed.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+			rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+				ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+					id := span.TraceID()
+					return !t.teed(xxhash.Sum64(id[:]))
+				})
+				return ss.Spans().Len() == 0
+			})
+			return rs.ScopeSpans().Len() == 0
+		})
+		if teed.ResourceSpans().Len() > 0 {
+			_ = t.teeTraces.ConsumeTraces(ctx, teed)
+		}
+	}
+	return t.traces.ConsumeTraces(ctx, td)
+}
+
+// ConsumeMetrics implements otelcol.ConsumeMetrics. Errors from the tee
+// consumers are ignored, so that they don't affect the output.
+func (c *consumer) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	t := c.getTee()
+
+	teed := pmetric.NewMetrics()
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		if rm := rms.At(i); t.teed(hashResource(rm.Resource())) {
+			rm.CopyTo(teed.ResourceMetrics().AppendEmpty())
+		}
+	}
+	if teed.ResourceMetrics().Len() > 0 {
+		_ = t.teeMetrics.ConsumeMetrics(ctx, teed)
+	}
+	return t.metrics.ConsumeMetrics(ctx, md)
+}
+
+// ConsumeLogs implements otelcol.ConsumeLogs. Errors from the tee consumers
+// are ignored, so that they don't affect the output.
+func (c *consumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	t := c.getTee()
+
+	teed := plog.NewLogs()
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		if rl := rls.At(i); t.teed(hashResource(rl.Resource())) {
+			rl.CopyTo(teed.ResourceLogs().AppendEmpty())
+		}
+	}
+	if teed.ResourceLogs().Len() > 0 {
+		_ = t.teeLogs.ConsumeLogs(ctx, teed)
+	}
+	return t.logs.ConsumeLogs(ctx, ld)
+}
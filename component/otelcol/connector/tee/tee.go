@@ -0,0 +1,87 @@
+// Package tee provides an otelcol.connector.tee component.
+package tee
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/lazyconsumer"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/river"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.connector.tee",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
+
+		Build: func(o component.Options, a component.Arguments) (component.Component, error) {
+			return New(o, a.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the otelcol.connector.tee component.
+type Arguments struct {
+	// Percentage of traces and resources sent to Tee.
+	Percentage float64 `river:"percentage,attr"`
+
+	// Output configures where to send all the data. Required.
+	Output *otelcol.ConsumerArguments `river:"output,block"`
+	// Tee configures where to additionally send a fraction of the data.
+	// Required.
+	Tee *otelcol.ConsumerArguments `river:"tee,block"`
+}
+
+var _ river.Validator = (*Arguments)(nil)
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Percentage < 0 || args.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, got %v", args.Percentage)
+	}
+	return nil
+}
+
+// Component is the otelcol.connector.tee component.
+type Component struct {
+	opts     component.Options
+	consumer *consumer
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new otelcol.connector.tee component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	res := &Component{
+		opts:     o,
+		consumer: &consumer{},
+	}
+	if err := res.Update(args); err != nil {
+		return nil, err
+	}
+
+	// Export the consumer. This will remain the same throughout the
+	// component's lifetime, so we do this during component construction.
+	export := lazyconsumer.New(context.Background())
+	export.SetConsumers(res.consumer, res.consumer, res.consumer)
+	o.OnStateChange(otelcol.ConsumerExports{Input: export})
+
+	return res, nil
+}
+
+// Run implements Component.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements Component.
+func (c *Component) Update(newConfig component.Arguments) error {
+	c.consumer.SetTee(newTee(newConfig.(Arguments)))
+	return nil
+}
@@ -0,0 +1,183 @@
+package tee_test
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/connector/tee"
+	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// sink records the names of the spans and the bodies of the log records it
+// receives.
+type sink struct {
+	mut   sync.Mutex
+	names map[string]int
+}
+
+func (s *sink) output() *otelcol.ConsumerArguments {
+	s.names = make(map[string]int)
+	c := &fakeconsumer.Consumer{
+		ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			rss := td.ResourceSpans()
+			for i := 0; i < rss.Len(); i++ {
+				spans := rss.At(i).ScopeSpans().At(0).Spans()
+				for j := 0; j < spans.Len(); j++ {
+					s.names[spans.At(j).Name()]++
+				}
+			}
+			return nil
+		},
+		ConsumeLogsFunc: func(_ context.Context, ld plog.Logs) error {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			rls := ld.ResourceLogs()
+			for i := 0; i < rls.Len(); i++ {
+				records := rls.At(i).ScopeLogs().At(0).LogRecords()
+				for j := 0; j < records.Len(); j++ {
+					s.names[records.At(j).Body().AsString()]++
+				}
+			}
+			return nil
+		},
+	}
+	return &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{c},
+		Logs:   []otelcol.Consumer{c},
+	}
+}
+
+func (s *sink) received() map[string]int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	res := make(map[string]int, len(s.names))
+	for name, n := range s.names {
+		res[name] = n
+	}
+	return res
+}
+
+// startTee runs otelcol.connector.tee with cfg, and returns its input and the
+// sinks of its output and tee blocks.
+func startTee(t *testing.T, cfg string) (otelcol.Consumer, *sink, *sink) {
+	t.Helper()
+
+	var args tee.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	output, teed := &sink{}, &sink{}
+	args.Output = output.output()
+	args.Tee = teed.output()
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.connector.tee")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, ctrl.Run(componenttest.TestContext(t), args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second))
+	require.NoError(t, ctrl.WaitExports(time.Second))
+
+	return ctrl.Exports().(otelcol.ConsumerExports).Input, output, teed
+}
+
+const items = 2000
+
+func TestTeeTraces(t *testing.T) {
+	input, output, teed := startTee(t, `
+		percentage = 10
+		output {}
+		tee {}
+	`)
+
+	// Every trace has a root span and a child span, in two resources.
+	td := ptrace.NewTraces()
+	for _, kind := range []string{"root", "child"} {
+		spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+		for i := 0; i < items; i++ {
+			var id pcommon.TraceID
+			binary.BigEndian.PutUint64(id[8:], uint64(i))
+			span := spans.AppendEmpty()
+			span.SetTraceID(id)
+			span.SetName(kind + "-" + strconv.Itoa(i))
+		}
+	}
+
+	// The input only accepts data once the component is running.
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(context.Background(), td) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Len(t, output.received(), 2*items)
+	received := teed.received()
+	require.InDelta(t, 2*items/10, len(received), items/100*6, "unexpected number of teed spans")
+	// All the spans of a trace are teed together.
+	for i := 0; i < items; i++ {
+		_, root := received["root-"+strconv.Itoa(i)]
+		_, child := received["child-"+strconv.Itoa(i)]
+		require.Equal(t, root, child)
+	}
+}
+
+func TestTeeLogs(t *testing.T) {
+	input, output, teed := startTee(t, `
+		percentage = 10
+		output {}
+		tee {}
+	`)
+
+	ld := plog.NewLogs()
+	for i := 0; i < items; i++ {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.instance.id", strconv.Itoa(i))
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr(strconv.Itoa(i))
+	}
+	require.Eventually(t, func() bool {
+		return input.ConsumeLogs(context.Background(), ld) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Len(t, output.received(), items)
+	before := teed.received()
+	require.InDelta(t, items/10, len(before), items/100*3, "unexpected number of teed resources")
+
+	// The same resources are teed every time.
+	require.NoError(t, input.ConsumeLogs(context.Background(), ld))
+	for name, n := range teed.received() {
+		require.Equal(t, 2, n)
+		require.Contains(t, before, name)
+	}
+}
+
+func TestTeeBounds(t *testing.T) {
+	input, output, teed := startTee(t, `
+		percentage = 0
+		output {}
+		tee {}
+	`)
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("line")
+	require.Eventually(t, func() bool {
+		return input.ConsumeLogs(context.Background(), ld) == nil
+	}, time.Second, 10*time.Millisecond)
+	require.Len(t, output.received(), 1)
+	require.Empty(t, teed.received())
+
+	var args tee.Arguments
+	require.Error(t, river.Unmarshal([]byte(`
+		percentage = 110
+		output {}
+		tee {}
+	`), &args))
+}
@@ -6,14 +6,17 @@ import (
 	"context"
 	"errors"
 	"os"
+	"path/filepath"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/internal/lazycollector"
 	"github.com/grafana/agent/component/otelcol/internal/lazyconsumer"
+	"github.com/grafana/agent/component/otelcol/internal/pipelinestats"
 	"github.com/grafana/agent/component/otelcol/internal/scheduler"
 	"github.com/grafana/agent/component/otelcol/internal/views"
 	"github.com/grafana/agent/pkg/build"
+	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/util/zapadapter"
 	"github.com/prometheus/client_golang/prometheus"
 	otelcomponent "go.opentelemetry.io/collector/component"
@@ -80,9 +83,11 @@ type Exporter struct {
 	opts     component.Options
 	factory  otelexporter.Factory
 	consumer *lazyconsumer.Consumer
+	stats    *pipelinestats.Stats
 
-	sched     *scheduler.Scheduler
-	collector *lazycollector.Collector
+	sched        *scheduler.Scheduler
+	collector    *lazycollector.Collector
+	queueStorage *queueStorage
 
 	// Signals which the exporter is able to export.
 	// Can be logs, metrics, traces or any combination of them.
@@ -90,8 +95,10 @@ type Exporter struct {
 }
 
 var (
-	_ component.Component       = (*Exporter)(nil)
-	_ component.HealthComponent = (*Exporter)(nil)
+	_ component.Component          = (*Exporter)(nil)
+	_ component.HealthComponent    = (*Exporter)(nil)
+	_ component.DebugComponent     = (*Exporter)(nil)
+	_ component.DrainableComponent = (*Exporter)(nil)
 )
 
 // New creates a new Flow component which encapsulates an OpenTelemetry
@@ -124,12 +131,31 @@ func New(opts component.Options, f otelexporter.Factory, args Arguments, support
 		opts:     opts,
 		factory:  f,
 		consumer: consumer,
+		stats:    pipelinestats.New(),
 
 		sched:     scheduler.New(opts.Logger),
 		collector: collector,
 
+		// Persistent sending queues store their data in the data directory of
+		// the component, through a storage extension exposed to the exporters.
+		queueStorage: newQueueStorage(
+			opts.Logger,
+			filepath.Join(opts.DataPath, "sending_queue"),
+			otelextension.CreateSettings{
+				ID: otelcol.QueueStorageID,
+				TelemetrySettings: otelcomponent.TelemetrySettings{
+					Logger:         zapadapter.New(opts.Logger),
+					TracerProvider: opts.Tracer,
+					MeterProvider:  metric.NewMeterProvider(),
+				},
+			},
+		),
+
 		supportedSignals: supportedSignals,
 	}
+	if err := e.stats.CountThroughput(opts.Registerer, false); err != nil {
+		return nil, err
+	}
 	if err := e.Update(args); err != nil {
 		return nil, err
 	}
@@ -139,7 +165,14 @@ func New(opts component.Options, f otelexporter.Factory, args Arguments, support
 // Run starts the Exporter component.
 func (e *Exporter) Run(ctx context.Context) error {
 	defer e.cancel()
-	return e.sched.Run(ctx)
+	err := e.sched.Run(ctx)
+
+	// The exporters are stopped once the scheduler returns, any queue client
+	// they left open can be closed.
+	if shutdownErr := e.queueStorage.Shutdown(context.Background()); shutdownErr != nil {
+		level.Warn(e.opts.Logger).Log("msg", "failed to close persistent queues", "err", shutdownErr)
+	}
+	return err
 }
 
 // Update implements component.Component. It will convert the Arguments into
@@ -148,12 +181,6 @@ func (e *Exporter) Run(ctx context.Context) error {
 func (e *Exporter) Update(args component.Arguments) error {
 	eargs := args.(Arguments)
 
-	host := scheduler.NewHost(
-		e.opts.Logger,
-		scheduler.WithHostExtensions(eargs.Extensions()),
-		scheduler.WithHostExporters(eargs.Exporters()),
-	)
-
 	reg := prometheus.NewRegistry()
 	e.collector.Set(reg)
 
@@ -191,12 +218,28 @@ func (e *Exporter) Update(args component.Arguments) error {
 		return err
 	}
 
+	if err := e.queueStorage.metrics.register(reg); err != nil {
+		return err
+	}
+	extensions := make(map[otelcomponent.ID]otelextension.Extension)
+	for id, ext := range eargs.Extensions() {
+		extensions[id] = ext
+	}
+	extensions[otelcol.QueueStorageID] = e.queueStorage
+
+	host := scheduler.NewHost(
+		e.opts.Logger,
+		scheduler.WithHostExtensions(extensions),
+		scheduler.WithHostExporters(eargs.Exporters()),
+	)
+
 	// Create instances of the exporter from our factory for each of our
 	// supported telemetry signals.
 	var components []otelcomponent.Component
 
 	var tracesExporter otelexporter.Traces
 	if e.supportedSignals.SupportsTraces() {
+		settings.ID = e.exporterID(otelcomponent.DataTypeTraces)
 		tracesExporter, err = e.factory.CreateTracesExporter(e.ctx, settings, exporterConfig)
 		if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
 			return err
@@ -207,6 +250,7 @@ func (e *Exporter) Update(args component.Arguments) error {
 
 	var metricsExporter otelexporter.Metrics
 	if e.supportedSignals.SupportsMetrics() {
+		settings.ID = e.exporterID(otelcomponent.DataTypeMetrics)
 		metricsExporter, err = e.factory.CreateMetricsExporter(e.ctx, settings, exporterConfig)
 		if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
 			return err
@@ -217,6 +261,7 @@ func (e *Exporter) Update(args component.Arguments) error {
 
 	var logsExporter otelexporter.Logs
 	if e.supportedSignals.SupportsLogs() {
+		settings.ID = e.exporterID(otelcomponent.DataTypeLogs)
 		logsExporter, err = e.factory.CreateLogsExporter(e.ctx, settings, exporterConfig)
 		if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
 			return err
@@ -225,13 +270,49 @@ func (e *Exporter) Update(args component.Arguments) error {
 		}
 	}
 
-	// Schedule the components to run once our component is running.
-	e.sched.Schedule(host, components...)
-	e.consumer.SetConsumers(tracesExporter, metricsExporter, logsExporter)
+	// The sending queues of the exporters report their metrics to a registry
+	// shared by the whole process, from which only the metrics of this
+	// component's exporters are collected.
+	queueIDs := make(map[string]otelcomponent.DataType)
+	for _, dataType := range []otelcomponent.DataType{otelcomponent.DataTypeTraces, otelcomponent.DataTypeMetrics, otelcomponent.DataTypeLogs} {
+		queueIDs[e.exporterID(dataType).String()] = dataType
+	}
+	if err := reg.Register(newQueueCollector(queueIDs)); err != nil {
+		return err
+	}
+
+	// Schedule the components to run once our component is running. Data is
+	// sent to them rather than to the components they replace right away, but
+	// it's held back until they've been started.
+	ready := e.consumer.SetPendingConsumers(
+		e.stats.InputTraces(tracesExporter),
+		e.stats.InputMetrics(metricsExporter),
+		e.stats.InputLogs(logsExporter),
+	)
+	e.sched.ScheduleWithCallback(host, ready, components...)
 	return nil
 }
 
+// exporterID returns the ID of the upstream exporter for the given telemetry
+// signal. Every signal of every component gets a distinct ID, since upstream
+// queue metrics are keyed by exporter ID for the whole process.
+func (e *Exporter) exporterID(dataType otelcomponent.DataType) otelcomponent.ID {
+	return otelcomponent.NewIDWithName(e.factory.Type(), e.opts.ID+"."+string(dataType))
+}
+
+// Drain implements component.DrainableComponent. The upstream exporters are
+// shut down, sending the data they buffer, and no longer accept data.
+func (e *Exporter) Drain(ctx context.Context) error {
+	e.consumer.SetConsumers(nil, nil, nil)
+	return e.sched.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (e *Exporter) CurrentHealth() component.Health {
 	return e.sched.CurrentHealth()
 }
+
+// DebugInfo implements component.DebugComponent.
+func (e *Exporter) DebugInfo() interface{} {
+	return e.stats.DebugInfo()
+}
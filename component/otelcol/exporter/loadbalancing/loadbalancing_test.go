@@ -1,10 +1,18 @@
 package loadbalancing_test
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/exporter/loadbalancing"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
 	"github.com/stretchr/testify/require"
@@ -12,6 +20,10 @@ import (
 	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
 )
 
 func TestConfigConversion(t *testing.T) {
@@ -268,3 +280,219 @@ func TestConfigConversion(t *testing.T) {
 		})
 	}
 }
+
+// TestTraceRouting runs the otelcol.exporter.loadbalancing component in front
+// of fake OTLP receivers, and ensures that all spans of a trace are sent to
+// the same receiver while traces are balanced across receivers.
+func TestTraceRouting(t *testing.T) {
+	const (
+		traceCount    = 200
+		spansPerTrace = 3
+	)
+
+	backends := []*fakeBackend{newFakeBackend(t), newFakeBackend(t)}
+
+	ctx := componenttest.TestContext(t)
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.exporter.loadbalancing")
+	require.NoError(t, err)
+
+	args := staticArguments(t, backends...)
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second), "component never started")
+	require.NoError(t, ctrl.WaitExports(time.Second), "component never exported anything")
+	input := ctrl.Exports().(otelcol.ConsumerExports).Input
+
+	owners := sendTraces(t, input, backends, traceCount, spansPerTrace)
+
+	// Every backend must receive a fair share of the traces.
+	for i, b := range backends {
+		received := b.traceCount()
+		require.Greater(t, received, traceCount/4, "backend %d received too few traces", i)
+	}
+
+	// Adding a backend must only move the traces which now belong to the new
+	// backend, rather than re-shuffling all of them.
+	backends = append(backends, newFakeBackend(t))
+	for _, b := range backends {
+		b.reset()
+	}
+	require.NoError(t, ctrl.Update(staticArguments(t, backends...)))
+
+	newOwners := sendTraces(t, input, backends, traceCount, spansPerTrace)
+
+	var moved, movedToNew int
+	for traceID, owner := range owners {
+		if newOwners[traceID] == owner {
+			continue
+		}
+		moved++
+		if newOwners[traceID] == 2 {
+			movedToNew++
+		}
+	}
+	require.Greater(t, movedToNew, 0, "no traces were sent to the new backend")
+	require.Equal(t, moved, movedToNew, "traces moved between the existing backends")
+	require.Less(t, moved, traceCount/2, "too many traces moved after adding a backend")
+}
+
+// staticArguments returns Arguments which balance traces across the given
+// backends, without queueing or retrying requests.
+func staticArguments(t *testing.T, backends ...*fakeBackend) loadbalancing.Arguments {
+	t.Helper()
+
+	hostnames := make([]string, 0, len(backends))
+	for _, b := range backends {
+		hostnames = append(hostnames, fmt.Sprintf("%q", b.addr))
+	}
+
+	cfg := fmt.Sprintf(`
+		resolver {
+			static {
+				hostnames = [%s]
+			}
+		}
+		protocol {
+			otlp {
+				queue {
+					enabled = false
+				}
+				retry {
+					enabled = false
+				}
+				client {
+					compression = "none"
+					tls {
+						insecure = true
+					}
+				}
+			}
+		}
+	`, strings.Join(hostnames, ", "))
+
+	var args loadbalancing.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	return args
+}
+
+// sendTraces sends count traces of spans spans each to input, and waits for
+// the backends to receive all of them. It returns the index of the backend
+// which received each trace, failing the test if the spans of a trace were
+// split across backends.
+func sendTraces(t *testing.T, input otelcol.Consumer, backends []*fakeBackend, count, spans int) map[pcommon.TraceID]int {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		traces := ptrace.NewTraces()
+		ss := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+		for j := 0; j < spans; j++ {
+			span := ss.AppendEmpty()
+			span.SetName(fmt.Sprintf("span-%d", j))
+			span.SetTraceID(pcommon.TraceID{byte(i), byte(i >> 8), 0xff})
+			span.SetSpanID(pcommon.SpanID{byte(j + 1)})
+		}
+
+		// The exporter rejects data until it has been started and has
+		// resolved its backends.
+		require.Eventually(t, func() bool {
+			return input.ConsumeTraces(context.Background(), traces) == nil
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	require.Eventually(t, func() bool {
+		var received int
+		for _, b := range backends {
+			received += b.spanCount()
+		}
+		return received == count*spans
+	}, 5*time.Second, 10*time.Millisecond)
+
+	owners := make(map[pcommon.TraceID]int, count)
+	for i, b := range backends {
+		b.mut.Lock()
+		for traceID, n := range b.spans {
+			_, seen := owners[traceID]
+			require.False(t, seen, "trace %s was sent to multiple backends", traceID)
+			require.Equal(t, spans, n, "backend %d received a partial trace %s", i, traceID)
+			owners[traceID] = i
+		}
+		b.mut.Unlock()
+	}
+	require.Len(t, owners, count)
+	return owners
+}
+
+// fakeBackend is an OTLP gRPC server which records the number of spans it
+// receives per trace.
+type fakeBackend struct {
+	ptraceotlp.UnimplementedGRPCServer
+	addr string
+
+	mut   sync.Mutex
+	spans map[pcommon.TraceID]int
+}
+
+var _ ptraceotlp.GRPCServer = (*fakeBackend)(nil)
+
+func newFakeBackend(t *testing.T) *fakeBackend {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	b := &fakeBackend{
+		addr:  lis.Addr().String(),
+		spans: make(map[pcommon.TraceID]int),
+	}
+
+	srv := grpc.NewServer()
+	ptraceotlp.RegisterGRPCServer(srv, b)
+
+	go func() {
+		require.NoError(t, srv.Serve(lis))
+	}()
+	t.Cleanup(srv.Stop)
+
+	return b
+}
+
+func (b *fakeBackend) Export(_ context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	rss := req.Traces().ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				b.spans[spans.At(k).TraceID()]++
+			}
+		}
+	}
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+func (b *fakeBackend) traceCount() int {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return len(b.spans)
+}
+
+func (b *fakeBackend) spanCount() int {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	var total int
+	for _, n := range b.spans {
+		total += n
+	}
+	return total
+}
+
+func (b *fakeBackend) reset() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.spans = make(map[pcommon.TraceID]int)
+}
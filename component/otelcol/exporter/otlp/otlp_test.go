@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/exporter/otlp"
 	"github.com/grafana/agent/pkg/flow/componenttest"
@@ -14,10 +18,15 @@ import (
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.opentelemetry.io/otel/trace/noop"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Test performs a basic integration test which runs the otelcol.exporter.otlp
@@ -143,3 +152,387 @@ func createTestTraces() ptrace.Traces {
 	}
 	return data
 }
+
+// TestRetryTransientErrors ensures that data sent while the OTLP server
+// returns transient errors is buffered in the sending queue and delivered once
+// the server recovers, without dropping anything.
+func TestRetryTransientErrors(t *testing.T) {
+	server := newFlakyTracesServer(t)
+	server.setFailing(true)
+
+	reg := prometheus.NewRegistry()
+	input := runExporter(t, reg, fmt.Sprintf(`
+		client {
+			endpoint    = "%s"
+			compression = "none"
+
+			tls {
+				insecure = true
+			}
+		}
+
+		sending_queue {
+			num_consumers = 1
+			queue_size    = 100
+		}
+
+		retry_on_failure {
+			initial_interval     = "10ms"
+			randomization_factor = 0
+			max_interval         = "50ms"
+			max_elapsed_time     = "1m"
+		}
+	`, server.addr))
+
+	const batches = 5
+	for i := 0; i < batches; i++ {
+		consumeTraces(t, input, createTestTraces())
+	}
+
+	// The only consumer keeps retrying the first batch, so the other batches
+	// wait in the queue.
+	require.Eventually(t, func() bool {
+		return gatherValue(t, reg, "exporter_queue_size") == batches-1 && server.attempts() > 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0, server.receivedSpans())
+
+	server.setFailing(false)
+	require.Eventually(t, func() bool {
+		return server.receivedSpans() == batches
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return gatherValue(t, reg, "exporter_queue_size") == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0.0, gatherValue(t, reg, "exporter_enqueue_failed_spans_total"))
+}
+
+// TestDropWhenQueueFull ensures that data is only dropped, and counted as
+// dropped, once the sending queue is full because the OTLP server keeps
+// failing.
+func TestDropWhenQueueFull(t *testing.T) {
+	server := newFlakyTracesServer(t)
+	server.setFailing(true)
+
+	reg := prometheus.NewRegistry()
+	input := runExporter(t, reg, fmt.Sprintf(`
+		client {
+			endpoint    = "%s"
+			compression = "none"
+
+			tls {
+				insecure = true
+			}
+		}
+
+		sending_queue {
+			num_consumers = 1
+			queue_size    = 2
+		}
+
+		retry_on_failure {
+			initial_interval = "10ms"
+			max_interval     = "50ms"
+			max_elapsed_time = "1m"
+		}
+	`, server.addr))
+
+	// Wait for the consumer to pick up the first batch, so that the contents
+	// of the queue are predictable.
+	consumeTraces(t, input, createTestTraces())
+	require.Eventually(t, func() bool { return server.attempts() > 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2.0, gatherValue(t, reg, "exporter_queue_capacity"))
+
+	var refused int
+	for i := 0; i < 10; i++ {
+		if err := input.ConsumeTraces(context.Background(), createTestTraces()); err != nil {
+			refused++
+		}
+	}
+
+	// Two batches fit in the queue, the others are dropped.
+	require.Equal(t, 8, refused)
+	require.Equal(t, 2.0, gatherValue(t, reg, "exporter_queue_size"))
+	require.Equal(t, 8.0, gatherValue(t, reg, "exporter_enqueue_failed_spans_total"))
+	require.Equal(t, 0, server.receivedSpans())
+}
+
+const persistentQueueConfig = `
+	timeout = "250ms"
+
+	client {
+		endpoint    = "%s"
+		compression = "none"
+
+		tls {
+			insecure = true
+		}
+	}
+
+	sending_queue {
+		num_consumers = 1
+		persistent    = true
+	}
+
+	retry_on_failure {
+		initial_interval = "10ms"
+		max_interval     = "50ms"
+		max_elapsed_time = "1m"
+	}
+`
+
+// TestPersistentQueueSurvivesRestart ensures that data queued while the OTLP
+// server is down is kept on disk when the component stops, and delivered by
+// the next component using the same data directory.
+func TestPersistentQueueSurvivesRestart(t *testing.T) {
+	addr := unusedAddr(t)
+	dataPath := t.TempDir()
+	cfg := fmt.Sprintf(persistentQueueConfig, addr)
+
+	queueData(t, dataPath, cfg, 3)
+
+	server := newFlakyTracesServerAt(t, addr)
+	runPersistentExporter(t, dataPath, cfg)
+	require.Eventually(t, func() bool {
+		return server.receivedSpans() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestPersistentQueueSkipsCorruptedItems ensures that queued items which
+// can't be decoded after a restart are skipped and counted, while the other
+// items are still delivered.
+func TestPersistentQueueSkipsCorruptedItems(t *testing.T) {
+	addr := unusedAddr(t)
+	dataPath := t.TempDir()
+	cfg := fmt.Sprintf(persistentQueueConfig, addr)
+
+	queueData(t, dataPath, cfg, 3)
+
+	// Items are stored under their index in the queue.
+	db, err := bbolt.Open(queueFile(t, dataPath), 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("default")).Put([]byte("2"), []byte("not a trace"))
+	}))
+	require.NoError(t, db.Close())
+
+	server := newFlakyTracesServerAt(t, addr)
+	reg := runPersistentExporter(t, dataPath, cfg)
+	require.Eventually(t, func() bool {
+		return server.receivedSpans() == 2 && gatherValue(t, reg, "exporter_queue_storage_corrupted_items_total") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestPersistentQueueDiscardsCorruptedFile ensures that a queue file which
+// isn't a valid database is moved aside, so that the component can still
+// queue and deliver new data.
+func TestPersistentQueueDiscardsCorruptedFile(t *testing.T) {
+	server := newFlakyTracesServer(t)
+	dataPath := t.TempDir()
+
+	path := queueFile(t, dataPath)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, []byte("not a database"), 0600))
+
+	reg := runPersistentExporter(t, dataPath, fmt.Sprintf(persistentQueueConfig, server.addr))
+	consumeTraces(t, reg.input, createTestTraces())
+
+	require.Eventually(t, func() bool {
+		return server.receivedSpans() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1.0, gatherValue(t, reg, "exporter_queue_storage_corrupted_files_total"))
+	require.FileExists(t, path+".corrupted")
+}
+
+// queueData runs a component with a persistent queue until it holds count
+// batches which can't be delivered, and then stops it.
+func queueData(t *testing.T, dataPath string, cfg string, count int) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(componenttest.TestContext(t))
+	reg := prometheus.NewRegistry()
+	input, stopped := startExporter(ctx, t, reg, dataPath, cfg)
+
+	for i := 0; i < count; i++ {
+		consumeTraces(t, input, createTestTraces())
+	}
+
+	// The only consumer keeps retrying the first batch, while the queue holds
+	// the second one ready for it.
+	require.Eventually(t, func() bool {
+		return gatherValue(t, reg, "exporter_queue_size") == float64(count-2)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-stopped
+}
+
+type persistentExporter struct {
+	*prometheus.Registry
+	input otelcol.Consumer
+}
+
+// runPersistentExporter runs a component with a persistent queue stored in
+// dataPath.
+func runPersistentExporter(t *testing.T, dataPath string, cfg string) persistentExporter {
+	reg := prometheus.NewRegistry()
+	input, _ := startExporter(componenttest.TestContext(t), t, reg, dataPath, cfg)
+	return persistentExporter{Registry: reg, input: input}
+}
+
+// queueFile returns the path of the file storing the traces queue of the
+// component run by the current test.
+func queueFile(t *testing.T, dataPath string) string {
+	name := fmt.Sprintf("otelcol.exporter.otlp.%s.traces", t.Name())
+	return filepath.Join(dataPath, "sending_queue", fmt.Sprintf("exporter_otlp_%s_traces", name))
+}
+
+// unusedAddr returns an address nothing listens on.
+func unusedAddr(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	return addr
+}
+
+// runExporter runs an otelcol.exporter.otlp component configured by cfg and
+// returns its input once it's running. The metrics of the component are
+// registered to reg.
+func runExporter(t *testing.T, reg prometheus.Registerer, cfg string) otelcol.Consumer {
+	input, _ := startExporter(componenttest.TestContext(t), t, reg, t.TempDir(), cfg)
+	return input
+}
+
+// startExporter is like runExporter, but runs the component until ctx is
+// canceled, storing its data in dataPath. The returned channel is closed once
+// the component stopped.
+func startExporter(ctx context.Context, t *testing.T, reg prometheus.Registerer, dataPath string, cfg string) (otelcol.Consumer, <-chan struct{}) {
+	var args otlp.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	registration, ok := component.Get("otelcol.exporter.otlp")
+	require.True(t, ok)
+
+	exportsCh := make(chan otelcol.ConsumerExports, 1)
+	c, err := registration.Build(component.Options{
+		// Queue metrics are shared by the whole process, so every test needs
+		// its own component ID.
+		ID:            "otelcol.exporter.otlp." + t.Name(),
+		Logger:        util.TestFlowLogger(t),
+		Tracer:        noop.NewTracerProvider(),
+		DataPath:      dataPath,
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) { exportsCh <- e.(otelcol.ConsumerExports) },
+	}, args)
+	require.NoError(t, err)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	require.Eventually(t, func() bool {
+		return c.(component.HealthComponent).CurrentHealth().Health == component.HealthTypeHealthy
+	}, 5*time.Second, 10*time.Millisecond)
+	return (<-exportsCh).Input, stopped
+}
+
+func consumeTraces(t *testing.T, input otelcol.Consumer, td ptrace.Traces) {
+	t.Helper()
+	require.NoError(t, input.ConsumeTraces(context.Background(), td))
+}
+
+// gatherValue returns the value of the metric with the given name in reg. For
+// per-signal metrics, the value of the traces series is returned.
+func gatherValue(t *testing.T, reg prometheus.Gatherer, name string) float64 {
+	t.Helper()
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "data_type" && l.GetValue() != "traces" {
+					continue metrics
+				}
+			}
+			return m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// flakyTracesServer is an OTLP gRPC server which returns a transient error for
+// every request while it's failing.
+type flakyTracesServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	addr string
+
+	mut      sync.Mutex
+	failing  bool
+	attempt  int
+	received int
+}
+
+var _ ptraceotlp.GRPCServer = (*flakyTracesServer)(nil)
+
+func newFlakyTracesServer(t *testing.T) *flakyTracesServer {
+	return newFlakyTracesServerAt(t, "127.0.0.1:0")
+}
+
+func newFlakyTracesServerAt(t *testing.T, addr string) *flakyTracesServer {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+
+	fs := &flakyTracesServer{addr: lis.Addr().String()}
+
+	srv := grpc.NewServer()
+	ptraceotlp.RegisterGRPCServer(srv, fs)
+
+	go func() {
+		require.NoError(t, srv.Serve(lis))
+	}()
+	t.Cleanup(srv.Stop)
+
+	return fs
+}
+
+func (fs *flakyTracesServer) Export(_ context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+
+	fs.attempt++
+	if fs.failing {
+		return ptraceotlp.NewExportResponse(), status.Error(codes.Unavailable, "server is unavailable")
+	}
+	fs.received += req.Traces().SpanCount()
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+func (fs *flakyTracesServer) setFailing(failing bool) {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	fs.failing = failing
+}
+
+func (fs *flakyTracesServer) attempts() int {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	return fs.attempt
+}
+
+func (fs *flakyTracesServer) receivedSpans() int {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	return fs.received
+}
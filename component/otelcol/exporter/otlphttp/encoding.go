@@ -0,0 +1,179 @@
+package otlphttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	otelcomponent "go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+// Supported encodings of the requests sent by the exporter.
+const (
+	EncodingProto = "proto"
+	EncodingJSON  = "json"
+)
+
+const (
+	protobufContentType = "application/x-protobuf"
+	jsonContentType     = "application/json"
+)
+
+// jsonRoundTripper re-encodes the protobuf requests of the upstream exporter,
+// which only supports protobuf, to JSON. Successful JSON responses are
+// encoded back to protobuf so that the upstream exporter can handle partial
+// successes.
+//
+// Requests are identified by their URL, as every signal is sent to its own
+// URL. Requests to other URLs are sent as is.
+type jsonRoundTripper struct {
+	next    http.RoundTripper
+	signals map[string]otelcomponent.DataType
+}
+
+// newJSONRoundTripper returns a function which wraps the HTTP transport of
+// the exporter with a jsonRoundTripper.
+func newJSONRoundTripper(args Arguments) func(http.RoundTripper) (http.RoundTripper, error) {
+	return func(next http.RoundTripper) (http.RoundTripper, error) {
+		signals := make(map[string]otelcomponent.DataType)
+		for dataType, override := range map[otelcomponent.DataType]string{
+			otelcomponent.DataTypeTraces:  args.TracesEndpoint,
+			otelcomponent.DataTypeMetrics: args.MetricsEndpoint,
+			otelcomponent.DataTypeLogs:    args.LogsEndpoint,
+		} {
+			u, err := signalURL(args.Client.Endpoint, override, dataType)
+			if err != nil {
+				return nil, err
+			}
+			if u != "" {
+				signals[u] = dataType
+			}
+		}
+		return &jsonRoundTripper{next: next, signals: signals}, nil
+	}
+}
+
+// signalURL returns the normalized URL the upstream exporter sends a signal
+// to, if any.
+func signalURL(endpoint string, override string, dataType otelcomponent.DataType) (string, error) {
+	switch {
+	case override != "":
+	case endpoint == "":
+		return "", nil
+	case strings.HasSuffix(endpoint, "/"):
+		override = endpoint + "v1/" + string(dataType)
+	default:
+		override = endpoint + "/v1/" + string(dataType)
+	}
+
+	u, err := url.Parse(override)
+	if err != nil {
+		return "", fmt.Errorf("%s endpoint must be a valid URL: %w", dataType, err)
+	}
+	return u.String(), nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *jsonRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	dataType, ok := rt.signals[req.URL.String()]
+	if !ok || req.Body == nil || req.Header.Get("Content-Type") != protobufContentType {
+		return rt.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body, err = protoToJSON(dataType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", jsonContentType)
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 || resp.Header.Get("Content-Type") != jsonContentType {
+		return resp, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	respBody, err = jsonResponseToProto(dataType, respBody)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	resp.ContentLength = int64(len(respBody))
+	resp.Header.Set("Content-Type", protobufContentType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(respBody)))
+	return resp, nil
+}
+
+func protoToJSON(dataType otelcomponent.DataType, body []byte) ([]byte, error) {
+	switch dataType {
+	case otelcomponent.DataTypeTraces:
+		req := ptraceotlp.NewExportRequest()
+		if err := req.UnmarshalProto(body); err != nil {
+			return nil, err
+		}
+		return req.MarshalJSON()
+	case otelcomponent.DataTypeMetrics:
+		req := pmetricotlp.NewExportRequest()
+		if err := req.UnmarshalProto(body); err != nil {
+			return nil, err
+		}
+		return req.MarshalJSON()
+	default:
+		req := plogotlp.NewExportRequest()
+		if err := req.UnmarshalProto(body); err != nil {
+			return nil, err
+		}
+		return req.MarshalJSON()
+	}
+}
+
+// jsonResponseToProto encodes an export response to protobuf. An empty body
+// is a valid response.
+func jsonResponseToProto(dataType otelcomponent.DataType, body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+
+	switch dataType {
+	case otelcomponent.DataTypeTraces:
+		resp := ptraceotlp.NewExportResponse()
+		if err := resp.UnmarshalJSON(body); err != nil {
+			return nil, fmt.Errorf("error parsing JSON response: %w", err)
+		}
+		return resp.MarshalProto()
+	case otelcomponent.DataTypeMetrics:
+		resp := pmetricotlp.NewExportResponse()
+		if err := resp.UnmarshalJSON(body); err != nil {
+			return nil, fmt.Errorf("error parsing JSON response: %w", err)
+		}
+		return resp.MarshalProto()
+	default:
+		resp := plogotlp.NewExportResponse()
+		if err := resp.UnmarshalJSON(body); err != nil {
+			return nil, fmt.Errorf("error parsing JSON response: %w", err)
+		}
+		return resp.MarshalProto()
+	}
+}
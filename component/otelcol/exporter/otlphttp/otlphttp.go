@@ -3,11 +3,13 @@ package otlphttp
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/exporter"
+	"github.com/grafana/agent/internal/featuregate"
 	otelcomponent "go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
 	otelextension "go.opentelemetry.io/collector/extension"
@@ -15,9 +17,10 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.exporter.otlphttp",
-		Args:    Arguments{},
-		Exports: otelcol.ConsumerExports{},
+		Name:      "otelcol.exporter.otlphttp",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := otlphttpexporter.NewFactory()
@@ -42,15 +45,19 @@ type Arguments struct {
 	TracesEndpoint  string `river:"traces_endpoint,attr,optional"`
 	MetricsEndpoint string `river:"metrics_endpoint,attr,optional"`
 	LogsEndpoint    string `river:"logs_endpoint,attr,optional"`
+
+	// The encoding of the requests: proto or json.
+	Encoding string `river:"encoding,attr,optional"`
 }
 
 var _ exporter.Arguments = Arguments{}
 
 // DefaultArguments holds default values for Arguments.
 var DefaultArguments = Arguments{
-	Queue:  otelcol.DefaultQueueArguments,
-	Retry:  otelcol.DefaultRetryArguments,
-	Client: DefaultHTTPClientArguments,
+	Queue:    otelcol.DefaultQueueArguments,
+	Retry:    otelcol.DefaultRetryArguments,
+	Client:   DefaultHTTPClientArguments,
+	Encoding: EncodingProto,
 }
 
 // SetToDefault implements river.Defaulter.
@@ -60,14 +67,18 @@ func (args *Arguments) SetToDefault() {
 
 // Convert implements exporter.Arguments.
 func (args Arguments) Convert() (otelcomponent.Config, error) {
-	return &otlphttpexporter.Config{
+	cfg := &otlphttpexporter.Config{
 		HTTPClientSettings: *(*otelcol.HTTPClientArguments)(&args.Client).Convert(),
 		QueueSettings:      *args.Queue.Convert(),
 		RetrySettings:      *args.Retry.Convert(),
 		TracesEndpoint:     args.TracesEndpoint,
 		MetricsEndpoint:    args.MetricsEndpoint,
 		LogsEndpoint:       args.LogsEndpoint,
-	}, nil
+	}
+	if args.Encoding == EncodingJSON {
+		cfg.HTTPClientSettings.CustomRoundTripper = newJSONRoundTripper(args)
+	}
+	return cfg, nil
 }
 
 // Extensions implements exporter.Arguments.
@@ -90,6 +101,9 @@ func (args *Arguments) Validate() error {
 	if args.Client.Endpoint == "" && args.TracesEndpoint == "" && args.MetricsEndpoint == "" && args.LogsEndpoint == "" {
 		return errors.New("at least one endpoint must be specified")
 	}
+	if args.Encoding != EncodingProto && args.Encoding != EncodingJSON {
+		return fmt.Errorf("invalid encoding %q, expected %s or %s", args.Encoding, EncodingProto, EncodingJSON)
+	}
 	return nil
 }
 
@@ -1,22 +1,36 @@
 package otlphttp_test
 
 import (
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/local/file"
 	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/auth"
+	"github.com/grafana/agent/component/otelcol/auth/basic"
+	"github.com/grafana/agent/component/otelcol/auth/bearer"
+	"github.com/grafana/agent/component/otelcol/auth/headers"
 	"github.com/grafana/agent/component/otelcol/exporter/otlphttp"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 )
 
 // Test performs a basic integration test which runs the
@@ -94,6 +108,276 @@ func Test(t *testing.T) {
 	}
 }
 
+// TestAuthenticators ensures that requests sent by the exporter carry the
+// credentials of the otelcol.auth component it references.
+func TestAuthenticators(t *testing.T) {
+	tests := []struct {
+		name           string
+		component      string
+		args           func(t *testing.T) component.Arguments
+		expectedHeader string
+	}{
+		{
+			name:      "basic",
+			component: "otelcol.auth.basic",
+			args: func(t *testing.T) component.Arguments {
+				return unmarshal[basic.Arguments](t, `
+					username = "user"
+					password = "pass"
+				`)
+			},
+			expectedHeader: "Basic dXNlcjpwYXNz",
+		},
+		{
+			name:      "bearer",
+			component: "otelcol.auth.bearer",
+			args: func(t *testing.T) component.Arguments {
+				return unmarshal[bearer.Arguments](t, `token = "secret-token"`)
+			},
+			expectedHeader: "Bearer secret-token",
+		},
+		{
+			name:      "headers",
+			component: "otelcol.auth.headers",
+			args: func(t *testing.T) component.Arguments {
+				return unmarshal[headers.Arguments](t, `
+					header {
+						key   = "Authorization"
+						value = "Custom secret-token"
+					}
+				`)
+			},
+			expectedHeader: "Custom secret-token",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newAuthServer(t)
+
+			authCtrl := runController(t, tc.component, tc.args(t))
+			handler := authCtrl.Exports().(auth.Exports).Handler
+
+			exporterCtrl := runController(t, "otelcol.exporter.otlphttp", exporterArguments(t, srv.url, handler))
+			sendTraces(t, exporterCtrl)
+
+			require.Eventually(t, func() bool {
+				return srv.lastAuthorization() == tc.expectedHeader
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+// TestAuthenticatorRotation ensures that rotating a token read from a secret
+// updates the credentials of subsequent requests. The test wires the
+// components together the same way the Flow controller does when the
+// arguments referencing an export get re-evaluated.
+func TestAuthenticatorRotation(t *testing.T) {
+	srv := newAuthServer(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("first"), 0600))
+
+	fileCtrl := runController(t, "local.file", unmarshal[file.Arguments](t, fmt.Sprintf(`
+		filename       = %q
+		detector       = "poll"
+		poll_frequency = "10ms"
+		is_secret      = true
+	`, tokenFile)))
+	token := func() rivertypes.Secret {
+		return rivertypes.Secret(fileCtrl.Exports().(file.Exports).Content.Value)
+	}
+
+	bearerArgs := bearer.DefaultArguments
+	bearerArgs.Token = token()
+	bearerCtrl := runController(t, "otelcol.auth.bearer", bearerArgs)
+	handler := bearerCtrl.Exports().(auth.Exports).Handler
+
+	exporterCtrl := runController(t, "otelcol.exporter.otlphttp", exporterArguments(t, srv.url, handler))
+	sendTraces(t, exporterCtrl)
+	require.Eventually(t, func() bool {
+		return srv.lastAuthorization() == "Bearer first"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Rotate the token, and propagate the new exports down the pipeline.
+	require.NoError(t, os.WriteFile(tokenFile, []byte("second"), 0600))
+	require.Eventually(t, func() bool {
+		return token() == "second"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	bearerArgs.Token = token()
+	require.NoError(t, bearerCtrl.Update(bearerArgs))
+	handler = bearerCtrl.Exports().(auth.Exports).Handler
+	require.NoError(t, exporterCtrl.Update(exporterArguments(t, srv.url, handler)))
+
+	sendTraces(t, exporterCtrl)
+	require.Eventually(t, func() bool {
+		return srv.lastAuthorization() == "Bearer second"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// authServer is an OTLP HTTP server which records the Authorization header
+// of the requests it receives.
+type authServer struct {
+	url string
+
+	mut           sync.Mutex
+	authorization string
+}
+
+// TestJSONEncoding ensures that the exporter sends JSON-encoded requests when
+// configured to, and handles JSON-encoded responses.
+func TestJSONEncoding(t *testing.T) {
+	var (
+		received = make(chan ptrace.Traces, 10)
+		rejected atomic.Int64
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		// Requests are compressed after being encoded.
+		require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		gr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		b, err := io.ReadAll(gr)
+		require.NoError(t, err)
+
+		req := ptraceotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalJSON(b))
+		received <- req.Traces()
+
+		resp := ptraceotlp.NewExportResponse()
+		if n := rejected.Load(); n > 0 {
+			resp.PartialSuccess().SetRejectedSpans(n)
+			resp.PartialSuccess().SetErrorMessage("span rejected")
+		}
+		body, err := resp.MarshalJSON()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	// The queue and retries are disabled so that export errors are returned
+	// to the sender.
+	args := unmarshal[otlphttp.Arguments](t, fmt.Sprintf(`
+		client {
+			endpoint = "%s"
+
+			tls {
+				insecure = true
+			}
+		}
+		encoding = "json"
+
+		sending_queue {
+			enabled = false
+		}
+		retry_on_failure {
+			enabled = false
+		}
+	`, srv.URL))
+	ctrl := runController(t, "otelcol.exporter.otlphttp", args)
+	input := ctrl.Exports().(otelcol.ConsumerExports).Input
+
+	require.NoError(t, input.ConsumeTraces(context.Background(), createTestTraces()))
+	tr := <-received
+	require.Equal(t, 1, tr.SpanCount())
+	require.Equal(t, "TestSpan", tr.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
+
+	// JSON responses are decoded to report partial successes.
+	rejected.Store(1)
+	err := input.ConsumeTraces(context.Background(), createTestTraces())
+	require.ErrorContains(t, err, "OTLP partial success: span rejected (1 rejected)")
+}
+
+func TestEncodingValidation(t *testing.T) {
+	var args otlphttp.Arguments
+	err := river.Unmarshal([]byte(`
+		client {
+			endpoint = "http://localhost:4318"
+		}
+		encoding = "yaml"
+	`), &args)
+	require.ErrorContains(t, err, `invalid encoding "yaml"`)
+
+	require.NoError(t, river.Unmarshal([]byte(`
+		client {
+			endpoint = "http://localhost:4318"
+		}
+	`), &args))
+	require.Equal(t, otlphttp.EncodingProto, args.Encoding)
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	as := &authServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		as.mut.Lock()
+		as.authorization = r.Header.Get("Authorization")
+		as.mut.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	as.url = srv.URL
+	return as
+}
+
+func (as *authServer) lastAuthorization() string {
+	as.mut.Lock()
+	defer as.mut.Unlock()
+	return as.authorization
+}
+
+func exporterArguments(t *testing.T, endpoint string, handler auth.Handler) otlphttp.Arguments {
+	args := unmarshal[otlphttp.Arguments](t, fmt.Sprintf(`
+		client {
+			endpoint    = "%s"
+			compression = "none"
+
+			tls {
+				insecure = true
+			}
+		}
+	`, endpoint))
+	args.Client.Auth = &handler
+	return args
+}
+
+// runController runs the component with the given name until the test ends,
+// and returns its controller once the component exported its first values.
+func runController(t *testing.T, name string, args component.Arguments) *componenttest.Controller {
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), name)
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, ctrl.Run(componenttest.TestContext(t), args))
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Second), "component never started")
+	require.NoError(t, ctrl.WaitExports(time.Second), "component never exported anything")
+	return ctrl
+}
+
+// sendTraces sends test traces to the exporter run by ctrl, retrying until the
+// exporter accepts them.
+func sendTraces(t *testing.T, ctrl *componenttest.Controller) {
+	input := ctrl.Exports().(otelcol.ConsumerExports).Input
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(context.Background(), createTestTraces()) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func unmarshal[T any](t *testing.T, cfg string) T {
+	var args T
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	return args
+}
+
 func createTestTraces() ptrace.Traces {
 	// Matches format from the protobuf definition:
 	// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
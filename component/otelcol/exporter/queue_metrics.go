@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+	otelcomponent "go.opentelemetry.io/collector/component"
+)
+
+// queueCollector exposes the metrics of the sending queues of upstream
+// exporters as Prometheus metrics.
+//
+// The upstream exporter helper records its queue metrics with OpenCensus in a
+// registry shared by the whole process, labeled with the ID of the exporter.
+// queueCollector only reports the series of the exporters it was created
+// for.
+type queueCollector struct {
+	// ids maps the ID of every upstream exporter to the telemetry signal it
+	// exports.
+	ids map[string]otelcomponent.DataType
+}
+
+var _ prometheus.Collector = (*queueCollector)(nil)
+
+var (
+	queueSizeDesc = prometheus.NewDesc(
+		"exporter_queue_size",
+		"Current size of the sending queue, in batches.",
+		[]string{"data_type"}, nil,
+	)
+	queueCapacityDesc = prometheus.NewDesc(
+		"exporter_queue_capacity",
+		"Fixed capacity of the sending queue, in batches.",
+		[]string{"data_type"}, nil,
+	)
+	enqueueFailedDescs = map[string]*prometheus.Desc{
+		"exporter/enqueue_failed_spans": prometheus.NewDesc(
+			"exporter_enqueue_failed_spans_total",
+			"Number of spans which could not be added to the sending queue.",
+			nil, nil,
+		),
+		"exporter/enqueue_failed_metric_points": prometheus.NewDesc(
+			"exporter_enqueue_failed_metric_points_total",
+			"Number of metric points which could not be added to the sending queue.",
+			nil, nil,
+		),
+		"exporter/enqueue_failed_log_records": prometheus.NewDesc(
+			"exporter_enqueue_failed_log_records_total",
+			"Number of log records which could not be added to the sending queue.",
+			nil, nil,
+		),
+	}
+)
+
+func newQueueCollector(ids map[string]otelcomponent.DataType) *queueCollector {
+	return &queueCollector{ids: ids}
+}
+
+// Describe implements prometheus.Collector.
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueSizeDesc
+	ch <- queueCapacityDesc
+	for _, desc := range enqueueFailedDescs {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	var (
+		sizes      = make(map[otelcomponent.DataType]int64)
+		capacities = make(map[otelcomponent.DataType]int64)
+		failed     = make(map[string]int64)
+	)
+
+	for _, producer := range metricproducer.GlobalManager().GetAll() {
+		for _, m := range producer.Read() {
+			for _, ts := range m.TimeSeries {
+				dataType, ok := c.lookup(ts)
+				if !ok {
+					continue
+				}
+
+				switch name := m.Descriptor.Name; name {
+				case "exporter/queue_size":
+					sizes[dataType] += lastValue(ts)
+				case "exporter/queue_capacity":
+					capacities[dataType] += lastValue(ts)
+				default:
+					if _, ok := enqueueFailedDescs[name]; ok {
+						failed[name] += lastValue(ts)
+					}
+				}
+			}
+		}
+	}
+
+	for dataType, v := range sizes {
+		ch <- prometheus.MustNewConstMetric(queueSizeDesc, prometheus.GaugeValue, float64(v), string(dataType))
+	}
+	for dataType, v := range capacities {
+		ch <- prometheus.MustNewConstMetric(queueCapacityDesc, prometheus.GaugeValue, float64(v), string(dataType))
+	}
+	for name, desc := range enqueueFailedDescs {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(failed[name]))
+	}
+}
+
+// lookup returns the telemetry signal of the exporter which ts belongs to.
+// The metrics of the exporter helper have a single label holding the ID of
+// the exporter.
+func (c *queueCollector) lookup(ts *metricdata.TimeSeries) (otelcomponent.DataType, bool) {
+	if len(ts.LabelValues) != 1 || !ts.LabelValues[0].Present {
+		return "", false
+	}
+	dataType, ok := c.ids[ts.LabelValues[0].Value]
+	return dataType, ok
+}
+
+func lastValue(ts *metricdata.TimeSeries) int64 {
+	if len(ts.Points) == 0 {
+		return 0
+	}
+	v, _ := ts.Points[len(ts.Points)-1].Value.(int64)
+	return v
+}
@@ -0,0 +1,319 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/storage/filestorage"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.etcd.io/bbolt"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.opentelemetry.io/collector/featuregate"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// queueStorage is a storage extension which persistent sending queues of
+// upstream exporters use to buffer data on disk.
+//
+// queueStorage wraps the upstream file storage extension, creating it the
+// first time a queue needs it. The same queueStorage is used for the lifetime
+// of a component, so that the queues of the exporters created by an update
+// find the data of the ones they replace. Queue files which are corrupted are
+// moved aside so that the queue starts empty rather than failing, and queued
+// items which can't be decoded are counted before the upstream queue skips
+// them.
+type queueStorage struct {
+	log       log.Logger
+	directory string
+	settings  otelextension.CreateSettings
+	metrics   *queueStorageMetrics
+
+	mut     sync.Mutex
+	inner   storage.Extension
+	clients map[*queueStorageClient]struct{}
+}
+
+var _ storage.Extension = (*queueStorage)(nil)
+
+type queueStorageMetrics struct {
+	corruptedFiles prometheus.Counter
+	corruptedItems prometheus.Counter
+}
+
+func newQueueStorageMetrics() *queueStorageMetrics {
+	return &queueStorageMetrics{
+		corruptedFiles: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "exporter_queue_storage_corrupted_files_total",
+			Help: "Number of corrupted persistent queue files which were discarded.",
+		}),
+		corruptedItems: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "exporter_queue_storage_corrupted_items_total",
+			Help: "Number of items in persistent queues which couldn't be decoded and were skipped.",
+		}),
+	}
+}
+
+// register registers the metrics to reg. The metrics outlive the registries
+// of the updates of a component, so they're registered to each of them.
+func (m *queueStorageMetrics) register(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.corruptedFiles, m.corruptedItems} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newQueueStorage(l log.Logger, directory string, settings otelextension.CreateSettings) *queueStorage {
+	return &queueStorage{
+		log:       l,
+		directory: directory,
+		settings:  settings,
+		metrics:   newQueueStorageMetrics(),
+		clients:   make(map[*queueStorageClient]struct{}),
+	}
+}
+
+// Start implements otelcomponent.Component.
+func (qs *queueStorage) Start(context.Context, otelcomponent.Host) error { return nil }
+
+// Shutdown implements otelcomponent.Component. The clients handed out by
+// queueStorage are normally closed by the queues using them, Shutdown closes
+// the ones which are still open.
+func (qs *queueStorage) Shutdown(ctx context.Context) error {
+	qs.mut.Lock()
+	clients := make([]*queueStorageClient, 0, len(qs.clients))
+	for c := range qs.clients {
+		clients = append(clients, c)
+	}
+	qs.mut.Unlock()
+
+	var errs []error
+	for _, c := range clients {
+		if err := c.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetClient implements storage.Extension.
+func (qs *queueStorage) GetClient(ctx context.Context, kind otelcomponent.Kind, id otelcomponent.ID, name string) (storage.Client, error) {
+	inner, err := qs.getInner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The IDs of the exporters of components in modules contain slashes,
+	// which the upstream extension only replaces behind a feature gate.
+	id = otelcomponent.NewIDWithName(id.Type(), sanitize(id.Name()))
+
+	client, err := inner.GetClient(ctx, kind, id, name)
+	if isCorrupted(err) {
+		// The file exists but isn't a valid database. Keep it around for
+		// inspection and start over with an empty queue.
+		path := qs.path(id, name)
+		level.Warn(qs.log).Log("msg", "discarding corrupted persistent queue file", "path", path, "err", err)
+		qs.metrics.corruptedFiles.Inc()
+
+		if renameErr := os.Rename(path, path+".corrupted"); renameErr != nil {
+			return nil, fmt.Errorf("opening persistent queue: %w", err)
+		}
+		client, err = inner.GetClient(ctx, kind, id, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &queueStorageClient{
+		Client:    client,
+		decode:    decoderFor(otelcomponent.DataType(name)),
+		corrupted: qs.metrics.corruptedItems,
+		storage:   qs,
+	}
+	qs.mut.Lock()
+	qs.clients[c] = struct{}{}
+	qs.mut.Unlock()
+	return c, nil
+}
+
+// path returns the path of the file the upstream extension stores the data of
+// the client for id and name in.
+func (qs *queueStorage) path(id otelcomponent.ID, name string) string {
+	fileName := fmt.Sprintf("exporter_%s_%s_%s", id.Type(), id.Name(), name)
+	if upstreamSanitizes() {
+		fileName = sanitize(fileName)
+	}
+	return filepath.Join(qs.directory, fileName)
+}
+
+func (qs *queueStorage) getInner(ctx context.Context) (storage.Extension, error) {
+	qs.mut.Lock()
+	defer qs.mut.Unlock()
+
+	if qs.inner != nil {
+		return qs.inner, nil
+	}
+
+	if err := os.MkdirAll(qs.directory, 0750); err != nil {
+		return nil, fmt.Errorf("creating persistent queue directory: %w", err)
+	}
+
+	fact := filestorage.NewFactory()
+	cfg := fact.CreateDefaultConfig().(*filestorage.Config)
+	cfg.Directory = qs.directory
+	cfg.Compaction.Directory = qs.directory
+
+	ext, err := fact.CreateExtension(ctx, qs.settings, cfg)
+	if err != nil {
+		return nil, err
+	}
+	inner, ok := ext.(storage.Extension)
+	if !ok {
+		return nil, fmt.Errorf("unexpected file storage extension type %T", ext)
+	}
+
+	qs.inner = inner
+	return qs.inner, nil
+}
+
+// isCorrupted returns whether err means that a queue file exists but isn't a
+// valid database. bbolt doesn't export the error for files which are too
+// small to hold its meta pages.
+func isCorrupted(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, target := range []error{bbolt.ErrInvalid, bbolt.ErrVersionMismatch, bbolt.ErrChecksum} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return err.Error() == "file size too small"
+}
+
+// upstreamSanitizeGate is the upstream feature gate which makes the file
+// storage extension replace unsafe characters in file names.
+const upstreamSanitizeGate = "extension.filestorage.replaceUnsafeCharacters"
+
+func upstreamSanitizes() bool {
+	var enabled bool
+	featuregate.GlobalRegistry().VisitAll(func(g *featuregate.Gate) {
+		if g.ID() == upstreamSanitizeGate {
+			enabled = g.IsEnabled()
+		}
+	})
+	return enabled
+}
+
+// sanitize replaces the characters of name which aren't safe in a file name
+// the same way as the upstream file storage extension does: by a tilde
+// followed by their Unicode code point. Tildes are replaced too, so that
+// sanitized names can't collide.
+func sanitize(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			sb.WriteRune(r)
+		default:
+			fmt.Fprintf(&sb, "~%04X", r)
+		}
+	}
+	return sb.String()
+}
+
+// queueStorageClient is a storage.Client which counts the queued items it
+// reads which can't be decoded.
+type queueStorageClient struct {
+	storage.Client
+
+	decode    func([]byte) error
+	corrupted prometheus.Counter
+	storage   *queueStorage
+}
+
+// Close implements storage.Client. Closing a client more than once is a no-op.
+func (c *queueStorageClient) Close(ctx context.Context) error {
+	c.storage.mut.Lock()
+	_, open := c.storage.clients[c]
+	delete(c.storage.clients, c)
+	c.storage.mut.Unlock()
+
+	if !open {
+		return nil
+	}
+	return c.Client.Close(ctx)
+}
+
+// Get implements storage.Client.
+func (c *queueStorageClient) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.Client.Get(ctx, key)
+	if err == nil {
+		c.check(key, value)
+	}
+	return value, err
+}
+
+// Batch implements storage.Client.
+func (c *queueStorageClient) Batch(ctx context.Context, ops ...storage.Operation) error {
+	if err := c.Client.Batch(ctx, ops...); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Type == storage.Get {
+			c.check(op.Key, op.Value)
+		}
+	}
+	return nil
+}
+
+// check decodes value if key identifies a queued item. The upstream queue
+// stores items under their numeric index, next to its own bookkeeping keys.
+func (c *queueStorageClient) check(key string, value []byte) {
+	if value == nil || c.decode == nil {
+		return
+	}
+	if _, err := strconv.ParseUint(key, 10, 64); err != nil {
+		return
+	}
+	if err := c.decode(value); err != nil {
+		c.corrupted.Inc()
+	}
+}
+
+// decoderFor returns a function which decodes queued items of the given
+// telemetry signal, using the same encoding as the upstream queue.
+func decoderFor(dataType otelcomponent.DataType) func([]byte) error {
+	switch dataType {
+	case otelcomponent.DataTypeTraces:
+		return func(b []byte) error {
+			_, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(b)
+			return err
+		}
+	case otelcomponent.DataTypeMetrics:
+		return func(b []byte) error {
+			_, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(b)
+			return err
+		}
+	case otelcomponent.DataTypeLogs:
+		return func(b []byte) error {
+			_, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(b)
+			return err
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/pkg/util/zapadapter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+)
+
+var testQueueID = otelcomponent.NewIDWithName("otlp", "module.file.test/otelcol.exporter.otlp.default.traces")
+
+func newTestQueueStorage(t *testing.T) *queueStorage {
+	l := util.TestFlowLogger(t)
+	return newQueueStorage(l, t.TempDir(), otelextension.CreateSettings{
+		TelemetrySettings: otelcomponent.TelemetrySettings{Logger: zapadapter.New(l)},
+	})
+}
+
+// TestQueueStorage_SanitizesPath ensures that exporter IDs containing slashes,
+// like the IDs of components in modules, are stored in a single file of the
+// queue directory.
+func TestQueueStorage_SanitizesPath(t *testing.T) {
+	ctx := context.Background()
+	qs := newTestQueueStorage(t)
+
+	client, err := qs.GetClient(ctx, otelcomponent.KindExporter, testQueueID, "traces")
+	require.NoError(t, err)
+	require.NoError(t, client.Close(ctx))
+
+	require.FileExists(t, filepath.Join(qs.directory, "exporter_otlp_module.file.test~002Fotelcol.exporter.otlp.default.traces_traces"))
+}
+
+// TestQueueStorage_KeepsUnopenableFile ensures that a queue file is only moved
+// aside when it's corrupted, and not when it can't be opened for another
+// reason.
+func TestQueueStorage_KeepsUnopenableFile(t *testing.T) {
+	ctx := context.Background()
+	qs := newTestQueueStorage(t)
+
+	// A directory in place of the queue file can't be opened as a database.
+	path := qs.path(otelcomponent.NewIDWithName(testQueueID.Type(), sanitize(testQueueID.Name())), "traces")
+	require.NoError(t, os.MkdirAll(path, 0750))
+
+	_, err := qs.GetClient(ctx, otelcomponent.KindExporter, testQueueID, "traces")
+	require.Error(t, err)
+	require.DirExists(t, path)
+	require.NoFileExists(t, path+".corrupted")
+	require.Equal(t, 0.0, testutil.ToFloat64(qs.metrics.corruptedFiles))
+}
+
+// TestQueueStorage_Shutdown ensures that the clients which are still open are
+// closed by Shutdown, releasing their queue files.
+func TestQueueStorage_Shutdown(t *testing.T) {
+	ctx := context.Background()
+	qs := newTestQueueStorage(t)
+
+	client, err := qs.GetClient(ctx, otelcomponent.KindExporter, testQueueID, "traces")
+	require.NoError(t, err)
+	path := qs.path(otelcomponent.NewIDWithName(testQueueID.Type(), sanitize(testQueueID.Name())), "traces")
+
+	require.NoError(t, qs.Shutdown(ctx))
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Clients closed by Shutdown can still be closed by their queue.
+	require.NoError(t, client.Close(ctx))
+}
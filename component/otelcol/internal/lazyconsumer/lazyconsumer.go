@@ -17,10 +17,19 @@ import (
 type Consumer struct {
 	ctx context.Context
 
-	mut             sync.RWMutex
-	metricsConsumer otelconsumer.Metrics
-	logsConsumer    otelconsumer.Logs
-	tracesConsumer  otelconsumer.Traces
+	mut       sync.RWMutex
+	consumers *consumers
+	// changed is closed and replaced whenever consumers changes.
+	changed chan struct{}
+}
+
+// consumers is a set of consumers to forward data to. Data is only forwarded
+// once the set is ready.
+type consumers struct {
+	metrics otelconsumer.Metrics
+	logs    otelconsumer.Logs
+	traces  otelconsumer.Traces
+	ready   bool
 }
 
 var (
@@ -33,7 +42,11 @@ var (
 // Consumer should stop accepting data; if the ctx is closed, no further data
 // will be accepted.
 func New(ctx context.Context) *Consumer {
-	return &Consumer{ctx: ctx}
+	return &Consumer{
+		ctx:       ctx,
+		consumers: &consumers{ready: true},
+		changed:   make(chan struct{}),
+	}
 }
 
 // Capabilities implements otelconsumer.baseConsumer.
@@ -48,74 +61,118 @@ func (c *Consumer) Capabilities() otelconsumer.Capabilities {
 
 // ConsumeTraces implements otelconsumer.Traces.
 func (c *Consumer) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
-	if c.ctx.Err() != nil {
-		return c.ctx.Err()
+	cs, err := c.wait(ctx)
+	if err != nil {
+		return err
 	}
-
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if c.tracesConsumer == nil {
+	if cs.traces == nil {
 		return otelcomponent.ErrDataTypeIsNotSupported
 	}
 
-	if c.tracesConsumer.Capabilities().MutatesData {
+	if cs.traces.Capabilities().MutatesData {
 		newTraces := ptrace.NewTraces()
 		td.CopyTo(newTraces)
 		td = newTraces
 	}
-	return c.tracesConsumer.ConsumeTraces(ctx, td)
+	return cs.traces.ConsumeTraces(ctx, td)
 }
 
 // ConsumeMetrics implements otelconsumer.Metrics.
 func (c *Consumer) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
-	if c.ctx.Err() != nil {
-		return c.ctx.Err()
+	cs, err := c.wait(ctx)
+	if err != nil {
+		return err
 	}
-
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if c.metricsConsumer == nil {
+	if cs.metrics == nil {
 		return otelcomponent.ErrDataTypeIsNotSupported
 	}
 
-	if c.metricsConsumer.Capabilities().MutatesData {
+	if cs.metrics.Capabilities().MutatesData {
 		newMetrics := pmetric.NewMetrics()
 		md.CopyTo(newMetrics)
 		md = newMetrics
 	}
-	return c.metricsConsumer.ConsumeMetrics(ctx, md)
+	return cs.metrics.ConsumeMetrics(ctx, md)
 }
 
 // ConsumeLogs implements otelconsumer.Logs.
 func (c *Consumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
-	if c.ctx.Err() != nil {
-		return c.ctx.Err()
+	cs, err := c.wait(ctx)
+	if err != nil {
+		return err
 	}
-
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if c.logsConsumer == nil {
+	if cs.logs == nil {
 		return otelcomponent.ErrDataTypeIsNotSupported
 	}
 
-	if c.logsConsumer.Capabilities().MutatesData {
+	if cs.logs.Capabilities().MutatesData {
 		newLogs := plog.NewLogs()
 		ld.CopyTo(newLogs)
 		ld = newLogs
 	}
-	return c.logsConsumer.ConsumeLogs(ctx, ld)
+	return cs.logs.ConsumeLogs(ctx, ld)
+}
+
+// wait returns the current set of consumers once it's ready. The lock isn't
+// held while data is forwarded, so that replacing the consumers never waits on
+// the consumers being replaced.
+func (c *Consumer) wait(ctx context.Context) (*consumers, error) {
+	for {
+		if c.ctx.Err() != nil {
+			return nil, c.ctx.Err()
+		}
+
+		c.mut.RLock()
+		cs, changed := c.consumers, c.changed
+		c.mut.RUnlock()
+		if cs.ready {
+			return cs, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		}
+	}
 }
 
 // SetConsumers updates the internal consumers that Consumer will forward data
 // to. It is valid for any combination of m, l, and t to be nil.
 func (c *Consumer) SetConsumers(t otelconsumer.Traces, m otelconsumer.Metrics, l otelconsumer.Logs) {
+	c.set(&consumers{traces: t, metrics: m, logs: l, ready: true})
+}
+
+// SetPendingConsumers is like SetConsumers, but data is held back until the
+// returned function is called, for example once the consumers are started.
+// Calling the returned function has no effect once the consumers were
+// replaced.
+func (c *Consumer) SetPendingConsumers(t otelconsumer.Traces, m otelconsumer.Metrics, l otelconsumer.Logs) (ready func()) {
+	cs := &consumers{traces: t, metrics: m, logs: l}
+	c.set(cs)
+
+	return func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		if c.consumers == cs {
+			c.consumers = &consumers{traces: t, metrics: m, logs: l, ready: true}
+			c.notify()
+		}
+	}
+}
+
+func (c *Consumer) set(cs *consumers) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
+	c.consumers = cs
+	c.notify()
+}
 
-	c.metricsConsumer = m
-	c.logsConsumer = l
-	c.tracesConsumer = t
+// notify wakes up the callers waiting for the consumers to be ready. c.mut
+// must be held.
+func (c *Consumer) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
 }
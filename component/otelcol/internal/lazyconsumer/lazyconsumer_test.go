@@ -0,0 +1,71 @@
+package lazyconsumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestPendingConsumers(t *testing.T) {
+	c := New(context.Background())
+
+	received := make(chan string, 2)
+	consumerFor := func(name string) otelconsumer.Traces {
+		fc, err := otelconsumer.NewTraces(func(context.Context, ptrace.Traces) error {
+			received <- name
+			return nil
+		})
+		require.NoError(t, err)
+		return fc
+	}
+
+	// Data is held back until the pending consumers are ready.
+	ready := c.SetPendingConsumers(consumerFor("first"), nil, nil)
+	errc := make(chan error, 1)
+	go func() { errc <- c.ConsumeTraces(context.Background(), ptrace.NewTraces()) }()
+	require.Never(t, func() bool { return len(received) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	// Marking consumers which were replaced as ready has no effect.
+	readySecond := c.SetPendingConsumers(consumerFor("second"), nil, nil)
+	ready()
+	require.Never(t, func() bool { return len(received) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	readySecond()
+	require.NoError(t, <-errc)
+	require.Equal(t, "second", <-received)
+}
+
+func TestSetConsumersDuringConsume(t *testing.T) {
+	c := New(context.Background())
+
+	// The consumer blocks until the consumers are replaced, which must not
+	// wait for it to return.
+	replaced := make(chan struct{})
+	blocking, err := otelconsumer.NewTraces(func(context.Context, ptrace.Traces) error {
+		<-replaced
+		return nil
+	})
+	require.NoError(t, err)
+	c.SetConsumers(blocking, nil, nil)
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.ConsumeTraces(context.Background(), ptrace.NewTraces()) }()
+	time.Sleep(50 * time.Millisecond)
+
+	c.SetConsumers(nil, nil, nil)
+	close(replaced)
+	require.NoError(t, <-errc)
+}
+
+func TestPendingConsumersCanceled(t *testing.T) {
+	c := New(context.Background())
+	c.SetPendingConsumers(nil, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, c.ConsumeTraces(ctx, ptrace.NewTraces()), context.DeadlineExceeded)
+}
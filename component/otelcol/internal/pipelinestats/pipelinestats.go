@@ -0,0 +1,293 @@
+// Package pipelinestats counts the telemetry flowing through otelcol
+// components, to report it as debug information similarly to the zPages of
+// the OpenTelemetry Collector, and as throughput metrics.
+package pipelinestats
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component/common/throughput"
+	"github.com/prometheus/client_golang/prometheus"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/atomic"
+)
+
+// Stats counts the items received and sent by a component for each telemetry
+// signal: spans for traces, data points for metrics, and log records for
+// logs. Stats are cumulative over the lifetime of the component, across
+// updates.
+type Stats struct {
+	mut           sync.Mutex
+	signals       map[otelcomponent.DataType]*SignalInfo
+	lastError     string
+	lastErrorTime time.Time
+
+	// throughput is set once, before the consumers are wrapped, so it's read
+	// without holding mut.
+	throughput    map[otelcomponent.DataType]*signalThroughput
+	throughputDir direction
+}
+
+// signalThroughput holds the throughput metrics of a signal, and the
+// estimated size of its items.
+type signalThroughput struct {
+	metrics *throughput.Metrics
+
+	batches      atomic.Uint64
+	bytesPerItem atomic.Float64
+}
+
+// sizeSampleInterval is how many batches of items are recorded in the
+// throughput metrics for every batch whose size is computed. Computing the
+// size of the items in the OTLP protobuf format walks all of them, so the
+// size of the other batches is estimated from the average size of the items
+// of the last computed batch.
+const sizeSampleInterval = 16
+
+// size returns the size of a batch of items, calling compute for one in
+// sizeSampleInterval batches, starting with the first one, and estimating it
+// otherwise.
+func (t *signalThroughput) size(items int, compute func() int) int {
+	if items == 0 {
+		return 0
+	}
+	if t.batches.Inc()%sizeSampleInterval == 1 {
+		size := compute()
+		t.bytesPerItem.Store(float64(size) / float64(items))
+		return size
+	}
+	return int(math.Round(t.bytesPerItem.Load() * float64(items)))
+}
+
+// New creates a new Stats.
+func New() *Stats {
+	return &Stats{
+		signals: map[otelcomponent.DataType]*SignalInfo{
+			otelcomponent.DataTypeTraces:  {},
+			otelcomponent.DataTypeMetrics: {},
+			otelcomponent.DataTypeLogs:    {},
+		},
+	}
+}
+
+// CountThroughput registers the throughput metrics of the component to reg,
+// and records the items the component receives in them, along with their
+// size in the OTLP protobuf encoding, computed for a sample of the batches.
+// Components which don't receive items from other components, such as
+// receivers, record the items they send instead when sent is true.
+//
+// CountThroughput must be called before the consumers are wrapped.
+func (s *Stats) CountThroughput(reg prometheus.Registerer, sent bool) error {
+	metrics := make(map[otelcomponent.DataType]*signalThroughput, len(s.signals))
+	for _, dataType := range []otelcomponent.DataType{otelcomponent.DataTypeTraces, otelcomponent.DataTypeMetrics, otelcomponent.DataTypeLogs} {
+		m, err := throughput.New(reg, string(dataType), true)
+		if err != nil {
+			return err
+		}
+		metrics[dataType] = &signalThroughput{metrics: m}
+	}
+
+	s.throughput = metrics
+	s.throughputDir = input
+	if sent {
+		s.throughputDir = output
+	}
+	return nil
+}
+
+// countsThroughput reports whether the items going in dir are recorded in
+// the throughput metrics.
+func (s *Stats) countsThroughput(dir direction) bool {
+	return s.throughput != nil && s.throughputDir == dir
+}
+
+// DebugInfo is the debug information reported by otelcol components.
+type DebugInfo struct {
+	Traces  SignalInfo `river:"traces,block"`
+	Metrics SignalInfo `river:"metrics,block"`
+	Logs    SignalInfo `river:"logs,block"`
+
+	LastError     string    `river:"last_error,attr,optional"`
+	LastErrorTime time.Time `river:"last_error_time,attr,optional"`
+}
+
+// SignalInfo holds the number of items of a telemetry signal which went
+// through a component.
+type SignalInfo struct {
+	// Items the component accepted, and items it returned an error for.
+	ReceivedItems uint64 `river:"received_items,attr"`
+	RefusedItems  uint64 `river:"refused_items,attr"`
+	// Items the component sent to the next components, and items the next
+	// components returned an error for.
+	SentItems       uint64 `river:"sent_items,attr"`
+	SendFailedItems uint64 `river:"send_failed_items,attr"`
+}
+
+// DebugInfo returns the current stats.
+func (s *Stats) DebugInfo() DebugInfo {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return DebugInfo{
+		Traces:  *s.signals[otelcomponent.DataTypeTraces],
+		Metrics: *s.signals[otelcomponent.DataTypeMetrics],
+		Logs:    *s.signals[otelcomponent.DataTypeLogs],
+
+		LastError:     s.lastError,
+		LastErrorTime: s.lastErrorTime,
+	}
+}
+
+type direction int
+
+const (
+	input direction = iota
+	output
+)
+
+// size returns the size of the items going in dir, or 0 if they aren't
+// recorded in the throughput metrics.
+func (s *Stats) size(dataType otelcomponent.DataType, dir direction, items int, compute func() int) int {
+	if !s.countsThroughput(dir) {
+		return 0
+	}
+	return s.throughput[dataType].size(items, compute)
+}
+
+func (s *Stats) record(dataType otelcomponent.DataType, dir direction, items, size int, err error) {
+	if err == nil && s.countsThroughput(dir) {
+		s.throughput[dataType].metrics.Observe(items, size)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	info := s.signals[dataType]
+	switch {
+	case dir == input && err == nil:
+		info.ReceivedItems += uint64(items)
+	case dir == input:
+		info.RefusedItems += uint64(items)
+	case err == nil:
+		info.SentItems += uint64(items)
+	default:
+		info.SendFailedItems += uint64(items)
+	}
+
+	if err != nil {
+		s.lastError = err.Error()
+		s.lastErrorTime = time.Now()
+	}
+}
+
+// InputTraces wraps the consumer receiving the traces sent to the component.
+// It returns nil if c is nil.
+func (s *Stats) InputTraces(c otelconsumer.Traces) otelconsumer.Traces {
+	if c == nil {
+		return nil
+	}
+	return &tracesConsumer{Traces: c, stats: s, dir: input}
+}
+
+// OutputTraces wraps the consumer the component sends traces to. It returns
+// nil if c is nil.
+func (s *Stats) OutputTraces(c otelconsumer.Traces) otelconsumer.Traces {
+	if c == nil {
+		return nil
+	}
+	return &tracesConsumer{Traces: c, stats: s, dir: output}
+}
+
+// InputMetrics wraps the consumer receiving the metrics sent to the
+// component. It returns nil if c is nil.
+func (s *Stats) InputMetrics(c otelconsumer.Metrics) otelconsumer.Metrics {
+	if c == nil {
+		return nil
+	}
+	return &metricsConsumer{Metrics: c, stats: s, dir: input}
+}
+
+// OutputMetrics wraps the consumer the component sends metrics to. It returns
+// nil if c is nil.
+func (s *Stats) OutputMetrics(c otelconsumer.Metrics) otelconsumer.Metrics {
+	if c == nil {
+		return nil
+	}
+	return &metricsConsumer{Metrics: c, stats: s, dir: output}
+}
+
+// InputLogs wraps the consumer receiving the logs sent to the component. It
+// returns nil if c is nil.
+func (s *Stats) InputLogs(c otelconsumer.Logs) otelconsumer.Logs {
+	if c == nil {
+		return nil
+	}
+	return &logsConsumer{Logs: c, stats: s, dir: input}
+}
+
+// OutputLogs wraps the consumer the component sends logs to. It returns nil
+// if c is nil.
+func (s *Stats) OutputLogs(c otelconsumer.Logs) otelconsumer.Logs {
+	if c == nil {
+		return nil
+	}
+	return &logsConsumer{Logs: c, stats: s, dir: output}
+}
+
+// Items are counted before being consumed, since consumers may take ownership
+// of the data.
+
+var (
+	tracesSizer  = &ptrace.ProtoMarshaler{}
+	metricsSizer = &pmetric.ProtoMarshaler{}
+	logsSizer    = &plog.ProtoMarshaler{}
+)
+
+type tracesConsumer struct {
+	otelconsumer.Traces
+	stats *Stats
+	dir   direction
+}
+
+func (c *tracesConsumer) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	items := td.SpanCount()
+	size := c.stats.size(otelcomponent.DataTypeTraces, c.dir, items, func() int { return tracesSizer.TracesSize(td) })
+	err := c.Traces.ConsumeTraces(ctx, td)
+	c.stats.record(otelcomponent.DataTypeTraces, c.dir, items, size, err)
+	return err
+}
+
+type metricsConsumer struct {
+	otelconsumer.Metrics
+	stats *Stats
+	dir   direction
+}
+
+func (c *metricsConsumer) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	items := md.DataPointCount()
+	size := c.stats.size(otelcomponent.DataTypeMetrics, c.dir, items, func() int { return metricsSizer.MetricsSize(md) })
+	err := c.Metrics.ConsumeMetrics(ctx, md)
+	c.stats.record(otelcomponent.DataTypeMetrics, c.dir, items, size, err)
+	return err
+}
+
+type logsConsumer struct {
+	otelconsumer.Logs
+	stats *Stats
+	dir   direction
+}
+
+func (c *logsConsumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	items := ld.LogRecordCount()
+	size := c.stats.size(otelcomponent.DataTypeLogs, c.dir, items, func() int { return logsSizer.LogsSize(ld) })
+	err := c.Logs.ConsumeLogs(ctx, ld)
+	c.stats.record(otelcomponent.DataTypeLogs, c.dir, items, size, err)
+	return err
+}
@@ -0,0 +1,41 @@
+package pipelinestats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSizeSampling ensures that the size of a batch of items is only computed
+// for a sample of the batches, and estimated from the last computed size for
+// the others.
+func TestSizeSampling(t *testing.T) {
+	var (
+		st       signalThroughput
+		computed int
+	)
+	compute := func(size int) func() int {
+		return func() int {
+			computed++
+			return size
+		}
+	}
+
+	require.Equal(t, 100, st.size(10, compute(100)))
+	require.Equal(t, 1, computed)
+
+	// The next batches are estimated from the 10 bytes per item of the first.
+	for i := 1; i < sizeSampleInterval; i++ {
+		require.Equal(t, 50, st.size(5, compute(0)))
+	}
+	require.Equal(t, 1, computed)
+
+	// The size is computed again once every sizeSampleInterval batches.
+	require.Equal(t, 40, st.size(2, compute(40)))
+	require.Equal(t, 2, computed)
+	require.Equal(t, 60, st.size(3, compute(0)))
+
+	// Empty batches have no size.
+	require.Equal(t, 0, st.size(0, compute(10)))
+	require.Equal(t, 2, computed)
+}
@@ -36,9 +36,18 @@ type Scheduler struct {
 	schedMut        sync.Mutex
 	schedComponents []otelcomponent.Component // Most recently created components
 	host            otelcomponent.Host
+	onStarted       func() // Called once schedComponents are started
 
 	// newComponentsCh is written to when schedComponents gets updated.
 	newComponentsCh chan struct{}
+	// drainCh is written to by Drain to stop the running components.
+	drainCh chan drainRequest
+}
+
+// drainRequest is a request to stop the running components of a Scheduler.
+type drainRequest struct {
+	ctx  context.Context
+	done chan struct{}
 }
 
 // New creates a new unstarted Scheduler. Call Run to start it, and call
@@ -47,6 +56,7 @@ func New(l log.Logger) *Scheduler {
 	return &Scheduler{
 		log:             l,
 		newComponentsCh: make(chan struct{}, 1),
+		drainCh:         make(chan drainRequest),
 	}
 }
 
@@ -57,11 +67,24 @@ func New(l log.Logger) *Scheduler {
 // components which have been removed since the last call to Schedule will be
 // stopped.
 func (cs *Scheduler) Schedule(h otelcomponent.Host, cc ...otelcomponent.Component) {
+	cs.ScheduleWithCallback(h, nil, cc...)
+}
+
+// ScheduleWithCallback is like Schedule, but calls onStarted once the
+// scheduled components have been started and before the health of the
+// Scheduler is updated. onStarted is not called if the components are
+// replaced by another call to Schedule before they could be started.
+//
+// Flow components use onStarted to only forward telemetry data to
+// OpenTelemetry Collector components once they are running, since most
+// components can't accept data before Start has been called.
+func (cs *Scheduler) ScheduleWithCallback(h otelcomponent.Host, onStarted func(), cc ...otelcomponent.Component) {
 	cs.schedMut.Lock()
 	defer cs.schedMut.Unlock()
 
 	cs.schedComponents = cc
 	cs.host = h
+	cs.onStarted = onStarted
 
 	select {
 	case cs.newComponentsCh <- struct{}{}:
@@ -96,14 +119,38 @@ func (cs *Scheduler) Run(ctx context.Context) error {
 			cs.schedMut.Lock()
 			components = cs.schedComponents
 			host := cs.host
+			onStarted := cs.onStarted
 			cs.schedMut.Unlock()
 
 			level.Debug(cs.log).Log("msg", "scheduling components", "count", len(components))
-			components = cs.startComponents(ctx, host, components...)
+			components = cs.startComponents(ctx, host, onStarted, components...)
+		case req := <-cs.drainCh:
+			level.Debug(cs.log).Log("msg", "draining components", "count", len(components))
+			cs.stopComponents(req.ctx, components...)
+			components = nil
+			close(req.done)
 		}
 	}
 }
 
+// Drain stops the running components, which send the data they buffer when
+// they're shut down, blocking until they're stopped or ctx is canceled.
+// Components which are scheduled afterwards are run as usual.
+func (cs *Scheduler) Drain(ctx context.Context) error {
+	req := drainRequest{ctx: ctx, done: make(chan struct{})}
+	select {
+	case cs.drainCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (cs *Scheduler) stopComponents(ctx context.Context, cc ...otelcomponent.Component) {
 	for _, c := range cc {
 		if err := c.Shutdown(ctx); err != nil {
@@ -112,9 +159,10 @@ func (cs *Scheduler) stopComponents(ctx context.Context, cc ...otelcomponent.Com
 	}
 }
 
-// startComponent schedules the provided components from cc. It then returns
-// the list of components which started successfully.
-func (cs *Scheduler) startComponents(ctx context.Context, h otelcomponent.Host, cc ...otelcomponent.Component) (started []otelcomponent.Component) {
+// startComponent schedules the provided components from cc, calling
+// onStarted afterwards if it's non-nil. It then returns the list of components
+// which started successfully.
+func (cs *Scheduler) startComponents(ctx context.Context, h otelcomponent.Host, onStarted func(), cc ...otelcomponent.Component) (started []otelcomponent.Component) {
 	var errs error
 
 	for _, c := range cc {
@@ -126,6 +174,10 @@ func (cs *Scheduler) startComponents(ctx context.Context, h otelcomponent.Host,
 		}
 	}
 
+	if onStarted != nil {
+		onStarted()
+	}
+
 	if errs != nil {
 		cs.setHealth(component.Health{
 			Health:     component.HealthTypeUnhealthy,
@@ -2,6 +2,7 @@ package scheduler_test
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -58,6 +59,36 @@ func TestScheduler(t *testing.T) {
 		require.NoError(t, stopped.Wait(5*time.Second), "component did not shutdown")
 	})
 
+	t.Run("Callback is called after components get started", func(t *testing.T) {
+		var (
+			l  = util.TestLogger(t)
+			cs = scheduler.New(l)
+			h  = scheduler.NewHost(l)
+		)
+
+		// Run our scheduler in the background.
+		go func() {
+			err := cs.Run(componenttest.TestContext(t))
+			require.NoError(t, err)
+		}()
+
+		// Schedule our component with a callback, which must only be called
+		// once the component has started.
+		var started atomic.Bool
+		component := &fakeComponent{
+			StartFunc: func(_ context.Context, _ otelcomponent.Host) error {
+				started.Store(true)
+				return nil
+			},
+		}
+		called := util.NewWaitTrigger()
+		cs.ScheduleWithCallback(h, func() {
+			require.True(t, started.Load(), "callback called before component started")
+			called.Trigger()
+		}, component)
+		require.NoError(t, called.Wait(5*time.Second), "callback was not called")
+	})
+
 	t.Run("Running components get stopped on shutdown", func(t *testing.T) {
 		var (
 			l  = util.TestLogger(t)
@@ -85,6 +116,42 @@ func TestScheduler(t *testing.T) {
 		cancel()
 		require.NoError(t, stopped.Wait(5*time.Second), "component did not shutdown")
 	})
+
+	t.Run("Running components get stopped when draining", func(t *testing.T) {
+		var (
+			l  = util.TestLogger(t)
+			cs = scheduler.New(l)
+			h  = scheduler.NewHost(l)
+		)
+
+		// Run our scheduler in the background.
+		go func() {
+			err := cs.Run(componenttest.TestContext(t))
+			require.NoError(t, err)
+		}()
+
+		var (
+			started = util.NewWaitTrigger()
+			stopped atomic.Bool
+		)
+		component := &fakeComponent{
+			StartFunc: func(_ context.Context, _ otelcomponent.Host) error {
+				started.Trigger()
+				return nil
+			},
+			ShutdownFunc: func(_ context.Context) error {
+				stopped.Store(true)
+				return nil
+			},
+		}
+		cs.Schedule(h, component)
+
+		// Draining must only return once the running component has been shut
+		// down.
+		require.NoError(t, started.Wait(5*time.Second), "component did not start")
+		require.NoError(t, cs.Drain(componenttest.TestContext(t)))
+		require.True(t, stopped.Load(), "component did not shutdown")
+	})
 }
 
 func newTriggerComponent() (component otelcomponent.Component, started, stopped *util.WaitTrigger) {
@@ -2,11 +2,13 @@ package batch_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
+	"github.com/grafana/agent/component/otelcol/internal/pipelinestats"
 	"github.com/grafana/agent/component/otelcol/processor/batch"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/flow/logging/level"
@@ -14,6 +16,7 @@ import (
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/river"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/processor/batchprocessor"
 )
@@ -78,6 +81,66 @@ func Test(t *testing.T) {
 	}
 }
 
+// TestDebugInfo ensures that the debug information of the component reports
+// the telemetry which went through it.
+func TestDebugInfo(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.processor.batch")
+	require.NoError(t, err)
+
+	var args batch.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		timeout = "10ms"
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`), &args))
+
+	// Traces are forwarded, while logs are refused by the next component.
+	traceCh := make(chan ptrace.Traces, 10)
+	args.Output = makeTracesOutput(traceCh)
+	args.Output.Logs = []otelcol.Consumer{&fakeconsumer.Consumer{
+		ConsumeLogsFunc: func(context.Context, plog.Logs) error {
+			return errors.New("logs backend unavailable")
+		},
+	}}
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second), "component never started")
+	require.NoError(t, ctrl.WaitExports(time.Second), "component never exported anything")
+
+	input := ctrl.Exports().(otelcol.ConsumerExports).Input
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(ctx, createTestTraces()) == nil
+	}, time.Second, 10*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, input.ConsumeTraces(ctx, createTestTraces()))
+	}
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	records.AppendEmpty().Body().SetStr("first")
+	records.AppendEmpty().Body().SetStr("second")
+	require.NoError(t, input.ConsumeLogs(ctx, logs))
+
+	var info pipelinestats.DebugInfo
+	require.Eventually(t, func() bool {
+		raw, err := ctrl.DebugInfo()
+		require.NoError(t, err)
+		info = raw.(pipelinestats.DebugInfo)
+		return info.Traces.SentItems == 5 && info.Logs.SendFailedItems == 2
+	}, 5*time.Second, 10*time.Millisecond, "unexpected debug info: %+v", info)
+
+	require.Equal(t, pipelinestats.SignalInfo{ReceivedItems: 5, SentItems: 5}, info.Traces)
+	require.Equal(t, pipelinestats.SignalInfo{ReceivedItems: 2, SendFailedItems: 2}, info.Logs)
+	require.Equal(t, pipelinestats.SignalInfo{}, info.Metrics)
+	require.Equal(t, "logs backend unavailable", info.LastError)
+	require.False(t, info.LastErrorTime.IsZero())
+}
+
 // makeTracesOutput returns ConsumerArguments which will forward traces to the
 // provided channel.
 func makeTracesOutput(ch chan ptrace.Traces) *otelcol.ConsumerArguments {
@@ -1,9 +1,13 @@
 package filter
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/processor"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/mitchellh/mapstructure"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/filterprocessor"
@@ -13,9 +17,10 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.processor.filter",
-		Args:    Arguments{},
-		Exports: otelcol.ConsumerExports{},
+		Name:      "otelcol.processor.filter",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := filterprocessor.NewFactory()
@@ -55,9 +60,54 @@ func (args *Arguments) Validate() error {
 	if err != nil {
 		return err
 	}
+	if err := args.validateConditions(); err != nil {
+		return err
+	}
 	return otelArgs.Validate()
 }
 
+// validateConditions parses every condition on its own, so that an invalid
+// condition is reported along with the block and the attribute it's set in.
+func (args *Arguments) validateConditions() error {
+	checks := []struct {
+		name       string
+		conditions []string
+		config     func(conditions []string) filterprocessor.Config
+	}{
+		{"traces.span", args.Traces.Span, func(c []string) (cfg filterprocessor.Config) {
+			cfg.Traces.SpanConditions = c
+			return
+		}},
+		{"traces.spanevent", args.Traces.SpanEvent, func(c []string) (cfg filterprocessor.Config) {
+			cfg.Traces.SpanEventConditions = c
+			return
+		}},
+		{"metrics.metric", args.Metrics.Metric, func(c []string) (cfg filterprocessor.Config) {
+			cfg.Metrics.MetricConditions = c
+			return
+		}},
+		{"metrics.datapoint", args.Metrics.Datapoint, func(c []string) (cfg filterprocessor.Config) {
+			cfg.Metrics.DataPointConditions = c
+			return
+		}},
+		{"logs.log_record", args.Logs.LogRecord, func(c []string) (cfg filterprocessor.Config) {
+			cfg.Logs.LogConditions = c
+			return
+		}},
+	}
+
+	var errs []error
+	for _, check := range checks {
+		for i, condition := range check.conditions {
+			cfg := check.config([]string{condition})
+			if err := cfg.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid condition %s[%d] %q: %w", check.name, i, condition, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Convert implements processor.Arguments.
 func (args Arguments) Convert() (otelcomponent.Config, error) {
 	return args.convertImpl()
@@ -1,9 +1,13 @@
 package filter_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/grafana/agent/component/otelcol/processor/filter"
+	"github.com/grafana/agent/component/otelcol/processor/processortest"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
 	"github.com/mitchellh/mapstructure"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/filterprocessor"
@@ -156,6 +160,19 @@ func TestArguments_UnmarshalRiver(t *testing.T) {
 			`,
 			errMsg: `unable to parse OTTL statement "match() where UnknowFunction(\"http.method\")": undefined function "UnknowFunction"`,
 		},
+		{
+			testName: "invalidConditionLocation",
+			cfg: `
+			traces {
+				span = [
+					"name == \"app_1\"",
+					"attributes[\"http.method\"] ==",
+				]
+			}
+			output {}
+			`,
+			errMsg: `invalid condition traces.span[1] "attributes[\"http.method\"] =="`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -186,3 +203,85 @@ func TestArguments_UnmarshalRiver(t *testing.T) {
 		})
 	}
 }
+
+func testRunProcessor(t *testing.T, processorConfig string, testSignal processortest.Signal) {
+	ctx := componenttest.TestContext(t)
+	testRunProcessorWithContext(ctx, t, processorConfig, testSignal)
+}
+
+func testRunProcessorWithContext(ctx context.Context, t *testing.T, processorConfig string, testSignal processortest.Signal) {
+	l := util.TestLogger(t)
+
+	ctrl, err := componenttest.NewControllerFromID(l, "otelcol.processor.filter")
+	require.NoError(t, err)
+
+	var args filter.Arguments
+	require.NoError(t, river.Unmarshal([]byte(processorConfig), &args))
+
+	// Override the arguments so signals get forwarded to the test channel.
+	args.Output = testSignal.MakeOutput()
+
+	prc := processortest.ProcessorRunConfig{
+		Ctx:        ctx,
+		T:          t,
+		Args:       args,
+		TestSignal: testSignal,
+		Ctrl:       ctrl,
+		L:          l,
+	}
+	processortest.TestRunProcessor(prc)
+}
+
+func Test_DropSpans(t *testing.T) {
+	cfg := `
+	traces {
+		span = [
+			"attributes[\"http.route\"] == \"/health\"",
+		]
+	}
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+
+	var inputTrace = `{
+		"resourceSpans": [{
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /health",
+					"attributes": [{
+						"key": "http.route",
+						"value": { "stringValue": "/health" }
+					}]
+				},{
+					"name": "GET /api",
+					"attributes": [{
+						"key": "http.route",
+						"value": { "stringValue": "/api" }
+					}]
+				},{
+					"name": "process"
+				}]
+			}]
+		}]
+	}`
+
+	expectedOutputTrace := `{
+		"resourceSpans": [{
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api",
+					"attributes": [{
+						"key": "http.route",
+						"value": { "stringValue": "/api" }
+					}]
+				},{
+					"name": "process"
+				}]
+			}]
+		}]
+	}`
+
+	testRunProcessor(t, cfg, processortest.NewTraceSignal(inputTrace, expectedOutputTrace))
+}
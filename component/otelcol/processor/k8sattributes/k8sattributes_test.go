@@ -1,12 +1,34 @@
 package k8sattributes_test
 
 import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
 	"github.com/grafana/agent/component/otelcol/processor/k8sattributes"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/k8sattributesprocessor"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
 func Test_Extract(t *testing.T) {
@@ -339,3 +361,224 @@ func Test_Exclude(t *testing.T) {
 	require.Equal(t, "jaeger-agent", exclude.Pods[0].Name)
 	require.Equal(t, "jaeger-collector", exclude.Pods[1].Name)
 }
+
+func Test_FakeAPIServer(t *testing.T) {
+	api := newFakeAPIServer(t)
+
+	cfg := `
+		auth_type = "none"
+
+		extract {
+			metadata = ["k8s.namespace.name", "k8s.pod.name", "k8s.node.name"]
+		}
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`
+	var args k8sattributes.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	spans := make(chan pcommon.Map, 10)
+	args.Output = &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+				spans <- td.ResourceSpans().At(0).Resource().Attributes()
+				return nil
+			},
+		}},
+	}
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.processor.k8sattributes")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, ctrl.Run(componenttest.TestContext(t), args))
+	}()
+	require.NoError(t, ctrl.WaitExports(time.Second))
+	input := ctrl.Exports().(otelcol.ConsumerExports).Input
+
+	// sendSpan sends a span from ip, and returns the resource attributes it's
+	// exported with.
+	sendSpan := func(ip string) map[string]any {
+		td := ptrace.NewTraces()
+		td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("test")
+		ctx := client.NewContext(context.Background(), client.Info{Addr: &net.IPAddr{IP: net.ParseIP(ip)}})
+		if err := input.ConsumeTraces(ctx, td); err != nil {
+			return nil
+		}
+		select {
+		case attrs := <-spans:
+			return attrs.AsRaw()
+		case <-time.After(time.Second):
+			require.FailNow(t, "span wasn't exported")
+			return nil
+		}
+	}
+	// requireEnriched waits for spans from ip to be exported with the metadata
+	// of pod.
+	requireEnriched := func(ip string, pod *corev1.Pod) {
+		t.Helper()
+		expect := map[string]any{
+			"k8s.pod.ip":         ip,
+			"k8s.namespace.name": pod.Namespace,
+			"k8s.pod.name":       pod.Name,
+			"k8s.node.name":      pod.Spec.NodeName,
+		}
+		require.Eventually(t, func() bool {
+			return reflect.DeepEqual(expect, sendSpan(ip))
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	checkout := newPod("checkout-1", "10.0.0.1", time.Now())
+	api.AddPod(checkout)
+	requireEnriched("10.0.0.1", checkout)
+
+	// Spans from unknown pods only get the IP of their pod.
+	require.Equal(t, map[string]any{"k8s.pod.ip": "10.0.0.2"}, sendSpan("10.0.0.2"))
+
+	// The IP of a deleted pod gets reused by a new pod.
+	api.DeletePod(checkout)
+	replacement := newPod("checkout-2", "10.0.0.1", time.Now().Add(time.Second))
+	api.AddPod(replacement)
+	requireEnriched("10.0.0.1", replacement)
+
+	// Deleted pods are kept for a while, so that spans sent right before a pod
+	// was deleted are still enriched. Events are handled in order, so the
+	// deletion was handled once the pod added afterwards is known.
+	api.DeletePod(replacement)
+	frontend := newPod("frontend-1", "10.0.0.3", time.Now())
+	api.AddPod(frontend)
+	requireEnriched("10.0.0.3", frontend)
+	requireEnriched("10.0.0.1", replacement)
+}
+
+func newPod(name, ip string, startTime time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "shop",
+			UID:       types.UID(name),
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{
+			PodIP:     ip,
+			StartTime: &metav1.Time{Time: startTime},
+		},
+	}
+}
+
+// fakeAPIServer is a fake Kubernetes API server, which serves the pods it's
+// given and no other resources.
+type fakeAPIServer struct {
+	t    *testing.T
+	done chan struct{}
+
+	mut      sync.Mutex
+	version  int
+	pods     map[string]*corev1.Pod
+	watchers map[chan metav1.WatchEvent]struct{}
+}
+
+// newFakeAPIServer starts a fakeAPIServer, which the Kubernetes clients of
+// the test connect to.
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	api := &fakeAPIServer{
+		t:        t,
+		done:     make(chan struct{}),
+		pods:     make(map[string]*corev1.Pod),
+		watchers: make(map[chan metav1.WatchEvent]struct{}),
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(api.serveHTTP))
+	t.Cleanup(func() {
+		close(api.done)
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	t.Setenv("KUBERNETES_SERVICE_HOST", u.Hostname())
+	t.Setenv("KUBERNETES_SERVICE_PORT", u.Port())
+	return api
+}
+
+// AddPod adds a new pod.
+func (api *fakeAPIServer) AddPod(pod *corev1.Pod) {
+	api.mut.Lock()
+	defer api.mut.Unlock()
+	api.pods[pod.Name] = pod
+	api.notify(watch.Added, pod)
+}
+
+// DeletePod deletes an existing pod.
+func (api *fakeAPIServer) DeletePod(pod *corev1.Pod) {
+	api.mut.Lock()
+	defer api.mut.Unlock()
+	delete(api.pods, pod.Name)
+	api.notify(watch.Deleted, pod)
+}
+
+// notify must be called with mut held.
+func (api *fakeAPIServer) notify(typ watch.EventType, pod *corev1.Pod) {
+	api.version++
+	pod = pod.DeepCopy()
+	pod.ResourceVersion = strconv.Itoa(api.version)
+
+	raw, err := json.Marshal(pod)
+	require.NoError(api.t, err)
+	for w := range api.watchers {
+		w <- metav1.WatchEvent{Type: string(typ), Object: runtime.RawExtension{Raw: raw}}
+	}
+}
+
+func (api *fakeAPIServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	isPods := r.URL.Path == "/api/v1/pods"
+
+	if r.URL.Query().Get("watch") != "true" {
+		api.mut.Lock()
+		list := corev1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: strconv.Itoa(api.version)}}
+		if isPods {
+			for _, pod := range api.pods {
+				list.Items = append(list.Items, *pod)
+			}
+		}
+		api.mut.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	// Only pods ever change, so the watches of other resources never get
+	// any events.
+	events := make(chan metav1.WatchEvent, 10)
+	if isPods {
+		api.mut.Lock()
+		api.watchers[events] = struct{}{}
+		api.mut.Unlock()
+		defer func() {
+			api.mut.Lock()
+			delete(api.watchers, events)
+			api.mut.Unlock()
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case ev := <-events:
+			_ = enc.Encode(ev)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		case <-api.done:
+			return
+		}
+	}
+}
@@ -1,20 +1,30 @@
 package memorylimiter_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
 	"github.com/grafana/agent/component/otelcol/processor/memorylimiter"
+	"github.com/grafana/agent/component/otelcol/receiver/otlp"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/river"
+	"github.com/phayes/freeport"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 )
 
 // Test performs a basic integration test which runs the
@@ -118,3 +128,162 @@ func createTestTraces() ptrace.Traces {
 	}
 	return data
 }
+
+// TestRefusesDataAboveLimit sends traces to an otelcol.receiver.otlp component
+// forwarding to an otelcol.processor.memory_limiter component whose limit is
+// lower than the memory the test process uses, and ensures that the data is
+// refused back to the client instead of being forwarded.
+func TestRefusesDataAboveLimit(t *testing.T) {
+	// Processors only report internal metrics through OpenTelemetry when the
+	// feature gate enabled by Flow is set.
+	require.NoError(t, util.SetupFlowModeOtelFeatureGates())
+
+	ctx := componenttest.TestContext(t)
+	l := util.TestLogger(t)
+
+	// Hold on to enough memory for the heap to stay above the limit, even
+	// after the garbage collections forced by the processor.
+	ballast := make([]byte, 16<<20)
+	defer runtime.KeepAlive(ballast)
+
+	var forwardedSpans atomic.Int64
+	reg := prometheus.NewRegistry()
+	limiter := runMemoryLimiter(t, reg, `
+		check_interval = "10ms"
+		limit          = "2MiB"
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`, &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+				forwardedSpans.Add(int64(td.SpanCount()))
+				return nil
+			},
+		}},
+	})
+
+	httpAddr := getFreeAddr(t)
+	receiver, err := componenttest.NewControllerFromID(l, "otelcol.receiver.otlp")
+	require.NoError(t, err)
+
+	var receiverArgs otlp.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		http {
+			endpoint = "%s"
+		}
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`, httpAddr)), &receiverArgs))
+	receiverArgs.Output = &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{limiter},
+	}
+
+	go func() {
+		require.NoError(t, receiver.Run(ctx, receiverArgs))
+	}()
+	require.NoError(t, receiver.WaitRunning(time.Second))
+
+	payload, err := ptraceotlp.NewExportRequestFromTraces(createLargeTestTraces(100)).MarshalProto()
+	require.NoError(t, err)
+
+	// Send batches of spans until the limiter notices that memory usage is
+	// above the limit. From then on, every request must be refused, telling
+	// the client to retry later.
+	var sentSpans int64
+	require.Eventually(t, func() bool {
+		resp, err := http.Post(fmt.Sprintf("http://%s/v1/traces", httpAddr), "application/x-protobuf", bytes.NewReader(payload))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			sentSpans += 100
+		}
+		return resp.StatusCode == http.StatusInternalServerError
+	}, 5*time.Second, time.Millisecond)
+
+	for i := 0; i < 50; i++ {
+		resp, err := http.Post(fmt.Sprintf("http://%s/v1/traces", httpAddr), "application/x-protobuf", bytes.NewReader(payload))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	// Only the spans accepted before the limit was reached are forwarded.
+	require.Equal(t, sentSpans, forwardedSpans.Load())
+	require.GreaterOrEqual(t, refusedSpans(t, reg), float64(50*100))
+}
+
+// runMemoryLimiter runs an otelcol.processor.memory_limiter component
+// configured by cfg which forwards data to output, and returns the consumer
+// to send data to once it's running. Its metrics are registered to reg.
+func runMemoryLimiter(t *testing.T, reg prometheus.Registerer, cfg string, output *otelcol.ConsumerArguments) otelcol.Consumer {
+	var args memorylimiter.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	args.Output = output
+
+	registration, ok := component.Get("otelcol.processor.memory_limiter")
+	require.True(t, ok)
+
+	exportsCh := make(chan otelcol.ConsumerExports, 1)
+	c, err := registration.Build(component.Options{
+		ID:            "otelcol.processor.memory_limiter.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) { exportsCh <- e.(otelcol.ConsumerExports) },
+	}, args)
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	require.Eventually(t, func() bool {
+		return c.(component.HealthComponent).CurrentHealth().Health == component.HealthTypeHealthy
+	}, 5*time.Second, 10*time.Millisecond)
+	return (<-exportsCh).Input
+}
+
+// refusedSpans returns the number of spans refused by the processor, as
+// reported by its metrics.
+func refusedSpans(t *testing.T, reg prometheus.Gatherer) float64 {
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	var total float64
+	for _, mf := range mfs {
+		if mf.GetName() != "processor_refused_spans_ratio_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			total += m.GetCounter().GetValue()
+		}
+	}
+	return total
+}
+
+// createLargeTestTraces returns traces with n spans, each carrying an
+// attribute large enough for the requests to allocate memory.
+func createLargeTestTraces(n int) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < n; i++ {
+		span := spans.AppendEmpty()
+		span.SetName("TestSpan")
+		span.Attributes().PutStr("payload", string(bytes.Repeat([]byte{'x'}, 1024)))
+	}
+	return traces
+}
+
+func getFreeAddr(t *testing.T) string {
+	t.Helper()
+
+	portNumber, err := freeport.GetFreePort()
+	require.NoError(t, err)
+
+	return fmt.Sprintf("localhost:%d", portNumber)
+}
@@ -2,8 +2,14 @@ package probabilistic_sampler_test
 
 import (
 	"context"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/fakeconsumer"
 	probabilisticsampler "github.com/grafana/agent/component/otelcol/processor/probabilistic_sampler"
 	"github.com/grafana/agent/component/otelcol/processor/processortest"
 	"github.com/grafana/agent/pkg/flow/componenttest"
@@ -11,6 +17,9 @@ import (
 	"github.com/grafana/river"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/probabilisticsamplerprocessor"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"golang.org/x/exp/maps"
 )
 
 func TestArguments_UnmarshalRiver(t *testing.T) {
@@ -195,3 +204,157 @@ func TestTraceProcessing(t *testing.T) {
 
 	testRunProcessor(t, cfg, processortest.NewTraceSignal(inputTraces, expectedOutputTraces))
 }
+
+// traceSink records the number of spans received for every trace.
+type traceSink struct {
+	mut   sync.Mutex
+	spans map[pcommon.TraceID]int
+}
+
+// startSampler runs otelcol.processor.probabilistic_sampler with cfg, and
+// returns its input once it accepts traces.
+func startSampler(t *testing.T, cfg string) (otelcol.Consumer, *traceSink) {
+	t.Helper()
+
+	var args probabilisticsampler.Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	sink := &traceSink{spans: make(map[pcommon.TraceID]int)}
+	args.Output = &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+				sink.mut.Lock()
+				defer sink.mut.Unlock()
+				spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+				for i := 0; i < spans.Len(); i++ {
+					sink.spans[spans.At(i).TraceID()]++
+				}
+				return nil
+			},
+		}},
+	}
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.processor.probabilistic_sampler")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, ctrl.Run(componenttest.TestContext(t), args))
+	}()
+	require.NoError(t, ctrl.WaitExports(time.Second))
+
+	input := ctrl.Exports().(otelcol.ConsumerExports).Input
+	require.Eventually(t, func() bool {
+		return input.ConsumeTraces(context.Background(), ptrace.NewTraces()) == nil
+	}, time.Second, 10*time.Millisecond)
+	return input, sink
+}
+
+// sampled returns the traces received by the sink.
+func (s *traceSink) sampled() map[pcommon.TraceID]int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return maps.Clone(s.spans)
+}
+
+// sendSpan sends a single span of the trace with the given ID.
+func sendSpan(t *testing.T, input otelcol.Consumer, traceID pcommon.TraceID, attrs map[string]any) {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(traceID)
+	require.NoError(t, span.Attributes().FromRaw(attrs))
+	require.NoError(t, input.ConsumeTraces(context.Background(), td))
+}
+
+// newTraceIDs returns n random trace IDs, which are the same for every test
+// run.
+func newTraceIDs(n int) []pcommon.TraceID {
+	rnd := rand.New(rand.NewSource(1))
+	ids := make([]pcommon.TraceID, n)
+	for i := range ids {
+		rnd.Read(ids[i][:])
+	}
+	return ids
+}
+
+func TestTraceSampling(t *testing.T) {
+	const (
+		traces = 4000
+		cfg    = `
+			sampling_percentage = 25
+			hash_seed           = 123
+
+			output {
+				// no-op: will be overridden by test code.
+			}
+		`
+	)
+	ids := newTraceIDs(traces)
+
+	input, sink := startSampler(t, cfg)
+	// Every span of a trace is sent on its own, so that the decision is made
+	// for every span.
+	for _, id := range ids {
+		sendSpan(t, input, id, nil)
+		sendSpan(t, input, id, nil)
+	}
+	sampled := sink.sampled()
+
+	require.InDelta(t, 0.25, float64(len(sampled))/traces, 0.03)
+	for id, spans := range sampled {
+		require.Equal(t, 2, spans, "only some spans of trace %s were sampled", id)
+	}
+
+	t.Run("same hash seed", func(t *testing.T) {
+		input, sink := startSampler(t, cfg)
+		for _, id := range ids {
+			sendSpan(t, input, id, nil)
+		}
+		require.Len(t, sink.sampled(), len(sampled))
+		for id := range sink.sampled() {
+			require.Contains(t, sampled, id)
+		}
+	})
+
+	t.Run("different hash seed", func(t *testing.T) {
+		input, sink := startSampler(t, strings.Replace(cfg, "123", "456", 1))
+		for _, id := range ids {
+			sendSpan(t, input, id, nil)
+		}
+
+		var shared int
+		for id := range sink.sampled() {
+			if _, ok := sampled[id]; ok {
+				shared++
+			}
+		}
+		// The decisions for different seeds are independent, so about a quarter
+		// of the traces sampled with one seed are sampled with the other.
+		require.InDelta(t, 0.25, float64(shared)/float64(len(sampled)), 0.1)
+	})
+}
+
+func TestTraceSampling_SamplingPriority(t *testing.T) {
+	input, sink := startSampler(t, `
+		sampling_percentage = 25
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`)
+
+	ids := newTraceIDs(200)
+	for i, id := range ids {
+		// Existing decisions take precedence over the sampling percentage.
+		priority := 0
+		if i%2 == 0 {
+			priority = 1
+		}
+		sendSpan(t, input, id, map[string]any{"sampling.priority": priority})
+	}
+
+	sampled := sink.sampled()
+	require.Len(t, sampled, len(ids)/2)
+	for i, id := range ids {
+		_, ok := sampled[id]
+		require.Equal(t, i%2 == 0, ok, "unexpected decision for trace %d", i)
+	}
+}
@@ -12,11 +12,13 @@ import (
 	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
 	"github.com/grafana/agent/component/otelcol/internal/lazycollector"
 	"github.com/grafana/agent/component/otelcol/internal/lazyconsumer"
+	"github.com/grafana/agent/component/otelcol/internal/pipelinestats"
 	"github.com/grafana/agent/component/otelcol/internal/scheduler"
 	"github.com/grafana/agent/pkg/build"
 	"github.com/grafana/agent/pkg/util/zapadapter"
 	"github.com/prometheus/client_golang/prometheus"
 	otelcomponent "go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configtelemetry"
 	otelextension "go.opentelemetry.io/collector/extension"
 	otelprocessor "go.opentelemetry.io/collector/processor"
 	sdkprometheus "go.opentelemetry.io/otel/exporters/prometheus"
@@ -53,14 +55,17 @@ type Processor struct {
 	opts     component.Options
 	factory  otelprocessor.Factory
 	consumer *lazyconsumer.Consumer
+	stats    *pipelinestats.Stats
 
 	sched     *scheduler.Scheduler
 	collector *lazycollector.Collector
 }
 
 var (
-	_ component.Component       = (*Processor)(nil)
-	_ component.HealthComponent = (*Processor)(nil)
+	_ component.Component          = (*Processor)(nil)
+	_ component.HealthComponent    = (*Processor)(nil)
+	_ component.DebugComponent     = (*Processor)(nil)
+	_ component.DrainableComponent = (*Processor)(nil)
 )
 
 // New creates a new Flow component which encapsulates an OpenTelemetry
@@ -93,10 +98,14 @@ func New(opts component.Options, f otelprocessor.Factory, args Arguments) (*Proc
 		opts:     opts,
 		factory:  f,
 		consumer: consumer,
+		stats:    pipelinestats.New(),
 
 		sched:     scheduler.New(opts.Logger),
 		collector: collector,
 	}
+	if err := p.stats.CountThroughput(opts.Registerer, false); err != nil {
+		return nil, err
+	}
 	if err := p.Update(args); err != nil {
 		return nil, err
 	}
@@ -136,6 +145,10 @@ func (p *Processor) Update(args component.Arguments) error {
 			TracerProvider: p.opts.Tracer,
 			MeterProvider:  metric.NewMeterProvider(metric.WithReader(promExporter)),
 
+			// Processors built on processorhelper only report how much data they
+			// accepted, refused, or dropped at a level other than none.
+			MetricsLevel: configtelemetry.LevelNormal,
+
 			ReportComponentStatus: func(*otelcomponent.StatusEvent) error {
 				return nil
 			},
@@ -155,9 +168,9 @@ func (p *Processor) Update(args component.Arguments) error {
 
 	var (
 		next        = pargs.NextConsumers()
-		nextTraces  = fanoutconsumer.Traces(next.Traces)
-		nextMetrics = fanoutconsumer.Metrics(next.Metrics)
-		nextLogs    = fanoutconsumer.Logs(next.Logs)
+		nextTraces  = p.stats.OutputTraces(fanoutconsumer.Traces(next.Traces))
+		nextMetrics = p.stats.OutputMetrics(fanoutconsumer.Metrics(next.Metrics))
+		nextLogs    = p.stats.OutputLogs(fanoutconsumer.Logs(next.Logs))
 	)
 
 	// Create instances of the processor from our factory for each of our
@@ -194,13 +207,31 @@ func (p *Processor) Update(args component.Arguments) error {
 		}
 	}
 
-	// Schedule the components to run once our component is running.
-	p.sched.Schedule(host, components...)
-	p.consumer.SetConsumers(tracesProcessor, metricsProcessor, logsProcessor)
+	// Schedule the components to run once our component is running. Data is
+	// sent to them rather than to the components they replace right away, but
+	// it's held back until they've been started.
+	ready := p.consumer.SetPendingConsumers(
+		p.stats.InputTraces(tracesProcessor),
+		p.stats.InputMetrics(metricsProcessor),
+		p.stats.InputLogs(logsProcessor),
+	)
+	p.sched.ScheduleWithCallback(host, ready, components...)
 	return nil
 }
 
+// Drain implements component.DrainableComponent. The upstream processors are
+// shut down, sending the data they buffer, and no longer accept data.
+func (p *Processor) Drain(ctx context.Context) error {
+	p.consumer.SetConsumers(nil, nil, nil)
+	return p.sched.Drain(ctx)
+}
+
 // CurrentHealth implements component.HealthComponent.
 func (p *Processor) CurrentHealth() component.Health {
 	return p.sched.CurrentHealth()
 }
+
+// DebugInfo implements component.DebugComponent.
+func (p *Processor) DebugInfo() interface{} {
+	return p.stats.DebugInfo()
+}
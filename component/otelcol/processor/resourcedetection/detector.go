@@ -0,0 +1,175 @@
+package resourcedetection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/resourcedetectionprocessor"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	otelprocessor "go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processorhelper"
+)
+
+// A detector detects resource attributes.
+type detector interface {
+	Detect(ctx context.Context) (pcommon.Resource, error)
+}
+
+// newFactory returns a factory for the upstream resourcedetection processor
+// which also supports the detectors in extra, by name. The upstream detectors
+// can't be replaced, so tests use extra detectors to detect known attributes.
+//
+// The extra detectors are run by a processor placed in front of the upstream
+// one, so the upstream detectors take precedence over them when override is
+// true, and the other way around when it's false.
+func newFactory(extra map[string]detector) otelprocessor.Factory {
+	upstream := resourcedetectionprocessor.NewFactory()
+
+	return otelprocessor.NewFactory(
+		upstream.Type(),
+		upstream.CreateDefaultConfig,
+		otelprocessor.WithTraces(func(ctx context.Context, set otelprocessor.CreateSettings, cfg otelcomponent.Config, next otelconsumer.Traces) (otelprocessor.Traces, error) {
+			ep, upstreamCfg := newExtraProcessor(cfg, extra)
+			if upstreamCfg != nil {
+				up, err := upstream.CreateTracesProcessor(ctx, set, upstreamCfg, next)
+				if err != nil {
+					return nil, err
+				}
+				ep.upstream, next = up, up
+			}
+			return processorhelper.NewTracesProcessor(ctx, set, cfg, next, ep.processTraces, ep.options()...)
+		}, upstream.TracesProcessorStability()),
+		otelprocessor.WithMetrics(func(ctx context.Context, set otelprocessor.CreateSettings, cfg otelcomponent.Config, next otelconsumer.Metrics) (otelprocessor.Metrics, error) {
+			ep, upstreamCfg := newExtraProcessor(cfg, extra)
+			if upstreamCfg != nil {
+				up, err := upstream.CreateMetricsProcessor(ctx, set, upstreamCfg, next)
+				if err != nil {
+					return nil, err
+				}
+				ep.upstream, next = up, up
+			}
+			return processorhelper.NewMetricsProcessor(ctx, set, cfg, next, ep.processMetrics, ep.options()...)
+		}, upstream.MetricsProcessorStability()),
+		otelprocessor.WithLogs(func(ctx context.Context, set otelprocessor.CreateSettings, cfg otelcomponent.Config, next otelconsumer.Logs) (otelprocessor.Logs, error) {
+			ep, upstreamCfg := newExtraProcessor(cfg, extra)
+			if upstreamCfg != nil {
+				up, err := upstream.CreateLogsProcessor(ctx, set, upstreamCfg, next)
+				if err != nil {
+					return nil, err
+				}
+				ep.upstream, next = up, up
+			}
+			return processorhelper.NewLogsProcessor(ctx, set, cfg, next, ep.processLogs, ep.options()...)
+		}, upstream.LogsProcessorStability()),
+	)
+}
+
+// extraProcessor adds the attributes detected by extra detectors to the
+// resources of the data it processes, before passing it to the upstream
+// processor if there's one.
+type extraProcessor struct {
+	cfg       *resourcedetectionprocessor.Config
+	detectors []detector
+	upstream  otelcomponent.Component
+
+	resource pcommon.Resource
+}
+
+// newExtraProcessor returns the processor running the detectors of cfg found
+// in extra, and the config of the upstream processor, which is nil if cfg only
+// has extra detectors.
+func newExtraProcessor(cfg otelcomponent.Config, extra map[string]detector) (*extraProcessor, *resourcedetectionprocessor.Config) {
+	rdCfg := cfg.(*resourcedetectionprocessor.Config)
+	ep := &extraProcessor{cfg: rdCfg, resource: pcommon.NewResource()}
+
+	upstreamCfg := *rdCfg
+	upstreamCfg.Detectors = nil
+	for _, name := range rdCfg.Detectors {
+		if d, ok := extra[name]; ok {
+			ep.detectors = append(ep.detectors, d)
+		} else {
+			upstreamCfg.Detectors = append(upstreamCfg.Detectors, name)
+		}
+	}
+	if len(upstreamCfg.Detectors) == 0 {
+		return ep, nil
+	}
+	return ep, &upstreamCfg
+}
+
+func (ep *extraProcessor) options() []processorhelper.Option {
+	return []processorhelper.Option{
+		processorhelper.WithCapabilities(otelconsumer.Capabilities{MutatesData: true}),
+		processorhelper.WithStart(ep.start),
+		processorhelper.WithShutdown(ep.shutdown),
+	}
+}
+
+// start starts the upstream processor and runs the extra detectors. Like for
+// the upstream detectors, the first detector to detect an attribute wins.
+func (ep *extraProcessor) start(ctx context.Context, host otelcomponent.Host) error {
+	if ep.upstream != nil {
+		if err := ep.upstream.Start(ctx, host); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ep.cfg.Timeout)
+	defer cancel()
+	for _, d := range ep.detectors {
+		res, err := d.Detect(ctx)
+		if err != nil {
+			return fmt.Errorf("detecting resource attributes: %w", err)
+		}
+		mergeResource(ep.resource, res, false)
+	}
+	return nil
+}
+
+func (ep *extraProcessor) shutdown(ctx context.Context) error {
+	if ep.upstream != nil {
+		return ep.upstream.Shutdown(ctx)
+	}
+	return nil
+}
+
+func (ep *extraProcessor) processTraces(_ context.Context, td ptrace.Traces) (ptrace.Traces, error) {
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		mergeResource(rs.At(i).Resource(), ep.resource, ep.cfg.Override)
+	}
+	return td, nil
+}
+
+func (ep *extraProcessor) processMetrics(_ context.Context, md pmetric.Metrics) (pmetric.Metrics, error) {
+	rm := md.ResourceMetrics()
+	for i := 0; i < rm.Len(); i++ {
+		mergeResource(rm.At(i).Resource(), ep.resource, ep.cfg.Override)
+	}
+	return md, nil
+}
+
+func (ep *extraProcessor) processLogs(_ context.Context, ld plog.Logs) (plog.Logs, error) {
+	rl := ld.ResourceLogs()
+	for i := 0; i < rl.Len(); i++ {
+		mergeResource(rl.At(i).Resource(), ep.resource, ep.cfg.Override)
+	}
+	return ld, nil
+}
+
+// mergeResource copies the attributes of from to to, replacing the existing
+// attributes of to only if override is true.
+func mergeResource(to, from pcommon.Resource, override bool) {
+	toAttrs := to.Attributes()
+	from.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if _, found := toAttrs.Get(k); override || !found {
+			v.CopyTo(toAttrs.PutEmpty(k))
+		}
+		return true
+	})
+}
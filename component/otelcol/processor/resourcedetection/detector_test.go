@@ -0,0 +1,113 @@
+package resourcedetection
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/processor"
+	"github.com/grafana/agent/component/otelcol/processor/processortest"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// fakeDetector detects a fixed set of attributes.
+type fakeDetector map[string]string
+
+func (d fakeDetector) Detect(context.Context) (pcommon.Resource, error) {
+	res := pcommon.NewResource()
+	for k, v := range d {
+		res.Attributes().PutStr(k, v)
+	}
+	return res, nil
+}
+
+func Test_FakeDetector(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=prod,host.name=env-host")
+
+	// The upstream env detector is run after the fake one, so the host name
+	// of the fake detector is kept. The attributes of the input are kept too
+	// since override is false.
+	cfg := `
+	override = false
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+
+	var inputTrace = `{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "cloud.provider",
+					"value": { "stringValue": "gcp" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api"
+				}]
+			}]
+		}]
+	}`
+
+	expectedOutputTrace := `{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "cloud.provider",
+					"value": { "stringValue": "gcp" }
+				},{
+					"key": "host.name",
+					"value": { "stringValue": "fake-host" }
+				},{
+					"key": "deployment.environment",
+					"value": { "stringValue": "prod" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api"
+				}]
+			}]
+		}]
+	}`
+
+	extra := map[string]detector{
+		"fake": fakeDetector{
+			"cloud.provider": "fake",
+			"host.name":      "fake-host",
+		},
+	}
+	l := util.TestLogger(t)
+	ctrl := componenttest.NewControllerFromReg(l, component.Registration{
+		Name:    "otelcol.processor.resourcedetection",
+		Args:    Arguments{},
+		Exports: otelcol.ConsumerExports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return processor.New(opts, newFactory(extra), args.(Arguments))
+		},
+	})
+
+	// The fake detector is unknown to Validate, so it's only added once the
+	// arguments are decoded.
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	args.Detectors = []string{"fake", "env"}
+	testSignal := processortest.NewTraceSignal(inputTrace, expectedOutputTrace)
+	args.Output = testSignal.MakeOutput()
+
+	processortest.TestRunProcessor(processortest.ProcessorRunConfig{
+		Ctx:        componenttest.TestContext(t),
+		T:          t,
+		Args:       args,
+		TestSignal: testSignal,
+		Ctrl:       ctrl,
+		L:          l,
+	})
+}
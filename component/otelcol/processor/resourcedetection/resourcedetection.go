@@ -0,0 +1,148 @@
+// Package resourcedetection provides an otelcol.processor.resourcedetection component.
+package resourcedetection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/processor"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/resourcedetectionprocessor"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.processor.resourcedetection",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			fact := newFactory(nil)
+			return processor.New(opts, fact, args.(Arguments))
+		},
+	})
+}
+
+// detectors holds the names of the detectors supported by the upstream
+// resourcedetection processor.
+var detectors = map[string]struct{}{
+	"aks":               {},
+	"azure":             {},
+	"consul":            {},
+	"docker":            {},
+	"ec2":               {},
+	"ecs":               {},
+	"eks":               {},
+	"elastic_beanstalk": {},
+	"env":               {},
+	"gcp":               {},
+	"heroku":            {},
+	"k8snode":           {},
+	"lambda":            {},
+	"openshift":         {},
+	"system":            {},
+}
+
+// Arguments configures the otelcol.processor.resourcedetection component.
+type Arguments struct {
+	// Detectors is an ordered list of the detectors used to detect resource
+	// attributes.
+	Detectors []string `river:"detectors,attr,optional"`
+
+	// Override determines whether existing resource attributes are replaced
+	// by detected ones.
+	Override bool `river:"override,attr,optional"`
+
+	// Timeout bounds the time detectors spend querying metadata endpoints.
+	Timeout time.Duration `river:"timeout,attr,optional"`
+
+	System SystemConfig `river:"system,block,optional"`
+
+	// Output configures where to send processed data. Required.
+	Output *otelcol.ConsumerArguments `river:"output,block"`
+}
+
+// SystemConfig configures the system detector.
+type SystemConfig struct {
+	HostnameSources []string `river:"hostname_sources,attr,optional"`
+}
+
+var (
+	_ processor.Arguments = Arguments{}
+)
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	Detectors: []string{"env"},
+	Override:  true,
+	Timeout:   5 * time.Second,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if len(args.Detectors) == 0 {
+		return fmt.Errorf("at least one detector must be set")
+	}
+	for _, d := range args.Detectors {
+		if _, ok := detectors[d]; !ok {
+			return fmt.Errorf("unknown detector %q", d)
+		}
+	}
+	if args.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than zero")
+	}
+
+	otelArgs, err := args.convertImpl()
+	if err != nil {
+		return err
+	}
+	return otelcomponent.ValidateConfig(otelArgs)
+}
+
+// Convert implements processor.Arguments.
+func (args Arguments) Convert() (otelcomponent.Config, error) {
+	return args.convertImpl()
+}
+
+// convertImpl is a helper function which returns the real type of the config,
+// instead of the otelcomponent.Config interface.
+func (args Arguments) convertImpl() (*resourcedetectionprocessor.Config, error) {
+	// Start from the upstream defaults, so that the detectors which can't be
+	// configured from River use their default settings.
+	defaultCfg := resourcedetectionprocessor.NewFactory().CreateDefaultConfig()
+	cfg, ok := defaultCfg.(*resourcedetectionprocessor.Config)
+	if !ok {
+		return nil, fmt.Errorf("unexpected default config type %T", defaultCfg)
+	}
+
+	cfg.Detectors = append([]string{}, args.Detectors...)
+	cfg.Override = args.Override
+	cfg.Timeout = args.Timeout
+	cfg.DetectorConfig.SystemConfig.HostnameSources = append([]string{}, args.System.HostnameSources...)
+	return cfg, nil
+}
+
+// Extensions implements processor.Arguments.
+func (args Arguments) Extensions() map[otelcomponent.ID]otelextension.Extension {
+	return nil
+}
+
+// Exporters implements processor.Arguments.
+func (args Arguments) Exporters() map[otelcomponent.DataType]map[otelcomponent.ID]otelcomponent.Component {
+	return nil
+}
+
+// NextConsumers implements processor.Arguments.
+func (args Arguments) NextConsumers() *otelcol.ConsumerArguments {
+	return args.Output
+}
@@ -0,0 +1,178 @@
+package resourcedetection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/otelcol/processor/processortest"
+	"github.com/grafana/agent/component/otelcol/processor/resourcedetection"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/resourcedetectionprocessor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments_UnmarshalRiver(t *testing.T) {
+	tests := []struct {
+		testName string
+		cfg      string
+		check    func(t *testing.T, cfg *resourcedetectionprocessor.Config)
+		errMsg   string
+	}{
+		{
+			testName: "Defaults",
+			cfg: `
+			output {}
+			`,
+			check: func(t *testing.T, cfg *resourcedetectionprocessor.Config) {
+				require.Equal(t, []string{"env"}, cfg.Detectors)
+				require.True(t, cfg.Override)
+				require.Equal(t, 5*time.Second, cfg.Timeout)
+			},
+		},
+		{
+			testName: "System",
+			cfg: `
+			detectors = ["env", "system"]
+			override  = false
+			timeout   = "2s"
+			system {
+				hostname_sources = ["os"]
+			}
+			output {}
+			`,
+			check: func(t *testing.T, cfg *resourcedetectionprocessor.Config) {
+				require.Equal(t, []string{"env", "system"}, cfg.Detectors)
+				require.False(t, cfg.Override)
+				require.Equal(t, 2*time.Second, cfg.Timeout)
+				require.Equal(t, []string{"os"}, cfg.DetectorConfig.SystemConfig.HostnameSources)
+			},
+		},
+		{
+			testName: "UnknownDetector",
+			cfg: `
+			detectors = ["env", "mainframe"]
+			output {}
+			`,
+			errMsg: `unknown detector "mainframe"`,
+		},
+		{
+			testName: "NoDetectors",
+			cfg: `
+			detectors = []
+			output {}
+			`,
+			errMsg: "at least one detector must be set",
+		},
+		{
+			testName: "InvalidHostnameSource",
+			cfg: `
+			detectors = ["system"]
+			system {
+				hostname_sources = ["guess"]
+			}
+			output {}
+			`,
+			errMsg: `hostname_sources contains invalid value: "guess"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			var args resourcedetection.Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			actual, err := args.Convert()
+			require.NoError(t, err)
+			tc.check(t, actual.(*resourcedetectionprocessor.Config))
+		})
+	}
+}
+
+func testRunProcessor(t *testing.T, processorConfig string, testSignal processortest.Signal) {
+	ctx := componenttest.TestContext(t)
+	testRunProcessorWithContext(ctx, t, processorConfig, testSignal)
+}
+
+func testRunProcessorWithContext(ctx context.Context, t *testing.T, processorConfig string, testSignal processortest.Signal) {
+	l := util.TestLogger(t)
+
+	ctrl, err := componenttest.NewControllerFromID(l, "otelcol.processor.resourcedetection")
+	require.NoError(t, err)
+
+	var args resourcedetection.Arguments
+	require.NoError(t, river.Unmarshal([]byte(processorConfig), &args))
+
+	// Override the arguments so signals get forwarded to the test channel.
+	args.Output = testSignal.MakeOutput()
+
+	prc := processortest.ProcessorRunConfig{
+		Ctx:        ctx,
+		T:          t,
+		Args:       args,
+		TestSignal: testSignal,
+		Ctrl:       ctrl,
+		L:          l,
+	}
+	processortest.TestRunProcessor(prc)
+}
+
+func Test_EnvDetector(t *testing.T) {
+	// The env detector reads resource attributes from the environment
+	// variable defined by the OpenTelemetry SDK specification.
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.name=checkout,deployment.environment=prod")
+
+	cfg := `
+	detectors = ["env"]
+	override  = false
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+
+	var inputTrace = `{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "service.name",
+					"value": { "stringValue": "cart" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api"
+				}]
+			}]
+		}]
+	}`
+
+	// Existing attributes are kept, since override is false.
+	expectedOutputTrace := `{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "service.name",
+					"value": { "stringValue": "cart" }
+				},{
+					"key": "deployment.environment",
+					"value": { "stringValue": "prod" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api"
+				}]
+			}]
+		}]
+	}`
+
+	testRunProcessor(t, cfg, processortest.NewTraceSignal(inputTrace, expectedOutputTrace))
+}
@@ -2,12 +2,14 @@
 package transform
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/processor"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/mitchellh/mapstructure"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/transformprocessor"
@@ -17,9 +19,10 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "otelcol.processor.transform",
-		Args:    Arguments{},
-		Exports: otelcol.ConsumerExports{},
+		Name:      "otelcol.processor.transform",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := transformprocessor.NewFactory()
@@ -90,9 +93,49 @@ func (args *Arguments) Validate() error {
 	if err != nil {
 		return err
 	}
+	if err := args.validateStatements(); err != nil {
+		return err
+	}
 	return otelArgs.Validate()
 }
 
+// validateStatements parses every statement on its own, so that an invalid
+// statement is reported along with the block it's set in.
+func (args *Arguments) validateStatements() error {
+	var errs []error
+	validate := func(blockName string, blocks contextStatementsSlice, withBlock func(Arguments, contextStatementsSlice) Arguments) {
+		for i, block := range blocks {
+			for j, stmt := range block.Statements {
+				single := withBlock(Arguments{ErrorMode: args.ErrorMode}, contextStatementsSlice{{
+					Context:    block.Context,
+					Statements: []string{stmt},
+				}})
+				otelArgs, err := single.convertImpl()
+				if err == nil {
+					err = otelArgs.Validate()
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("invalid statement %s[%d].statements[%d] %q: %w", blockName, i, j, stmt, err))
+				}
+			}
+		}
+	}
+
+	validate("trace_statements", args.TraceStatements, func(a Arguments, s contextStatementsSlice) Arguments {
+		a.TraceStatements = s
+		return a
+	})
+	validate("metric_statements", args.MetricStatements, func(a Arguments, s contextStatementsSlice) Arguments {
+		a.MetricStatements = s
+		return a
+	})
+	validate("log_statements", args.LogStatements, func(a Arguments, s contextStatementsSlice) Arguments {
+		a.LogStatements = s
+		return a
+	})
+	return errors.Join(errs...)
+}
+
 func (stmts *contextStatementsSlice) convert() []interface{} {
 	if stmts == nil {
 		return nil
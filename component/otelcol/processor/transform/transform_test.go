@@ -1,9 +1,13 @@
 package transform_test
 
 import (
+	"context"
 	"testing"
 
+	"github.com/grafana/agent/component/otelcol/processor/processortest"
 	"github.com/grafana/agent/component/otelcol/processor/transform"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
 	"github.com/mitchellh/mapstructure"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/transformprocessor"
@@ -518,6 +522,26 @@ func TestArguments_UnmarshalRiver(t *testing.T) {
 			`,
 			errorMsg: `3:15: "test" unknown context test`,
 		},
+		{
+			testName: "invalid_statement_location",
+			cfg: `
+			trace_statements {
+				context = "resource"
+				statements = [
+					` + backtick + `delete_key(attributes, "host.name")` + backtick + `,
+				]
+			}
+			trace_statements {
+				context = "span"
+				statements = [
+					` + backtick + `set(name, "bear")` + backtick + `,
+					` + backtick + `not_a_function(attributes)` + backtick + `,
+				]
+			}
+			output {}
+			`,
+			errorMsg: `invalid statement trace_statements[1].statements[1] "not_a_function(attributes)"`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -549,3 +573,152 @@ func TestArguments_UnmarshalRiver(t *testing.T) {
 		})
 	}
 }
+
+func testRunProcessor(t *testing.T, processorConfig string, testSignal processortest.Signal) {
+	ctx := componenttest.TestContext(t)
+	testRunProcessorWithContext(ctx, t, processorConfig, testSignal)
+}
+
+func testRunProcessorWithContext(ctx context.Context, t *testing.T, processorConfig string, testSignal processortest.Signal) {
+	l := util.TestLogger(t)
+
+	ctrl, err := componenttest.NewControllerFromID(l, "otelcol.processor.transform")
+	require.NoError(t, err)
+
+	var args transform.Arguments
+	require.NoError(t, river.Unmarshal([]byte(processorConfig), &args))
+
+	// Override the arguments so signals get forwarded to the test channel.
+	args.Output = testSignal.MakeOutput()
+
+	prc := processortest.ProcessorRunConfig{
+		Ctx:        ctx,
+		T:          t,
+		Args:       args,
+		TestSignal: testSignal,
+		Ctrl:       ctrl,
+		L:          l,
+	}
+	processortest.TestRunProcessor(prc)
+}
+
+func Test_RenameAndDeleteSpanAttributes(t *testing.T) {
+	cfg := `
+	trace_statements {
+		context = "span"
+		statements = [
+			` + backtick + `set(attributes["http.request.method"], attributes["http.method"])` + backtick + `,
+			` + backtick + `delete_key(attributes, "http.method")` + backtick + `,
+			` + backtick + `delete_key(attributes, "user.email")` + backtick + `,
+		]
+	}
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+
+	var inputTrace = `{
+		"resourceSpans": [{
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api",
+					"attributes": [{
+						"key": "http.method",
+						"value": { "stringValue": "GET" }
+					},{
+						"key": "user.email",
+						"value": { "stringValue": "user@example.com" }
+					},{
+						"key": "http.route",
+						"value": { "stringValue": "/api" }
+					}]
+				}]
+			}]
+		}]
+	}`
+
+	expectedOutputTrace := `{
+		"resourceSpans": [{
+			"scopeSpans": [{
+				"spans": [{
+					"name": "GET /api",
+					"attributes": [{
+						"key": "http.request.method",
+						"value": { "stringValue": "GET" }
+					},{
+						"key": "http.route",
+						"value": { "stringValue": "/api" }
+					}]
+				}]
+			}]
+		}]
+	}`
+
+	testRunProcessor(t, cfg, processortest.NewTraceSignal(inputTrace, expectedOutputTrace))
+}
+
+func Test_IgnoreStatementErrors(t *testing.T) {
+	// The first statement fails for spans whose payload isn't valid JSON. With
+	// error_mode set to "ignore", the error is logged and the remaining
+	// statements are still applied.
+	cfg := `
+	error_mode = "ignore"
+	trace_statements {
+		context = "span"
+		statements = [
+			` + backtick + `merge_maps(attributes, ParseJSON(attributes["payload"]), "upsert")` + backtick + `,
+			` + backtick + `delete_key(attributes, "payload")` + backtick + `,
+		]
+	}
+
+	output {
+		// no-op: will be overridden by test code.
+	}
+	`
+
+	var inputTrace = `{
+		"resourceSpans": [{
+			"scopeSpans": [{
+				"spans": [{
+					"name": "invalid",
+					"attributes": [{
+						"key": "payload",
+						"value": { "stringValue": "{not json" }
+					},{
+						"key": "user",
+						"value": { "stringValue": "alice" }
+					}]
+				},{
+					"name": "valid",
+					"attributes": [{
+						"key": "payload",
+						"value": { "stringValue": "{\"user\": \"bob\"}" }
+					}]
+				}]
+			}]
+		}]
+	}`
+
+	expectedOutputTrace := `{
+		"resourceSpans": [{
+			"scopeSpans": [{
+				"spans": [{
+					"name": "invalid",
+					"attributes": [{
+						"key": "user",
+						"value": { "stringValue": "alice" }
+					}]
+				},{
+					"name": "valid",
+					"attributes": [{
+						"key": "user",
+						"value": { "stringValue": "bob" }
+					}]
+				}]
+			}]
+		}]
+	}`
+
+	testRunProcessor(t, cfg, processortest.NewTraceSignal(inputTrace, expectedOutputTrace))
+}
@@ -1,6 +1,7 @@
 package otlp_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -95,6 +96,80 @@ func Test(t *testing.T) {
 	}
 }
 
+// TestHTTPEncodings ensures that the HTTP server of otelcol.receiver.otlp
+// accepts both protobuf and JSON requests, and responds with the encoding of
+// the request.
+func TestHTTPEncodings(t *testing.T) {
+	httpAddr := getFreeAddr(t)
+
+	ctx := componenttest.TestContext(t)
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "otelcol.receiver.otlp")
+	require.NoError(t, err)
+
+	var args otlp.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		http {
+			endpoint = "%s"
+		}
+
+		output {
+			// no-op: will be overridden by test code.
+		}
+	`, httpAddr)), &args))
+	traceCh := make(chan ptrace.Traces, 1)
+	args.Output = makeTracesOutput(traceCh)
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second))
+
+	payload, err := os.ReadFile("testdata/payload.json")
+	require.NoError(t, err)
+	traces, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(payload)
+	require.NoError(t, err)
+	protoPayload, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+	require.NoError(t, err)
+
+	tracesURL := fmt.Sprintf("http://%s/v1/traces", httpAddr)
+	post := func(contentType string, body []byte) *http.Response {
+		var resp *http.Response
+		require.Eventually(t, func() bool {
+			resp, err = http.Post(tracesURL, contentType, bytes.NewReader(body))
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	tt := []struct {
+		contentType string
+		body        []byte
+	}{
+		{contentType: "application/json", body: payload},
+		{contentType: "application/x-protobuf", body: protoPayload},
+	}
+	for _, tc := range tt {
+		t.Run(tc.contentType, func(t *testing.T) {
+			resp := post(tc.contentType, tc.body)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tc.contentType, resp.Header.Get("Content-Type"))
+
+			select {
+			case <-time.After(time.Second):
+				require.FailNow(t, "failed waiting for traces")
+			case tr := <-traceCh:
+				require.Equal(t, traces, tr)
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		resp := post("text/plain", payload)
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+}
+
 // makeTracesOutput returns ConsumerArguments which will forward traces to the
 // provided channel.
 func makeTracesOutput(ch chan ptrace.Traces) *otelcol.ConsumerArguments {
@@ -6,17 +6,20 @@ import (
 	"context"
 	"errors"
 	"os"
+	"sync"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/otelcol/internal/fanoutconsumer"
 	"github.com/grafana/agent/component/otelcol/internal/lazycollector"
+	"github.com/grafana/agent/component/otelcol/internal/pipelinestats"
 	"github.com/grafana/agent/component/otelcol/internal/scheduler"
 	"github.com/grafana/agent/component/otelcol/internal/views"
 	"github.com/grafana/agent/pkg/build"
 	"github.com/grafana/agent/pkg/util/zapadapter"
 	"github.com/prometheus/client_golang/prometheus"
 	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/extension"
 	otelreceiver "go.opentelemetry.io/collector/receiver"
 	sdkprometheus "go.opentelemetry.io/otel/exporters/prometheus"
@@ -55,14 +58,17 @@ type Receiver struct {
 
 	opts    component.Options
 	factory otelreceiver.Factory
+	stats   *pipelinestats.Stats
 
 	sched     *scheduler.Scheduler
 	collector *lazycollector.Collector
 }
 
 var (
-	_ component.Component       = (*Receiver)(nil)
-	_ component.HealthComponent = (*Receiver)(nil)
+	_ component.Component          = (*Receiver)(nil)
+	_ component.HealthComponent    = (*Receiver)(nil)
+	_ component.DebugComponent     = (*Receiver)(nil)
+	_ component.DrainableComponent = (*Receiver)(nil)
 )
 
 // New creates a new Flow component which encapsulates an OpenTelemetry
@@ -86,10 +92,16 @@ func New(opts component.Options, f otelreceiver.Factory, args Arguments) (*Recei
 
 		opts:    opts,
 		factory: f,
+		stats:   pipelinestats.New(),
 
 		sched:     scheduler.New(opts.Logger),
 		collector: collector,
 	}
+	// Receivers count the items they send, since they receive them from
+	// outside of Flow.
+	if err := r.stats.CountThroughput(opts.Registerer, true); err != nil {
+		return nil, err
+	}
 	if err := r.Update(args); err != nil {
 		return nil, err
 	}
@@ -153,42 +165,103 @@ func (r *Receiver) Update(args component.Arguments) error {
 
 	var (
 		next        = rargs.NextConsumers()
-		nextTraces  = fanoutconsumer.Traces(next.Traces)
-		nextMetrics = fanoutconsumer.Metrics(next.Metrics)
-		nextLogs    = fanoutconsumer.Logs(next.Logs)
+		nextTraces  = r.stats.OutputTraces(fanoutconsumer.Traces(next.Traces))
+		nextMetrics = r.stats.OutputMetrics(fanoutconsumer.Metrics(next.Metrics))
+		nextLogs    = r.stats.OutputLogs(fanoutconsumer.Logs(next.Logs))
 	)
 
 	// Create instances of the receiver from our factory for each of our
 	// supported telemetry signals.
+	components, err := r.createReceivers(settings, receiverConfig, nextTraces, nextMetrics, nextLogs)
+	if err != nil {
+		return err
+	}
+
+	// Schedule the components to run once our component is running.
+	r.sched.Schedule(host, components...)
+	return nil
+}
+
+// createReceivers creates the receivers of each of the telemetry signals
+// supported by the factory, while holding the shared components lock of the
+// factory.
+func (r *Receiver) createReceivers(
+	settings otelreceiver.CreateSettings,
+	cfg otelcomponent.Config,
+	nextTraces otelconsumer.Traces,
+	nextMetrics otelconsumer.Metrics,
+	nextLogs otelconsumer.Logs,
+) ([]otelcomponent.Component, error) {
+	mut := sharedComponentsMut(r.factory.Type())
+	mut.Lock()
+	defer mut.Unlock()
+
 	var components []otelcomponent.Component
 
-	tracesReceiver, err := r.factory.CreateTracesReceiver(r.ctx, settings, receiverConfig, nextTraces)
+	tracesReceiver, err := r.factory.CreateTracesReceiver(r.ctx, settings, cfg, nextTraces)
 	if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
-		return err
+		return nil, err
 	} else if tracesReceiver != nil {
-		components = append(components, tracesReceiver)
+		components = append(components, serializedShutdown{tracesReceiver, mut})
 	}
 
-	metricsReceiver, err := r.factory.CreateMetricsReceiver(r.ctx, settings, receiverConfig, nextMetrics)
+	metricsReceiver, err := r.factory.CreateMetricsReceiver(r.ctx, settings, cfg, nextMetrics)
 	if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
-		return err
+		return nil, err
 	} else if metricsReceiver != nil {
-		components = append(components, metricsReceiver)
+		components = append(components, serializedShutdown{metricsReceiver, mut})
 	}
 
-	logsReceiver, err := r.factory.CreateLogsReceiver(r.ctx, settings, receiverConfig, nextLogs)
+	logsReceiver, err := r.factory.CreateLogsReceiver(r.ctx, settings, cfg, nextLogs)
 	if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
-		return err
+		return nil, err
 	} else if logsReceiver != nil {
-		components = append(components, logsReceiver)
+		components = append(components, serializedShutdown{logsReceiver, mut})
 	}
 
-	// Schedule the components to run once our component is running.
-	r.sched.Schedule(host, components...)
-	return nil
+	return components, nil
+}
+
+// sharedComponentsMuts holds the locks serializing creating and shutting down
+// receivers, by factory type. Some upstream receivers, like the OTLP receiver,
+// share instances through a global map of their package which isn't safe for
+// concurrent use, and which is written to in both cases. Each factory has its
+// own map, so receivers of different types aren't serialized.
+var sharedComponentsMuts sync.Map // map[otelcomponent.Type]*sync.Mutex
+
+// sharedComponentsMut returns the shared components lock of the factories of
+// type typ.
+func sharedComponentsMut(typ otelcomponent.Type) *sync.Mutex {
+	mut, _ := sharedComponentsMuts.LoadOrStore(typ, &sync.Mutex{})
+	return mut.(*sync.Mutex)
+}
+
+// serializedShutdown wraps a receiver so that it's shut down while holding
+// the shared components lock of its factory.
+type serializedShutdown struct {
+	otelcomponent.Component
+	mut *sync.Mutex
+}
+
+// Shutdown implements otelcomponent.Component.
+func (c serializedShutdown) Shutdown(ctx context.Context) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.Component.Shutdown(ctx)
+}
+
+// Drain implements component.DrainableComponent. The upstream receivers are
+// shut down so that they no longer accept data.
+func (r *Receiver) Drain(ctx context.Context) error {
+	return r.sched.Drain(ctx)
 }
 
 // CurrentHealth implements component.HealthComponent.
 func (r *Receiver) CurrentHealth() component.Health {
 	return r.sched.CurrentHealth()
 }
+
+// DebugInfo implements component.DebugComponent.
+func (r *Receiver) DebugInfo() interface{} {
+	return r.stats.DebugInfo()
+}
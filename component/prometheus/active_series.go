@@ -0,0 +1,38 @@
+package prometheus
+
+import (
+	"github.com/grafana/agent/component"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// ActiveSeriesComponent is an extension interface for components which track
+// the series they recently received, such as the series of a WAL.
+type ActiveSeriesComponent interface {
+	component.Component
+
+	// ActiveSeries returns the label sets of the active series for which
+	// match returns true. Series which were marked stale are omitted.
+	//
+	// ActiveSeries must be safe for calling concurrently.
+	ActiveSeries(match func(labels.Labels) bool) []labels.Labels
+}
+
+// MatchesAny reports whether l matches all of the matchers of any of the
+// selectors.
+func MatchesAny(selectors [][]*labels.Matcher, l labels.Labels) bool {
+	for _, matchers := range selectors {
+		if matchesAll(matchers, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(matchers []*labels.Matcher, l labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(l.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
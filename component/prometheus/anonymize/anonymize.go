@@ -0,0 +1,165 @@
+// Package anonymize provides the prometheus.anonymize component.
+package anonymize
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/anonymize"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.anonymize",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// prometheus.anonymize component.
+type Arguments struct {
+	// Where the anonymized metrics should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	Anonymize anonymize.Arguments `river:",squash"`
+}
+
+// Exports holds values which are exported by the prometheus.anonymize
+// component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.anonymize component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	mut        sync.RWMutex
+	anonymizer *anonymize.Anonymizer
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.anonymize component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{opts: o}
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			return next.Append(0, c.anonymize(l), t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			// The labels of exemplars, such as user IDs, are anonymized as well.
+			e.Labels = c.anonymize(e.Labels)
+			return next.AppendExemplar(0, c.anonymize(l), e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			return next.UpdateMetadata(0, c.anonymize(l), m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			return next.AppendHistogram(0, c.anonymize(l), t, h, fh)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	anonymizer, err := anonymize.New(newArgs.Anonymize)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.anonymizer = anonymizer
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// anonymize returns lbls with the values of the sensitive labels hashed or
+// dropped.
+func (c *Component) anonymize(lbls labels.Labels) labels.Labels {
+	c.mut.RLock()
+	a := c.anonymizer
+	c.mut.RUnlock()
+
+	var changed bool
+	lbls.Range(func(l labels.Label) {
+		if a.Applies(l.Name) {
+			changed = true
+		}
+	})
+	if !changed {
+		return lbls
+	}
+
+	b := labels.NewScratchBuilder(lbls.Len())
+	lbls.Range(func(l labels.Label) {
+		if v, ok := a.Value(l.Name, l.Value); ok {
+			b.Add(l.Name, v)
+		}
+	})
+	return b.Labels()
+}
@@ -0,0 +1,123 @@
+package anonymize
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	commonanonymize "github.com/grafana/agent/component/common/anonymize"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to = []
+	salt       = "s3cr3t"
+
+	rule {
+		labels = ["user_id"]
+	}
+`), &args))
+	require.Equal(t, "s3cr3t", string(args.Anonymize.Salt))
+	require.Equal(t, []commonanonymize.Rule{{Labels: []string{"user_id"}, Regex: ".*", Action: "hash"}}, args.Anonymize.Rules)
+}
+
+func TestAnonymize(t *testing.T) {
+	ls := labelstore.New(nil)
+	sink := newFakeSink(ls)
+	c, err := New(component.Options{
+		ID:            "prometheus.anonymize.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, Arguments{
+		ForwardTo: []storage.Appendable{sink.interceptor},
+		Anonymize: commonanonymize.Arguments{
+			Salt: "s3cr3t",
+			Rules: []commonanonymize.Rule{
+				{Labels: []string{"user_id"}, Regex: ".*", Action: "hash"},
+				{Labels: []string{"email"}, Regex: ".*", Action: "drop"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	send := func() {
+		app := c.receiver.Appender(context.Background())
+		l := labels.FromStrings("__name__", "requests_total", "job", "api", "user_id", "42", "email", "jane@example.com")
+		_, err := app.Append(0, l, 1000, 1)
+		require.NoError(t, err)
+		_, err = app.AppendExemplar(0, l, exemplar.Exemplar{Labels: labels.FromStrings("user_id", "42"), Value: 1, Ts: 1000, HasTs: true})
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+	send()
+	send()
+
+	received := sink.series()
+	require.Len(t, received, 2)
+	// The series is anonymized deterministically, other labels pass through.
+	require.Equal(t, received[0], received[1])
+	require.Equal(t, "api", received[0].Get("job"))
+	require.Equal(t, "requests_total", received[0].Get("__name__"))
+	require.False(t, received[0].Has("email"))
+	hashed := received[0].Get("user_id")
+	require.Len(t, hashed, 32)
+	require.NotEqual(t, "42", hashed)
+
+	require.Equal(t, []string{hashed, hashed}, sink.exemplarUserIDs())
+}
+
+// fakeSink records the series of every sample and the user_id label of every
+// exemplar it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut       sync.Mutex
+	received  []labels.Labels
+	exemplars []string
+}
+
+func newFakeSink(ls labelstore.LabelStore) *fakeSink {
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls,
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+			sink.mut.Lock()
+			defer sink.mut.Unlock()
+			sink.received = append(sink.received, l)
+			return ref, nil
+		}),
+		prometheus.WithExemplarHook(func(ref storage.SeriesRef, _ labels.Labels, e exemplar.Exemplar, _ storage.Appender) (storage.SeriesRef, error) {
+			sink.mut.Lock()
+			defer sink.mut.Unlock()
+			sink.exemplars = append(sink.exemplars, e.Labels.Get("user_id"))
+			return ref, nil
+		}),
+	)
+	return sink
+}
+
+func (s *fakeSink) series() []labels.Labels {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]labels.Labels{}, s.received...)
+}
+
+func (s *fakeSink) exemplarUserIDs() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.exemplars...)
+}
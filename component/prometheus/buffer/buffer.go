@@ -0,0 +1,520 @@
+// Package buffer provides the prometheus.buffer component.
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.buffer",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// replayInterval is how often buffered samples are replayed to the receivers
+// which failed to accept them.
+const replayInterval = time.Second
+
+// Arguments holds values which are used to configure the prometheus.buffer
+// component.
+type Arguments struct {
+	// Where received metrics are forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+	// Maximum number of samples held in the buffer.
+	MaxSamples int `river:"max_samples,attr,optional"`
+	// Maximum time samples are held in the buffer.
+	MaxAge time.Duration `river:"max_age,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for Arguments.
+var DefaultArguments = Arguments{
+	MaxSamples: 100000,
+	MaxAge:     time.Minute,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.MaxSamples <= 0 {
+		return fmt.Errorf("max_samples must be greater than 0")
+	}
+	if args.MaxAge <= 0 {
+		return fmt.Errorf("max_age must be greater than 0")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.buffer component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.buffer component.
+//
+// Committed batches of samples are forwarded to every receiver. Batches a
+// receiver fails to accept, such as because the receiver was stopped while
+// the configuration is reloaded, are buffered and replayed to the receiver
+// until it accepts them. When a receiver is replaced by new receivers, the
+// batches it didn't accept are replayed to the new receivers instead.
+type Component struct {
+	opts   component.Options
+	ls     labelstore.LabelStore
+	exited atomic.Bool
+
+	buffered prometheus_client.Gauge
+	replayed prometheus_client.Counter
+	dropped  *prometheus_client.CounterVec
+
+	// Batches aren't delivered under mut, so that a slow receiver doesn't
+	// block the others. Receivers get the batches in the order they were
+	// committed, except for the batches committed while delivering to a
+	// receiver fails, which may reach it before the batch which failed.
+	mut      sync.Mutex
+	args     Arguments
+	children []storage.Appendable
+	pending  []*batch
+	samples  int // Number of samples of the pending batches.
+
+	updated chan struct{}
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// New creates a new prometheus.buffer component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts: o,
+		ls:   data.(labelstore.LabelStore),
+
+		buffered: prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+			Name: "agent_prometheus_buffer_buffered_samples",
+			Help: "Number of samples held in the buffer.",
+		}),
+		replayed: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "agent_prometheus_buffer_replayed_samples_total",
+			Help: "Total number of buffered samples replayed to a receiver.",
+		}),
+		dropped: prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+			Name: "agent_prometheus_buffer_dropped_samples_total",
+			Help: "Total number of buffered samples dropped before they could be replayed.",
+		}, []string{"reason"}),
+
+		updated: make(chan struct{}, 1),
+	}
+	for _, collector := range []prometheus_client.Collector{c.buffered, c.replayed, c.dropped} {
+		if err := o.Registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-c.updated:
+		}
+		c.replay(ctx)
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var added []storage.Appendable
+	for _, child := range newArgs.ForwardTo {
+		if !contains(c.children, child) {
+			added = append(added, child)
+		}
+	}
+	for _, b := range c.pending {
+		var (
+			remaining []storage.Appendable
+			replaced  bool
+		)
+		for _, child := range b.remaining {
+			if contains(newArgs.ForwardTo, child) {
+				remaining = append(remaining, child)
+			} else {
+				replaced = true
+			}
+		}
+		if replaced {
+			for _, child := range added {
+				if !contains(remaining, child) {
+					remaining = append(remaining, child)
+				}
+			}
+		}
+		b.remaining = remaining
+	}
+
+	c.args = newArgs
+	c.children = newArgs.ForwardTo
+	c.trim(time.Now())
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Appender implements storage.Appendable.
+func (c *Component) Appender(ctx context.Context) storage.Appender {
+	return &appender{c: c, ctx: ctx}
+}
+
+// commit forwards b to the receivers. Receivers which fail to accept b, or
+// which still have pending batches, get b once they accepted the previous
+// batches.
+func (c *Component) commit(ctx context.Context, b *batch) {
+	c.mut.Lock()
+	children := append([]storage.Appendable(nil), c.children...)
+	var deliver []storage.Appendable
+	for _, child := range children {
+		if c.hasPending(child) {
+			b.remaining = append(b.remaining, child)
+		} else {
+			deliver = append(deliver, child)
+		}
+	}
+	c.mut.Unlock()
+
+	var failed []storage.Appendable
+	for _, child := range deliver {
+		if err := c.deliver(ctx, child, b); err != nil {
+			failed = append(failed, child)
+		}
+	}
+	if len(b.remaining) == 0 && len(failed) == 0 {
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// The receivers may have been replaced while b was delivered, in which
+	// case b is forwarded to the new receivers instead, like Update does for
+	// the pending batches.
+	var (
+		remaining []storage.Appendable
+		replaced  bool
+	)
+	for _, child := range append(b.remaining, failed...) {
+		if contains(c.children, child) {
+			remaining = appendMissing(remaining, child)
+		} else {
+			replaced = true
+		}
+	}
+	if replaced {
+		for _, child := range c.children {
+			if !contains(children, child) {
+				remaining = appendMissing(remaining, child)
+			}
+		}
+	}
+	b.remaining = remaining
+	if len(b.remaining) > 0 {
+		c.pending = append(c.pending, b)
+		c.samples += b.samples
+		c.trim(time.Now())
+	}
+}
+
+// replay forwards the pending batches to the receivers which didn't accept
+// them yet, in order. Replaying to a receiver stops at the first batch it
+// fails to accept.
+//
+// Batches committed while replaying are queued after the pending batches of
+// the receivers being replayed to.
+func (c *Component) replay(ctx context.Context) {
+	type attempt struct {
+		b         *batch
+		remaining []storage.Appendable
+		delivered []storage.Appendable
+	}
+
+	c.mut.Lock()
+	c.trim(time.Now())
+	attempts := make([]attempt, 0, len(c.pending))
+	for _, b := range c.pending {
+		attempts = append(attempts, attempt{b: b, remaining: append([]storage.Appendable(nil), b.remaining...)})
+	}
+	c.mut.Unlock()
+
+	failed := make(map[storage.Appendable]struct{})
+	for i, a := range attempts {
+		for _, child := range a.remaining {
+			if _, ok := failed[child]; ok {
+				continue
+			}
+			if err := c.deliver(ctx, child, a.b); err != nil {
+				level.Debug(c.opts.Logger).Log("msg", "failed to replay buffered samples", "err", err)
+				failed[child] = struct{}{}
+				continue
+			}
+			attempts[i].delivered = append(attempts[i].delivered, child)
+			c.replayed.Add(float64(a.b.samples))
+		}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// The receivers of the batches may have changed while replaying, so only
+	// the ones which got the batches are removed.
+	for _, a := range attempts {
+		var remaining []storage.Appendable
+		for _, child := range a.b.remaining {
+			if !contains(a.delivered, child) {
+				remaining = append(remaining, child)
+			}
+		}
+		a.b.remaining = remaining
+	}
+	c.trim(time.Now())
+}
+
+// trim drops the pending batches which are older than max_age, or which
+// don't fit in max_samples, oldest first. It also drops the batches no
+// receiver is waiting for anymore.
+func (c *Component) trim(now time.Time) {
+	var (
+		pending = c.pending[:0]
+		samples = c.samples
+	)
+	for _, b := range c.pending {
+		switch {
+		case len(b.remaining) == 0:
+			samples -= b.samples
+		case now.Sub(b.created) > c.args.MaxAge:
+			c.dropped.WithLabelValues("expired").Add(float64(b.samples))
+			samples -= b.samples
+		case samples > c.args.MaxSamples:
+			c.dropped.WithLabelValues("full").Add(float64(b.samples))
+			samples -= b.samples
+		default:
+			pending = append(pending, b)
+		}
+	}
+	c.pending = pending
+	c.samples = samples
+	c.buffered.Set(float64(c.samples))
+}
+
+func (c *Component) hasPending(child storage.Appendable) bool {
+	for _, b := range c.pending {
+		if contains(b.remaining, child) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver appends b to child.
+func (c *Component) deliver(ctx context.Context, child storage.Appendable, b *batch) error {
+	app := child.Appender(ctx)
+	for _, r := range b.records {
+		if err := r.append(app); err != nil {
+			_ = app.Rollback()
+			return err
+		}
+	}
+	return app.Commit()
+}
+
+// appendMissing appends child to children if it's not already there.
+func appendMissing(children []storage.Appendable, child storage.Appendable) []storage.Appendable {
+	if contains(children, child) {
+		return children
+	}
+	return append(children, child)
+}
+
+func contains(children []storage.Appendable, child storage.Appendable) bool {
+	for _, c := range children {
+		if c == child {
+			return true
+		}
+	}
+	return false
+}
+
+// batch holds the records of a committed appender.
+type batch struct {
+	created time.Time
+	records []record
+	samples int // Number of samples and histograms of records.
+
+	// The receivers which didn't accept the batch yet.
+	remaining []storage.Appendable
+}
+
+type recordType int
+
+const (
+	recordSample recordType = iota
+	recordHistogram
+	recordExemplar
+	recordMetadata
+)
+
+// record is a single call to an appender.
+type record struct {
+	typ recordType
+	ref storage.SeriesRef
+	l   labels.Labels
+
+	t  int64
+	v  float64
+	h  *histogram.Histogram
+	fh *histogram.FloatHistogram
+	e  exemplar.Exemplar
+	m  metadata.Metadata
+}
+
+func (r *record) append(app storage.Appender) error {
+	var err error
+	switch r.typ {
+	case recordSample:
+		_, err = app.Append(r.ref, r.l, r.t, r.v)
+	case recordHistogram:
+		_, err = app.AppendHistogram(r.ref, r.l, r.t, r.h, r.fh)
+	case recordExemplar:
+		_, err = app.AppendExemplar(r.ref, r.l, r.e)
+	case recordMetadata:
+		_, err = app.UpdateMetadata(r.ref, r.l, r.m)
+	}
+	return err
+}
+
+// appender collects the records of a batch, which is forwarded when the
+// appender is committed.
+type appender struct {
+	c   *Component
+	ctx context.Context
+	b   batch
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+func (a *appender) add(r record) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+	if r.ref == 0 {
+		r.ref = storage.SeriesRef(a.c.ls.GetOrAddGlobalRefID(r.l))
+	}
+	if r.typ == recordSample || r.typ == recordHistogram {
+		a.b.samples++
+	}
+	a.b.records = append(a.b.records, r)
+	return r.ref, nil
+}
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	return a.add(record{typ: recordSample, ref: ref, l: l, t: t, v: v})
+}
+
+// AppendHistogram implements storage.Appender.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	// Histograms are copied since the caller may reuse them once they're
+	// appended.
+	if h != nil {
+		h = h.Copy()
+	}
+	if fh != nil {
+		fh = fh.Copy()
+	}
+	return a.add(record{typ: recordHistogram, ref: ref, l: l, t: t, h: h, fh: fh})
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return a.add(record{typ: recordExemplar, ref: ref, l: l, e: e})
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	return a.add(record{typ: recordMetadata, ref: ref, l: l, m: m})
+}
+
+// Commit implements storage.Appender. Batches which can't be forwarded yet
+// are buffered, so committing never fails unless the component exited.
+func (a *appender) Commit() error {
+	if a.c.exited.Load() {
+		return fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+	if len(a.b.records) == 0 {
+		return nil
+	}
+	b := a.b
+	b.created = time.Now()
+	a.b = batch{}
+	a.c.commit(a.ctx, &b)
+	return nil
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	a.b = batch{}
+	return nil
+}
@@ -0,0 +1,264 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`forward_to = []`), &args))
+	require.Equal(t, DefaultArguments.MaxSamples, args.MaxSamples)
+	require.Equal(t, DefaultArguments.MaxAge, args.MaxAge)
+
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	forward_to  = []
+	max_samples = 0
+`), &args), "max_samples must be greater than 0")
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	forward_to = []
+	max_age    = "0s"
+`), &args), "max_age must be greater than 0")
+}
+
+// TestReload ensures that the samples sent while a receiver is replaced by a
+// new one aren't lost.
+func TestReload(t *testing.T) {
+	ls := labelstore.New(nil)
+	oldSink, newSink := newFakeSink(ls), newFakeSink(ls)
+	c, _ := runBuffer(t, ls, Arguments{
+		ForwardTo:  []storage.Appendable{oldSink.interceptor},
+		MaxSamples: DefaultArguments.MaxSamples,
+		MaxAge:     DefaultArguments.MaxAge,
+	})
+
+	const total = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < total; i++ {
+			appendSample(c, i)
+			if i == total/4 {
+				// The old receiver is stopped before the configuration
+				// using the new one is applied.
+				oldSink.stop()
+			}
+			if i == total/2 {
+				_ = c.Update(Arguments{
+					ForwardTo:  []storage.Appendable{newSink.interceptor},
+					MaxSamples: DefaultArguments.MaxSamples,
+					MaxAge:     DefaultArguments.MaxAge,
+				})
+			}
+		}
+	}()
+	<-done
+
+	require.Eventually(t, func() bool {
+		return len(oldSink.values())+len(newSink.values()) == total
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// Every sample was received once, in order.
+	received := append(oldSink.values(), newSink.values()...)
+	for i, v := range received {
+		require.Equal(t, i, v)
+	}
+}
+
+// TestReplay ensures that batches are replayed to a receiver once it accepts
+// them again.
+func TestReplay(t *testing.T) {
+	ls := labelstore.New(nil)
+	sink := newFakeSink(ls)
+	c, reg := runBuffer(t, ls, Arguments{
+		ForwardTo:  []storage.Appendable{sink.interceptor},
+		MaxSamples: DefaultArguments.MaxSamples,
+		MaxAge:     DefaultArguments.MaxAge,
+	})
+
+	sink.stop()
+	for i := 0; i < 10; i++ {
+		appendSample(c, i)
+	}
+	require.Empty(t, sink.values())
+	require.Equal(t, 10.0, testutil.ToFloat64(c.buffered))
+
+	sink.start()
+	require.Eventually(t, func() bool {
+		return len(sink.values()) == 10
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, sink.values())
+	require.Equal(t, 0.0, testutil.ToFloat64(c.buffered))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP agent_prometheus_buffer_replayed_samples_total Total number of buffered samples replayed to a receiver.
+# TYPE agent_prometheus_buffer_replayed_samples_total counter
+agent_prometheus_buffer_replayed_samples_total 10
+`), "agent_prometheus_buffer_replayed_samples_total"))
+}
+
+// TestSlowReceiver ensures that the buffer isn't locked while a batch is
+// forwarded to a receiver.
+func TestSlowReceiver(t *testing.T) {
+	ls := labelstore.New(nil)
+	var (
+		blocked = make(chan struct{})
+		release = make(chan struct{})
+		once    sync.Once
+	)
+	slow := prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, _ labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		once.Do(func() { close(blocked) })
+		<-release
+		return ref, nil
+	}))
+	c, _ := runBuffer(t, ls, Arguments{
+		ForwardTo:  []storage.Appendable{slow},
+		MaxSamples: DefaultArguments.MaxSamples,
+		MaxAge:     DefaultArguments.MaxAge,
+	})
+
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		appendSample(c, 0)
+	}()
+	<-blocked
+
+	updated := make(chan struct{})
+	go func() {
+		defer close(updated)
+		_ = c.Update(Arguments{
+			ForwardTo:  []storage.Appendable{slow},
+			MaxSamples: DefaultArguments.MaxSamples,
+			MaxAge:     DefaultArguments.MaxAge,
+		})
+	}()
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the buffer is locked while forwarding a batch")
+	}
+
+	close(release)
+	<-committed
+}
+
+func TestBounds(t *testing.T) {
+	t.Run("max_samples", func(t *testing.T) {
+		ls := labelstore.New(nil)
+		sink := newFakeSink(ls)
+		sink.stop()
+		c, _ := runBuffer(t, ls, Arguments{
+			ForwardTo:  []storage.Appendable{sink.interceptor},
+			MaxSamples: 5,
+			MaxAge:     time.Hour,
+		})
+
+		for i := 0; i < 10; i++ {
+			appendSample(c, i)
+		}
+		require.Equal(t, 5.0, testutil.ToFloat64(c.dropped.WithLabelValues("full")))
+
+		// The oldest samples are dropped.
+		sink.start()
+		require.Eventually(t, func() bool {
+			return len(sink.values()) == 5
+		}, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, []int{5, 6, 7, 8, 9}, sink.values())
+	})
+
+	t.Run("max_age", func(t *testing.T) {
+		ls := labelstore.New(nil)
+		sink := newFakeSink(ls)
+		sink.stop()
+		c, _ := runBuffer(t, ls, Arguments{
+			ForwardTo:  []storage.Appendable{sink.interceptor},
+			MaxSamples: 100,
+			MaxAge:     100 * time.Millisecond,
+		})
+
+		for i := 0; i < 10; i++ {
+			appendSample(c, i)
+		}
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(c.dropped.WithLabelValues("expired")) == 10
+		}, 10*time.Second, 10*time.Millisecond)
+
+		sink.start()
+		appendSample(c, 10)
+		require.Equal(t, []int{10}, sink.values())
+	})
+}
+
+func runBuffer(t *testing.T, ls labelstore.LabelStore, args Arguments) (*Component, *prom.Registry) {
+	reg := prom.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "prometheus.buffer.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	go func() { _ = c.Run(componenttest.TestContext(t)) }()
+	return c, reg
+}
+
+// appendSample commits a batch holding a sample of value i.
+func appendSample(c *Component, i int) {
+	app := c.Appender(context.Background())
+	_, _ = app.Append(0, labels.FromStrings("__name__", "test", "batch", strconv.Itoa(i)), int64(i), float64(i))
+	_ = app.Commit()
+}
+
+// fakeSink records the values of the samples it receives, and rejects them
+// like an exited component when stopped.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+	stopped     atomic.Bool
+
+	mut      sync.Mutex
+	received []int
+}
+
+func newFakeSink(ls labelstore.LabelStore) *fakeSink {
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		if sink.stopped.Load() {
+			return 0, fmt.Errorf("sink has exited")
+		}
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, int(v))
+		return ref, nil
+	}))
+	return sink
+}
+
+func (s *fakeSink) stop()  { s.stopped.Store(true) }
+func (s *fakeSink) start() { s.stopped.Store(false) }
+
+func (s *fakeSink) values() []int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]int{}, s.received...)
+}
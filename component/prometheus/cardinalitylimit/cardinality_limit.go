@@ -0,0 +1,520 @@
+// Package cardinalitylimit provides the prometheus.cardinality_limit
+// component.
+package cardinalitylimit
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.cardinality_limit",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// prometheus.cardinality_limit component.
+type Arguments struct {
+	// Where the metrics should be forwarded to once their labels are limited.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+	// Limits by label.
+	Limits []Limit `river:"limit,block,optional"`
+	// How often the sample counts of the label values are halved, so that the
+	// values kept follow the recent volume and idle values expire.
+	DecayInterval time.Duration `river:"decay_interval,attr,optional"`
+	// How often the sums of the series folded into the other value are
+	// forwarded.
+	AggregationInterval time.Duration `river:"aggregation_interval,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for Arguments.
+var DefaultArguments = Arguments{
+	DecayInterval:       10 * time.Minute,
+	AggregationInterval: time.Minute,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.DecayInterval <= 0 {
+		return fmt.Errorf("decay_interval must be greater than 0")
+	}
+	if args.AggregationInterval <= 0 {
+		return fmt.Errorf("aggregation_interval must be greater than 0")
+	}
+	seen := make(map[string]struct{}, len(args.Limits))
+	for _, l := range args.Limits {
+		if _, ok := seen[l.Label]; ok {
+			return fmt.Errorf("duplicate limit for label %q", l.Label)
+		}
+		seen[l.Label] = struct{}{}
+	}
+	return nil
+}
+
+// Limit bounds the number of distinct values of a label.
+type Limit struct {
+	Label      string `river:"label,attr"`
+	MaxValues  int    `river:"max_values,attr"`
+	OtherValue string `river:"other_value,attr,optional"`
+}
+
+// DefaultLimit holds the default settings for Limit.
+var DefaultLimit = Limit{
+	OtherValue: "other",
+}
+
+// SetToDefault implements river.Defaulter.
+func (l *Limit) SetToDefault() {
+	*l = DefaultLimit
+}
+
+// Validate implements river.Validator.
+func (l *Limit) Validate() error {
+	if l.Label == "" {
+		return fmt.Errorf("label must not be empty")
+	}
+	if l.MaxValues <= 0 {
+		return fmt.Errorf("max_values must be greater than 0")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.cardinality_limit
+// component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.cardinality_limit component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+	updated  chan struct{}
+
+	keptValues    *prometheus_client.GaugeVec
+	foldedSamples *prometheus_client.CounterVec
+	folds         *folds
+
+	// mut guards the trackers, which have their own lock, so that samples with
+	// different limited labels don't contend with each other.
+	mut                 sync.RWMutex
+	decayInterval       time.Duration
+	aggregationInterval time.Duration
+	trackers            map[string]*tracker
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.cardinality_limit component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:     o,
+		updated:  make(chan struct{}, 1),
+		trackers: make(map[string]*tracker),
+		folds:    newFolds(),
+		keptValues: prometheus_client.NewGaugeVec(prometheus_client.GaugeOpts{
+			Name: "agent_prometheus_cardinality_limit_kept_values",
+			Help: "Number of values currently kept for each limited label.",
+		}, []string{"label"}),
+		foldedSamples: prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+			Name: "agent_prometheus_cardinality_limit_folded_samples_total",
+			Help: "Total number of samples whose limited label value was replaced by the other value because it isn't kept.",
+		}, []string{"label"}),
+	}
+	for _, m := range []prometheus_client.Collector{c.keptValues, c.foldedSamples} {
+		if err := o.Registerer.Register(m); err != nil {
+			return nil, err
+		}
+	}
+
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			folded, ok := c.limit(l, true)
+			if ok {
+				c.folds.Add(folded, l.Hash(), t, v, nil, time.Now())
+				return 0, nil
+			}
+			return next.Append(0, l, t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			// The exemplars of the folded series would be mixed up, since the
+			// samples forwarded are sums.
+			if _, ok := c.limit(l, false); ok {
+				return 0, nil
+			}
+			return next.AppendExemplar(0, l, e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			folded, _ := c.limit(l, false)
+			return next.UpdateMetadata(0, folded, m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			folded, ok := c.limit(l, true)
+			if ok {
+				// The histograms are kept until the next flush, so they're
+				// copied.
+				if h != nil {
+					fh = h.ToFloat()
+				} else {
+					fh = fh.Copy()
+				}
+				c.folds.Add(folded, l.Hash(), t, 0, fh, time.Now())
+				return 0, nil
+			}
+			return next.AppendHistogram(0, l, t, h, fh)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	c.mut.RLock()
+	decayTicker := time.NewTicker(c.decayInterval)
+	aggregationTicker := time.NewTicker(c.aggregationInterval)
+	c.mut.RUnlock()
+	defer decayTicker.Stop()
+	defer aggregationTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updated:
+			c.mut.RLock()
+			decayTicker.Reset(c.decayInterval)
+			aggregationTicker.Reset(c.aggregationInterval)
+			c.mut.RUnlock()
+		case <-decayTicker.C:
+			c.decay()
+		case <-aggregationTicker.C:
+			c.flush(ctx)
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+	c.decayInterval = newArgs.DecayInterval
+	c.aggregationInterval = newArgs.AggregationInterval
+
+	// The values kept for a label are only forgotten when its limit changes.
+	trackers := make(map[string]*tracker, len(newArgs.Limits))
+	for _, l := range newArgs.Limits {
+		if t, ok := c.trackers[l.Label]; ok && t.limit == l {
+			trackers[l.Label] = t
+			continue
+		}
+		trackers[l.Label] = newTracker(l)
+		c.keptValues.WithLabelValues(l.Label).Set(0)
+	}
+	for label := range c.trackers {
+		if _, ok := trackers[label]; !ok {
+			c.keptValues.DeleteLabelValues(label)
+			c.foldedSamples.DeleteLabelValues(label)
+		}
+	}
+	c.trackers = trackers
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// limit returns lbls with the values of the limited labels which aren't kept
+// replaced by their other value, and whether any was replaced. Samples are
+// counted towards the volume of the label values of the series if count is
+// true.
+func (c *Component) limit(lbls labels.Labels, count bool) (labels.Labels, bool) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	var b *labels.Builder
+	lbls.Range(func(l labels.Label) {
+		t, ok := c.trackers[l.Name]
+		if !ok {
+			return
+		}
+		kept, added := t.keep(l.Value, count)
+		if added {
+			c.keptValues.WithLabelValues(l.Name).Set(float64(t.keptValues()))
+		}
+		if kept {
+			return
+		}
+		if count {
+			c.foldedSamples.WithLabelValues(l.Name).Inc()
+		}
+		if b == nil {
+			b = labels.NewBuilder(lbls)
+		}
+		b.Set(l.Name, t.limit.OtherValue)
+	})
+	if b == nil {
+		return lbls, false
+	}
+	return b.Labels(), true
+}
+
+// flush forwards the sums of the series folded into the other value.
+func (c *Component) flush(ctx context.Context) {
+	c.mut.RLock()
+	expire := c.decayInterval
+	c.mut.RUnlock()
+
+	aggregates := c.folds.Flush(time.Now(), expire)
+	if len(aggregates) == 0 {
+		return
+	}
+	app := c.fanout.Appender(ctx)
+	for _, a := range aggregates {
+		var err error
+		if a.fh != nil {
+			_, err = app.AppendHistogram(0, a.labels, a.t, nil, a.fh)
+		} else {
+			_, err = app.Append(0, a.labels, a.t, a.v)
+		}
+		if err != nil {
+			level.Warn(c.opts.Logger).Log("msg", "failed to forward folded series", "series", a.labels, "err", err)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to forward folded series", "err", err)
+	}
+}
+
+// decay halves the sample counts of every label value.
+func (c *Component) decay() {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	for label, t := range c.trackers {
+		c.keptValues.WithLabelValues(label).Set(float64(t.decay()))
+	}
+}
+
+// tracker keeps the values of a label with the most samples, up to the limit
+// of distinct values.
+//
+// Besides the kept values, up to as many candidate values are tracked. A new
+// value takes the place of the candidate with the fewest samples, inheriting
+// its count like in the Space-Saving algorithm, so that the memory used by a
+// tracker is bounded however many distinct values the label has. A candidate
+// replaces the kept value with the fewest samples once it has more samples
+// than it.
+type tracker struct {
+	limit Limit
+
+	mut        sync.Mutex
+	values     map[string]*entry
+	kept       entryHeap
+	candidates entryHeap
+}
+
+// entry holds the recent sample count of a label value.
+type entry struct {
+	value string
+	count uint64
+	kept  bool
+	index int // Index of the entry in its heap.
+}
+
+func newTracker(l Limit) *tracker {
+	return &tracker{
+		limit:  l,
+		values: make(map[string]*entry, 2*l.MaxValues),
+	}
+}
+
+// keep reports whether the value v is kept, and whether it was added to the
+// kept values. If count is true, the sample is counted towards the volume of
+// v.
+func (t *tracker) keep(v string, count bool) (kept, added bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	e, ok := t.values[v]
+	if !count {
+		return ok && e.kept, false
+	}
+
+	switch {
+	case ok:
+		e.count++
+		if e.kept {
+			heap.Fix(&t.kept, e.index)
+			return true, false
+		}
+		heap.Fix(&t.candidates, e.index)
+
+	case len(t.kept) < t.limit.MaxValues:
+		e = &entry{value: v, count: 1, kept: true}
+		t.values[v] = e
+		heap.Push(&t.kept, e)
+		return true, true
+
+	case len(t.candidates) < t.limit.MaxValues:
+		e = &entry{value: v, count: 1}
+		t.values[v] = e
+		heap.Push(&t.candidates, e)
+
+	default:
+		e = t.candidates[0]
+		delete(t.values, e.value)
+		e.value = v
+		e.count++
+		t.values[v] = e
+		heap.Fix(&t.candidates, 0)
+	}
+
+	if len(t.kept) < t.limit.MaxValues {
+		// Kept values expired since the candidate was added.
+		heap.Remove(&t.candidates, e.index)
+		e.kept = true
+		heap.Push(&t.kept, e)
+		return true, true
+	}
+	if least := t.kept[0]; e.count > least.count {
+		// Swap the candidate with the kept value with the fewest samples.
+		least.kept, e.kept = false, true
+		t.kept[0], t.candidates[e.index] = e, least
+		least.index, e.index = e.index, 0
+		heap.Fix(&t.kept, 0)
+		heap.Fix(&t.candidates, least.index)
+		return true, false
+	}
+	return false, false
+}
+
+// keptValues returns the number of values kept.
+func (t *tracker) keptValues() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return len(t.kept)
+}
+
+// decay halves the sample counts and returns the number of values kept. The
+// values left without samples expire, freeing their place.
+func (t *tracker) decay() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.kept = t.decayHeap(t.kept)
+	t.candidates = t.decayHeap(t.candidates)
+	return len(t.kept)
+}
+
+func (t *tracker) decayHeap(h entryHeap) entryHeap {
+	res := h[:0]
+	for _, e := range h {
+		if e.count /= 2; e.count == 0 {
+			delete(t.values, e.value)
+			continue
+		}
+		e.index = len(res)
+		res = append(res, e)
+	}
+	for i := len(res); i < len(h); i++ {
+		h[i] = nil
+	}
+	heap.Init(&res)
+	return res
+}
+
+// entryHeap is a min-heap of entries by sample count.
+type entryHeap []*entry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
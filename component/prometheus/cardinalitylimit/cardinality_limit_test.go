@@ -0,0 +1,243 @@
+package cardinalitylimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to = []
+
+	limit {
+		label      = "path"
+		max_values = 100
+	}
+`), &args))
+	require.Equal(t, DefaultArguments.DecayInterval, args.DecayInterval)
+	require.Equal(t, DefaultArguments.AggregationInterval, args.AggregationInterval)
+	require.Equal(t, []Limit{{Label: "path", MaxValues: 100, OtherValue: "other"}}, args.Limits)
+
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	forward_to = []
+
+	limit {
+		label      = "path"
+		max_values = 0
+	}
+`), &args), "max_values must be greater than 0")
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	forward_to = []
+
+	limit {
+		label      = "path"
+		max_values = 1
+	}
+
+	limit {
+		label      = "path"
+		max_values = 2
+	}
+`), &args), `duplicate limit for label "path"`)
+}
+
+// TestLimit ensures that the values of a label beyond the limit are folded
+// into the other value while the values with the most samples are kept.
+func TestLimit(t *testing.T) {
+	c, sink := newTestComponent(t, Limit{Label: "path", MaxValues: 3, OtherValue: "other"})
+
+	// send reports whether the sample of path was forwarded as is.
+	send := func(path string) bool {
+		before := sink.count()
+		appendSample(t, c, labels.FromStrings("__name__", "requests_total", "path", path), 0, 1)
+		return sink.count() > before
+	}
+
+	// Rare values fill the limit first.
+	for i := 0; i < 5; i++ {
+		send(fmt.Sprintf("/rare/%d", i))
+	}
+	// Frequent values replace them once they have more samples.
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 10; j++ {
+			send(fmt.Sprintf("/frequent/%d", i))
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		require.True(t, send(fmt.Sprintf("/frequent/%d", i)))
+	}
+	for i := 0; i < 5; i++ {
+		require.False(t, send(fmt.Sprintf("/rare/%d", i)))
+	}
+	// Series which are kept are forwarded unchanged.
+	require.Equal(t, labels.FromStrings("__name__", "requests_total", "path", "/frequent/2"), sink.last().labels)
+
+	// The rare values, and the frequent values before they were kept, are
+	// folded into a single series, forwarded with the sum of their last
+	// samples.
+	before := sink.count()
+	c.flush(context.Background())
+	require.Equal(t, before+1, sink.count())
+	require.Equal(t, labels.FromStrings("__name__", "requests_total", "path", "other"), sink.last().labels)
+	require.GreaterOrEqual(t, sink.last().v, 5.0)
+
+	require.Equal(t, 3.0, testutil.ToFloat64(c.keptValues.WithLabelValues("path")))
+	require.Greater(t, testutil.ToFloat64(c.foldedSamples.WithLabelValues("path")), 5.0)
+
+	// Values without recent samples eventually expire.
+	for i := 0; i < 5; i++ {
+		c.decay()
+	}
+	require.Equal(t, 0.0, testutil.ToFloat64(c.keptValues.WithLabelValues("path")))
+	require.True(t, send("/rare/0"))
+}
+
+// TestFold ensures that the counters folded into the same series are summed
+// once per aggregation interval, and that they stop counting towards the sum
+// once they have no samples.
+func TestFold(t *testing.T) {
+	c, sink := newTestComponent(t, Limit{Label: "id", MaxValues: 1, OtherValue: "other"})
+
+	series := func(id string) labels.Labels {
+		return labels.FromStrings("__name__", "jobs_total", "id", id)
+	}
+	other := series("other")
+
+	// The value with the most samples is kept, and the others are folded.
+	for i := 0; i < 10; i++ {
+		appendSample(t, c, series("kept"), int64(i), float64(i))
+	}
+	appendSample(t, c, series("a"), 1000, 5)
+	appendSample(t, c, series("b"), 1000, 7)
+	// Only the last sample of a series counts.
+	appendSample(t, c, series("a"), 2000, 6)
+	require.Equal(t, 10, sink.count())
+
+	c.flush(context.Background())
+	require.Equal(t, sample{labels: other, t: 2000, v: 13}, sink.last())
+
+	// Nothing is forwarded without new samples.
+	c.flush(context.Background())
+	require.Equal(t, 11, sink.count())
+
+	appendSample(t, c, series("b"), 3000, 9)
+	c.flush(context.Background())
+	require.Equal(t, sample{labels: other, t: 3000, v: 15}, sink.last())
+
+	// Expired series stop counting towards the sum.
+	now := time.Now().Add(DefaultArguments.DecayInterval)
+	c.folds.Add(other, series("c").Hash(), 4000, 1, nil, now)
+	require.Equal(t, []aggregate{{labels: other, t: 4000, v: 1}}, c.folds.Flush(now, DefaultArguments.DecayInterval))
+}
+
+// TestTrackerBounded ensures that a tracker tracks at most twice as many
+// values as its limit, however many distinct values it sees, and that values
+// keep getting kept by volume.
+func TestTrackerBounded(t *testing.T) {
+	tr := newTracker(Limit{Label: "id", MaxValues: 10})
+
+	for i := 0; i < 10_000; i++ {
+		tr.keep(fmt.Sprintf("unique-%d", i), true)
+		// A few values are far more frequent than the others.
+		tr.keep(fmt.Sprintf("frequent-%d", i%5), true)
+		require.LessOrEqual(t, len(tr.values), 20)
+	}
+	require.Len(t, tr.kept, 10)
+	for i := 0; i < 5; i++ {
+		kept, _ := tr.keep(fmt.Sprintf("frequent-%d", i), false)
+		require.True(t, kept)
+	}
+
+	for i := 0; i < 64; i++ {
+		tr.decay()
+	}
+	require.Empty(t, tr.values)
+	require.Empty(t, tr.kept)
+	require.Empty(t, tr.candidates)
+}
+
+func newTestComponent(t *testing.T, limit Limit) (*Component, *fakeSink) {
+	t.Helper()
+
+	ls := labelstore.New(nil)
+	sink := newFakeSink(ls)
+	c, err := New(component.Options{
+		ID:            "prometheus.cardinality_limit.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, Arguments{
+		ForwardTo:           []storage.Appendable{sink.interceptor},
+		Limits:              []Limit{limit},
+		DecayInterval:       time.Hour,
+		AggregationInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	return c, sink
+}
+
+func appendSample(t *testing.T, c *Component, l labels.Labels, ts int64, v float64) {
+	t.Helper()
+
+	app := c.receiver.Appender(context.Background())
+	_, err := app.Append(0, l, ts, v)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+}
+
+// sample is a sample received by a fakeSink.
+type sample struct {
+	labels labels.Labels
+	t      int64
+	v      float64
+}
+
+// fakeSink records the samples it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []sample
+}
+
+func newFakeSink(ls labelstore.LabelStore) *fakeSink {
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, t int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, sample{labels: l, t: t, v: v})
+		return ref, nil
+	}))
+	return sink
+}
+
+func (s *fakeSink) count() int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return len(s.received)
+}
+
+func (s *fakeSink) last() sample {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.received[len(s.received)-1]
+}
@@ -0,0 +1,145 @@
+package cardinalitylimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// folds aggregates the samples of the series folded into the same series by
+// replacing the values of their limited labels with the other value.
+//
+// The samples of different series can't be forwarded as the samples of a
+// single series, which would see its value jump between theirs. Instead, the
+// last sample of every series folded into a series is kept, and their sum is
+// forwarded once every aggregation interval, so that a folded counter is the
+// sum of the counters folded into it.
+type folds struct {
+	mut    sync.Mutex
+	series map[uint64]*foldedSeries // By hash of the folded labels.
+}
+
+// foldedSeries holds the last samples of the series folded into a series.
+type foldedSeries struct {
+	labels  labels.Labels
+	sources map[uint64]*foldedSample // By hash of the original labels.
+	// Timestamp of the last sample forwarded for the series.
+	lastT int64
+}
+
+// foldedSample is the last sample of a series folded into another. fh is set
+// for histogram samples.
+type foldedSample struct {
+	t    int64
+	v    float64
+	fh   *histogram.FloatHistogram
+	seen time.Time
+}
+
+// aggregate is a sample to forward for a folded series.
+type aggregate struct {
+	labels labels.Labels
+	t      int64
+	v      float64
+	fh     *histogram.FloatHistogram
+}
+
+func newFolds() *folds {
+	return &folds{series: make(map[uint64]*foldedSeries)}
+}
+
+// Add records the sample of the series source, folded into the series
+// folded. fh must not be modified afterwards.
+func (f *folds) Add(folded labels.Labels, source uint64, t int64, v float64, fh *histogram.FloatHistogram, now time.Time) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	hash := folded.Hash()
+	s, ok := f.series[hash]
+	if !ok {
+		s = &foldedSeries{
+			labels:  folded,
+			sources: make(map[uint64]*foldedSample),
+			lastT:   -1 << 63,
+		}
+		f.series[hash] = s
+	}
+	if last, ok := s.sources[source]; ok && last.t > t {
+		return
+	}
+	s.sources[source] = &foldedSample{t: t, v: v, fh: fh, seen: now}
+}
+
+// Flush returns the samples to forward for the folded series which received
+// samples since the last flush. Each one is the sum of the last samples of
+// the series folded into it, at the timestamp of the most recent of them. The
+// series which had no samples for the expire duration stop counting towards
+// the sum.
+func (f *folds) Flush(now time.Time, expire time.Duration) []aggregate {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	var res []aggregate
+	for hash, s := range f.series {
+		for source, sample := range s.sources {
+			if now.Sub(sample.seen) >= expire {
+				delete(s.sources, source)
+			}
+		}
+		if len(s.sources) == 0 {
+			delete(f.series, hash)
+			continue
+		}
+
+		var (
+			t          = s.lastT
+			floats     int
+			sum        float64
+			histograms []*histogram.FloatHistogram
+		)
+		for _, sample := range s.sources {
+			if sample.t > t {
+				t = sample.t
+			}
+			if sample.fh != nil {
+				histograms = append(histograms, sample.fh)
+				continue
+			}
+			floats++
+			sum += sample.v
+		}
+		if t == s.lastT {
+			// No new samples since the last flush.
+			continue
+		}
+		s.lastT = t
+
+		if floats > 0 {
+			res = append(res, aggregate{labels: s.labels, t: t, v: sum})
+		}
+		if len(histograms) > 0 {
+			res = append(res, aggregate{labels: s.labels, t: t, fh: sumHistograms(histograms)})
+		}
+	}
+	return res
+}
+
+// sumHistograms returns the sum of hs. Histograms are added to the one with
+// the lowest resolution, which the others can be converted to.
+func sumHistograms(hs []*histogram.FloatHistogram) *histogram.FloatHistogram {
+	lowest := 0
+	for i, h := range hs {
+		if h.Schema < hs[lowest].Schema {
+			lowest = i
+		}
+	}
+	sum := hs[lowest].Copy()
+	for i, h := range hs {
+		if i != lowest {
+			sum.Add(h)
+		}
+	}
+	return sum.Compact(0)
+}
@@ -0,0 +1,271 @@
+// Package delta provides the prometheus.delta component.
+package delta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+	"golang.org/x/exp/slices"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.delta",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Supported values for the mode argument.
+const (
+	ModeDelta = "delta"
+	ModeRate  = "rate"
+)
+
+// Arguments holds values which are used to configure the prometheus.delta
+// component.
+type Arguments struct {
+	// Where the converted metrics should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// Whether counters are converted to the increase since their previous
+	// sample, or to their per-second rate of increase.
+	Mode string `river:"mode,attr,optional"`
+	// Suffixes of the names of the metrics handled as counters.
+	CounterSuffixes []string `river:"counter_suffixes,attr,optional"`
+	// How long the previous sample of a series is kept without receiving a new
+	// one.
+	SeriesTTL time.Duration `river:"series_ttl,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for Arguments.
+var DefaultArguments = Arguments{
+	Mode:            ModeDelta,
+	CounterSuffixes: []string{"_total", "_count", "_sum", "_bucket"},
+	SeriesTTL:       10 * time.Minute,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Mode != ModeDelta && args.Mode != ModeRate {
+		return fmt.Errorf("mode must be %q or %q, got %q", ModeDelta, ModeRate, args.Mode)
+	}
+	if args.SeriesTTL <= 0 {
+		return fmt.Errorf("series_ttl must be greater than 0")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.delta component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.delta component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	counterResets prometheus_client.Counter
+	trackedSeries prometheus_client.Gauge
+
+	mut  sync.Mutex
+	args Arguments
+	// Previous sample of every counter series, by hash of its labels.
+	previous map[uint64]*previousSample
+}
+
+// previousSample is the last sample received for a counter series, along with
+// when it was received.
+type previousSample struct {
+	t, seen int64
+	v       float64
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.delta component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:     o,
+		previous: make(map[uint64]*previousSample),
+	}
+	c.counterResets = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_delta_counter_resets_total",
+		Help: "Total number of counter resets detected",
+	})
+	c.trackedSeries = prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+		Name: "agent_prometheus_delta_tracked_series",
+		Help: "Number of counter series whose previous sample is kept",
+	})
+	for _, metric := range []prometheus_client.Collector{c.counterResets, c.trackedSeries} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			newV, ok := c.convert(l, t, v)
+			if !ok {
+				return 0, nil
+			}
+			return next.Append(0, l, t, newV)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.evictSeries(time.Now())
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	// The previous samples don't apply to the new settings if the converted
+	// counters change.
+	if newArgs.Mode != c.args.Mode || !slices.Equal(newArgs.CounterSuffixes, c.args.CounterSuffixes) {
+		c.previous = make(map[uint64]*previousSample)
+		c.trackedSeries.Set(0)
+	}
+	c.args = newArgs
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// convert returns the value to forward for the sample of lbls. Samples of
+// non-counter metrics and staleness markers are forwarded unchanged. It returns
+// false if the sample must be dropped, which is the case for the first sample
+// of a counter series since there is no previous value to compare it with.
+func (c *Component) convert(lbls labels.Labels, t int64, v float64) (float64, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if !c.isCounter(lbls.Get(labels.MetricName)) {
+		return v, true
+	}
+
+	hash := lbls.Hash()
+	if value.IsStaleNaN(v) {
+		c.forget(hash)
+		return v, true
+	}
+
+	prev, found := c.previous[hash]
+	if !found {
+		c.previous[hash] = &previousSample{t: t, v: v, seen: time.Now().UnixMilli()}
+		c.trackedSeries.Set(float64(len(c.previous)))
+		return 0, false
+	}
+	if t <= prev.t {
+		// Out of order or duplicate samples can't be converted.
+		return 0, false
+	}
+
+	delta := v - prev.v
+	if v < prev.v {
+		// The counter was reset and started again from 0.
+		c.counterResets.Inc()
+		delta = v
+	}
+	elapsed := float64(t-prev.t) / 1000
+	prev.t, prev.v, prev.seen = t, v, time.Now().UnixMilli()
+
+	if c.args.Mode == ModeRate {
+		return delta / elapsed, true
+	}
+	return delta, true
+}
+
+func (c *Component) isCounter(name string) bool {
+	for _, suffix := range c.args.CounterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Component) forget(hash uint64) {
+	delete(c.previous, hash)
+	c.trackedSeries.Set(float64(len(c.previous)))
+}
+
+// evictSeries forgets the previous samples of the series which didn't receive
+// a sample within the series TTL, such as series of targets which are gone
+// without being marked as stale.
+func (c *Component) evictSeries(now time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	cutoff := now.Add(-c.args.SeriesTTL).UnixMilli()
+	for hash, prev := range c.previous {
+		if prev.seen < cutoff {
+			c.forget(hash)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package delta
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to = []
+	mode       = "rate"
+`), &args))
+	require.Equal(t, ModeRate, args.Mode)
+	require.Equal(t, DefaultArguments.CounterSuffixes, args.CounterSuffixes)
+	require.Equal(t, 10*time.Minute, args.SeriesTTL)
+
+	require.EqualError(t, river.Unmarshal([]byte(`
+	forward_to = []
+	mode       = "gauge"
+`), &args), `mode must be "delta" or "rate", got "gauge"`)
+}
+
+// TestDelta ensures that counters are converted to their increase since their
+// previous sample, skipping their first sample and handling counter resets.
+func TestDelta(t *testing.T) {
+	c, sink := generateDelta(t, ModeDelta)
+
+	for i, v := range []float64{10, 15, 25, 3, 8} {
+		appendSample(t, c, "requests_total", int64(i)*15_000, v)
+	}
+	// Metrics which aren't counters are forwarded unchanged.
+	appendSample(t, c, "temperature_celsius", 0, 21.5)
+
+	require.Equal(t, []sample{
+		{"requests_total", 15_000, 5},
+		{"requests_total", 30_000, 10},
+		{"requests_total", 45_000, 3},
+		{"requests_total", 60_000, 5},
+		{"temperature_celsius", 0, 21.5},
+	}, sink.samples())
+	require.Equal(t, 1.0, testutil.ToFloat64(c.counterResets))
+}
+
+func TestRate(t *testing.T) {
+	c, sink := generateDelta(t, ModeRate)
+
+	for i, v := range []float64{10, 40, 6} {
+		appendSample(t, c, "requests_total", int64(i)*15_000, v)
+	}
+
+	require.Equal(t, []sample{
+		{"requests_total", 15_000, 2},
+		{"requests_total", 30_000, 0.4},
+	}, sink.samples())
+}
+
+// TestStaleness ensures that staleness markers are forwarded and that the
+// series starts over afterwards.
+func TestStaleness(t *testing.T) {
+	c, sink := generateDelta(t, ModeDelta)
+
+	appendSample(t, c, "requests_total", 0, 10)
+	appendSample(t, c, "requests_total", 15_000, math.Float64frombits(value.StaleNaN))
+	appendSample(t, c, "requests_total", 30_000, 20)
+	appendSample(t, c, "requests_total", 45_000, 25)
+
+	res := sink.samples()
+	require.Len(t, res, 2)
+	require.True(t, value.IsStaleNaN(res[0].V))
+	require.Equal(t, sample{"requests_total", 45_000, 5}, res[1])
+}
+
+func TestEvictSeries(t *testing.T) {
+	c, _ := generateDelta(t, ModeDelta)
+	appendSample(t, c, "requests_total", 0, 10)
+	require.Equal(t, 1.0, testutil.ToFloat64(c.trackedSeries))
+
+	c.evictSeries(time.Now())
+	require.Equal(t, 1.0, testutil.ToFloat64(c.trackedSeries))
+	c.evictSeries(time.Now().Add(time.Hour))
+	require.Equal(t, 0.0, testutil.ToFloat64(c.trackedSeries))
+}
+
+type sample struct {
+	Name string
+	T    int64
+	V    float64
+}
+
+// fakeSink records every sample it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []sample
+}
+
+func (s *fakeSink) samples() []sample {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]sample{}, s.received...)
+}
+
+func generateDelta(t *testing.T, mode string) (*Component, *fakeSink) {
+	ls := labelstore.New(nil)
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, t int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, sample{l.Get(labels.MetricName), t, v})
+		return ref, nil
+	}))
+
+	args := DefaultArguments
+	args.Mode = mode
+	args.ForwardTo = []storage.Appendable{sink.interceptor}
+	c, err := New(component.Options{
+		ID:            "prometheus.delta.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c, sink
+}
+
+func appendSample(t *testing.T, c *Component, name string, ts int64, v float64) {
+	app := c.receiver.Appender(context.Background())
+	_, err := app.Append(0, labels.FromStrings(labels.MetricName, name, "job", "app"), ts, v)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+}
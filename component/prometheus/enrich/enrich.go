@@ -0,0 +1,208 @@
+// Package enrich provides the prometheus.enrich component.
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.enrich",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.enrich
+// component.
+type Arguments struct {
+	// Where the enriched metrics should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// Label of the series whose value is looked up in the table.
+	KeyLabel string `river:"key_label,attr"`
+	// Labels to add to the series by value of the key label.
+	Table map[string]map[string]string `river:"table,attr"`
+	// Labels to add to the series whose key isn't in the table.
+	DefaultLabels map[string]string `river:"default_labels,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if !model.LabelName(args.KeyLabel).IsValid() {
+		return fmt.Errorf("key_label %q is not a valid label name", args.KeyLabel)
+	}
+	for key, lbls := range args.Table {
+		for name := range lbls {
+			if !model.LabelName(name).IsValid() {
+				return fmt.Errorf("invalid label name %q in the table entry for %q", name, key)
+			}
+		}
+	}
+	for name := range args.DefaultLabels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q in default_labels", name)
+		}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.enrich component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.enrich component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	metricsProcessed prometheus_client.Counter
+	lookupMisses     prometheus_client.Counter
+
+	mut  sync.RWMutex
+	args Arguments
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.enrich component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{opts: o}
+	c.metricsProcessed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_enrich_metrics_processed_total",
+		Help: "Total number of metrics processed",
+	})
+	c.lookupMisses = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_enrich_lookup_misses_total",
+		Help: "Total number of metrics whose key wasn't found in the table",
+	})
+	for _, metric := range []prometheus_client.Collector{c.metricsProcessed, c.lookupMisses} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			return next.Append(0, c.enrich(l, true), t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			return next.AppendExemplar(0, c.enrich(l, false), e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			return next.UpdateMetadata(0, c.enrich(l, false), m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			return next.AppendHistogram(0, c.enrich(l, true), t, h, fh)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component. The new table applies to the samples
+// received afterwards, so that it can be reloaded while samples flow through
+// the component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// enrich returns lbls with the labels of the table entry of its key label, or
+// the default labels if there is no such entry. Labels from the table replace
+// the labels of lbls with the same name. Misses are only counted if count is
+// true, so that they're counted once per sample.
+func (c *Component) enrich(lbls labels.Labels, count bool) labels.Labels {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	add, found := c.args.Table[lbls.Get(c.args.KeyLabel)]
+	if !found {
+		if count {
+			c.lookupMisses.Inc()
+		}
+		add = c.args.DefaultLabels
+	}
+	if len(add) == 0 {
+		return lbls
+	}
+
+	lb := labels.NewBuilder(lbls)
+	for name, value := range add {
+		lb.Set(name, value)
+	}
+	return lb.Labels()
+}
@@ -0,0 +1,165 @@
+package enrich
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var exampleRiverConfig = `
+	forward_to = []
+	key_label  = "instance"
+	table      = json_decode("{\"db-1:9100\": {\"team\": \"storage\"}}")
+
+	default_labels = {
+		team = "unknown",
+	}
+`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(exampleRiverConfig), &args))
+	require.Equal(t, "instance", args.KeyLabel)
+	require.Equal(t, map[string]map[string]string{"db-1:9100": {"team": "storage"}}, args.Table)
+	require.Equal(t, map[string]string{"team": "unknown"}, args.DefaultLabels)
+}
+
+func TestBadRiverConfig(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"invalid key label", `forward_to = []
+key_label = "the-instance"
+table = {}`, `key_label "the-instance" is not a valid label name`},
+		{"invalid table label", `forward_to = []
+key_label = "instance"
+table = { "db-1:9100" = { "the-team" = "storage" } }`, `invalid label name "the-team" in the table entry for "db-1:9100"`},
+		{"invalid default label", `forward_to = []
+key_label = "instance"
+table = {}
+default_labels = { "the-team" = "unknown" }`, `invalid label name "the-team" in default_labels`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(tc.config), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	c, sink := generateEnrich(t, Arguments{
+		KeyLabel: "instance",
+		Table: map[string]map[string]string{
+			"db-1:9100":  {"team": "storage", "tier": "1"},
+			"web-1:9100": {"team": "frontend"},
+		},
+	})
+
+	appendSeries(t, c, "db-1:9100", "web-1:9100", "cache-1:9100")
+
+	require.Equal(t, []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "instance", "db-1:9100", "team", "storage", "tier", "1"),
+		labels.FromStrings(labels.MetricName, "up", "instance", "web-1:9100", "team", "frontend"),
+		labels.FromStrings(labels.MetricName, "up", "instance", "cache-1:9100"),
+	}, sink.series())
+	require.Equal(t, 3.0, testutil.ToFloat64(c.metricsProcessed))
+	require.Equal(t, 1.0, testutil.ToFloat64(c.lookupMisses))
+}
+
+func TestDefaultLabels(t *testing.T) {
+	c, sink := generateEnrich(t, Arguments{
+		KeyLabel:      "instance",
+		Table:         map[string]map[string]string{"db-1:9100": {"team": "storage"}},
+		DefaultLabels: map[string]string{"team": "unknown"},
+	})
+
+	appendSeries(t, c, "db-1:9100", "cache-1:9100")
+
+	require.Equal(t, []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "instance", "db-1:9100", "team", "storage"),
+		labels.FromStrings(labels.MetricName, "up", "instance", "cache-1:9100", "team", "unknown"),
+	}, sink.series())
+}
+
+// TestReloadTable ensures that a new table applies to the samples received
+// after the update.
+func TestReloadTable(t *testing.T) {
+	c, sink := generateEnrich(t, Arguments{
+		KeyLabel: "instance",
+		Table:    map[string]map[string]string{"db-1:9100": {"team": "storage"}},
+	})
+	appendSeries(t, c, "db-1:9100")
+
+	require.NoError(t, c.Update(Arguments{
+		ForwardTo: []storage.Appendable{sink.interceptor},
+		KeyLabel:  "instance",
+		Table:     map[string]map[string]string{"db-1:9100": {"team": "databases"}},
+	}))
+	appendSeries(t, c, "db-1:9100")
+
+	require.Equal(t, []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "instance", "db-1:9100", "team", "storage"),
+		labels.FromStrings(labels.MetricName, "up", "instance", "db-1:9100", "team", "databases"),
+	}, sink.series())
+}
+
+// fakeSink records the labels of every sample it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []labels.Labels
+}
+
+func (s *fakeSink) series() []labels.Labels {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]labels.Labels{}, s.received...)
+}
+
+func generateEnrich(t *testing.T, args Arguments) (*Component, *fakeSink) {
+	ls := labelstore.New(nil)
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, l)
+		return ref, nil
+	}))
+
+	args.ForwardTo = []storage.Appendable{sink.interceptor}
+	c, err := New(component.Options{
+		ID:            "prometheus.enrich.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c, sink
+}
+
+func appendSeries(t *testing.T, c *Component, instances ...string) {
+	app := c.receiver.Appender(context.Background())
+	for _, instance := range instances {
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, "up", "instance", instance), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
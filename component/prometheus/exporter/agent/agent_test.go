@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/component/prometheus/scrape"
+	"github.com/grafana/agent/pkg/build"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/cluster"
+	http_service "github.com/grafana/agent/service/http"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildInfo ensures that the agent_build_info series registered by the
+// agent binaries is exposed by prometheus.exporter.agent, so that scraping
+// the agent reports its version.
+func TestBuildInfo(t *testing.T) {
+	// The binaries register the build information collector to the default
+	// registry, which is what prometheus.exporter.agent exposes.
+	var are prometheus_client.AlreadyRegisteredError
+	if err := prometheus_client.Register(build.NewCollector("agent")); err != nil && !errors.As(err, &are) {
+		require.NoError(t, err)
+	}
+
+	opts := component.Options{
+		ID:         "prometheus.exporter.agent.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+	}
+	integration, _, err := createExporter(opts, Arguments{}, "agent")
+	require.NoError(t, err)
+	handler, err := integration.MetricsHandler()
+	require.NoError(t, err)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	buildInfo := make(chan labels.Labels, 1)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		if l.Get(labels.MetricName) == "agent_build_info" {
+			select {
+			case buildInfo <- l:
+			default:
+			}
+		}
+		return ref, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := scrape.New(scrapeOptions(t), scrape.Arguments{
+		Targets:             []discovery.Target{{"__address__": strings.TrimPrefix(srv.URL, "http://")}},
+		ForwardTo:           []storage.Appendable{sink},
+		MetricsPath:         "/metrics",
+		Scheme:              "http",
+		HonorTimestamps:     true,
+		ScrapeInterval:      100 * time.Millisecond,
+		ScrapeTimeout:       85 * time.Millisecond,
+		TimestampSkewAction: scrape.SkewActionWarn,
+	})
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	select {
+	case l := <-buildInfo:
+		for _, name := range []string{"version", "revision", "branch", "goversion"} {
+			require.True(t, l.Has(name), "agent_build_info has no %s label: %s", name, l)
+		}
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "agent_build_info was never scraped")
+	}
+}
+
+func scrapeOptions(t *testing.T) component.Options {
+	return component.Options{
+		ID:         "prometheus.scrape.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			switch name {
+			case http_service.ServiceName:
+				return http_service.Data{
+					HTTPListenAddr:   "localhost:12345",
+					MemoryListenAddr: "agent.internal:1245",
+					BaseHTTPPath:     "/",
+					DialFunc:         (&net.Dialer{}).DialContext,
+				}, nil
+			case cluster.ServiceName:
+				return cluster.Mock(), nil
+			case labelstore.ServiceName:
+				return labelstore.New(nil), nil
+			default:
+				return nil, fmt.Errorf("service %q does not exist", name)
+			}
+		},
+	}
+}
@@ -1,10 +1,20 @@
 package statsd
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/river"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/stretchr/testify/require"
 )
 
@@ -91,3 +101,152 @@ func TestConvert(t *testing.T) {
 		require.Nil(t, configStatsd.MappingConfig)
 	})
 }
+
+const testMappingConfig = `
+mappings:
+- match: "app.*.requests"
+  name: "app_requests_total"
+  labels:
+    service: "$1"
+- match: "app.*.in_flight"
+  name: "app_in_flight_requests"
+  labels:
+    service: "$1"
+- match: "app.*.*.latency"
+  observer_type: histogram
+  histogram_options:
+    buckets: [0.1, 0.5, 1]
+  name: "app_request_duration_seconds"
+  labels:
+    service: "$1"
+    endpoint: "$2"
+- match: "debug.*"
+  action: drop
+  name: "dropped"
+`
+
+func TestMapping(t *testing.T) {
+	mappingPath := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(mappingPath, []byte(testMappingConfig), 0644))
+
+	udpAddr, tcpAddr := freeAddr(t, "udp"), freeAddr(t, "tcp")
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		listen_udp           = %q
+		listen_tcp           = %q
+		mapping_config_path  = %q
+		event_flush_interval = "10ms"
+	`, udpAddr, tcpAddr, mappingPath)), &args))
+
+	integration, _, err := createExporter(component.Options{Logger: util.TestFlowLogger(t)}, args, "statsd")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = integration.Run(ctx) }()
+
+	handler, err := integration.MetricsHandler()
+	require.NoError(t, err)
+	gather := func() map[string]*dto.MetricFamily {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		families, err := new(expfmt.TextParser).TextToMetricFamilies(rec.Body)
+		require.NoError(t, err)
+		return families
+	}
+
+	// The listeners are started asynchronously.
+	var tcpConn net.Conn
+	require.Eventually(t, func() bool {
+		tcpConn, err = net.Dial("tcp", tcpAddr)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer tcpConn.Close()
+
+	_, err = fmt.Fprint(tcpConn, "app.users.requests:3|c\napp.users.in_flight:7|g\n")
+	require.NoError(t, err)
+
+	udpConn, err := net.Dial("udp", udpAddr)
+	require.NoError(t, err)
+	defer udpConn.Close()
+	for _, line := range []string{
+		"app.users.get.latency:320|ms",
+		"app.users.requests:2|c",
+		"debug.cache_hits:1|c",
+		"unmapped.metric:1|c",
+		"malformed",
+	} {
+		_, err = udpConn.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	var families map[string]*dto.MetricFamily
+	require.Eventually(t, func() bool {
+		families = gather()
+		requests := families["app_requests_total"].GetMetric()
+		return len(requests) > 0 && requests[0].GetCounter().GetValue() == 5 &&
+			families["statsd_exporter_sample_errors_total"] != nil &&
+			families["app_request_duration_seconds"] != nil &&
+			families["unmapped_metric"] != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	requireLabels(t, map[string]string{"service": "users"}, families["app_requests_total"].GetMetric()[0])
+
+	inFlight := families["app_in_flight_requests"].GetMetric()[0]
+	requireLabels(t, map[string]string{"service": "users"}, inFlight)
+	require.Equal(t, 7.0, inFlight.GetGauge().GetValue())
+
+	// Timers are converted from milliseconds to seconds.
+	latency := families["app_request_duration_seconds"].GetMetric()[0]
+	requireLabels(t, map[string]string{"service": "users", "endpoint": "get"}, latency)
+	require.Equal(t, uint64(1), latency.GetHistogram().GetSampleCount())
+	require.InDelta(t, 0.32, latency.GetHistogram().GetSampleSum(), 1e-9)
+
+	// Dropped, unmapped and malformed lines are counted.
+	require.NotContains(t, families, "debug_cache_hits")
+	require.Equal(t, 1.0, counterValue(families["statsd_exporter_events_actions_total"], "action", "drop"))
+	require.Equal(t, 1.0, counterValue(families["statsd_exporter_events_unmapped_total"], "", ""))
+	require.Equal(t, 1.0, counterValue(families["statsd_exporter_sample_errors_total"], "reason", "malformed_line"))
+}
+
+func freeAddr(t *testing.T, network string) string {
+	var addr string
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr = conn.LocalAddr().String()
+		require.NoError(t, conn.Close())
+	default:
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr = lis.Addr().String()
+		require.NoError(t, lis.Close())
+	}
+	return addr
+}
+
+func requireLabels(t *testing.T, expect map[string]string, m *dto.Metric) {
+	actual := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		actual[l.GetName()] = l.GetValue()
+	}
+	require.Equal(t, expect, actual)
+}
+
+// counterValue returns the value of the counter of mf whose label name has
+// the given value, or of its only counter if name is empty.
+func counterValue(mf *dto.MetricFamily, name, value string) float64 {
+	for _, m := range mf.GetMetric() {
+		if name == "" {
+			return m.GetCounter().GetValue()
+		}
+		for _, l := range m.GetLabel() {
+			if l.GetName() == name && l.GetValue() == value {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
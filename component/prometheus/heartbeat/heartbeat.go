@@ -0,0 +1,201 @@
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.heartbeat",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Supported values for the type argument.
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+// Arguments holds values which are used to configure the prometheus.heartbeat
+// component.
+type Arguments struct {
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// How often to emit the heartbeat sample.
+	Interval time.Duration `river:"interval,attr,optional"`
+	// Name of the emitted metric.
+	MetricName string `river:"metric_name,attr,optional"`
+	// Whether the heartbeat is a gauge which is always 1 or a counter which
+	// counts emitted heartbeats.
+	Type string `river:"type,attr,optional"`
+	// Value of the instance label. Defaults to the hostname.
+	Instance string `river:"instance,attr,optional"`
+	// Extra labels to attach to the heartbeat series.
+	Labels map[string]string `river:"labels,attr,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	Interval:   15 * time.Second,
+	MetricName: "agent_heartbeat",
+	Type:       TypeGauge,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+	if !model.IsValidMetricName(model.LabelValue(args.MetricName)) {
+		return fmt.Errorf("metric_name %q is not a valid metric name", args.MetricName)
+	}
+	switch args.Type {
+	case TypeGauge, TypeCounter:
+	default:
+		return fmt.Errorf("type must be one of %q or %q, got %q", TypeGauge, TypeCounter, args.Type)
+	}
+	for name := range args.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("label name %q is not valid", name)
+		}
+	}
+	return nil
+}
+
+// Component implements the prometheus.heartbeat component.
+type Component struct {
+	opts   component.Options
+	fanout *prometheus.Fanout
+
+	updated chan struct{}
+
+	mut   sync.RWMutex
+	args  Arguments
+	lbls  labels.Labels
+	count float64
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.heartbeat component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    o,
+		fanout:  prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+		updated: make(chan struct{}, 1),
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	c.mut.RLock()
+	ticker := time.NewTicker(c.args.Interval)
+	c.mut.RUnlock()
+	defer ticker.Stop()
+
+	c.beat(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updated:
+			c.mut.RLock()
+			ticker.Reset(c.args.Interval)
+			c.mut.RUnlock()
+		case <-ticker.C:
+			c.beat(ctx)
+		}
+	}
+}
+
+// beat sends a single heartbeat sample to the downstream receivers.
+func (c *Component) beat(ctx context.Context) {
+	c.mut.Lock()
+	c.count++
+	value := 1.0
+	if c.args.Type == TypeCounter {
+		value = c.count
+	}
+	lbls := c.lbls
+	c.mut.Unlock()
+
+	app := c.fanout.Appender(ctx)
+	if _, err := app.Append(0, lbls, time.Now().UnixMilli(), value); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to append heartbeat", "err", err)
+		_ = app.Rollback()
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to commit heartbeat", "err", err)
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	instance := newArgs.Instance
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to determine hostname for instance label: %w", err)
+		}
+		instance = hostname
+	}
+
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	for name, value := range newArgs.Labels {
+		lb.Set(name, value)
+	}
+	lb.Set(model.InstanceLabel, instance)
+	lb.Set(model.MetricNameLabel, newArgs.MetricName)
+
+	c.mut.Lock()
+	c.args = newArgs
+	c.lbls = lb.Labels()
+	c.mut.Unlock()
+
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
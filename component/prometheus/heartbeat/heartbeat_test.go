@@ -0,0 +1,158 @@
+package heartbeat
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var exampleRiverConfig = `
+	forward_to  = []
+	interval    = "30s"
+	metric_name = "my_heartbeat"
+	type        = "counter"
+	instance    = "agent-1"
+	labels      = { "cluster" = "prod" }
+`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(exampleRiverConfig), &args))
+	require.Equal(t, 30*time.Second, args.Interval)
+	require.Equal(t, "my_heartbeat", args.MetricName)
+	require.Equal(t, TypeCounter, args.Type)
+}
+
+func TestBadRiverConfig(t *testing.T) {
+	tt := []struct {
+		name   string
+		cfg    string
+		errMsg string
+	}{
+		{
+			name: "bad type",
+			cfg: `
+				forward_to = []
+				type = "histogram"`,
+			errMsg: "type must be one of",
+		},
+		{
+			name: "bad metric name",
+			cfg: `
+				forward_to = []
+				metric_name = "agent-heartbeat"`,
+			errMsg: "is not a valid metric name",
+		},
+		{
+			name: "bad interval",
+			cfg: `
+				forward_to = []
+				interval = "0s"`,
+			errMsg: "interval must be greater than 0",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			require.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	var (
+		mut      sync.Mutex
+		received []sample
+	)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, ts int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		mut.Lock()
+		defer mut.Unlock()
+		received = append(received, sample{labels: l, ts: ts, value: v})
+		return ref, nil
+	}))
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		forward_to = []
+		interval   = "50ms"
+		type       = "counter"
+		instance   = "agent-1"
+		labels     = { "cluster" = "prod" }
+	`), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+
+	ctx, cancel := context.WithCancel(componenttest.TestContext(t))
+	defer cancel()
+
+	tc, err := componenttest.NewControllerFromID(nil, "prometheus.heartbeat")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, tc.Run(ctx, args))
+	}()
+	require.NoError(t, tc.WaitRunning(time.Second))
+
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(received) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	mut.Lock()
+	defer mut.Unlock()
+
+	expectLabels := labels.FromStrings(
+		"__name__", "agent_heartbeat",
+		"cluster", "prod",
+		"instance", "agent-1",
+	)
+	for i, s := range received[:3] {
+		require.Equal(t, expectLabels, s.labels)
+		require.Equal(t, float64(i+1), s.value)
+		if i > 0 {
+			// Heartbeats are emitted on the configured cadence; allow for
+			// scheduling delays in slow test environments.
+			require.GreaterOrEqual(t, s.ts-received[i-1].ts, int64(40))
+		}
+	}
+}
+
+func TestHeartbeatDefaultInstance(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`forward_to = []`), &args))
+
+	c, err := New(component.Options{
+		ID:         "prometheus.heartbeat.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, args)
+	require.NoError(t, err)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	require.Equal(t, hostname, c.lbls.Get("instance"))
+	require.Equal(t, "agent_heartbeat", c.lbls.Get("__name__"))
+}
+
+type sample struct {
+	labels labels.Labels
+	ts     int64
+	value  float64
+}
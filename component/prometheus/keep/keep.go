@@ -0,0 +1,257 @@
+package keep
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/regexp"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.keep",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.keep
+// component.
+type Arguments struct {
+	// Where the filtered metrics should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// Regular expressions matched against the metric name. When set, only
+	// metrics matching at least one expression are forwarded.
+	Allowlist []string `river:"allowlist,attr,optional"`
+	// Regular expressions matched against the metric name. Metrics matching
+	// at least one expression are dropped, even if they are allowlisted.
+	Denylist []string `river:"denylist,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if _, err := compileAll(args.Allowlist); err != nil {
+		return fmt.Errorf("invalid allowlist: %w", err)
+	}
+	if _, err := compileAll(args.Denylist); err != nil {
+		return fmt.Errorf("invalid denylist: %w", err)
+	}
+	return nil
+}
+
+// compileAll compiles each expression in exprs. Expressions are anchored on
+// both ends to match the behavior of relabel rules.
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Exports holds values which are exported by the prometheus.keep component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.keep component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	metricsProcessed prometheus_client.Counter
+	metricsDropped   prometheus_client.Counter
+
+	mut       sync.RWMutex
+	allowlist []*regexp.Regexp
+	denylist  []*regexp.Regexp
+
+	// cache holds the filtering decision for each metric name seen since
+	// the last update.
+	cacheMut sync.RWMutex
+	cache    map[string]bool
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.keep component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:  o,
+		cache: make(map[string]bool),
+	}
+	c.metricsProcessed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_keep_metrics_processed_total",
+		Help: "Total number of metrics processed",
+	})
+	c.metricsDropped = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_keep_metrics_dropped_total",
+		Help: "Total number of metrics dropped by the allowlist or denylist",
+	})
+	for _, metric := range []prometheus_client.Collector{c.metricsProcessed, c.metricsDropped} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			if !c.keep(l) {
+				c.metricsDropped.Inc()
+				return 0, nil
+			}
+			return next.Append(0, l, t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			if !c.keep(l) {
+				return 0, nil
+			}
+			return next.AppendExemplar(0, l, e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			if !c.keep(l) {
+				return 0, nil
+			}
+			return next.UpdateMetadata(0, l, m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			if !c.keep(l) {
+				c.metricsDropped.Inc()
+				return 0, nil
+			}
+			return next.AppendHistogram(0, l, t, h, fh)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	allowlist, err := compileAll(newArgs.Allowlist)
+	if err != nil {
+		return err
+	}
+	denylist, err := compileAll(newArgs.Denylist)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.allowlist = allowlist
+	c.denylist = denylist
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	c.cacheMut.Lock()
+	c.cache = make(map[string]bool)
+	c.cacheMut.Unlock()
+
+	return nil
+}
+
+// keep reports whether the metric identified by lbls should be forwarded.
+func (c *Component) keep(lbls labels.Labels) bool {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	name := lbls.Get(labels.MetricName)
+
+	c.cacheMut.RLock()
+	result, found := c.cache[name]
+	c.cacheMut.RUnlock()
+	if found {
+		return result
+	}
+
+	result = matchesAny(c.allowlist, name, true) && !matchesAny(c.denylist, name, false)
+
+	c.cacheMut.Lock()
+	c.cache[name] = result
+	c.cacheMut.Unlock()
+	return result
+}
+
+// matchesAny reports whether name matches any of the expressions in res. If
+// res is empty, matchesAny returns ifEmpty.
+func matchesAny(res []*regexp.Regexp, name string, ifEmpty bool) bool {
+	if len(res) == 0 {
+		return ifEmpty
+	}
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package keep
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var exampleRiverConfig = `
+	forward_to = []
+	allowlist  = ["agent_.*", "up"]
+	denylist   = ["go_.*"]
+`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(exampleRiverConfig), &args))
+	require.Equal(t, []string{"agent_.*", "up"}, args.Allowlist)
+	require.Equal(t, []string{"go_.*"}, args.Denylist)
+}
+
+func TestBadRiverConfig(t *testing.T) {
+	var exampleRiverConfig = `
+	forward_to = []
+	denylist   = ["go_(.*"]
+`
+	var args Arguments
+	err := river.Unmarshal([]byte(exampleRiverConfig), &args)
+	require.ErrorContains(t, err, "invalid denylist")
+}
+
+func TestDenylist(t *testing.T) {
+	c, sink, reg := generateKeep(t, Arguments{
+		Denylist: []string{"go_.*"},
+	})
+
+	appendSeries(t, c,
+		"go_goroutines",
+		"go_memstats_alloc_bytes",
+		"agent_build_info",
+		"up",
+	)
+
+	require.ElementsMatch(t, []string{"agent_build_info", "up"}, sink.names())
+	require.Equal(t, 4.0, testutil.ToFloat64(c.metricsProcessed))
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metricsDropped))
+
+	count, err := testutil.GatherAndCount(reg, "agent_prometheus_keep_metrics_dropped_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestAllowlist(t *testing.T) {
+	c, sink, _ := generateKeep(t, Arguments{
+		Allowlist: []string{"agent_.*", "go_.*"},
+		Denylist:  []string{"go_memstats_.*"},
+	})
+
+	appendSeries(t, c,
+		"go_goroutines",
+		"go_memstats_alloc_bytes",
+		"agent_build_info",
+		"up",
+	)
+
+	require.ElementsMatch(t, []string{"go_goroutines", "agent_build_info"}, sink.names())
+}
+
+func TestAnchoredExpressions(t *testing.T) {
+	c, sink, _ := generateKeep(t, Arguments{
+		Denylist: []string{"go"},
+	})
+
+	appendSeries(t, c, "go", "go_goroutines", "cargo")
+
+	require.ElementsMatch(t, []string{"go_goroutines", "cargo"}, sink.names())
+}
+
+func TestUpdateResetsCache(t *testing.T) {
+	c, sink, _ := generateKeep(t, Arguments{
+		Denylist: []string{"go_.*"},
+	})
+	appendSeries(t, c, "go_goroutines")
+	require.Empty(t, sink.names())
+
+	require.NoError(t, c.Update(Arguments{
+		ForwardTo: []storage.Appendable{sink.interceptor},
+	}))
+	appendSeries(t, c, "go_goroutines")
+	require.Equal(t, []string{"go_goroutines"}, sink.names())
+}
+
+// fakeSink records the metric names of every sample it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []string
+}
+
+func (s *fakeSink) names() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.received...)
+}
+
+func generateKeep(t *testing.T, args Arguments) (*Component, *fakeSink, *prom.Registry) {
+	ls := labelstore.New(nil)
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, l.Get(labels.MetricName))
+		return ref, nil
+	}))
+
+	reg := prom.NewRegistry()
+	args.ForwardTo = []storage.Appendable{sink.interceptor}
+	c, err := New(component.Options{
+		ID:            "prometheus.keep.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c, sink, reg
+}
+
+func appendSeries(t *testing.T, c *Component, names ...string) {
+	app := c.receiver.Appender(context.Background())
+	for _, name := range names {
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, name, "job", "agent"), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
@@ -0,0 +1,341 @@
+package normalize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.normalize",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Validation schemes of metric and label names.
+const (
+	// SchemeLegacy only allows the characters of the legacy Prometheus
+	// names. Invalid characters are replaced with underscores.
+	SchemeLegacy = "legacy"
+	// SchemeUTF8 allows any UTF-8 name.
+	SchemeUTF8 = "utf8"
+)
+
+// Arguments holds values which are used to configure the prometheus.normalize
+// component.
+type Arguments struct {
+	// Where the normalized metrics should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// ValidationScheme is the set of rules metric and label names follow.
+	ValidationScheme string `river:"validation_scheme,attr,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	ValidationScheme: SchemeLegacy,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	switch args.ValidationScheme {
+	case SchemeLegacy, SchemeUTF8:
+		return nil
+	default:
+		return fmt.Errorf("validation_scheme must be %q or %q, got %q", SchemeLegacy, SchemeUTF8, args.ValidationScheme)
+	}
+}
+
+// Exports holds values which are exported by the prometheus.normalize
+// component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.normalize component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	metricsProcessed prometheus_client.Counter
+	metricsSanitized prometheus_client.Counter
+	metricsDropped   prometheus_client.Counter
+
+	mut    sync.RWMutex
+	scheme string
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.normalize component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{opts: o}
+	c.metricsProcessed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_normalize_metrics_processed_total",
+		Help: "Total number of metrics processed",
+	})
+	c.metricsSanitized = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_normalize_metrics_sanitized_total",
+		Help: "Total number of metrics whose names were sanitized",
+	})
+	c.metricsDropped = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_normalize_metrics_dropped_total",
+		Help: "Total number of metrics dropped because of invalid names",
+	})
+	for _, metric := range []prometheus_client.Collector{c.metricsProcessed, c.metricsSanitized, c.metricsDropped} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			newLbls, ok := c.normalize(l, true)
+			if !ok {
+				return 0, nil
+			}
+			return next.Append(0, newLbls, t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			newLbls, ok := c.normalize(l, false)
+			if !ok {
+				return 0, nil
+			}
+			return next.AppendExemplar(0, newLbls, e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			newLbls, ok := c.normalize(l, false)
+			if !ok {
+				return 0, nil
+			}
+			return next.UpdateMetadata(0, newLbls, m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			newLbls, ok := c.normalize(l, true)
+			if !ok {
+				return 0, nil
+			}
+			return next.AppendHistogram(0, newLbls, t, h, fh)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.scheme = newArgs.ValidationScheme
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// normalize returns lbls with names following the validation scheme. It
+// returns false if the series must be dropped because its names can't be
+// made valid. Samples are counted when count is true.
+func (c *Component) normalize(lbls labels.Labels, count bool) (labels.Labels, bool) {
+	c.mut.RLock()
+	scheme := c.scheme
+	c.mut.RUnlock()
+
+	var (
+		newLbls labels.Labels
+		ok      bool
+	)
+	if scheme == SchemeUTF8 {
+		newLbls, ok = lbls, validUTF8(lbls)
+	} else {
+		newLbls, ok = sanitizeLegacy(lbls)
+	}
+
+	if count {
+		switch {
+		case !ok:
+			c.metricsDropped.Inc()
+		case !labels.Equal(lbls, newLbls):
+			c.metricsSanitized.Inc()
+		}
+	}
+	return newLbls, ok
+}
+
+// validUTF8 reports whether the metric and label names of lbls are valid
+// UTF-8 names.
+func validUTF8(lbls labels.Labels) bool {
+	valid := true
+	lbls.Range(func(l labels.Label) {
+		if l.Name == "" || !utf8.ValidString(l.Name) {
+			valid = false
+		}
+		if l.Name == labels.MetricName && (l.Value == "" || !utf8.ValidString(l.Value)) {
+			valid = false
+		}
+	})
+	return valid
+}
+
+// sanitizeLegacy returns lbls with the invalid characters of the metric and
+// label names replaced with underscores. When a sanitized label name is the
+// same as another label name, the label whose name was already valid is kept,
+// or else the first label in lexicographic order. It returns false if lbls
+// has an empty name.
+func sanitizeLegacy(lbls labels.Labels) (labels.Labels, bool) {
+	valid, changed := true, false
+	lbls.Range(func(l labels.Label) {
+		if l.Name == "" {
+			valid = false
+		}
+		if !isLegacyName(l.Name, false) || (l.Name == labels.MetricName && !isLegacyName(l.Value, true)) {
+			changed = true
+		}
+	})
+	if !valid || (lbls.Get(labels.MetricName) == "" && lbls.Has(labels.MetricName)) {
+		return labels.EmptyLabels(), false
+	}
+	if !changed {
+		return lbls, true
+	}
+
+	// Labels whose names are already valid are set first, so that they take
+	// precedence over sanitized names.
+	var (
+		b       = labels.NewScratchBuilder(lbls.Len())
+		seen    = make(map[string]struct{}, lbls.Len())
+		invalid []labels.Label
+	)
+	lbls.Range(func(l labels.Label) {
+		if l.Name == labels.MetricName {
+			l.Value = sanitizeName(l.Value, true)
+		}
+		if !isLegacyName(l.Name, false) {
+			invalid = append(invalid, l)
+			return
+		}
+		seen[l.Name] = struct{}{}
+		b.Add(l.Name, l.Value)
+	})
+	for _, l := range invalid {
+		name := sanitizeName(l.Name, false)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		b.Add(name, l.Value)
+	}
+	b.Sort()
+	return b.Labels(), true
+}
+
+// isLegacyName reports whether name is a valid legacy label name, or a valid
+// legacy metric name when metric is true.
+func isLegacyName(name string, metric bool) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !isLegacyRune(r, i == 0, metric) {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeName replaces the characters of name which aren't valid in a legacy
+// label name, or in a legacy metric name when metric is true, with
+// underscores. Names starting with a digit are prefixed with an underscore.
+func sanitizeName(name string, metric bool) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case isLegacyRune(r, i == 0, metric):
+			sb.WriteRune(r)
+		case i == 0 && r >= '0' && r <= '9':
+			sb.WriteRune('_')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+func isLegacyRune(r rune, first, metric bool) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' ||
+		(metric && r == ':') || (!first && r >= '0' && r <= '9')
+}
@@ -0,0 +1,176 @@
+package normalize
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`forward_to = []`), &args))
+	require.Equal(t, SchemeLegacy, args.ValidationScheme)
+
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to        = []
+	validation_scheme = "utf8"
+`), &args))
+	require.Equal(t, SchemeUTF8, args.ValidationScheme)
+}
+
+func TestBadRiverConfig(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+	forward_to        = []
+	validation_scheme = "ascii"
+`), &args)
+	require.EqualError(t, err, `validation_scheme must be "legacy" or "utf8", got "ascii"`)
+}
+
+func TestSanitizeLegacy(t *testing.T) {
+	tt := []struct {
+		name     string
+		in       labels.Labels
+		expected labels.Labels
+	}{
+		{
+			name:     "valid",
+			in:       labels.FromStrings("__name__", "http_requests:rate5m", "job", "api"),
+			expected: labels.FromStrings("__name__", "http_requests:rate5m", "job", "api"),
+		},
+		{
+			name:     "invalid label names",
+			in:       labels.FromStrings("__name__", "up", "service.name", "api", "1st", "a", "día", "lunes"),
+			expected: labels.FromStrings("__name__", "up", "service_name", "api", "_1st", "a", "d_a", "lunes"),
+		},
+		{
+			name:     "invalid metric name",
+			in:       labels.FromStrings("__name__", "http.server.duration", "job", "api"),
+			expected: labels.FromStrings("__name__", "http_server_duration", "job", "api"),
+		},
+		{
+			name:     "valid name takes precedence",
+			in:       labels.FromStrings("__name__", "up", "k8s.pod", "sanitized", "k8s_pod", "valid"),
+			expected: labels.FromStrings("__name__", "up", "k8s_pod", "valid"),
+		},
+		{
+			name:     "first sanitized name takes precedence",
+			in:       labels.FromStrings("__name__", "up", "k8s-pod", "first", "k8s.pod", "second"),
+			expected: labels.FromStrings("__name__", "up", "k8s_pod", "first"),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := sanitizeLegacy(tc.in)
+			require.True(t, ok)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestValidationSchemes(t *testing.T) {
+	series := labels.FromStrings("__name__", "http.server.duration", "service.name", "api", "job", "api")
+
+	tt := []struct {
+		scheme   string
+		expected labels.Labels
+	}{
+		{SchemeLegacy, labels.FromStrings("__name__", "http_server_duration", "service_name", "api", "job", "api")},
+		{SchemeUTF8, series},
+	}
+	for _, tc := range tt {
+		t.Run(tc.scheme, func(t *testing.T) {
+			c, sink := generateNormalize(t, Arguments{ValidationScheme: tc.scheme})
+			appendSeries(t, c, series, labels.FromStrings("__name__", "up", "job", "api"))
+
+			require.Equal(t, []labels.Labels{tc.expected, labels.FromStrings("__name__", "up", "job", "api")}, sink.series())
+			require.Equal(t, 2.0, testutil.ToFloat64(c.metricsProcessed))
+			require.Equal(t, 0.0, testutil.ToFloat64(c.metricsDropped))
+		})
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	c, sink := generateNormalize(t, Arguments{ValidationScheme: SchemeUTF8})
+	appendSeries(t, c,
+		labels.FromStrings("__name__", "up", "job\xff", "api"),
+		labels.FromStrings("__name__", "up\xff", "job", "api"),
+		labels.FromStrings("__name__", "up", "job", "api"),
+	)
+
+	require.Equal(t, []labels.Labels{labels.FromStrings("__name__", "up", "job", "api")}, sink.series())
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metricsDropped))
+}
+
+func TestUpdate(t *testing.T) {
+	c, sink := generateNormalize(t, Arguments{ValidationScheme: SchemeUTF8})
+	series := labels.FromStrings("__name__", "up", "service.name", "api")
+	appendSeries(t, c, series)
+
+	require.NoError(t, c.Update(Arguments{
+		ForwardTo:        []storage.Appendable{sink.interceptor},
+		ValidationScheme: SchemeLegacy,
+	}))
+	appendSeries(t, c, series)
+
+	require.Equal(t, []labels.Labels{series, labels.FromStrings("__name__", "up", "service_name", "api")}, sink.series())
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metricsSanitized))
+}
+
+// fakeSink records the series of every sample it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []labels.Labels
+}
+
+func (s *fakeSink) series() []labels.Labels {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]labels.Labels{}, s.received...)
+}
+
+func generateNormalize(t *testing.T, args Arguments) (*Component, *fakeSink) {
+	ls := labelstore.New(nil)
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, l)
+		return ref, nil
+	}))
+
+	args.ForwardTo = []storage.Appendable{sink.interceptor}
+	c, err := New(component.Options{
+		ID:            "prometheus.normalize.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c, sink
+}
+
+func appendSeries(t *testing.T, c *Component, series ...labels.Labels) {
+	app := c.receiver.Appender(context.Background())
+	for _, l := range series {
+		_, err := app.Append(0, l, 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
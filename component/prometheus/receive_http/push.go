@@ -0,0 +1,229 @@
+package receive_http
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// pushPathPrefix is the prefix of the paths of the Pushgateway push API. It
+// is followed by the grouping labels as name/value pairs, starting with the
+// job label.
+const pushPathPrefix = "/metrics/"
+
+// pushHandler implements the push API of the Pushgateway. Pushed samples are
+// forwarded once, with the time they were pushed at and the grouping labels
+// of their group.
+//
+// The series pushed to each group are remembered, so that the series replaced
+// or deleted by later requests are marked stale:
+//
+//   - PUT replaces all the series of the group.
+//   - POST replaces the series of the metrics with the same names.
+//   - DELETE deletes all the series of the group.
+type pushHandler struct {
+	log        log.Logger
+	appendable storage.Appendable
+
+	mut sync.Mutex
+	// Labels of the series of each group, by metric name.
+	groups map[string]map[string][]labels.Labels
+}
+
+type pushedSample struct {
+	labels labels.Labels
+	v      float64
+}
+
+func newPushHandler(l log.Logger, appendable storage.Appendable) *pushHandler {
+	return &pushHandler{
+		log:        l,
+		appendable: appendable,
+		groups:     make(map[string]map[string][]labels.Labels),
+	}
+}
+
+func (h *pushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	grouping, err := parseGroupingLabels(strings.TrimPrefix(r.URL.Path, pushPathPrefix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var pushed map[string][]pushedSample
+	if r.Method != http.MethodDelete {
+		pushed, err = decodePush(r, grouping)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.push(r, grouping, pushed); err != nil {
+		level.Error(h.log).Log("msg", "failed to forward pushed metrics", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// push forwards the pushed samples of a group, and marks stale the series of
+// the group which are replaced by the request but weren't pushed again.
+func (h *pushHandler) push(r *http.Request, grouping labels.Labels, pushed map[string][]pushedSample) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	key := grouping.String()
+	previous := h.groups[key]
+
+	next := make(map[string][]labels.Labels, len(pushed))
+	if r.Method == http.MethodPost {
+		for name, series := range previous {
+			next[name] = series
+		}
+	}
+	for name, samples := range pushed {
+		series := make([]labels.Labels, 0, len(samples))
+		for _, s := range samples {
+			series = append(series, s.labels)
+		}
+		next[name] = series
+	}
+
+	var (
+		now = time.Now().UnixMilli()
+		app = h.appendable.Appender(r.Context())
+	)
+	for _, samples := range pushed {
+		for _, s := range samples {
+			if _, err := app.Append(0, s.labels, now, s.v); err != nil {
+				_ = app.Rollback()
+				return err
+			}
+		}
+	}
+	for name, series := range previous {
+		kept := make(map[uint64]struct{}, len(next[name]))
+		for _, l := range next[name] {
+			kept[l.Hash()] = struct{}{}
+		}
+		for _, l := range series {
+			if _, ok := kept[l.Hash()]; ok {
+				continue
+			}
+			if _, err := app.Append(0, l, now, math.Float64frombits(value.StaleNaN)); err != nil {
+				_ = app.Rollback()
+				return err
+			}
+		}
+	}
+	if err := app.Commit(); err != nil {
+		return err
+	}
+
+	if len(next) == 0 {
+		delete(h.groups, key)
+	} else {
+		h.groups[key] = next
+	}
+	return nil
+}
+
+// parseGroupingLabels parses the grouping labels of a push API path, made of
+// label name/value pairs starting with the job label. Label names with a
+// @base64 suffix have a base64url-encoded value.
+func parseGroupingLabels(path string) (labels.Labels, error) {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if len(parts)%2 != 0 {
+		return labels.EmptyLabels(), fmt.Errorf("grouping labels must be name/value pairs, got %q", path)
+	}
+	if parts[0] != "job" && parts[0] != "job@base64" {
+		return labels.EmptyLabels(), fmt.Errorf("grouping labels must start with the job label, got %q", path)
+	}
+
+	b := labels.NewScratchBuilder(len(parts) / 2)
+	for i := 0; i < len(parts); i += 2 {
+		name, v := parts[i], parts[i+1]
+		if strings.HasSuffix(name, "@base64") {
+			name = strings.TrimSuffix(name, "@base64")
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+			if err != nil {
+				return labels.EmptyLabels(), fmt.Errorf("invalid base64 value for label %q: %w", name, err)
+			}
+			v = string(decoded)
+		}
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return labels.EmptyLabels(), fmt.Errorf("invalid grouping label name %q", name)
+		}
+		if name == "job" && v == "" {
+			return labels.EmptyLabels(), errors.New("job label value must not be empty")
+		}
+		b.Add(name, v)
+	}
+	b.Sort()
+
+	grouping := b.Labels()
+	if name, dup := grouping.HasDuplicateLabelNames(); dup {
+		return labels.EmptyLabels(), fmt.Errorf("duplicate grouping label %q", name)
+	}
+	return grouping, nil
+}
+
+// decodePush decodes the metric families of a push request, in the text or
+// protobuf format, into samples by metric name. Grouping labels override the
+// labels of the same name of pushed metrics. As with the Pushgateway, pushed
+// metrics can't have timestamps.
+func decodePush(r *http.Request, grouping labels.Labels) (map[string][]pushedSample, error) {
+	var (
+		res = make(map[string][]pushedSample)
+		dec = expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+	)
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding pushed metrics: %w", err)
+		}
+
+		for _, m := range mf.GetMetric() {
+			if m.TimestampMs != nil {
+				return nil, fmt.Errorf("pushed metric %s has a timestamp", mf.GetName())
+			}
+		}
+
+		vector, err := expfmt.ExtractSamples(&expfmt.DecodeOptions{}, &mf)
+		if err != nil {
+			return nil, fmt.Errorf("decoding pushed metrics: %w", err)
+		}
+		for _, s := range vector {
+			b := labels.NewBuilder(labels.EmptyLabels())
+			for name, v := range s.Metric {
+				b.Set(string(name), string(v))
+			}
+			grouping.Range(func(l labels.Label) {
+				b.Set(l.Name, l.Value)
+			})
+			res[mf.GetName()] = append(res[mf.GetName()], pushedSample{labels: b.Labels(), v: float64(s.Value)})
+		}
+	}
+	return res, nil
+}
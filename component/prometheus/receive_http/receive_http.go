@@ -11,6 +11,7 @@ import (
 	"github.com/grafana/agent/component"
 	fnet "github.com/grafana/agent/component/common/net"
 	agentprom "github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/agent/service/labelstore"
@@ -21,8 +22,9 @@ import (
 
 func init() {
 	component.Register(component.Registration{
-		Name: "prometheus.receive_http",
-		Args: Arguments{},
+		Name:      "prometheus.receive_http",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -45,6 +47,7 @@ func (args *Arguments) SetToDefault() {
 type Component struct {
 	opts               component.Options
 	handler            http.Handler
+	pushHandler        http.Handler
 	fanout             *agentprom.Fanout
 	uncheckedCollector *util.UncheckedCollector
 
@@ -67,6 +70,7 @@ func New(opts component.Options, args Arguments) (*Component, error) {
 	c := &Component{
 		opts:               opts,
 		handler:            remote.NewWriteHandler(opts.Logger, opts.Registerer, fanout),
+		pushHandler:        newPushHandler(opts.Logger, fanout),
 		fanout:             fanout,
 		uncheckedCollector: uncheckedCollector,
 	}
@@ -113,6 +117,7 @@ func (c *Component) Update(args component.Arguments) error {
 
 	err = c.server.MountAndRun(func(router *mux.Router) {
 		router.Path("/api/v1/metrics/write").Methods("POST").Handler(c.handler)
+		router.PathPrefix(pushPathPrefix+"job").Methods("PUT", "POST", "DELETE").Handler(c.pushHandler)
 	})
 	if err != nil {
 		return err
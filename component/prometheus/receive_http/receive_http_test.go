@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/storage/remote"
@@ -395,3 +398,122 @@ func getFreePort(t *testing.T) int {
 	require.NoError(t, err)
 	return p
 }
+
+func TestPushgateway(t *testing.T) {
+	actualSamples := make(chan testSample, 100)
+	args := Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{
+				ListenAddress: "localhost",
+				ListenPort:    getFreePort(t),
+			},
+			GRPC: testGRPCConfig(t),
+		},
+		ForwardTo: testAppendable(actualSamples),
+	}
+	comp, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+	waitForServerToBeReady(t, args)
+
+	group := fmt.Sprintf("http://localhost:%d/metrics/job/batch/instance/host1", args.Server.HTTP.ListenPort)
+	push := func(method string, body string, expectedStatus int) {
+		req, err := http.NewRequestWithContext(ctx, method, group, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, expectedStatus, resp.StatusCode)
+	}
+	receive := func(count int) map[string]string {
+		res := make(map[string]string)
+		for i := 0; i < count; i++ {
+			select {
+			case s := <-actualSamples:
+				require.InDelta(t, time.Now().UnixMilli(), s.ts, float64(5*time.Second/time.Millisecond))
+				if value.IsStaleNaN(s.val) {
+					res[s.l.String()] = "stale"
+				} else {
+					res[s.l.String()] = strconv.FormatFloat(s.val, 'g', -1, 64)
+				}
+			case <-ctx.Done():
+				t.Fatalf("test timed out")
+			}
+		}
+		select {
+		case unexpected := <-actualSamples:
+			t.Fatalf("unexpected extra sample received: %v", unexpected)
+		default:
+		}
+		return res
+	}
+
+	// Grouping labels are added to pushed metrics, overriding labels of the
+	// same name.
+	push(http.MethodPut, `
+# TYPE batch_duration_seconds gauge
+batch_duration_seconds{instance="ignored"} 12.5
+# TYPE batch_records_total counter
+batch_records_total{stage="extract"} 100
+batch_records_total{stage="load"} 90
+`, http.StatusOK)
+	require.Equal(t, map[string]string{
+		`{__name__="batch_duration_seconds", instance="host1", job="batch"}`:               "12.5",
+		`{__name__="batch_records_total", instance="host1", job="batch", stage="extract"}`: "100",
+		`{__name__="batch_records_total", instance="host1", job="batch", stage="load"}`:    "90",
+	}, receive(3))
+
+	// POST replaces the series of the pushed metrics only.
+	push(http.MethodPost, "batch_records_total{stage=\"extract\"} 120\n", http.StatusOK)
+	require.Equal(t, map[string]string{
+		`{__name__="batch_records_total", instance="host1", job="batch", stage="extract"}`: "120",
+		`{__name__="batch_records_total", instance="host1", job="batch", stage="load"}`:    "stale",
+	}, receive(2))
+
+	// PUT replaces all the series of the group.
+	push(http.MethodPut, "batch_duration_seconds 3\n", http.StatusOK)
+	require.Equal(t, map[string]string{
+		`{__name__="batch_duration_seconds", instance="host1", job="batch"}`:               "3",
+		`{__name__="batch_records_total", instance="host1", job="batch", stage="extract"}`: "stale",
+	}, receive(2))
+
+	// Pushed metrics can't have timestamps.
+	push(http.MethodPost, "batch_duration_seconds 3 1700000000000\n", http.StatusBadRequest)
+
+	push(http.MethodDelete, "", http.StatusAccepted)
+	require.Equal(t, map[string]string{
+		`{__name__="batch_duration_seconds", instance="host1", job="batch"}`: "stale",
+	}, receive(1))
+}
+
+func TestParseGroupingLabels(t *testing.T) {
+	tt := []struct {
+		path   string
+		expect labels.Labels
+		err    string
+	}{
+		{path: "job/batch", expect: labels.FromStrings("job", "batch")},
+		{path: "job/batch/instance/host1/", expect: labels.FromStrings("job", "batch", "instance", "host1")},
+		{path: "job@base64/L3Zhci90bXA/path@base64/YS9i", expect: labels.FromStrings("job", "/var/tmp", "path", "a/b")},
+		{path: "job/batch/instance", err: "must be name/value pairs"},
+		{path: "instance/host1", err: "must start with the job label"},
+		{path: "job/", err: "must be name/value pairs"},
+		{path: "job/batch/__name__/up", err: `invalid grouping label name "__name__"`},
+		{path: "job/batch/job/other", err: `duplicate grouping label "job"`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			actual, err := parseGroupingLabels(tc.path)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
@@ -12,9 +12,12 @@ import (
 	"github.com/grafana/agent/component"
 	flow_relabel "github.com/grafana/agent/component/common/relabel"
 	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/service/labelstore"
 	lru "github.com/hashicorp/golang-lru/v2"
 	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
@@ -22,13 +25,15 @@ import (
 
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/util/strutil"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "prometheus.relabel",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "prometheus.relabel",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -45,16 +50,51 @@ type Arguments struct {
 	// The relabelling rules to apply to each metric before it's forwarded.
 	MetricRelabelConfigs []*flow_relabel.Config `river:"rule,block,optional"`
 
+	// How to handle the invalid metric and label names produced by the rules.
+	NameValidation string `river:"name_validation,attr,optional"`
+
 	// Cache size to use for LRU cache.
 	//CacheSize int `river:"cache_size,attr,optional"`
 }
 
+// Supported values of the name_validation argument.
+const (
+	// NameValidationNone forwards the series with invalid names as is.
+	NameValidationNone = "none"
+	// NameValidationLenient sanitizes the invalid names of the series,
+	// replacing the invalid characters with underscores.
+	NameValidationLenient = "lenient"
+	// NameValidationStrict rejects the rules which produce invalid names as
+	// far as their configuration tells, and drops the series with invalid
+	// names.
+	NameValidationStrict = "strict"
+)
+
+// DefaultArguments holds the default settings for the prometheus.relabel
+// component.
+var DefaultArguments = Arguments{
+	NameValidation: NameValidationNone,
+}
+
 // SetToDefault implements river.Defaulter.
-/*func (arg *Arguments) SetToDefault() {
-	*arg = Arguments{
-		CacheSize: 500_000,
+func (arg *Arguments) SetToDefault() {
+	*arg = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (arg *Arguments) Validate() error {
+	switch arg.NameValidation {
+	case NameValidationNone, NameValidationLenient:
+		return nil
+	case NameValidationStrict:
+		if err := flow_relabel.ValidateNames(arg.MetricRelabelConfigs); err != nil {
+			return fmt.Errorf("invalid names with strict name_validation: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported name_validation %q, must be one of %q, %q, or %q", arg.NameValidation, NameValidationNone, NameValidationLenient, NameValidationStrict)
 	}
-}*/
+}
 
 // Exports holds values which are exported by the prometheus.relabel component.
 type Exports struct {
@@ -67,9 +107,11 @@ type Component struct {
 	mut              sync.RWMutex
 	opts             component.Options
 	mrc              []*relabel.Config
+	nameValidation   string
 	receiver         *prometheus.Interceptor
 	metricsProcessed prometheus_client.Counter
 	metricsOutgoing  prometheus_client.Counter
+	invalidNames     prometheus_client.Counter
 	cacheHits        prometheus_client.Counter
 	cacheMisses      prometheus_client.Counter
 	cacheSize        prometheus_client.Gauge
@@ -125,8 +167,12 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		Name: "agent_prometheus_relabel_cache_deletes",
 		Help: "Total number of cache deletes",
 	})
+	c.invalidNames = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_relabel_invalid_names_total",
+		Help: "Total number of series with invalid metric or label names, dropped or sanitized depending on name_validation",
+	})
 
-	for _, metric := range []prometheus_client.Collector{c.metricsProcessed, c.metricsOutgoing, c.cacheMisses, c.cacheHits, c.cacheSize, c.cacheDeletes} {
+	for _, metric := range []prometheus_client.Collector{c.metricsProcessed, c.metricsOutgoing, c.cacheMisses, c.cacheHits, c.cacheSize, c.cacheDeletes, c.invalidNames} {
 		err = o.Registerer.Register(metric)
 		if err != nil {
 			return nil, err
@@ -212,6 +258,7 @@ func (c *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
 	c.clearCache(100_000)
 	c.mrc = flow_relabel.ComponentToPromRelabelConfigs(newArgs.MetricRelabelConfigs)
+	c.nameValidation = newArgs.NameValidation
 	c.fanout.UpdateChildren(newArgs.ForwardTo)
 
 	c.opts.OnStateChange(Exports{Receiver: c.receiver, Rules: newArgs.MetricRelabelConfigs})
@@ -239,6 +286,9 @@ func (c *Component) relabel(val float64, lbls labels.Labels) labels.Labels {
 		// Relabel against a copy of the labels to prevent modifying the original
 		// slice.
 		relabelled, keep = relabel.Process(lbls.Copy(), c.mrc...)
+		if keep {
+			relabelled, keep = c.validateNames(relabelled)
+		}
 		c.cacheMisses.Inc()
 		c.addToCache(globalRef, relabelled, keep)
 	}
@@ -255,6 +305,49 @@ func (c *Component) relabel(val float64, lbls labels.Labels) labels.Labels {
 	return relabelled
 }
 
+// validateNames handles the invalid metric and label names of the relabeled
+// series lbls according to name_validation.
+func (c *Component) validateNames(lbls labels.Labels) (labels.Labels, bool) {
+	if c.nameValidation == NameValidationNone || c.nameValidation == "" || validNames(lbls) {
+		return lbls, true
+	}
+	c.invalidNames.Inc()
+
+	if c.nameValidation == NameValidationStrict {
+		level.Debug(c.opts.Logger).Log("msg", "dropping series with invalid names", "series", lbls.String())
+		return labels.EmptyLabels(), false
+	}
+
+	b := labels.NewBuilder(lbls)
+	lbls.Range(func(l labels.Label) {
+		switch {
+		case l.Name == model.MetricNameLabel && !model.IsValidMetricName(model.LabelValue(l.Value)):
+			b.Set(l.Name, strutil.SanitizeFullLabelName(l.Value))
+		case l.Name != model.MetricNameLabel && !model.LabelName(l.Name).IsValid():
+			b.Del(l.Name)
+			// The valid labels win over the sanitized ones with the same name.
+			if name := strutil.SanitizeFullLabelName(l.Name); !lbls.Has(name) {
+				b.Set(name, l.Value)
+			}
+		}
+	})
+	return b.Labels(), true
+}
+
+// validNames reports whether the metric name and label names of lbls are
+// valid.
+func validNames(lbls labels.Labels) bool {
+	valid := true
+	lbls.Range(func(l labels.Label) {
+		if l.Name == model.MetricNameLabel {
+			valid = valid && model.IsValidMetricName(model.LabelValue(l.Value))
+		} else {
+			valid = valid && model.LabelName(l.Name).IsValid()
+		}
+	})
+	return valid
+}
+
 func (c *Component) getFromCache(id uint64) (*labelAndID, bool) {
 	c.cacheMut.RLock()
 	defer c.cacheMut.RUnlock()
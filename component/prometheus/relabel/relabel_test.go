@@ -80,6 +80,42 @@ func TestNil(t *testing.T) {
 	relabeller.relabel(0, lbls)
 }
 
+func TestCaseActions(t *testing.T) {
+	var received []labels.Labels
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		received = append(received, l)
+		return ref, nil
+	}))
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		rule {
+			source_labels = ["env"]
+			target_label  = "env"
+			action        = "lowercase"
+		}
+
+		rule {
+			source_labels = ["region", "zone"]
+			separator     = "-"
+			target_label  = "location"
+			action        = "uppercase"
+		}
+		forward_to = []
+	`), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+	c := newTestComponent(t, args)
+
+	app := c.receiver.Appender(context.Background())
+	_, err := app.Append(0, labels.FromStrings("__name__", "up", "env", "PROD", "region", "eu", "zone", "b"), time.Now().UnixMilli(), 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	require.Equal(t, []labels.Labels{
+		labels.FromStrings("__name__", "up", "env", "prod", "location", "EU-B", "region", "eu", "zone", "b"),
+	}, received)
+}
+
 func TestLRU(t *testing.T) {
 	relabeller := generateRelabel(t)
 
@@ -213,3 +249,141 @@ func TestRuleGetter(t *testing.T) {
 	require.Equal(t, gotUpdated[0].SourceLabels, gotOriginal[0].SourceLabels)
 	require.Equal(t, gotUpdated[0].Regex, gotOriginal[0].Regex)
 }
+
+func TestNameValidation(t *testing.T) {
+	// The target label of the lowercase action isn't expanded, so the rule
+	// produces a "$1" label.
+	const rule = `
+		rule {
+			source_labels = ["instance"]
+			target_label  = "$1"
+			action        = "lowercase"
+		}
+
+		rule {
+			source_labels = ["job"]
+			target_label  = "__name__"
+		}
+		forward_to = []
+	`
+
+	t.Run("strict", func(t *testing.T) {
+		var args Arguments
+		err := river.Unmarshal([]byte(rule+`name_validation = "strict"`), &args)
+		require.EqualError(t, err, `invalid names with strict name_validation: rule 1: "$1" is not a valid label name for the 'target_label' of the lowercase action, which doesn't expand references to capture groups`)
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(rule+`name_validation = "lenient"`), &args))
+
+		c := newTestComponent(t, args)
+		require.Equal(t,
+			labels.FromStrings("__name__", "my_job", "_1", "host:80", "instance", "HOST:80", "job", "my-job"),
+			c.relabel(0, labels.FromStrings("instance", "HOST:80", "job", "my-job")),
+		)
+	})
+
+	t.Run("strict drops invalid series", func(t *testing.T) {
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(`
+			rule {
+				source_labels = ["job"]
+				target_label  = "__name__"
+			}
+			forward_to      = []
+			name_validation = "strict"
+		`), &args))
+
+		c := newTestComponent(t, args)
+		require.True(t, c.relabel(0, labels.FromStrings("job", "my-job")).IsEmpty())
+		require.Equal(t,
+			labels.FromStrings("__name__", "my_job", "job", "my_job"),
+			c.relabel(0, labels.FromStrings("job", "my_job")),
+		)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(rule), &args))
+		require.Equal(t, NameValidationNone, args.NameValidation)
+
+		c := newTestComponent(t, args)
+		require.Equal(t,
+			labels.FromStrings("$1", "host:80", "__name__", "my-job", "instance", "HOST:80", "job", "my-job"),
+			c.relabel(0, labels.FromStrings("instance", "HOST:80", "job", "my-job")),
+		)
+	})
+}
+
+func TestValidateNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		expect string
+	}{
+		{
+			name: "missing capture group",
+			rule: `
+				source_labels = ["instance"]
+				regex         = "(.*):.*"
+				target_label  = "$1_suffix"
+			`,
+			expect: `rule 1: the 'target_label' "$1_suffix" references the capture group "1_suffix", which the regex "(.*):.*" doesn't have`,
+		},
+		{
+			name: "named capture group",
+			rule: `
+				source_labels = ["instance"]
+				regex         = "(?P<host>.*):.*"
+				target_label  = "${host}_suffix"
+			`,
+		},
+		{
+			name: "invalid metric name",
+			rule: `
+				target_label = "__name__"
+				replacement  = "my-metric"
+			`,
+			expect: `rule 1: "my-metric" is not a valid metric name for the 'replacement' of __name__`,
+		},
+		{
+			name: "labelmap",
+			rule: `
+				regex       = "__meta_(.*)"
+				replacement = "${2}"
+				action      = "labelmap"
+			`,
+			expect: `rule 1: the 'replacement' "${2}" references the capture group "2", which the regex "__meta_(.*)" doesn't have`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(`
+				forward_to      = []
+				name_validation = "strict"
+				rule {`+tc.rule+`}
+			`), &args)
+			if tc.expect == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, "invalid names with strict name_validation: "+tc.expect)
+			}
+		})
+	}
+}
+
+func newTestComponent(t *testing.T, args Arguments) *Component {
+	c, err := New(component.Options{
+		ID:            "1",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c
+}
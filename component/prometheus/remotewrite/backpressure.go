@@ -0,0 +1,177 @@
+package remotewrite
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// backpressureRegisterer is the prometheus.Registerer the remote storage
+// registers its metrics to. It keeps the enqueue retries counters of the
+// queues, which increase every time a queue stops reading from the WAL
+// because its shards are full, and exposes them as backpressure events by
+// endpoint.
+//
+// It also keeps the counters of the samples and histograms sent by the
+// queues, which the endpoint transports rate limit their requests by.
+type backpressureRegisterer struct {
+	prometheus.Registerer
+
+	desc *prometheus.Desc
+
+	mut     sync.Mutex
+	retries map[prometheus.Counter]struct{}
+	sent    map[prometheus.Counter]queueKey
+}
+
+var (
+	_ prometheus.Registerer = (*backpressureRegisterer)(nil)
+	_ prometheus.Collector  = (*backpressureRegisterer)(nil)
+)
+
+const (
+	// enqueueRetriesName is the name of the counter of enqueue retries of
+	// the remote storage queues.
+	enqueueRetriesName = "prometheus_remote_storage_enqueue_retries_total"
+	// samplesTotalName and histogramsTotalName are the names of the counters
+	// of the samples and histograms sent by the remote storage queues,
+	// retries included. They're increased right before the requests are
+	// sent.
+	samplesTotalName    = "prometheus_remote_storage_samples_total"
+	histogramsTotalName = "prometheus_remote_storage_histograms_total"
+)
+
+// queueKey identifies the queue of a remote storage metric by its labels.
+type queueKey struct {
+	remoteName, url string
+}
+
+func newBackpressureRegisterer(reg prometheus.Registerer) *backpressureRegisterer {
+	return &backpressureRegisterer{
+		Registerer: reg,
+		desc: prometheus.NewDesc(
+			"agent_prometheus_remote_write_backpressure_total",
+			"Number of times reading samples from the WAL was paused because the shards of the queue of the endpoint were full.",
+			[]string{"url"}, nil,
+		),
+		retries: make(map[prometheus.Counter]struct{}),
+		sent:    make(map[prometheus.Counter]queueKey),
+	}
+}
+
+// Register implements prometheus.Registerer.
+func (r *backpressureRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	counter, ok := c.(prometheus.Counter)
+	if !ok {
+		return nil
+	}
+	switch metricName(counter) {
+	case enqueueRetriesName:
+		r.mut.Lock()
+		r.retries[counter] = struct{}{}
+		r.mut.Unlock()
+	case samplesTotalName, histogramsTotalName:
+		var m dto.Metric
+		if err := counter.Write(&m); err != nil {
+			return nil
+		}
+		r.mut.Lock()
+		r.sent[counter] = queueKey{
+			remoteName: labelValue(&m, "remote_name"),
+			url:        labelValue(&m, "url"),
+		}
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+// SentSamples returns the number of samples and histograms sent by the queue
+// identified by key since it was created.
+func (r *backpressureRegisterer) SentSamples(key queueKey) float64 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	var total float64
+	for counter, k := range r.sent {
+		if k != key {
+			continue
+		}
+		var m dto.Metric
+		if err := counter.Write(&m); err == nil {
+			total += m.GetCounter().GetValue()
+		}
+	}
+	return total
+}
+
+// labelValue returns the value of the label name of m.
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// metricName returns the name of the metric of c. prometheus.Desc doesn't
+// expose it, so it's gathered from a registry of its own.
+func metricName(c prometheus.Collector) string {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		return ""
+	}
+	families, err := reg.Gather()
+	if err != nil || len(families) != 1 {
+		return ""
+	}
+	return families[0].GetName()
+}
+
+// MustRegister implements prometheus.Registerer.
+func (r *backpressureRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister implements prometheus.Registerer.
+func (r *backpressureRegisterer) Unregister(c prometheus.Collector) bool {
+	if counter, ok := c.(prometheus.Counter); ok {
+		r.mut.Lock()
+		delete(r.retries, counter)
+		delete(r.sent, counter)
+		r.mut.Unlock()
+	}
+	return r.Registerer.Unregister(c)
+}
+
+// Describe implements prometheus.Collector.
+func (r *backpressureRegisterer) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.desc
+}
+
+// Collect implements prometheus.Collector. The retries of the queues of the
+// same URL are added up.
+func (r *backpressureRegisterer) Collect(ch chan<- prometheus.Metric) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	totals := make(map[string]float64, len(r.retries))
+	for counter := range r.retries {
+		var m dto.Metric
+		if err := counter.Write(&m); err != nil {
+			continue
+		}
+		totals[labelValue(&m, "url")] += m.GetCounter().GetValue()
+	}
+	for url, total := range totals {
+		ch <- prometheus.MustNewConstMetric(r.desc, prometheus.CounterValue, total, url)
+	}
+}
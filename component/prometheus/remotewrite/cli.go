@@ -16,6 +16,7 @@ import (
 func InstallTools(cmd *cobra.Command) {
 	cmd.AddCommand(
 		samplesCmd(),
+		segmentStatsCmd(),
 		targetStatsCmd(),
 		walStatsCmd(),
 	)
@@ -203,6 +204,72 @@ deletion but then comes back at some point).`,
 	}
 }
 
+func segmentStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "segment-stats [WAL directory]",
+		Short: "Collect stats on every segment of the WAL",
+		Long: `segment-stats reads a WAL directory and collects information on the most
+recent checkpoint and on every segment within it: its size, the number of
+series and samples it holds, and the timestamps of its oldest and newest
+samples.
+
+Segments marked as checkpointed hold records which are also in the checkpoint,
+and are deleted the next time the WAL is truncated. A growing number of
+checkpointed segments indicates that the WAL isn't truncated.
+
+The WAL is only read, so segment-stats can be run against the WAL of a running
+agent.`,
+		Args: cobra.ExactArgs(1),
+
+		Run: func(_ *cobra.Command, args []string) {
+			directory := args[0]
+			if _, err := os.Stat(directory); os.IsNotExist(err) {
+				fmt.Printf("%s does not exist\n", directory)
+				os.Exit(1)
+			} else if err != nil {
+				fmt.Printf("error getting wal: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Check if ./wal is a subdirectory, use that instead.
+			if _, err := os.Stat(filepath.Join(directory, "wal")); err == nil {
+				directory = filepath.Join(directory, "wal")
+			}
+
+			stats, err := waltools.CalculateSegmentStats(directory)
+			if err != nil {
+				fmt.Printf("failed to get segment stats: %v\n", err)
+				os.Exit(1)
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			defer table.Render()
+
+			table.SetHeader([]string{"Segment", "Size (bytes)", "Series", "Samples", "Oldest Sample", "Newest Sample", "Checkpointed"})
+
+			for _, s := range stats {
+				var from, to string
+				if s.Samples > 0 {
+					from, to = s.From.String(), s.To.String()
+				}
+				checkpointed := ""
+				if s.Checkpointed {
+					checkpointed = "yes"
+				}
+				table.Append([]string{
+					s.Name,
+					fmt.Sprintf("%d", s.Size),
+					fmt.Sprintf("%d", s.Series),
+					fmt.Sprintf("%d", s.Samples),
+					from,
+					to,
+					checkpointed,
+				})
+			}
+		},
+	}
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,172 @@
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeadMansSwitchOptions configures detection of remote_write pipelines which
+// have stopped delivering data.
+type DeadMansSwitchOptions struct {
+	// Timeout is how long the component may go without successfully sending
+	// any data before the switch is triggered.
+	Timeout time.Duration `river:"timeout,attr"`
+	// ReportUnhealthy marks the component as unhealthy while the switch is
+	// triggered.
+	ReportUnhealthy bool `river:"report_unhealthy,attr,optional"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (o *DeadMansSwitchOptions) SetToDefault() {
+	*o = DeadMansSwitchOptions{
+		ReportUnhealthy: true,
+	}
+}
+
+// Validate implements river.Validator.
+func (o *DeadMansSwitchOptions) Validate() error {
+	if o.Timeout <= 0 {
+		return fmt.Errorf("dead_mans_switch timeout must be greater than 0")
+	}
+	return nil
+}
+
+// deadMansSwitch tracks the most recent time data was successfully sent to
+// all endpoints and trips once no progress has been made within the
+// configured timeout.
+type deadMansSwitch struct {
+	log    log.Logger
+	noData prometheus.Gauge
+
+	mut          sync.Mutex
+	opts         *DeadMansSwitchOptions
+	lastSentTs   int64
+	lastProgress time.Time
+	triggered    bool
+}
+
+func newDeadMansSwitch(l log.Logger, reg prometheus.Registerer) (*deadMansSwitch, error) {
+	noData := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_prometheus_remote_write_no_data",
+		Help: "Set to 1 when no data has been successfully sent to remote_write endpoints within the dead_mans_switch timeout.",
+	})
+	if err := reg.Register(noData); err != nil {
+		return nil, err
+	}
+
+	return &deadMansSwitch{
+		log:          l,
+		noData:       noData,
+		lastProgress: time.Now(),
+	}, nil
+}
+
+// SetOptions updates the options of the switch. Passing nil disables the
+// switch and clears any triggered state.
+func (d *deadMansSwitch) SetOptions(opts *DeadMansSwitchOptions) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.opts == nil && opts != nil {
+		// Give newly enabled switches a full timeout before triggering.
+		d.lastProgress = time.Now()
+	}
+	d.opts = opts
+	if opts == nil {
+		d.setTriggered(false)
+	}
+}
+
+// Observe records the lowest timestamp sent across all endpoints at time now.
+// Any change in the sent timestamp is treated as progress.
+func (d *deadMansSwitch) Observe(now time.Time, lowestSentTs int64) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if lowestSentTs != d.lastSentTs {
+		d.lastSentTs = lowestSentTs
+		d.lastProgress = now
+	}
+	if d.opts == nil {
+		return
+	}
+
+	stalled := now.Sub(d.lastProgress) >= d.opts.Timeout
+	if stalled && !d.triggered {
+		level.Warn(d.log).Log("msg", "no data has been successfully sent to remote_write endpoints within the dead_mans_switch timeout", "timeout", d.opts.Timeout, "last_progress", d.lastProgress)
+	} else if !stalled && d.triggered {
+		level.Info(d.log).Log("msg", "remote_write endpoints are receiving data again")
+	}
+	d.setTriggered(stalled)
+}
+
+func (d *deadMansSwitch) setTriggered(triggered bool) {
+	d.triggered = triggered
+	if triggered {
+		d.noData.Set(1)
+	} else {
+		d.noData.Set(0)
+	}
+}
+
+// Run periodically observes the lowest sent timestamp until ctx is canceled.
+func (d *deadMansSwitch) Run(ctx context.Context, lowestSentTimestamp func() int64) {
+	ticker := time.NewTicker(d.checkInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Observe(now, lowestSentTimestamp())
+			ticker.Reset(d.checkInterval())
+		}
+	}
+}
+
+// checkInterval returns how often the switch should be checked so that it
+// trips shortly after the timeout elapses.
+func (d *deadMansSwitch) checkInterval() time.Duration {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	const maxInterval = 15 * time.Second
+	if d.opts == nil {
+		return maxInterval
+	}
+	interval := d.opts.Timeout / 10
+	switch {
+	case interval <= 0:
+		return time.Millisecond
+	case interval > maxInterval:
+		return maxInterval
+	default:
+		return interval
+	}
+}
+
+// CurrentHealth returns the health derived from the switch state.
+func (d *deadMansSwitch) CurrentHealth() component.Health {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.triggered && d.opts != nil && d.opts.ReportUnhealthy {
+		return component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("no data successfully sent to remote_write endpoints since %s", d.lastProgress.Format(time.RFC3339)),
+			UpdateTime: d.lastProgress.Add(d.opts.Timeout),
+		}
+	}
+	return component.Health{
+		Health:     component.HealthTypeHealthy,
+		UpdateTime: d.lastProgress,
+	}
+}
@@ -0,0 +1,138 @@
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestDeadMansSwitch_Observe(t *testing.T) {
+	d, err := newDeadMansSwitch(util.TestLogger(t), prometheus.NewRegistry())
+	require.NoError(t, err)
+	d.SetOptions(&DeadMansSwitchOptions{Timeout: time.Minute, ReportUnhealthy: true})
+
+	start := time.Now()
+	d.Observe(start, 100)
+	require.Equal(t, 0.0, testutil.ToFloat64(d.noData))
+
+	// No progress, but still within the timeout.
+	d.Observe(start.Add(30*time.Second), 100)
+	require.Equal(t, 0.0, testutil.ToFloat64(d.noData))
+	require.Equal(t, component.HealthTypeHealthy, d.CurrentHealth().Health)
+
+	// No progress past the timeout.
+	d.Observe(start.Add(time.Minute), 100)
+	require.Equal(t, 1.0, testutil.ToFloat64(d.noData))
+	require.Equal(t, component.HealthTypeUnhealthy, d.CurrentHealth().Health)
+
+	// Progress clears the switch.
+	d.Observe(start.Add(90*time.Second), 200)
+	require.Equal(t, 0.0, testutil.ToFloat64(d.noData))
+	require.Equal(t, component.HealthTypeHealthy, d.CurrentHealth().Health)
+
+	// Disabling the switch clears any triggered state.
+	d.Observe(start.Add(5*time.Minute), 200)
+	require.Equal(t, 1.0, testutil.ToFloat64(d.noData))
+	d.SetOptions(nil)
+	require.Equal(t, 0.0, testutil.ToFloat64(d.noData))
+	require.Equal(t, component.HealthTypeHealthy, d.CurrentHealth().Health)
+}
+
+func TestDeadMansSwitch_ReportUnhealthyDisabled(t *testing.T) {
+	d, err := newDeadMansSwitch(util.TestLogger(t), prometheus.NewRegistry())
+	require.NoError(t, err)
+	d.SetOptions(&DeadMansSwitchOptions{Timeout: time.Minute, ReportUnhealthy: false})
+
+	start := time.Now()
+	d.Observe(start, 100)
+	d.Observe(start.Add(2*time.Minute), 100)
+	require.Equal(t, 1.0, testutil.ToFloat64(d.noData))
+	require.Equal(t, component.HealthTypeHealthy, d.CurrentHealth().Health)
+}
+
+func TestDeadMansSwitch_FailingEndpoint(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		endpoint {
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+				min_backoff         = "10ms"
+				max_backoff         = "50ms"
+			}
+		}
+
+		dead_mans_switch {
+			timeout = "500ms"
+		}
+	`, srv.URL)), &args))
+
+	reg := prometheus.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "prometheus.remote_write.test",
+		Logger:        util.TestFlowLogger(t),
+		DataPath:      t.TempDir(),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	// Use a future timestamp since remote_write ignores samples which are
+	// older than the time it started.
+	sendSample := func(ts time.Time) {
+		app := c.receiver.Appender(context.Background())
+		_, err := app.Append(0, labels.FromStrings("__name__", "heartbeat"), ts.UnixMilli(), 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+	sendSample(time.Now().Add(time.Minute))
+
+	// Writes fail, so the switch must trip after the timeout.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(c.deadMansSwitch.noData) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, component.HealthTypeUnhealthy, c.CurrentHealth().Health)
+
+	// Once writes start succeeding, the switch is cleared. The WAL watcher
+	// may take a while to pick up new samples, so allow for a generous wait.
+	healthy.Store(true)
+	sendSample(time.Now().Add(2 * time.Minute))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(c.deadMansSwitch.noData) == 0
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+}
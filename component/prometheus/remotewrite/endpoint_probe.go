@@ -0,0 +1,212 @@
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/useragent"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/config"
+)
+
+// EndpointProbeOptions configures periodic requests checking whether the
+// endpoints are reachable, whether or not data is being sent to them.
+type EndpointProbeOptions struct {
+	// How often the endpoints are probed.
+	Interval time.Duration `river:"interval,attr,optional"`
+	// How long to wait for the response to a probe.
+	Timeout time.Duration `river:"timeout,attr,optional"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (o *EndpointProbeOptions) SetToDefault() {
+	*o = EndpointProbeOptions{
+		Interval: time.Minute,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// Validate implements river.Validator.
+func (o *EndpointProbeOptions) Validate() error {
+	if o.Interval <= 0 {
+		return fmt.Errorf("endpoint_probe interval must be greater than 0")
+	}
+	if o.Timeout <= 0 || o.Timeout > o.Interval {
+		return fmt.Errorf("endpoint_probe timeout must be greater than 0 and at most the interval")
+	}
+	return nil
+}
+
+// endpointProbe periodically sends GET requests to the endpoints to report
+// whether they're reachable. Any response other than a server error counts as
+// reachable, since remote_write endpoints usually reject GET requests. The
+// requests are signed and authenticated like the requests of the queues.
+type endpointProbe struct {
+	log log.Logger
+	up  *prometheus.GaugeVec
+
+	updated chan struct{}
+
+	mut     sync.Mutex
+	opts    *EndpointProbeOptions
+	targets []probeTarget
+}
+
+// probeTarget is an endpoint to probe along with the client to probe it with.
+type probeTarget struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func newEndpointProbe(l log.Logger, reg prometheus.Registerer) (*endpointProbe, error) {
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_prometheus_remote_write_endpoint_up",
+		Help: "Whether the last endpoint_probe request to each endpoint got a response other than a server error.",
+	}, []string{"endpoint", "url"})
+	if err := reg.Register(up); err != nil {
+		return nil, err
+	}
+
+	return &endpointProbe{
+		log:     l,
+		up:      up,
+		updated: make(chan struct{}, 1),
+	}, nil
+}
+
+// SetOptions updates the options of the probe and the endpoints to probe,
+// which are probed right away. Passing nil options disables the probe.
+//
+// The endpoints are probed with the client of their queue, created from
+// their remote_write configuration rws, or through their transport if they
+// have one in transports, by endpoint key.
+func (p *endpointProbe) SetOptions(opts *EndpointProbeOptions, endpoints []*EndpointOptions, rws []*config.RemoteWriteConfig, transports map[string]*endpointTransport) error {
+	var targets []probeTarget
+	if opts != nil {
+		for i, ep := range endpoints {
+			var rt http.RoundTripper
+			if t, ok := transports[ep.Name+"/"+ep.URL]; ok {
+				rt = t.Unlimited()
+			} else {
+				client, err := newRemoteClient(rws[i])
+				if err != nil {
+					return fmt.Errorf("failed to create endpoint_probe client for %s: %w", ep.URL, err)
+				}
+				rt = client.Client.Transport
+			}
+			targets = append(targets, probeTarget{
+				name:   ep.Name,
+				url:    ep.URL,
+				client: &http.Client{Transport: rt},
+			})
+		}
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.opts = opts
+	p.targets = targets
+	p.up.Reset()
+
+	select {
+	case p.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run probes the endpoints every interval until ctx is canceled.
+func (p *endpointProbe) Run(ctx context.Context) {
+	// The endpoints are probed for the first time once the options are set.
+	var next <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.updated:
+		case <-next:
+		}
+
+		p.mut.Lock()
+		opts, targets := p.opts, p.targets
+		p.mut.Unlock()
+		if opts == nil {
+			next = nil
+			continue
+		}
+
+		p.probeAll(ctx, opts.Timeout, targets)
+		next = time.After(opts.Interval)
+	}
+}
+
+// probeAll probes all targets concurrently, so that unreachable endpoints
+// don't delay probing the others.
+func (p *endpointProbe) probeAll(ctx context.Context, timeout time.Duration, targets []probeTarget) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target probeTarget) {
+			defer wg.Done()
+
+			err := probe(ctx, timeout, target)
+			if err != nil {
+				level.Warn(p.log).Log("msg", "remote_write endpoint is unreachable", "endpoint", target.name, "url", target.url, "err", err)
+			}
+
+			p.mut.Lock()
+			defer p.mut.Unlock()
+			// The endpoints may have changed while probing.
+			if ctx.Err() != nil || !p.isTarget(target) {
+				return
+			}
+			if err != nil {
+				p.up.WithLabelValues(target.name, target.url).Set(0)
+			} else {
+				p.up.WithLabelValues(target.name, target.url).Set(1)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+func (p *endpointProbe) isTarget(target probeTarget) bool {
+	for _, t := range p.targets {
+		if t.name == target.name && t.url == target.url {
+			return true
+		}
+	}
+	return false
+}
+
+// probe sends a GET request to target, failing if there is no response or if
+// it's a server error.
+func probe(ctx context.Context, timeout time.Duration, target probeTarget) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", useragent.Get())
+
+	resp, err := target.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+	return nil
+}
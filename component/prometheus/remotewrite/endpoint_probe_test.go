@@ -0,0 +1,179 @@
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// TestEndpointProbe ensures that the endpoint_up gauge reports whether the
+// endpoints are reachable while no data is sent to them.
+func TestEndpointProbe(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		// Like most remote_write endpoints, only accept POST requests.
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+	defer srv.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		endpoint {
+			url = "%s/api/v1/write"
+		}
+
+		endpoint {
+			url = "%s/api/v1/write"
+		}
+
+		endpoint_probe {
+			interval = "100ms"
+			timeout  = "50ms"
+		}
+	`, srv.URL, down.URL)), &args))
+
+	c, err := New(component.Options{
+		ID:            "prometheus.remote_write.test",
+		Logger:        util.TestFlowLogger(t),
+		DataPath:      t.TempDir(),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prometheus.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	endpointUp := func(url string) func() bool {
+		return func() bool {
+			return testutil.ToFloat64(c.endpointProbe.up.WithLabelValues("", url+"/api/v1/write")) == 1
+		}
+	}
+	require.Eventually(t, endpointUp(srv.URL), 10*time.Second, 10*time.Millisecond)
+	require.False(t, endpointUp(down.URL)())
+
+	healthy.Store(false)
+	require.Eventually(t, func() bool { return !endpointUp(srv.URL)() }, 10*time.Second, 10*time.Millisecond)
+}
+
+// TestEndpointProbeAuthentication ensures that the probes are authenticated
+// and signed like the requests of the queues of the endpoints.
+func TestEndpointProbeAuthentication(t *testing.T) {
+	probes := make(chan http.Header, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			select {
+			case probes <- r.Header:
+			default:
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		endpoint {
+			name    = "tenant"
+			url     = "%[1]s/api/v1/write"
+			headers = { "X-Scope-OrgID" = "tenant" }
+
+			basic_auth {
+				username = "user"
+				password = "pass"
+			}
+		}
+
+		endpoint {
+			name = "signed"
+			url  = "%[1]s/api/v1/write"
+
+			hmac {
+				secret = "s3cr3t"
+				header = "X-Agent-Signature"
+			}
+		}
+
+		endpoint_probe {
+			interval = "1m"
+			timeout  = "5s"
+		}
+	`, srv.URL)), &args))
+
+	c, err := New(component.Options{
+		ID:            "prometheus.remote_write.test",
+		Logger:        util.TestFlowLogger(t),
+		DataPath:      t.TempDir(),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prometheus.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	var tenant, signed bool
+	for !tenant || !signed {
+		select {
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "timed out waiting for probes")
+		case header := <-probes:
+			if header.Get("X-Scope-OrgID") == "tenant" {
+				require.Equal(t, "Basic dXNlcjpwYXNz", header.Get("Authorization"))
+				tenant = true
+			}
+			if header.Get("X-Agent-Signature") != "" {
+				signed = true
+			}
+		}
+	}
+
+	// Both endpoints have the same URL, and are told apart by their name.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(c.endpointProbe.up.WithLabelValues("tenant", srv.URL+"/api/v1/write")) == 1 &&
+			testutil.ToFloat64(c.endpointProbe.up.WithLabelValues("signed", srv.URL+"/api/v1/write")) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestBadEndpointProbeConfig(t *testing.T) {
+	var args Arguments
+	require.EqualError(t, river.Unmarshal([]byte(`
+		endpoint_probe {
+			interval = "10s"
+			timeout  = "1m"
+		}
+	`), &args), "endpoint_probe timeout must be greater than 0 and at most the interval")
+}
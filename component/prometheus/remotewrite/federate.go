@@ -0,0 +1,148 @@
+package remotewrite
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/pkg/metrics/wal"
+	http_service "github.com/grafana/agent/service/http"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	_ http_service.Component           = (*Component)(nil)
+	_ prometheus.ActiveSeriesComponent = (*Component)(nil)
+)
+
+// Handler implements http_service.Component. It serves the latest samples of
+// the series in the WAL at /federate, in the same format as the Prometheus
+// federation endpoint, and at /api/v1/query for instant queries.
+func (c *Component) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/federate", c.federate)
+	mux.HandleFunc("/api/v1/query", c.query)
+	return mux
+}
+
+func (c *Component) federate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "error parsing form values: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Series are returned if they match any of the match[] selectors.
+	var selectors [][]*labels.Matcher
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		selectors = append(selectors, matchers)
+	}
+	if len(selectors) == 0 {
+		http.Error(w, "match[] parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	samples := c.latestSamples(selectors)
+
+	c.mut.RLock()
+	externalLabels := labels.FromMap(c.cfg.ExternalLabels)
+	c.mut.RUnlock()
+
+	format := expfmt.Negotiate(r.Header)
+	w.Header().Set("Content-Type", string(format))
+	enc := expfmt.NewEncoder(w, format)
+
+	for _, mf := range metricFamilies(samples, externalLabels) {
+		if err := enc.Encode(mf); err != nil {
+			level.Error(c.log).Log("msg", "federation failed", "err", err)
+			return
+		}
+	}
+}
+
+// latestSamples returns the latest samples of the series in the WAL which
+// match any of the selectors. Series whose latest sample is a staleness marker
+// are omitted.
+func (c *Component) latestSamples(selectors [][]*labels.Matcher) []wal.Sample {
+	samples := c.walStore.LatestSamples(func(l labels.Labels) bool {
+		return prometheus.MatchesAny(selectors, l)
+	})
+
+	res := samples[:0]
+	for _, s := range samples {
+		if !value.IsStaleNaN(s.V) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// ActiveSeries implements prometheus.ActiveSeriesComponent. It returns the
+// series in the WAL whose latest sample isn't a staleness marker.
+func (c *Component) ActiveSeries(match func(labels.Labels) bool) []labels.Labels {
+	var res []labels.Labels
+	for _, s := range c.walStore.LatestSamples(match) {
+		if !value.IsStaleNaN(s.V) {
+			res = append(res, s.Labels)
+		}
+	}
+	return res
+}
+
+// metricFamilies groups samples into untyped metric families sorted by name.
+// External labels are added to series which don't already have them.
+func metricFamilies(samples []wal.Sample, externalLabels labels.Labels) []*dto.MetricFamily {
+	sort.Slice(samples, func(i, j int) bool {
+		if a, b := samples[i].Labels.Get(labels.MetricName), samples[j].Labels.Get(labels.MetricName); a != b {
+			return a < b
+		}
+		return labels.Compare(samples[i].Labels, samples[j].Labels) < 0
+	})
+
+	var (
+		res []*dto.MetricFamily
+		mf  *dto.MetricFamily
+	)
+	for _, s := range samples {
+		name := s.Labels.Get(labels.MetricName)
+		if mf == nil || mf.GetName() != name {
+			mf = &dto.MetricFamily{
+				Name: proto.String(name),
+				Type: dto.MetricType_UNTYPED.Enum(),
+			}
+			res = append(res, mf)
+		}
+
+		m := &dto.Metric{
+			Untyped:     &dto.Untyped{Value: proto.Float64(s.V)},
+			TimestampMs: proto.Int64(s.T),
+		}
+		s.Labels.Range(func(l labels.Label) {
+			if l.Name == labels.MetricName {
+				return
+			}
+			m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(l.Name), Value: proto.String(l.Value)})
+		})
+		externalLabels.Range(func(l labels.Label) {
+			if s.Labels.Has(l.Name) {
+				return
+			}
+			m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(l.Name), Value: proto.String(l.Value)})
+		})
+		sort.Slice(m.Label, func(i, j int) bool {
+			return m.Label[i].GetName() < m.Label[j].GetName()
+		})
+		mf.Metric = append(mf.Metric, m)
+	}
+	return res
+}
@@ -0,0 +1,73 @@
+package remotewrite
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// queryResponse is the response of the instant query endpoint, which follows
+// the format of the Prometheus HTTP API.
+type queryResponse struct {
+	Status    string     `json:"status"`
+	Data      *queryData `json:"data,omitempty"`
+	ErrorType string     `json:"errorType,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type queryData struct {
+	ResultType string       `json:"resultType"`
+	Result     model.Vector `json:"result"`
+}
+
+// query serves instant queries against the latest samples of the series in
+// the WAL. Only series selectors are supported: queries with functions or
+// operators are rejected.
+func (c *Component) query(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		c.writeQueryResponse(w, http.StatusBadRequest, queryResponse{Status: "error", ErrorType: "bad_data", Error: "error parsing form values: " + err.Error()})
+		return
+	}
+
+	matchers, err := parser.ParseMetricSelector(r.Form.Get("query"))
+	if err != nil {
+		c.writeQueryResponse(w, http.StatusBadRequest, queryResponse{Status: "error", ErrorType: "bad_data", Error: "only series selectors are supported: " + err.Error()})
+		return
+	}
+
+	samples := c.latestSamples([][]*labels.Matcher{matchers})
+	sort.Slice(samples, func(i, j int) bool {
+		return labels.Compare(samples[i].Labels, samples[j].Labels) < 0
+	})
+
+	vector := make(model.Vector, 0, len(samples))
+	for _, s := range samples {
+		metric := make(model.Metric, s.Labels.Len())
+		s.Labels.Range(func(l labels.Label) {
+			metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		})
+		vector = append(vector, &model.Sample{
+			Metric:    metric,
+			Value:     model.SampleValue(s.V),
+			Timestamp: model.Time(s.T),
+		})
+	}
+
+	c.writeQueryResponse(w, http.StatusOK, queryResponse{
+		Status: "success",
+		Data:   &queryData{ResultType: "vector", Result: vector},
+	})
+}
+
+func (c *Component) writeQueryResponse(w http.ResponseWriter, code int, resp queryResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(c.log).Log("msg", "failed to write query response", "err", err)
+	}
+}
@@ -0,0 +1,151 @@
+package remotewrite
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// endpointRelay is a local HTTP proxy relaying the requests of the
+// remote_write queues of the endpoints with an hmac or google block, a rate
+// limit, or a batch send deadline jitter, to their endpointTransport.
+//
+// The queues create their HTTP clients themselves from the remote_write
+// configuration, which has no room for custom round trippers. The queues of
+// these endpoints are instead configured to send their requests through the
+// relay with the proxy_url setting, see endpointTransport.Update. The relay
+// only listens on the loopback interface, and only relays the requests
+// carrying the token of one of its transports as proxy credentials, so that
+// other local processes can't have their requests signed or authenticated.
+type endpointRelay struct {
+	srv  *http.Server
+	addr string
+
+	mut        sync.RWMutex
+	transports map[string]*endpointTransport // By token.
+}
+
+var _ http.Handler = (*endpointRelay)(nil)
+
+// relayReadHeaderTimeout bounds the time to read the headers of the requests
+// of the queues, which come from the same process.
+const relayReadHeaderTimeout = 10 * time.Second
+
+// newEndpointRelay starts a relay listening on a random loopback port.
+func newEndpointRelay() (*endpointRelay, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r := &endpointRelay{
+		addr:       ln.Addr().String(),
+		transports: make(map[string]*endpointTransport),
+	}
+	r.srv = &http.Server{Handler: r, ReadHeaderTimeout: relayReadHeaderTimeout}
+	go func() { _ = r.srv.Serve(ln) }()
+	return r, nil
+}
+
+// Addr returns the address the relay listens on.
+func (r *endpointRelay) Addr() string {
+	return r.addr
+}
+
+// AddTransports adds transports to the transports requests are relayed to.
+func (r *endpointRelay) AddTransports(transports map[string]*endpointTransport) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for _, t := range transports {
+		r.transports[t.token] = t
+	}
+}
+
+// SetTransports sets the transports requests are relayed to, rejecting the
+// requests of the queues of the other transports.
+func (r *endpointRelay) SetTransports(transports map[string]*endpointTransport) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.transports = make(map[string]*endpointTransport, len(transports))
+	for _, t := range transports {
+		r.transports[t.token] = t
+	}
+}
+
+// hopHeaders are the headers which only apply to the connection to the relay,
+// and aren't relayed.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ServeHTTP implements http.Handler. Requests are sent to the endpoint of the
+// transport matching their proxy credentials, whatever their URL is. Errors
+// sending a request are reported as HTTP 502 responses, which the queues
+// retry.
+func (r *endpointRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// The proxy credentials are sent as basic authentication credentials.
+	creds := &http.Request{Header: http.Header{"Authorization": req.Header.Values("Proxy-Authorization")}}
+	_, token, _ := creds.BasicAuth()
+
+	r.mut.RLock()
+	t := r.transports[token]
+	r.mut.RUnlock()
+	if token == "" || t == nil {
+		http.Error(w, "unknown relay credentials", http.StatusProxyAuthRequired)
+		return
+	}
+
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, t.URL(), req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out.Header = req.Header.Clone()
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	out.ContentLength = req.ContentLength
+
+	resp, err := t.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	for _, h := range hopHeaders {
+		w.Header().Del(h)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// Close stops the relay.
+func (r *endpointRelay) Close() error {
+	err := r.srv.Close()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// newRelayToken returns a random token identifying a transport to the relay.
+func newRelayToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,59 @@
+package remotewrite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEndpointRelay ensures that the relay only relays the requests carrying
+// the token of one of its transports, to the endpoint of that transport.
+func TestEndpointRelay(t *testing.T) {
+	paths := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	relay, err := newEndpointRelay()
+	require.NoError(t, err)
+	defer relay.Close()
+
+	transport := newEndpointTransport(&transportMetrics{
+		queues:            newBackpressureRegisterer(prometheus.NewRegistry()),
+		sendRate:          prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "send_rate"}, []string{"endpoint", "url"}),
+		batchSendDeadline: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "batch_send_deadline"}, []string{"endpoint", "url"}),
+	})
+	transport.url = srv.URL + "/api/v1/write"
+	transport.next = http.DefaultTransport
+	relay.SetTransports(map[string]*endpointTransport{"endpoint": transport})
+
+	send := func(token string) int {
+		proxy := &url.URL{Scheme: "http", Host: relay.Addr()}
+		if token != "" {
+			proxy.User = url.UserPassword("agent", token)
+		}
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxy)}}
+		resp, err := client.Post("http://example.com/other", "application/x-protobuf", strings.NewReader("body"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusProxyAuthRequired, send(""))
+	require.Equal(t, http.StatusProxyAuthRequired, send("wrong"))
+	require.Empty(t, paths)
+
+	require.Equal(t, http.StatusNoContent, send(transport.token))
+	require.Equal(t, "/api/v1/write", <-paths)
+
+	// The requests of the transports which are gone are rejected.
+	relay.SetTransports(nil)
+	require.Equal(t, http.StatusProxyAuthRequired, send(transport.token))
+}
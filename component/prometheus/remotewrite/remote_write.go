@@ -17,13 +17,17 @@ import (
 	"github.com/prometheus/prometheus/model/metadata"
 
 	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/service/labelstore"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/throughput"
 	"github.com/grafana/agent/internal/useragent"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/metrics/wal"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/storage/remote"
@@ -38,9 +42,10 @@ func init() {
 	remote.UserAgent = useragent.Get()
 
 	component.Register(component.Registration{
-		Name:    "prometheus.remote_write",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "prometheus.remote_write",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 
 		Build: func(o component.Options, c component.Arguments) (component.Component, error) {
 			return New(o, c.(Arguments))
@@ -53,10 +58,28 @@ type Component struct {
 	log  log.Logger
 	opts component.Options
 
-	walStore    *wal.Storage
-	remoteStore *remote.Storage
-	storage     storage.Storage
-	exited      atomic.Bool
+	walStore       *wal.Storage
+	remoteStore    *remote.Storage
+	storage        storage.Storage
+	deadMansSwitch *deadMansSwitch
+	endpointProbe  *endpointProbe
+	exited         atomic.Bool
+
+	transportMetrics *transportMetrics
+	droppedSamples   *prometheus_client.CounterVec
+	throughput       *throughput.Metrics
+
+	// endpointTransports sign, authenticate, jitter, and rate limit the
+	// requests of the endpoints with an hmac or google block, a rate limit,
+	// or a batch send deadline jitter, by endpoint key. The requests are relayed to them by relay, which is only
+	// started once an endpoint needs it.
+	endpointTransports map[string]*endpointTransport
+	relay              *endpointRelay
+
+	// requiredLabels holds the labels of require_labels which aren't set by
+	// external_labels. It's set apart from cfg so that appends don't wait for
+	// updates of the remote storage.
+	requiredLabels atomic.Pointer[[]string]
 
 	mut sync.RWMutex
 	cfg Arguments
@@ -82,7 +105,11 @@ func New(o component.Options, c Arguments) (*Component, error) {
 	}
 
 	remoteLogger := log.With(o.Logger, "subcomponent", "rw")
-	remoteStore := remote.NewStorage(remoteLogger, o.Registerer, startTime, o.DataPath, remoteFlushDeadline, nil)
+	remoteReg := newBackpressureRegisterer(o.Registerer)
+	if err := o.Registerer.Register(remoteReg); err != nil {
+		return nil, err
+	}
+	remoteStore := remote.NewStorage(remoteLogger, remoteReg, startTime, o.DataPath, remoteFlushDeadline, nil)
 
 	service, err := o.GetServiceData(labelstore.ServiceName)
 	if err != nil {
@@ -96,6 +123,46 @@ func New(o component.Options, c Arguments) (*Component, error) {
 		walStore:    walStorage,
 		remoteStore: remoteStore,
 		storage:     storage.NewFanout(o.Logger, walStorage, remoteStore),
+
+		endpointTransports: make(map[string]*endpointTransport),
+		transportMetrics: &transportMetrics{
+			queues: remoteReg,
+			batchSendDeadline: prometheus_client.NewGaugeVec(prometheus_client.GaugeOpts{
+				Name: "agent_prometheus_remote_write_batch_send_deadline_seconds",
+				Help: "Batch send deadline of the last flush of each endpoint, including jitter.",
+			}, []string{"endpoint", "url"}),
+			sendRate: prometheus_client.NewGaugeVec(prometheus_client.GaugeOpts{
+				Name: "agent_prometheus_remote_write_send_rate_samples_per_second",
+				Help: "Number of samples sent per second to each rate limited endpoint.",
+			}, []string{"endpoint", "url"}),
+		},
+		droppedSamples: prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+			Name: "agent_prometheus_remote_write_dropped_samples_total",
+			Help: "Total number of samples dropped before being written to the WAL, by reason.",
+		}, []string{"reason"}),
+	}
+	if err := o.Registerer.Register(res.transportMetrics.batchSendDeadline); err != nil {
+		return nil, err
+	}
+	if err := o.Registerer.Register(res.transportMetrics.sendRate); err != nil {
+		return nil, err
+	}
+	if err := o.Registerer.Register(res.droppedSamples); err != nil {
+		return nil, err
+	}
+	// The size of the samples is only known once they're sent, by the
+	// prometheus_remote_storage_bytes_total metric.
+	res.throughput, err = throughput.New(o.Registerer, throughput.SignalMetrics, false)
+	if err != nil {
+		return nil, err
+	}
+	res.deadMansSwitch, err = newDeadMansSwitch(o.Logger, o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	res.endpointProbe, err = newEndpointProbe(log.With(o.Logger, "subcomponent", "endpoint_probe"), o.Registerer)
+	if err != nil {
+		return nil, err
 	}
 	res.receiver = prometheus.NewInterceptor(
 		res.storage,
@@ -111,30 +178,47 @@ func New(o component.Options, c Arguments) (*Component, error) {
 			if res.exited.Load() {
 				return 0, fmt.Errorf("%s has exited", o.ID)
 			}
+			if res.missingRequiredLabel(l) {
+				res.droppedSamples.WithLabelValues(reasonMissingLabel).Inc()
+				return globalRef, nil
+			}
 
 			localID := ls.GetLocalRefID(res.opts.ID, uint64(globalRef))
 			newRef, nextErr := next.Append(storage.SeriesRef(localID), l, t, v)
 			if localID == 0 {
 				ls.GetOrAddLink(res.opts.ID, uint64(newRef), l)
 			}
+			if nextErr == nil {
+				res.throughput.Observe(1, 0)
+			}
 			return globalRef, nextErr
 		}),
 		prometheus.WithHistogramHook(func(globalRef storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
 			if res.exited.Load() {
 				return 0, fmt.Errorf("%s has exited", o.ID)
 			}
+			if res.missingRequiredLabel(l) {
+				res.droppedSamples.WithLabelValues(reasonMissingLabel).Inc()
+				return globalRef, nil
+			}
 
 			localID := ls.GetLocalRefID(res.opts.ID, uint64(globalRef))
 			newRef, nextErr := next.AppendHistogram(storage.SeriesRef(localID), l, t, h, fh)
 			if localID == 0 {
 				ls.GetOrAddLink(res.opts.ID, uint64(newRef), l)
 			}
+			if nextErr == nil {
+				res.throughput.Observe(1, 0)
+			}
 			return globalRef, nextErr
 		}),
 		prometheus.WithMetadataHook(func(globalRef storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
 			if res.exited.Load() {
 				return 0, fmt.Errorf("%s has exited", o.ID)
 			}
+			if res.missingRequiredLabel(l) {
+				return globalRef, nil
+			}
 
 			localID := ls.GetLocalRefID(res.opts.ID, uint64(globalRef))
 			newRef, nextErr := next.UpdateMetadata(storage.SeriesRef(localID), l, m)
@@ -147,6 +231,9 @@ func New(o component.Options, c Arguments) (*Component, error) {
 			if res.exited.Load() {
 				return 0, fmt.Errorf("%s has exited", o.ID)
 			}
+			if res.missingRequiredLabel(l) {
+				return globalRef, nil
+			}
 
 			localID := ls.GetLocalRefID(res.opts.ID, uint64(globalRef))
 			newRef, nextErr := next.AppendExemplar(storage.SeriesRef(localID), l, e)
@@ -169,10 +256,48 @@ func New(o component.Options, c Arguments) (*Component, error) {
 
 func startTime() (int64, error) { return 0, nil }
 
-var _ component.Component = (*Component)(nil)
+// reasonMissingLabel is the reason of the samples dropped because their series
+// lacks one of the required labels.
+const reasonMissingLabel = "missing_label"
+
+// missingRequiredLabel reports whether the series identified by l lacks one
+// of the labels of require_labels which aren't set by external_labels.
+func (c *Component) missingRequiredLabel(l labels.Labels) bool {
+	required := c.requiredLabels.Load()
+	if required == nil {
+		return false
+	}
+	for _, name := range *required {
+		if l.Get(name) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// setRequiredLabels sets the labels of require_labels which aren't set by
+// external_labels of cfg.
+func (c *Component) setRequiredLabels(cfg Arguments) {
+	var required []string
+	for _, name := range cfg.RequireLabels {
+		if _, external := cfg.ExternalLabels[name]; !external {
+			required = append(required, name)
+		}
+	}
+	c.requiredLabels.Store(&required)
+}
+
+var (
+	_ component.Component          = (*Component)(nil)
+	_ component.HealthComponent    = (*Component)(nil)
+	_ component.DrainableComponent = (*Component)(nil)
+)
 
 // Run implements Component.
 func (c *Component) Run(ctx context.Context) error {
+	go c.deadMansSwitch.Run(ctx, c.remoteStore.LowestSentTimestamp)
+	go c.endpointProbe.Run(ctx)
+
 	defer func() {
 		c.exited.Store(true)
 
@@ -182,6 +307,13 @@ func (c *Component) Run(ctx context.Context) error {
 		if err != nil {
 			level.Error(c.log).Log("msg", "error when closing storage", "err", err)
 		}
+
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		if c.relay != nil {
+			_ = c.relay.Close()
+			c.relay = nil
+		}
 	}()
 
 	// Track the last timestamp we truncated for to prevent segments from getting
@@ -246,6 +378,50 @@ func (c *Component) truncateFrequency() time.Duration {
 	return c.cfg.WALOptions.TruncateFrequency
 }
 
+// drainCheckFrequency is how often Drain checks whether the samples were sent.
+var drainCheckFrequency = 100 * time.Millisecond
+
+// Drain implements component.DrainableComponent. It waits for the samples
+// written to the WAL before it's called to be sent to every endpoint, which
+// happens as configured by their queue_config block.
+func (c *Component) Drain(ctx context.Context) error {
+	c.mut.RLock()
+	endpoints := len(c.cfg.Endpoints)
+	c.mut.RUnlock()
+	if endpoints == 0 {
+		return nil
+	}
+
+	// Only the samples up to the current time are waited for, so that a
+	// sample in the future, such as one from a target with a skewed clock,
+	// doesn't leave Drain waiting for samples the queues may not send.
+	var (
+		now     = timestamp.FromTime(time.Now())
+		highest = int64(math.MinInt64)
+	)
+	for _, s := range c.walStore.LatestSamples(func(labels.Labels) bool { return true }) {
+		if s.T > highest && s.T <= now {
+			highest = s.T
+		}
+	}
+	// The timestamps of the samples sent are tracked with a precision of one
+	// second.
+	highest -= highest % 1000
+
+	// The queues otherwise only periodically read the samples written to the
+	// WAL.
+	c.remoteStore.Notify()
+
+	for c.remoteStore.LowestSentTimestamp() < highest {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(drainCheckFrequency):
+		}
+	}
+	return nil
+}
+
 // Update implements Component.
 func (c *Component) Update(newConfig component.Arguments) error {
 	cfg := newConfig.(Arguments)
@@ -257,11 +433,99 @@ func (c *Component) Update(newConfig component.Arguments) error {
 	if err != nil {
 		return err
 	}
+	c.setBatchSendDeadlines(cfg)
+	transports, err := c.applyTransports(cfg, convertedConfig)
+	if err != nil {
+		return err
+	}
+	if err := c.endpointProbe.SetOptions(cfg.EndpointProbe, cfg.Endpoints, convertedConfig.RemoteWriteConfigs, transports); err != nil {
+		return err
+	}
+	// The queues being replaced may still be sending requests through the
+	// relay until the remote storage is updated.
+	if c.relay != nil {
+		c.relay.AddTransports(transports)
+	}
+	// Appends use the new required labels while the queues are replaced.
+	c.setRequiredLabels(cfg)
 	err = c.remoteStore.ApplyConfig(convertedConfig)
 	if err != nil {
 		return err
 	}
+	c.setTransports(transports)
+
+	c.deadMansSwitch.SetOptions(cfg.DeadMansSwitch)
 
 	c.cfg = cfg
 	return nil
 }
+
+// setBatchSendDeadlines reports the configured batch send deadline of the
+// endpoints. The transports of the jittered endpoints report the deadline of
+// every flush, including its jitter.
+func (c *Component) setBatchSendDeadlines(cfg Arguments) {
+	c.transportMetrics.batchSendDeadline.Reset()
+	for _, ep := range cfg.Endpoints {
+		deadline := DefaultQueueOptions.BatchSendDeadline
+		if ep.QueueOptions != nil {
+			deadline = ep.QueueOptions.BatchSendDeadline
+		}
+		c.transportMetrics.batchSendDeadline.WithLabelValues(ep.Name, ep.URL).Set(deadline.Seconds())
+	}
+}
+
+// applyTransports updates the transports of the endpoints of converted which
+// have an hmac or google block, a jitter, or a rate limit, and has their
+// queues send their requests through the relay, which is started if needed.
+// Transports are kept across updates so that their rate limits and tokens are
+// kept. The transports in use are returned by endpoint key.
+func (c *Component) applyTransports(cfg Arguments, converted *config.Config) (map[string]*endpointTransport, error) {
+	used := make(map[string]*endpointTransport)
+	for i, ep := range cfg.Endpoints {
+		var (
+			rateLimited = ep.QueueOptions != nil && ep.QueueOptions.MaxSamplesPerSecond > 0
+			jittered    = ep.QueueOptions != nil && ep.QueueOptions.BatchSendDeadlineJitter > 0
+		)
+		if ep.HMAC == nil && ep.Google == nil && !rateLimited && !jittered {
+			continue
+		}
+		if c.relay == nil {
+			relay, err := newEndpointRelay()
+			if err != nil {
+				return nil, fmt.Errorf("failed to start remote_write relay: %w", err)
+			}
+			c.relay = relay
+		}
+		key := ep.Name + "/" + ep.URL
+
+		t, ok := c.endpointTransports[key]
+		if !ok {
+			t = newEndpointTransport(c.transportMetrics)
+		}
+		if err := t.Update(ep, converted.RemoteWriteConfigs[i], c.relay.Addr()); err != nil {
+			return nil, err
+		}
+		used[key] = t
+	}
+	return used, nil
+}
+
+// setTransports records the transports in use once the remote storage is
+// updated. The relay stops relaying the requests of the transports which are
+// gone, whose queues are stopped.
+func (c *Component) setTransports(used map[string]*endpointTransport) {
+	for key, t := range c.endpointTransports {
+		if _, ok := used[key]; !ok {
+			c.transportMetrics.sendRate.DeleteLabelValues(t.Name(), t.URL())
+		}
+	}
+	c.endpointTransports = used
+	if c.relay != nil {
+		c.relay.SetTransports(used)
+	}
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	return c.deadMansSwitch.CurrentHealth()
+}
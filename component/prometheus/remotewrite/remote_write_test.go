@@ -1,21 +1,39 @@
 package remotewrite_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/prometheus/remotewrite"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
 	"github.com/grafana/river"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/storage/remote"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 // Test is an integration-level test which ensures that metrics can get sent to
@@ -89,6 +107,74 @@ func Test(t *testing.T) {
 	}
 }
 
+// TestWriteRelabelPerEndpoint ensures that the write relabeling rules of an
+// endpoint only apply to the series sent to that endpoint.
+func TestWriteRelabelPerEndpoint(t *testing.T) {
+	var (
+		droppingResult = make(chan *prompb.WriteRequest, 10)
+		keepingResult  = make(chan *prompb.WriteRequest, 10)
+	)
+	dropping := newTestServer(t, droppingResult)
+	defer dropping.Close()
+	keeping := newTestServer(t, keepingResult)
+	defer keeping.Close()
+
+	args := testArgsForConfig(t, fmt.Sprintf(`
+		endpoint {
+			name           = "dropping"
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+
+			write_relabel_config {
+				source_labels = ["__name__"]
+				regex         = "dropped_metric"
+				action        = "drop"
+			}
+		}
+
+		endpoint {
+			name           = "keeping"
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+		}
+	`, dropping.URL, keeping.URL))
+	tc, err := componenttest.NewControllerFromID(util.TestLogger(t), "prometheus.remote_write")
+	require.NoError(t, err)
+	go func() {
+		err = tc.Run(componenttest.TestContext(t), args)
+		require.NoError(t, err)
+	}()
+	require.NoError(t, tc.WaitRunning(5*time.Second))
+
+	sampleTimestamp := time.Now().Add(time.Minute).UnixMilli()
+
+	rwExports := tc.Exports().(remotewrite.Exports)
+	app := rwExports.Receiver.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "dropped_metric"), sampleTimestamp, 12)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "kept_metric"), sampleTimestamp, 34)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	keptSeries := prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "kept_metric"}},
+		Samples: []prompb.Sample{{Timestamp: sampleTimestamp, Value: 34}},
+	}
+	assertReceived(t, droppingResult, []prompb.TimeSeries{keptSeries})
+	assertReceived(t, keepingResult, []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "dropped_metric"}},
+		Samples: []prompb.Sample{{Timestamp: sampleTimestamp, Value: 12}},
+	}, keptSeries})
+}
+
 func TestUpdate(t *testing.T) {
 	writeResult := make(chan *prompb.WriteRequest)
 
@@ -181,6 +267,757 @@ func TestUpdate(t *testing.T) {
 	}})
 }
 
+// TestBatchSendDeadlineJitter ensures that every flush of an endpoint is
+// delayed by its own random delay of up to the configured jitter, without
+// losing samples.
+func TestBatchSendDeadlineJitter(t *testing.T) {
+	type request struct {
+		at      time.Time
+		samples int
+	}
+	requests := make(chan request, 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		at := time.Now()
+		req, err := remote.DecodeWriteRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var samples int
+		for _, ts := range req.Timeseries {
+			samples += len(ts.Samples)
+		}
+		if samples > 0 {
+			requests <- request{at: at, samples: samples}
+		}
+	}))
+	defer srv.Close()
+
+	const (
+		deadline = 100 * time.Millisecond
+		jitter   = 100 * time.Millisecond
+	)
+	// A single shard flushes on its deadline, as its batches never fill up.
+	c, exports, reg := runComponent(t, fmt.Sprintf(`
+		endpoint {
+			name = "jittered"
+			url  = "%s/api/v1/write"
+
+			queue_config {
+				min_shards                 = 1
+				max_shards                 = 1
+				batch_send_deadline        = "%s"
+				batch_send_deadline_jitter = "%s"
+			}
+		}
+	`, srv.URL, deadline, jitter))
+
+	// Samples are appended continuously, so that every flush has samples.
+	const flushes = 20
+	var (
+		appended = atomic.NewInt64(0)
+		stop     = make(chan struct{})
+		done     = make(chan struct{})
+	)
+	// Draining with a cancelled context only notifies the queues of the new
+	// samples, which they otherwise read from the WAL every 15 seconds.
+	notify, cancel := context.WithCancel(context.Background())
+	cancel()
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				appendSample(t, exports, labels.FromStrings("series", "jitter"), time.Now().UnixMilli(), 1)
+				appended.Inc()
+				_ = c.Drain(notify)
+			}
+		}
+	}()
+
+	var (
+		received  int64
+		intervals []time.Duration
+		last      time.Time
+	)
+	for len(intervals) < flushes {
+		select {
+		case <-time.After(time.Minute):
+			require.FailNow(t, "timed out waiting for flushes", "got %d intervals", len(intervals))
+		case req := <-requests:
+			received += int64(req.samples)
+			// The first requests send the samples appended before the queue
+			// started.
+			if !last.IsZero() && received > 10 {
+				intervals = append(intervals, req.at.Sub(last))
+			}
+			last = req.at
+		}
+	}
+	close(stop)
+	<-done
+	_ = c.Drain(notify)
+
+	var minInterval, maxInterval time.Duration
+	for i, interval := range intervals {
+		// Every interval is the deadline plus the delay of the flush, and the
+		// time taken to send the request.
+		require.GreaterOrEqual(t, interval, deadline-10*time.Millisecond, "interval %d", i)
+		require.LessOrEqual(t, interval, deadline+jitter+50*time.Millisecond, "interval %d", i)
+		if i == 0 || interval < minInterval {
+			minInterval = interval
+		}
+		if interval > maxInterval {
+			maxInterval = interval
+		}
+	}
+	require.Greater(t, maxInterval-minInterval, jitter/5, "the flush intervals should vary with the jitter, got %v", intervals)
+
+	// Every appended sample is still sent.
+	for received < appended.Load() {
+		select {
+		case <-time.After(time.Minute):
+			require.FailNow(t, "timed out waiting for samples", "received %d of %d samples", received, appended.Load())
+		case req := <-requests:
+			received += int64(req.samples)
+		}
+	}
+	require.Equal(t, appended.Load(), received)
+
+	// The deadline of the last flush is reported.
+	got := gatherGauge(t, reg, "agent_prometheus_remote_write_batch_send_deadline_seconds")
+	require.GreaterOrEqual(t, got, deadline.Seconds())
+	require.LessOrEqual(t, got, (deadline + jitter).Seconds())
+}
+
+// TestMaxPendingSamples ensures that the number of samples pending to be sent
+// stays bounded while the endpoint is unavailable, and that all the samples
+// are sent once it recovers.
+func TestMaxPendingSamples(t *testing.T) {
+	var (
+		stalled  = atomic.NewBool(true)
+		received = atomic.NewInt64(0)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stalled.Load() {
+			http.Error(w, "stalled", http.StatusServiceUnavailable)
+			return
+		}
+		req, err := remote.DecodeWriteRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, series := range req.Timeseries {
+			received.Add(int64(len(series.Samples)))
+		}
+	}))
+	defer srv.Close()
+
+	_, exports, reg := runComponent(t, fmt.Sprintf(`
+		endpoint {
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+				min_backoff         = "10ms"
+				max_backoff         = "100ms"
+				max_pending_samples = 30
+			}
+		}
+	`, srv.URL))
+
+	ts := time.Now().Add(time.Minute).UnixMilli()
+	app := exports.Receiver.Appender(context.Background())
+	for i := 0; i < 300; i++ {
+		_, err := app.Append(0, labels.FromStrings("series", fmt.Sprint(i)), ts, float64(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	// The WAL reader should be blocked by the full shards, without the
+	// pending samples going over the limit.
+	var maxPending float64
+	require.Eventually(t, func() bool {
+		maxPending = math.Max(maxPending, gatherValue(t, reg, "prometheus_remote_storage_samples_pending"))
+		return gatherValue(t, reg, "agent_prometheus_remote_write_backpressure_total") > 0
+	}, time.Minute, 10*time.Millisecond)
+	require.LessOrEqual(t, maxPending, 30.0)
+	require.Zero(t, received.Load())
+
+	stalled.Store(false)
+	require.Eventually(t, func() bool {
+		return received.Load() == 300
+	}, time.Minute, 10*time.Millisecond)
+}
+
+// TestMaxSamplesPerSecond ensures that the samples sent to an endpoint with
+// a rate limit are held in the WAL until they fit within the limit, rather
+// than dropped.
+func TestMaxSamplesPerSecond(t *testing.T) {
+	type sent struct {
+		at      time.Time
+		samples int
+	}
+	requests := make(chan sent, 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := remote.DecodeWriteRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var n int
+		for _, series := range req.Timeseries {
+			n += len(series.Samples)
+		}
+		requests <- sent{at: time.Now(), samples: n}
+	}))
+	defer srv.Close()
+
+	_, exports, reg := runComponent(t, fmt.Sprintf(`
+		endpoint {
+			url = "%s/api/v1/write"
+
+			queue_config {
+				batch_send_deadline    = "100ms"
+				max_samples_per_send   = 50
+				max_samples_per_second = 200
+			}
+		}
+	`, srv.URL))
+
+	const total = 800
+	ts := time.Now().Add(time.Minute).UnixMilli()
+	app := exports.Receiver.Appender(context.Background())
+	for i := 0; i < total; i++ {
+		_, err := app.Append(0, labels.FromStrings("series", fmt.Sprint(i)), ts, float64(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	// Apart from the initial burst of one second worth of samples, samples
+	// are sent at most at the configured rate.
+	var (
+		start    time.Time
+		received int
+	)
+	for received < total {
+		select {
+		case <-time.After(time.Minute):
+			require.FailNow(t, "timed out waiting for metrics", "received %d samples", received)
+		case req := <-requests:
+			if start.IsZero() {
+				start = req.at
+			}
+			received += req.samples
+			allowed := 200 + 200*req.at.Sub(start).Seconds()
+			require.LessOrEqual(t, float64(received), allowed+50, "samples were sent faster than the limit")
+		}
+	}
+	require.Equal(t, total, received, "all samples must be sent")
+	require.GreaterOrEqual(t, time.Since(start), 2*time.Second, "samples were sent faster than the limit")
+
+	// The send rate is measured over windows of at least a second, which may
+	// end with one more batch.
+	rate := gatherGauge(t, reg, "agent_prometheus_remote_write_send_rate_samples_per_second")
+	require.Greater(t, rate, 0.0)
+	require.LessOrEqual(t, rate, 200.0+50)
+}
+
+// TestHMACSignature ensures that requests sent to an endpoint with an hmac
+// block are signed with the configured secret.
+func TestHMACSignature(t *testing.T) {
+	type signedRequest struct {
+		body      []byte
+		signature string
+	}
+	requests := make(chan signedRequest, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- signedRequest{body: body, signature: r.Header.Get("X-Agent-Signature")}
+	}))
+	defer srv.Close()
+
+	_, exports, reg := runComponent(t, fmt.Sprintf(`
+		endpoint {
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+
+			hmac {
+				secret = "s3cr3t"
+				header = "X-Agent-Signature"
+			}
+		}
+	`, srv.URL))
+
+	appendSample(t, exports, labels.FromStrings("foo", "bar"), time.Now().Add(time.Minute).UnixMilli(), 1)
+
+	var req signedRequest
+	select {
+	case <-time.After(time.Minute):
+		require.FailNow(t, "timed out waiting for metrics")
+	case req = <-requests:
+	}
+
+	_, err := remote.DecodeWriteRequest(bytes.NewReader(req.body))
+	require.NoError(t, err, "the body must be forwarded as is")
+
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write(req.body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	require.True(t, hmac.Equal([]byte(sign("s3cr3t")), []byte(req.signature)), "signature should validate against the secret")
+	require.False(t, hmac.Equal([]byte(sign("wrong")), []byte(req.signature)), "signature should not validate against another secret")
+
+	// The queue sends its requests to the endpoint itself.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != "prometheus_remote_storage_samples_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "url" {
+					require.Equal(t, srv.URL+"/api/v1/write", l.GetValue())
+				}
+			}
+		}
+	}
+}
+
+// TestEnableHTTP2 ensures that remote_write negotiates HTTP/2 with endpoints
+// supporting it only when enable_http2 is enabled, including when requests go
+// through the endpoint transport.
+func TestEnableHTTP2(t *testing.T) {
+	tests := []struct {
+		name     string
+		extra    string
+		expected string
+	}{
+		{name: "enabled", extra: `enable_http2 = true`, expected: "HTTP/2.0"},
+		{name: "disabled", extra: `enable_http2 = false`, expected: "HTTP/1.1"},
+		{name: "enabled with hmac", extra: `hmac { secret = "s3cr3t" }`, expected: "HTTP/2.0"},
+		{name: "disabled with hmac", extra: `
+			enable_http2 = false
+			hmac { secret = "s3cr3t" }`, expected: "HTTP/1.1"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			protos := make(chan string, 10)
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case protos <- r.Proto:
+				default:
+				}
+			}))
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			defer srv.Close()
+
+			_, exports, _ := runComponent(t, fmt.Sprintf(`
+		endpoint {
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+			%s
+
+			tls_config {
+				insecure_skip_verify = true
+			}
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+		}
+	`, srv.URL, tc.extra))
+
+			appendSample(t, exports, labels.FromStrings("foo", "bar"), time.Now().Add(time.Minute).UnixMilli(), 1)
+
+			select {
+			case <-time.After(time.Minute):
+				require.FailNow(t, "timed out waiting for metrics")
+			case proto := <-protos:
+				require.Equal(t, tc.expected, proto)
+			}
+		})
+	}
+}
+
+// TestSigV4 ensures that requests sent to an endpoint with a sigv4 block are
+// signed with AWS Signature Version 4, using credentials from the config or
+// from the environment.
+func TestSigV4(t *testing.T) {
+	tests := []struct {
+		name      string
+		sigv4     string
+		env       map[string]string
+		accessKey string
+	}{
+		{
+			name: "static credentials",
+			sigv4: `
+				region     = "us-east-1"
+				access_key = "static_access_key"
+				secret_key = "static_secret_key"
+			`,
+			accessKey: "static_access_key",
+		},
+		{
+			name:  "environment credentials",
+			sigv4: `region = "eu-west-1"`,
+			env: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "env_access_key",
+				"AWS_SECRET_ACCESS_KEY": "env_secret_key",
+			},
+			accessKey: "env_access_key",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			headers := make(chan http.Header, 10)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers <- r.Header.Clone()
+			}))
+			defer srv.Close()
+
+			_, exports, _ := runComponent(t, fmt.Sprintf(`
+				endpoint {
+					url            = "%s/api/v1/write"
+					remote_timeout = "100ms"
+
+					queue_config {
+						batch_send_deadline = "100ms"
+					}
+
+					sigv4 {
+						%s
+					}
+				}
+			`, srv.URL, tc.sigv4))
+
+			appendSample(t, exports, labels.FromStrings("foo", "bar"), time.Now().Add(time.Minute).UnixMilli(), 1)
+
+			var h http.Header
+			select {
+			case <-time.After(time.Minute):
+				require.FailNow(t, "timed out waiting for metrics")
+			case h = <-headers:
+			}
+
+			expect := `^AWS4-HMAC-SHA256 Credential=` + tc.accessKey + `/\d{8}/[a-z0-9-]+/aps/aws4_request, SignedHeaders=[a-z0-9;-]+, Signature=[0-9a-f]{64}$`
+			require.Regexp(t, expect, h.Get("Authorization"))
+			require.NotEmpty(t, h.Get("X-Amz-Date"))
+		})
+	}
+}
+
+// TestGoogleAuth ensures that requests sent to an endpoint with a google
+// block carry an access token obtained with the Google credentials, and that
+// the token is refreshed before it expires.
+func TestGoogleAuth(t *testing.T) {
+	var tokens atomic.Int64
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// The token expires within the early refresh window of the token
+		// source, so a new token is requested for each request.
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 5}`, tokens.Inc())
+	}))
+	defer tokenSrv.Close()
+
+	creds := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(creds, []byte(fmt.Sprintf(`{
+		"type": "authorized_user",
+		"client_id": "fake-client-id",
+		"client_secret": "fake-client-secret",
+		"refresh_token": "fake-refresh-token",
+		"token_uri": "%s/token"
+	}`, tokenSrv.URL)), 0600))
+
+	var requests atomic.Int64
+	authorizations := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		// Fail the first request so that it's retried with a new token.
+		if requests.Inc() == 1 {
+			http.Error(w, "try again", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	_, exports, _ := runComponent(t, fmt.Sprintf(`
+		endpoint {
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+				min_backoff         = "10ms"
+			}
+
+			google {
+				credentials_file = "%s"
+			}
+		}
+	`, srv.URL, creds))
+
+	appendSample(t, exports, labels.FromStrings("foo", "bar"), time.Now().Add(time.Minute).UnixMilli(), 1)
+
+	for _, expect := range []string{"Bearer token-1", "Bearer token-2"} {
+		select {
+		case <-time.After(time.Minute):
+			require.FailNow(t, "timed out waiting for metrics")
+		case authorization := <-authorizations:
+			require.Equal(t, expect, authorization)
+		}
+	}
+}
+
+// TestFederate ensures that the latest samples of the series in the WAL can
+// be federated.
+func TestFederate(t *testing.T) {
+	c, exports, _ := runComponent(t, `
+		external_labels = {
+			cluster = "local",
+		}
+	`)
+
+	app := exports.Receiver.Appender(context.Background())
+	for _, s := range []struct {
+		labels labels.Labels
+		ts     int64
+		value  float64
+	}{
+		{labels.FromStrings("__name__", "up", "job", "agent", "instance", "a"), 1000, 0},
+		{labels.FromStrings("__name__", "up", "job", "agent", "instance", "a"), 2000, 1},
+		{labels.FromStrings("__name__", "agent_build_info", "job", "agent", "version", "v1"), 2000, 1},
+		{labels.FromStrings("__name__", "up", "job", "other"), 2000, 1},
+		{labels.FromStrings("__name__", "node_cpu", "job", "node"), 2000, 5},
+		{labels.FromStrings("__name__", "node_cpu", "job", "gone"), 1000, 3},
+		{labels.FromStrings("__name__", "node_cpu", "job", "gone"), 2000, math.Float64frombits(value.StaleNaN)},
+	} {
+		_, err := app.Append(0, s.labels, s.ts, s.value)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+
+	t.Run("multiple selectors", func(t *testing.T) {
+		query := url.Values{"match[]": []string{`{job="agent"}`, `node_cpu`}}
+		resp, err := http.Get(srv.URL + "/federate?" + query.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		expect := `# TYPE agent_build_info untyped
+agent_build_info{cluster="local",job="agent",version="v1"} 1 2000
+# TYPE node_cpu untyped
+node_cpu{cluster="local",job="node"} 5 2000
+# TYPE up untyped
+up{cluster="local",instance="a",job="agent"} 1 2000
+`
+		require.Equal(t, expect, string(body))
+	})
+
+	t.Run("missing selector", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/federate")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/federate?" + url.Values{"match[]": []string{`{job=`}}.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+// TestQuery ensures that instant queries return the latest samples of the
+// series in the WAL.
+func TestQuery(t *testing.T) {
+	c, exports, reg := runComponent(t, "")
+
+	// Simulate a self-scrape of the component, so that its own metrics end up
+	// in the WAL.
+	appendSample(t, exports, labels.FromStrings("__name__", "up", "job", "agent"), 1000, 1)
+	activeSeries := gatherGauge(t, reg, "agent_wal_storage_active_series")
+	require.NotZero(t, activeSeries)
+	appendSample(t, exports, labels.FromStrings("__name__", "agent_wal_storage_active_series", "job", "agent"), 2000, activeSeries)
+
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+
+	query := func(t *testing.T, q string) (int, map[string]interface{}) {
+		resp, err := http.Get(srv.URL + "/api/v1/query?" + url.Values{"query": []string{q}}.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	t.Run("selector", func(t *testing.T) {
+		code, body := query(t, `agent_wal_storage_active_series{job="agent"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result": []interface{}{
+					map[string]interface{}{
+						"metric": map[string]interface{}{"__name__": "agent_wal_storage_active_series", "job": "agent"},
+						"value":  []interface{}{2.0, strconv.FormatFloat(activeSeries, 'f', -1, 64)},
+					},
+				},
+			},
+		}, body)
+	})
+
+	t.Run("unsupported expression", func(t *testing.T) {
+		code, body := query(t, `sum(agent_wal_storage_active_series)`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "error", body["status"])
+		require.Equal(t, "bad_data", body["errorType"])
+	})
+}
+
+// TestRequireLabels ensures that the series lacking a required label are
+// dropped and counted, while the other series are sent.
+func TestRequireLabels(t *testing.T) {
+	writeResult := make(chan *prompb.WriteRequest, 10)
+	srv := newTestServer(t, writeResult)
+	defer srv.Close()
+
+	_, exports, reg := runComponent(t, fmt.Sprintf(`
+		external_labels = {
+			region = "eu",
+		}
+		require_labels = ["cluster", "region"]
+
+		endpoint {
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+		}
+	`, srv.URL))
+
+	ts := time.Now().Add(time.Minute).UnixMilli()
+	app := exports.Receiver.Appender(context.Background())
+	_, err := app.Append(0, labels.FromStrings("job", "incomplete"), ts, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("cluster", "prod", "job", "complete"), ts, 2)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	// The region label is set by external_labels.
+	assertReceived(t, writeResult, []prompb.TimeSeries{{
+		Labels: []prompb.Label{
+			{Name: "cluster", Value: "prod"},
+			{Name: "job", Value: "complete"},
+			{Name: "region", Value: "eu"},
+		},
+		Samples: []prompb.Sample{{Timestamp: ts, Value: 2}},
+	}})
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP agent_prometheus_remote_write_dropped_samples_total Total number of samples dropped before being written to the WAL, by reason.
+# TYPE agent_prometheus_remote_write_dropped_samples_total counter
+agent_prometheus_remote_write_dropped_samples_total{reason="missing_label"} 1
+`), "agent_prometheus_remote_write_dropped_samples_total"))
+}
+
+// runComponent runs a prometheus.remote_write component configured by cfg
+// until the test ends. The metrics of the component are registered to the
+// returned registry.
+func runComponent(t *testing.T, cfg string) (*remotewrite.Component, remotewrite.Exports, *prometheus_client.Registry) {
+	var (
+		reg     = prometheus_client.NewRegistry()
+		exports remotewrite.Exports
+	)
+	c, err := remotewrite.New(component.Options{
+		ID:            "prometheus.remote_write.test",
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    reg,
+		DataPath:      t.TempDir(),
+		OnStateChange: func(e component.Exports) { exports = e.(remotewrite.Exports) },
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, testArgsForConfig(t, cfg))
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+	return c, exports, reg
+}
+
+func appendSample(t *testing.T, exports remotewrite.Exports, l labels.Labels, ts int64, v float64) {
+	app := exports.Receiver.Appender(context.Background())
+	_, err := app.Append(0, l, ts, v)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+}
+
+func gatherGauge(t *testing.T, reg prometheus_client.Gatherer, name string) float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() == name {
+			return mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	require.FailNow(t, "metric not found", name)
+	return 0
+}
+
+// gatherValue returns the sum of the values of the counter or gauge name, or
+// 0 if it doesn't exist yet.
+func gatherValue(t *testing.T, reg prometheus_client.Gatherer, name string) float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var res float64
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			res += m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return res
+}
+
 func assertReceived(t *testing.T, writeResult chan *prompb.WriteRequest, expect []prompb.TimeSeries) {
 	select {
 	case <-time.After(time.Minute):
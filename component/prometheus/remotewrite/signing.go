@@ -0,0 +1,63 @@
+package remotewrite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/grafana/river/rivertypes"
+)
+
+// Supported HMAC algorithms.
+const (
+	HMACAlgorithmSHA256 = "sha256"
+	HMACAlgorithmSHA512 = "sha512"
+)
+
+// DefaultHMACConfig holds default settings for HMACConfig.
+var DefaultHMACConfig = HMACConfig{
+	Header:    "X-Signature",
+	Algorithm: HMACAlgorithmSHA256,
+}
+
+// HMACConfig configures the signature of the requests sent to an endpoint.
+type HMACConfig struct {
+	Secret    rivertypes.Secret `river:"secret,attr"`
+	Header    string            `river:"header,attr,optional"`
+	Algorithm string            `river:"algorithm,attr,optional"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (h *HMACConfig) SetToDefault() {
+	*h = DefaultHMACConfig
+}
+
+// Validate implements river.Validator.
+func (h *HMACConfig) Validate() error {
+	switch {
+	case h.Secret == "":
+		return errors.New("hmac secret must not be empty")
+	case h.Header == "":
+		return errors.New("hmac header must not be empty")
+	case h.Algorithm != HMACAlgorithmSHA256 && h.Algorithm != HMACAlgorithmSHA512:
+		return fmt.Errorf("unsupported hmac algorithm %q, must be %q or %q", h.Algorithm, HMACAlgorithmSHA256, HMACAlgorithmSHA512)
+	}
+	return nil
+}
+
+// Sign returns the signature of body, as sent in the signature header: the
+// name of the algorithm followed by the hex-encoded HMAC of body, for example
+// sha256=<hex>.
+func (h *HMACConfig) Sign(body []byte) string {
+	newHash := sha256.New
+	if h.Algorithm == HMACAlgorithmSHA512 {
+		newHash = func() hash.Hash { return sha512.New() }
+	}
+	mac := hmac.New(newHash, []byte(h.Secret))
+	_, _ = mac.Write(body)
+	return h.Algorithm + "=" + hex.EncodeToString(mac.Sum(nil))
+}
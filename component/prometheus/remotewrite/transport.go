@@ -0,0 +1,371 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	types "github.com/grafana/agent/component/common/config"
+	"github.com/prometheus/client_golang/prometheus"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage/remote"
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v2"
+)
+
+// endpointTransport signs, authenticates, jitters, and rate limits the
+// requests of the remote_write queue of an endpoint, which are relayed to it
+// by the endpointRelay. It sends them to the endpoint with a client created
+// from the full configuration of the endpoint.
+//
+// Rate limited requests are held until they fit within the limit. This keeps
+// the shards of the queue busy, so that samples are buffered in the WAL
+// rather than dropped.
+//
+// Jittered requests are held for a random delay. A shard only restarts the
+// batch send deadline of its next flush once its request completes, so every
+// flush of the shard is delayed by its own random delay.
+type endpointTransport struct {
+	// token identifies the transport to the relay.
+	token   string
+	metrics *transportMetrics
+
+	// limiter is nil when the requests aren't rate limited. It's kept across
+	// updates so that the rate of the endpoint is kept when the limit
+	// changes.
+	limiter *rate.Limiter
+
+	// The send rate is measured over windows of at least sendRateWindow.
+	// sent is the number of samples the queue sent when the last request
+	// was rate limited.
+	rateMut     sync.Mutex
+	rateStart   time.Time
+	rateSamples int
+	sent        float64
+
+	mut      sync.RWMutex
+	name     string
+	url      string
+	queue    queueKey
+	next     http.RoundTripper
+	hmac     *HMACConfig
+	deadline time.Duration
+	jitter   time.Duration
+
+	// google and googleTokens are kept across updates so that cached tokens
+	// aren't discarded when the credentials don't change.
+	google       *types.GoogleCredentials
+	googleTokens oauth2.TokenSource
+}
+
+var _ http.RoundTripper = (*endpointTransport)(nil)
+
+// sendRateWindow is the minimum period the send rate of an endpointTransport
+// is measured over.
+const sendRateWindow = time.Second
+
+// transportMetrics holds the metrics of the endpoint transports, labeled by
+// the name and URL of their endpoint.
+type transportMetrics struct {
+	// queues counts the samples sent by the queues, which rate limited
+	// transports are limited by.
+	queues *backpressureRegisterer
+	// sendRate reports the number of samples sent per second by rate limited
+	// transports.
+	sendRate *prometheus.GaugeVec
+	// batchSendDeadline reports the batch send deadline of the last flush of
+	// each endpoint, including its jitter.
+	batchSendDeadline *prometheus.GaugeVec
+}
+
+func newEndpointTransport(metrics *transportMetrics) *endpointTransport {
+	return &endpointTransport{
+		token:     newRelayToken(),
+		metrics:   metrics,
+		rateStart: time.Now(),
+	}
+}
+
+// Update sets the endpoint the requests are sent to, and creates the client
+// they're sent with from the settings of rw. rw is then changed so that the
+// queue created from it sends its requests through the relay listening on
+// relayAddr: its requests are sent to the endpoint over plain HTTP, so that
+// the relay can read them, and through the relay as a proxy.
+func (t *endpointTransport) Update(ep *EndpointOptions, rw *config.RemoteWriteConfig, relayAddr string) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	googleTokens := t.googleTokens
+	if ep.Google == nil {
+		googleTokens = nil
+	} else if googleTokens == nil || !reflect.DeepEqual(t.google, ep.Google) {
+		var err error
+		googleTokens, err = ep.Google.TokenSource(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	client, err := newRemoteClient(rw)
+	if err != nil {
+		return err
+	}
+
+	var (
+		maxSamplesPerSecond float64
+		deadline            = DefaultQueueOptions.BatchSendDeadline
+		jitter              time.Duration
+	)
+	if ep.QueueOptions != nil {
+		maxSamplesPerSecond = ep.QueueOptions.MaxSamplesPerSecond
+		deadline = ep.QueueOptions.BatchSendDeadline
+		jitter = ep.QueueOptions.BatchSendDeadlineJitter
+	}
+	switch {
+	case maxSamplesPerSecond <= 0:
+		t.limiter = nil
+	case t.limiter == nil:
+		t.limiter = rate.NewLimiter(rate.Limit(maxSamplesPerSecond), rateBurst(ep.QueueOptions))
+	default:
+		t.limiter.SetLimit(rate.Limit(maxSamplesPerSecond))
+		t.limiter.SetBurst(rateBurst(ep.QueueOptions))
+	}
+
+	// The queue is named like the remote storage names it, so that the
+	// samples it sends can be told apart from the samples sent by the
+	// queues of the endpoints with the same URL. The name is picked before
+	// the configuration is changed, as it would otherwise change with the
+	// token of the transport.
+	if rw.Name == "" {
+		name, err := remoteName(rw)
+		if err != nil {
+			return err
+		}
+		rw.Name = name
+	}
+
+	t.name = ep.Name
+	t.url = ep.URL
+	t.next = client.Client.Transport
+	t.hmac = ep.HMAC
+	t.deadline = deadline
+	t.jitter = jitter
+	t.google = ep.Google
+	t.googleTokens = googleTokens
+
+	relayURL := *rw.URL.URL
+	relayURL.Scheme = "http"
+	rw.URL = &common.URL{URL: &relayURL}
+	rw.HTTPClientConfig = common.DefaultHTTPClientConfig
+	rw.HTTPClientConfig.ProxyURL = common.URL{URL: &url.URL{
+		Scheme: "http",
+		User:   url.UserPassword("agent", t.token),
+		Host:   relayAddr,
+	}}
+	rw.SigV4Config = nil
+	rw.AzureADConfig = nil
+	rw.Headers = nil
+	t.queue = queueKey{remoteName: rw.Name, url: relayURL.String()}
+	return nil
+}
+
+// newRemoteClient creates the client the remote storage would send the
+// requests of the queue of rw with, which signs and authenticates them with
+// sigv4 or azuread, and adds the configured headers.
+func newRemoteClient(rw *config.RemoteWriteConfig) (*remote.Client, error) {
+	wc, err := remote.NewWriteClient(rw.Name, &remote.ClientConfig{
+		URL:              rw.URL,
+		Timeout:          rw.RemoteTimeout,
+		HTTPClientConfig: rw.HTTPClientConfig,
+		SigV4Config:      rw.SigV4Config,
+		AzureADConfig:    rw.AzureADConfig,
+		Headers:          rw.Headers,
+		RetryOnRateLimit: rw.QueueConfig.RetryOnRateLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, ok := wc.(*remote.Client)
+	if !ok {
+		return nil, fmt.Errorf("unexpected remote_write client type %T", wc)
+	}
+	return client, nil
+}
+
+// remoteName returns the name the remote storage gives to the queue of rw
+// when it has none: the beginning of the hash of its configuration.
+func remoteName(rw *config.RemoteWriteConfig) (string, error) {
+	b, err := yaml.Marshal(rw)
+	if err != nil {
+		return "", err
+	}
+	hash := md5.Sum(b)
+	return hex.EncodeToString(hash[:])[:6], nil
+}
+
+// Name returns the name of the endpoint.
+func (t *endpointTransport) Name() string {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.name
+}
+
+// URL returns the URL of the endpoint.
+func (t *endpointTransport) URL() string {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.url
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mut.RLock()
+	var (
+		name     = t.name
+		url      = t.url
+		limiter  = t.limiter
+		queue    = t.queue
+		deadline = t.deadline
+		jitter   = t.jitter
+	)
+	t.mut.RUnlock()
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if jitter > 0 {
+		delay := time.Duration(rand.Int63n(int64(jitter) + 1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		t.metrics.batchSendDeadline.WithLabelValues(name, url).Set((deadline + delay).Seconds())
+	}
+
+	var samples int
+	if limiter != nil {
+		samples = t.unsentSamples(queue)
+	}
+	if samples > 0 {
+		// Batches are never larger than the burst, unless the queue
+		// configuration changed while the request was pending.
+		if err := limiter.WaitN(req.Context(), min(samples, limiter.Burst())); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.send(req, body)
+	if err == nil && samples > 0 && resp.StatusCode/100 == 2 {
+		t.recordSent(name, url, samples)
+	}
+	return resp, err
+}
+
+// send signs and authenticates req, whose body was read to body, and sends
+// it to the endpoint with the client of the endpoint.
+func (t *endpointTransport) send(req *http.Request, body []byte) (*http.Response, error) {
+	t.mut.RLock()
+	next, hmac := t.next, t.hmac
+	if t.googleTokens != nil {
+		next = &oauth2.Transport{Source: t.googleTokens, Base: next}
+	}
+	t.mut.RUnlock()
+
+	// Round trippers must not modify the requests they're given.
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	if hmac != nil {
+		req.Header.Set(hmac.Header, hmac.Sign(body))
+	}
+	return next.RoundTrip(req)
+}
+
+// Unlimited returns a round tripper sending requests to the endpoint like the
+// transport, but without delaying or rate limiting them, for the requests
+// which don't come from the queue.
+func (t *endpointTransport) Unlimited() http.RoundTripper {
+	return unlimitedTransport{t: t}
+}
+
+type unlimitedTransport struct {
+	t *endpointTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (u unlimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	return u.t.send(req, body)
+}
+
+// readBody reads and closes the body of req, which may be nil.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	return body, err
+}
+
+// unsentSamples returns the number of samples the queue started sending since
+// the last rate limited request. The queue counts the samples of a request
+// right before sending it, so these are the samples of this request, or also
+// of the concurrent requests of other shards, in which case they're only
+// accounted for once. Samples aren't read from the requests themselves, which
+// would take decoding all of them.
+func (t *endpointTransport) unsentSamples(queue queueKey) int {
+	sent := t.metrics.queues.SentSamples(queue)
+
+	t.rateMut.Lock()
+	defer t.rateMut.Unlock()
+	n := sent - t.sent
+	if n < 0 {
+		// The queue was recreated and started counting again.
+		n = sent
+	}
+	t.sent = sent
+	return int(n)
+}
+
+// recordSent records that n samples were sent, updating the send rate once
+// the current window is over.
+func (t *endpointTransport) recordSent(name, url string, n int) {
+	t.rateMut.Lock()
+	defer t.rateMut.Unlock()
+
+	t.rateSamples += n
+	if elapsed := time.Since(t.rateStart); elapsed >= sendRateWindow {
+		t.metrics.sendRate.WithLabelValues(name, url).Set(float64(t.rateSamples) / elapsed.Seconds())
+		t.rateStart = time.Now()
+		t.rateSamples = 0
+	}
+}
+
+// rateBurst returns the burst of the rate limit of the queue q, which must
+// allow for full batches to be sent.
+func rateBurst(q *QueueOptions) int {
+	return max(int(math.Ceil(q.MaxSamplesPerSecond)), q.MaxSamplesPerSend)
+}
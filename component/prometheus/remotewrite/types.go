@@ -10,14 +10,12 @@ import (
 	flow_relabel "github.com/grafana/agent/component/common/relabel"
 	"github.com/grafana/river/rivertypes"
 
-	"github.com/google/uuid"
 	common "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	promsigv4 "github.com/prometheus/common/sigv4"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
-	"github.com/prometheus/prometheus/storage/remote/azuread"
 )
 
 // Defaults for config blocks.
@@ -53,9 +51,14 @@ var (
 // Arguments represents the input state of the prometheus.remote_write
 // component.
 type Arguments struct {
-	ExternalLabels map[string]string  `river:"external_labels,attr,optional"`
-	Endpoints      []*EndpointOptions `river:"endpoint,block,optional"`
-	WALOptions     WALOptions         `river:"wal,block,optional"`
+	ExternalLabels map[string]string      `river:"external_labels,attr,optional"`
+	Endpoints      []*EndpointOptions     `river:"endpoint,block,optional"`
+	WALOptions     WALOptions             `river:"wal,block,optional"`
+	DeadMansSwitch *DeadMansSwitchOptions `river:"dead_mans_switch,block,optional"`
+	EndpointProbe  *EndpointProbeOptions  `river:"endpoint_probe,block,optional"`
+	// Labels which every series must have to be sent. Labels set by
+	// ExternalLabels are always present.
+	RequireLabels []string `river:"require_labels,attr,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
@@ -66,18 +69,20 @@ func (rc *Arguments) SetToDefault() {
 // EndpointOptions describes an individual location for where metrics in the WAL
 // should be delivered to using the remote_write protocol.
 type EndpointOptions struct {
-	Name                 string                  `river:"name,attr,optional"`
-	URL                  string                  `river:"url,attr"`
-	RemoteTimeout        time.Duration           `river:"remote_timeout,attr,optional"`
-	Headers              map[string]string       `river:"headers,attr,optional"`
-	SendExemplars        bool                    `river:"send_exemplars,attr,optional"`
-	SendNativeHistograms bool                    `river:"send_native_histograms,attr,optional"`
-	HTTPClientConfig     *types.HTTPClientConfig `river:",squash"`
-	QueueOptions         *QueueOptions           `river:"queue_config,block,optional"`
-	MetadataOptions      *MetadataOptions        `river:"metadata_config,block,optional"`
-	WriteRelabelConfigs  []*flow_relabel.Config  `river:"write_relabel_config,block,optional"`
-	SigV4                *SigV4Config            `river:"sigv4,block,optional"`
-	AzureAD              *AzureADConfig          `river:"azuread,block,optional"`
+	Name                 string                   `river:"name,attr,optional"`
+	URL                  string                   `river:"url,attr"`
+	RemoteTimeout        time.Duration            `river:"remote_timeout,attr,optional"`
+	Headers              map[string]string        `river:"headers,attr,optional"`
+	SendExemplars        bool                     `river:"send_exemplars,attr,optional"`
+	SendNativeHistograms bool                     `river:"send_native_histograms,attr,optional"`
+	HTTPClientConfig     *types.HTTPClientConfig  `river:",squash"`
+	QueueOptions         *QueueOptions            `river:"queue_config,block,optional"`
+	MetadataOptions      *MetadataOptions         `river:"metadata_config,block,optional"`
+	WriteRelabelConfigs  []*flow_relabel.Config   `river:"write_relabel_config,block,optional"`
+	SigV4                *SigV4Config             `river:"sigv4,block,optional"`
+	AzureAD              *types.AzureADConfig     `river:"azuread,block,optional"`
+	HMAC                 *HMACConfig              `river:"hmac,block,optional"`
+	Google               *types.GoogleCredentials `river:"google,block,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
@@ -106,16 +111,22 @@ func (r *EndpointOptions) Validate() error {
 		}
 	}
 
-	const tooManyAuthErr = "at most one of sigv4, azuread, basic_auth, oauth2, bearer_token & bearer_token_file must be configured"
+	const tooManyAuthErr = "at most one of sigv4, azuread, google, basic_auth, oauth2, bearer_token & bearer_token_file must be configured"
 
 	if r.SigV4 != nil {
-		if r.AzureAD != nil || isAuthSetInHttpClientConfig(r.HTTPClientConfig) {
+		if r.AzureAD != nil || r.Google != nil || isAuthSetInHttpClientConfig(r.HTTPClientConfig) {
 			return fmt.Errorf(tooManyAuthErr)
 		}
 	}
 
 	if r.AzureAD != nil {
-		if r.SigV4 != nil || isAuthSetInHttpClientConfig(r.HTTPClientConfig) {
+		if r.SigV4 != nil || r.Google != nil || isAuthSetInHttpClientConfig(r.HTTPClientConfig) {
+			return fmt.Errorf(tooManyAuthErr)
+		}
+	}
+
+	if r.Google != nil {
+		if r.SigV4 != nil || r.AzureAD != nil || isAuthSetInHttpClientConfig(r.HTTPClientConfig) {
 			return fmt.Errorf(tooManyAuthErr)
 		}
 	}
@@ -133,14 +144,17 @@ func (r *EndpointOptions) Validate() error {
 
 // QueueOptions handles the low level queue config options for a remote_write
 type QueueOptions struct {
-	Capacity          int           `river:"capacity,attr,optional"`
-	MaxShards         int           `river:"max_shards,attr,optional"`
-	MinShards         int           `river:"min_shards,attr,optional"`
-	MaxSamplesPerSend int           `river:"max_samples_per_send,attr,optional"`
-	BatchSendDeadline time.Duration `river:"batch_send_deadline,attr,optional"`
-	MinBackoff        time.Duration `river:"min_backoff,attr,optional"`
-	MaxBackoff        time.Duration `river:"max_backoff,attr,optional"`
-	RetryOnHTTP429    bool          `river:"retry_on_http_429,attr,optional"`
+	Capacity                int           `river:"capacity,attr,optional"`
+	MaxShards               int           `river:"max_shards,attr,optional"`
+	MinShards               int           `river:"min_shards,attr,optional"`
+	MaxSamplesPerSend       int           `river:"max_samples_per_send,attr,optional"`
+	BatchSendDeadline       time.Duration `river:"batch_send_deadline,attr,optional"`
+	BatchSendDeadlineJitter time.Duration `river:"batch_send_deadline_jitter,attr,optional"`
+	MaxPendingSamples       int           `river:"max_pending_samples,attr,optional"`
+	MinBackoff              time.Duration `river:"min_backoff,attr,optional"`
+	MaxBackoff              time.Duration `river:"max_backoff,attr,optional"`
+	RetryOnHTTP429          bool          `river:"retry_on_http_429,attr,optional"`
+	MaxSamplesPerSecond     float64       `river:"max_samples_per_second,attr,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
@@ -148,6 +162,32 @@ func (r *QueueOptions) SetToDefault() {
 	*r = DefaultQueueOptions
 }
 
+// Validate implements river.Validator.
+func (r *QueueOptions) Validate() error {
+	if r.MaxSamplesPerSend <= 0 {
+		return fmt.Errorf("max_samples_per_send must be greater than 0")
+	}
+	if r.BatchSendDeadlineJitter < 0 {
+		return fmt.Errorf("batch_send_deadline_jitter must not be negative")
+	}
+	if r.MaxPendingSamples < 0 {
+		return fmt.Errorf("max_pending_samples must not be negative")
+	}
+	q := config.QueueConfig{
+		Capacity:          r.Capacity,
+		MaxShards:         r.MaxShards,
+		MinShards:         r.MinShards,
+		MaxSamplesPerSend: r.MaxSamplesPerSend,
+	}
+	if err := r.limitPendingSamples(&q); err != nil {
+		return err
+	}
+	if r.MaxSamplesPerSecond < 0 {
+		return fmt.Errorf("max_samples_per_second must not be negative")
+	}
+	return nil
+}
+
 func (r *QueueOptions) toPrometheusType() config.QueueConfig {
 	if r == nil {
 		var res QueueOptions
@@ -155,7 +195,7 @@ func (r *QueueOptions) toPrometheusType() config.QueueConfig {
 		return res.toPrometheusType()
 	}
 
-	return config.QueueConfig{
+	res := config.QueueConfig{
 		Capacity:          r.Capacity,
 		MaxShards:         r.MaxShards,
 		MinShards:         r.MinShards,
@@ -165,6 +205,76 @@ func (r *QueueOptions) toPrometheusType() config.QueueConfig {
 		MaxBackoff:        model.Duration(r.MaxBackoff),
 		RetryOnRateLimit:  r.RetryOnHTTP429,
 	}
+	// The limits are checked by QueueOptions.Validate.
+	_ = r.limitPendingSamples(&res)
+	return res
+}
+
+// limitPendingSamples lowers the number of shards of the queue, and the size
+// of their buffers if needed, so that the shards can't hold more than
+// max_pending_samples samples in total. Once the shards are full, samples are
+// no longer read from the WAL until some of them are sent.
+//
+// Only the values left to their default are lowered. An error is returned if
+// the values set in the configuration can hold more samples.
+func (r *QueueOptions) limitPendingSamples(q *config.QueueConfig) error {
+	// A shard holds its full batches, buffered up to its capacity, its
+	// partial batch, and the batch being sent.
+	if r.MaxPendingSamples <= 0 || q.MaxSamplesPerSend <= 0 {
+		return nil
+	}
+	shardPending := func() int {
+		batches := q.Capacity / q.MaxSamplesPerSend
+		if batches == 0 {
+			batches = 1
+		}
+		return (batches + 2) * q.MaxSamplesPerSend
+	}
+
+	var (
+		maxPending     = r.MaxPendingSamples
+		defaultBuffers = true
+	)
+	if shardPending() > maxPending {
+		if r.MaxSamplesPerSend == DefaultQueueOptions.MaxSamplesPerSend {
+			q.MaxSamplesPerSend = maxPending / 3
+			if q.MaxSamplesPerSend == 0 {
+				q.MaxSamplesPerSend = 1
+			}
+		} else {
+			defaultBuffers = false
+		}
+		if r.Capacity == DefaultQueueOptions.Capacity {
+			if q.Capacity > q.MaxSamplesPerSend {
+				q.Capacity = q.MaxSamplesPerSend
+			}
+		} else {
+			defaultBuffers = false
+		}
+	}
+	// The smallest buffers hold 3 samples, which is the minimum effective
+	// value of max_pending_samples.
+	if shardPending() > maxPending && !defaultBuffers {
+		return fmt.Errorf("max_pending_samples must be at least %d with the configured capacity and max_samples_per_send", shardPending())
+	}
+
+	maxShards := maxPending / shardPending()
+	if maxShards == 0 {
+		maxShards = 1
+	}
+	if q.MaxShards > maxShards {
+		if r.MaxShards != DefaultQueueOptions.MaxShards {
+			return fmt.Errorf("max_shards must be at most %d to hold at most max_pending_samples samples", maxShards)
+		}
+		q.MaxShards = maxShards
+	}
+	if q.MinShards > q.MaxShards {
+		if r.MinShards != DefaultQueueOptions.MinShards {
+			return fmt.Errorf("min_shards must be at most %d to hold at most max_pending_samples samples", q.MaxShards)
+		}
+		q.MinShards = q.MaxShards
+	}
+	return nil
 }
 
 // MetadataOptions configures how metadata gets sent over the remote_write
@@ -245,7 +355,7 @@ func convertConfigs(cfg Arguments) (*config.Config, error) {
 			QueueConfig:         rw.QueueOptions.toPrometheusType(),
 			MetadataConfig:      rw.MetadataOptions.toPrometheusType(),
 			SigV4Config:         rw.SigV4.toPrometheusType(),
-			AzureADConfig:       rw.AzureAD.toPrometheusType(),
+			AzureADConfig:       rw.AzureAD.Convert(),
 		})
 	}
 
@@ -266,58 +376,6 @@ func toLabels(in map[string]string) labels.Labels {
 	return res
 }
 
-// ManagedIdentityConfig is used to store managed identity config values
-type ManagedIdentityConfig struct {
-	// ClientID is the clientId of the managed identity that is being used to authenticate.
-	ClientID string `river:"client_id,attr"`
-}
-
-func (m ManagedIdentityConfig) toPrometheusType() azuread.ManagedIdentityConfig {
-	return azuread.ManagedIdentityConfig{
-		ClientID: m.ClientID,
-	}
-}
-
-type AzureADConfig struct {
-	// ManagedIdentity is the managed identity that is being used to authenticate.
-	ManagedIdentity ManagedIdentityConfig `river:"managed_identity,block"`
-
-	// Cloud is the Azure cloud in which the service is running. Example: AzurePublic/AzureGovernment/AzureChina.
-	Cloud string `river:"cloud,attr,optional"`
-}
-
-func (a *AzureADConfig) Validate() error {
-	if a.Cloud != azuread.AzureChina && a.Cloud != azuread.AzureGovernment && a.Cloud != azuread.AzurePublic {
-		return fmt.Errorf("must provide a cloud in the Azure AD config")
-	}
-
-	_, err := uuid.Parse(a.ManagedIdentity.ClientID)
-	if err != nil {
-		return fmt.Errorf("the provided Azure Managed Identity client_id provided is invalid")
-	}
-
-	return nil
-}
-
-// SetToDefault implements river.Defaulter.
-func (a *AzureADConfig) SetToDefault() {
-	*a = AzureADConfig{
-		Cloud: azuread.AzurePublic,
-	}
-}
-
-func (a *AzureADConfig) toPrometheusType() *azuread.AzureADConfig {
-	if a == nil {
-		return nil
-	}
-
-	mangedIdentity := a.ManagedIdentity.toPrometheusType()
-	return &azuread.AzureADConfig{
-		ManagedIdentity: &mangedIdentity,
-		Cloud:           a.Cloud,
-	}
-}
-
 type SigV4Config struct {
 	Region    string            `river:"region,attr,optional"`
 	AccessKey string            `river:"access_key,attr,optional"`
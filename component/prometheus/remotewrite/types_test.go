@@ -1,6 +1,7 @@
 package remotewrite
 
 import (
+	"fmt"
 	"net/url"
 	"testing"
 	"time"
@@ -206,7 +207,7 @@ func TestRiverConfig(t *testing.T) {
 				sigv4 {}
 				bearer_token = "token"
 			}`,
-			errorMsg: "at most one of sigv4, azuread, basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+			errorMsg: "at most one of sigv4, azuread, google, basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
 		},
 		{
 			testName: "TooManyAuth2",
@@ -221,7 +222,18 @@ func TestRiverConfig(t *testing.T) {
 					}
 				}
 			}`,
-			errorMsg: "at most one of sigv4, azuread, basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+			errorMsg: "at most one of sigv4, azuread, google, basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+		},
+		{
+			testName: "TooManyAuth3",
+			cfg: `
+			endpoint {
+				url  = "http://0.0.0.0:11111/api/v1/write"
+
+				google {}
+				bearer_token = "token"
+			}`,
+			errorMsg: "at most one of sigv4, azuread, google, basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
 		},
 		{
 			testName: "BadAzureClientId",
@@ -278,3 +290,225 @@ func TestRiverConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchSendDeadlineJitter(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+		endpoint {
+			url = "http://0.0.0.0:11111/api/v1/write"
+
+			queue_config {
+				batch_send_deadline_jitter = "-1s"
+			}
+		}
+	`), &args)
+	require.EqualError(t, err, "batch_send_deadline_jitter must not be negative")
+}
+
+func TestLimitPendingSamples(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxPending int
+		expect     func(q *config.QueueConfig)
+	}{
+		{
+			name:       "unlimited",
+			maxPending: 0,
+			expect:     func(q *config.QueueConfig) {},
+		},
+		{
+			name:       "fewer shards",
+			maxPending: 70000,
+			expect: func(q *config.QueueConfig) {
+				// Each shard holds up to 14000 samples.
+				q.MaxShards = 5
+			},
+		},
+		{
+			name:       "smaller buffers",
+			maxPending: 3000,
+			expect: func(q *config.QueueConfig) {
+				q.MaxShards = 1
+				q.Capacity = 1000
+				q.MaxSamplesPerSend = 1000
+			},
+		},
+		{
+			name:       "minimum buffers",
+			maxPending: 1,
+			expect: func(q *config.QueueConfig) {
+				q.MaxShards = 1
+				q.Capacity = 1
+				q.MaxSamplesPerSend = 1
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultQueueOptions
+			opts.MaxPendingSamples = tc.maxPending
+
+			expect := DefaultQueueOptions.toPrometheusType()
+			tc.expect(&expect)
+			require.Equal(t, expect, opts.toPrometheusType())
+		})
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		var args Arguments
+		err := river.Unmarshal([]byte(`
+			endpoint {
+				url = "http://0.0.0.0:11111/api/v1/write"
+
+				queue_config {
+					max_pending_samples = -1
+				}
+			}
+		`), &args)
+		require.EqualError(t, err, "max_pending_samples must not be negative")
+	})
+
+	t.Run("configured values", func(t *testing.T) {
+		// The configured values are kept, and only the default ones are
+		// lowered.
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(`
+			endpoint {
+				url = "http://0.0.0.0:11111/api/v1/write"
+
+				queue_config {
+					max_pending_samples  = 3000
+					max_samples_per_send = 500
+					min_shards           = 2
+				}
+			}
+		`), &args))
+		q := args.Endpoints[0].QueueOptions.toPrometheusType()
+		require.Equal(t, 500, q.MaxSamplesPerSend)
+		require.Equal(t, 500, q.Capacity)
+		require.Equal(t, 2, q.MaxShards)
+		require.Equal(t, 2, q.MinShards)
+	})
+
+	errorTests := []struct {
+		name       string
+		maxPending int
+		config     string
+		err        string
+	}{
+		{
+			name:       "capacity",
+			maxPending: 12000,
+			config:     `capacity = 50000`,
+			err:        "max_pending_samples must be at least 56000 with the configured capacity and max_samples_per_send",
+		},
+		{
+			name:       "max_samples_per_send",
+			maxPending: 12000,
+			config:     `max_samples_per_send = 5000`,
+			err:        "max_pending_samples must be at least 15000 with the configured capacity and max_samples_per_send",
+		},
+		{
+			name:       "max_shards",
+			maxPending: 70000,
+			config:     `max_shards = 10`,
+			err:        "max_shards must be at most 5 to hold at most max_pending_samples samples",
+		},
+		{
+			name:       "min_shards",
+			maxPending: 70000,
+			config:     `min_shards = 10`,
+			err:        "min_shards must be at most 5 to hold at most max_pending_samples samples",
+		},
+	}
+	for _, tc := range errorTests {
+		t.Run("too large "+tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(fmt.Sprintf(`
+				endpoint {
+					url = "http://0.0.0.0:11111/api/v1/write"
+
+					queue_config {
+						max_pending_samples = %d
+						%s
+					}
+				}
+			`, tc.maxPending, tc.config)), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+
+	t.Run("no samples per send", func(t *testing.T) {
+		var args Arguments
+		err := river.Unmarshal([]byte(`
+			endpoint {
+				url = "http://0.0.0.0:11111/api/v1/write"
+
+				queue_config {
+					max_pending_samples  = 100
+					max_samples_per_send = 0
+				}
+			}
+		`), &args)
+		require.EqualError(t, err, "max_samples_per_send must be greater than 0")
+	})
+}
+
+func TestMaxSamplesPerSecondConfig(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+		endpoint {
+			url = "http://0.0.0.0:11111/api/v1/write"
+
+			queue_config {
+				max_samples_per_second = -1
+			}
+		}
+	`), &args)
+	require.EqualError(t, err, "max_samples_per_second must not be negative")
+
+	require.Equal(t, 2000, rateBurst(&QueueOptions{MaxSamplesPerSend: 2000, MaxSamplesPerSecond: 100}))
+	require.Equal(t, 5001, rateBurst(&QueueOptions{MaxSamplesPerSend: 2000, MaxSamplesPerSecond: 5000.5}))
+}
+
+func TestHMACConfig(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+		endpoint {
+			url = "http://0.0.0.0:11111/api/v1/write"
+
+			hmac {
+				secret = "s3cr3t"
+			}
+		}
+	`), &args)
+	require.NoError(t, err)
+
+	cfg := args.Endpoints[0].HMAC
+	require.Equal(t, "X-Signature", cfg.Header)
+	require.Equal(t, HMACAlgorithmSHA256, cfg.Algorithm)
+
+	// Signature computed with: echo -n "payload" | openssl dgst -sha256 -hmac s3cr3t
+	require.Equal(t, "sha256=9747a46cf3eeff4c181f0e08bc0388aaf2e49e139bad03dd7fefec920b08b082", cfg.Sign([]byte("payload")))
+
+	// The secret must not leak when the arguments are encoded, for example in
+	// the UI.
+	buf, err := river.Marshal(args)
+	require.NoError(t, err)
+	require.NotContains(t, string(buf), "s3cr3t")
+}
+
+func TestBadHMACConfig(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+		endpoint {
+			url = "http://0.0.0.0:11111/api/v1/write"
+
+			hmac {
+				secret    = "s3cr3t"
+				algorithm = "md5"
+			}
+		}
+	`), &args)
+	require.EqualError(t, err, `unsupported hmac algorithm "md5", must be "sha256" or "sha512"`)
+}
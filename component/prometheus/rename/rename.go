@@ -0,0 +1,283 @@
+package rename
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/regexp"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.rename",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.rename
+// component.
+type Arguments struct {
+	// Where the renamed metrics should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// Prefix added to the metric names.
+	Prefix string `river:"prefix,attr,optional"`
+	// Suffix added to the metric names, before their type suffix such as
+	// _total.
+	Suffix string `river:"suffix,attr,optional"`
+	// NormalizeUnits replaces abbreviated units at the end of metric names,
+	// such as ms, with their full name, such as milliseconds.
+	NormalizeUnits bool `river:"normalize_units,attr,optional"`
+}
+
+var (
+	prefixRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	suffixRegexp = regexp.MustCompile(`^[a-zA-Z0-9_:]*$`)
+)
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Prefix != "" && !prefixRegexp.MatchString(args.Prefix) {
+		return fmt.Errorf("prefix %q must be a valid start of a metric name", args.Prefix)
+	}
+	if !suffixRegexp.MatchString(args.Suffix) {
+		return fmt.Errorf("suffix %q must only contain characters valid in a metric name", args.Suffix)
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.rename component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.rename component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	metricsProcessed prometheus_client.Counter
+	metricsCollided  prometheus_client.Counter
+
+	mut  sync.Mutex
+	args Arguments
+	// renamed holds the new name of each metric name seen since the last
+	// update, and sources holds the metric name each new name was first
+	// produced from.
+	renamed map[string]string
+	sources map[string]string
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.rename component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{opts: o}
+	c.metricsProcessed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_rename_metrics_processed_total",
+		Help: "Total number of metrics processed",
+	})
+	c.metricsCollided = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_rename_collisions_total",
+		Help: "Total number of metrics dropped because another metric was renamed to the same name",
+	})
+	for _, metric := range []prometheus_client.Collector{c.metricsProcessed, c.metricsCollided} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.fanout = prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		ls,
+		prometheus.WithAppendHook(func(_ storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			newLbls, ok := c.rename(l)
+			if !ok {
+				c.metricsCollided.Inc()
+				return 0, nil
+			}
+			return next.Append(0, newLbls, t, v)
+		}),
+		prometheus.WithExemplarHook(func(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			newLbls, ok := c.rename(l)
+			if !ok {
+				return 0, nil
+			}
+			return next.AppendExemplar(0, newLbls, e)
+		}),
+		prometheus.WithMetadataHook(func(_ storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			newLbls, ok := c.rename(l)
+			if !ok {
+				return 0, nil
+			}
+			return next.UpdateMetadata(0, newLbls, m)
+		}),
+		prometheus.WithHistogramHook(func(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			c.metricsProcessed.Inc()
+			newLbls, ok := c.rename(l)
+			if !ok {
+				c.metricsCollided.Inc()
+				return 0, nil
+			}
+			return next.AppendHistogram(0, newLbls, t, h, fh)
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+	c.renamed = make(map[string]string)
+	c.sources = make(map[string]string)
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// rename returns lbls with the metric name renamed. It returns false if
+// another metric name was already renamed to the same name, in which case the
+// metric must be dropped so that the series of both metrics aren't mixed up.
+func (c *Component) rename(lbls labels.Labels) (labels.Labels, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	name := lbls.Get(labels.MetricName)
+	if name == "" {
+		return lbls, true
+	}
+	newName, found := c.renamed[name]
+	if !found {
+		newName = renameMetric(c.args, name)
+		c.renamed[name] = newName
+		if source, exists := c.sources[newName]; !exists {
+			c.sources[newName] = name
+		} else if source != name {
+			level.Warn(c.opts.Logger).Log("msg", "dropping metric renamed to the same name as another metric", "metric", name, "other_metric", source, "new_name", newName)
+		}
+	}
+	if c.sources[newName] != name {
+		return labels.EmptyLabels(), false
+	}
+	if newName == name {
+		return lbls, true
+	}
+	return labels.NewBuilder(lbls).Set(labels.MetricName, newName).Labels(), true
+}
+
+// typeSuffixes are the suffixes of the series of a metric which depend on its
+// type. They're kept at the end of renamed metric names so that all the
+// series of a metric are renamed consistently.
+var typeSuffixes = []string{"_total", "_bucket", "_count", "_sum", "_created", "_info"}
+
+// units maps abbreviated units to their full name, as used in the names of
+// metrics translated from OpenTelemetry.
+var units = map[string]string{
+	"ns":   "nanoseconds",
+	"us":   "microseconds",
+	"ms":   "milliseconds",
+	"s":    "seconds",
+	"sec":  "seconds",
+	"secs": "seconds",
+	"min":  "minutes",
+	"mins": "minutes",
+	"h":    "hours",
+	"d":    "days",
+	"b":    "bytes",
+	"kb":   "kilobytes",
+	"mb":   "megabytes",
+	"gb":   "gigabytes",
+	"kib":  "kibibytes",
+	"mib":  "mebibytes",
+	"gib":  "gibibytes",
+	"pct":  "percent",
+}
+
+// renameMetric returns the new name of the metric name.
+func renameMetric(args Arguments, name string) string {
+	base, typeSuffix := name, ""
+	for _, suffix := range typeSuffixes {
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) {
+			base, typeSuffix = strings.TrimSuffix(name, suffix), suffix
+			break
+		}
+	}
+
+	if args.NormalizeUnits {
+		if i := strings.LastIndexByte(base, '_'); i > 0 {
+			if unit, ok := units[strings.ToLower(base[i+1:])]; ok {
+				base = base[:i+1] + unit
+			}
+		}
+	}
+
+	return args.Prefix + base + args.Suffix + typeSuffix
+}
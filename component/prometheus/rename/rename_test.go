@@ -0,0 +1,184 @@
+package rename
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var exampleRiverConfig = `
+	forward_to      = []
+	prefix          = "myapp_"
+	suffix          = "_v2"
+	normalize_units = true
+`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(exampleRiverConfig), &args))
+	require.Equal(t, "myapp_", args.Prefix)
+	require.Equal(t, "_v2", args.Suffix)
+	require.True(t, args.NormalizeUnits)
+}
+
+func TestBadRiverConfig(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"invalid prefix", `forward_to = []
+prefix = "1app_"`, `prefix "1app_" must be a valid start of a metric name`},
+		{"invalid suffix", `forward_to = []
+suffix = "-v2"`, `suffix "-v2" must only contain characters valid in a metric name`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(tc.config), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestRenameMetric(t *testing.T) {
+	tt := []struct {
+		args     Arguments
+		name     string
+		expected string
+	}{
+		{Arguments{}, "foo_seconds_total", "foo_seconds_total"},
+		{Arguments{Prefix: "myapp_"}, "foo_seconds_total", "myapp_foo_seconds_total"},
+		{Arguments{Suffix: "_v2"}, "foo_seconds_total", "foo_seconds_v2_total"},
+		{Arguments{Suffix: "_v2"}, "foo_seconds_bucket", "foo_seconds_v2_bucket"},
+		{Arguments{Suffix: "_v2"}, "up", "up_v2"},
+		{Arguments{NormalizeUnits: true}, "request_duration_ms", "request_duration_milliseconds"},
+		{Arguments{NormalizeUnits: true}, "request_duration_ms_count", "request_duration_milliseconds_count"},
+		{Arguments{NormalizeUnits: true}, "uptime_SECS_total", "uptime_seconds_total"},
+		{Arguments{NormalizeUnits: true}, "ms", "ms"},
+		{Arguments{NormalizeUnits: true}, "requests_total", "requests_total"},
+		{Arguments{Prefix: "myapp_", NormalizeUnits: true}, "memory_mb", "myapp_memory_megabytes"},
+	}
+	for _, tc := range tt {
+		require.Equal(t, tc.expected, renameMetric(tc.args, tc.name), "renaming %q with %+v", tc.name, tc.args)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c, sink, _ := generateRename(t, Arguments{
+		Prefix: "myapp_",
+	})
+
+	appendSeries(t, c, "foo_seconds_total")
+
+	require.Equal(t, []labels.Labels{
+		labels.FromStrings(labels.MetricName, "myapp_foo_seconds_total", "job", "agent"),
+	}, sink.series())
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metricsProcessed))
+}
+
+func TestCollisions(t *testing.T) {
+	c, sink, reg := generateRename(t, Arguments{
+		NormalizeUnits: true,
+	})
+
+	appendSeries(t, c,
+		"request_duration_ms",
+		"request_duration_milliseconds",
+		"request_duration_ms",
+		"up",
+	)
+
+	require.Equal(t, []string{
+		"request_duration_milliseconds",
+		"request_duration_milliseconds",
+		"up",
+	}, sink.names())
+	require.Equal(t, 4.0, testutil.ToFloat64(c.metricsProcessed))
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metricsCollided))
+
+	count, err := testutil.GatherAndCount(reg, "agent_prometheus_rename_collisions_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestUpdateResetsCache(t *testing.T) {
+	c, sink, _ := generateRename(t, Arguments{
+		Prefix: "myapp_",
+	})
+	appendSeries(t, c, "up")
+	require.Equal(t, []string{"myapp_up"}, sink.names())
+
+	require.NoError(t, c.Update(Arguments{
+		ForwardTo: []storage.Appendable{sink.interceptor},
+		Prefix:    "other_",
+	}))
+	appendSeries(t, c, "up")
+	require.Equal(t, []string{"myapp_up", "other_up"}, sink.names())
+}
+
+// fakeSink records the labels of every sample it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []labels.Labels
+}
+
+func (s *fakeSink) series() []labels.Labels {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]labels.Labels{}, s.received...)
+}
+
+func (s *fakeSink) names() []string {
+	var names []string
+	for _, l := range s.series() {
+		names = append(names, l.Get(labels.MetricName))
+	}
+	return names
+}
+
+func generateRename(t *testing.T, args Arguments) (*Component, *fakeSink, *prom.Registry) {
+	ls := labelstore.New(nil)
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, l)
+		return ref, nil
+	}))
+
+	reg := prom.NewRegistry()
+	args.ForwardTo = []storage.Appendable{sink.interceptor}
+	c, err := New(component.Options{
+		ID:            "prometheus.rename.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c, sink, reg
+}
+
+func appendSeries(t *testing.T, c *Component, names ...string) {
+	app := c.receiver.Appender(context.Background())
+	for _, name := range names {
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, name, "job", "agent"), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
@@ -0,0 +1,293 @@
+// Package replay provides the prometheus.replay component.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.replay",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Supported values for the format argument.
+const (
+	FormatPrometheus  = "prometheus"
+	FormatOpenMetrics = "openmetrics"
+)
+
+// Arguments holds values which are used to configure the prometheus.replay
+// component.
+type Arguments struct {
+	// Where the replayed samples should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+	// Path of the file holding the samples to replay.
+	Path string `river:"path,attr"`
+	// Exposition format of the file.
+	Format string `river:"format,attr,optional"`
+	// How many times faster than the time between the samples they're
+	// replayed. Samples are replayed without waiting if 0.
+	Speed float64 `river:"speed,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for Arguments.
+var DefaultArguments = Arguments{
+	Format: FormatPrometheus,
+	Speed:  1,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	switch args.Format {
+	case FormatPrometheus, FormatOpenMetrics:
+	default:
+		return fmt.Errorf("format must be one of %q or %q, got %q", FormatPrometheus, FormatOpenMetrics, args.Format)
+	}
+	if args.Speed < 0 {
+		return fmt.Errorf("speed must not be negative")
+	}
+	return nil
+}
+
+// sample is a sample read from the replayed file.
+type sample struct {
+	Labels labels.Labels
+	T      int64
+	V      float64
+}
+
+// Component implements the prometheus.replay component.
+type Component struct {
+	opts   component.Options
+	fanout *prometheus.Fanout
+
+	replayedSamples prometheus_client.Counter
+	pendingSamples  prometheus_client.Gauge
+
+	updated chan struct{}
+
+	mut     sync.Mutex
+	args    Arguments
+	samples []sample
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.replay component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    o,
+		fanout:  prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+		updated: make(chan struct{}, 1),
+		replayedSamples: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "agent_prometheus_replay_samples_total",
+			Help: "Total number of samples replayed from the file.",
+		}),
+		pendingSamples: prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+			Name: "agent_prometheus_replay_pending_samples",
+			Help: "Number of samples of the file left to replay.",
+		}),
+	}
+	for _, m := range []prometheus_client.Collector{c.replayedSamples, c.pendingSamples} {
+		if err := o.Registerer.Register(m); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	stop := func() {}
+	defer func() { stop() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updated:
+			// The replay starts over whenever the file or how it's replayed
+			// changes.
+			stop()
+
+			c.mut.Lock()
+			samples, speed := c.samples, c.args.Speed
+			c.mut.Unlock()
+
+			stop = c.startReplay(ctx, samples, speed)
+		}
+	}
+}
+
+// startReplay replays samples in the background until ctx is canceled or the
+// returned function is called, which waits for the replay to stop.
+func (c *Component) startReplay(ctx context.Context, samples []sample, speed float64) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.replay(ctx, samples, speed)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// replay forwards samples, sorted by timestamp, waiting between the samples
+// of consecutive timestamps for their difference divided by speed.
+func (c *Component) replay(ctx context.Context, samples []sample, speed float64) {
+	c.pendingSamples.Set(float64(len(samples)))
+
+	for start := 0; start < len(samples); {
+		end := start + 1
+		for end < len(samples) && samples[end].T == samples[start].T {
+			end++
+		}
+
+		if start > 0 && speed > 0 {
+			wait := time.Duration(float64(samples[start].T-samples[start-1].T) * float64(time.Millisecond) / speed)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		if err := c.forward(ctx, samples[start:end]); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to replay samples", "err", err)
+			return
+		}
+		c.replayedSamples.Add(float64(end - start))
+		c.pendingSamples.Sub(float64(end - start))
+		start = end
+	}
+	level.Info(c.opts.Logger).Log("msg", "finished replaying samples", "samples", len(samples))
+}
+
+// forward appends samples to the downstream receivers in a single batch.
+func (c *Component) forward(ctx context.Context, samples []sample) error {
+	app := c.fanout.Appender(ctx)
+	for _, s := range samples {
+		if _, err := app.Append(0, s.Labels, s.T, s.V); err != nil {
+			_ = app.Rollback()
+			return err
+		}
+	}
+	return app.Commit()
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Changing the receivers doesn't replay the samples again.
+	prev := c.args
+	if c.samples != nil && prev.Path == newArgs.Path && prev.Format == newArgs.Format && prev.Speed == newArgs.Speed {
+		c.args = newArgs
+		return nil
+	}
+
+	samples, err := readSamples(newArgs.Path, newArgs.Format)
+	if err != nil {
+		return err
+	}
+	c.args = newArgs
+	c.samples = samples
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// readSamples reads the samples of the file at path, sorted by timestamp.
+// Samples of the same timestamp keep the order of the file.
+func readSamples(path, format string) ([]sample, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := "text/plain"
+	if format == FormatOpenMetrics {
+		contentType = "application/openmetrics-text"
+	}
+	p, err := textparse.New(b, contentType, false)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []sample{}
+	for {
+		entry, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if entry != textparse.EntrySeries {
+			continue
+		}
+
+		series, ts, v := p.Series()
+		if ts == nil {
+			return nil, fmt.Errorf("sample %s in %s has no timestamp", series, path)
+		}
+		var lbls labels.Labels
+		p.Metric(&lbls)
+		samples = append(samples, sample{Labels: lbls, T: *ts, V: v})
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].T < samples[j].T
+	})
+	return samples, nil
+}
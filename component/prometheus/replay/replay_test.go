@@ -0,0 +1,157 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to = []
+	path       = "/tmp/metrics.prom"
+	speed      = 10
+`), &args))
+	require.Equal(t, FormatPrometheus, args.Format)
+	require.Equal(t, 10.0, args.Speed)
+
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	forward_to = []
+	path       = "/tmp/metrics.prom"
+	format     = "json"
+`), &args), "format must be one of")
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	forward_to = []
+	path       = "/tmp/metrics.prom"
+	speed      = -1
+`), &args), "speed must not be negative")
+}
+
+// TestReplay ensures that the samples of the file are forwarded in the order
+// of their timestamps, with their original timestamps.
+func TestReplay(t *testing.T) {
+	tt := []struct {
+		name   string
+		path   string
+		format string
+		expect []sample
+	}{
+		{
+			name:   "prometheus",
+			path:   "testdata/metrics.prom",
+			format: FormatPrometheus,
+			expect: []sample{
+				{Labels: labels.FromStrings("__name__", "http_requests_total", "code", "200"), T: 1700000000000, V: 5},
+				{Labels: labels.FromStrings("__name__", "http_requests_total", "code", "500"), T: 1700000000000, V: 1},
+				{Labels: labels.FromStrings("__name__", "http_requests_total", "code", "200"), T: 1700000001000, V: 7},
+				{Labels: labels.FromStrings("__name__", "temperature_celsius"), T: 1700000001000, V: 21.5},
+				{Labels: labels.FromStrings("__name__", "http_requests_total", "code", "200"), T: 1700000002000, V: 10},
+			},
+		},
+		{
+			name:   "openmetrics",
+			path:   "testdata/metrics.txt",
+			format: FormatOpenMetrics,
+			expect: []sample{
+				{Labels: labels.FromStrings("__name__", "temperature_celsius"), T: 1700000000000, V: 20},
+				{Labels: labels.FromStrings("__name__", "temperature_celsius"), T: 1700000001500, V: 21.5},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := newFakeSink()
+			c, err := New(testOptions(t), Arguments{
+				ForwardTo: []storage.Appendable{sink.interceptor},
+				Path:      tc.path,
+				Format:    tc.format,
+			})
+			require.NoError(t, err)
+
+			// At a speed of 0, the samples are replayed without waiting.
+			c.replay(context.Background(), c.samples, 0)
+			require.Equal(t, tc.expect, sink.get())
+		})
+	}
+}
+
+// TestSpeed ensures that the time between the samples is divided by the
+// speed.
+func TestSpeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+	require.NoError(t, os.WriteFile(path, []byte(`
+up 1 1700000000000
+up 1 1700000001000
+up 1 1700000002000
+`), 0o644))
+
+	sink := newFakeSink()
+	c, err := New(testOptions(t), Arguments{
+		ForwardTo: []storage.Appendable{sink.interceptor},
+		Path:      path,
+		Format:    FormatPrometheus,
+		Speed:     10,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	go c.Run(ctx)
+
+	require.Eventually(t, func() bool { return len(sink.get()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	// The 2 seconds between the samples are replayed in 200 milliseconds.
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func testOptions(t *testing.T) component.Options {
+	return component.Options{
+		ID:            "prometheus.replay.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prometheus_client.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}
+}
+
+// fakeSink records the samples it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []sample
+}
+
+func newFakeSink() *fakeSink {
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, t int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received = append(sink.received, sample{Labels: l, T: t, V: v})
+		return ref, nil
+	}))
+	return sink
+}
+
+func (s *fakeSink) get() []sample {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]sample{}, s.received...)
+}
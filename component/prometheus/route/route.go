@@ -0,0 +1,282 @@
+// Package route provides the prometheus.route component.
+package route
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/hashicorp/go-multierror"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.route",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.route
+// component.
+type Arguments struct {
+	// Label whose value selects the route of a series.
+	Label string `river:"label,attr"`
+	// Routes by label value.
+	Routes []Route `river:"route,block,optional"`
+	// Where the series matching no route are forwarded to.
+	DefaultForwardTo []storage.Appendable `river:"default_forward_to,attr,optional"`
+}
+
+// Route forwards the series with a given label value.
+type Route struct {
+	Value     string               `river:"value,attr"`
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Label == "" {
+		return fmt.Errorf("label must not be empty")
+	}
+	seen := make(map[string]struct{}, len(args.Routes))
+	for _, r := range args.Routes {
+		if _, ok := seen[r.Value]; ok {
+			return fmt.Errorf("duplicate route for value %q", r.Value)
+		}
+		seen[r.Value] = struct{}{}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.route component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.route component.
+type Component struct {
+	opts   component.Options
+	ls     labelstore.LabelStore
+	exited atomic.Bool
+
+	droppedSamples prometheus_client.Counter
+
+	mut   sync.RWMutex
+	label string
+	// Fanouts of the current routes by label value, and of the default route.
+	routes       map[string]*prometheus.Fanout
+	defaultRoute *prometheus.Fanout
+	hasDefault   bool
+	// Every fanout created so far by label value. Fanouts are kept when their
+	// route is removed, since their metrics can't be unregistered.
+	fanouts map[string]*prometheus.Fanout
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// New creates a new prometheus.route component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    o,
+		ls:      ls,
+		fanouts: make(map[string]*prometheus.Fanout),
+		droppedSamples: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "agent_prometheus_route_dropped_samples_total",
+			Help: "Total number of samples dropped because their series matched no route and no default route is set.",
+		}),
+	}
+	if err := o.Registerer.Register(c.droppedSamples); err != nil {
+		return nil, err
+	}
+	// The default route has an empty route label, like series without the
+	// routing label.
+	c.defaultRoute = prometheus.NewFanout(args.DefaultForwardTo, o.ID, wrapRegisterer(o.Registerer, ""), ls)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func wrapRegisterer(reg prometheus_client.Registerer, route string) prometheus_client.Registerer {
+	return prometheus_client.WrapRegistererWith(prometheus_client.Labels{"route": route}, reg)
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.label = newArgs.Label
+	c.routes = make(map[string]*prometheus.Fanout, len(newArgs.Routes))
+	for _, r := range newArgs.Routes {
+		fanout, ok := c.fanouts[r.Value]
+		if ok {
+			fanout.UpdateChildren(r.ForwardTo)
+		} else {
+			fanout = prometheus.NewFanout(r.ForwardTo, c.opts.ID, wrapRegisterer(c.opts.Registerer, r.Value), c.ls)
+			c.fanouts[r.Value] = fanout
+		}
+		c.routes[r.Value] = fanout
+	}
+	c.defaultRoute.UpdateChildren(newArgs.DefaultForwardTo)
+	c.hasDefault = len(newArgs.DefaultForwardTo) > 0
+	return nil
+}
+
+// route returns the fanout the series identified by l is routed to, or nil if
+// it must be dropped.
+func (c *Component) route(l labels.Labels) *prometheus.Fanout {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	if fanout, ok := c.routes[l.Get(c.label)]; ok {
+		return fanout
+	}
+	if c.hasDefault {
+		return c.defaultRoute
+	}
+	return nil
+}
+
+// Appender implements storage.Appendable.
+func (c *Component) Appender(ctx context.Context) storage.Appender {
+	return &appender{
+		c:         c,
+		ctx:       ctx,
+		appenders: make(map[*prometheus.Fanout]storage.Appender),
+	}
+}
+
+// appender forwards each sample to the appender of the route of its series,
+// created the first time the route is used.
+type appender struct {
+	c         *Component
+	ctx       context.Context
+	appenders map[*prometheus.Fanout]storage.Appender
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// next returns the appender of the route of the series identified by l, or
+// nil if the series must be dropped.
+func (a *appender) next(l labels.Labels) (storage.Appender, error) {
+	if a.c.exited.Load() {
+		return nil, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+
+	fanout := a.c.route(l)
+	if fanout == nil {
+		return nil, nil
+	}
+	app, ok := a.appenders[fanout]
+	if !ok {
+		app = fanout.Appender(a.ctx)
+		a.appenders[fanout] = app
+	}
+	return app, nil
+}
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	next, err := a.next(l)
+	if next == nil {
+		if err == nil {
+			a.c.droppedSamples.Inc()
+		}
+		return 0, err
+	}
+	return next.Append(ref, l, t, v)
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	next, err := a.next(l)
+	if next == nil {
+		return 0, err
+	}
+	return next.AppendExemplar(ref, l, e)
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	next, err := a.next(l)
+	if next == nil {
+		return 0, err
+	}
+	return next.UpdateMetadata(ref, l, m)
+}
+
+// AppendHistogram implements storage.Appender.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	next, err := a.next(l)
+	if next == nil {
+		if err == nil {
+			a.c.droppedSamples.Inc()
+		}
+		return 0, err
+	}
+	return next.AppendHistogram(ref, l, t, h, fh)
+}
+
+// Commit implements storage.Appender.
+func (a *appender) Commit() error {
+	var multiErr error
+	for _, app := range a.appenders {
+		if err := app.Commit(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return multiErr
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	var multiErr error
+	for _, app := range a.appenders {
+		if err := app.Rollback(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return multiErr
+}
@@ -0,0 +1,138 @@
+package route
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	label = "tenant"
+
+	route {
+		value      = "team-a"
+		forward_to = []
+	}
+	route {
+		value      = "team-b"
+		forward_to = []
+	}
+`), &args))
+	require.Len(t, args.Routes, 2)
+
+	err := river.Unmarshal([]byte(`
+	label = "tenant"
+
+	route {
+		value      = "team-a"
+		forward_to = []
+	}
+	route {
+		value      = "team-a"
+		forward_to = []
+	}
+`), &args)
+	require.ErrorContains(t, err, `duplicate route for value "team-a"`)
+
+	err = river.Unmarshal([]byte(`label = ""`), &args)
+	require.ErrorContains(t, err, "label must not be empty")
+}
+
+func TestRoutes(t *testing.T) {
+	ls := labelstore.New(nil)
+	teamA, teamB, fallback := newFakeSink(ls), newFakeSink(ls), newFakeSink(ls)
+	args := Arguments{
+		Label: "tenant",
+		Routes: []Route{
+			{Value: "team-a", ForwardTo: []storage.Appendable{teamA.interceptor}},
+			{Value: "team-b", ForwardTo: []storage.Appendable{teamB.interceptor}},
+		},
+		DefaultForwardTo: []storage.Appendable{fallback.interceptor},
+	}
+	c := generateRoute(t, ls, args)
+
+	appendSeries(t, c, "team-a", "team-b", "team-c", "")
+	require.Equal(t, []string{`{__name__="up", tenant="team-a"}`}, teamA.series())
+	require.Equal(t, []string{`{__name__="up", tenant="team-b"}`}, teamB.series())
+	require.Equal(t, []string{`{__name__="up", tenant="team-c"}`, `{__name__="up"}`}, fallback.series())
+	require.Equal(t, 0.0, testutil.ToFloat64(c.droppedSamples))
+
+	// Without a default route, unmatched series are dropped.
+	args.DefaultForwardTo = nil
+	require.NoError(t, c.Update(args))
+	appendSeries(t, c, "team-a", "team-c", "")
+	require.Len(t, teamA.series(), 1)
+	require.Len(t, fallback.series(), 2)
+	require.Equal(t, 2.0, testutil.ToFloat64(c.droppedSamples))
+}
+
+func appendSeries(t *testing.T, c *Component, tenants ...string) {
+	app := c.Appender(context.Background())
+	for _, tenant := range tenants {
+		l := labels.FromStrings("__name__", "up")
+		if tenant != "" {
+			l = labels.FromStrings("__name__", "up", "tenant", tenant)
+		}
+		_, err := app.Append(0, l, 1000, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
+
+// fakeSink records the series of every sample it receives, in order.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received []string
+}
+
+func newFakeSink(ls labelstore.LabelStore) *fakeSink {
+	sink := &fakeSink{}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		for _, s := range sink.received {
+			if s == l.String() {
+				return ref, nil
+			}
+		}
+		sink.received = append(sink.received, l.String())
+		return ref, nil
+	}))
+	return sink
+}
+
+// series returns the distinct series received by the sink.
+func (s *fakeSink) series() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.received...)
+}
+
+func generateRoute(t *testing.T, ls labelstore.LabelStore, args Arguments) *Component {
+	c, err := New(component.Options{
+		ID:            "prometheus.route.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c
+}
@@ -0,0 +1,195 @@
+package scrape
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/loki/pkg/logproto"
+	client_prometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// failureLogsAppendable wraps an Appendable to emit a log entry for every
+// failed scrape, which is forwarded to the receivers set with SetReceivers.
+//
+// The report series of a scrape are appended once the target has been
+// scraped, so the error of a scrape whose up series is 0 is already the last
+// error of its target.
+//
+// The entries are buffered until they're forwarded, and dropped if the buffer
+// is full, so that blocked receivers don't stall the scrapes.
+type failureLogsAppendable struct {
+	next    storage.Appendable
+	entries chan loki.Entry
+	dropped client_prometheus.Counter
+
+	mut       sync.RWMutex
+	receivers []loki.LogsReceiver
+}
+
+var _ storage.Appendable = (*failureLogsAppendable)(nil)
+
+// maxPendingFailureLogs is the number of failure log entries buffered until
+// they're forwarded.
+const maxPendingFailureLogs = 100
+
+func newFailureLogsAppendable(next storage.Appendable, reg client_prometheus.Registerer) (*failureLogsAppendable, error) {
+	dropped := client_prometheus.NewCounter(client_prometheus.CounterOpts{
+		Name: "agent_prometheus_scrape_failure_logs_dropped_total",
+		Help: "Total number of failure log entries dropped because the receivers were too slow.",
+	})
+	if err := reg.Register(dropped); err != nil {
+		return nil, err
+	}
+	return &failureLogsAppendable{
+		next:    next,
+		entries: make(chan loki.Entry, maxPendingFailureLogs),
+		dropped: dropped,
+	}, nil
+}
+
+// SetReceivers sets the receivers of the log entries, disabling them if
+// there are none. It applies to Appenders requested afterwards.
+func (fa *failureLogsAppendable) SetReceivers(receivers []loki.LogsReceiver) {
+	fa.mut.Lock()
+	defer fa.mut.Unlock()
+	fa.receivers = receivers
+}
+
+// Appender implements storage.Appendable.
+func (fa *failureLogsAppendable) Appender(ctx context.Context) storage.Appender {
+	next := fa.next.Appender(ctx)
+
+	fa.mut.RLock()
+	enabled := len(fa.receivers) > 0
+	fa.mut.RUnlock()
+	if !enabled {
+		return next
+	}
+
+	target, ok := scrape.TargetFromContext(ctx)
+	if !ok || target == nil {
+		return next
+	}
+	return &failureLogsAppender{
+		Appender: next,
+		parent:   fa,
+		target:   target,
+		phase:    reportPhase{target: target},
+	}
+}
+
+// Run forwards the log entries to the receivers until ctx is canceled.
+func (fa *failureLogsAppendable) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry := <-fa.entries:
+			fa.mut.RLock()
+			receivers := fa.receivers
+			fa.mut.RUnlock()
+
+			for _, receiver := range receivers {
+				select {
+				case <-ctx.Done():
+					return
+				case receiver.Chan() <- entry:
+				}
+			}
+		}
+	}
+}
+
+type failureLogsAppender struct {
+	storage.Appender
+	parent *failureLogsAppendable
+	target *scrape.Target
+	phase  reportPhase
+
+	// The up series of the scrape if it failed, and the duration of the
+	// scrape.
+	failed   bool
+	up       labels.Labels
+	t        int64
+	duration time.Duration
+}
+
+var _ storage.Appender = (*failureLogsAppender)(nil)
+
+// Append implements storage.Appender.
+func (app *failureLogsAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if !app.phase.isReportSeries(l, t, v) {
+		return app.Appender.Append(ref, l, t, v)
+	}
+	switch l.Get(labels.MetricName) {
+	case upMetric:
+		if v == 0 && !value.IsStaleNaN(v) {
+			app.failed, app.up, app.t = true, l, t
+		}
+	case scrapeDurationMetric:
+		app.duration = time.Duration(v * float64(time.Second))
+	}
+	return app.Appender.Append(ref, l, t, v)
+}
+
+// Commit implements storage.Appender. The log entry of a failed scrape is
+// dropped if the buffer of entries is full.
+func (app *failureLogsAppender) Commit() error {
+	if err := app.Appender.Commit(); err != nil {
+		return err
+	}
+	if !app.failed {
+		return nil
+	}
+
+	entry, err := app.newEntry()
+	if err != nil {
+		return err
+	}
+	select {
+	case app.parent.entries <- entry:
+	default:
+		app.parent.dropped.Inc()
+	}
+	return nil
+}
+
+// newEntry returns the log entry for the failed scrape. It has the job and
+// instance labels of the up series of the scrape, and its line is in logfmt
+// and holds the target, the duration and the error of the scrape.
+func (app *failureLogsAppender) newEntry() (loki.Entry, error) {
+	keyvals := []interface{}{
+		"msg", "scrape failed",
+		"target", app.target.URL().String(),
+		"duration", app.duration.String(),
+	}
+	if err := app.target.LastError(); err != nil {
+		keyvals = append(keyvals, "err", err.Error())
+	}
+	line, err := logfmt.MarshalKeyvals(keyvals...)
+	if err != nil {
+		return loki.Entry{}, err
+	}
+
+	ls := model.LabelSet{}
+	for _, name := range []string{model.JobLabel, model.InstanceLabel} {
+		if v := app.up.Get(name); v != "" {
+			ls[model.LabelName(name)] = model.LabelValue(v)
+		}
+	}
+	return loki.Entry{
+		Labels: ls,
+		Entry: logproto.Entry{
+			Timestamp: time.UnixMilli(app.t),
+			Line:      string(line),
+		},
+	}, nil
+}
@@ -0,0 +1,408 @@
+package scrape
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+// nativeHistogramSchema is the schema of the native histograms converted from
+// classic histograms, with 8 buckets per power of 2. It's a tradeoff between
+// the accuracy of the quantiles of the native histograms and their number of
+// buckets.
+const nativeHistogramSchema = 3
+
+// zeroThresholdShift is the number of powers of 2 between the lowest classic
+// bucket bound and the zero threshold of the converted native histograms, see
+// convert.
+const zeroThresholdShift = 3
+
+// Suffixes of the series making up a classic histogram.
+const (
+	bucketSuffix = "_bucket"
+	sumSuffix    = "_sum"
+	countSuffix  = "_count"
+)
+
+// classicHistogramAppendable wraps an Appendable so that classic histograms
+// are converted into native histograms when enabled.
+//
+// The series of a classic histogram are buffered until the Appender is
+// committed, since a histogram can only be converted once all of its series
+// have been appended. Histograms which can't be converted, such as those with
+// missing series, are forwarded unchanged.
+//
+// The references returned for the buffered series are their global references
+// in the label store, which are also the references the series get when
+// forwarded. This lets the scrape loop cache the series, even though they are
+// only sent at commit time.
+type classicHistogramAppendable struct {
+	next    storage.Appendable
+	ls      labelstore.LabelStore
+	enabled atomic.Bool
+}
+
+var _ storage.Appendable = (*classicHistogramAppendable)(nil)
+
+func newClassicHistogramAppendable(next storage.Appendable, ls labelstore.LabelStore) *classicHistogramAppendable {
+	return &classicHistogramAppendable{next: next, ls: ls}
+}
+
+// SetEnabled toggles the conversion of classic histograms. It applies to
+// Appenders requested afterwards.
+func (ca *classicHistogramAppendable) SetEnabled(enabled bool) {
+	ca.enabled.Store(enabled)
+}
+
+// Appender implements storage.Appendable.
+func (ca *classicHistogramAppendable) Appender(ctx context.Context) storage.Appender {
+	next := ca.next.Appender(ctx)
+	if !ca.enabled.Load() {
+		return next
+	}
+	return &classicHistogramAppender{
+		Appender:   next,
+		ls:         ca.ls,
+		histograms: make(map[string]*classicHistogram),
+		natives:    make(map[string]struct{}),
+	}
+}
+
+type classicHistogramAppender struct {
+	storage.Appender
+	ls labelstore.LabelStore
+
+	// Buffered candidate histograms by the labels of their native histogram,
+	// in the order they were first seen.
+	histograms map[string]*classicHistogram
+	order      []string
+	// The last buffered sample, which exemplars are appended for.
+	last *bufferedSample
+	// Labels of the native histograms appended directly.
+	natives map[string]struct{}
+}
+
+var _ storage.Appender = (*classicHistogramAppender)(nil)
+
+// classicHistogram holds the buffered series of a classic histogram.
+type classicHistogram struct {
+	labels  labels.Labels // Labels of the native histogram.
+	buckets []bufferedSample
+	sum     *bufferedSample
+	count   *bufferedSample
+}
+
+// bufferedSample is a sample of a classic histogram series, along with its
+// exemplars.
+type bufferedSample struct {
+	labels    labels.Labels
+	t         int64
+	v         float64
+	le        float64
+	exemplars []exemplar.Exemplar
+}
+
+// Append implements storage.Appender.
+func (app *classicHistogramAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	s := app.buffer(l)
+	app.last = s
+	if s == nil {
+		return app.Appender.Append(ref, l, t, v)
+	}
+	s.t, s.v = t, v
+	return app.ref(l), nil
+}
+
+// ref returns the global reference of the series l.
+func (app *classicHistogramAppender) ref(l labels.Labels) storage.SeriesRef {
+	return storage.SeriesRef(app.ls.GetOrAddGlobalRefID(l))
+}
+
+// AppendExemplar implements storage.Appender.
+func (app *classicHistogramAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	// Exemplars are appended right after the sample of their series.
+	if s := app.last; s != nil && labels.Equal(s.labels, l) {
+		s.exemplars = append(s.exemplars, e)
+		return app.ref(l), nil
+	}
+	return app.Appender.AppendExemplar(ref, l, e)
+}
+
+// AppendHistogram implements storage.Appender.
+func (app *classicHistogramAppender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	app.natives[l.String()] = struct{}{}
+	return app.Appender.AppendHistogram(ref, l, t, h, fh)
+}
+
+// Commit implements storage.Appender.
+func (app *classicHistogramAppender) Commit() error {
+	if err := app.flush(); err != nil {
+		_ = app.Appender.Rollback()
+		return err
+	}
+	return app.Appender.Commit()
+}
+
+// Rollback implements storage.Appender.
+func (app *classicHistogramAppender) Rollback() error {
+	app.histograms, app.order, app.last = nil, nil, nil
+	return app.Appender.Rollback()
+}
+
+// buffer returns where to buffer the sample of the series l, or nil if l
+// can't be part of a classic histogram.
+func (app *classicHistogramAppender) buffer(l labels.Labels) *bufferedSample {
+	name := l.Get(labels.MetricName)
+
+	var (
+		base   string
+		bucket bool
+		le     float64
+	)
+	switch {
+	case strings.HasSuffix(name, bucketSuffix) && l.Has(labels.BucketLabel):
+		var err error
+		if le, err = strconv.ParseFloat(l.Get(labels.BucketLabel), 64); err != nil {
+			return nil
+		}
+		base, bucket = strings.TrimSuffix(name, bucketSuffix), true
+	case strings.HasSuffix(name, sumSuffix):
+		base = strings.TrimSuffix(name, sumSuffix)
+	case strings.HasSuffix(name, countSuffix):
+		base = strings.TrimSuffix(name, countSuffix)
+	default:
+		return nil
+	}
+
+	hl := labels.NewBuilder(l).Set(labels.MetricName, base).Del(labels.BucketLabel).Labels()
+	key := hl.String()
+	h, ok := app.histograms[key]
+	if !ok {
+		h = &classicHistogram{labels: hl}
+		app.histograms[key] = h
+		app.order = append(app.order, key)
+	}
+
+	s := bufferedSample{labels: l, le: le}
+	switch {
+	case bucket:
+		h.buckets = append(h.buckets, s)
+		return &h.buckets[len(h.buckets)-1]
+	case strings.HasSuffix(name, sumSuffix):
+		h.sum = &s
+		return h.sum
+	default:
+		h.count = &s
+		return h.count
+	}
+}
+
+// flush appends the buffered histograms to the next Appender, converted into
+// native histograms when possible.
+func (app *classicHistogramAppender) flush() error {
+	for _, key := range app.order {
+		h := app.histograms[key]
+
+		// Classic histograms are also scraped when exposed as native
+		// histograms if scrape_classic_histograms is enabled, in which case
+		// they are kept as is.
+		if _, native := app.natives[key]; !native {
+			if t, fh, ok := h.convert(); ok {
+				ref := app.ref(h.labels)
+				if _, err := app.Appender.AppendHistogram(ref, h.labels, t, nil, fh); err != nil {
+					return err
+				}
+				for _, s := range h.samples() {
+					for _, e := range s.exemplars {
+						if _, err := app.Appender.AppendExemplar(ref, h.labels, e); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+		}
+
+		for _, s := range h.samples() {
+			ref := app.ref(s.labels)
+			if _, err := app.Appender.Append(ref, s.labels, s.t, s.v); err != nil {
+				return err
+			}
+			for _, e := range s.exemplars {
+				if _, err := app.Appender.AppendExemplar(ref, s.labels, e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	app.histograms, app.order, app.last = nil, nil, nil
+	return nil
+}
+
+// samples returns the buffered samples of h.
+func (h *classicHistogram) samples() []*bufferedSample {
+	res := make([]*bufferedSample, 0, len(h.buckets)+2)
+	for i := range h.buckets {
+		res = append(res, &h.buckets[i])
+	}
+	if h.sum != nil {
+		res = append(res, h.sum)
+	}
+	if h.count != nil {
+		res = append(res, h.count)
+	}
+	return res
+}
+
+// convert returns the native histogram equivalent to h and its timestamp.
+// ok is false if h isn't a complete classic histogram: it must have a +Inf
+// bucket and at least one finite bucket, positive bucket bounds, cumulative
+// bucket counts, and the _sum and _count series, all sampled at the same time.
+//
+// Like histogram_quantile does for classic histograms, the observations of a
+// classic bucket are assumed to be evenly spread between its bounds, and the
+// lowest bucket to start at 0. The observations of a classic bucket are split
+// between the native buckets it overlaps, in proportion to their overlap.
+// Below the lowest classic bound divided by 2^zeroThresholdShift, they are
+// counted in the zero bucket. The observations above the largest finite bound
+// are counted in the native bucket containing it, since native histograms
+// have no bucket for them.
+//
+// This way, the quantiles of the native histogram match the ones of the
+// classic histogram, within the width of the native buckets holding classic
+// bucket bounds.
+func (h *classicHistogram) convert() (int64, *histogram.FloatHistogram, bool) {
+	if h.sum == nil || h.count == nil || len(h.buckets) < 2 {
+		return 0, nil, false
+	}
+	buckets := append([]bufferedSample{}, h.buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].le < buckets[j].le })
+
+	var (
+		t      = h.count.t
+		inf    = buckets[len(buckets)-1]
+		finite = buckets[:len(buckets)-1]
+		stale  = value.IsStaleNaN(h.count.v)
+	)
+	if !math.IsInf(inf.le, +1) || finite[0].le <= 0 {
+		return 0, nil, false
+	}
+	for i, s := range finite {
+		if math.IsInf(s.le, +1) || (i > 0 && s.le == finite[i-1].le) {
+			return 0, nil, false
+		}
+	}
+	for _, s := range append(buckets, *h.sum) {
+		if s.t != t || value.IsStaleNaN(s.v) != stale {
+			return 0, nil, false
+		}
+	}
+	// The series of histograms which disappeared are marked as stale all at
+	// once.
+	if stale {
+		return t, &histogram.FloatHistogram{Sum: math.Float64frombits(value.StaleNaN)}, true
+	}
+	if inf.v != h.count.v {
+		return 0, nil, false
+	}
+
+	// The zero threshold is a native bucket bound, so that the zero bucket
+	// doesn't overlap the other buckets.
+	zeroThreshold := nativeBucketBound(nativeBucketIndex(finite[0].le) - zeroThresholdShift<<nativeHistogramSchema)
+	fh := &histogram.FloatHistogram{
+		Schema:        nativeHistogramSchema,
+		ZeroThreshold: zeroThreshold,
+		Count:         h.count.v,
+		Sum:           h.sum.v,
+	}
+
+	var (
+		counts   = make(map[int32]float64)
+		lower    = 0.0
+		previous = 0.0
+	)
+	for _, s := range finite {
+		count := s.v - previous
+		if count < 0 || math.IsNaN(count) {
+			return 0, nil, false
+		}
+		previous = s.v
+
+		if count > 0 {
+			width := s.le - lower
+			if lower < zeroThreshold {
+				fh.ZeroCount += count * (zeroThreshold - lower) / width
+				lower = zeroThreshold
+			}
+			for i := nativeBucketIndex(lower); i <= nativeBucketIndex(s.le); i++ {
+				overlap := math.Min(s.le, nativeBucketBound(i)) - math.Max(lower, nativeBucketBound(i-1))
+				if overlap > 0 {
+					counts[i] += count * overlap / width
+				}
+			}
+		}
+		lower = s.le
+	}
+	overflow := inf.v - previous
+	if overflow < 0 || math.IsNaN(overflow) {
+		return 0, nil, false
+	}
+	if overflow > 0 {
+		counts[nativeBucketIndex(finite[len(finite)-1].le)] += overflow
+	}
+
+	fh.PositiveSpans, fh.PositiveBuckets = nativeBuckets(counts)
+	return t, fh, true
+}
+
+// nativeBucketIndex returns the index of the native bucket containing the
+// positive value v.
+func nativeBucketIndex(v float64) int32 {
+	return int32(math.Ceil(math.Log2(v) * (1 << nativeHistogramSchema)))
+}
+
+// nativeBucketBound returns the upper bound of the native bucket of index i.
+func nativeBucketBound(i int32) float64 {
+	return math.Exp2(float64(i) / (1 << nativeHistogramSchema))
+}
+
+// nativeBuckets returns the spans and absolute counts of the buckets of a
+// float histogram holding counts.
+func nativeBuckets(counts map[int32]float64) ([]histogram.Span, []float64) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	indices := make([]int32, 0, len(counts))
+	for i := range counts {
+		indices = append(indices, i)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	var (
+		spans   []histogram.Span
+		buckets = make([]float64, 0, len(indices))
+	)
+	for i, idx := range indices {
+		if i > 0 && idx == indices[i-1]+1 {
+			spans[len(spans)-1].Length++
+		} else {
+			offset := idx
+			if i > 0 {
+				offset = idx - indices[i-1] - 1
+			}
+			spans = append(spans, histogram.Span{Offset: offset, Length: 1})
+		}
+		buckets = append(buckets, counts[idx])
+	}
+	return spans, buckets
+}
@@ -0,0 +1,263 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassicHistogramAppender(t *testing.T) {
+	series := func(name string, v float64, lbls ...string) sample {
+		return sample{labels: labels.FromStrings(append([]string{labels.MetricName, name}, lbls...)...), v: v}
+	}
+	stale := math.Float64frombits(value.StaleNaN)
+
+	tests := []struct {
+		name       string
+		samples    []sample
+		native     bool // Whether a native histogram is also appended.
+		histogram  *histogram.FloatHistogram
+		forwarded  int // Number of forwarded classic series.
+		staleCheck bool
+	}{
+		{
+			name: "complete",
+			samples: []sample{
+				series("h_bucket", 1, "le", "1"),
+				series("h_bucket", 3, "le", "2"),
+				series("h_bucket", 4, "le", "+Inf"),
+				series("h_sum", 5),
+				series("h_count", 4),
+			},
+			// The observation of the first bucket is spread over (0, 1]: an
+			// eighth of it in the zero bucket up to 1/8, and the rest in the 23
+			// native buckets up to 1. The observations of the second bucket
+			// are spread over the 8 native buckets of (1, 2], and the
+			// observation above 2 is counted in the last one.
+			histogram: &histogram.FloatHistogram{
+				Schema:        nativeHistogramSchema,
+				ZeroThreshold: 0.125,
+				ZeroCount:     0.125,
+				Count:         4,
+				Sum:           5,
+				PositiveSpans: []histogram.Span{{Offset: -23, Length: 32}},
+				PositiveBuckets: func() []float64 {
+					var buckets []float64
+					for i := -23; i <= 8; i++ {
+						lower, upper := math.Exp2(float64(i-1)/8), math.Exp2(float64(i)/8)
+						if i <= 0 {
+							buckets = append(buckets, upper-lower)
+						} else {
+							buckets = append(buckets, 2*(upper-lower))
+						}
+					}
+					buckets[len(buckets)-1]++
+					return buckets
+				}(),
+			},
+		},
+		{
+			name: "negative and zero buckets",
+			samples: []sample{
+				series("h_bucket", 1, "le", "-1"),
+				series("h_bucket", 2, "le", "0"),
+				series("h_bucket", 4, "le", "1"),
+				series("h_bucket", 4, "le", "+Inf"),
+				series("h_sum", 0),
+				series("h_count", 4),
+			},
+			forwarded: 6,
+		},
+		{
+			name: "stale",
+			samples: []sample{
+				series("h_bucket", stale, "le", "1"),
+				series("h_bucket", stale, "le", "+Inf"),
+				series("h_sum", stale),
+				series("h_count", stale),
+			},
+			staleCheck: true,
+		},
+		{
+			name: "missing +Inf bucket",
+			samples: []sample{
+				series("h_bucket", 1, "le", "1"),
+				series("h_bucket", 3, "le", "2"),
+				series("h_sum", 5),
+				series("h_count", 3),
+			},
+			forwarded: 4,
+		},
+		{
+			name: "missing count",
+			samples: []sample{
+				series("h_bucket", 1, "le", "1"),
+				series("h_bucket", 4, "le", "+Inf"),
+				series("h_sum", 5),
+			},
+			forwarded: 3,
+		},
+		{
+			name: "decreasing buckets",
+			samples: []sample{
+				series("h_bucket", 3, "le", "1"),
+				series("h_bucket", 2, "le", "+Inf"),
+				series("h_sum", 5),
+				series("h_count", 2),
+			},
+			forwarded: 4,
+		},
+		{
+			name: "summary",
+			samples: []sample{
+				series("s", 1, "quantile", "0.5"),
+				series("s_sum", 5),
+				series("s_count", 4),
+			},
+			forwarded: 3,
+		},
+		{
+			name: "also native",
+			samples: []sample{
+				series("h_bucket", 1, "le", "1"),
+				series("h_bucket", 4, "le", "+Inf"),
+				series("h_sum", 5),
+				series("h_count", 4),
+			},
+			native:    true,
+			forwarded: 4,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				histograms []*histogram.FloatHistogram
+				forwarded  int
+			)
+			sink := prometheus.NewInterceptor(nil, labelstore.New(nil),
+				prometheus.WithAppendHook(func(ref storage.SeriesRef, _ labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+					forwarded++
+					return ref, nil
+				}),
+				prometheus.WithHistogramHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ *histogram.Histogram, fh *histogram.FloatHistogram, _ storage.Appender) (storage.SeriesRef, error) {
+					if fh != nil {
+						require.Equal(t, "h", l.Get(labels.MetricName))
+						histograms = append(histograms, fh)
+					}
+					return ref, nil
+				}),
+			)
+			ls := labelstore.New(nil)
+			appendable := newClassicHistogramAppendable(sink, ls)
+			appendable.SetEnabled(true)
+
+			app := appendable.Appender(context.Background())
+			if tc.native {
+				_, err := app.AppendHistogram(0, labels.FromStrings(labels.MetricName, "h"), 0, &histogram.Histogram{}, nil)
+				require.NoError(t, err)
+			}
+			for _, s := range tc.samples {
+				// The buffered series get their global reference, so that
+				// they can be cached by the scrape loop.
+				ref, err := app.Append(0, s.labels, 0, s.v)
+				require.NoError(t, err)
+				require.Equal(t, storage.SeriesRef(ls.GetOrAddGlobalRefID(s.labels)), ref)
+			}
+			require.NoError(t, app.Commit())
+
+			require.Equal(t, tc.forwarded, forwarded)
+			switch {
+			case tc.staleCheck:
+				require.Len(t, histograms, 1)
+				require.True(t, value.IsStaleNaN(histograms[0].Sum))
+			case tc.histogram != nil:
+				require.Len(t, histograms, 1)
+				fh := histograms[0]
+				require.InDeltaSlice(t, tc.histogram.PositiveBuckets, fh.PositiveBuckets, 1e-9)
+				fh.PositiveBuckets = tc.histogram.PositiveBuckets
+				require.InDelta(t, tc.histogram.ZeroCount, fh.ZeroCount, 1e-9)
+				fh.ZeroCount = tc.histogram.ZeroCount
+				require.Equal(t, tc.histogram, fh)
+			default:
+				require.Empty(t, histograms)
+			}
+		})
+	}
+}
+
+// TestClassicHistogramQuantiles ensures that the quantiles of the converted
+// histograms are close to the quantiles of the classic histograms.
+func TestClassicHistogramQuantiles(t *testing.T) {
+	db := teststorage.New(t)
+	t.Cleanup(func() { _ = db.Close() })
+
+	var (
+		ts      = time.Unix(0, 0)
+		bounds  = []string{"0.005", "0.01", "0.025", "0.05", "0.1", "0.25", "0.5", "1", "2.5", "5", "10", "+Inf"}
+		counts  = []float64{2, 10, 12, 40, 70, 71, 80, 95, 99, 99, 99, 100}
+		classic = db.Appender(context.Background())
+		app     = newClassicHistogramAppendable(db, labelstore.New(nil))
+	)
+	app.SetEnabled(true)
+	converted := app.Appender(context.Background())
+	for _, app := range []struct {
+		storage.Appender
+		name string
+	}{{classic, "classic"}, {converted, "converted"}} {
+		for i, le := range bounds {
+			_, err := app.Append(0, labels.FromStrings(labels.MetricName, app.name+"_bucket", "le", le), ts.UnixMilli(), counts[i])
+			require.NoError(t, err)
+		}
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, app.name+"_sum"), ts.UnixMilli(), 30)
+		require.NoError(t, err)
+		_, err = app.Append(0, labels.FromStrings(labels.MetricName, app.name+"_count"), ts.UnixMilli(), 100)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+
+	engine := promql.NewEngine(promql.EngineOpts{MaxSamples: 1000, Timeout: time.Minute})
+	quantile := func(expr string) float64 {
+		q, err := engine.NewInstantQuery(context.Background(), db, nil, expr, ts)
+		require.NoError(t, err)
+		defer q.Close()
+		res := q.Exec(context.Background())
+		require.NoError(t, res.Err)
+		v, err := res.Vector()
+		require.NoError(t, err)
+		require.Len(t, v, 1, expr)
+		return v[0].F
+	}
+
+	for _, q := range []float64{0.01, 0.05, 0.25, 0.5, 0.75, 0.9, 0.95, 0.98} {
+		expected := quantile(fmt.Sprintf("histogram_quantile(%g, classic_bucket)", q))
+		actual := quantile(fmt.Sprintf("histogram_quantile(%g, converted)", q))
+		require.InEpsilon(t, expected, actual, 0.01, "quantile %g", q)
+	}
+
+	// The quantiles at classic bucket bounds, or above the largest finite
+	// bound, are within the native bucket holding the bound, whose bounds are
+	// within 2^(1/8) of each other.
+	nativeBucketWidth := math.Exp2(1.0/8) - 1
+	require.Equal(t, 0.01, quantile("histogram_quantile(0.1, classic_bucket)"))
+	require.InEpsilon(t, 0.01, quantile("histogram_quantile(0.1, converted)"), nativeBucketWidth)
+	require.Equal(t, 10.0, quantile("histogram_quantile(0.995, classic_bucket)"))
+	require.InEpsilon(t, 10.0, quantile("histogram_quantile(0.995, converted)"), nativeBucketWidth)
+	require.Equal(t, 30.0/100, quantile("histogram_sum(converted) / histogram_count(converted)"))
+}
+
+type sample struct {
+	labels labels.Labels
+	v      float64
+}
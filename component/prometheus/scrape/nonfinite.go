@@ -0,0 +1,80 @@
+package scrape
+
+import (
+	"context"
+	"math"
+
+	client_prometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+// nonFiniteAppendable wraps an Appendable to drop the scraped samples whose
+// value is NaN or infinite when enabled, since some databases reject them.
+// Staleness markers, which are NaN values too, are always kept.
+type nonFiniteAppendable struct {
+	next    storage.Appendable
+	dropped client_prometheus.Counter
+	enabled atomic.Bool
+}
+
+var _ storage.Appendable = (*nonFiniteAppendable)(nil)
+
+func newNonFiniteAppendable(next storage.Appendable, reg client_prometheus.Registerer) (*nonFiniteAppendable, error) {
+	dropped := client_prometheus.NewCounter(client_prometheus.CounterOpts{
+		Name: "agent_prometheus_scrape_non_finite_dropped_samples_total",
+		Help: "Total number of scraped samples dropped because their value is NaN or infinite.",
+	})
+	if err := reg.Register(dropped); err != nil {
+		return nil, err
+	}
+	return &nonFiniteAppendable{next: next, dropped: dropped}, nil
+}
+
+// SetEnabled toggles dropping samples with non-finite values. It applies to
+// Appenders requested afterwards.
+func (na *nonFiniteAppendable) SetEnabled(enabled bool) {
+	na.enabled.Store(enabled)
+}
+
+// Appender implements storage.Appendable.
+func (na *nonFiniteAppendable) Appender(ctx context.Context) storage.Appender {
+	next := na.next.Appender(ctx)
+	if !na.enabled.Load() {
+		return next
+	}
+	return &nonFiniteAppender{Appender: next, parent: na}
+}
+
+type nonFiniteAppender struct {
+	storage.Appender
+	parent *nonFiniteAppendable
+
+	// Labels of the last dropped sample, whose exemplars are dropped too.
+	dropped labels.Labels
+}
+
+var _ storage.Appender = (*nonFiniteAppender)(nil)
+
+// Append implements storage.Appender.
+func (app *nonFiniteAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if (math.IsNaN(v) && !value.IsStaleNaN(v)) || math.IsInf(v, 0) {
+		app.parent.dropped.Inc()
+		app.dropped = l
+		return 0, nil
+	}
+	app.dropped = labels.EmptyLabels()
+	return app.Appender.Append(ref, l, t, v)
+}
+
+// AppendExemplar implements storage.Appender.
+func (app *nonFiniteAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	// Exemplars are appended right after the sample of their series.
+	if !app.dropped.IsEmpty() && labels.Equal(app.dropped, l) {
+		return 0, nil
+	}
+	return app.Appender.AppendExemplar(ref, l, e)
+}
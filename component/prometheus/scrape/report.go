@@ -0,0 +1,194 @@
+package scrape
+
+import (
+	"context"
+	"math"
+
+	"github.com/grafana/agent/component/common/throughput"
+	client_prometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// Names of the extra report series which the scrape manager appends after
+// every scrape when extra metrics are enabled.
+const (
+	scrapeTimeoutMetric     = "scrape_timeout_seconds"
+	scrapeSampleLimitMetric = "scrape_sample_limit"
+	scrapeBodySizeMetric    = "scrape_body_size_bytes"
+)
+
+// reportSeries holds the names of all the report series which the scrape
+// manager appends after every scrape.
+var reportSeries = map[string]struct{}{
+	upMetric:                                {},
+	scrapeDurationMetric:                    {},
+	"scrape_samples_scraped":                {},
+	"scrape_samples_post_metric_relabeling": {},
+	"scrape_series_added":                   {},
+	scrapeTimeoutMetric:                     {},
+	scrapeSampleLimitMetric:                 {},
+	scrapeBodySizeMetric:                    {},
+}
+
+// reportPhase tells the report series of a scrape apart from the samples of
+// its target, which may be named like them.
+//
+// The scrape loop appends the report series after the samples of the target,
+// once it recorded the scrape on the target, so the scrape loop is reporting
+// from its first up sample with the timestamp of the last scrape of the
+// target. The stale report series are appended once the target is no longer
+// scraped, after the last scrape of the target.
+type reportPhase struct {
+	target    *scrape.Target
+	reporting bool
+}
+
+// isReportSeries returns whether the sample l, appended at t with the value
+// v, is a report series.
+func (p *reportPhase) isReportSeries(l labels.Labels, t int64, v float64) bool {
+	if p.target == nil {
+		return false
+	}
+	name := l.Get(labels.MetricName)
+	if !p.reporting && name == upMetric {
+		last := p.target.LastScrape()
+		switch {
+		case !last.IsZero() && t == timestamp.FromTime(last):
+			p.reporting = true
+		case value.IsStaleNaN(v) && (last.IsZero() || t > timestamp.FromTime(last)):
+			p.reporting = true
+		}
+	}
+	if !p.reporting {
+		return false
+	}
+	_, ok := reportSeries[name]
+	return ok
+}
+
+// reportMetrics holds the per-target metrics recorded from the report series
+// of the scrapes, and the throughput of the component.
+type reportMetrics struct {
+	bodySize     *client_prometheus.GaugeVec
+	responseTime *client_prometheus.HistogramVec
+	throughput   *throughput.Metrics
+}
+
+func newReportMetrics(reg client_prometheus.Registerer) (*reportMetrics, error) {
+	m := &reportMetrics{
+		bodySize: client_prometheus.NewGaugeVec(client_prometheus.GaugeOpts{
+			Name: "agent_prometheus_scrape_body_size_bytes",
+			Help: "Uncompressed size of the last response body of each target, or -1 if it exceeded body_size_limit.",
+		}, []string{"target"}),
+		responseTime: client_prometheus.NewHistogramVec(client_prometheus.HistogramOpts{
+			Name:    "agent_prometheus_scrape_response_time_seconds",
+			Help:    "Time taken to scrape each target.",
+			Buckets: client_prometheus.DefBuckets,
+		}, []string{"target"}),
+	}
+	for _, c := range []client_prometheus.Collector{m.bodySize, m.responseTime} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	m.throughput, err = throughput.New(reg, throughput.SignalMetrics, true)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// reportAppendable wraps an Appendable to record the per-target metrics of
+// reportMetrics from the report series of each scrape. The body size of the
+// scrapes is only known when extra metrics are enabled.
+type reportAppendable struct {
+	next    storage.Appendable
+	metrics *reportMetrics
+}
+
+var _ storage.Appendable = (*reportAppendable)(nil)
+
+func newReportAppendable(next storage.Appendable, metrics *reportMetrics) *reportAppendable {
+	return &reportAppendable{next: next, metrics: metrics}
+}
+
+// Appender implements storage.Appendable.
+func (ra *reportAppendable) Appender(ctx context.Context) storage.Appender {
+	app := &reportAppender{
+		Appender: ra.next.Appender(ctx),
+		metrics:  ra.metrics,
+	}
+	if target, ok := scrape.TargetFromContext(ctx); ok && target != nil {
+		app.phase.target = target
+		app.target = target.URL().String()
+	}
+	return app
+}
+
+type reportAppender struct {
+	storage.Appender
+	metrics *reportMetrics
+	phase   reportPhase
+	target  string
+
+	// The samples of the target and the size of their scrape, recorded as the
+	// throughput of the component once committed.
+	samples  int
+	bodySize int
+}
+
+var _ storage.Appender = (*reportAppender)(nil)
+
+// Append implements storage.Appender.
+func (app *reportAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if app.target == "" {
+		return app.Appender.Append(ref, l, t, v)
+	}
+
+	if !app.phase.isReportSeries(l, t, v) {
+		app.samples++
+		return app.Appender.Append(ref, l, t, v)
+	}
+
+	switch name := l.Get(labels.MetricName); {
+	case name == upMetric:
+		// Stale report series are appended once the target is no longer
+		// scraped.
+		if value.IsStaleNaN(v) {
+			app.metrics.bodySize.DeleteLabelValues(app.target)
+			app.metrics.responseTime.DeleteLabelValues(app.target)
+		}
+	case name == scrapeDurationMetric && !math.IsNaN(v):
+		app.metrics.responseTime.WithLabelValues(app.target).Observe(v)
+	case name == scrapeBodySizeMetric && !math.IsNaN(v):
+		app.metrics.bodySize.WithLabelValues(app.target).Set(v)
+		if v > 0 {
+			app.bodySize = int(v)
+		}
+	}
+	return app.Appender.Append(ref, l, t, v)
+}
+
+// AppendHistogram implements storage.Appender.
+func (app *reportAppender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	if app.target != "" {
+		app.samples++
+	}
+	return app.Appender.AppendHistogram(ref, l, t, h, fh)
+}
+
+// Commit implements storage.Appender.
+func (app *reportAppender) Commit() error {
+	err := app.Appender.Commit()
+	if err == nil && app.target != "" {
+		app.metrics.throughput.Observe(app.samples, app.bodySize)
+	}
+	return err
+}
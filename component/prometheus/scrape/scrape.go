@@ -4,14 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/alecthomas/units"
 	"github.com/grafana/agent/component"
 	component_config "github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/component/common/loki"
 	"github.com/grafana/agent/component/discovery"
 	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/useragent"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/service/cluster"
@@ -22,16 +25,19 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/scrape"
 	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
 )
 
 func init() {
 	scrape.UserAgent = useragent.Get()
 
 	component.Register(component.Registration{
-		Name: "prometheus.scrape",
-		Args: Arguments{},
+		Name:      "prometheus.scrape",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
@@ -55,6 +61,8 @@ type Arguments struct {
 	Params url.Values `river:"params,attr,optional"`
 	// Whether to scrape a classic histogram that is also exposed as a native histogram.
 	ScrapeClassicHistograms bool `river:"scrape_classic_histograms,attr,optional"`
+	// Whether to convert classic histograms into native histograms.
+	ConvertClassicHistograms bool `river:"convert_classic_histograms,attr,optional"`
 	// How frequently to scrape the targets of this scrape config.
 	ScrapeInterval time.Duration `river:"scrape_interval,attr,optional"`
 	// The timeout for scraping targets of this config.
@@ -88,19 +96,44 @@ type Arguments struct {
 	ExtraMetrics              bool `river:"extra_metrics,attr,optional"`
 	EnableProtobufNegotiation bool `river:"enable_protobuf_negotiation,attr,optional"`
 
+	// Largest allowed difference between the timestamps of the scraped
+	// samples and the local time, and what to do with the scrapes exceeding
+	// it.
+	TimestampSkewTolerance time.Duration `river:"timestamp_skew_tolerance,attr,optional"`
+	TimestampSkewAction    string        `river:"timestamp_skew_action,attr,optional"`
+
+	// Whether scraped samples whose value is NaN or infinite are dropped.
+	DropNonFiniteValues bool `river:"drop_non_finite_values,attr,optional"`
+
+	// Expressions dropping or rewriting the scraped samples.
+	Transform *TransformArguments `river:"transform,block,optional"`
+
+	// Number of scrape intervals without a completed scrape after which the
+	// scrape loops are restarted. 0 disables the watchdog.
+	WatchdogIntervals uint `river:"watchdog_intervals,attr,optional"`
+
+	// Discovery labels of the targets to keep on the scraped series, without
+	// their __meta_ prefix.
+	KeepMetaLabels []string `river:"keep_meta_labels,attr,optional"`
+
+	// Receivers of the log entries emitted for the failed scrapes.
+	FailureLogsForwardTo []loki.LogsReceiver `river:"failure_logs_forward_to,attr,optional"`
+
 	Clustering cluster.ComponentBlock `river:"clustering,block,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
 func (arg *Arguments) SetToDefault() {
 	*arg = Arguments{
-		MetricsPath:      "/metrics",
-		Scheme:           "http",
-		HonorLabels:      false,
-		HonorTimestamps:  true,
-		HTTPClientConfig: component_config.DefaultHTTPClientConfig,
-		ScrapeInterval:   1 * time.Minute,  // From config.DefaultGlobalConfig
-		ScrapeTimeout:    10 * time.Second, // From config.DefaultGlobalConfig
+		MetricsPath:         "/metrics",
+		Scheme:              "http",
+		HonorLabels:         false,
+		HonorTimestamps:     true,
+		HTTPClientConfig:    component_config.DefaultHTTPClientConfig,
+		ScrapeInterval:      1 * time.Minute,  // From config.DefaultGlobalConfig
+		ScrapeTimeout:       10 * time.Second, // From config.DefaultGlobalConfig
+		TimestampSkewAction: SkewActionWarn,
+		WatchdogIntervals:   DefaultWatchdogIntervals,
 	}
 }
 
@@ -109,6 +142,17 @@ func (arg *Arguments) Validate() error {
 	if arg.ScrapeTimeout > arg.ScrapeInterval {
 		return fmt.Errorf("scrape_timeout (%s) greater than scrape_interval (%s) for scrape config with job name %q", arg.ScrapeTimeout, arg.ScrapeInterval, arg.JobName)
 	}
+	if arg.TimestampSkewTolerance < 0 {
+		return fmt.Errorf("timestamp_skew_tolerance must not be negative")
+	}
+	if arg.TimestampSkewAction != SkewActionWarn && arg.TimestampSkewAction != SkewActionFail {
+		return fmt.Errorf("timestamp_skew_action must be %q or %q, got %q", SkewActionWarn, SkewActionFail, arg.TimestampSkewAction)
+	}
+	for _, name := range arg.KeepMetaLabels {
+		if !strings.HasPrefix(name, model.MetaLabelPrefix) || !model.LabelName(name).IsValid() || name == model.MetaLabelPrefix {
+			return fmt.Errorf("keep_meta_labels must only contain discovery labels starting with %q, got %q", model.MetaLabelPrefix, name)
+		}
+	}
 
 	// We must explicitly Validate because HTTPClientConfig is squashed and it won't run otherwise
 	return arg.HTTPClientConfig.Validate()
@@ -120,16 +164,28 @@ type Component struct {
 	cluster cluster.Cluster
 
 	reloadTargets chan struct{}
-
-	mut          sync.RWMutex
-	args         Arguments
-	scraper      *scrape.Manager
-	appendable   *prometheus.Fanout
-	targetsGauge client_prometheus.Gauge
+	paused        atomic.Bool
+
+	mut     sync.RWMutex
+	args    Arguments
+	scraper *scrape.Manager
+	// The options and appendable new scrape managers are created with when
+	// the watchdog restarts the scrape loops.
+	scrapeOptions    *scrape.Options
+	scrapeAppendable storage.Appendable
+	appendable       *prometheus.Fanout
+	histograms       *classicHistogramAppendable
+	skew             *skewAppendable
+	transform        *transformAppendable
+	nonFinite        *nonFiniteAppendable
+	watchdog         *watchdog
+	failureLogs      *failureLogsAppendable
+	targetsGauge     client_prometheus.Gauge
 }
 
 var (
-	_ component.Component = (*Component)(nil)
+	_ component.Component         = (*Component)(nil)
+	_ component.PausableComponent = (*Component)(nil)
 )
 
 // New creates a new prometheus.scrape component.
@@ -152,6 +208,11 @@ func New(o component.Options, args Arguments) (*Component, error) {
 	}
 	ls := service.(labelstore.LabelStore)
 
+	metrics, err := newReportMetrics(o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
 	flowAppendable := prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
 	scrapeOptions := &scrape.Options{
 		ExtraMetrics: args.ExtraMetrics,
@@ -159,8 +220,33 @@ func New(o component.Options, args Arguments) (*Component, error) {
 			config_util.WithDialContextFunc(httpData.DialFunc),
 		},
 		EnableProtobufNegotiation: args.EnableProtobufNegotiation,
+		// Pass the scrape target in the Appender context so that scrape spans
+		// can be annotated with the target being scraped.
+		PassMetadataInContext: true,
+	}
+	histogramsAppendable := newClassicHistogramAppendable(flowAppendable, ls)
+	reportAppendable := newReportAppendable(histogramsAppendable, metrics)
+	skewAppendable, err := newSkewAppendable(reportAppendable, o.Logger, o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	nonFiniteAppendable, err := newNonFiniteAppendable(skewAppendable, o.Registerer)
+	if err != nil {
+		return nil, err
 	}
-	scraper := scrape.NewManager(scrapeOptions, o.Logger, flowAppendable)
+	transformAppendable, err := newTransformAppendable(nonFiniteAppendable, o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	failureLogsAppendable, err := newFailureLogsAppendable(newTracingAppendable(transformAppendable, o.Tracer), o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	watchdog, err := newWatchdog(o.Logger, o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	scraper := scrape.NewManager(scrapeOptions, o.Logger, failureLogsAppendable)
 
 	targetsGauge := client_prometheus.NewGauge(client_prometheus.GaugeOpts{
 		Name: "agent_prometheus_scrape_targets_gauge",
@@ -171,12 +257,20 @@ func New(o component.Options, args Arguments) (*Component, error) {
 	}
 
 	c := &Component{
-		opts:          o,
-		cluster:       clusterData,
-		reloadTargets: make(chan struct{}, 1),
-		scraper:       scraper,
-		appendable:    flowAppendable,
-		targetsGauge:  targetsGauge,
+		opts:             o,
+		cluster:          clusterData,
+		reloadTargets:    make(chan struct{}, 1),
+		scraper:          scraper,
+		scrapeOptions:    scrapeOptions,
+		scrapeAppendable: failureLogsAppendable,
+		appendable:       flowAppendable,
+		histograms:       histogramsAppendable,
+		skew:             skewAppendable,
+		transform:        transformAppendable,
+		nonFinite:        nonFiniteAppendable,
+		watchdog:         watchdog,
+		failureLogs:      failureLogsAppendable,
+		targetsGauge:     targetsGauge,
 	}
 
 	// Call to Update() to set the receivers and targets once at the start.
@@ -189,22 +283,39 @@ func New(o component.Options, args Arguments) (*Component, error) {
 
 // Run implements component.Component.
 func (c *Component) Run(ctx context.Context) error {
-	defer c.scraper.Stop()
+	defer func() {
+		c.mut.RLock()
+		defer c.mut.RUnlock()
+		c.scraper.Stop()
+	}()
 
-	targetSetsChan := make(chan map[string][]*targetgroup.Group)
+	go c.failureLogs.Run(ctx)
 
-	go func() {
-		err := c.scraper.Run(targetSetsChan)
-		level.Info(c.opts.Logger).Log("msg", "scrape manager stopped")
-		if err != nil {
-			level.Error(c.opts.Logger).Log("msg", "scrape manager failed", "err", err)
-		}
-	}()
+	c.mut.RLock()
+	targetSetsChan := c.runScraper(c.scraper)
+	c.mut.RUnlock()
+
+	watchdogTicker := time.NewTicker(watchdogCheckInterval)
+	defer watchdogTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-watchdogTicker.C:
+			c.mut.RLock()
+			var (
+				scraper   = c.scraper
+				intervals = c.args.WatchdogIntervals
+			)
+			c.mut.RUnlock()
+			if intervals == 0 || c.paused.Load() {
+				continue
+			}
+			if c.watchdog.Check(scraper.TargetsActive(), intervals, time.Now()) {
+				targetSetsChan = c.restartScraper()
+				c.scheduleReload()
+			}
 		case <-c.reloadTargets:
 			c.mut.RLock()
 			var (
@@ -217,7 +328,15 @@ func (c *Component) Run(ctx context.Context) error {
 			}
 			c.mut.RUnlock()
 
-			promTargets := c.distTargets(targets, jobName, clusteringEnabled)
+			// No target is scraped while paused. The scrape manager stops the
+			// scrape loops, which write staleness markers for their series.
+			var promTargets map[string][]*targetgroup.Group
+			if c.paused.Load() {
+				c.targetsGauge.Set(0)
+				promTargets = c.componentTargetsToProm(jobName, nil)
+			} else {
+				promTargets = c.distTargets(targets, jobName, clusteringEnabled)
+			}
 
 			select {
 			case targetSetsChan <- promTargets:
@@ -228,6 +347,42 @@ func (c *Component) Run(ctx context.Context) error {
 	}
 }
 
+// runScraper runs scraper in the background, and returns the channel its
+// target sets are passed through.
+func (c *Component) runScraper(scraper *scrape.Manager) chan map[string][]*targetgroup.Group {
+	targetSetsChan := make(chan map[string][]*targetgroup.Group)
+	go func() {
+		err := scraper.Run(targetSetsChan)
+		level.Info(c.opts.Logger).Log("msg", "scrape manager stopped")
+		if err != nil {
+			level.Error(c.opts.Logger).Log("msg", "scrape manager failed", "err", err)
+		}
+	}()
+	return targetSetsChan
+}
+
+// restartScraper replaces the scrape manager with a new one, which scrapes the
+// targets with new scrape loops once they're passed to it through the
+// returned channel. The old scrape manager is stopped in the background, as
+// it waits for its stalled scrape loops, which are left behind until they
+// complete their scrape.
+func (c *Component) restartScraper() chan map[string][]*targetgroup.Group {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	old := c.scraper
+	scraper := scrape.NewManager(c.scrapeOptions, c.opts.Logger, c.scrapeAppendable)
+	sc := getPromScrapeConfigs(c.opts.ID, c.args)
+	if err := scraper.ApplyConfig(&config.Config{ScrapeConfigs: []*config.ScrapeConfig{sc}}); err != nil {
+		// The configuration was already applied to the old scrape manager.
+		level.Error(c.opts.Logger).Log("msg", "failed to apply scrape configs to the new scrape manager", "err", err)
+	}
+	c.scraper = scraper
+	go old.Stop()
+
+	return c.runScraper(scraper)
+}
+
 // Update implements component.Component.
 func (c *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
@@ -237,6 +392,13 @@ func (c *Component) Update(args component.Arguments) error {
 	c.args = newArgs
 
 	c.appendable.UpdateChildren(newArgs.ForwardTo)
+	c.histograms.SetEnabled(newArgs.ConvertClassicHistograms)
+	c.skew.SetTolerance(newArgs.TimestampSkewTolerance, newArgs.TimestampSkewAction == SkewActionFail)
+	c.nonFinite.SetEnabled(newArgs.DropNonFiniteValues)
+	c.failureLogs.SetReceivers(newArgs.FailureLogsForwardTo)
+	if err := c.transform.SetTransform(newArgs.Transform); err != nil {
+		return err
+	}
 
 	sc := getPromScrapeConfigs(c.opts.ID, newArgs)
 	err := c.scraper.ApplyConfig(&config.Config{
@@ -247,10 +409,7 @@ func (c *Component) Update(args component.Arguments) error {
 	}
 	level.Debug(c.opts.Logger).Log("msg", "scrape config was updated")
 
-	select {
-	case c.reloadTargets <- struct{}{}:
-	default:
-	}
+	c.scheduleReload()
 
 	return nil
 }
@@ -265,6 +424,32 @@ func (c *Component) NotifyClusterChange() {
 	}
 
 	// Schedule a reload so targets get redistributed.
+	c.scheduleReload()
+}
+
+// Pause implements component.PausableComponent. Targets aren't scraped while
+// the component is paused.
+func (c *Component) Pause() {
+	c.setPaused(true)
+}
+
+// Resume implements component.PausableComponent.
+func (c *Component) Resume() {
+	c.setPaused(false)
+}
+
+func (c *Component) setPaused(paused bool) {
+	if c.paused.Swap(paused) == paused {
+		return
+	}
+
+	// Schedule a reload so targets get dropped or scraped again.
+	c.scheduleReload()
+}
+
+// scheduleReload schedules the targets to be passed again to the scrape
+// manager.
+func (c *Component) scheduleReload() {
 	select {
 	case c.reloadTargets <- struct{}{}:
 	default:
@@ -275,7 +460,7 @@ func (c *Component) NotifyClusterChange() {
 // scrape_config.
 // As explained in the Config struct, the following fields are purposefully
 // missing out, as they're being implemented by another components.
-// - RelabelConfigs
+// - RelabelConfigs, apart from the rules keeping the KeepMetaLabels
 // - MetricsRelabelConfigs
 // - ServiceDiscoveryConfigs
 func getPromScrapeConfigs(jobName string, c Arguments) *config.ScrapeConfig {
@@ -302,6 +487,19 @@ func getPromScrapeConfigs(jobName string, c Arguments) *config.ScrapeConfig {
 
 	// HTTP scrape client settings
 	dec.HTTPClientConfig = *c.HTTPClientConfig.Convert()
+
+	// The discovery labels are dropped after the target relabeling, so the
+	// ones to keep are copied to labels without the __meta_ prefix.
+	for _, name := range c.KeepMetaLabels {
+		dec.RelabelConfigs = append(dec.RelabelConfigs, &relabel.Config{
+			SourceLabels: model.LabelNames{model.LabelName(name)},
+			Separator:    relabel.DefaultRelabelConfig.Separator,
+			Regex:        relabel.MustNewRegexp("(.+)"),
+			TargetLabel:  strings.TrimPrefix(name, model.MetaLabelPrefix),
+			Replacement:  "$1",
+			Action:       relabel.Replace,
+		})
+	}
 	return &dec
 }
 
@@ -363,8 +561,12 @@ func BuildTargetStatuses(targets map[string][]*scrape.Target) []TargetStatus {
 
 // DebugInfo implements component.DebugComponent
 func (c *Component) DebugInfo() interface{} {
+	c.mut.RLock()
+	scraper := c.scraper
+	c.mut.RUnlock()
+
 	return ScraperStatus{
-		TargetStatus: BuildTargetStatuses(c.scraper.TargetsActive()),
+		TargetStatus: BuildTargetStatuses(scraper.TargetsActive()),
 	}
 }
 
@@ -3,24 +3,49 @@ package scrape
 import (
 	"context"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/loki"
+	"github.com/grafana/agent/component/discovery"
+	lokiwrite "github.com/grafana/agent/component/loki/write"
+	"github.com/grafana/agent/component/otelcol"
 	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/component/prometheus/remotewrite"
+	"github.com/grafana/agent/pkg/flow/tracing"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/agent/service/cluster"
 	http_service "github.com/grafana/agent/service/http"
 	"github.com/grafana/agent/service/labelstore"
 	"github.com/grafana/ckit/memconn"
+	"github.com/grafana/loki/pkg/logproto"
+	loki_util "github.com/grafana/loki/pkg/util"
 	"github.com/grafana/river"
 	prometheus_client "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	promql_parser "github.com/prometheus/prometheus/promql/parser"
+	prom_scrape "github.com/prometheus/prometheus/scrape"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/atomic"
 )
 
 func TestRiverConfig(t *testing.T) {
@@ -210,6 +235,1107 @@ func TestCustomDialer(t *testing.T) {
 	require.NoError(t, err, "custom dialer was not used")
 }
 
+// TestFollowRedirects ensures that prometheus.scrape follows redirects
+// returned by targets only when follow_redirects is enabled, and gives up on
+// redirect loops.
+func TestFollowRedirects(t *testing.T) {
+	reg := prometheus_client.NewRegistry()
+	reg.MustRegister(prometheus_client.NewGauge(prometheus_client.GaugeOpts{Name: "redirected_metric"}))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", http.RedirectHandler("/redirected", http.StatusFound))
+	mux.Handle("/loop", http.RedirectHandler("/loop", http.StatusFound))
+	mux.Handle("/redirected", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name            string
+		followRedirects bool
+		metricsPath     string
+		expectUp        float64
+		expectMetric    bool
+	}{
+		{name: "follow", followRedirects: true, metricsPath: "/metrics", expectUp: 1, expectMetric: true},
+		{name: "don't follow", followRedirects: false, metricsPath: "/metrics", expectUp: 0},
+		{name: "loop", followRedirects: true, metricsPath: "/loop", expectUp: 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			type scrape struct {
+				up     float64
+				metric bool
+			}
+			var (
+				scrapes = make(chan scrape, 10)
+				current scrape
+			)
+			sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+				switch l.Get(labels.MetricName) {
+				case "redirected_metric":
+					current.metric = true
+				case "up":
+					current.up = v
+					select {
+					case scrapes <- current:
+					default:
+					}
+					current = scrape{}
+				}
+				return ref, nil
+			}))
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+			targets          = [{ __address__ = %q }]
+			forward_to       = []
+			metrics_path     = %q
+			follow_redirects = %t
+			scrape_interval  = "100ms"
+			scrape_timeout   = "85ms"
+			`, strings.TrimPrefix(srv.URL, "http://"), tc.metricsPath, tc.followRedirects)), &args))
+			args.ForwardTo = []storage.Appendable{sink}
+
+			s, err := New(testOptions(t), args)
+			require.NoError(t, err)
+			go s.Run(ctx)
+
+			select {
+			case res := <-scrapes:
+				require.Equal(t, tc.expectUp, res.up)
+				require.Equal(t, tc.expectMetric, res.metric)
+			case <-time.After(30 * time.Second):
+				require.FailNow(t, "target was never scraped")
+			}
+		})
+	}
+}
+
+// TestEnableHTTP2 ensures that prometheus.scrape negotiates HTTP/2 with
+// targets supporting it only when enable_http2 is enabled.
+func TestEnableHTTP2(t *testing.T) {
+	tests := []struct {
+		enableHTTP2 bool
+		expected    string
+	}{
+		{enableHTTP2: true, expected: "HTTP/2.0"},
+		{enableHTTP2: false, expected: "HTTP/1.1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			protos := make(chan string, 10)
+			handler := promhttp.HandlerFor(prometheus_client.NewRegistry(), promhttp.HandlerOpts{})
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case protos <- r.Proto:
+				default:
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			defer srv.Close()
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+			targets         = [{ __address__ = %q }]
+			forward_to      = []
+			scheme          = "https"
+			enable_http2    = %t
+			scrape_interval = "100ms"
+			scrape_timeout  = "85ms"
+
+			tls_config {
+				insecure_skip_verify = true
+			}
+			`, strings.TrimPrefix(srv.URL, "https://"), tc.enableHTTP2)), &args))
+
+			s, err := New(testOptions(t), args)
+			require.NoError(t, err)
+			go s.Run(ctx)
+
+			select {
+			case proto := <-protos:
+				require.Equal(t, tc.expected, proto)
+			case <-time.After(30 * time.Second):
+				require.FailNow(t, "target was never scraped")
+			}
+		})
+	}
+}
+
+// TestConvertClassicHistograms ensures that prometheus.scrape forwards the
+// classic histograms of targets as native histograms when
+// convert_classic_histograms is enabled.
+func TestConvertClassicHistograms(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{le="0.1"} 1
+request_duration_seconds_bucket{le="1"} 3
+request_duration_seconds_bucket{le="+Inf"} 4
+request_duration_seconds_sum 7.5
+request_duration_seconds_count 4
+# TYPE incomplete_seconds histogram
+incomplete_seconds_bucket{le="0.1"} 1
+incomplete_seconds_bucket{le="1"} 3
+incomplete_seconds_sum 2
+incomplete_seconds_count 3
+`)
+	}))
+	defer srv.Close()
+
+	type scrape struct {
+		histograms map[string]*histogram.FloatHistogram
+		samples    map[string]float64
+	}
+	var (
+		scrapes = make(chan scrape, 10)
+		current = scrape{histograms: map[string]*histogram.FloatHistogram{}, samples: map[string]float64{}}
+	)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil),
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+			name := l.Get(labels.MetricName)
+			if name == "up" {
+				select {
+				case scrapes <- current:
+				default:
+				}
+				current = scrape{histograms: map[string]*histogram.FloatHistogram{}, samples: map[string]float64{}}
+				return ref, nil
+			}
+			if le := l.Get(labels.BucketLabel); le != "" {
+				name += "{le=" + le + "}"
+			}
+			current.samples[name] = v
+			return ref, nil
+		}),
+		prometheus.WithHistogramHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ *histogram.Histogram, fh *histogram.FloatHistogram, _ storage.Appender) (storage.SeriesRef, error) {
+			current.histograms[l.Get(labels.MetricName)] = fh
+			return ref, nil
+		}),
+	)
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets                    = [{ __address__ = %q }]
+	forward_to                 = []
+	convert_classic_histograms = true
+	scrape_interval            = "100ms"
+	scrape_timeout             = "85ms"
+	`, strings.TrimPrefix(srv.URL, "http://"))), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+
+	s, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	// Histograms are forwarded when the scrape is committed, after the report
+	// series, so they are collected along with the report series of the next
+	// scrape.
+	var res scrape
+	for i := 0; i < 2; i++ {
+		select {
+		case res = <-scrapes:
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "target was never scraped")
+		}
+	}
+
+	fh := res.histograms["request_duration_seconds"]
+	require.NotNil(t, fh, "classic histogram wasn't converted")
+	require.Equal(t, 4.0, fh.Count)
+	require.Equal(t, 7.5, fh.Sum)
+	total := fh.ZeroCount
+	it := fh.PositiveBucketIterator()
+	for it.Next() {
+		total += it.At().Count
+	}
+	require.InDelta(t, 4.0, total, 1e-9)
+
+	// The histogram without a +Inf bucket can't be converted and is forwarded
+	// as is.
+	require.NotContains(t, res.histograms, "incomplete_seconds")
+	require.Equal(t, map[string]float64{
+		"incomplete_seconds_bucket{le=0.1}": 1,
+		"incomplete_seconds_bucket{le=1}":   3,
+		"incomplete_seconds_sum":            2,
+		"incomplete_seconds_count":          3,
+	}, filterSamples(res.samples, "incomplete_seconds", "request_duration_seconds"))
+}
+
+// filterSamples returns the samples of the series starting with any of the
+// prefixes.
+func filterSamples(samples map[string]float64, prefixes ...string) map[string]float64 {
+	res := make(map[string]float64)
+	for name, v := range samples {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				res[name] = v
+			}
+		}
+	}
+	return res
+}
+
+// TestReportMetrics ensures that prometheus.scrape records the body size and
+// response time of the scrapes of each target from the extra report series,
+// and fails the scrapes of targets exceeding body_size_limit.
+func TestReportMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	small := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "small_metric 1")
+	}))
+	defer small.Close()
+	large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 100; i++ {
+			_, _ = fmt.Fprintf(w, "large_metric{index=\"%d\"} 1\n", i)
+		}
+	}))
+	defer large.Close()
+
+	var (
+		ups   = make(chan string, 100)
+		extra atomic.Bool
+	)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		switch l.Get(labels.MetricName) {
+		case "up":
+			select {
+			case ups <- fmt.Sprintf("%s=%v", l.Get("instance"), v):
+			default:
+			}
+		case "scrape_timeout_seconds", "scrape_sample_limit", "scrape_body_size_bytes":
+			extra.Store(true)
+		}
+		return ref, nil
+	}))
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets         = [{ __address__ = %q }, { __address__ = %q }]
+	forward_to      = []
+	body_size_limit = "1KiB"
+	extra_metrics   = true
+	scrape_interval = "100ms"
+	scrape_timeout  = "85ms"
+	`, strings.TrimPrefix(small.URL, "http://"), strings.TrimPrefix(large.URL, "http://"))), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+
+	reg := prometheus_client.NewRegistry()
+	opts := testOptions(t)
+	opts.Registerer = reg
+	s, err := New(opts, args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	// Wait for both targets to be scraped twice.
+	seen := make(map[string]int)
+	for len(seen) < 2 || seen[strings.TrimPrefix(small.URL, "http://")+"=1"] < 2 || seen[strings.TrimPrefix(large.URL, "http://")+"=0"] < 2 {
+		select {
+		case up := <-ups:
+			seen[up]++
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "targets were never scraped", "%v", seen)
+		}
+	}
+	cancel()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var (
+		bodySizes = make(map[string]float64)
+		scrapes   = make(map[string]uint64)
+	)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetName() {
+			case "agent_prometheus_scrape_body_size_bytes":
+				bodySizes[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+			case "agent_prometheus_scrape_response_time_seconds":
+				scrapes[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	require.Equal(t, map[string]float64{
+		small.URL + "/metrics": float64(len("small_metric 1\n")),
+		// The body size of scrapes exceeding the limit is unknown.
+		large.URL + "/metrics": -1,
+	}, bodySizes)
+	require.GreaterOrEqual(t, scrapes[small.URL+"/metrics"], uint64(2))
+	require.GreaterOrEqual(t, scrapes[large.URL+"/metrics"], uint64(2))
+
+	// The extra report series are still forwarded.
+	require.True(t, extra.Load())
+}
+
+// TestReportSeries ensures that only the series appended once the scrape was
+// reported are told apart as the report series, even if the samples of the
+// target are named like them.
+func TestReportSeries(t *testing.T) {
+	reg := prometheus_client.NewRegistry()
+	metrics, err := newReportMetrics(reg)
+	require.NoError(t, err)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil))
+	skew, err := newSkewAppendable(newReportAppendable(sink, metrics), log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	target := prom_scrape.NewTarget(labels.FromStrings(model.AddressLabel, "example:9090", model.SchemeLabel, "http", model.MetricsPathLabel, "/metrics"), labels.EmptyLabels(), nil)
+	app := skew.Appender(prom_scrape.ContextWithTarget(context.Background(), target))
+
+	type sample struct {
+		name string
+		t    time.Time
+		v    float64
+	}
+	appendSamples := func(samples ...sample) {
+		for _, s := range samples {
+			_, err := app.Append(0, labels.FromStrings(labels.MetricName, s.name), s.t.UnixMilli(), s.v)
+			require.NoError(t, err)
+		}
+	}
+
+	now := time.Now()
+	// The samples of the target, some named like report series.
+	appendSamples(
+		sample{upMetric, now, 1},
+		sample{"skewed_metric", now.Add(-time.Hour), 1},
+		sample{scrapeDurationMetric, now, 100},
+	)
+	// The report series, appended once the scrape is reported.
+	target.Report(now, time.Second, nil)
+	appendSamples(
+		sample{upMetric, now, 1},
+		sample{scrapeDurationMetric, now, 1},
+		sample{scrapeBodySizeMetric, now, 10},
+	)
+	require.NoError(t, app.Commit())
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var (
+		items        float64
+		bytes        float64
+		responseTime float64
+	)
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "agent_component_throughput_items_total":
+			items = mf.GetMetric()[0].GetCounter().GetValue()
+		case "agent_component_throughput_bytes_total":
+			bytes = mf.GetMetric()[0].GetCounter().GetValue()
+		case "agent_prometheus_scrape_response_time_seconds":
+			responseTime = mf.GetMetric()[0].GetHistogram().GetSampleSum()
+		}
+	}
+	require.Equal(t, 3.0, items)
+	require.Equal(t, 10.0, bytes)
+	require.Equal(t, 1.0, responseTime)
+	require.InDelta(t, -time.Hour.Seconds(), testutil.ToFloat64(skew.skew), 60)
+}
+
+// TestThroughput ensures that prometheus.scrape and prometheus.remote_write
+// report the samples flowing through them while the agent scrapes itself.
+func TestThroughput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	// Both components are scraped, like an agent scraping its own metrics.
+	// Their metrics are labeled by component ID like in Flow.
+	reg := prometheus_client.NewRegistry()
+	componentReg := func(id string) prometheus_client.Registerer {
+		return prometheus_client.WrapRegistererWith(prometheus_client.Labels{"component_id": id}, reg)
+	}
+	target := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer target.Close()
+
+	var writeArgs remotewrite.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	endpoint {
+		url = "%s/api/v1/write"
+	}
+	`, endpoint.URL)), &writeArgs))
+	var receiver storage.Appendable
+	writeOpts := testOptions(t)
+	writeOpts.ID = "prometheus.remote_write.test"
+	writeOpts.Registerer = componentReg(writeOpts.ID)
+	writeOpts.DataPath = t.TempDir()
+	writeOpts.OnStateChange = func(e component.Exports) { receiver = e.(remotewrite.Exports).Receiver }
+	w, err := remotewrite.New(writeOpts, writeArgs)
+	require.NoError(t, err)
+	go w.Run(ctx)
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets         = [{ __address__ = %q }]
+	forward_to      = []
+	extra_metrics   = true
+	scrape_interval = "100ms"
+	scrape_timeout  = "85ms"
+	`, strings.TrimPrefix(target.URL, "http://"))), &args))
+	args.ForwardTo = []storage.Appendable{receiver}
+
+	opts := testOptions(t)
+	opts.Registerer = componentReg(opts.ID)
+	s, err := New(opts, args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	// throughput returns the value of the throughput metric name of the
+	// component with the given ID.
+	throughput := func(name, id string) float64 {
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		for _, mf := range families {
+			if mf.GetName() != name {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "component_id" && l.GetValue() == id {
+						return m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return 0
+	}
+	require.Eventually(t, func() bool {
+		return throughput("agent_component_throughput_items_total", opts.ID) > 0 &&
+			throughput("agent_component_throughput_items_total", writeOpts.ID) > 0
+	}, 30*time.Second, 50*time.Millisecond)
+	require.Greater(t, throughput("agent_component_throughput_bytes_total", opts.ID), 0.0)
+}
+
+// TestFailureLogs ensures that prometheus.scrape emits a log entry for every
+// failed scrape which reaches Loki through loki.write.
+func TestFailureLogs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pushes := make(chan logproto.PushRequest, 10)
+	lokiSink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushReq logproto.PushRequest
+		err := loki_util.ParseProtoReader(context.Background(), r.Body, int(r.ContentLength), math.MaxInt32, &pushReq, loki_util.RawSnappy)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		select {
+		case pushes <- pushReq:
+		default:
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer lokiSink.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	var writeArgs lokiwrite.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	endpoint {
+		url        = "%s/loki/api/v1/push"
+		batch_wait = "10ms"
+	}
+	`, lokiSink.URL)), &writeArgs))
+	receivers := make(chan loki.LogsReceiver, 1)
+	writeOpts := testOptions(t)
+	writeOpts.ID = "loki.write.test"
+	writeOpts.DataPath = t.TempDir()
+	writeOpts.OnStateChange = func(e component.Exports) { receivers <- e.(lokiwrite.Exports).Receiver }
+	w, err := lokiwrite.New(writeOpts, writeArgs)
+	require.NoError(t, err)
+	go w.Run(ctx)
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets         = [{ __address__ = %q }]
+	forward_to      = []
+	job_name        = "broken"
+	scrape_interval = "100ms"
+	scrape_timeout  = "85ms"
+	`, strings.TrimPrefix(target.URL, "http://"))), &args))
+	args.FailureLogsForwardTo = []loki.LogsReceiver{<-receivers}
+
+	s, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	select {
+	case pushReq := <-pushes:
+		require.NotEmpty(t, pushReq.Streams)
+		stream := pushReq.Streams[0]
+		lbls, err := promql_parser.ParseMetric(stream.Labels)
+		require.NoError(t, err)
+		require.Equal(t, "broken", lbls.Get("job"))
+		require.Equal(t, strings.TrimPrefix(target.URL, "http://"), lbls.Get("instance"))
+
+		require.NotEmpty(t, stream.Entries)
+		line := stream.Entries[0].Line
+		require.Contains(t, line, `msg="scrape failed"`)
+		require.Contains(t, line, fmt.Sprintf("target=%s/metrics", target.URL))
+		require.Contains(t, line, "duration=")
+		require.Contains(t, line, `err="server returned HTTP status 500 Internal Server Error"`)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "failure logs never reached Loki")
+	}
+}
+
+// TestFailureLogsDropped ensures that the failure log entries are dropped
+// instead of blocking the scrapes when the receivers don't keep up.
+func TestFailureLogsDropped(t *testing.T) {
+	reg := prometheus_client.NewRegistry()
+	fa, err := newFailureLogsAppendable(prometheus.NewInterceptor(nil, labelstore.New(nil)), reg)
+	require.NoError(t, err)
+	// The entries are never forwarded, as Run isn't called.
+	fa.SetReceivers([]loki.LogsReceiver{loki.NewLogsReceiver()})
+
+	target := prom_scrape.NewTarget(labels.FromStrings(model.AddressLabel, "example:9090", model.SchemeLabel, "http", model.MetricsPathLabel, "/metrics"), labels.EmptyLabels(), nil)
+	ctx := prom_scrape.ContextWithTarget(context.Background(), target)
+
+	now := time.Now()
+	for i := 0; i < maxPendingFailureLogs+10; i++ {
+		ts := now.Add(time.Duration(i) * time.Second)
+		target.Report(ts, time.Second, fmt.Errorf("scrape failed"))
+
+		app := fa.Appender(ctx)
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, upMetric), ts.UnixMilli(), 0)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+	require.Len(t, fa.entries, maxPendingFailureLogs)
+	require.Equal(t, 10.0, testutil.ToFloat64(fa.dropped))
+}
+
+// TestTimestampSkew ensures that prometheus.scrape records how far the
+// timestamps of the scraped samples are from the local time, and warns about
+// or fails the scrapes of targets exceeding timestamp_skew_tolerance.
+func TestTimestampSkew(t *testing.T) {
+	skewedTarget := func(skew time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, "skewed_metric 1 %d\n", time.Now().Add(skew).UnixMilli())
+		}))
+	}
+	// Samples more than 10 minutes in the future are dropped by the scrape
+	// manager before being appended.
+	future, past := skewedTarget(5*time.Minute), skewedTarget(-time.Hour)
+	defer future.Close()
+	defer past.Close()
+	var (
+		futureURL = future.URL + "/metrics"
+		pastURL   = past.URL + "/metrics"
+	)
+
+	for _, action := range []string{SkewActionWarn, SkewActionFail} {
+		t.Run(action, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var (
+				ups      = make(chan string, 100)
+				warnings sync.Map
+			)
+			sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+				if l.Get(labels.MetricName) == "up" {
+					select {
+					case ups <- fmt.Sprintf("%s=%v", l.Get("instance"), v):
+					default:
+					}
+				}
+				return ref, nil
+			}))
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+			targets                  = [{ __address__ = %q }, { __address__ = %q }]
+			forward_to               = []
+			scrape_interval          = "100ms"
+			scrape_timeout           = "85ms"
+			timestamp_skew_tolerance = "1m"
+			timestamp_skew_action    = %q
+			`, strings.TrimPrefix(future.URL, "http://"), strings.TrimPrefix(past.URL, "http://"), action)), &args))
+			args.ForwardTo = []storage.Appendable{sink}
+
+			reg := prometheus_client.NewRegistry()
+			opts := testOptions(t)
+			opts.Registerer = reg
+			opts.Logger = log.LoggerFunc(func(keyvals ...interface{}) error {
+				fields := make(map[interface{}]interface{})
+				for i := 0; i+1 < len(keyvals); i += 2 {
+					fields[keyvals[i]] = keyvals[i+1]
+				}
+				if fields["msg"] == "timestamps of scraped samples are skewed from the local time" {
+					warnings.Store(fields["target"], true)
+				}
+				return nil
+			})
+			s, err := New(opts, args)
+			require.NoError(t, err)
+			go s.Run(ctx)
+
+			// Scrapes of skewed targets only fail with the fail action.
+			up := "1"
+			if action == SkewActionFail {
+				up = "0"
+			}
+			seen := make(map[string]int)
+			for seen[strings.TrimPrefix(future.URL, "http://")+"="+up] < 2 || seen[strings.TrimPrefix(past.URL, "http://")+"="+up] < 2 {
+				select {
+				case up := <-ups:
+					seen[up]++
+				case <-time.After(30 * time.Second):
+					require.FailNow(t, "targets were never scraped", "%v", seen)
+				}
+			}
+			cancel()
+
+			families, err := reg.Gather()
+			require.NoError(t, err)
+			skews := make(map[string]float64)
+			for _, mf := range families {
+				if mf.GetName() != "agent_prometheus_scrape_timestamp_skew_seconds" {
+					continue
+				}
+				for _, m := range mf.GetMetric() {
+					skews[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+				}
+			}
+			require.InDelta(t, (5 * time.Minute).Seconds(), skews[futureURL], 10)
+			require.InDelta(t, -time.Hour.Seconds(), skews[pastURL], 10)
+
+			for _, target := range []string{futureURL, pastURL} {
+				_, warned := warnings.Load(target)
+				require.True(t, warned, "no warning logged for %s", target)
+			}
+		})
+	}
+}
+
+func testOptions(t *testing.T) component.Options {
+	return component.Options{
+		ID:         "prometheus.scrape.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			switch name {
+			case http_service.ServiceName:
+				return http_service.Data{
+					HTTPListenAddr:   "localhost:12345",
+					MemoryListenAddr: "agent.internal:1245",
+					BaseHTTPPath:     "/",
+					DialFunc:         (&net.Dialer{}).DialContext,
+				}, nil
+			case cluster.ServiceName:
+				return cluster.Mock(), nil
+			case labelstore.ServiceName:
+				return labelstore.New(nil), nil
+			default:
+				return nil, fmt.Errorf("service %q does not exist", name)
+			}
+		},
+	}
+}
+
+// TestPause ensures that a paused prometheus.scrape component stops scraping
+// its targets, and scrapes them again once resumed.
+func TestPause(t *testing.T) {
+	var scrapes atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scrapes.Inc()
+		_, _ = fmt.Fprintln(w, "paused_metric 1")
+	}))
+	defer srv.Close()
+
+	var (
+		samples = make(chan float64, 100)
+		stale   = make(chan struct{}, 1)
+	)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		switch {
+		case l.Get(labels.MetricName) != "paused_metric":
+		case value.IsStaleNaN(v):
+			select {
+			case stale <- struct{}{}:
+			default:
+			}
+		default:
+			select {
+			case samples <- v:
+			default:
+			}
+		}
+		return ref, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := New(testOptions(t), Arguments{
+		Targets:             []discovery.Target{{"__address__": strings.TrimPrefix(srv.URL, "http://")}},
+		ForwardTo:           []storage.Appendable{sink},
+		MetricsPath:         "/metrics",
+		Scheme:              "http",
+		ScrapeInterval:      100 * time.Millisecond,
+		ScrapeTimeout:       85 * time.Millisecond,
+		TimestampSkewAction: SkewActionWarn,
+	})
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	waitSample := func() {
+		t.Helper()
+		select {
+		case <-samples:
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "target was never scraped")
+		}
+	}
+	waitSample()
+
+	// The series of the target are marked stale once its scrape loop stops.
+	s.Pause()
+	select {
+	case <-stale:
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "target was never dropped")
+	}
+	for len(samples) > 0 {
+		<-samples
+	}
+	before := scrapes.Load()
+	time.Sleep(time.Second)
+	require.Equal(t, before, scrapes.Load(), "target was scraped while paused")
+	require.Empty(t, samples, "samples were emitted while paused")
+
+	s.Resume()
+	waitSample()
+}
+
+// TestTransform ensures that the transform expressions drop and rewrite the
+// scraped samples before they're forwarded, leaving the report series as is.
+func TestTransform(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `queue_length{queue="a"} 3
+queue_length{queue="b"} 42
+queue_length{queue="c"} 10
+`)
+	}))
+	defer srv.Close()
+
+	var (
+		scrapes = make(chan map[string]float64, 10)
+		current = map[string]float64{}
+	)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		if l.Get(labels.MetricName) == "up" {
+			current["up"] = v
+			select {
+			case scrapes <- current:
+			default:
+			}
+			current = map[string]float64{}
+			return ref, nil
+		}
+		if l.Get(labels.MetricName) == "queue_length" {
+			current[l.Get("queue")+"/"+l.Get("size")] = v
+		}
+		return ref, nil
+	}))
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets         = [{ __address__ = %q }]
+	forward_to      = []
+	scrape_interval = "100ms"
+	scrape_timeout  = "85ms"
+
+	transform {
+		drop_if    = "value > 10"
+		value      = "value * 2"
+		set_labels = { size = `+"`"+`value > 5 ? "large" : "small"`+"`"+` }
+	}
+	`, strings.TrimPrefix(srv.URL, "http://"))), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+
+	s, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	select {
+	case res := <-scrapes:
+		require.Equal(t, map[string]float64{
+			"a/small": 6,
+			"c/large": 20,
+			"up":      1,
+		}, res)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "target was never scraped")
+	}
+}
+
+// TestTransformFailure ensures that the samples for which an expression fails
+// are dropped and counted, while the other samples are forwarded.
+func TestTransformFailure(t *testing.T) {
+	var forwarded []string
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		forwarded = append(forwarded, l.Get("queue"))
+		return ref, nil
+	}))
+	ta, err := newTransformAppendable(sink, prometheus_client.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, ta.SetTransform(&TransformArguments{
+		DropIf: "int(labels.queue) > 10",
+	}))
+
+	app := ta.Appender(context.Background())
+	for _, queue := range []string{"1", "a", "2"} {
+		_, err = app.Append(0, labels.FromStrings("__name__", "queue_length", "queue", queue), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+	require.Equal(t, []string{"1", "2"}, forwarded)
+	require.Equal(t, 1.0, testutil.ToFloat64(ta.failed))
+}
+
+// TestTransformSeries ensures that the histograms and exemplars of a series
+// are dropped and rewritten like its samples, and that the labels can be
+// looked up or used as a map.
+func TestTransformSeries(t *testing.T) {
+	var forwarded []string
+	record := func(kind string, l labels.Labels) {
+		forwarded = append(forwarded, kind+" "+l.Get("queue")+"/"+l.Get("size"))
+	}
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil),
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+			record("sample", l)
+			return ref, nil
+		}),
+		prometheus.WithHistogramHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ *histogram.Histogram, _ *histogram.FloatHistogram, _ storage.Appender) (storage.SeriesRef, error) {
+			record("histogram", l)
+			return ref, nil
+		}),
+		prometheus.WithExemplarHook(func(ref storage.SeriesRef, l labels.Labels, _ exemplar.Exemplar, _ storage.Appender) (storage.SeriesRef, error) {
+			record("exemplar", l)
+			return ref, nil
+		}),
+	)
+	ta, err := newTransformAppendable(sink, prometheus_client.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, ta.SetTransform(&TransformArguments{
+		DropIf: `labels["queue"] == "dropped" || labels.missing != ""`,
+		SetLabels: map[string]string{
+			"size": `len(keys(labels)) > 2 ? "many" : (value > 5 ? "large" : "small")`,
+		},
+	}))
+
+	app := ta.Appender(context.Background())
+	for _, queue := range []string{"a", "dropped"} {
+		l := labels.FromStrings("__name__", "queue_length", "queue", queue)
+		_, err = app.Append(0, l, 0, 10)
+		require.NoError(t, err)
+		_, err = app.AppendExemplar(0, l, exemplar.Exemplar{Value: 1, Ts: 0})
+		require.NoError(t, err)
+		_, err = app.AppendHistogram(0, l, 0, &histogram.Histogram{Count: 1}, nil)
+		require.NoError(t, err)
+	}
+	_, err = app.Append(0, labels.FromStrings("__name__", "queue_length", "queue", "b", "zone", "z"), 0, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	require.Equal(t, []string{
+		"sample a/large",
+		"exemplar a/large",
+		"histogram a/small",
+		"sample b/many",
+	}, forwarded)
+	// The exemplars dropped along with their sample aren't counted again.
+	require.Equal(t, 2.0, testutil.ToFloat64(ta.dropped))
+}
+
+// TestTransformLimits ensures that the expressions whose cost isn't bounded
+// by their size are rejected.
+func TestTransformLimits(t *testing.T) {
+	tests := map[string]string{
+		"all(1..100000, # >= 0)":        "ranges aren't allowed",
+		`len(repeat("a", 1000000)) > 0`: "unknown name repeat",
+		`any(keys(labels), {any(keys(labels), {any(keys(labels), {# == ""})})})`: "more than the limit of 2",
+		strings.Repeat("value + ", 200) + "value > 0":                            "more than the limit of 256",
+	}
+	for dropIf, expectErr := range tests {
+		args := TransformArguments{DropIf: dropIf}
+		require.ErrorContains(t, args.Validate(), expectErr, dropIf)
+	}
+
+	args := TransformArguments{DropIf: `any(keys(labels), {hasPrefix(#, "tmp_")}) && value > 10`}
+	require.NoError(t, args.Validate())
+}
+
+func TestBadTransformConfig(t *testing.T) {
+	var args Arguments
+	require.ErrorContains(t, river.Unmarshal([]byte(`
+	targets    = []
+	forward_to = []
+
+	transform {
+		drop_if = "value + 1"
+	}
+	`), &args), "invalid drop_if expression")
+}
+
+// TestDropNonFiniteValues ensures that samples with NaN or infinite values are
+// dropped when drop_non_finite_values is enabled, while finite values and
+// staleness markers are forwarded.
+func TestDropNonFiniteValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `ratio{kind="nan"} NaN
+ratio{kind="inf"} +Inf
+ratio{kind="negative_inf"} -Inf
+ratio{kind="finite"} 0.5
+`)
+	}))
+	defer srv.Close()
+
+	var (
+		scrapes = make(chan map[string]float64, 10)
+		current = map[string]float64{}
+	)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		switch l.Get(labels.MetricName) {
+		case "up":
+			select {
+			case scrapes <- current:
+			default:
+			}
+			current = map[string]float64{}
+		case "ratio":
+			current[l.Get("kind")] = v
+		}
+		return ref, nil
+	}))
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets                = [{ __address__ = %q }]
+	forward_to             = []
+	drop_non_finite_values = true
+	scrape_interval        = "100ms"
+	scrape_timeout         = "85ms"
+	`, strings.TrimPrefix(srv.URL, "http://"))), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+
+	s, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	select {
+	case res := <-scrapes:
+		require.Equal(t, map[string]float64{"finite": 0.5}, res)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "target was never scraped")
+	}
+	require.Equal(t, 3.0, testutil.ToFloat64(s.nonFinite.dropped))
+
+	// Staleness markers are NaN values, but are still forwarded.
+	var stale []labels.Labels
+	next := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+		stale = append(stale, l)
+		return ref, nil
+	}))
+	na, err := newNonFiniteAppendable(next, prometheus_client.NewRegistry())
+	require.NoError(t, err)
+	na.SetEnabled(true)
+	_, err = na.Appender(ctx).Append(0, labels.FromStrings("__name__", "ratio"), 0, math.Float64frombits(value.StaleNaN))
+	require.NoError(t, err)
+	require.Equal(t, []labels.Labels{labels.FromStrings("__name__", "ratio")}, stale)
+}
+
+func TestKeepMetaLabels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "requests_total 1\n")
+	}))
+	defer srv.Close()
+
+	series := make(chan labels.Labels, 10)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		if l.Get(labels.MetricName) == "requests_total" {
+			select {
+			case series <- l:
+			default:
+			}
+		}
+		return ref, nil
+	}))
+
+	// The targets are the ones a discovery component would export.
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets = [{
+		__address__                          = %q,
+		__meta_kubernetes_pod_name           = "app-0",
+		__meta_kubernetes_pod_container_name = "app",
+		__meta_kubernetes_namespace          = "",
+	}]
+	forward_to       = []
+	keep_meta_labels = ["__meta_kubernetes_pod_name", "__meta_kubernetes_namespace"]
+	scrape_interval  = "100ms"
+	scrape_timeout   = "85ms"
+	`, strings.TrimPrefix(srv.URL, "http://"))), &args))
+	args.ForwardTo = []storage.Appendable{sink}
+
+	s, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	select {
+	case l := <-series:
+		require.Equal(t, "app-0", l.Get("kubernetes_pod_name"))
+		// Empty discovery labels and the ones not kept aren't promoted.
+		require.False(t, l.Has("kubernetes_namespace"))
+		require.False(t, l.Has("kubernetes_pod_container_name"))
+		require.False(t, l.Has("__meta_kubernetes_pod_name"))
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "target was never scraped")
+	}
+}
+
+func TestBadKeepMetaLabels(t *testing.T) {
+	for _, name := range []string{"kubernetes_pod_name", "__meta_", "__meta_pod-name"} {
+		var args Arguments
+		err := river.Unmarshal([]byte(fmt.Sprintf(`
+		targets          = []
+		forward_to       = []
+		keep_meta_labels = [%q]
+		`, name)), &args)
+		require.ErrorContains(t, err, "keep_meta_labels must only contain discovery labels", name)
+	}
+}
+
 func TestValidateScrapeConfig(t *testing.T) {
 	var exampleRiverConfig = `
 	targets         = [{ "target1" = "target1" }]
@@ -222,3 +1348,212 @@ func TestValidateScrapeConfig(t *testing.T) {
 	err := river.Unmarshal([]byte(exampleRiverConfig), &args)
 	require.ErrorContains(t, err, "scrape_timeout (20s) greater than scrape_interval (10s) for scrape config with job name \"local\"")
 }
+
+// TestScrapeSpans ensures that prometheus.scrape records a span for every
+// scrape which is exported through the tracing subsystem.
+func TestScrapeSpans(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(promhttp.HandlerFor(prometheus_client.NewRegistry(), promhttp.HandlerOpts{}))
+	defer srv.Close()
+
+	spans := make(chan ptrace.Span, 100)
+	tracer, err := tracing.New(tracing.Options{
+		SamplingFraction: 1,
+		WriteTo: []otelcol.Consumer{&fakeTracesConsumer{
+			consume: func(td ptrace.Traces) {
+				for i := 0; i < td.ResourceSpans().Len(); i++ {
+					rs := td.ResourceSpans().At(i)
+					for j := 0; j < rs.ScopeSpans().Len(); j++ {
+						ss := rs.ScopeSpans().At(j)
+						for k := 0; k < ss.Spans().Len(); k++ {
+							select {
+							case spans <- ss.Spans().At(k):
+							default:
+							}
+						}
+					}
+				}
+			},
+		}},
+	})
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, tracer.Run(ctx))
+	}()
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+	targets         = [{ __address__ = %q }]
+	forward_to      = []
+	scrape_interval = "100ms"
+	scrape_timeout  = "85ms"
+	`, strings.TrimPrefix(srv.URL, "http://"))), &args))
+
+	opts := component.Options{
+		ID:         "prometheus.scrape.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+		Tracer:     tracer,
+		GetServiceData: func(name string) (interface{}, error) {
+			switch name {
+			case http_service.ServiceName:
+				return http_service.Data{
+					HTTPListenAddr:   "localhost:12345",
+					MemoryListenAddr: "agent.internal:1245",
+					BaseHTTPPath:     "/",
+					DialFunc:         (&net.Dialer{}).DialContext,
+				}, nil
+			case cluster.ServiceName:
+				return cluster.Mock(), nil
+			case labelstore.ServiceName:
+				return labelstore.New(nil), nil
+			default:
+				return nil, fmt.Errorf("service %q does not exist", name)
+			}
+		},
+	}
+
+	s, err := New(opts, args)
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	// Spans are exported in batches, so allow enough time for the first batch
+	// to be sent.
+	select {
+	case span := <-spans:
+		require.Equal(t, "Scrape", span.Name())
+		attrs := span.Attributes().AsRaw()
+		require.Equal(t, srv.URL+"/metrics", attrs["target"])
+		require.Equal(t, "prometheus.scrape.test", attrs["job"])
+		require.Equal(t, true, attrs["up"])
+		require.Contains(t, attrs, "duration_seconds")
+		require.Greater(t, attrs["duration_seconds"], 0.0)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "no scrape span was exported")
+	}
+}
+
+type fakeTracesConsumer struct {
+	consume func(ptrace.Traces)
+}
+
+var _ otelcol.Consumer = (*fakeTracesConsumer)(nil)
+
+func (c *fakeTracesConsumer) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{}
+}
+
+func (c *fakeTracesConsumer) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+	c.consume(td)
+	return nil
+}
+
+func (c *fakeTracesConsumer) ConsumeMetrics(context.Context, pmetric.Metrics) error { return nil }
+
+func (c *fakeTracesConsumer) ConsumeLogs(context.Context, plog.Logs) error { return nil }
+
+// TestWatchdog ensures that the scrape loops are restarted by the watchdog
+// once the scrape loop of a target stops completing scrapes, because writing
+// its samples blocks, while the other targets are still scraped.
+func TestWatchdog(t *testing.T) {
+	defer func(d time.Duration) { watchdogCheckInterval = d }(watchdogCheckInterval)
+	watchdogCheckInterval = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "test_metric 1")
+	})
+	stalled := httptest.NewServer(handler)
+	defer stalled.Close()
+	healthy := httptest.NewServer(handler)
+	defer healthy.Close()
+
+	var (
+		stalledInstance = strings.TrimPrefix(stalled.URL, "http://")
+		healthyInstance = strings.TrimPrefix(healthy.URL, "http://")
+
+		release      = make(chan struct{})
+		blocked      atomic.Bool
+		stalledAgain atomic.Int64
+		healthyAfter atomic.Int64
+	)
+	defer close(release)
+	sink := prometheus.NewInterceptor(nil, labelstore.New(nil), prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		if l.Get(model.MetricNameLabel) != "test_metric" {
+			return ref, nil
+		}
+		switch l.Get(model.InstanceLabel) {
+		case stalledInstance:
+			if blocked.CompareAndSwap(false, true) {
+				// The first scrape loop of the target never completes its
+				// scrape.
+				<-release
+				return ref, nil
+			}
+			stalledAgain.Inc()
+		case healthyInstance:
+			if blocked.Load() {
+				healthyAfter.Inc()
+			}
+		}
+		return ref, nil
+	}))
+
+	reg := prometheus_client.NewRegistry()
+	opts := testOptions(t)
+	opts.Registerer = reg
+	s, err := New(opts, Arguments{
+		Targets: []discovery.Target{
+			{"__address__": stalledInstance},
+			{"__address__": healthyInstance},
+		},
+		ForwardTo:           []storage.Appendable{sink},
+		MetricsPath:         "/metrics",
+		Scheme:              "http",
+		ScrapeInterval:      100 * time.Millisecond,
+		ScrapeTimeout:       50 * time.Millisecond,
+		TimestampSkewAction: SkewActionWarn,
+		WatchdogIntervals:   3,
+	})
+	require.NoError(t, err)
+	go s.Run(ctx)
+
+	restarted := func() float64 {
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		for _, mf := range families {
+			if mf.GetName() == "agent_prometheus_scrape_loops_restarted_total" {
+				return mf.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		return 0
+	}
+
+	// The stalled scrape loop is detected, and a new scrape loop scrapes its
+	// target while the old one is still blocked.
+	require.Eventually(t, func() bool {
+		return restarted() >= 1 && stalledAgain.Load() > 0
+	}, 30*time.Second, 50*time.Millisecond)
+
+	// The healthy target is still scraped.
+	require.Eventually(t, func() bool {
+		return healthyAfter.Load() > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Only the stalled scrape loop was counted.
+	require.Equal(t, float64(1), restarted())
+}
+
+// TestWatchdogDisabled ensures that the watchdog is disabled by default.
+func TestWatchdogDisabled(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		targets    = []
+		forward_to = []
+	`), &args))
+	require.Zero(t, args.WatchdogIntervals)
+}
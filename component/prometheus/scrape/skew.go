@@ -0,0 +1,147 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	client_prometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+// Actions taken when the timestamps of the scraped samples are skewed by more
+// than the tolerance.
+const (
+	SkewActionWarn = "warn"
+	SkewActionFail = "fail"
+)
+
+// skewAppendable wraps an Appendable to record how far the timestamps of the
+// scraped samples of each target are from the local time, and to warn about
+// or fail the scrapes exceeding the tolerance.
+type skewAppendable struct {
+	next   storage.Appendable
+	logger log.Logger
+	skew   *client_prometheus.GaugeVec
+
+	tolerance atomic.Duration
+	fail      atomic.Bool
+}
+
+var _ storage.Appendable = (*skewAppendable)(nil)
+
+func newSkewAppendable(next storage.Appendable, logger log.Logger, reg client_prometheus.Registerer) (*skewAppendable, error) {
+	skew := client_prometheus.NewGaugeVec(client_prometheus.GaugeOpts{
+		Name: "agent_prometheus_scrape_timestamp_skew_seconds",
+		Help: "Largest difference between the timestamps of the samples of the last scrape of each target and the local time. Positive values are in the future.",
+	}, []string{"target"})
+	if err := reg.Register(skew); err != nil {
+		return nil, err
+	}
+	return &skewAppendable{next: next, logger: logger, skew: skew}, nil
+}
+
+// SetTolerance sets the largest allowed skew, or disables the check if
+// tolerance is 0, and whether scrapes exceeding it fail. It applies to
+// Appenders requested afterwards.
+func (sa *skewAppendable) SetTolerance(tolerance time.Duration, fail bool) {
+	sa.tolerance.Store(tolerance)
+	sa.fail.Store(fail)
+}
+
+// Appender implements storage.Appendable.
+func (sa *skewAppendable) Appender(ctx context.Context) storage.Appender {
+	next := sa.next.Appender(ctx)
+	target, ok := scrape.TargetFromContext(ctx)
+	if !ok || target == nil {
+		return next
+	}
+	return &skewAppender{
+		Appender:  next,
+		parent:    sa,
+		target:    target.URL().String(),
+		phase:     reportPhase{target: target},
+		tolerance: sa.tolerance.Load(),
+		fail:      sa.fail.Load(),
+		now:       time.Now(),
+	}
+}
+
+type skewAppender struct {
+	storage.Appender
+	parent    *skewAppendable
+	target    string
+	phase     reportPhase
+	tolerance time.Duration
+	fail      bool
+	now       time.Time
+
+	// The largest skew seen, and whether any sample was seen at all. The
+	// report series, which always have the time of the scrape, are ignored.
+	skew     time.Duration
+	observed bool
+	stale    bool
+}
+
+var _ storage.Appender = (*skewAppender)(nil)
+
+// Append implements storage.Appender.
+func (app *skewAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if app.phase.isReportSeries(l, t, v) {
+		if l.Get(labels.MetricName) == upMetric {
+			// Stale report series are appended once the target is no longer
+			// scraped.
+			app.stale = value.IsStaleNaN(v)
+		}
+		return app.Appender.Append(ref, l, t, v)
+	}
+	if value.IsStaleNaN(v) {
+		return app.Appender.Append(ref, l, t, v)
+	}
+
+	skew := time.UnixMilli(t).Sub(app.now)
+	if !app.observed || abs(skew) > abs(app.skew) {
+		app.skew, app.observed = skew, true
+	}
+	if app.fail && app.tolerance > 0 && abs(skew) > app.tolerance {
+		return 0, fmt.Errorf("timestamp of sample %s is skewed by %s from the local time, more than the tolerance of %s", l, skew, app.tolerance)
+	}
+	return app.Appender.Append(ref, l, t, v)
+}
+
+// Commit implements storage.Appender.
+func (app *skewAppender) Commit() error {
+	app.report()
+	return app.Appender.Commit()
+}
+
+// Rollback implements storage.Appender.
+func (app *skewAppender) Rollback() error {
+	app.report()
+	return app.Appender.Rollback()
+}
+
+func (app *skewAppender) report() {
+	switch {
+	case app.stale:
+		app.parent.skew.DeleteLabelValues(app.target)
+	case app.observed:
+		app.parent.skew.WithLabelValues(app.target).Set(app.skew.Seconds())
+		if app.tolerance > 0 && abs(app.skew) > app.tolerance {
+			level.Warn(app.parent.logger).Log("msg", "timestamps of scraped samples are skewed from the local time", "target", app.target, "skew", app.skew, "tolerance", app.tolerance)
+		}
+	}
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
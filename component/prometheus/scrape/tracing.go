@@ -0,0 +1,118 @@
+package scrape
+
+import (
+	"context"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names of the report series which the scrape manager appends after every
+// scrape.
+const (
+	scrapeDurationMetric = "scrape_duration_seconds"
+	upMetric             = "up"
+)
+
+// tracingAppendable wraps an Appendable so that every scrape is recorded as a
+// span.
+//
+// The scrape manager requests a new Appender at the start of each scrape and
+// commits it once all samples and report series have been appended, so the
+// lifetime of an Appender closely matches the lifetime of a scrape. The span
+// is passed to the next Appendable through the context, allowing downstream
+// components to create child spans.
+type tracingAppendable struct {
+	next   storage.Appendable
+	tracer trace.Tracer
+}
+
+var _ storage.Appendable = (*tracingAppendable)(nil)
+
+func newTracingAppendable(next storage.Appendable, tp trace.TracerProvider) *tracingAppendable {
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	return &tracingAppendable{
+		next:   next,
+		tracer: tp.Tracer(""),
+	}
+}
+
+// Appender implements storage.Appendable.
+func (ta *tracingAppendable) Appender(ctx context.Context) storage.Appender {
+	ctx, span := ta.tracer.Start(ctx, "Scrape", trace.WithSpanKind(trace.SpanKindInternal))
+	if target, ok := scrape.TargetFromContext(ctx); ok && target != nil {
+		span.SetAttributes(
+			attribute.String("target", target.URL().String()),
+			attribute.String("job", target.Labels().Get(model.JobLabel)),
+		)
+	}
+
+	return &tracingAppender{
+		Appender: ta.next.Appender(ctx),
+		span:     span,
+	}
+}
+
+type tracingAppender struct {
+	storage.Appender
+	span trace.Span
+
+	samples int64
+}
+
+var _ storage.Appender = (*tracingAppender)(nil)
+
+// Append implements storage.Appender.
+func (app *tracingAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	switch l.Get(model.MetricNameLabel) {
+	case scrapeDurationMetric:
+		app.span.SetAttributes(attribute.Float64("duration_seconds", v))
+	case upMetric:
+		app.span.SetAttributes(attribute.Bool("up", v == 1))
+	}
+	app.samples++
+	return app.Appender.Append(ref, l, t, v)
+}
+
+// AppendHistogram implements storage.Appender.
+func (app *tracingAppender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	app.samples++
+	return app.Appender.AppendHistogram(ref, l, t, h, fh)
+}
+
+// Commit implements storage.Appender.
+func (app *tracingAppender) Commit() error {
+	defer app.span.End()
+
+	err := app.Appender.Commit()
+	app.finish(err)
+	return err
+}
+
+// Rollback implements storage.Appender.
+func (app *tracingAppender) Rollback() error {
+	defer app.span.End()
+
+	err := app.Appender.Rollback()
+	app.span.SetAttributes(attribute.Bool("rolled_back", true))
+	app.finish(err)
+	return err
+}
+
+func (app *tracingAppender) finish(err error) {
+	app.span.SetAttributes(attribute.Int64("samples", app.samples))
+	if err != nil {
+		app.span.RecordError(err)
+		app.span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	app.span.SetStatus(codes.Ok, "")
+}
@@ -0,0 +1,442 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/ast"
+	"github.com/antonmedv/expr/parser"
+	"github.com/antonmedv/expr/vm"
+	client_prometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// TransformArguments configures expressions evaluated for every scraped
+// sample to drop or rewrite it.
+type TransformArguments struct {
+	// Expression dropping the sample if it evaluates to true.
+	DropIf string `river:"drop_if,attr,optional"`
+	// Expression evaluating to the new value of the sample.
+	Value string `river:"value,attr,optional"`
+	// Expressions evaluating to the new values of labels by name. Labels set
+	// to an empty string are removed.
+	SetLabels map[string]string `river:"set_labels,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (args *TransformArguments) Validate() error {
+	_, err := args.compile()
+	return err
+}
+
+// Limits of the transform expressions, which bound the cost of evaluating
+// them for every sample. Expressions can't use ranges or the repeat builtin,
+// whose cost depends on their operands, so that the cost of an expression
+// only depends on its size, its number of predicates and the labels of the
+// sample.
+const (
+	maxTransformNodes      = 256
+	maxTransformPredicates = 2
+)
+
+// transformLimits checks that an expression is within the limits.
+type transformLimits struct {
+	nodes, predicates int
+	err               error
+}
+
+func (tl *transformLimits) Visit(node *ast.Node) {
+	tl.nodes++
+	switch n := (*node).(type) {
+	case *ast.ClosureNode:
+		tl.predicates++
+	case *ast.BinaryNode:
+		if n.Operator == ".." && tl.err == nil {
+			tl.err = fmt.Errorf("ranges aren't allowed")
+		}
+	}
+}
+
+// compileTransform compiles the expression input once it's checked to be
+// within the limits. The expression is evaluated against the sample held by
+// state.
+func compileTransform(input string, state *transformState, opts ...expr.Option) (*vm.Program, error) {
+	tree, err := parser.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	var limits transformLimits
+	ast.Walk(&tree.Node, &limits)
+	switch {
+	case limits.err != nil:
+		return nil, limits.err
+	case limits.nodes > maxTransformNodes:
+		return nil, fmt.Errorf("expression has %d nodes, more than the limit of %d", limits.nodes, maxTransformNodes)
+	case limits.predicates > maxTransformPredicates:
+		return nil, fmt.Errorf("expression has %d predicates, more than the limit of %d", limits.predicates, maxTransformPredicates)
+	}
+
+	usage := &labelsUsage{}
+	opts = append(opts,
+		expr.Env(transformEnv{}),
+		expr.DisableBuiltin("repeat"),
+		expr.Function(labelFunction, func(params ...any) (any, error) {
+			return state.labels.Get(params[0].(string)), nil
+		}, new(func(string) string)),
+		expr.Patch(labelLookups{}),
+		expr.Patch(usage),
+	)
+	program, err := expr.Compile(input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if usage.used {
+		state.usesMap = true
+	}
+	return program, nil
+}
+
+// labelFunction is the name of the function looking up a label of the sample,
+// which can't be called from the expressions themselves.
+const labelFunction = "$label"
+
+// labelLookups replaces the lookups of a label in the labels variable, such as
+// labels["job"] or labels.job, with calls to the label function, so that they
+// don't need the labels as a map.
+type labelLookups struct{}
+
+func (labelLookups) Visit(node *ast.Node) {
+	member, ok := (*node).(*ast.MemberNode)
+	if !ok {
+		return
+	}
+	if id, ok := member.Node.(*ast.IdentifierNode); !ok || id.Value != "labels" {
+		return
+	}
+	ast.Patch(node, &ast.CallNode{
+		Callee:    &ast.IdentifierNode{Value: labelFunction},
+		Arguments: []ast.Node{member.Property},
+	})
+}
+
+// labelsUsage records whether the labels variable is still used once the
+// label lookups are replaced, such as by keys(labels).
+type labelsUsage struct {
+	used bool
+}
+
+func (u *labelsUsage) Visit(node *ast.Node) {
+	if id, ok := (*node).(*ast.IdentifierNode); ok && id.Value == "labels" {
+		u.used = true
+	}
+}
+
+// transformEnv holds the variables available to the expressions.
+type transformEnv struct {
+	Name      string            `expr:"name"`
+	Labels    map[string]string `expr:"labels"`
+	Value     float64           `expr:"value"`
+	Timestamp int64             `expr:"timestamp"`
+}
+
+// transformState holds the sample the expressions of a transformPrograms are
+// evaluated against.
+type transformState struct {
+	labels labels.Labels
+	// usesMap is true if an expression uses the labels variable other than to
+	// look up a label, in which case the labels are also copied to m, which
+	// is reused across samples.
+	usesMap bool
+	m       map[string]string
+}
+
+// transformPrograms holds the compiled expressions of TransformArguments.
+// Programs may store variables while running, and share their state, so they
+// can't be run concurrently.
+type transformPrograms struct {
+	state     *transformState
+	dropIf    *vm.Program
+	value     *vm.Program
+	setLabels map[string]*vm.Program
+}
+
+func (args *TransformArguments) compile() (*transformPrograms, error) {
+	var (
+		res = &transformPrograms{
+			state:     &transformState{},
+			setLabels: make(map[string]*vm.Program, len(args.SetLabels)),
+		}
+		err error
+	)
+	if args.DropIf != "" {
+		if res.dropIf, err = compileTransform(args.DropIf, res.state, expr.AsBool()); err != nil {
+			return nil, fmt.Errorf("invalid drop_if expression: %w", err)
+		}
+	}
+	if args.Value != "" {
+		if res.value, err = compileTransform(args.Value, res.state, expr.AsFloat64()); err != nil {
+			return nil, fmt.Errorf("invalid value expression: %w", err)
+		}
+	}
+	for name, e := range args.SetLabels {
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("invalid label name %q in set_labels", name)
+		}
+		if res.setLabels[name], err = compileTransform(e, res.state, expr.AsKind(reflect.String)); err != nil {
+			return nil, fmt.Errorf("invalid expression for label %q: %w", name, err)
+		}
+	}
+	return res, nil
+}
+
+// transformAppendable wraps an Appendable to drop or rewrite the scraped
+// samples with the expressions of TransformArguments.
+type transformAppendable struct {
+	next    storage.Appendable
+	dropped client_prometheus.Counter
+	failed  client_prometheus.Counter
+
+	mut      sync.RWMutex
+	programs *sync.Pool // Pool of *transformPrograms; nil if disabled.
+}
+
+var _ storage.Appendable = (*transformAppendable)(nil)
+
+func newTransformAppendable(next storage.Appendable, reg client_prometheus.Registerer) (*transformAppendable, error) {
+	dropped := client_prometheus.NewCounter(client_prometheus.CounterOpts{
+		Name: "agent_prometheus_scrape_transform_dropped_samples_total",
+		Help: "Total number of scraped samples dropped by the drop_if expression.",
+	})
+	failed := client_prometheus.NewCounter(client_prometheus.CounterOpts{
+		Name: "agent_prometheus_scrape_transform_failed_samples_total",
+		Help: "Total number of scraped samples dropped because an expression failed.",
+	})
+	for _, c := range []client_prometheus.Collector{dropped, failed} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return &transformAppendable{next: next, dropped: dropped, failed: failed}, nil
+}
+
+// SetTransform sets the expressions to evaluate for every sample, or disables
+// transformations if args is nil. It applies to Appenders requested
+// afterwards.
+func (ta *transformAppendable) SetTransform(args *TransformArguments) error {
+	if args == nil {
+		ta.mut.Lock()
+		ta.programs = nil
+		ta.mut.Unlock()
+		return nil
+	}
+
+	// Every concurrent scrape gets its own copy of the programs.
+	programs, err := args.compile()
+	if err != nil {
+		return err
+	}
+	args = &TransformArguments{DropIf: args.DropIf, Value: args.Value, SetLabels: args.SetLabels}
+	pool := &sync.Pool{
+		New: func() any {
+			// The expressions already compiled once.
+			p, _ := args.compile()
+			return p
+		},
+	}
+	pool.Put(programs)
+
+	ta.mut.Lock()
+	ta.programs = pool
+	ta.mut.Unlock()
+	return nil
+}
+
+// Appender implements storage.Appendable.
+func (ta *transformAppendable) Appender(ctx context.Context) storage.Appender {
+	next := ta.next.Appender(ctx)
+
+	ta.mut.RLock()
+	defer ta.mut.RUnlock()
+	if ta.programs == nil {
+		return next
+	}
+	app := &transformAppender{
+		Appender: next,
+		parent:   ta,
+		pool:     ta.programs,
+	}
+	if target, ok := scrape.TargetFromContext(ctx); ok {
+		app.phase.target = target
+	}
+	return app
+}
+
+type transformAppender struct {
+	storage.Appender
+	parent *transformAppendable
+	pool   *sync.Pool
+	phase  reportPhase
+
+	// The scraped labels of the last series transformed, and the outcome, so
+	// that its exemplars follow the sample they're appended after.
+	lastLabels labels.Labels
+	last       transformResult
+}
+
+var _ storage.Appender = (*transformAppender)(nil)
+
+// transformResult is the outcome of transforming a sample.
+type transformResult struct {
+	drop   bool
+	labels labels.Labels
+	value  float64
+	err    error
+}
+
+// Append implements storage.Appender. The report series aren't transformed.
+// Samples for which an expression fails are dropped.
+func (app *transformAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if app.phase.isReportSeries(l, t, v) {
+		return app.Appender.Append(ref, l, t, v)
+	}
+
+	res, ok := app.transform(l, t, v, value.IsStaleNaN(v), true)
+	if !ok {
+		return 0, nil
+	}
+	if !labels.Equal(res.labels, l) {
+		// The reference of the scraped series doesn't match the rewritten one.
+		ref = 0
+	}
+	return app.Appender.Append(ref, res.labels, t, res.value)
+}
+
+// AppendHistogram implements storage.Appender. The expressions see the count
+// of the histogram as the value of the sample, and the value expression
+// doesn't apply.
+func (app *transformAppender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	var (
+		count float64
+		stale bool
+	)
+	if h != nil {
+		count, stale = float64(h.Count), value.IsStaleNaN(h.Sum)
+	} else if fh != nil {
+		count, stale = fh.Count, value.IsStaleNaN(fh.Sum)
+	}
+	res, ok := app.transform(l, t, count, stale, false)
+	if !ok {
+		return 0, nil
+	}
+	if !labels.Equal(res.labels, l) {
+		ref = 0
+	}
+	return app.Appender.AppendHistogram(ref, res.labels, t, h, fh)
+}
+
+// AppendExemplar implements storage.Appender. Exemplars are dropped or
+// rewritten along with the sample of their series they're appended after,
+// and otherwise evaluated on their own.
+func (app *transformAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	var res transformResult
+	if app.lastLabels != nil && labels.Equal(l, app.lastLabels) {
+		if app.last.drop || app.last.err != nil {
+			return 0, nil
+		}
+		res = app.last
+	} else {
+		var ok bool
+		if res, ok = app.transform(l, e.Ts, e.Value, false, false); !ok {
+			return 0, nil
+		}
+	}
+	if !labels.Equal(res.labels, l) {
+		ref = 0
+	}
+	return app.Appender.AppendExemplar(ref, res.labels, e)
+}
+
+// transform runs the expressions for a sample, counting the samples which
+// are dropped, and reports whether the sample is kept. The outcome is
+// remembered for the exemplars of the series if remember is true.
+func (app *transformAppender) transform(l labels.Labels, t int64, v float64, stale, remember bool) (transformResult, bool) {
+	programs := app.pool.Get().(*transformPrograms)
+	res := programs.run(l, t, v, stale)
+	app.pool.Put(programs)
+
+	if remember {
+		app.lastLabels, app.last = l, res
+	}
+	switch {
+	case res.err != nil:
+		app.parent.failed.Inc()
+		return res, false
+	case res.drop:
+		app.parent.dropped.Inc()
+		return res, false
+	}
+	return res, true
+}
+
+// run evaluates the expressions for a sample. Only the labels of staleness
+// markers are rewritten so that they still mark the series written before as
+// stale.
+func (p *transformPrograms) run(l labels.Labels, t int64, v float64, stale bool) transformResult {
+	p.state.labels = l
+	defer func() { p.state.labels = labels.EmptyLabels() }()
+
+	env := transformEnv{
+		Name:      l.Get(labels.MetricName),
+		Value:     v,
+		Timestamp: t,
+	}
+	if p.state.usesMap {
+		if p.state.m == nil {
+			p.state.m = make(map[string]string, l.Len())
+		}
+		clear(p.state.m)
+		l.Range(func(l labels.Label) {
+			p.state.m[l.Name] = l.Value
+		})
+		env.Labels = p.state.m
+	}
+	res := transformResult{labels: l, value: v}
+
+	if p.dropIf != nil && !stale {
+		out, err := expr.Run(p.dropIf, env)
+		if err != nil {
+			return transformResult{err: err}
+		}
+		if out.(bool) {
+			return transformResult{drop: true}
+		}
+	}
+	if p.value != nil && !stale {
+		out, err := expr.Run(p.value, env)
+		if err != nil {
+			return transformResult{err: err}
+		}
+		res.value = out.(float64)
+	}
+	if len(p.setLabels) > 0 {
+		lb := labels.NewBuilder(l)
+		for name, program := range p.setLabels {
+			out, err := expr.Run(program, env)
+			if err != nil {
+				return transformResult{err: err}
+			}
+			lb.Set(name, out.(string))
+		}
+		res.labels = lb.Labels()
+	}
+	return res
+}
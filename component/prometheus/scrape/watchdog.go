@@ -0,0 +1,103 @@
+package scrape
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	client_prometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/scrape"
+)
+
+// DefaultWatchdogIntervals is the default number of scrape intervals without
+// a completed scrape after which the scrape loops are restarted. The watchdog
+// is disabled by default.
+const DefaultWatchdogIntervals uint = 0
+
+// watchdogCheckInterval is how often the watchdog checks for stalled scrape
+// loops.
+var watchdogCheckInterval = 5 * time.Second
+
+// watchdog detects the scrape loops which stopped completing scrapes.
+//
+// A scrape loop records the time of every scrape it completes, which is
+// reported by the LastScrape method of its target. A scrape loop is stalled
+// once its last scrape, or the time its target was first seen if it was never
+// scraped, is older than the configured number of scrape intervals of its
+// target. This happens when it's blocked while writing the scraped samples to
+// the downstream components.
+//
+// The scrape manager waits for a scrape loop to finish its scrape when it
+// stops it, so the scrape loops of a target can't be restarted by removing it
+// from the targets of the manager. The component instead replaces its scrape
+// manager, see Component.restartScraper.
+type watchdog struct {
+	logger    log.Logger
+	restarted client_prometheus.Counter
+
+	// firstSeen holds the time the targets which were never scraped were
+	// first seen.
+	firstSeen map[*scrape.Target]time.Time
+}
+
+func newWatchdog(logger log.Logger, reg client_prometheus.Registerer) (*watchdog, error) {
+	restarted := client_prometheus.NewCounter(client_prometheus.CounterOpts{
+		Name: "agent_prometheus_scrape_loops_restarted_total",
+		Help: "Total number of stalled scrape loops restarted by the watchdog.",
+	})
+	if err := reg.Register(restarted); err != nil {
+		return nil, err
+	}
+	return &watchdog{
+		logger:    logger,
+		restarted: restarted,
+		firstSeen: make(map[*scrape.Target]time.Time),
+	}, nil
+}
+
+// Check returns whether any of the active targets has a stalled scrape loop,
+// once intervals of its scrape interval passed without a completed scrape.
+// The stalled scrape loops are counted as restarted.
+func (w *watchdog) Check(active map[string][]*scrape.Target, intervals uint, now time.Time) bool {
+	var stalled int
+	firstSeen := make(map[*scrape.Target]time.Time, len(w.firstSeen))
+	for _, targets := range active {
+		for _, t := range targets {
+			last := t.LastScrape()
+			if last.IsZero() {
+				seen, ok := w.firstSeen[t]
+				if !ok {
+					seen = now
+				}
+				firstSeen[t] = seen
+				last = seen
+			}
+
+			if now.Sub(last) <= time.Duration(intervals)*targetInterval(t) {
+				continue
+			}
+			stalled++
+			level.Warn(w.logger).Log("msg", "scrape loop stopped completing scrapes, restarting the scrape loops", "target", t.URL().String(), "last_scrape", last)
+		}
+	}
+	w.firstSeen = firstSeen
+
+	if stalled == 0 {
+		return false
+	}
+	w.restarted.Add(float64(stalled))
+	// The targets of the new scrape loops are new.
+	w.firstSeen = make(map[*scrape.Target]time.Time)
+	return true
+}
+
+// targetInterval returns the scrape interval of target, or a minute if it
+// can't be parsed.
+func targetInterval(target *scrape.Target) time.Duration {
+	d, err := model.ParseDuration(target.GetValue(model.ScrapeIntervalLabel))
+	if err != nil || d <= 0 {
+		return time.Minute
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,118 @@
+package sli
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// expression is a good or total expression of an objective. Expressions are
+// evaluated over the increases of the counters matching their selectors, so
+// they're restricted to the operations which keep them linear: metric
+// selectors combined with sum, +, -, and multiplication or division by
+// numbers.
+type expression struct {
+	expr parser.Expr
+
+	// The matchers of the selectors of the expression, and the index of every
+	// selector in the increases the expression is evaluated over.
+	selectors []*selectorState
+	index     map[*parser.VectorSelector]int
+}
+
+func parseExpression(s string) (*expression, error) {
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	e := &expression{expr: expr, index: make(map[*parser.VectorSelector]int)}
+	if err := e.check(expr); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// check returns an error if node uses an operation which isn't supported,
+// and registers the selectors of node.
+func (e *expression) check(node parser.Expr) error {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		if n.OriginalOffset != 0 || n.Offset != 0 || n.Timestamp != nil || n.StartOrEnd != 0 {
+			return fmt.Errorf("offset and @ modifiers are not supported, the increases of the counters are computed over the windows of the objective")
+		}
+		e.index[n] = len(e.selectors)
+		e.selectors = append(e.selectors, newSelectorState(n))
+		return nil
+	case *parser.NumberLiteral:
+		return nil
+	case *parser.ParenExpr:
+		return e.check(n.Expr)
+	case *parser.UnaryExpr:
+		return e.check(n.Expr)
+	case *parser.AggregateExpr:
+		if n.Op != parser.SUM || len(n.Grouping) > 0 || n.Without {
+			return fmt.Errorf("only the sum aggregation without grouping is supported, got %s", n.Op)
+		}
+		return e.check(n.Expr)
+	case *parser.BinaryExpr:
+		_, lhsNumber := n.LHS.(*parser.NumberLiteral)
+		_, rhsNumber := n.RHS.(*parser.NumberLiteral)
+		switch {
+		case n.Op == parser.ADD || n.Op == parser.SUB:
+		case n.Op == parser.MUL && (lhsNumber || rhsNumber):
+		case n.Op == parser.DIV && rhsNumber:
+		case n.Op == parser.MUL || n.Op == parser.DIV:
+			return fmt.Errorf("the %s operator is only supported with a number", n.Op)
+		default:
+			return fmt.Errorf("the %s operator is not supported", n.Op)
+		}
+		if n.VectorMatching != nil && (len(n.VectorMatching.MatchingLabels) > 0 || n.VectorMatching.On) {
+			return fmt.Errorf("vector matching isn't supported")
+		}
+		if err := e.check(n.LHS); err != nil {
+			return err
+		}
+		return e.check(n.RHS)
+	case *parser.Call:
+		return fmt.Errorf("the %s function is not supported, the increases of the counters are computed over the windows of the objective", n.Func.Name)
+	default:
+		return fmt.Errorf("%s is not supported, only metric selectors combined with sum, +, -, and multiplication or division by numbers are", node)
+	}
+}
+
+// eval returns the value of the expression for the increases of the counters
+// matching its selectors.
+func (e *expression) eval(increases []float64) float64 {
+	return e.evalNode(e.expr, increases)
+}
+
+func (e *expression) evalNode(node parser.Expr, increases []float64) float64 {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		return increases[e.index[n]]
+	case *parser.NumberLiteral:
+		return n.Val
+	case *parser.ParenExpr:
+		return e.evalNode(n.Expr, increases)
+	case *parser.UnaryExpr:
+		if n.Op == parser.SUB {
+			return -e.evalNode(n.Expr, increases)
+		}
+		return e.evalNode(n.Expr, increases)
+	case *parser.AggregateExpr:
+		return e.evalNode(n.Expr, increases)
+	case *parser.BinaryExpr:
+		lhs, rhs := e.evalNode(n.LHS, increases), e.evalNode(n.RHS, increases)
+		switch n.Op {
+		case parser.ADD:
+			return lhs + rhs
+		case parser.SUB:
+			return lhs - rhs
+		case parser.MUL:
+			return lhs * rhs
+		default:
+			return lhs / rhs
+		}
+	}
+	return 0
+}
@@ -0,0 +1,499 @@
+// Package sli provides the prometheus.sli component.
+package sli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.sli",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Names of the series computed for every objective.
+const (
+	metricSLIRatio             = "slo:sli_ratio"
+	metricBurnRate             = "slo:burn_rate"
+	metricErrorBudgetRemaining = "slo:error_budget_remaining"
+)
+
+// Arguments holds values which are used to configure the prometheus.sli
+// component.
+type Arguments struct {
+	// Where the computed series should be forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+
+	// How often the series of the objectives are computed.
+	Interval   time.Duration `river:"interval,attr,optional"`
+	Objectives []Objective   `river:"objective,block,optional"`
+}
+
+// Objective describes a service-level objective: the ratio of the Good
+// expression to the Total expression, evaluated over the increases of the
+// counters, should be at least Target over Period.
+type Objective struct {
+	Name   string  `river:"name,attr"`
+	Good   string  `river:"good,attr"`
+	Total  string  `river:"total,attr"`
+	Target float64 `river:"target,attr"`
+
+	ShortWindow time.Duration `river:"short_window,attr,optional"`
+	LongWindow  time.Duration `river:"long_window,attr,optional"`
+	Period      time.Duration `river:"period,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for Arguments.
+var DefaultArguments = Arguments{
+	Interval: time.Minute,
+}
+
+// DefaultObjective holds the default settings for Objective.
+var DefaultObjective = Objective{
+	ShortWindow: 5 * time.Minute,
+	LongWindow:  time.Hour,
+	Period:      30 * 24 * time.Hour,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// SetToDefault implements river.Defaulter.
+func (o *Objective) SetToDefault() {
+	*o = DefaultObjective
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+	names := make(map[string]struct{}, len(args.Objectives))
+	for _, o := range args.Objectives {
+		if o.Name == "" {
+			return fmt.Errorf("objective name must not be empty")
+		}
+		if _, ok := names[o.Name]; ok {
+			return fmt.Errorf("objective %q is configured more than once", o.Name)
+		}
+		names[o.Name] = struct{}{}
+
+		if _, err := parseExpression(o.Good); err != nil {
+			return fmt.Errorf("objective %q has an invalid good expression: %w", o.Name, err)
+		}
+		if _, err := parseExpression(o.Total); err != nil {
+			return fmt.Errorf("objective %q has an invalid total expression: %w", o.Name, err)
+		}
+		if o.Target <= 0 || o.Target >= 1 {
+			return fmt.Errorf("objective %q must have a target between 0 and 1 exclusive, got %g", o.Name, o.Target)
+		}
+		if o.ShortWindow < args.Interval {
+			return fmt.Errorf("objective %q must have a short_window of at least the interval", o.Name)
+		}
+		if o.LongWindow <= o.ShortWindow {
+			return fmt.Errorf("objective %q must have a long_window greater than its short_window", o.Name)
+		}
+		if o.Period < o.LongWindow {
+			return fmt.Errorf("objective %q must have a period of at least its long_window", o.Name)
+		}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.sli component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.sli component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+
+	updated chan struct{}
+
+	mut  sync.Mutex
+	args Arguments
+
+	// objectives is replaced on updates, so that the samples sent to the
+	// receiver are recorded without holding mut.
+	objectives atomic.Pointer[[]*objectiveState]
+}
+
+// objectiveState holds an objective with the counters matching the selectors
+// of its expressions, and their increases over its windows and its period.
+type objectiveState struct {
+	Objective
+	good, total *expression
+
+	// selectors holds the selectors of the good expression followed by the
+	// ones of the total expression. Increases are indexed the same way.
+	selectors []*selectorState
+
+	mut sync.Mutex
+	// The increases of the counters since the series were last computed, and
+	// at every computation within the long window.
+	pending []float64
+	buckets []bucket
+	// The increases of the counters within the period, summed into buckets
+	// of periodResolution.
+	periodBuckets []bucket
+}
+
+// periodBucketsCount is the number of buckets the increases of the counters
+// within the period of an objective are summed into.
+const periodBucketsCount = 720
+
+// bucket holds the increases of the counters from start until end.
+type bucket struct {
+	start, end time.Time
+	increases  []float64
+}
+
+// selectorState holds the last sample of the counters matching a selector.
+type selectorState struct {
+	matchers []*labels.Matcher
+	series   map[uint64]*lastSample
+}
+
+type lastSample struct {
+	v    float64
+	seen time.Time
+}
+
+func (s *selectorState) matches(l labels.Labels) bool {
+	for _, m := range s.matchers {
+		if !m.Matches(l.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// increase records the sample v of the counter l and returns its increase
+// since the previous sample. The first sample of a counter has no increase.
+func (s *selectorState) increase(hash uint64, v float64, now time.Time) float64 {
+	if value.IsStaleNaN(v) {
+		// The series is gone, forget about it.
+		delete(s.series, hash)
+		return 0
+	}
+	if math.IsNaN(v) {
+		return 0
+	}
+
+	prev, ok := s.series[hash]
+	if !ok {
+		s.series[hash] = &lastSample{v: v, seen: now}
+		return 0
+	}
+	inc := v - prev.v
+	if inc < 0 {
+		// The counter was reset.
+		inc = v
+	}
+	prev.v, prev.seen = v, now
+	return inc
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+)
+
+// New creates a new prometheus.sli component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    o,
+		fanout:  prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+		updated: make(chan struct{}, 1),
+	}
+	c.receiver = prometheus.NewInterceptor(
+		nil,
+		ls,
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+			c.record(l, v, time.Now())
+			return ref, nil
+		}),
+	)
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	c.mut.Lock()
+	interval := c.args.Interval
+	c.mut.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updated:
+			c.mut.Lock()
+			if c.args.Interval != interval {
+				interval = c.args.Interval
+				ticker.Reset(interval)
+			}
+			c.mut.Unlock()
+		case now := <-ticker.C:
+			c.emit(ctx, now)
+		}
+	}
+}
+
+// Update implements component.Component. The counters and increases of the
+// objectives which are left unchanged are kept.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	oldObjectives := make(map[string]*objectiveState)
+	for _, o := range c.loadObjectives() {
+		oldObjectives[o.Name] = o
+	}
+
+	objectives := make([]*objectiveState, 0, len(newArgs.Objectives))
+	for _, o := range newArgs.Objectives {
+		if old, ok := oldObjectives[o.Name]; ok && reflect.DeepEqual(old.Objective, o) {
+			objectives = append(objectives, old)
+			continue
+		}
+		state, err := newObjectiveState(o)
+		if err != nil {
+			return err
+		}
+		objectives = append(objectives, state)
+	}
+
+	c.args = newArgs
+	c.objectives.Store(&objectives)
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func newObjectiveState(o Objective) (*objectiveState, error) {
+	good, err := parseExpression(o.Good)
+	if err != nil {
+		return nil, fmt.Errorf("objective %q has an invalid good expression: %w", o.Name, err)
+	}
+	total, err := parseExpression(o.Total)
+	if err != nil {
+		return nil, fmt.Errorf("objective %q has an invalid total expression: %w", o.Name, err)
+	}
+	selectors := append(append([]*selectorState{}, good.selectors...), total.selectors...)
+	return &objectiveState{
+		Objective: o,
+		good:      good,
+		total:     total,
+		selectors: selectors,
+		pending:   make([]float64, len(selectors)),
+	}, nil
+}
+
+func newSelectorState(vs *parser.VectorSelector) *selectorState {
+	return &selectorState{matchers: vs.LabelMatchers, series: make(map[uint64]*lastSample)}
+}
+
+func (c *Component) loadObjectives() []*objectiveState {
+	if objectives := c.objectives.Load(); objectives != nil {
+		return *objectives
+	}
+	return nil
+}
+
+// record adds the increase of the counter l to the objectives whose selectors
+// match it. Only the objectives matching l are locked.
+func (c *Component) record(l labels.Labels, v float64, now time.Time) {
+	var (
+		hash   uint64
+		hashed bool
+	)
+	for _, o := range c.loadObjectives() {
+		for i, s := range o.selectors {
+			if !s.matches(l) {
+				continue
+			}
+			if !hashed {
+				hash, hashed = l.Hash(), true
+			}
+			o.mut.Lock()
+			o.pending[i] += s.increase(hash, v, now)
+			o.mut.Unlock()
+		}
+	}
+}
+
+// emit computes the series of the objectives at now and sends them to the
+// downstream receivers.
+func (c *Component) emit(ctx context.Context, now time.Time) {
+	samples := c.compute(now)
+	if len(samples) == 0 {
+		return
+	}
+
+	app := c.fanout.Appender(ctx)
+	for _, s := range samples {
+		if _, err := app.Append(0, s.l, now.UnixMilli(), s.v); err != nil {
+			level.Warn(c.opts.Logger).Log("msg", "failed to append objective series", "series", s.l.String(), "err", err)
+			_ = app.Rollback()
+			return
+		}
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to commit objective series", "err", err)
+	}
+}
+
+type sample struct {
+	l labels.Labels
+	v float64
+}
+
+// compute closes the pending increases of the objectives at now, and returns
+// their series over their windows and their period. No series are returned
+// for a window in which the total expression didn't increase.
+func (c *Component) compute(now time.Time) []sample {
+	var samples []sample
+	for _, o := range c.loadObjectives() {
+		samples = append(samples, o.compute(now)...)
+	}
+	return samples
+}
+
+func (o *objectiveState) compute(now time.Time) []sample {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+
+	o.closePending(now)
+
+	var samples []sample
+	for _, window := range []time.Duration{o.ShortWindow, o.LongWindow} {
+		ratio, ok := o.ratio(o.buckets, now.Add(-window))
+		if !ok {
+			continue
+		}
+		windowLabel := model.Duration(window).String()
+		samples = append(samples,
+			sample{l: labels.FromStrings(model.MetricNameLabel, metricSLIRatio, "slo", o.Name, "window", windowLabel), v: ratio},
+			sample{l: labels.FromStrings(model.MetricNameLabel, metricBurnRate, "slo", o.Name, "window", windowLabel), v: (1 - ratio) / (1 - o.Target)},
+		)
+	}
+	if ratio, ok := o.ratio(o.periodBuckets, now.Add(-o.Period)); ok {
+		samples = append(samples, sample{
+			l: labels.FromStrings(model.MetricNameLabel, metricErrorBudgetRemaining, "slo", o.Name),
+			v: 1 - (1-ratio)/(1-o.Target),
+		})
+	}
+	return samples
+}
+
+// closePending adds the pending increases to the buckets at now, and forgets
+// the increases and the counters which are out of the long window or the
+// period.
+func (o *objectiveState) closePending(now time.Time) {
+	var start time.Time
+	if len(o.buckets) > 0 {
+		start = o.buckets[len(o.buckets)-1].end
+	}
+	o.buckets = append(o.buckets, bucket{start: start, end: now, increases: o.pending})
+
+	resolution := o.Period / periodBucketsCount
+	if n := len(o.periodBuckets); n > 0 && now.Sub(o.periodBuckets[n-1].start) < resolution {
+		last := &o.periodBuckets[n-1]
+		for i, inc := range o.pending {
+			last.increases[i] += inc
+		}
+		last.end = now
+	} else {
+		o.periodBuckets = append(o.periodBuckets, bucket{start: now, end: now, increases: append([]float64(nil), o.pending...)})
+	}
+	o.pending = make([]float64, len(o.selectors))
+
+	windowStart := now.Add(-o.LongWindow)
+	for len(o.buckets) > 0 && !o.buckets[0].end.After(windowStart) {
+		o.buckets = o.buckets[1:]
+	}
+	periodStart := now.Add(-o.Period)
+	for len(o.periodBuckets) > 0 && !o.periodBuckets[0].end.After(periodStart) {
+		o.periodBuckets = o.periodBuckets[1:]
+	}
+	for _, s := range o.selectors {
+		for hash, last := range s.series {
+			if !last.seen.After(windowStart) {
+				delete(s.series, hash)
+			}
+		}
+	}
+}
+
+// ratio returns the ratio of the good expression to the total expression,
+// evaluated over the increases of the buckets ending after start, or false if
+// the total expression isn't positive.
+func (o *objectiveState) ratio(buckets []bucket, start time.Time) (float64, bool) {
+	increases := make([]float64, len(o.selectors))
+	for _, b := range buckets {
+		if b.end.After(start) {
+			for i, inc := range b.increases {
+				increases[i] += inc
+			}
+		}
+	}
+
+	nGood := len(o.good.selectors)
+	good, total := o.good.eval(increases[:nGood]), o.total.eval(increases[nGood:])
+	if total <= 0 {
+		return 0, false
+	}
+	return math.Max(0, math.Min(good/total, 1)), true
+}
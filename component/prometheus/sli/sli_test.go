@@ -0,0 +1,331 @@
+package sli
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const testObjective = `
+	forward_to = []
+
+	objective {
+		name   = "availability"
+		good   = "requests_total{code=\"200\"}"
+		total  = "requests_total"
+		target = 0.9
+	}
+`
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(testObjective), &args))
+	require.Equal(t, time.Minute, args.Interval)
+	require.Equal(t, []Objective{{
+		Name:        "availability",
+		Good:        `requests_total{code="200"}`,
+		Total:       "requests_total",
+		Target:      0.9,
+		ShortWindow: 5 * time.Minute,
+		LongWindow:  time.Hour,
+		Period:      30 * 24 * time.Hour,
+	}}, args.Objectives)
+
+	tests := []struct {
+		objective string
+		err       string
+	}{
+		{
+			objective: `name = "a"
+			good = "good_total{"
+			total = "total"
+			target = 0.9`,
+			err: `objective "a" has an invalid good expression`,
+		},
+		{
+			objective: `name = "a"
+			good = "sum(rate(good_total[5m]))"
+			total = "total"
+			target = 0.9`,
+			err: `objective "a" has an invalid good expression: the rate function is not supported`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total"
+			total = "sum by (code) (total)"
+			target = 0.9`,
+			err: `objective "a" has an invalid total expression: only the sum aggregation without grouping is supported`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total offset 5m"
+			total = "total"
+			target = 0.9`,
+			err: `objective "a" has an invalid good expression: offset and @ modifiers are not supported`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total"
+			total = "total @ end()"
+			target = 0.9`,
+			err: `objective "a" has an invalid total expression: offset and @ modifiers are not supported`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total * total"
+			total = "total"
+			target = 0.9`,
+			err: `objective "a" has an invalid good expression: the * operator is only supported with a number`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total"
+			total = "total"
+			target = 0.9
+			period = "30m"`,
+			err: `objective "a" must have a period of at least its long_window`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total"
+			total = "total"
+			target = 1`,
+			err: `objective "a" must have a target between 0 and 1 exclusive, got 1`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total"
+			total = "total"
+			target = 0.9
+			short_window = "1h"
+			long_window = "5m"`,
+			err: `objective "a" must have a long_window greater than its short_window`,
+		},
+		{
+			objective: `name = "a"
+			good = "good_total"
+			total = "total"
+			target = 0.9
+			short_window = "10s"`,
+			err: `objective "a" must have a short_window of at least the interval`,
+		},
+	}
+	for _, tt := range tests {
+		err := river.Unmarshal([]byte("forward_to = []\nobjective {\n"+tt.objective+"\n}"), &args)
+		require.ErrorContains(t, err, tt.err)
+	}
+}
+
+// TestBurnRate ensures that the ratio of good to total requests and the burn
+// rate are computed over both windows from the increases of the counters, and
+// the remaining error budget over the period.
+func TestBurnRate(t *testing.T) {
+	c, sink := newTestComponent(t)
+	ctx := context.Background()
+
+	var (
+		start = time.Unix(0, 0)
+		good  = labels.FromStrings("__name__", "requests_total", "code", "200")
+		bad   = labels.FromStrings("__name__", "requests_total", "code", "500")
+		other = labels.FromStrings("__name__", "errors_total")
+	)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	// The first samples of the counters have no increase.
+	c.record(good, 0, at(0))
+	c.record(bad, 0, at(0))
+	c.emit(ctx, at(0))
+	require.Empty(t, sink.series())
+
+	c.record(good, 90, at(30*time.Second))
+	c.record(bad, 10, at(30*time.Second))
+	c.record(other, 100, at(30*time.Second))
+	c.emit(ctx, at(time.Minute))
+	requireSeries(t, map[string]float64{
+		`{__name__="slo:sli_ratio", slo="availability", window="5m"}`: 0.9,
+		`{__name__="slo:burn_rate", slo="availability", window="5m"}`: 1,
+		`{__name__="slo:sli_ratio", slo="availability", window="1h"}`: 0.9,
+		`{__name__="slo:burn_rate", slo="availability", window="1h"}`: 1,
+		`{__name__="slo:error_budget_remaining", slo="availability"}`: 0,
+	}, sink.series())
+
+	// Only errors happen once the first increases are out of the short
+	// window.
+	c.record(good, 90, at(10*time.Minute))
+	c.record(bad, 30, at(10*time.Minute))
+	c.emit(ctx, at(10*time.Minute))
+	requireSeries(t, map[string]float64{
+		`{__name__="slo:sli_ratio", slo="availability", window="5m"}`: 0,
+		`{__name__="slo:burn_rate", slo="availability", window="5m"}`: 10,
+		`{__name__="slo:sli_ratio", slo="availability", window="1h"}`: 0.75,
+		`{__name__="slo:burn_rate", slo="availability", window="1h"}`: 2.5,
+		`{__name__="slo:error_budget_remaining", slo="availability"}`: -1.5,
+	}, sink.series())
+
+	// Both counters were reset.
+	c.record(good, 10, at(11*time.Minute))
+	c.record(bad, 5, at(11*time.Minute))
+	c.emit(ctx, at(11*time.Minute))
+	requireSeries(t, map[string]float64{
+		`{__name__="slo:sli_ratio", slo="availability", window="5m"}`: 10.0 / 35,
+		`{__name__="slo:burn_rate", slo="availability", window="5m"}`: (1 - 10.0/35) / 0.1,
+		`{__name__="slo:sli_ratio", slo="availability", window="1h"}`: 100.0 / 135,
+		`{__name__="slo:burn_rate", slo="availability", window="1h"}`: (1 - 100.0/135) / 0.1,
+		`{__name__="slo:error_budget_remaining", slo="availability"}`: 1 - (1-100.0/135)/0.1,
+	}, sink.series())
+
+	// Only the remaining error budget is computed once the counters stop
+	// increasing for longer than the long window.
+	c.emit(ctx, at(2*time.Hour))
+	requireSeries(t, map[string]float64{
+		`{__name__="slo:error_budget_remaining", slo="availability"}`: 1 - (1-100.0/135)/0.1,
+	}, sink.series())
+
+	// No series are computed once the counters stop increasing for longer
+	// than the period.
+	c.emit(ctx, at(31*24*time.Hour))
+	require.Empty(t, sink.series())
+}
+
+// TestExpressions ensures that the good and total expressions are evaluated
+// over the increases of the counters matching their selectors.
+func TestExpressions(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		forward_to = []
+
+		objective {
+			name   = "latency"
+			good   = "sum(requests_total) - (errors_total + slow_requests_total)"
+			total  = "requests_total"
+			target = 0.9
+			period = "2h"
+		}
+	`), &args))
+	c, sink := newTestComponentArgs(t, args)
+	ctx := context.Background()
+
+	var (
+		start    = time.Unix(0, 0)
+		requests = labels.FromStrings("__name__", "requests_total")
+		errors   = labels.FromStrings("__name__", "errors_total")
+		slow     = labels.FromStrings("__name__", "slow_requests_total")
+	)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	for i, v := range []float64{0, 100} {
+		now := at(time.Duration(i) * time.Minute)
+		c.record(requests, v, now)
+		c.record(errors, v/20, now)
+		c.record(slow, v/10, now)
+	}
+	c.emit(ctx, at(time.Minute))
+	requireSeries(t, map[string]float64{
+		`{__name__="slo:sli_ratio", slo="latency", window="5m"}`: 0.85,
+		`{__name__="slo:burn_rate", slo="latency", window="5m"}`: 1.5,
+		`{__name__="slo:sli_ratio", slo="latency", window="1h"}`: 0.85,
+		`{__name__="slo:burn_rate", slo="latency", window="1h"}`: 1.5,
+		`{__name__="slo:error_budget_remaining", slo="latency"}`: -0.5,
+	}, sink.series())
+
+	// The increases are forgotten after the period.
+	c.emit(ctx, at(90*time.Minute))
+	requireSeries(t, map[string]float64{
+		`{__name__="slo:error_budget_remaining", slo="latency"}`: -0.5,
+	}, sink.series())
+	c.emit(ctx, at(3*time.Hour))
+	require.Empty(t, sink.series())
+}
+
+// TestReceiver ensures that the samples sent to the receiver of the
+// component are recorded.
+func TestReceiver(t *testing.T) {
+	c, sink := newTestComponent(t)
+
+	for _, v := range []float64{0, 20} {
+		app := c.receiver.Appender(context.Background())
+		_, err := app.Append(0, labels.FromStrings("__name__", "requests_total", "code", "200"), 0, v)
+		require.NoError(t, err)
+		_, err = app.Append(0, labels.FromStrings("__name__", "requests_total", "code", "500"), 0, v/4)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+
+	c.emit(context.Background(), time.Now())
+	series := sink.series()
+	require.InDelta(t, 0.8, series[`{__name__="slo:sli_ratio", slo="availability", window="5m"}`], 1e-9)
+	require.InDelta(t, 2, series[`{__name__="slo:burn_rate", slo="availability", window="1h"}`], 1e-9)
+}
+
+func requireSeries(t *testing.T, expect, actual map[string]float64) {
+	t.Helper()
+	require.Len(t, actual, len(expect), "series: %v", actual)
+	for series, v := range expect {
+		require.Contains(t, actual, series)
+		require.InDelta(t, v, actual[series], 1e-9, series)
+	}
+}
+
+// fakeSink records the value of every series appended to it.
+type fakeSink struct {
+	*prometheus.Interceptor
+
+	mut     sync.Mutex
+	pending map[string]float64
+}
+
+func newFakeSink() *fakeSink {
+	s := &fakeSink{pending: map[string]float64{}}
+	s.Interceptor = prometheus.NewInterceptor(nil, labelstore.New(nil),
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, v float64, _ storage.Appender) (storage.SeriesRef, error) {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			s.pending[l.String()] = v
+			return ref, nil
+		}),
+	)
+	return s
+}
+
+// series returns the series appended since it was last called.
+func (s *fakeSink) series() map[string]float64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	res := s.pending
+	s.pending = map[string]float64{}
+	return res
+}
+
+func newTestComponent(t *testing.T) (*Component, *fakeSink) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(testObjective), &args))
+	return newTestComponentArgs(t, args)
+}
+
+func newTestComponentArgs(t *testing.T, args Arguments) (*Component, *fakeSink) {
+	sink := newFakeSink()
+	args.ForwardTo = []storage.Appendable{sink}
+
+	c, err := New(component.Options{
+		ID:            "prometheus.sli.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil), nil
+		},
+	}, args)
+	require.NoError(t, err)
+	return c, sink
+}
@@ -0,0 +1,223 @@
+// Package tee provides the prometheus.tee component.
+package tee
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.tee",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.tee
+// component.
+type Arguments struct {
+	// Where every received metric is forwarded to.
+	ForwardTo []storage.Appendable `river:"forward_to,attr"`
+	// Where a fraction of the received metrics is additionally forwarded to.
+	TeeTo []storage.Appendable `river:"tee_to,attr"`
+	// Percentage of series forwarded to TeeTo.
+	Percentage float64 `river:"percentage,attr"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Percentage < 0 || args.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, got %v", args.Percentage)
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.tee component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.tee component.
+type Component struct {
+	opts    component.Options
+	forward *prometheus.Fanout
+	tee     *prometheus.Fanout
+	exited  atomic.Bool
+
+	mut       sync.RWMutex
+	threshold uint64
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// New creates a new prometheus.tee component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	// Both fanouts register the same metrics, so they're told apart by the
+	// destination they forward to.
+	c := &Component{
+		opts:    o,
+		forward: prometheus.NewFanout(args.ForwardTo, o.ID, wrapRegisterer(o.Registerer, "forward_to"), ls),
+		tee:     prometheus.NewFanout(args.TeeTo, o.ID, wrapRegisterer(o.Registerer, "tee_to"), ls),
+	}
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func wrapRegisterer(reg prometheus_client.Registerer, destination string) prometheus_client.Registerer {
+	return prometheus_client.WrapRegistererWith(prometheus_client.Labels{"destination": destination}, reg)
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if newArgs.Percentage >= 100 {
+		c.threshold = math.MaxUint64
+	} else {
+		c.threshold = uint64(newArgs.Percentage / 100 * math.MaxUint64)
+	}
+	c.forward.UpdateChildren(newArgs.ForwardTo)
+	c.tee.UpdateChildren(newArgs.TeeTo)
+	return nil
+}
+
+// teed reports whether the series identified by l is forwarded to tee_to.
+// The decision is based on the hash of the labels, so that a series is
+// always either forwarded or not.
+func (c *Component) teed(l labels.Labels) bool {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	if c.threshold == 0 {
+		return false
+	}
+	return l.Hash() <= c.threshold
+}
+
+// Appender implements storage.Appendable.
+func (c *Component) Appender(ctx context.Context) storage.Appender {
+	return &appender{
+		c:       c,
+		forward: c.forward.Appender(ctx),
+		tee:     c.tee.Appender(ctx),
+	}
+}
+
+// appender forwards every sample to the forward_to receivers, and the
+// samples of the teed series to the tee_to receivers as well.
+type appender struct {
+	c       *Component
+	forward storage.Appender
+	tee     storage.Appender
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+
+	if a.c.teed(l) {
+		_, _ = a.tee.Append(ref, l, t, v)
+	}
+	return a.forward.Append(ref, l, t, v)
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+
+	if a.c.teed(l) {
+		_, _ = a.tee.AppendExemplar(ref, l, e)
+	}
+	return a.forward.AppendExemplar(ref, l, e)
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+
+	if a.c.teed(l) {
+		_, _ = a.tee.UpdateMetadata(ref, l, m)
+	}
+	return a.forward.UpdateMetadata(ref, l, m)
+}
+
+// AppendHistogram implements storage.Appender.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+
+	if a.c.teed(l) {
+		_, _ = a.tee.AppendHistogram(ref, l, t, h, fh)
+	}
+	return a.forward.AppendHistogram(ref, l, t, h, fh)
+}
+
+// Commit implements storage.Appender. Failing to send to tee_to isn't
+// reported to the sender, so that the primary pipeline isn't affected by the
+// secondary one.
+func (a *appender) Commit() error {
+	_ = a.tee.Commit()
+	return a.forward.Commit()
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	_ = a.tee.Rollback()
+	return a.forward.Rollback()
+}
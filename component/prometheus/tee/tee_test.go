@@ -0,0 +1,137 @@
+package tee
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+	forward_to = []
+	tee_to     = []
+	percentage = 10
+`), &args))
+	require.Equal(t, 10.0, args.Percentage)
+
+	err := river.Unmarshal([]byte(`
+	forward_to = []
+	tee_to     = []
+	percentage = 110
+`), &args)
+	require.ErrorContains(t, err, "percentage must be between 0 and 100")
+}
+
+func TestTeesFraction(t *testing.T) {
+	c, primary, secondary := generateTee(t, 10)
+
+	const series = 2000
+	appendSeries(t, c, series)
+
+	require.Len(t, primary.series(), series)
+	teed := len(secondary.series())
+	require.InDelta(t, series/10, teed, series/100*3, "unexpected number of teed series")
+
+	// The same series are teed every time.
+	before := secondary.series()
+	appendSeries(t, c, series)
+	require.Len(t, primary.series(), series)
+	require.Equal(t, before, secondary.series())
+	require.Equal(t, 2*teed, secondary.samples())
+}
+
+func TestTeeBounds(t *testing.T) {
+	c, primary, secondary := generateTee(t, 0)
+	appendSeries(t, c, 100)
+	require.Len(t, primary.series(), 100)
+	require.Empty(t, secondary.series())
+
+	require.NoError(t, c.Update(Arguments{
+		ForwardTo:  []storage.Appendable{primary.interceptor},
+		TeeTo:      []storage.Appendable{secondary.interceptor},
+		Percentage: 100,
+	}))
+	appendSeries(t, c, 100)
+	require.Len(t, secondary.series(), 100)
+}
+
+func appendSeries(t *testing.T, c *Component, count int) {
+	app := c.Appender(context.Background())
+	for i := 0; i < count; i++ {
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "instance", strconv.Itoa(i)), 1000, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+}
+
+// fakeSink records the series of every sample it receives.
+type fakeSink struct {
+	interceptor *prometheus.Interceptor
+
+	mut      sync.Mutex
+	received map[string]int
+}
+
+func newFakeSink(ls labelstore.LabelStore) *fakeSink {
+	sink := &fakeSink{received: make(map[string]int)}
+	sink.interceptor = prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		sink.mut.Lock()
+		defer sink.mut.Unlock()
+		sink.received[l.String()]++
+		return ref, nil
+	}))
+	return sink
+}
+
+func (s *fakeSink) series() map[string]struct{} {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	res := make(map[string]struct{}, len(s.received))
+	for series := range s.received {
+		res[series] = struct{}{}
+	}
+	return res
+}
+
+func (s *fakeSink) samples() int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var total int
+	for _, n := range s.received {
+		total += n
+	}
+	return total
+}
+
+func generateTee(t *testing.T, percentage float64) (*Component, *fakeSink, *fakeSink) {
+	ls := labelstore.New(nil)
+	primary, secondary := newFakeSink(ls), newFakeSink(ls)
+
+	c, err := New(component.Options{
+		ID:            "prometheus.tee.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, Arguments{
+		ForwardTo:  []storage.Appendable{primary.interceptor},
+		TeeTo:      []storage.Appendable{secondary.interceptor},
+		Percentage: percentage,
+	})
+	require.NoError(t, err)
+	return c, primary, secondary
+}
@@ -0,0 +1,347 @@
+// Package file provides the prometheus.write.file component.
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/alecthomas/units"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/agent/service/labelstore"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.write.file",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// prometheus.write.file component.
+type Arguments struct {
+	// Where the received metrics should be forwarded to after being written.
+	ForwardTo []storage.Appendable `river:"forward_to,attr,optional"`
+
+	// File samples are appended to.
+	Filename string `river:"filename,attr"`
+	// Size after which the file is rotated.
+	MaxSize units.Base2Bytes `river:"max_size,attr,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	MaxSize: 10 * units.MiB,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.Filename == "" {
+		return fmt.Errorf("filename must not be empty")
+	}
+	if args.MaxSize <= 0 {
+		return fmt.Errorf("max_size must be greater than 0")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.write.file
+// component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.write.file component.
+type Component struct {
+	opts   component.Options
+	fanout *prometheus.Fanout
+
+	samplesWritten prometheus_client.Counter
+	rotations      prometheus_client.Counter
+
+	mut      sync.Mutex
+	filename string
+	maxSize  int64
+	file     *os.File
+	size     int64
+	exited   bool
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// New creates a new prometheus.write.file component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := data.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:   o,
+		fanout: prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+	}
+	c.samplesWritten = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_write_file_samples_written_total",
+		Help: "Total number of samples written to the file",
+	})
+	c.rotations = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_write_file_rotations_total",
+		Help: "Total number of times the file was rotated because it reached max_size",
+	})
+	for _, metric := range []prometheus_client.Collector{c.samplesWritten, c.rotations} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.exited = true
+	if c.file != nil {
+		return c.file.Close()
+	}
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.file == nil || newArgs.Filename != c.filename {
+		f, size, err := openFile(newArgs.Filename)
+		if err != nil {
+			return err
+		}
+		if c.file != nil {
+			_ = c.file.Close()
+		}
+		c.file, c.size = f, size
+	}
+	c.filename = newArgs.Filename
+	c.maxSize = int64(newArgs.MaxSize)
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+	return nil
+}
+
+func openFile(filename string) (*os.File, int64, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, fmt.Errorf("opening file: %w", err)
+	}
+	return f, fi.Size(), nil
+}
+
+// Appender implements storage.Appendable.
+func (c *Component) Appender(ctx context.Context) storage.Appender {
+	return &appender{c: c, next: c.fanout.Appender(ctx)}
+}
+
+// write appends the lines of a committed batch of samples to the file. The
+// file is rotated before it grows larger than max_size, keeping the previous
+// file with a .1 suffix, so batches larger than max_size are split across
+// rotations at line boundaries. Lines larger than max_size are written to a
+// file of their own.
+func (c *Component) write(lines []byte, samples int) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.exited {
+		return fmt.Errorf("%s has exited", c.opts.ID)
+	}
+
+	for len(lines) > 0 {
+		chunk := nextChunk(lines, c.maxSize)
+		lines = lines[len(chunk):]
+
+		if c.size > 0 && c.size+int64(len(chunk)) > c.maxSize {
+			if err := c.rotate(); err != nil {
+				// Samples are still written to the current file, which
+				// is rotated again with the next batch.
+				level.Warn(c.opts.Logger).Log("msg", "failed to rotate file", "filename", c.filename, "err", err)
+			}
+		}
+
+		n, err := c.file.Write(chunk)
+		c.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	c.samplesWritten.Add(float64(samples))
+	return nil
+}
+
+// nextChunk returns the longest prefix of whole lines of lines which is at
+// most maxSize bytes long, or its first line if it's longer.
+func nextChunk(lines []byte, maxSize int64) []byte {
+	var end int
+	for end < len(lines) {
+		next := bytes.IndexByte(lines[end:], '\n') + 1
+		if next == 0 {
+			next = len(lines) - end
+		}
+		if end > 0 && int64(end+next) > maxSize {
+			break
+		}
+		end += next
+	}
+	return lines[:end]
+}
+
+// rotate renames the file to keep it with a .1 suffix, and opens a new one.
+// If it fails, the current file is kept.
+func (c *Component) rotate() error {
+	rotated := c.filename + ".1"
+	if err := os.Rename(c.filename, rotated); err != nil {
+		return err
+	}
+	f, size, err := openFile(c.filename)
+	if err != nil {
+		// Keep the current file under its name.
+		_ = os.Rename(rotated, c.filename)
+		return err
+	}
+
+	old := c.file
+	c.file, c.size = f, size
+	if err := old.Close(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to close rotated file", "filename", rotated, "err", err)
+	}
+	c.rotations.Inc()
+	return nil
+}
+
+// appender writes the samples it receives to the file of the component on
+// commit, and forwards them to the next components.
+type appender struct {
+	c    *Component
+	next storage.Appender
+
+	buf     bytes.Buffer
+	samples int
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	a.writeLine(l, strconv.FormatFloat(v, 'g', -1, 64), t)
+	return a.next.Append(ref, l, t, v)
+}
+
+// AppendHistogram implements storage.Appender.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	if h != nil {
+		a.writeLine(l, h.String(), t)
+	} else if fh != nil {
+		a.writeLine(l, fh.String(), t)
+	}
+	return a.next.AppendHistogram(ref, l, t, h, fh)
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return a.next.AppendExemplar(ref, l, e)
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	return a.next.UpdateMetadata(ref, l, m)
+}
+
+// Commit implements storage.Appender. Failing to write the file doesn't
+// prevent the samples from being forwarded.
+func (a *appender) Commit() error {
+	if a.buf.Len() > 0 {
+		if err := a.c.write(a.buf.Bytes(), a.samples); err != nil {
+			level.Warn(a.c.opts.Logger).Log("msg", "failed to write samples to file", "err", err)
+		}
+	}
+	return a.next.Commit()
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	a.buf.Reset()
+	a.samples = 0
+	return a.next.Rollback()
+}
+
+// writeLine buffers a line for a sample, in a format similar to the
+// Prometheus text exposition format:
+//
+//	metric_name{label="value",...} value timestamp
+func (a *appender) writeLine(l labels.Labels, value string, t int64) {
+	a.buf.WriteString(l.Get(labels.MetricName))
+	a.buf.WriteByte('{')
+	first := true
+	l.Range(func(l labels.Label) {
+		if l.Name == labels.MetricName {
+			return
+		}
+		if !first {
+			a.buf.WriteByte(',')
+		}
+		first = false
+		a.buf.WriteString(l.Name)
+		a.buf.WriteByte('=')
+		a.buf.WriteString(strconv.Quote(l.Value))
+	})
+	a.buf.WriteString("} ")
+	a.buf.WriteString(value)
+	a.buf.WriteByte(' ')
+	a.buf.WriteString(strconv.FormatInt(t, 10))
+	a.buf.WriteByte('\n')
+	a.samples++
+}
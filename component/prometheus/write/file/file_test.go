@@ -0,0 +1,171 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`filename = "/tmp/samples.txt"`), &args))
+	require.Equal(t, DefaultArguments.MaxSize, args.MaxSize)
+
+	err := river.Unmarshal([]byte(`
+	filename = "/tmp/samples.txt"
+	max_size = "0B"
+`), &args)
+	require.ErrorContains(t, err, "max_size must be greater than 0")
+}
+
+func TestWritesSamples(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "samples.txt")
+	c, reg := generateFile(t, Arguments{Filename: filename, MaxSize: DefaultArguments.MaxSize})
+
+	app := c.Appender(context.Background())
+	_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "agent", "instance", "localhost:12345"), 1000, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "agent_build_info", "job", "agent", "version", "v0.38.0"), 1000, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "go_goroutines", "job", "agent"), 1000, 42.5)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	// Rolled back samples are never written.
+	app = c.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "up", "job", "rolled_back"), 2000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Rollback())
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, `up{instance="localhost:12345",job="agent"} 1 1000
+agent_build_info{job="agent",version="v0.38.0"} 1 1000
+go_goroutines{job="agent"} 42.5 1000
+`, string(content))
+	require.Equal(t, 3.0, testutil.ToFloat64(c.samplesWritten))
+
+	count, err := testutil.GatherAndCount(reg, "agent_prometheus_write_file_samples_written_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestRotatesBySize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "samples.txt")
+	line := "up{job=\"agent\"} 1 1000\n"
+	c, _ := generateFile(t, Arguments{Filename: filename, MaxSize: 2 * 23})
+	require.Len(t, line, 23)
+
+	for i := 0; i < 5; i++ {
+		app := c.Appender(context.Background())
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "agent"), 1000, 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+
+	// Only the current file and the previous one are kept, so at most twice
+	// max_size is used on disk.
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, line, string(content))
+
+	content, err = os.ReadFile(filename + ".1")
+	require.NoError(t, err)
+	require.Equal(t, line+line, string(content))
+
+	require.Equal(t, 2.0, testutil.ToFloat64(c.rotations))
+}
+
+// TestRotatesLargeBatches ensures that batches larger than max_size are split
+// across rotations at line boundaries, and that lines larger than max_size
+// are written to a file of their own.
+func TestRotatesLargeBatches(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "samples.txt")
+	c, _ := generateFile(t, Arguments{Filename: filename, MaxSize: 2 * 23})
+
+	app := c.Appender(context.Background())
+	for i := 0; i < 3; i++ {
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "agent"), 1000, float64(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "up{job=\"agent\"} 2 1000\n", string(content))
+	content, err = os.ReadFile(filename + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "up{job=\"agent\"} 0 1000\nup{job=\"agent\"} 1 1000\n", string(content))
+
+	app = c.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "up", "job", "agent", "instance", "a-very-long-instance-name"), 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	content, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "up{instance=\"a-very-long-instance-name\",job=\"agent\"} 1 1000\n", string(content))
+	require.Equal(t, 2.0, testutil.ToFloat64(c.rotations))
+	require.Equal(t, 4.0, testutil.ToFloat64(c.samplesWritten))
+}
+
+// TestRotateFailure ensures that samples are still written to the current
+// file when it can't be rotated.
+func TestRotateFailure(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "samples.txt")
+	c, _ := generateFile(t, Arguments{Filename: filename, MaxSize: 23})
+
+	// The file can't be renamed to a directory.
+	require.NoError(t, os.Mkdir(filename+".1", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(filename+".1", "keep"), nil, 0644))
+
+	for i := 0; i < 2; i++ {
+		app := c.Appender(context.Background())
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "agent"), 1000, 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "up{job=\"agent\"} 1 1000\nup{job=\"agent\"} 1 1000\n", string(content))
+	require.Equal(t, 0.0, testutil.ToFloat64(c.rotations))
+}
+
+func generateFile(t *testing.T, args Arguments) (*Component, *prom.Registry) {
+	reg := prom.NewRegistry()
+	ls := labelstore.New(nil)
+	c, err := New(component.Options{
+		ID:            "prometheus.write.file.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+		GetServiceData: func(name string) (interface{}, error) {
+			return ls, nil
+		},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return c, reg
+}
@@ -0,0 +1,474 @@
+// Package graphite provides the prometheus.write.graphite component.
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.write.graphite",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Supported line formats.
+const (
+	FormatGraphite = "graphite"
+	FormatInflux   = "influx"
+)
+
+// maxDatagramSize is the maximum size of the UDP packets sent, chosen to fit
+// in the MTU of most networks.
+const maxDatagramSize = 1432
+
+// Arguments holds values which are used to configure the
+// prometheus.write.graphite component.
+type Arguments struct {
+	// Address of the server to send lines to.
+	Address string `river:"address,attr"`
+	// Network protocol used to connect to the server: tcp or udp.
+	Protocol string `river:"protocol,attr,optional"`
+	// Line format: graphite or influx.
+	Format string `river:"format,attr,optional"`
+	// Prefix added to the name of every metric.
+	Prefix string `river:"prefix,attr,optional"`
+	// Timeout for connecting to the server and writing lines.
+	Timeout time.Duration `river:"timeout,attr,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	Protocol: "tcp",
+	Format:   FormatGraphite,
+	Timeout:  10 * time.Second,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if _, _, err := net.SplitHostPort(args.Address); err != nil {
+		return fmt.Errorf("invalid address %q: %w", args.Address, err)
+	}
+	switch args.Protocol {
+	case "tcp", "udp":
+	default:
+		return fmt.Errorf("unsupported protocol %q, expected tcp or udp", args.Protocol)
+	}
+	switch args.Format {
+	case FormatGraphite, FormatInflux:
+	default:
+		return fmt.Errorf("unsupported format %q, expected %s or %s", args.Format, FormatGraphite, FormatInflux)
+	}
+	if args.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.write.graphite
+// component.
+type Exports struct {
+	Receiver storage.Appendable `river:"receiver,attr"`
+}
+
+// Component implements the prometheus.write.graphite component.
+type Component struct {
+	opts component.Options
+
+	sentPoints    prometheus_client.Counter
+	droppedPoints prometheus_client.Counter
+
+	// batches holds the committed batches of lines until the sender goroutine
+	// started by Run sends them, so that commits don't wait for the server.
+	batches chan [][]byte
+
+	mut    sync.Mutex
+	args   Arguments
+	exited bool
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// maxPendingBatches is the number of committed batches held while the
+// previous ones are being sent. Batches committed once it's reached are
+// dropped.
+const maxPendingBatches = 100
+
+// New creates a new prometheus.write.graphite component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:    o,
+		batches: make(chan [][]byte, maxPendingBatches),
+	}
+	c.sentPoints = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_write_graphite_sent_points_total",
+		Help: "Total number of points sent to the server",
+	})
+	c.droppedPoints = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "agent_prometheus_write_graphite_dropped_points_total",
+		Help: "Total number of points dropped because they couldn't be converted, queued, or sent",
+	})
+	for _, metric := range []prometheus_client.Collector{c.sentPoints, c.droppedPoints} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	// Immediately export the receiver which remains the same for the component
+	// lifetime.
+	o.OnStateChange(Exports{Receiver: c})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component. It sends the committed batches until
+// ctx is canceled.
+func (c *Component) Run(ctx context.Context) error {
+	s := &sender{c: c}
+	defer s.closeConn()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.mut.Lock()
+			c.exited = true
+			c.mut.Unlock()
+
+			// The batches which weren't sent yet are dropped.
+			for {
+				select {
+				case lines := <-c.batches:
+					c.droppedPoints.Add(float64(len(lines)))
+				default:
+					return nil
+				}
+			}
+		case lines := <-c.batches:
+			s.send(lines)
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// The sender connects to the new server on its next write.
+	c.args = newArgs
+	return nil
+}
+
+// Appender implements storage.Appendable.
+func (c *Component) Appender(_ context.Context) storage.Appender {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return &appender{c: c, format: c.args.Format, prefix: c.args.Prefix}
+}
+
+// enqueue queues a committed batch of lines to be sent, dropping it if too
+// many batches are already queued.
+func (c *Component) enqueue(lines [][]byte) {
+	c.mut.Lock()
+	exited := c.exited
+	c.mut.Unlock()
+	if exited {
+		c.droppedPoints.Add(float64(len(lines)))
+		return
+	}
+
+	select {
+	case c.batches <- lines:
+	default:
+		level.Warn(c.opts.Logger).Log("msg", "too many batches waiting to be sent, dropping points", "points", len(lines))
+		c.droppedPoints.Add(float64(len(lines)))
+	}
+}
+
+// sender sends the committed batches to the server over a connection it keeps
+// open across batches. It's only used by the goroutine of Run.
+type sender struct {
+	c *Component
+
+	conn     net.Conn
+	connArgs Arguments
+	// closed is closed once the server closed conn.
+	closed chan struct{}
+}
+
+// send writes a batch of lines to the server, connecting to it first if
+// needed. If the connection was lost, send reconnects once before dropping
+// the points.
+func (s *sender) send(lines [][]byte) {
+	s.c.mut.Lock()
+	args := s.c.args
+	s.c.mut.Unlock()
+
+	err := s.write(args, lines)
+	if err != nil {
+		// The connection may have been closed by the server since the last
+		// write. Try again with a fresh connection.
+		s.closeConn()
+		err = s.write(args, lines)
+	}
+	if err != nil {
+		s.closeConn()
+		level.Warn(s.c.opts.Logger).Log("msg", "failed to send points", "address", args.Address, "err", err)
+		s.c.droppedPoints.Add(float64(len(lines)))
+		return
+	}
+	s.c.sentPoints.Add(float64(len(lines)))
+}
+
+func (s *sender) write(args Arguments, lines [][]byte) error {
+	if s.conn != nil {
+		select {
+		case <-s.closed:
+			s.closeConn()
+		default:
+			// Connect to the new server if it changed.
+			if args.Address != s.connArgs.Address || args.Protocol != s.connArgs.Protocol {
+				s.closeConn()
+			}
+		}
+	}
+	if s.conn == nil {
+		conn, err := net.DialTimeout(args.Protocol, args.Address, args.Timeout)
+		if err != nil {
+			return err
+		}
+		s.conn, s.connArgs = conn, args
+		s.closed = make(chan struct{})
+		if args.Protocol == "tcp" {
+			go watchConn(conn, s.closed)
+		}
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(args.Timeout)); err != nil {
+		return err
+	}
+
+	var packets [][]byte
+	if args.Protocol == "udp" {
+		packets = packLines(lines, maxDatagramSize)
+	} else {
+		packets = [][]byte{bytes.Join(lines, nil)}
+	}
+	for _, p := range packets {
+		if _, err := s.conn.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sender) closeConn() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// watchConn closes closed once conn is closed, by either side. Servers never
+// send data over the connection, so reads only return once it's closed.
+func watchConn(conn net.Conn, closed chan struct{}) {
+	defer close(closed)
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+// packLines groups lines into packets of at most size bytes. A line larger
+// than size is sent in its own packet.
+func packLines(lines [][]byte, size int) [][]byte {
+	var (
+		packets [][]byte
+		current []byte
+	)
+	for _, line := range lines {
+		if len(current) > 0 && len(current)+len(line) > size {
+			packets = append(packets, current)
+			current = nil
+		}
+		current = append(current, line...)
+	}
+	if len(current) > 0 {
+		packets = append(packets, current)
+	}
+	return packets
+}
+
+// appender converts the samples it receives into lines, and sends them to
+// the server on commit.
+type appender struct {
+	c      *Component
+	format string
+	prefix string
+
+	lines   [][]byte
+	dropped int
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	// Staleness markers have no equivalent in Graphite or InfluxDB.
+	if value.IsStaleNaN(v) {
+		return ref, nil
+	}
+
+	switch a.format {
+	case FormatInflux:
+		// The float fields of the line protocol can't hold NaN or infinite
+		// values.
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			a.dropped++
+			return ref, nil
+		}
+		a.lines = append(a.lines, influxLine(a.prefix, l, t, v))
+	default:
+		a.lines = append(a.lines, graphiteLine(a.prefix, l, t, v))
+	}
+	return ref, nil
+}
+
+// AppendHistogram implements storage.Appender. Native histograms can't be
+// converted, and are dropped.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, _ labels.Labels, _ int64, _ *histogram.Histogram, _ *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	a.dropped++
+	return ref, nil
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+// Commit implements storage.Appender.
+func (a *appender) Commit() error {
+	a.c.droppedPoints.Add(float64(a.dropped))
+	if len(a.lines) > 0 {
+		a.c.enqueue(a.lines)
+	}
+	return nil
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	a.lines = nil
+	a.dropped = 0
+	return nil
+}
+
+// graphiteLine formats a sample using the Graphite plaintext protocol, with
+// labels as tags:
+//
+//	name;tag1=value1;tag2=value2 value timestamp_seconds
+func graphiteLine(prefix string, l labels.Labels, t int64, v float64) []byte {
+	var b bytes.Buffer
+	b.WriteString(prefix)
+	b.WriteString(graphiteTagReplacer.Replace(l.Get(labels.MetricName)))
+	l.Range(func(l labels.Label) {
+		if l.Name == labels.MetricName {
+			return
+		}
+		b.WriteByte(';')
+		b.WriteString(graphiteTagReplacer.Replace(l.Name))
+		b.WriteByte('=')
+		b.WriteString(graphiteTagReplacer.Replace(l.Value))
+	})
+	b.WriteByte(' ')
+	b.WriteString(formatValue(v))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(t/1000, 10))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// graphiteTagReplacer replaces the characters which have a meaning in tagged
+// Graphite series.
+var graphiteTagReplacer = strings.NewReplacer(";", "_", "=", "_", " ", "_", "~", "_", "!", "_", "^", "_")
+
+// influxLine formats a sample using the InfluxDB line protocol, with labels as
+// tags and the sample in the value field:
+//
+//	name,tag1=value1,tag2=value2 value=value timestamp_nanoseconds
+func influxLine(prefix string, l labels.Labels, t int64, v float64) []byte {
+	var b bytes.Buffer
+	b.WriteString(influxMeasurementReplacer.Replace(prefix + l.Get(labels.MetricName)))
+	l.Range(func(l labels.Label) {
+		if l.Name == labels.MetricName || l.Value == "" {
+			return
+		}
+		b.WriteByte(',')
+		b.WriteString(influxTagReplacer.Replace(l.Name))
+		b.WriteByte('=')
+		b.WriteString(influxTagReplacer.Replace(l.Value))
+	})
+	b.WriteString(" value=")
+	b.WriteString(formatValue(v))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(t*int64(time.Millisecond), 10))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+var (
+	influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagReplacer         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
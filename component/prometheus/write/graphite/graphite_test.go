@@ -0,0 +1,297 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/river"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`address = "localhost:2003"`), &args))
+	require.Equal(t, "tcp", args.Protocol)
+	require.Equal(t, FormatGraphite, args.Format)
+
+	err := river.Unmarshal([]byte(`
+	address = "localhost:2003"
+	format  = "opentsdb"
+`), &args)
+	require.ErrorContains(t, err, `unsupported format "opentsdb"`)
+
+	err = river.Unmarshal([]byte(`address = "localhost"`), &args)
+	require.ErrorContains(t, err, `invalid address "localhost"`)
+}
+
+func TestFormats(t *testing.T) {
+	tt := []struct {
+		name   string
+		format string
+		prefix string
+		expect []string
+	}{
+		{
+			name:   "graphite",
+			format: FormatGraphite,
+			prefix: "agent.",
+			expect: []string{
+				"agent.http_requests_total;code=200;instance=localhost:8080;job=api;path=/a_b 1027 1700000000",
+				"agent.go_goroutines;instance=localhost:8080;job=api 42.5 1700000000",
+			},
+		},
+		{
+			name:   "influx",
+			format: FormatInflux,
+			expect: []string{
+				`http_requests_total,code=200,instance=localhost:8080,job=api,path=/a\ b value=1027 1700000000000000000`,
+				`go_goroutines,instance=localhost:8080,job=api value=42.5 1700000000000000000`,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, addr := newTCPServer(t)
+			c, _ := generateGraphite(t, Arguments{
+				Address:  addr,
+				Protocol: "tcp",
+				Format:   tc.format,
+				Prefix:   tc.prefix,
+				Timeout:  time.Second,
+			})
+
+			app := c.Appender(context.Background())
+			_, err := app.Append(0, labels.FromStrings("__name__", "http_requests_total", "job", "api", "instance", "localhost:8080", "code", "200", "path", "/a b"), 1700000000000, 1027)
+			require.NoError(t, err)
+			_, err = app.Append(0, labels.FromStrings("__name__", "go_goroutines", "job", "api", "instance", "localhost:8080"), 1700000000000, 42.5)
+			require.NoError(t, err)
+			// Staleness markers aren't sent.
+			_, err = app.Append(0, labels.FromStrings("__name__", "up", "job", "api"), 1700000000000, math.Float64frombits(value.StaleNaN))
+			require.NoError(t, err)
+			require.NoError(t, app.Commit())
+
+			for _, expect := range tc.expect {
+				require.Equal(t, expect, receive(t, lines))
+			}
+			require.Eventually(t, func() bool {
+				return testutil.ToFloat64(c.sentPoints) == 2
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	c, _ := generateGraphite(t, Arguments{
+		Address:  conn.LocalAddr().String(),
+		Protocol: "udp",
+		Format:   FormatGraphite,
+		Timeout:  time.Second,
+	})
+
+	app := c.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "up", "job", "api"), 1700000000000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	buf := make([]byte, maxDatagramSize)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "up;job=api 1 1700000000\n", string(buf[:n]))
+}
+
+func TestReconnects(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	c, _ := generateGraphite(t, Arguments{
+		Address:  addr,
+		Protocol: "tcp",
+		Format:   FormatGraphite,
+		Timeout:  time.Second,
+	})
+	send := func(v float64) {
+		app := c.Appender(context.Background())
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "api"), 1700000000000, v)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+
+	// The server goes away after receiving the first point.
+	accepted := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		accepted <- line
+		_ = conn.Close()
+		_ = lis.Close()
+	}()
+	send(1)
+	require.Equal(t, "up;job=api 1 1700000000\n", <-accepted)
+
+	// Points sent while the server is down are dropped.
+	require.Eventually(t, func() bool {
+		send(2)
+		return testutil.ToFloat64(c.droppedPoints) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Once the server is back, the component reconnects to it.
+	lines := newTCPServerAt(t, addr)
+	send(3)
+	require.Equal(t, "up;job=api 3 1700000000", receive(t, lines))
+}
+
+func TestDropsHistograms(t *testing.T) {
+	_, addr := newTCPServer(t)
+	c, _ := generateGraphite(t, Arguments{
+		Address:  addr,
+		Protocol: "tcp",
+		Format:   FormatGraphite,
+		Timeout:  time.Second,
+	})
+
+	app := c.Appender(context.Background())
+	_, err := app.AppendHistogram(0, labels.FromStrings("__name__", "request_duration_seconds"), 1700000000000, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+	require.Equal(t, 1.0, testutil.ToFloat64(c.droppedPoints))
+	require.Equal(t, 0.0, testutil.ToFloat64(c.sentPoints))
+}
+
+// TestInfluxDropsNonFinite ensures that NaN and infinite values, which the
+// InfluxDB line protocol can't represent, are dropped.
+func TestInfluxDropsNonFinite(t *testing.T) {
+	lines, addr := newTCPServer(t)
+	c, _ := generateGraphite(t, Arguments{
+		Address:  addr,
+		Protocol: "tcp",
+		Format:   FormatInflux,
+		Timeout:  time.Second,
+	})
+
+	app := c.Appender(context.Background())
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1} {
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "api"), 1700000000000, v)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	require.Equal(t, "up,job=api value=1 1700000000000000000", receive(t, lines))
+	require.Equal(t, 3.0, testutil.ToFloat64(c.droppedPoints))
+}
+
+// TestDropsWhenQueueFull ensures that commits don't wait for the batches to
+// be sent, and that the batches committed while too many are waiting to be
+// sent are dropped.
+func TestDropsWhenQueueFull(t *testing.T) {
+	_, addr := newTCPServer(t)
+	// The component isn't run, so that the batches aren't sent.
+	c, err := New(component.Options{
+		ID:            "prometheus.write.graphite.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    prom.NewRegistry(),
+	}, Arguments{
+		Address:  addr,
+		Protocol: "tcp",
+		Format:   FormatGraphite,
+		Timeout:  time.Second,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < maxPendingBatches+1; i++ {
+		app := c.Appender(context.Background())
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "job", "api"), 1700000000000, 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+	require.Equal(t, 1.0, testutil.ToFloat64(c.droppedPoints))
+}
+
+func generateGraphite(t *testing.T, args Arguments) (*Component, *prom.Registry) {
+	reg := prom.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "prometheus.write.graphite.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		Registerer:    reg,
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = c.Run(ctx) }()
+	return c, reg
+}
+
+// newTCPServer starts a server which sends the lines it receives to the
+// returned channel.
+func newTCPServer(t *testing.T) (<-chan string, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return serve(t, lis), lis.Addr().String()
+}
+
+func newTCPServerAt(t *testing.T, addr string) <-chan string {
+	var (
+		lis net.Listener
+		err error
+	)
+	// The port may not be released yet by the previous listener.
+	require.Eventually(t, func() bool {
+		lis, err = net.Listen("tcp", addr)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return serve(t, lis)
+}
+
+func serve(t *testing.T, lis net.Listener) <-chan string {
+	t.Cleanup(func() { _ = lis.Close() })
+
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receive(t *testing.T, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for line")
+		return ""
+	}
+}
@@ -0,0 +1,283 @@
+package self
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/pyroscope"
+	"github.com/grafana/agent/component/pyroscope/scrape"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+const (
+	pprofMemory     = "memory"
+	pprofGoroutine  = "goroutine"
+	pprofProcessCPU = "process_cpu"
+
+	serviceName = "grafana-agent"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "pyroscope.self",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the pyroscope.self
+// component.
+type Arguments struct {
+	ForwardTo []pyroscope.Appendable `river:"forward_to,attr"`
+
+	// The job name to override the job label with.
+	JobName string `river:"job_name,attr,optional"`
+	// How frequently to capture profiles.
+	ProfilingInterval time.Duration `river:"profiling_interval,attr,optional"`
+
+	ProfilingConfig ProfilingConfig `river:"profiling_config,block,optional"`
+}
+
+// ProfilingConfig configures the profiles to capture.
+type ProfilingConfig struct {
+	Memory     ProfilingTarget    `river:"profile.memory,block,optional"`
+	Goroutine  ProfilingTarget    `river:"profile.goroutine,block,optional"`
+	ProcessCPU CPUProfilingTarget `river:"profile.process_cpu,block,optional"`
+}
+
+// ProfilingTarget configures a profile to capture.
+type ProfilingTarget struct {
+	Enabled bool `river:"enabled,attr,optional"`
+}
+
+// CPUProfilingTarget configures the CPU profile to capture.
+type CPUProfilingTarget struct {
+	Enabled bool `river:"enabled,attr,optional"`
+	// How long the CPU profile is captured for at the start of every
+	// interval. The CPU profiler of the process is released in between.
+	Duration time.Duration `river:"duration,attr,optional"`
+}
+
+// DefaultArguments holds the default settings for the pyroscope.self
+// component.
+var DefaultArguments = Arguments{
+	ProfilingInterval: 15 * time.Second,
+	ProfilingConfig: ProfilingConfig{
+		Memory:     ProfilingTarget{Enabled: true},
+		Goroutine:  ProfilingTarget{Enabled: true},
+		ProcessCPU: CPUProfilingTarget{Enabled: false, Duration: 5 * time.Second},
+	},
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// SetToDefault implements river.Defaulter.
+func (t *CPUProfilingTarget) SetToDefault() {
+	*t = DefaultArguments.ProfilingConfig.ProcessCPU
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.ProfilingInterval < time.Second {
+		return fmt.Errorf("profiling_interval must be at least 1s")
+	}
+	if cpu := args.ProfilingConfig.ProcessCPU; cpu.Enabled && (cpu.Duration <= 0 || cpu.Duration >= args.ProfilingInterval) {
+		return fmt.Errorf("profile.process_cpu duration must be greater than 0 and less than profiling_interval")
+	}
+	return nil
+}
+
+// Component implements the pyroscope.self component.
+type Component struct {
+	opts       component.Options
+	appendable *pyroscope.Fanout
+	instance   string
+
+	reload chan struct{}
+
+	mut  sync.RWMutex
+	args Arguments
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new pyroscope.self component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	instance, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	c := &Component{
+		opts:       o,
+		appendable: pyroscope.NewFanout(args.ForwardTo, o.ID, o.Registerer),
+		instance:   instance,
+		reload:     make(chan struct{}, 1),
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	// Only the allocations made since the previous capture are forwarded for
+	// memory profiles, like pyroscope.scrape does.
+	var (
+		forward = pyroscope.AppendableFunc(func(ctx context.Context, lbs labels.Labels, samples []*pyroscope.RawSample) error {
+			return c.appendable.Appender().Append(ctx, lbs, samples)
+		})
+		memory = scrape.NewDeltaAppender(forward, labels.FromStrings(model.MetricNameLabel, pprofMemory))
+		cpu    = &cpuProfiler{}
+	)
+	defer cpu.stop()
+
+	c.mut.RLock()
+	interval := c.args.ProfilingInterval
+	c.mut.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// cpuDone fires once the CPU profile of the current interval has been
+	// captured for long enough. It's nil while no CPU profile is running.
+	var cpuDone <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-cpuDone:
+			cpuDone = nil
+			c.mut.RLock()
+			args := c.args
+			c.mut.RUnlock()
+			if profile := cpu.stop(); profile != nil {
+				c.forward(ctx, forward, args, pprofProcessCPU, profile)
+			}
+		case <-c.reload:
+			c.mut.RLock()
+			if c.args.ProfilingInterval != interval {
+				interval = c.args.ProfilingInterval
+				ticker.Reset(interval)
+			}
+			c.mut.RUnlock()
+		case <-ticker.C:
+			c.mut.RLock()
+			args := c.args
+			c.mut.RUnlock()
+
+			if args.ProfilingConfig.Memory.Enabled {
+				c.capture(ctx, memory, args, pprofMemory, "heap")
+			}
+			if args.ProfilingConfig.Goroutine.Enabled {
+				c.capture(ctx, forward, args, pprofGoroutine, "goroutine")
+			}
+			if cpuDone == nil && c.startCPU(args, cpu) {
+				cpuDone = time.After(args.ProfilingConfig.ProcessCPU.Duration)
+			}
+		}
+	}
+}
+
+// startCPU starts the CPU profile if it's enabled, and returns whether it was
+// started.
+func (c *Component) startCPU(args Arguments, cpu *cpuProfiler) bool {
+	if !args.ProfilingConfig.ProcessCPU.Enabled {
+		return false
+	}
+	if err := cpu.start(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to start CPU profile", "err", err)
+		return false
+	}
+	return true
+}
+
+// capture writes the runtime profile with the given lookup name and forwards
+// it as a profile of the given type.
+func (c *Component) capture(ctx context.Context, app pyroscope.Appender, args Arguments, profileType, lookup string) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup(lookup).WriteTo(&buf, 0); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to capture profile", "profile", profileType, "err", err)
+		return
+	}
+	c.forward(ctx, app, args, profileType, buf.Bytes())
+}
+
+func (c *Component) forward(ctx context.Context, app pyroscope.Appender, args Arguments, profileType string, profile []byte) {
+	jobName := c.opts.ID
+	if args.JobName != "" {
+		jobName = args.JobName
+	}
+	lbs := labels.FromStrings(
+		model.MetricNameLabel, profileType,
+		model.JobLabel, jobName,
+		model.InstanceLabel, c.instance,
+		"service_name", serviceName,
+	)
+	if err := app.Append(ctx, lbs, []*pyroscope.RawSample{{RawProfile: profile}}); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "push failed", "profile", profileType, "err", err)
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+	c.appendable.UpdateChildren(newArgs.ForwardTo)
+
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// cpuProfiler captures the CPU profile of the process. Only one CPU profile
+// can be running at a time in a process, so starting it fails while the CPU
+// profile is requested from the /debug/pprof/profile endpoint, and requests to
+// that endpoint fail while it's running.
+type cpuProfiler struct {
+	buf *bytes.Buffer
+}
+
+func (p *cpuProfiler) start() error {
+	buf := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return err
+	}
+	p.buf = buf
+	return nil
+}
+
+// stop stops the running CPU profile and returns it, or nil if no profile is
+// running.
+func (p *cpuProfiler) stop() []byte {
+	if p.buf == nil {
+		return nil
+	}
+	pprof.StopCPUProfile()
+	profile := p.buf.Bytes()
+	p.buf = nil
+	return profile
+}
@@ -0,0 +1,191 @@
+package self
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/pprof/profile"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/pyroscope"
+	"github.com/grafana/agent/component/pyroscope/write"
+	"github.com/grafana/agent/pkg/util"
+	pushv1 "github.com/grafana/pyroscope/api/gen/proto/go/push/v1"
+	"github.com/grafana/pyroscope/api/gen/proto/go/push/v1/pushv1connect"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+type pushFunc func(context.Context, *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error)
+
+func (p pushFunc) Push(ctx context.Context, r *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error) {
+	return p(ctx, r)
+}
+
+func TestForwardHeapProfile(t *testing.T) {
+	var (
+		mut      sync.Mutex
+		profiles = map[string][][]byte{}
+		labels   = map[string]map[string]string{}
+	)
+	_, handler := pushv1connect.NewPusherServiceHandler(pushFunc(
+		func(_ context.Context, req *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error) {
+			mut.Lock()
+			defer mut.Unlock()
+			for _, series := range req.Msg.Series {
+				lbs := map[string]string{}
+				for _, l := range series.Labels {
+					lbs[l.Name] = l.Value
+				}
+				name := lbs["__name__"]
+				labels[name] = lbs
+				for _, sample := range series.Samples {
+					profiles[name] = append(profiles[name], sample.RawProfile)
+				}
+			}
+			return &connect.Response[pushv1.PushResponse]{}, nil
+		},
+	))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	writeArgs := write.DefaultArguments()
+	writeArgs.Endpoints = []*write.EndpointOptions{{
+		URL:               server.URL,
+		MinBackoff:        100 * time.Millisecond,
+		MaxBackoff:        200 * time.Millisecond,
+		MaxBackoffRetries: 1,
+		RemoteTimeout:     write.GetDefaultEndpointOptions().RemoteTimeout,
+	}}
+	receiver := make(chan pyroscope.Appendable, 1)
+	writer, err := write.New(component.Options{
+		ID:         "pyroscope.write.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {
+			receiver <- e.(write.Exports).Receiver
+		},
+	}, writeArgs)
+	require.NoError(t, err)
+	go writer.Run(ctx)
+
+	args := DefaultArguments
+	args.ForwardTo = []pyroscope.Appendable{<-receiver}
+	args.ProfilingInterval = 100 * time.Millisecond
+	args.ProfilingConfig.ProcessCPU.Enabled = false
+	args.ProfilingConfig.Goroutine.Enabled = false
+	c, err := New(component.Options{
+		ID:         "pyroscope.self.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+	}, args)
+	require.NoError(t, err)
+	go c.Run(ctx)
+
+	// The first heap profile is only used to compute the delta of the next one.
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(profiles["memory"]) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, "pyroscope.self.test", labels["memory"]["job"])
+	require.Equal(t, "grafana-agent", labels["memory"]["service_name"])
+	require.Equal(t, "false", labels["memory"]["__delta__"])
+	require.NotContains(t, profiles, "goroutine")
+	require.NotContains(t, profiles, "process_cpu")
+
+	p, err := profile.Parse(bytes.NewReader(profiles["memory"][0]))
+	require.NoError(t, err)
+	var types []string
+	for _, st := range p.SampleType {
+		types = append(types, st.Type)
+	}
+	require.Equal(t, []string{"alloc_objects", "alloc_space", "inuse_objects", "inuse_space"}, types)
+}
+
+func TestArguments_Validate(t *testing.T) {
+	args := DefaultArguments
+	require.NoError(t, args.Validate())
+
+	args.ProfilingInterval = 100 * time.Millisecond
+	require.EqualError(t, args.Validate(), "profiling_interval must be at least 1s")
+
+	args = DefaultArguments
+	args.ProfilingConfig.ProcessCPU.Enabled = true
+	args.ProfilingConfig.ProcessCPU.Duration = args.ProfilingInterval
+	require.EqualError(t, args.Validate(), "profile.process_cpu duration must be greater than 0 and less than profiling_interval")
+}
+
+func TestRiverConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		forward_to = []
+		profiling_config {
+			profile.process_cpu {
+				enabled = true
+			}
+		}
+	`), &args))
+	require.Equal(t, CPUProfilingTarget{Enabled: true, Duration: 5 * time.Second}, args.ProfilingConfig.ProcessCPU)
+	require.True(t, args.ProfilingConfig.Memory.Enabled)
+
+	// The CPU profile isn't captured by default.
+	require.NoError(t, river.Unmarshal([]byte(`forward_to = []`), &args))
+	require.False(t, args.ProfilingConfig.ProcessCPU.Enabled)
+}
+
+// TestCPUProfileReleased ensures that the CPU profiler of the process is only
+// held for the duration of the CPU profile of every interval.
+func TestCPUProfileReleased(t *testing.T) {
+	var (
+		mut      sync.Mutex
+		profiles int
+	)
+	app := pyroscope.AppendableFunc(func(_ context.Context, lbs labels.Labels, _ []*pyroscope.RawSample) error {
+		mut.Lock()
+		defer mut.Unlock()
+		if lbs.Get("__name__") == "process_cpu" {
+			profiles++
+		}
+		return nil
+	})
+
+	args := DefaultArguments
+	args.ForwardTo = []pyroscope.Appendable{app}
+	args.ProfilingInterval = time.Second
+	args.ProfilingConfig.ProcessCPU = CPUProfilingTarget{Enabled: true, Duration: 100 * time.Millisecond}
+	c, err := New(component.Options{
+		ID:         "pyroscope.self.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return profiles > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// The profiler is free again until the next interval starts.
+	var buf bytes.Buffer
+	require.NoError(t, pprof.StartCPUProfile(&buf))
+	pprof.StopCPUProfile()
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/regexp"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/trace"
@@ -50,6 +52,11 @@ type Module interface {
 	// Run blocks until the provided context is canceled. The ID of a module as defined in
 	// ModuleController.NewModule will not be released until Run returns.
 	Run(context.Context) error
+
+	// Drain sends the data buffered by the components within the Module,
+	// blocking until it's sent or the provided context is canceled. See
+	// [DrainableComponent] for more information.
+	Drain(context.Context) error
 }
 
 // ExportFunc is used for onExport of the Module
@@ -117,6 +124,11 @@ type Registration struct {
 	// any number of underscores or alphanumeric ASCII characters.
 	Name string
 
+	// Stability is the overall stability level of the component. Components
+	// below the minimum stability level configured for the Flow controller
+	// can't be used.
+	Stability featuregate.Stability
+
 	// An example Arguments value that the registered component expects to
 	// receive as input. Components should provide the zero value of their
 	// Arguments type here.
@@ -137,12 +149,16 @@ func (r Registration) CloneArguments() Arguments {
 }
 
 // Register registers a component. Register will panic if the name is in use by
-// another component, if the name is invalid, or if the component name has a
-// suffix length mismatch with an existing component.
+// another component, if the name is invalid, if the component name has a
+// suffix length mismatch with an existing component, or if the stability
+// level of the component is undefined.
 func Register(r Registration) {
 	if _, exist := registered[r.Name]; exist {
 		panic(fmt.Sprintf("Component name %q already registered", r.Name))
 	}
+	if r.Stability == featuregate.StabilityUndefined {
+		panic(fmt.Sprintf("Component %q has an undefined stability level", r.Name))
+	}
 
 	parsed, err := parseComponentName(r.Name)
 	if err != nil {
@@ -202,3 +218,13 @@ func Get(name string) (Registration, bool) {
 	r, ok := registered[name]
 	return r, ok
 }
+
+// AllNames returns the sorted names of all registered components.
+func AllNames() []string {
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
 	common_config "github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/useragent"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/river/rivertypes"
@@ -23,9 +24,10 @@ var userAgent = useragent.Get()
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "remote.http",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "remote.http",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
 		},
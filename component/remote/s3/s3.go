@@ -14,15 +14,17 @@ import (
 	aws_config "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/river/rivertypes"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "remote.s3",
-		Args:    Arguments{},
-		Exports: Exports{},
+		Name:      "remote.s3",
+		Stability: featuregate.StabilityStable,
+		Args:      Arguments{},
+		Exports:   Exports{},
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
 		},
@@ -50,15 +52,11 @@ var (
 
 // New initializes the S3 component.
 func New(o component.Options, args Arguments) (*Component, error) {
-	s3cfg, err := generateS3Config(args)
+	s3Client, err := NewClient(args.Options)
 	if err != nil {
 		return nil, err
 	}
 
-	s3Client := s3.NewFromConfig(*s3cfg, func(s3o *s3.Options) {
-		s3o.UsePathStyle = args.Options.UsePathStyle
-	})
-
 	bucket, file := getPathBucketAndFile(args.Path)
 	s := &Component{
 		opts:       o,
@@ -105,13 +103,10 @@ func (s *Component) Run(ctx context.Context) error {
 func (s *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
 
-	s3cfg, err := generateS3Config(newArgs)
+	s3Client, err := NewClient(newArgs.Options)
 	if err != nil {
 		return nil
 	}
-	s3Client := s3.NewFromConfig(*s3cfg, func(s3o *s3.Options) {
-		s3o.UsePathStyle = newArgs.Options.UsePathStyle
-	})
 
 	bucket, file := getPathBucketAndFile(newArgs.Path)
 
@@ -130,26 +125,37 @@ func (s *Component) CurrentHealth() component.Health {
 	return s.health
 }
 
-func generateS3Config(args Arguments) (*aws.Config, error) {
+// NewClient creates an S3 client from the client options.
+func NewClient(opts Client) (*s3.Client, error) {
+	s3cfg, err := generateS3Config(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(*s3cfg, func(s3o *s3.Options) {
+		s3o.UsePathStyle = opts.UsePathStyle
+	}), nil
+}
+
+func generateS3Config(opts Client) (*aws.Config, error) {
 	configOptions := make([]func(*aws_config.LoadOptions) error, 0)
 	// Override the endpoint.
-	if args.Options.Endpoint != "" {
+	if opts.Endpoint != "" {
 		endFunc := aws.EndpointResolverWithOptionsFunc(func(service, region string, _ ...interface{}) (aws.Endpoint, error) {
 			// The S3 compatible system used for testing with does not require signing region, so it's fine to be blank
 			// but when using a proxy to real S3 it needs to be injected.
-			return aws.Endpoint{URL: args.Options.Endpoint, SigningRegion: args.Options.SigningRegion}, nil
+			return aws.Endpoint{URL: opts.Endpoint, SigningRegion: opts.SigningRegion}, nil
 		})
 		endResolver := aws_config.WithEndpointResolverWithOptions(endFunc)
 		configOptions = append(configOptions, endResolver)
 	}
 
 	// This incredibly nested option turns off SSL.
-	if args.Options.DisableSSL {
+	if opts.DisableSSL {
 		httpOverride := aws_config.WithHTTPClient(
 			&http.Client{
 				Transport: &http.Transport{
 					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: args.Options.DisableSSL,
+						InsecureSkipVerify: opts.DisableSSL,
 					},
 				},
 			},
@@ -159,14 +165,14 @@ func generateS3Config(args Arguments) (*aws.Config, error) {
 
 	// Check to see if we need to override the credentials, else it will use the default ones.
 	// https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-envvars.html
-	if args.Options.AccessKey != "" {
-		if args.Options.Secret == "" {
+	if opts.AccessKey != "" {
+		if opts.Secret == "" {
 			return nil, fmt.Errorf("if accesskey or secret are specified then the other must also be specified")
 		}
 		credFunc := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
 			return aws.Credentials{
-				AccessKeyID:     args.Options.AccessKey,
-				SecretAccessKey: string(args.Options.Secret),
+				AccessKeyID:     opts.AccessKey,
+				SecretAccessKey: string(opts.Secret),
 			}, nil
 		})
 		credProvider := aws_config.WithCredentialsProvider(credFunc)
@@ -178,8 +184,8 @@ func generateS3Config(args Arguments) (*aws.Config, error) {
 		return nil, err
 	}
 	// Set region.
-	if args.Options.Region != "" {
-		cfg.Region = args.Options.Region
+	if opts.Region != "" {
+		cfg.Region = opts.Region
 	}
 
 	return &cfg, nil
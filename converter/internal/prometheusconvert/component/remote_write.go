@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/grafana/agent/component/common/config"
 	"github.com/grafana/agent/component/prometheus/remotewrite"
 	"github.com/grafana/agent/converter/diag"
 	"github.com/grafana/agent/converter/internal/common"
@@ -123,14 +124,14 @@ func toSigV4(sigv4Config *sigv4.SigV4Config) *remotewrite.SigV4Config {
 }
 
 // toAzureAD converts a Prometheus AzureAD config to a River AzureAD config.
-func toAzureAD(azureADConfig *azuread.AzureADConfig) *remotewrite.AzureADConfig {
+func toAzureAD(azureADConfig *azuread.AzureADConfig) *config.AzureADConfig {
 	if azureADConfig == nil {
 		return nil
 	}
 
-	return &remotewrite.AzureADConfig{
+	return &config.AzureADConfig{
 		Cloud: azureADConfig.Cloud,
-		ManagedIdentity: remotewrite.ManagedIdentityConfig{
+		ManagedIdentity: config.ManagedIdentityConfig{
 			ClientID: azureADConfig.ManagedIdentity.ClientID,
 		},
 	}
@@ -62,6 +62,7 @@ func toScrapeArguments(scrapeConfig *prom_config.ScrapeConfig, forwardTo []stora
 		HTTPClientConfig:          *common.ToHttpClientConfig(&scrapeConfig.HTTPClientConfig),
 		ExtraMetrics:              false,
 		EnableProtobufNegotiation: false,
+		TimestampSkewAction:       scrape.SkewActionWarn,
 		Clustering:                cluster.ComponentBlock{Enabled: false},
 	}
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/alecthomas/units"
 	"github.com/grafana/agent/component/common/loki"
+	lokiclient "github.com/grafana/agent/component/common/loki/client"
 	lokiwrite "github.com/grafana/agent/component/loki/write"
 	"github.com/grafana/agent/converter/diag"
 	"github.com/grafana/agent/converter/internal/common"
@@ -52,6 +53,8 @@ func toLokiWriteArguments(config *client.Config, diags *diag.Diagnostics) *lokiw
 				RemoteTimeout:     config.Timeout,
 				TenantID:          config.TenantID,
 				RetryOnHTTP429:    !config.DropRateLimitedBatches,
+				Encoding:          lokiclient.EncodingSnappy,
+				FutureTolerance:   lokiclient.FutureTolerance,
 			},
 		},
 		ExternalLabels: convertFlagLabels(config.ExternalLabels),
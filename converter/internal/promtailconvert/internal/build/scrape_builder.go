@@ -131,10 +131,9 @@ func (s *ScrapeConfigBuilder) getOrNewProcessStageReceivers() []loki.LogsReceive
 			flowStages[i] = fs
 		}
 	}
-	args := process.Arguments{
-		ForwardTo: s.globalCtx.WriteReceivers,
-		Stages:    flowStages,
-	}
+	args := process.DefaultArguments
+	args.ForwardTo = s.globalCtx.WriteReceivers
+	args.Stages = flowStages
 	compLabel := common.LabelForParts(s.globalCtx.LabelPrefix, s.cfg.JobName)
 	s.f.Body().AppendBlock(common.NewBlockWithOverride([]string{"loki", "process"}, compLabel, args))
 	s.processStageReceivers = []loki.LogsReceiver{common.ConvertLogsReceiver{
@@ -0,0 +1,78 @@
+// Package featuregate describes the stability levels of features, such as
+// Flow components, and gates the use of unstable features.
+package featuregate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stability is the stability level of a feature.
+type Stability int
+
+// Supported stability levels, from the least to the most stable.
+const (
+	// StabilityUndefined is the zero value of Stability. Features must not be
+	// left at this level.
+	StabilityUndefined Stability = iota
+	// StabilityExperimental is for features which may change or be removed in
+	// any release.
+	StabilityExperimental
+	// StabilityBeta is for features which may change in minor releases, but
+	// which are unlikely to be removed.
+	StabilityBeta
+	// StabilityStable is for features covered by the backward compatibility
+	// guarantees.
+	StabilityStable
+)
+
+var stabilityNames = map[Stability]string{
+	StabilityUndefined:    "undefined",
+	StabilityExperimental: "experimental",
+	StabilityBeta:         "beta",
+	StabilityStable:       "stable",
+}
+
+// AllowedStabilities returns the names of the stability levels which can be
+// configured, from the least to the most stable.
+func AllowedStabilities() []string {
+	return []string{
+		StabilityExperimental.String(),
+		StabilityBeta.String(),
+		StabilityStable.String(),
+	}
+}
+
+// String returns the name of the stability level.
+func (s Stability) String() string {
+	if name, ok := stabilityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Stability(%d)", int(s))
+}
+
+// Set implements pflag.Value, setting s from the name of a stability level.
+func (s *Stability) Set(name string) error {
+	for level, levelName := range stabilityNames {
+		if level != StabilityUndefined && levelName == name {
+			*s = level
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid stability level %q, must be one of: %s", name, strings.Join(AllowedStabilities(), ", "))
+}
+
+// Type implements pflag.Value.
+func (s *Stability) Type() string { return "string" }
+
+// AllowedAt returns an error if a feature at the featureStability level can't
+// be used when the minimum allowed stability level is minStability.
+func AllowedAt(minStability Stability, featureStability Stability) error {
+	if featureStability == StabilityUndefined {
+		return fmt.Errorf("stability level of the feature is undefined")
+	}
+	if featureStability < minStability {
+		return fmt.Errorf("stability level %q is below the minimum allowed stability level %q", featureStability, minStability)
+	}
+	return nil
+}
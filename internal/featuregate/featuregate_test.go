@@ -0,0 +1,44 @@
+package featuregate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowedAt(t *testing.T) {
+	tests := []struct {
+		min, feature Stability
+		allowed      bool
+	}{
+		{StabilityStable, StabilityStable, true},
+		{StabilityStable, StabilityBeta, false},
+		{StabilityStable, StabilityExperimental, false},
+		{StabilityBeta, StabilityStable, true},
+		{StabilityBeta, StabilityBeta, true},
+		{StabilityBeta, StabilityExperimental, false},
+		{StabilityExperimental, StabilityExperimental, true},
+		{StabilityExperimental, StabilityUndefined, false},
+	}
+	for _, tc := range tests {
+		err := AllowedAt(tc.min, tc.feature)
+		if tc.allowed {
+			require.NoError(t, err, "%s feature must be allowed at %s", tc.feature, tc.min)
+		} else {
+			require.Error(t, err, "%s feature must not be allowed at %s", tc.feature, tc.min)
+		}
+	}
+
+	require.EqualError(t, AllowedAt(StabilityStable, StabilityBeta), `stability level "beta" is below the minimum allowed stability level "stable"`)
+}
+
+func TestStabilitySet(t *testing.T) {
+	var s Stability
+	require.NoError(t, s.Set("beta"))
+	require.Equal(t, StabilityBeta, s)
+	require.Equal(t, "beta", s.String())
+
+	require.EqualError(t, s.Set("undefined"), `invalid stability level "undefined", must be one of: experimental, beta, stable`)
+	require.EqualError(t, s.Set("alpha"), `invalid stability level "alpha", must be one of: experimental, beta, stable`)
+	require.Equal(t, StabilityBeta, s)
+}
@@ -0,0 +1,230 @@
+package waltools
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/wlog"
+)
+
+// SegmentStats stores statistics on a single segment or checkpoint of the
+// WAL.
+type SegmentStats struct {
+	// Name is the name of the segment file or checkpoint directory.
+	Name string
+
+	// Checkpoint is true if the stats are for the most recently created
+	// checkpoint.
+	Checkpoint bool
+
+	// Checkpointed is true for segments whose records are also stored in the
+	// checkpoint. These segments are deleted once the WAL is truncated.
+	Checkpointed bool
+
+	// Size is the size of the segment in bytes.
+	Size int64
+
+	// Series is the number of series records in the segment.
+	Series int
+
+	// Samples is the number of samples in the segment, including histogram
+	// samples.
+	Samples int
+
+	// From holds the timestamp of the oldest sample in the segment. From and
+	// To are the zero time if the segment has no samples.
+	From time.Time
+
+	// To holds the timestamp of the newest sample in the segment.
+	To time.Time
+}
+
+// CalculateSegmentStats calculates the statistics of the most recent
+// checkpoint and of every segment of the WAL for the given directory. walDir
+// must be a folder containing segment files and checkpoint directories.
+//
+// The WAL is only read, so CalculateSegmentStats can be used on the WAL of a
+// running process. The records which are still being written at the end of
+// the newest segment are ignored, and the segments and checkpoints which are
+// deleted by a truncation while they're listed are skipped.
+func CalculateSegmentStats(walDir string) ([]SegmentStats, error) {
+	var res []SegmentStats
+
+	checkpoint, checkpointIdx, err := wlog.LastCheckpoint(walDir)
+	if err != nil && err != record.ErrNotFound {
+		return nil, err
+	}
+	if checkpoint != "" {
+		stats, err := checkpointStats(checkpoint)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// The checkpoint was replaced by a newer one.
+			checkpoint = ""
+		case err != nil:
+			return nil, err
+		default:
+			res = append(res, stats)
+		}
+	}
+
+	first, last, err := wlog.Segments(walDir)
+	if err != nil {
+		return nil, err
+	}
+	// Segments returns -1 for both first and last if there are no segments.
+	for i := first; i >= 0 && i <= last; i++ {
+		// The newest segment may be written to while it's read.
+		stats, err := segmentStats(wlog.SegmentName(walDir, i), i == last)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The segment was deleted by a truncation.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		stats.Checkpointed = checkpoint != "" && i <= checkpointIdx
+		res = append(res, stats)
+	}
+
+	return res, nil
+}
+
+// checkpointStats returns the statistics of the checkpoint directory dir.
+func checkpointStats(dir string) (SegmentStats, error) {
+	stats := SegmentStats{Name: filepath.Base(dir), Checkpoint: true}
+	size, err := dirSize(dir)
+	if err != nil {
+		return stats, err
+	}
+	stats.Size = size
+
+	sr, err := wlog.NewSegmentsReader(dir)
+	if err != nil {
+		return stats, err
+	}
+	defer sr.Close()
+	err = stats.read(wlog.NewReader(sr), false)
+	return stats, err
+}
+
+// segmentStats returns the statistics of the segment file name. live must be
+// true if the segment may still be written to.
+func segmentStats(name string, live bool) (SegmentStats, error) {
+	stats := SegmentStats{Name: filepath.Base(name)}
+
+	s, err := wlog.OpenReadSegment(name)
+	if err != nil {
+		return stats, err
+	}
+	defer s.Close()
+	info, err := s.Stat()
+	if err != nil {
+		return stats, err
+	}
+	stats.Size = info.Size()
+
+	if live {
+		err = stats.read(wlog.NewLiveReader(log.NewNopLogger(), wlog.NewLiveReaderMetrics(nil), s), true)
+	} else {
+		err = stats.read(wlog.NewReader(wlog.NewSegmentBufReader(s)), false)
+	}
+	return stats, err
+}
+
+// recordReader is implemented by wlog.Reader and wlog.LiveReader.
+type recordReader interface {
+	Next() bool
+	Record() []byte
+	Err() error
+}
+
+// read counts the series and samples of the records read from r. live must
+// be true if r is a wlog.LiveReader.
+func (s *SegmentStats) read(r recordReader, live bool) error {
+	var (
+		dec      record.Decoder
+		from, to int64 = math.MaxInt64, math.MinInt64
+	)
+	observe := func(t int64) {
+		s.Samples++
+		if t < from {
+			from = t
+		}
+		if t > to {
+			to = t
+		}
+	}
+
+	for r.Next() {
+		rec := r.Record()
+
+		// Like for the stats of the whole WAL, other record types are ignored.
+		switch dec.Type(rec) {
+		case record.Series:
+			series, err := dec.Series(rec, nil)
+			if err != nil {
+				return err
+			}
+			s.Series += len(series)
+		case record.Samples:
+			samples, err := dec.Samples(rec, nil)
+			if err != nil {
+				return err
+			}
+			for _, sample := range samples {
+				observe(sample.T)
+			}
+		case record.HistogramSamples:
+			samples, err := dec.HistogramSamples(rec, nil)
+			if err != nil {
+				return err
+			}
+			for _, sample := range samples {
+				observe(sample.T)
+			}
+		case record.FloatHistogramSamples:
+			samples, err := dec.FloatHistogramSamples(rec, nil)
+			if err != nil {
+				return err
+			}
+			for _, sample := range samples {
+				observe(sample.T)
+			}
+		}
+	}
+
+	if s.Samples > 0 {
+		s.From = timestamp.Time(from)
+		s.To = timestamp.Time(to)
+	}
+
+	err := r.Err()
+	if live && errors.Is(err, io.EOF) {
+		// A LiveReader reports the end of the data written so far as io.EOF.
+		return nil
+	}
+	return err
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,77 @@
+package waltools
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/wlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentStats(t *testing.T) {
+	walDir := setupTestWAL(t)
+	stats, err := CalculateSegmentStats(walDir)
+	require.NoError(t, err)
+	require.Len(t, stats, 5)
+
+	// Test sizes and times separately since they depend on the encoding.
+	for i, s := range stats {
+		require.Equal(t, s.Series > 0 || s.Samples > 0, s.Size > 0, "size of %s", s.Name)
+		stats[i].Size = 0
+	}
+	require.Equal(t, int64(1), timestamp.FromTime(stats[3].From))
+	require.Equal(t, int64(20), timestamp.FromTime(stats[3].To))
+	stats[3].From, stats[3].To = stats[0].From, stats[0].To
+
+	require.Equal(t, []SegmentStats{
+		{Name: "checkpoint.00000001", Checkpoint: true, Series: 21},
+		{Name: "00000000", Checkpointed: true, Series: 21},
+		{Name: "00000001", Checkpointed: true},
+		{Name: "00000002", Samples: 21},
+		{Name: "00000003"},
+	}, stats)
+}
+
+func TestSegmentStats_Live(t *testing.T) {
+	walDir := filepath.Join(t.TempDir(), "wal")
+	w, err := wlog.NewSize(log.NewNopLogger(), prometheus.NewRegistry(), walDir, wlog.DefaultSegmentSize, wlog.CompressionSnappy)
+	require.NoError(t, err)
+	defer w.Close()
+
+	var encoder record.Encoder
+	require.NoError(t, w.Log(encoder.Series([]record.RefSeries{{Ref: 1, Labels: labels.FromStrings("__name__", "test")}}, nil)))
+	require.NoError(t, w.Log(encoder.Samples([]record.RefSample{{Ref: 1, T: 10, V: 1}, {Ref: 1, T: 20, V: 1}}, nil)))
+
+	// The segment is still open for writing.
+	stats, err := CalculateSegmentStats(walDir)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, "00000000", stats[0].Name)
+	require.Equal(t, 1, stats[0].Series)
+	require.Equal(t, 2, stats[0].Samples)
+	require.Equal(t, int64(20), timestamp.FromTime(stats[0].To))
+}
+
+func TestSegmentStats_Deleted(t *testing.T) {
+	walDir := setupTestWAL(t)
+
+	// The segments and checkpoints deleted by a truncation while the WAL is
+	// listed are reported as missing, so that they're skipped.
+	name := wlog.SegmentName(walDir, 0)
+	require.NoError(t, os.Remove(name))
+	_, err := segmentStats(name, false)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	checkpoint, _, err := wlog.LastCheckpoint(walDir)
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(checkpoint))
+	_, err = checkpointStats(checkpoint)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -22,13 +22,22 @@ func init() {
 }
 
 func injectVersion() {
-	version.Version = Version
+	// Labels with an empty value are dropped, so unset values are reported as
+	// unknown to keep every label of the build information metric.
+	version.Version = orUnknown(Version)
 	version.Revision = Revision
-	version.Branch = Branch
+	version.Branch = orUnknown(Branch)
 	version.BuildUser = BuildUser
 	version.BuildDate = BuildDate
 }
 
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
 // NewCollector returns a collector that exports metrics about current
 // version information.
 func NewCollector(program string) prometheus.Collector {
@@ -0,0 +1,121 @@
+package flow_test
+
+// This file contains tests which verify that the log_level attribute of a
+// component block only affects logs emitted by that component.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/grafana/agent/component/prometheus/remotewrite"
+	_ "github.com/grafana/agent/component/prometheus/scrape"
+)
+
+func TestComponentLogLevel(t *testing.T) {
+	// Scrapes of the target always fail, which prometheus.scrape reports at the
+	// debug level on every scrape.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	config := fmt.Sprintf(`
+	prometheus.scrape "default" {
+		targets         = [{"__address__" = %q}]
+		forward_to      = [prometheus.remote_write.default.receiver]
+		scrape_interval = "50ms"
+		scrape_timeout  = "25ms"
+		log_level       = "debug"
+	}
+
+	prometheus.remote_write "default" {
+		endpoint {
+			url = "http://%s/api/v1/write"
+		}
+	}
+`, addr, addr)
+
+	var buf syncBuffer
+	logger, err := logging.New(&buf, logging.Options{
+		Level:  logging.LevelInfo,
+		Format: logging.FormatLogfmt,
+	})
+	require.NoError(t, err)
+
+	opts := testOptions(t)
+	opts.Logger = logger
+
+	ctrl := flow.New(opts)
+	f, err := flow.ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool {
+		return countLines(buf.String(), "level=debug", "component=prometheus.scrape.default", "Scrape failed") >= 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	logs := buf.String()
+	require.Zero(t, countLines(logs, "level=debug", "component=prometheus.remote_write.default"), "remote_write should not log at debug level")
+	require.NotZero(t, countLines(logs, "level=info", "component=prometheus.remote_write.default"), "remote_write should still log at info level")
+}
+
+// countLines returns the number of lines in logs which contain all substrs.
+func countLines(logs string, substrs ...string) int {
+	var count int
+NextLine:
+	for _, line := range strings.Split(logs, "\n") {
+		for _, substr := range substrs {
+			if !strings.Contains(line, substr) {
+				continue NextLine
+			}
+		}
+		count++
+	}
+	return count
+}
+
+type syncBuffer struct {
+	mut sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.buf.Reset()
+}
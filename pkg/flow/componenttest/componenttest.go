@@ -178,6 +178,22 @@ func (c *Controller) buildComponent(dataPath string, args component.Arguments) (
 	return inner, nil
 }
 
+// DebugInfo returns the current debug information of the running component.
+// Should only be called after Run.
+func (c *Controller) DebugInfo() (interface{}, error) {
+	c.innerMut.Lock()
+	defer c.innerMut.Unlock()
+
+	if c.inner == nil {
+		return nil, fmt.Errorf("component is not running")
+	}
+	dc, ok := c.inner.(component.DebugComponent)
+	if !ok {
+		return nil, fmt.Errorf("component does not expose debug information")
+	}
+	return dc.DebugInfo(), nil
+}
+
 // Update updates the running component. Should only be called after Run.
 func (c *Controller) Update(args component.Arguments) error {
 	c.innerMut.Lock()
@@ -188,3 +204,38 @@ func (c *Controller) Update(args component.Arguments) error {
 	}
 	return c.inner.Update(args)
 }
+
+// Pause pauses the running component. Should only be called after Run.
+func (c *Controller) Pause() error {
+	pc, err := c.pausable()
+	if err != nil {
+		return err
+	}
+	pc.Pause()
+	return nil
+}
+
+// Resume resumes the running component after a call to Pause. Should only be
+// called after Run.
+func (c *Controller) Resume() error {
+	pc, err := c.pausable()
+	if err != nil {
+		return err
+	}
+	pc.Resume()
+	return nil
+}
+
+func (c *Controller) pausable() (component.PausableComponent, error) {
+	c.innerMut.Lock()
+	defer c.innerMut.Unlock()
+
+	if c.inner == nil {
+		return nil, fmt.Errorf("component is not running")
+	}
+	pc, ok := c.inner.(component.PausableComponent)
+	if !ok {
+		return nil, component.ErrComponentNotPausable
+	}
+	return pc, nil
+}
@@ -50,6 +50,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging"
@@ -95,6 +96,22 @@ type Options struct {
 	// loaded config source.
 	OnExportsChange func(exports map[string]any)
 
+	// MinStability is the minimum stability level of the components which can
+	// be used in the loaded config source. Components of any stability level
+	// can be used if MinStability is unset.
+	MinStability featuregate.Stability
+
+	// ComponentsSoftLimit is the number of components across the controller
+	// and its modules above which a warning is logged. The soft limit is
+	// disabled if ComponentsSoftLimit is 0.
+	ComponentsSoftLimit int
+
+	// ComponentsHardLimit is the maximum number of components across the
+	// controller and its modules. Loading config sources which would exceed
+	// the hard limit fails. The hard limit is disabled if ComponentsHardLimit
+	// is 0.
+	ComponentsHardLimit int
+
 	// List of Services to run with the Flow controller.
 	//
 	// Services are configured when LoadFile is invoked. Services are started
@@ -122,10 +139,11 @@ type Flow struct {
 // New creates a new, unstarted Flow controller. Call Run to run the controller.
 func New(o Options) *Flow {
 	return newController(controllerOptions{
-		Options:        o,
-		ModuleRegistry: newModuleRegistry(),
-		IsModule:       false, // We are creating a new root controller.
-		WorkerPool:     worker.NewDefaultWorkerPool(),
+		Options:          o,
+		ModuleRegistry:   newModuleRegistry(),
+		ComponentLimiter: controller.NewComponentLimiter(o.Logger, o.Reg, o.ComponentsSoftLimit, o.ComponentsHardLimit),
+		IsModule:         false, // We are creating a new root controller.
+		WorkerPool:       worker.NewDefaultWorkerPool(),
 	})
 }
 
@@ -136,6 +154,7 @@ type controllerOptions struct {
 
 	ComponentRegistry controller.ComponentRegistry // Custom component registry used in tests.
 	ModuleRegistry    *moduleRegistry              // Where to register created modules.
+	ComponentLimiter  *controller.ComponentLimiter // Limit on the number of components shared with modules.
 	IsModule          bool                         // Whether this controller is for a module.
 	// A worker pool to evaluate components asynchronously. A default one will be created if this is nil.
 	WorkerPool worker.Pool
@@ -165,6 +184,10 @@ func newController(o controllerOptions) *Flow {
 		workerPool = worker.NewDefaultWorkerPool()
 	}
 
+	if o.MinStability == featuregate.StabilityUndefined {
+		o.MinStability = featuregate.StabilityExperimental
+	}
+
 	f := &Flow{
 		log:    log,
 		tracer: tracer,
@@ -192,10 +215,13 @@ func newController(o controllerOptions) *Flow {
 			OnExportsChange: o.OnExportsChange,
 			Registerer:      o.Reg,
 			ControllerID:    o.ControllerID,
+			MinStability:    o.MinStability,
 			NewModuleController: func(id string) controller.ModuleController {
 				return newModuleController(&moduleControllerOptions{
 					ComponentRegistry: o.ComponentRegistry,
+					MinStability:      o.MinStability,
 					ModuleRegistry:    o.ModuleRegistry,
+					ComponentLimiter:  o.ComponentLimiter,
 					Logger:            log,
 					Tracer:            tracer,
 					Reg:               o.Reg,
@@ -218,6 +244,7 @@ func newController(o controllerOptions) *Flow {
 		Host:              f,
 		ComponentRegistry: o.ComponentRegistry,
 		WorkerPool:        workerPool,
+		ComponentLimiter:  o.ComponentLimiter,
 	})
 
 	return f
@@ -1,7 +1,11 @@
 package flow
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
@@ -37,6 +41,45 @@ func (f *Flow) GetComponent(id component.ID, opts component.InfoOptions) (*compo
 	return f.getComponentDetail(cn, graph, opts), nil
 }
 
+// PauseComponent implements [component.PauseProvider].
+func (f *Flow) PauseComponent(id component.ID) error {
+	return f.setComponentPaused(id, true)
+}
+
+// ResumeComponent implements [component.PauseProvider].
+func (f *Flow) ResumeComponent(id component.ID) error {
+	return f.setComponentPaused(id, false)
+}
+
+func (f *Flow) setComponentPaused(id component.ID, paused bool) error {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	if id.ModuleID != "" {
+		mod, ok := f.modules.Get(id.ModuleID)
+		if !ok {
+			return component.ErrComponentNotFound
+		}
+
+		return mod.f.setComponentPaused(component.ID{LocalID: id.LocalID}, paused)
+	}
+
+	node := f.loader.Graph().GetByID(id.LocalID)
+	if node == nil {
+		return component.ErrComponentNotFound
+	}
+
+	cn, ok := node.(*controller.ComponentNode)
+	if !ok {
+		return fmt.Errorf("%q is not a component", id)
+	}
+
+	if paused {
+		return cn.Pause()
+	}
+	return cn.Resume()
+}
+
 // ListComponents implements [component.Provider].
 func (f *Flow) ListComponents(moduleID string, opts component.InfoOptions) ([]*component.Info, error) {
 	f.loadMut.RLock()
@@ -63,6 +106,35 @@ func (f *Flow) ListComponents(moduleID string, opts component.InfoOptions) ([]*c
 	return detail, nil
 }
 
+// ListLoadedComponents returns the components of the loaded config, followed
+// by the components of the modules they created, recursively. Unlike
+// ListComponents, it doesn't require f or the modules to be running, so it can
+// be used to inspect a config which was loaded but not run.
+func (f *Flow) ListLoadedComponents(opts component.InfoOptions) []*component.Info {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	var (
+		components = f.loader.Components()
+		graph      = f.loader.OriginalGraph()
+		detail     []*component.Info
+	)
+	for _, cn := range components {
+		detail = append(detail, f.getComponentDetail(cn, graph, opts))
+
+		mc, ok := cn.ModuleController().(*moduleController)
+		if !ok {
+			continue
+		}
+		mods := mc.createdModules()
+		sort.Slice(mods, func(i, j int) bool { return mods[i].o.ID < mods[j].o.ID })
+		for _, mod := range mods {
+			detail = append(detail, mod.f.ListLoadedComponents(opts)...)
+		}
+	}
+	return detail
+}
+
 func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph, opts component.InfoOptions) *component.Info {
 	var references, referencedBy []string
 
@@ -127,3 +199,62 @@ func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph
 		DebugInfo: debugInfo,
 	}
 }
+
+// Drain sends the data buffered by the running components implementing
+// [component.DrainableComponent], returning once all of them are drained or
+// ctx is canceled. A component is drained once the components sending data to
+// it are drained, so that the data they send is drained as well. Independent
+// components are drained concurrently.
+//
+// Drain should be called on shutdown, before canceling the context given to
+// Run.
+func (f *Flow) Drain(ctx context.Context) error {
+	f.loadMut.RLock()
+	graph := f.loader.Graph()
+	f.loadMut.RUnlock()
+
+	var (
+		nodes   = graph.Nodes()
+		drained = make(map[dag.Node]chan struct{}, len(nodes))
+
+		wg   sync.WaitGroup
+		mut  sync.Mutex
+		errs error
+	)
+	for _, n := range nodes {
+		drained[n] = make(chan struct{})
+	}
+
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n dag.Node) {
+			defer wg.Done()
+			defer close(drained[n])
+
+			// Components depend on the components they send data to.
+			for _, dependant := range graph.Dependants(n) {
+				select {
+				case <-drained[dependant]:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			cn, ok := n.(*controller.ComponentNode)
+			if !ok {
+				return
+			}
+			if err := cn.Drain(ctx); err != nil {
+				mut.Lock()
+				errs = errors.Join(errs, fmt.Errorf("draining %s: %w", cn.NodeID(), err))
+				mut.Unlock()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if errs != nil {
+		return errs
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,220 @@
+package flow
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestController_PauseComponent(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	registry := controller.RegistryMap{
+		"testcomponents.pausable": component.Registration{
+			Name:      "testcomponents.pausable",
+			Stability: featuregate.StabilityStable,
+			Args:      struct{}{},
+
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				return &pausableFake{}, nil
+			},
+		},
+		"testcomponents.fake": component.Registration{
+			Name:      "testcomponents.fake",
+			Stability: featuregate.StabilityStable,
+			Args:      struct{}{},
+
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				return &testcomponents.Fake{}, nil
+			},
+		},
+	}
+
+	ctrl := newController(controllerOptions{
+		Options:           testOptions(t),
+		ComponentRegistry: registry,
+		ModuleRegistry:    newModuleRegistry(),
+	})
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.pausable "default" { }
+		testcomponents.fake "default" { }
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	var (
+		id        = component.ID{LocalID: "testcomponents.pausable.default"}
+		inner     = ctrl.loader.Graph().GetByID(id.LocalID).(*controller.ComponentNode).Component().(*pausableFake)
+		getHealth = func() component.HealthType {
+			info, err := ctrl.GetComponent(id, component.InfoOptions{GetHealth: true})
+			require.NoError(t, err)
+			return info.Health.Health
+		}
+	)
+	require.NotEqual(t, component.HealthTypePaused, getHealth())
+
+	require.NoError(t, ctrl.PauseComponent(id))
+	require.True(t, inner.paused.Load())
+	require.Equal(t, component.HealthTypePaused, getHealth())
+
+	require.NoError(t, ctrl.ResumeComponent(id))
+	require.False(t, inner.paused.Load())
+	require.NotEqual(t, component.HealthTypePaused, getHealth())
+
+	require.ErrorIs(t, ctrl.PauseComponent(component.ID{LocalID: "testcomponents.fake.default"}), component.ErrComponentNotPausable)
+	require.ErrorIs(t, ctrl.PauseComponent(component.ID{LocalID: "testcomponents.pausable.missing"}), component.ErrComponentNotFound)
+}
+
+// pausableFake is a fake component which records whether it's paused.
+type pausableFake struct {
+	testcomponents.Fake
+	paused atomic.Bool
+}
+
+var _ component.PausableComponent = (*pausableFake)(nil)
+
+func (p *pausableFake) Pause()  { p.paused.Store(true) }
+func (p *pausableFake) Resume() { p.paused.Store(false) }
+
+func TestController_Drain(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		mut     sync.Mutex
+		drained []string
+	)
+	registry := controller.RegistryMap{
+		"testcomponents.drainable": component.Registration{
+			Name:      "testcomponents.drainable",
+			Stability: featuregate.StabilityStable,
+			Args:      drainableArgs{},
+			Exports:   drainableExports{},
+
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				opts.OnStateChange(drainableExports{Output: opts.ID})
+				return &drainableFake{drain: func() {
+					mut.Lock()
+					defer mut.Unlock()
+					drained = append(drained, opts.ID)
+				}}, nil
+			},
+		},
+	}
+
+	ctrl := newController(controllerOptions{
+		Options:           testOptions(t),
+		ComponentRegistry: registry,
+		ModuleRegistry:    newModuleRegistry(),
+	})
+	defer cleanUpController(ctrl)
+
+	// Data is sent from source to middle, and from middle to sink.
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.drainable "sink" { }
+		testcomponents.drainable "middle" {
+			input = testcomponents.drainable.sink.output
+		}
+		testcomponents.drainable "source" {
+			input = testcomponents.drainable.middle.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	require.NoError(t, ctrl.Drain(context.Background()))
+	require.Equal(t, []string{
+		"testcomponents.drainable.source",
+		"testcomponents.drainable.middle",
+		"testcomponents.drainable.sink",
+	}, drained)
+}
+
+type drainableArgs struct {
+	Input string `river:"input,attr,optional"`
+}
+
+type drainableExports struct {
+	Output string `river:"output,attr"`
+}
+
+// drainableFake is a fake component which calls drain when it's drained.
+type drainableFake struct {
+	testcomponents.Fake
+	drain func()
+}
+
+var _ component.DrainableComponent = (*drainableFake)(nil)
+
+func (d *drainableFake) Drain(_ context.Context) error {
+	d.drain()
+	return nil
+}
+
+func TestController_ListLoadedComponents(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	registry := controller.RegistryMap{
+		"testcomponents.fake": component.Registration{
+			Name:      "testcomponents.fake",
+			Stability: featuregate.StabilityStable,
+			Args:      struct{}{},
+
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				return &testcomponents.Fake{}, nil
+			},
+		},
+		"testcomponents.module": component.Registration{
+			Name:      "testcomponents.module",
+			Stability: featuregate.StabilityStable,
+			Args:      struct{}{},
+
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				mod, err := opts.ModuleController.NewModule("", nil)
+				if err != nil {
+					return nil, err
+				}
+				if err := mod.LoadConfig([]byte(`testcomponents.fake "inner" { }`), nil); err != nil {
+					return nil, err
+				}
+				return &testcomponents.Fake{}, nil
+			},
+		},
+	}
+
+	ctrl := newController(controllerOptions{
+		Options:           testOptions(t),
+		ComponentRegistry: registry,
+		ModuleRegistry:    newModuleRegistry(),
+	})
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.fake "default" { }
+		testcomponents.module "default" { }
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	// The module isn't running, so it isn't listed by ListComponents.
+	_, err = ctrl.ListComponents("testcomponents.module.default", component.InfoOptions{})
+	require.ErrorIs(t, err, component.ErrModuleNotFound)
+
+	var ids []component.ID
+	for _, info := range ctrl.ListLoadedComponents(component.InfoOptions{}) {
+		ids = append(ids, info.ID)
+	}
+	require.ElementsMatch(t, []component.ID{
+		{LocalID: "testcomponents.fake.default"},
+		{LocalID: "testcomponents.module.default"},
+		{ModuleID: "testcomponents.module.default", LocalID: "testcomponents.fake.inner"},
+	}, ids)
+}
@@ -0,0 +1,154 @@
+package flow_test
+
+// This file contains tests which verify that reloading the configuration
+// keeps the state of the components which are still defined.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/agent/service"
+	cluster_service "github.com/grafana/agent/service/cluster"
+	http_service "github.com/grafana/agent/service/http"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/grafana/agent/component/prometheus/relabel"
+	_ "github.com/grafana/agent/component/prometheus/scrape"
+)
+
+func TestReload_KeepsUnchangedComponents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, "test_metric 1")
+	}))
+	defer srv.Close()
+	srvURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	config := fmt.Sprintf(`
+		prometheus.scrape "app" {
+			targets         = [{"__address__" = %q}]
+			forward_to      = [prometheus.relabel.sink.receiver]
+			scrape_interval = "50ms"
+			scrape_timeout  = "40ms"
+		}
+
+		prometheus.relabel "sink" {
+			forward_to = []
+		}
+	`, srvURL.Host)
+
+	reg := prometheus.NewRegistry()
+	ctrl := flow.New(reloadTestOptions(t, reg))
+	loadConfig(t, ctrl, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The scrape manager applies new targets every 5 seconds, so it takes a
+	// while for the first scrape to happen.
+	scrapeID := component.ID{LocalID: "prometheus.scrape.app"}
+	require.Eventually(t, func() bool {
+		return forwardedSamples(t, reg, scrapeID.LocalID) >= 2
+	}, 30*time.Second, 10*time.Millisecond, "samples were never forwarded")
+
+	before := getComponentInstance(t, ctrl, scrapeID)
+	forwardedBefore := forwardedSamples(t, reg, scrapeID.LocalID)
+
+	// Reload the configuration with an unrelated component added.
+	loadConfig(t, ctrl, config+`
+		prometheus.relabel "unrelated" {
+			forward_to = []
+		}
+	`)
+
+	// The scrape component must be the same instance, and its counters must
+	// keep growing from where they were.
+	require.Same(t, before, getComponentInstance(t, ctrl, scrapeID))
+	require.GreaterOrEqual(t, forwardedSamples(t, reg, scrapeID.LocalID), forwardedBefore)
+	require.Eventually(t, func() bool {
+		return forwardedSamples(t, reg, scrapeID.LocalID) >= forwardedBefore+2
+	}, 5*time.Second, 10*time.Millisecond, "samples stopped being forwarded after the reload")
+
+	_, err = ctrl.GetComponent(component.ID{LocalID: "prometheus.relabel.unrelated"}, component.InfoOptions{})
+	require.NoError(t, err)
+}
+
+func reloadTestOptions(t *testing.T, reg prometheus.Registerer) flow.Options {
+	t.Helper()
+	s, err := logging.New(os.Stderr, logging.DefaultOptions)
+	require.NoError(t, err)
+
+	clusterService, err := cluster_service.New(cluster_service.Options{
+		Log:              s,
+		EnableClustering: false,
+		NodeName:         "test-node",
+		AdvertiseAddress: "127.0.0.1:80",
+	})
+	require.NoError(t, err)
+
+	return flow.Options{
+		Logger:   s,
+		DataPath: t.TempDir(),
+		Reg:      reg,
+		Services: []service.Service{
+			http_service.New(http_service.Options{}),
+			clusterService,
+			labelstore.New(nil),
+		},
+	}
+}
+
+func loadConfig(t *testing.T, ctrl *flow.Flow, config string) {
+	t.Helper()
+	f, err := flow.ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+}
+
+func getComponentInstance(t *testing.T, ctrl *flow.Flow, id component.ID) component.Component {
+	t.Helper()
+	info, err := ctrl.GetComponent(id, component.InfoOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, info.Component)
+	return info.Component
+}
+
+// forwardedSamples returns the number of samples the component with the given
+// ID forwarded to the next components.
+func forwardedSamples(t *testing.T, reg prometheus.Gatherer, componentID string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != "agent_prometheus_forwarded_samples_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "component_id" && l.GetValue() == componentID {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
 	"github.com/grafana/agent/pkg/flow/internal/testservices"
@@ -210,8 +211,9 @@ func TestComponents_Using_Services(t *testing.T) {
 
 		registry = controller.RegistryMap{
 			"service_consumer": component.Registration{
-				Name: "service_consumer",
-				Args: struct{}{},
+				Name:      "service_consumer",
+				Stability: featuregate.StabilityStable,
+				Args:      struct{}{},
 
 				Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 					// Call Trigger in a defer so we can make some extra assertions before
@@ -269,8 +271,9 @@ func TestComponents_Using_Services_In_Modules(t *testing.T) {
 
 		registry = controller.RegistryMap{
 			"module_loader": component.Registration{
-				Name: "module_loader",
-				Args: struct{}{},
+				Name:      "module_loader",
+				Stability: featuregate.StabilityStable,
+				Args:      struct{}{},
 
 				Build: func(opts component.Options, _ component.Arguments) (component.Component, error) {
 					mod, err := opts.ModuleController.NewModule("", nil)
@@ -290,8 +293,9 @@ func TestComponents_Using_Services_In_Modules(t *testing.T) {
 			},
 
 			"service_consumer": component.Registration{
-				Name: "service_consumer",
-				Args: struct{}{},
+				Name:      "service_consumer",
+				Stability: featuregate.StabilityStable,
+				Args:      struct{}{},
 
 				Build: func(opts component.Options, _ component.Arguments) (component.Component, error) {
 					// Call Trigger in a defer so we can make some extra assertions before
@@ -2,14 +2,19 @@ package flow
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/dag"
 	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
 	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 )
@@ -53,6 +58,145 @@ func TestController_LoadSource_Evaluation(t *testing.T) {
 	require.Equal(t, "hello, world!", out.(testcomponents.PassthroughExports).Output)
 }
 
+func TestController_HealthMetrics(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	reg := prometheus.NewRegistry()
+	opts := testOptions(t)
+	opts.Reg = reg
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	requireMetrics := func(total, unhealthy int) {
+		t.Helper()
+		expect := fmt.Sprintf(`
+			# HELP agent_components_total Total number of components.
+			# TYPE agent_components_total gauge
+			agent_components_total{controller_id=""} %d
+			# HELP agent_components_unhealthy_total Total number of components which are unhealthy or exited.
+			# TYPE agent_components_unhealthy_total gauge
+			agent_components_unhealthy_total{controller_id=""} %d
+		`, total, unhealthy)
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect), "agent_components_total", "agent_components_unhealthy_total"))
+	}
+
+	load := func(input string) error {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`
+			testcomponents.passthrough "static" {
+				input = "hello, world!"
+			}
+
+			testcomponents.passthrough "forwarded" {
+				input = %s
+			}
+		`, input)))
+		require.NoError(t, err)
+		return ctrl.LoadSource(f, nil)
+	}
+
+	require.NoError(t, load("testcomponents.passthrough.static.output"))
+	requireMetrics(2, 0)
+
+	// The component can't be evaluated and becomes unhealthy.
+	require.Error(t, load("testcomponents.passthrough.static.output + 1"))
+	requireMetrics(2, 1)
+
+	require.NoError(t, load(`"fixed"`))
+	requireMetrics(2, 0)
+}
+
+func TestController_ComponentLimit(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	reg := prometheus.NewRegistry()
+	opts := testOptions(t)
+	opts.Reg = reg
+	opts.ComponentsHardLimit = 3
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	requireActive := func(active int) {
+		t.Helper()
+		expect := fmt.Sprintf(`
+			# HELP agent_components_active Number of components across the controller and its modules.
+			# TYPE agent_components_active gauge
+			agent_components_active %d
+		`, active)
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect), "agent_components_active"))
+	}
+
+	load := func(components int) error {
+		var source strings.Builder
+		for i := 0; i < components; i++ {
+			fmt.Fprintf(&source, "testcomponents.passthrough \"c%d\" {\n\tinput = \"hello\"\n}\n", i)
+		}
+		f, err := ParseSource(t.Name(), []byte(source.String()))
+		require.NoError(t, err)
+		return ctrl.LoadSource(f, nil)
+	}
+
+	require.NoError(t, load(3))
+	requireActive(3)
+
+	// The config isn't loaded, so the components of the previous config keep
+	// running.
+	err := load(4)
+	require.ErrorContains(t, err, "loading 4 components would run 4 components in total, exceeding the hard limit of 3 components")
+	require.Len(t, ctrl.loader.Components(), 3)
+	requireActive(3)
+
+	require.NoError(t, load(1))
+	requireActive(1)
+}
+
+func TestController_LoadSource_Stability(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	registry := controller.RegistryMap{
+		"testcomponents.experimental": component.Registration{
+			Name:      "testcomponents.experimental",
+			Stability: featuregate.StabilityExperimental,
+			Args:      struct{}{},
+
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				return &testcomponents.Fake{}, nil
+			},
+		},
+	}
+
+	f, err := ParseSource(t.Name(), []byte(`testcomponents.experimental "example" {}`))
+	require.NoError(t, err)
+
+	t.Run("below minimum stability level", func(t *testing.T) {
+		opts := testOptions(t)
+		opts.MinStability = featuregate.StabilityBeta
+		ctrl := newController(controllerOptions{
+			Options:           opts,
+			ComponentRegistry: registry,
+			ModuleRegistry:    newModuleRegistry(),
+		})
+		defer cleanUpController(ctrl)
+
+		err := ctrl.LoadSource(f, nil)
+		require.ErrorContains(t, err, `Component "testcomponents.experimental" can't be used: stability level "experimental" is below the minimum allowed stability level "beta"`)
+		require.Empty(t, ctrl.loader.Components())
+	})
+
+	t.Run("at minimum stability level", func(t *testing.T) {
+		opts := testOptions(t)
+		opts.MinStability = featuregate.StabilityExperimental
+		ctrl := newController(controllerOptions{
+			Options:           opts,
+			ComponentRegistry: registry,
+			ModuleRegistry:    newModuleRegistry(),
+		})
+		defer cleanUpController(ctrl)
+
+		require.NoError(t, ctrl.LoadSource(f, nil))
+		require.Len(t, ctrl.loader.Components(), 1)
+	})
+}
+
 func getFields(t *testing.T, g *dag.Graph, nodeID string) (component.Arguments, component.Exports) {
 	t.Helper()
 
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ComponentLimiter limits the total number of components of a controller and
+// all of its modules. A nil ComponentLimiter doesn't limit components.
+type ComponentLimiter struct {
+	log    log.Logger
+	soft   int
+	hard   int
+	active prometheus.Gauge
+
+	mut    sync.Mutex
+	counts map[string]int // Number of components by controller ID.
+}
+
+// NewComponentLimiter creates a new ComponentLimiter. A warning is logged when
+// there are more than soft components, and controllers fail to load more than
+// hard components. Limits set to 0 are disabled.
+func NewComponentLimiter(l log.Logger, reg prometheus.Registerer, soft, hard int) *ComponentLimiter {
+	cl := &ComponentLimiter{
+		log:  l,
+		soft: soft,
+		hard: hard,
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "agent_components_active",
+			Help: "Number of components across the controller and its modules.",
+		}),
+		counts: make(map[string]int),
+	}
+	if reg != nil {
+		reg.MustRegister(cl.active)
+	}
+	return cl
+}
+
+// Set sets the number of components of the controller with the given ID. Set
+// fails and leaves the number unchanged if the total number of components
+// would exceed the hard limit.
+func (cl *ComponentLimiter) Set(controllerID string, n int) error {
+	if cl == nil {
+		return nil
+	}
+
+	cl.mut.Lock()
+	defer cl.mut.Unlock()
+
+	total := n
+	for id, count := range cl.counts {
+		if id != controllerID {
+			total += count
+		}
+	}
+
+	if cl.hard > 0 && total > cl.hard {
+		return fmt.Errorf("loading %d components would run %d components in total, exceeding the hard limit of %d components", n, total, cl.hard)
+	}
+	if cl.soft > 0 && total > cl.soft {
+		level.Warn(cl.log).Log("msg", "number of components exceeds the soft limit", "controller_id", controllerID, "components", total, "soft_limit", cl.soft)
+	}
+
+	cl.counts[controllerID] = n
+	cl.active.Set(float64(total))
+	return nil
+}
+
+// Release releases the components of the controller with the given ID once it
+// stopped.
+func (cl *ComponentLimiter) Release(controllerID string) {
+	if cl == nil {
+		return
+	}
+
+	cl.mut.Lock()
+	defer cl.mut.Unlock()
+
+	delete(cl.counts, controllerID)
+	cl.active.Set(float64(cl.totalLocked()))
+}
+
+// totalLocked returns the total number of components. cl.mut must be held.
+func (cl *ComponentLimiter) totalLocked() int {
+	var total int
+	for _, count := range cl.counts {
+		total += count
+	}
+	return total
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/internal/dag"
 	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging/level"
@@ -31,6 +32,7 @@ type Loader struct {
 	host         service.Host
 	componentReg ComponentRegistry
 	workerPool   worker.Pool
+	limiter      *ComponentLimiter
 	// backoffConfig is used to backoff when an updated component's dependencies cannot be submitted to worker
 	// pool for evaluation in EvaluateDependencies, because the queue is full. This is an unlikely scenario, but when
 	// it happens we should avoid retrying too often to give other goroutines a chance to progress. Having a backoff
@@ -58,6 +60,7 @@ type LoaderOptions struct {
 	Host              service.Host      // Service host (when running services).
 	ComponentRegistry ComponentRegistry // Registry to search for components.
 	WorkerPool        worker.Pool       // Worker pool to use for async tasks.
+	ComponentLimiter  *ComponentLimiter // Limit on the number of components. Optional.
 }
 
 // NewLoader creates a new Loader. Components built by the Loader will be built
@@ -82,6 +85,7 @@ func NewLoader(opts LoaderOptions) *Loader {
 		host:         host,
 		componentReg: reg,
 		workerPool:   opts.WorkerPool,
+		limiter:      opts.ComponentLimiter,
 
 		// This is a reasonable default which should work for most cases. If a component is completely stuck, we would
 		// retry and log an error every 10 seconds, at most.
@@ -134,6 +138,14 @@ func (l *Loader) Apply(args map[string]any, componentBlocks []*ast.BlockStmt, co
 		return diags
 	}
 
+	if err := l.limiter.Set(l.globals.ControllerID, len(componentBlocks)); err != nil {
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  err.Error(),
+		})
+		return diags
+	}
+
 	var (
 		components   = make([]*ComponentNode, 0, len(componentBlocks))
 		componentIDs = make([]ComponentID, 0, len(componentBlocks))
@@ -455,6 +467,16 @@ func (l *Loader) populateComponentNodes(g *dag.Graph, componentBlocks []*ast.Blo
 				continue
 			}
 
+			if err := featuregate.AllowedAt(l.globals.MinStability, registration.Stability); err != nil {
+				diags.Add(diag.Diagnostic{
+					Severity: diag.SeverityLevelError,
+					Message:  fmt.Sprintf("Component %q can't be used: %s", componentName, err),
+					StartPos: block.NamePos.Position(),
+					EndPos:   block.NamePos.Add(len(componentName) - 1).Position(),
+				})
+				continue
+			}
+
 			if block.Label == "" {
 				diags.Add(diag.Diagnostic{
 					Severity: diag.SeverityLevelError,
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/grafana/agent/component"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -90,8 +91,10 @@ func (cm *controllerMetrics) Describe(ch chan<- *prometheus.Desc) {
 }
 
 type controllerCollector struct {
-	l                      *Loader
-	runningComponentsTotal *prometheus.Desc
+	l                        *Loader
+	runningComponentsTotal   *prometheus.Desc
+	componentsTotal          *prometheus.Desc
+	unhealthyComponentsTotal *prometheus.Desc
 }
 
 func newControllerCollector(l *Loader, id string) *controllerCollector {
@@ -103,23 +106,46 @@ func newControllerCollector(l *Loader, id string) *controllerCollector {
 			[]string{"health_type"},
 			map[string]string{"controller_id": id},
 		),
+		componentsTotal: prometheus.NewDesc(
+			"agent_components_total",
+			"Total number of components.",
+			nil,
+			map[string]string{"controller_id": id},
+		),
+		unhealthyComponentsTotal: prometheus.NewDesc(
+			"agent_components_unhealthy_total",
+			"Total number of components which are unhealthy or exited.",
+			nil,
+			map[string]string{"controller_id": id},
+		),
 	}
 }
 
 func (cc *controllerCollector) Collect(ch chan<- prometheus.Metric) {
-	componentsByHealth := make(map[string]int)
+	var (
+		componentsByHealth = make(map[string]int)
+		components         = cc.l.Components()
+		unhealthy          int
+	)
 
-	for _, component := range cc.l.Components() {
-		health := component.CurrentHealth().Health.String()
-		componentsByHealth[health]++
-		component.registry.Collect(ch)
+	for _, cn := range components {
+		health := cn.CurrentHealth().Health
+		componentsByHealth[health.String()]++
+		if health == component.HealthTypeUnhealthy || health == component.HealthTypeExited {
+			unhealthy++
+		}
+		cn.registry.Collect(ch)
 	}
 
 	for health, count := range componentsByHealth {
 		ch <- prometheus.MustNewConstMetric(cc.runningComponentsTotal, prometheus.GaugeValue, float64(count), health)
 	}
+	ch <- prometheus.MustNewConstMetric(cc.componentsTotal, prometheus.GaugeValue, float64(len(components)))
+	ch <- prometheus.MustNewConstMetric(cc.unhealthyComponentsTotal, prometheus.GaugeValue, float64(unhealthy))
 }
 
 func (cc *controllerCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- cc.runningComponentsTotal
+	ch <- cc.componentsTotal
+	ch <- cc.unhealthyComponentsTotal
 }
@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/logging"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/flow/tracing"
@@ -72,6 +73,7 @@ type ComponentGlobals struct {
 	ControllerID        string                                 // ID of controller.
 	NewModuleController func(id string) ModuleController       // Func to generate a module controller.
 	GetServiceData      func(name string) (interface{}, error) // Get data for a service.
+	MinStability        featuregate.Stability                  // Minimum allowed stability level of components.
 }
 
 // ComponentNode is a controller node which manages a user-defined component.
@@ -92,12 +94,14 @@ type ComponentNode struct {
 	moduleController  ModuleController
 	OnComponentUpdate func(cn *ComponentNode) // Informs controller that we need to reevaluate
 	lastUpdateTime    atomic.Time
+	logger            *logging.ChildLogger // Logger of the managed component; nil if there is no global logger.
 
-	mut     sync.RWMutex
-	block   *ast.BlockStmt // Current River block to derive args from
-	eval    *vm.Evaluator
-	managed component.Component // Inner managed component
-	args    component.Arguments // Evaluated arguments for the managed component
+	mut      sync.RWMutex
+	block    *ast.BlockStmt // Current River block to derive args from
+	eval     *vm.Evaluator
+	logLevel *ast.AttributeStmt  // Optional log level override from the River block
+	managed  component.Component // Inner managed component
+	args     component.Arguments // Evaluated arguments for the managed component
 
 	// NOTE(rfratto): health and exports have their own mutex because they may be
 	// set asynchronously while mut is still being held (i.e., when calling Evaluate
@@ -106,6 +110,8 @@ type ComponentNode struct {
 	healthMut  sync.RWMutex
 	evalHealth component.Health // Health of the last evaluate
 	runHealth  component.Health // Health of running the component
+	paused     bool             // Whether the managed component is paused
+	pauseTime  time.Time        // When the managed component was last paused
 
 	exportsMut sync.RWMutex
 	exports    component.Exports // Evaluated exports for the managed component
@@ -149,7 +155,6 @@ func NewComponentNode(globals ComponentGlobals, reg component.Registration, b *a
 		OnComponentUpdate: globals.OnComponentUpdate,
 
 		block: b,
-		eval:  vm.New(b.Body),
 
 		// Prepopulate arguments and exports with their zero values.
 		args:    reg.Args,
@@ -158,6 +163,7 @@ func NewComponentNode(globals ComponentGlobals, reg component.Registration, b *a
 		evalHealth: initHealth,
 		runHealth:  initHealth,
 	}
+	cn.eval, cn.logLevel = newComponentEvaluator(b)
 	cn.managedOpts = getManagedOptions(globals, cn)
 
 	return cn
@@ -165,9 +171,16 @@ func NewComponentNode(globals ComponentGlobals, reg component.Registration, b *a
 
 func getManagedOptions(globals ComponentGlobals, cn *ComponentNode) component.Options {
 	cn.registry = prometheus.NewRegistry()
+
+	var logger log.Logger = globals.Logger
+	if globals.Logger != nil {
+		cn.logger = globals.Logger.Child()
+		logger = cn.logger
+	}
+
 	return component.Options{
 		ID:     cn.globalID,
-		Logger: log.With(globals.Logger, "component", cn.globalID),
+		Logger: log.With(logger, "component", cn.globalID),
 		Registerer: prometheus.WrapRegistererWith(prometheus.Labels{
 			"component_id": cn.globalID,
 		}, cn.registry),
@@ -230,7 +243,29 @@ func (cn *ComponentNode) UpdateBlock(b *ast.BlockStmt) {
 	cn.mut.Lock()
 	defer cn.mut.Unlock()
 	cn.block = b
-	cn.eval = vm.New(b.Body)
+	cn.eval, cn.logLevel = newComponentEvaluator(b)
+}
+
+// logLevelAttr is the name of an attribute which may be set in any component
+// block to override the log level of that component.
+const logLevelAttr = "log_level"
+
+// newComponentEvaluator returns an evaluator for the arguments of the
+// component defined by b. The log_level attribute is not passed to the
+// component and is instead returned separately, if present.
+func newComponentEvaluator(b *ast.BlockStmt) (*vm.Evaluator, *ast.AttributeStmt) {
+	var (
+		body     = make(ast.Body, 0, len(b.Body))
+		logLevel *ast.AttributeStmt
+	)
+	for _, stmt := range b.Body {
+		if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == logLevelAttr {
+			logLevel = attr
+			continue
+		}
+		body = append(body, stmt)
+	}
+	return vm.New(body), logLevel
 }
 
 // Evaluate implements BlockNode and updates the arguments for the managed component
@@ -256,6 +291,10 @@ func (cn *ComponentNode) evaluate(scope *vm.Scope) error {
 	cn.mut.Lock()
 	defer cn.mut.Unlock()
 
+	if err := cn.evaluateLogLevel(scope); err != nil {
+		return err
+	}
+
 	argsPointer := cn.reg.CloneArguments()
 	if err := cn.eval.Evaluate(scope, argsPointer); err != nil {
 		return fmt.Errorf("decoding River: %w", err)
@@ -293,6 +332,22 @@ func (cn *ComponentNode) evaluate(scope *vm.Scope) error {
 	return nil
 }
 
+// evaluateLogLevel applies the log_level attribute of the component block, if
+// any, to the logger of the managed component. cn.mut must be held when
+// calling.
+func (cn *ComponentNode) evaluateLogLevel(scope *vm.Scope) error {
+	var logLevel logging.Level
+	if cn.logLevel != nil {
+		if err := vm.New(cn.logLevel.Value).Evaluate(scope, &logLevel); err != nil {
+			return fmt.Errorf("decoding %s: %w", logLevelAttr, err)
+		}
+	}
+	if cn.logger != nil {
+		cn.logger.SetLevel(logLevel)
+	}
+	return nil
+}
+
 // Run runs the managed component in the calling goroutine until ctx is
 // canceled. Evaluate must have been called at least once without returning an
 // error before calling Run.
@@ -391,6 +446,7 @@ func (cn *ComponentNode) setExports(e component.Exports) {
 //  1. Health from the call to Run().
 //  2. Health from the last call to Evaluate().
 //  3. Health reported from the component.
+//  4. Whether the component is paused.
 func (cn *ComponentNode) CurrentHealth() component.Health {
 	cn.healthMut.RLock()
 	defer cn.healthMut.RUnlock()
@@ -398,14 +454,71 @@ func (cn *ComponentNode) CurrentHealth() component.Health {
 	var (
 		runHealth  = cn.runHealth
 		evalHealth = cn.evalHealth
+		healths    []component.Health
 	)
 
 	if hc, ok := cn.managed.(component.HealthComponent); ok {
-		componentHealth := hc.CurrentHealth()
-		return component.LeastHealthy(runHealth, evalHealth, componentHealth)
+		healths = append(healths, hc.CurrentHealth())
 	}
+	if cn.paused {
+		healths = append(healths, component.Health{
+			Health:     component.HealthTypePaused,
+			Message:    "component paused",
+			UpdateTime: cn.pauseTime,
+		})
+	}
+
+	return component.LeastHealthy(runHealth, append([]component.Health{evalHealth}, healths...)...)
+}
+
+// Pause pauses the managed component until Resume is called. Pause returns
+// [component.ErrComponentNotPausable] if the managed component doesn't
+// implement [component.PausableComponent] or hasn't been built yet.
+func (cn *ComponentNode) Pause() error {
+	return cn.setPaused(true)
+}
 
-	return component.LeastHealthy(runHealth, evalHealth)
+// Resume resumes the managed component after a call to Pause. Resume returns
+// the same errors as Pause.
+func (cn *ComponentNode) Resume() error {
+	return cn.setPaused(false)
+}
+
+func (cn *ComponentNode) setPaused(paused bool) error {
+	cn.mut.RLock()
+	pc, ok := cn.managed.(component.PausableComponent)
+	cn.mut.RUnlock()
+	if !ok {
+		return component.ErrComponentNotPausable
+	}
+
+	cn.healthMut.Lock()
+	defer cn.healthMut.Unlock()
+
+	if cn.paused == paused {
+		return nil
+	}
+	if paused {
+		pc.Pause()
+		cn.pauseTime = time.Now()
+	} else {
+		pc.Resume()
+	}
+	cn.paused = paused
+	return nil
+}
+
+// Drain sends the data buffered by the managed component. Drain does nothing
+// if the managed component doesn't implement [component.DrainableComponent]
+// or hasn't been built yet.
+func (cn *ComponentNode) Drain(ctx context.Context) error {
+	cn.mut.RLock()
+	dc, ok := cn.managed.(component.DrainableComponent)
+	cn.mut.RUnlock()
+	if !ok {
+		return nil
+	}
+	return dc.Drain(ctx)
 }
 
 // DebugInfo returns debugging information from the managed component (if any).
@@ -450,3 +563,9 @@ func (cn *ComponentNode) setRunHealth(t component.HealthType, msg string) {
 func (cn *ComponentNode) ModuleIDs() []string {
 	return cn.moduleController.ModuleIDs()
 }
+
+// ModuleController returns the controller of the modules created by the
+// component.
+func (cn *ComponentNode) ModuleController() ModuleController {
+	return cn.moduleController
+}
@@ -57,20 +57,44 @@ func NewDefaultLoggingConfigNode(globals ComponentGlobals) *LoggingConfigNode {
 func (cn *LoggingConfigNode) Evaluate(scope *vm.Scope) error {
 	cn.mut.RLock()
 	defer cn.mut.RUnlock()
-	args := logging.DefaultOptions
+
+	logger := cn.l.(*logging.Logger)
+	defaults := logger.Defaults()
+
+	args := defaults
 	if cn.eval != nil {
 		if err := cn.eval.Evaluate(scope, &args); err != nil {
 			return fmt.Errorf("decoding River: %w", err)
 		}
+
+		// Options which aren't set in the block fall back to the ones the logger
+		// was created with (e.g., from command-line flags) rather than the
+		// built-in defaults.
+		if !hasAttribute(cn.block, "level") {
+			args.Level = defaults.Level
+		}
+		if !hasAttribute(cn.block, "format") {
+			args.Format = defaults.Format
+		}
 	}
 
-	if err := cn.l.(*logging.Logger).Update(args); err != nil {
+	if err := logger.Update(args); err != nil {
 		return fmt.Errorf("could not update logger: %w", err)
 	}
 
 	return nil
 }
 
+// hasAttribute returns true if b directly contains an attribute called name.
+func hasAttribute(b *ast.BlockStmt, name string) bool {
+	for _, stmt := range b.Body {
+		if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Block implements BlockNode and returns the current block of the managed config node.
 func (cn *LoggingConfigNode) Block() *ast.BlockStmt {
 	cn.mut.RLock()
@@ -21,7 +21,8 @@ type EnabledAware interface {
 // Logger is the logging subsystem of Flow. It supports being dynamically
 // updated at runtime.
 type Logger struct {
-	inner io.Writer // Writer passed to New.
+	inner    io.Writer // Writer passed to New.
+	defaults Options   // Options passed to New.
 
 	level   *slog.LevelVar // Current configured level.
 	format  *formatVar     // Current configured format.
@@ -45,7 +46,8 @@ func New(w io.Writer, o Options) (*Logger, error) {
 	)
 
 	l := &Logger{
-		inner: w,
+		inner:    w,
+		defaults: o,
 
 		level:  &leveler,
 		format: &format,
@@ -63,6 +65,10 @@ func New(w io.Writer, o Options) (*Logger, error) {
 	return l, nil
 }
 
+// Defaults returns the options the logger was created with. Defaults are used
+// for any options which are not explicitly configured in the logging block.
+func (l *Logger) Defaults() Options { return l.defaults }
+
 // Handler returns a [slog.Handler]. The returned Handler remains valid if l is
 // updated.
 func (l *Logger) Handler() slog.Handler { return l.handler }
@@ -154,3 +160,77 @@ func (w *writerVar) Write(p []byte) (n int, err error) {
 
 	return w.w.Write(p)
 }
+
+// Child returns a new ChildLogger which writes to l. The ChildLogger logs at
+// the currently configured level of l unless overridden by calling
+// [ChildLogger.SetLevel].
+func (l *Logger) Child() *ChildLogger {
+	leveler := &levelOverride{parent: l.level}
+
+	return &ChildLogger{
+		level: leveler,
+		handler: &handler{
+			w:         l.writer,
+			leveler:   leveler,
+			formatter: l.format,
+		},
+	}
+}
+
+// ChildLogger is a logger which shares its output and format with the Logger
+// it was created from, but may have its own log level.
+type ChildLogger struct {
+	level   *levelOverride
+	handler *handler
+}
+
+var _ EnabledAware = (*ChildLogger)(nil)
+
+// Enabled implements EnabledAware interface.
+func (l *ChildLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	return l.handler.Enabled(ctx, level)
+}
+
+// Handler returns a [slog.Handler]. The returned Handler remains valid if l
+// or its parent Logger are updated.
+func (l *ChildLogger) Handler() slog.Handler { return l.handler }
+
+// SetLevel overrides the log level of l. Passing an empty level removes the
+// override so that the level of the parent Logger is used again.
+func (l *ChildLogger) SetLevel(level Level) {
+	l.level.Set(level)
+}
+
+// Log implements log.Logger.
+func (l *ChildLogger) Log(kvps ...interface{}) error {
+	return slogadapter.GoKit(l.handler).Log(kvps...)
+}
+
+// levelOverride is a slog.Leveler which defers to a parent slog.Leveler
+// unless an override level is set.
+type levelOverride struct {
+	parent slog.Leveler
+
+	mut      sync.RWMutex
+	override *slog.Level
+}
+
+func (lo *levelOverride) Level() slog.Level {
+	lo.mut.RLock()
+	defer lo.mut.RUnlock()
+	if lo.override != nil {
+		return *lo.override
+	}
+	return lo.parent.Level()
+}
+
+func (lo *levelOverride) Set(level Level) {
+	lo.mut.Lock()
+	defer lo.mut.Unlock()
+	if level == "" {
+		lo.override = nil
+		return
+	}
+	override := slogLevel(level).Level()
+	lo.override = &override
+}
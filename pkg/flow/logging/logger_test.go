@@ -165,6 +165,32 @@ func TestLevels(t *testing.T) {
 	}
 }
 
+func TestChildLevelOverride(t *testing.T) {
+	buffer := bytes.NewBuffer(nil)
+	logger, err := logging.New(buffer, infoLevel())
+	require.NoError(t, err)
+
+	child := logger.Child()
+
+	// Without an override, the child uses the level of the parent.
+	flowlevel.Debug(child).Log("msg", "dropped")
+	require.Empty(t, buffer.String())
+
+	child.SetLevel(logging.LevelDebug)
+	flowlevel.Debug(child).Log("msg", "child debug")
+	flowlevel.Debug(logger).Log("msg", "parent debug")
+	require.Contains(t, buffer.String(), "msg=\"child debug\"")
+	require.NotContains(t, buffer.String(), "parent debug")
+
+	// Removing the override falls back to the level of the parent again, even
+	// after the parent has been updated.
+	buffer.Reset()
+	child.SetLevel("")
+	require.NoError(t, logger.Update(warnLevel()))
+	flowlevel.Info(child).Log("msg", "dropped")
+	require.Empty(t, buffer.String())
+}
+
 func BenchmarkLogging_NoLevel_Prints(b *testing.B) {
 	logger, err := logging.New(io.Discard, infoLevel())
 	require.NoError(b, err)
@@ -0,0 +1,99 @@
+package flow_test
+
+// This file contains tests which verify that the log format configured for
+// the controller applies to the logs of all components.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingFormat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	components := fmt.Sprintf(`
+	prometheus.remote_write "default" {
+		endpoint {
+			url = "%s/api/v1/write"
+		}
+	}
+`, srv.URL)
+
+	// The logger is created with the JSON format, which is what happens when
+	// running with --log.format=json.
+	var buf syncBuffer
+	logger, err := logging.New(&buf, logging.Options{
+		Level:  logging.LevelInfo,
+		Format: logging.FormatJSON,
+	})
+	require.NoError(t, err)
+
+	opts := testOptions(t)
+	opts.Logger = logger
+
+	ctrl := flow.New(opts)
+	f, err := flow.ParseSource(t.Name(), []byte(components))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool {
+		return countLines(buf.String(), `"component":"prometheus.remote_write.default"`) > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), "log line is not valid JSON: %s", line)
+		require.Contains(t, entry, "ts")
+		require.Contains(t, entry, "level")
+		require.Contains(t, entry, "msg")
+		if component, ok := entry["component"]; ok {
+			require.Equal(t, "prometheus.remote_write.default", component)
+		}
+	}
+
+	// The format can be switched on reload through the logging block.
+	buf.Reset()
+	f, err = flow.ParseSource(t.Name(), []byte(`logging { format = "logfmt" }`+"\n"+components))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	lastLine := lines[len(lines)-1]
+	require.Contains(t, lastLine, `msg="finished complete graph evaluation"`)
+	require.True(t, strings.HasPrefix(lastLine, "ts="), "expected logfmt log line, got: %s", lastLine)
+
+	// Removing the format from the logging block falls back to the format the
+	// logger was created with.
+	buf.Reset()
+	f, err = flow.ParseSource(t.Name(), []byte(`logging { level = "info" }`+"\n"+components))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	lastLine = lines[len(lines)-1]
+	require.True(t, json.Valid([]byte(lastLine)), "expected JSON log line, got: %s", lastLine)
+}
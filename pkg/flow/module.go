@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging"
@@ -21,6 +22,10 @@ type moduleController struct {
 	mut     sync.RWMutex
 	o       *moduleControllerOptions
 	modules map[string]struct{}
+
+	// created holds the modules created by the controller, by ID, whether or
+	// not they're running.
+	created map[string]*module
 }
 
 var (
@@ -32,6 +37,7 @@ func newModuleController(o *moduleControllerOptions) controller.ModuleController
 	return &moduleController{
 		o:       o,
 		modules: map[string]struct{}{},
+		created: map[string]*module{},
 	}
 }
 
@@ -54,10 +60,20 @@ func (m *moduleController) NewModule(id string, export component.ExportFunc) (co
 		moduleControllerOptions: m.o,
 		parent:                  m,
 	})
+	m.created[fullPath] = mod
 
 	return mod, nil
 }
 
+// createdModules returns the modules created by the controller. Unlike the
+// modules listed by ModuleIDs, they might not be running.
+func (m *moduleController) createdModules() []*module {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return maps.Values(m.created)
+}
+
 func (m *moduleController) removeModule(mod *module) {
 	m.mut.Lock()
 	defer m.mut.Unlock()
@@ -109,6 +125,7 @@ func newModule(o *moduleOptions) *module {
 			IsModule:          true,
 			ModuleRegistry:    o.ModuleRegistry,
 			ComponentRegistry: o.ComponentRegistry,
+			ComponentLimiter:  o.ComponentLimiter,
 			WorkerPool:        o.WorkerPool,
 			Options: Options{
 				ControllerID: o.ID,
@@ -116,6 +133,7 @@ func newModule(o *moduleOptions) *module {
 				Reg:          o.Reg,
 				Logger:       o.Logger,
 				DataPath:     o.DataPath,
+				MinStability: o.MinStability,
 				OnExportsChange: func(exports map[string]any) {
 					if o.export != nil {
 						o.export(exports)
@@ -145,11 +163,17 @@ func (c *module) Run(ctx context.Context) error {
 		return err
 	}
 	defer c.o.parent.removeModule(c)
+	defer c.o.ComponentLimiter.Release(c.o.ID)
 
 	c.f.Run(ctx)
 	return nil
 }
 
+// Drain sends the data buffered by the components of the Module.
+func (c *module) Drain(ctx context.Context) error {
+	return c.f.Drain(ctx)
+}
+
 // moduleControllerOptions holds static options for module controller.
 type moduleControllerOptions struct {
 	// Logger to use for controller logs and components. A no-op logger will be
@@ -180,10 +204,18 @@ type moduleControllerOptions struct {
 	// controller.
 	ModuleRegistry *moduleRegistry
 
+	// ComponentLimiter limits the number of components of the root controller
+	// and all of its modules.
+	ComponentLimiter *controller.ComponentLimiter
+
 	// ServiceMap is a map of services which can be used in the module
 	// controller.
 	ServiceMap controller.ServiceMap
 
+	// MinStability is the minimum stability level of the components which can
+	// be used in the module.
+	MinStability featuregate.Stability
+
 	// WorkerPool is a worker pool that can be used to run tasks asynchronously. A default pool will be created if this
 	// is nil.
 	WorkerPool worker.Pool
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging"
 	"github.com/prometheus/client_golang/prometheus"
@@ -239,6 +241,44 @@ func TestDuplicateIDList(t *testing.T) {
 	})
 }
 
+func TestModuleComponentLimit(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	o := testModuleControllerOptions(t)
+	defer o.WorkerPool.Stop()
+	o.ComponentLimiter = controller.NewComponentLimiter(o.Logger, nil, 0, 3)
+	nc := newModuleController(o)
+
+	const content = `
+		testcomponents.passthrough "a" {
+			input = "hello"
+		}
+		testcomponents.passthrough "b" {
+			input = "hello"
+		}
+	`
+
+	mod1, err := nc.NewModule("t1", nil)
+	require.NoError(t, err)
+	require.NoError(t, mod1.LoadConfig([]byte(content), nil))
+	ctx, cncl := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, mod1.Run(ctx))
+	}()
+
+	// Modules share the limit, so the components of the second module would
+	// exceed it.
+	mod2, err := nc.NewModule("t2", nil)
+	require.NoError(t, err)
+	require.ErrorContains(t, mod2.LoadConfig([]byte(content), nil), "exceeding the hard limit of 3 components")
+
+	// The components of stopped modules don't count towards the limit.
+	cncl()
+	<-done
+	require.NoError(t, mod2.LoadConfig([]byte(content), nil))
+}
+
 func testModuleControllerOptions(t *testing.T) *moduleControllerOptions {
 	t.Helper()
 
@@ -256,9 +296,10 @@ func testModuleControllerOptions(t *testing.T) *moduleControllerOptions {
 
 func init() {
 	component.Register(component.Registration{
-		Name:    "test.module",
-		Args:    TestArguments{},
-		Exports: TestExports{},
+		Name:      "test.module",
+		Stability: featuregate.StabilityStable,
+		Args:      TestArguments{},
+		Exports:   TestExports{},
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return &testModule{
@@ -0,0 +1,103 @@
+package statsd_exporter //nolint:golint
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// eventQueue batches the events of the listeners like event.EventQueue, and
+// can be stopped. The ticker flushing event.EventQueue can't be stopped, and
+// its goroutine blocks forever once the events are no longer read.
+type eventQueue struct {
+	c              chan<- event.Events
+	flushThreshold int
+	eventsFlushed  prometheus.Counter
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mut     sync.Mutex
+	q       event.Events
+	stopped bool
+}
+
+var _ event.EventHandler = (*eventQueue)(nil)
+
+// newEventQueue returns a queue flushing its events to c once it holds
+// flushThreshold events, and every flushInterval. Stop must be called to
+// stop it.
+func newEventQueue(c chan<- event.Events, flushThreshold int, flushInterval time.Duration, eventsFlushed prometheus.Counter) *eventQueue {
+	eq := &eventQueue{
+		c:              c,
+		flushThreshold: flushThreshold,
+		eventsFlushed:  eventsFlushed,
+		done:           make(chan struct{}),
+		q:              make(event.Events, 0, flushThreshold),
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	eq.wg.Add(1)
+	go func() {
+		defer eq.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-eq.done:
+				return
+			case <-ticker.C:
+				eq.Flush()
+			}
+		}
+	}()
+	return eq
+}
+
+// Queue implements event.EventHandler.
+func (eq *eventQueue) Queue(events event.Events) {
+	eq.mut.Lock()
+	defer eq.mut.Unlock()
+
+	for _, e := range events {
+		eq.q = append(eq.q, e)
+		if len(eq.q) >= eq.flushThreshold {
+			eq.flushUnlocked()
+		}
+	}
+}
+
+// Flush sends the queued events.
+func (eq *eventQueue) Flush() {
+	eq.mut.Lock()
+	defer eq.mut.Unlock()
+	eq.flushUnlocked()
+}
+
+// flushUnlocked sends the queued events, unless the queue is stopped. It must
+// be called with mut held.
+func (eq *eventQueue) flushUnlocked() {
+	if eq.stopped {
+		return
+	}
+	select {
+	case eq.c <- eq.q:
+		eq.eventsFlushed.Inc()
+	case <-eq.done:
+	}
+	eq.q = make(event.Events, 0, cap(eq.q))
+}
+
+// Stop stops the queue, dropping the queued events. The channel of the queue
+// can be closed once Stop returns.
+func (eq *eventQueue) Stop() {
+	eq.stopOnce.Do(func() { close(eq.done) })
+	eq.wg.Wait()
+
+	// Wait for the flushes in progress, which return once done is closed.
+	eq.mut.Lock()
+	defer eq.mut.Unlock()
+	eq.stopped = true
+}
@@ -0,0 +1,38 @@
+package statsd_exporter //nolint:golint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// TestEventQueueStop ensures that stopping the event queue stops its
+// goroutine, even when its events are no longer read, and that it no longer
+// flushes afterwards.
+func TestEventQueueStop(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+
+	events := make(chan event.Events)
+	eq := newEventQueue(events, 2, time.Millisecond, prometheus.NewCounter(prometheus.CounterOpts{Name: "flushed"}))
+
+	// The queued event is flushed by the ticker.
+	eq.Queue(event.Events{&event.CounterEvent{CMetricName: "a"}})
+	for evs := range events {
+		if len(evs) > 0 {
+			require.Equal(t, "a", evs[0].MetricName())
+			break
+		}
+	}
+
+	// The ticker flushes to events, which are no longer read.
+	time.Sleep(10 * time.Millisecond)
+	eq.Stop()
+	close(events)
+
+	eq.Queue(event.Events{&event.CounterEvent{CMetricName: "b"}, &event.CounterEvent{CMetricName: "c"}})
+	eq.Flush()
+}
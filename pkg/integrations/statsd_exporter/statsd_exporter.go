@@ -204,9 +204,14 @@ func (e *Exporter) Run(ctx context.Context) error {
 		parser.EnableSignalFXParsing()
 	}
 
+	// The listeners are closed before the event queue is stopped, and events
+	// is only closed once the queue no longer flushes to it.
 	events := make(chan event.Events, e.cfg.EventQueueSize)
-	defer close(events)
-	eventQueue := event.NewEventQueue(events, e.cfg.EventFlushThreshold, e.cfg.EventFlushInterval, e.metrics.EventsFlushed)
+	eventQueue := newEventQueue(events, e.cfg.EventFlushThreshold, e.cfg.EventFlushInterval, e.metrics.EventsFlushed)
+	defer func() {
+		eventQueue.Stop()
+		close(events)
+	}()
 
 	var relayTarget *relay.Relay
 	if e.cfg.RelayAddr != "" {
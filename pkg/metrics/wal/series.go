@@ -21,6 +21,11 @@ type memSeries struct {
 
 	// Last recorded timestamp. Used by gc to determine if a series is stale.
 	lastTs int64
+
+	// Value of the float sample recorded at lastTs. Only set when hasValue is
+	// true, which isn't the case for histogram samples.
+	lastValue float64
+	hasValue  bool
 }
 
 // updateTimestamp obtains the lock on s and will attempt to update lastTs.
@@ -30,6 +35,21 @@ func (m *memSeries) updateTimestamp(newTs int64) bool {
 	defer m.Unlock()
 	if newTs >= m.lastTs {
 		m.lastTs = newTs
+		m.hasValue = false
+		return true
+	}
+	return false
+}
+
+// updateSample is like updateTimestamp, but also records v as the value of
+// the float sample at newTs.
+func (m *memSeries) updateSample(newTs int64, v float64) bool {
+	m.Lock()
+	defer m.Unlock()
+	if newTs >= m.lastTs {
+		m.lastTs = newTs
+		m.lastValue = v
+		m.hasValue = true
 		return true
 	}
 	return false
@@ -398,6 +398,8 @@ func (w *Storage) loadWAL(r *wlog.Reader, multiRef map[chunks.HeadSeriesRef]chun
 				series := w.series.GetByID(ref)
 				if s.T > series.lastTs {
 					series.lastTs = s.T
+					series.lastValue = s.V
+					series.hasValue = true
 				}
 			}
 
@@ -414,6 +416,7 @@ func (w *Storage) loadWAL(r *wlog.Reader, multiRef map[chunks.HeadSeriesRef]chun
 				series := w.series.GetByID(ref)
 				if entry.T > series.lastTs {
 					series.lastTs = entry.T
+					series.hasValue = false
 				}
 			}
 
@@ -430,6 +433,7 @@ func (w *Storage) loadWAL(r *wlog.Reader, multiRef map[chunks.HeadSeriesRef]chun
 				series := w.series.GetByID(ref)
 				if entry.T > series.lastTs {
 					series.lastTs = entry.T
+					series.hasValue = false
 				}
 			}
 
@@ -462,6 +466,26 @@ func (w *Storage) Directory() string {
 	return w.path
 }
 
+// Sample is the latest float sample of a series.
+type Sample struct {
+	Labels labels.Labels
+	T      int64
+	V      float64
+}
+
+// LatestSamples returns the latest float sample of every series in the
+// storage for which match returns true. Series whose latest sample is a
+// histogram are skipped.
+func (w *Storage) LatestSamples(match func(labels.Labels) bool) []Sample {
+	var res []Sample
+	for series := range w.series.iterator().Channel() {
+		if series.hasValue && match(series.lset) {
+			res = append(res, Sample{Labels: series.lset, T: series.lastTs, V: series.lastValue})
+		}
+	}
+	return res
+}
+
 // Appender returns a new appender against the storage.
 func (w *Storage) Appender(_ context.Context) storage.Appender {
 	return w.appenderPool.Get().(storage.Appender)
@@ -925,7 +949,7 @@ func (a *appender) log() error {
 	var series *memSeries
 	for i, s := range a.pendingSamples {
 		series = a.sampleSeries[i]
-		if !series.updateTimestamp(s.T) {
+		if !series.updateSample(s.T, s.V) {
 			a.w.metrics.totalOutOfOrderSamples.Inc()
 		}
 	}
@@ -18,6 +18,7 @@ import (
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/chunks"
 	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -268,6 +269,37 @@ func TestStorage_ExistingWAL_RefID(t *testing.T) {
 	require.Equal(t, uint64(len(payload)), s.nextRef.Load(), "cached ref ID should be equal to the number of series written")
 }
 
+func TestStorage_LatestSamples(t *testing.T) {
+	walDir := t.TempDir()
+
+	s, err := NewStorage(log.NewNopLogger(), nil, walDir)
+	require.NoError(t, err)
+
+	app := s.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "foo", "job", "a"), 10, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "foo", "job", "a"), 20, 2)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "bar", "job", "b"), 15, 3)
+	require.NoError(t, err)
+	_, err = app.AppendHistogram(0, labels.FromStrings("__name__", "hist", "job", "a"), 15, tsdbutil.GenerateTestHistogram(1), nil)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	jobA := func(l labels.Labels) bool { return l.Get("job") == "a" }
+	expect := []Sample{{Labels: labels.FromStrings("__name__", "foo", "job", "a"), T: 20, V: 2}}
+	require.Equal(t, expect, s.LatestSamples(jobA))
+
+	// The latest samples should be known again after replaying the WAL.
+	require.NoError(t, s.Close())
+	s, err = NewStorage(log.NewNopLogger(), nil, walDir)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+	require.Equal(t, expect, s.LatestSamples(jobA))
+}
+
 func TestStorage_Truncate(t *testing.T) {
 	// Same as before but now do the following:
 	// after writing all the data, forcefully create 4 more segments,
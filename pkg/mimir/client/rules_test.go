@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/instrument"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/require"
 )
 
@@ -73,3 +75,33 @@ func TestMimirClient_X(t *testing.T) {
 		})
 	}
 }
+
+func TestMimirClient_CreateRuleGroup(t *testing.T) {
+	payloadCh := make(chan string, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		payloadCh <- string(buf)
+	}))
+	defer ts.Close()
+
+	client, err := New(log.NewNopLogger(), Config{
+		Address: ts.URL,
+	}, prometheus.NewHistogramVec(prometheus.HistogramOpts{}, instrument.HistogramCollectorBuckets))
+	require.NoError(t, err)
+
+	groups, errs := rulefmt.Parse([]byte(`
+groups:
+- name: alerts
+  rules:
+  - alert: HighErrorRate
+    expr: rate(errors_total[5m]) > 1
+    for: 1m
+    keep_firing_for: 5m
+`))
+	require.Empty(t, errs)
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", groups.Groups[0]))
+
+	payload := <-payloadCh
+	require.Contains(t, payload, "keep_firing_for: 5m")
+}
@@ -161,9 +161,11 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 		otelmux.WithTracerProvider(s.tracer),
 	))
 
+	// OpenMetrics is negotiated through the Accept header so that scrapers
+	// which support it also receive exemplars.
 	r.Handle(
 		"/metrics",
-		promhttp.HandlerFor(s.gatherer, promhttp.HandlerOpts{}),
+		promhttp.HandlerFor(s.gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
 	)
 	if s.opts.EnablePProf {
 		r.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
@@ -3,7 +3,9 @@ package http
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/grafana/agent/component"
@@ -155,12 +157,72 @@ func Test_Toggle_TLS(t *testing.T) {
 	}
 }
 
+func TestMetricsOpenMetrics(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+
+	reg := prometheus.NewRegistry()
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test_request_duration_seconds",
+		Help:    "Test histogram with exemplars.",
+		Buckets: []float64{0.1, 1},
+	})
+	reg.MustRegister(hist)
+	hist.(prometheus.ExemplarObserver).ObserveWithExemplar(0.5, prometheus.Labels{"trace_id": "abc123"})
+
+	env, err := newTestEnvironmentWithGatherer(t, reg)
+	require.NoError(t, err)
+	require.NoError(t, env.ApplyConfig(`/* empty */`))
+
+	go func() {
+		require.NoError(t, env.Run(ctx))
+	}()
+
+	scrape := func(t require.TestingT, accept string) (contentType string, body string) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/metrics", env.ListenAddr()), nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		bb, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Header.Get("Content-Type"), string(bb)
+	}
+
+	t.Run("OpenMetrics", func(t *testing.T) {
+		util.Eventually(t, func(t require.TestingT) {
+			contentType, body := scrape(t, "application/openmetrics-text; version=1.0.0")
+			require.Contains(t, contentType, "application/openmetrics-text")
+			require.Contains(t, body, `test_request_duration_seconds_bucket{le="1.0"} 1 # {trace_id="abc123"} 0.5`)
+			require.True(t, strings.HasSuffix(body, "# EOF\n"))
+		})
+	})
+
+	t.Run("Prometheus text", func(t *testing.T) {
+		util.Eventually(t, func(t require.TestingT) {
+			contentType, body := scrape(t, "")
+			require.Contains(t, contentType, "text/plain")
+			require.Contains(t, body, `test_request_duration_seconds_bucket{le="1"} 1`)
+			require.NotContains(t, body, "trace_id")
+		})
+	})
+}
+
 type testEnvironment struct {
 	svc  *Service
 	addr string
 }
 
 func newTestEnvironment(t *testing.T) (*testEnvironment, error) {
+	return newTestEnvironmentWithGatherer(t, prometheus.NewRegistry())
+}
+
+func newTestEnvironmentWithGatherer(t *testing.T, gatherer prometheus.Gatherer) (*testEnvironment, error) {
 	port, err := freeport.GetFreePort()
 	if err != nil {
 		return nil, err
@@ -169,7 +231,7 @@ func newTestEnvironment(t *testing.T) (*testEnvironment, error) {
 	svc := New(Options{
 		Logger:   util.TestLogger(t),
 		Tracer:   noop.NewTracerProvider(),
-		Gatherer: prometheus.NewRegistry(),
+		Gatherer: gatherer,
 
 		ReadyFunc:  func() bool { return true },
 		ReloadFunc: func() (*flow.Source, error) { return nil, nil },
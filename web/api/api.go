@@ -6,8 +6,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"path"
+	"sort"
 
 	"github.com/gorilla/mux"
 	"github.com/grafana/agent/component"
@@ -34,8 +36,19 @@ func (f *FlowAPI) RegisterRoutes(urlPrefix string, r *mux.Router) {
 
 	r.Handle(path.Join(urlPrefix, "/modules/{moduleID:.+}/components"), httputil.CompressionHandler{Handler: f.listComponentsHandler()})
 	r.Handle(path.Join(urlPrefix, "/components"), httputil.CompressionHandler{Handler: f.listComponentsHandler()})
+	// The refs routes must be registered before the /components/{id:.+} route,
+	// which would otherwise match them. Component IDs always contain a period,
+	// so they never conflict with these routes.
+	r.Handle(path.Join(urlPrefix, "/modules/{moduleID:.+}/components/refs"), httputil.CompressionHandler{Handler: f.listComponentRefsHandler()})
+	r.Handle(path.Join(urlPrefix, "/components/refs"), httputil.CompressionHandler{Handler: f.listComponentRefsHandler()})
+	// Likewise for the pause and resume routes, which can't conflict with the
+	// IDs of the components since component names can't end with them.
+	r.Handle(path.Join(urlPrefix, "/components/{id:.+}/pause"), f.pauseComponentHandler(true)).Methods(http.MethodPost)
+	r.Handle(path.Join(urlPrefix, "/components/{id:.+}/resume"), f.pauseComponentHandler(false)).Methods(http.MethodPost)
 	r.Handle(path.Join(urlPrefix, "/components/{id:.+}"), httputil.CompressionHandler{Handler: f.getComponentHandler()})
 	r.Handle(path.Join(urlPrefix, "/peers"), httputil.CompressionHandler{Handler: f.getClusteringPeersHandler()})
+	r.Handle(path.Join(urlPrefix, "/series"), httputil.CompressionHandler{Handler: f.listSeriesHandler()})
+	r.Handle(path.Join(urlPrefix, "/series/cardinality"), httputil.CompressionHandler{Handler: f.metricCardinalityHandler()})
 }
 
 func (f *FlowAPI) listComponentsHandler() http.HandlerFunc {
@@ -64,6 +77,59 @@ func (f *FlowAPI) listComponentsHandler() http.HandlerFunc {
 	}
 }
 
+// componentRefs lists the components a component references, and the
+// components which reference it.
+type componentRefs struct {
+	ModuleID     string   `json:"moduleID"`
+	LocalID      string   `json:"localID"`
+	ReferencesTo []string `json:"referencesTo"`
+	ReferencedBy []string `json:"referencedBy"`
+}
+
+func (f *FlowAPI) listComponentRefsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// moduleID is set from the /modules/{moduleID:.+}/components/refs route
+		// above but not from the /components/refs route.
+		var moduleID string
+		if vars := mux.Vars(r); vars != nil {
+			moduleID = vars["moduleID"]
+		}
+
+		components, err := f.flow.ListComponents(moduleID, component.InfoOptions{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		refs := make([]componentRefs, 0, len(components))
+		for _, c := range components {
+			refs = append(refs, componentRefs{
+				ModuleID:     c.ID.ModuleID,
+				LocalID:      c.ID.LocalID,
+				ReferencesTo: sortedIDs(c.References),
+				ReferencedBy: sortedIDs(c.ReferencedBy),
+			})
+		}
+
+		bb, err := json.Marshal(refs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// sortedIDs returns a sorted copy of ids, which is never nil.
+func sortedIDs(ids []string) []string {
+	res := append([]string{}, ids...)
+	sort.Strings(res)
+	return res
+}
+
+// getComponentHandler returns the details of a single component, including its
+// current evaluated arguments and exports. Secrets are redacted by the River
+// JSON encoding of the arguments.
 func (f *FlowAPI) getComponentHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -89,6 +155,36 @@ func (f *FlowAPI) getComponentHandler() http.HandlerFunc {
 	}
 }
 
+// pauseComponentHandler pauses a component if pause is true, or resumes it
+// otherwise, for a maintenance window without reloading the config.
+func (f *FlowAPI) pauseComponentHandler(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pp, ok := f.flow.(component.PauseProvider)
+		if !ok {
+			http.Error(w, "pausing components is not supported", http.StatusNotImplemented)
+			return
+		}
+
+		id := component.ParseID(mux.Vars(r)["id"])
+		var err error
+		if pause {
+			err = pp.PauseComponent(id)
+		} else {
+			err = pp.ResumeComponent(id)
+		}
+		switch {
+		case errors.Is(err, component.ErrComponentNotFound):
+			http.NotFound(w, r)
+		case errors.Is(err, component.ErrComponentNotPausable):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
 func (f *FlowAPI) getClusteringPeersHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, _ *http.Request) {
 		// TODO(@tpaschalis) Detect if clustering is disabled and propagate to
@@ -0,0 +1,465 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/agent/service"
+	cluster_service "github.com/grafana/agent/service/cluster"
+	http_service "github.com/grafana/agent/service/http"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/grafana/agent/web/api"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/grafana/agent/component/prometheus/exporter/agent"
+	_ "github.com/grafana/agent/component/prometheus/remotewrite"
+	_ "github.com/grafana/agent/component/prometheus/scrape"
+)
+
+func TestComponentRefs(t *testing.T) {
+	config := `
+		prometheus.exporter.agent "default" { }
+
+		prometheus.scrape "agent_self" {
+			targets    = prometheus.exporter.agent.default.targets
+			forward_to = [prometheus.remote_write.default.receiver]
+		}
+
+		prometheus.remote_write "default" { }
+	`
+	router := newTestAPI(t, config)
+
+	refs := getComponentRefs(t, router, "/api/v0/web/components/refs")
+	require.Equal(t, map[string]componentRefs{
+		"prometheus.exporter.agent.default": {
+			ReferencesTo: []string{},
+			ReferencedBy: []string{"prometheus.scrape.agent_self"},
+		},
+		"prometheus.scrape.agent_self": {
+			ReferencesTo: []string{"prometheus.exporter.agent.default", "prometheus.remote_write.default"},
+			ReferencedBy: []string{},
+		},
+		"prometheus.remote_write.default": {
+			ReferencesTo: []string{},
+			ReferencedBy: []string{"prometheus.scrape.agent_self"},
+		},
+	}, refs)
+}
+
+func TestComponentState(t *testing.T) {
+	config := `
+		prometheus.remote_write "default" {
+			endpoint {
+				url = "http://localhost:9009/api/v1/push"
+
+				basic_auth {
+					username = "user"
+					password = "hunter2"
+				}
+			}
+		}
+	`
+	router := newTestAPI(t, config)
+
+	state, raw := getComponentState(t, router, "prometheus.remote_write.default")
+	require.NotContains(t, raw, "hunter2", "secrets must be redacted")
+
+	endpoint := findRiverField(t, state.Arguments, "endpoint")
+	require.Equal(t, "block", endpoint.Type)
+	url := findRiverField(t, endpoint.Body, "url")
+	require.Equal(t, "string", url.Value.Type)
+	require.JSONEq(t, `"http://localhost:9009/api/v1/push"`, string(url.Value.Value))
+
+	receiver := findRiverField(t, state.Exports, "receiver")
+	require.Equal(t, "capsule", receiver.Value.Type)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/components/prometheus.remote_write.missing", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPauseComponent(t *testing.T) {
+	config := `
+		prometheus.scrape "agent_self" {
+			targets    = []
+			forward_to = []
+		}
+
+		prometheus.remote_write "default" { }
+	`
+	router := newTestAPI(t, config)
+
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/web/components/"+path, nil))
+		return rec.Code
+	}
+	healthState := func(id string) string {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/components/"+id, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp struct {
+			Health struct {
+				State string `json:"state"`
+			} `json:"health"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Health.State
+	}
+
+	require.Equal(t, http.StatusNoContent, post("prometheus.scrape.agent_self/pause"))
+	require.Equal(t, "paused", healthState("prometheus.scrape.agent_self"))
+
+	require.Equal(t, http.StatusNoContent, post("prometheus.scrape.agent_self/resume"))
+	require.NotEqual(t, "paused", healthState("prometheus.scrape.agent_self"))
+
+	require.Equal(t, http.StatusBadRequest, post("prometheus.remote_write.default/pause"))
+	require.Equal(t, http.StatusNotFound, post("prometheus.scrape.missing/pause"))
+}
+
+func TestListSeries(t *testing.T) {
+	// The agent scrapes the metrics of its own process.
+	srv := httptest.NewServer(promhttp.Handler())
+	defer srv.Close()
+
+	config := fmt.Sprintf(`
+		prometheus.scrape "agent_self" {
+			targets         = [{"__address__" = %q}]
+			forward_to      = [prometheus.remote_write.default.receiver]
+			scrape_interval = "100ms"
+			scrape_timeout  = "85ms"
+		}
+
+		prometheus.remote_write "default" { }
+	`, strings.TrimPrefix(srv.URL, "http://"))
+	router := newRunningTestAPI(t, config)
+
+	var inventory seriesInventory
+	require.Eventually(t, func() bool {
+		inventory = listSeries(t, router, nil)
+		return inventory.metricSeries("up") > 0
+	}, 30*time.Second, 100*time.Millisecond, "the self-scrape was never listed")
+
+	require.Equal(t, 1, inventory.metricSeries("up"))
+	require.Equal(t, 1, inventory.metricSeries("go_goroutines"))
+	// One series per quantile of the summary.
+	require.Equal(t, 5, inventory.metricSeries("go_gc_duration_seconds"))
+	require.Greater(t, inventory.TotalSeries, 10)
+
+	// Matchers scope the series listed.
+	goSeries := listSeries(t, router, url.Values{"match[]": {`{__name__=~"go_.+"}`}})
+	require.NotEmpty(t, goSeries.Metrics)
+	for _, m := range goSeries.Metrics {
+		require.True(t, strings.HasPrefix(m.Name, "go_"), m.Name)
+	}
+
+	// Pages list every series exactly once.
+	var (
+		seen   = make(map[string]struct{})
+		offset = 0
+	)
+	for {
+		page := listSeries(t, router, url.Values{
+			"match[]": {`{__name__=~"go_.+"}`},
+			"limit":   {"7"},
+			"offset":  {strconv.Itoa(offset)},
+		})
+		require.LessOrEqual(t, len(page.Series), 7)
+		for _, s := range page.Series {
+			seen[fmt.Sprint(s)] = struct{}{}
+		}
+		if page.NextOffset == 0 {
+			break
+		}
+		offset = page.NextOffset
+	}
+	require.Len(t, seen, goSeries.TotalSeries)
+
+	// Limits and offsets too large to add up don't overflow.
+	huge := strconv.Itoa(math.MaxInt)
+	all := listSeries(t, router, url.Values{"match[]": {`{__name__=~"go_.+"}`}, "limit": {huge}})
+	require.Len(t, all.Series, min(all.TotalSeries, 10000))
+	require.Empty(t, listSeries(t, router, url.Values{"limit": {huge}, "offset": {huge}}).Series)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/series?limit=0", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMetricCardinality(t *testing.T) {
+	// The path label of the synthetic metric has many more values than the
+	// code label.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 50; i++ {
+			_, _ = fmt.Fprintf(w, "synthetic_requests_total{path=\"/users/%d\",code=\"200\"} 1\n", i)
+		}
+		for i := 0; i < 5; i++ {
+			_, _ = fmt.Fprintf(w, "synthetic_requests_total{path=\"/users/%d\",code=\"500\"} 1\n", i)
+		}
+	}))
+	defer srv.Close()
+
+	config := fmt.Sprintf(`
+		prometheus.scrape "app" {
+			targets         = [{"__address__" = %q}]
+			forward_to      = [prometheus.remote_write.default.receiver]
+			scrape_interval = "100ms"
+			scrape_timeout  = "85ms"
+		}
+
+		prometheus.remote_write "default" { }
+	`, strings.TrimPrefix(srv.URL, "http://"))
+	router := newRunningTestAPI(t, config)
+
+	var cardinality metricCardinality
+	require.Eventually(t, func() bool {
+		cardinality = getMetricCardinality(t, router, url.Values{"metric": {"synthetic_requests_total"}, "limit": {"3"}})
+		return cardinality.TotalSeries == 55
+	}, 30*time.Second, 100*time.Millisecond, "the synthetic metric was never scraped")
+
+	top := cardinality.Labels[0]
+	require.Equal(t, "path", top.Name)
+	require.Equal(t, 50, top.DistinctValues)
+	// The paths with a series for both codes contribute the most series.
+	require.Equal(t, []labelValueSeries{
+		{Value: "/users/0", Series: 2},
+		{Value: "/users/1", Series: 2},
+		{Value: "/users/2", Series: 2},
+	}, top.TopValues)
+
+	code := cardinality.label("code")
+	require.Equal(t, 2, code.DistinctValues)
+	require.Equal(t, []labelValueSeries{{Value: "200", Series: 50}, {Value: "500", Series: 5}}, code.TopValues)
+	require.Equal(t, 1, cardinality.label("job").DistinctValues)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/series/cardinality", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// newTestAPI loads config in a Flow controller, and returns a router serving
+// the API for it.
+func newTestAPI(t *testing.T, config string) *mux.Router {
+	t.Helper()
+
+	router, _ := newTestController(t, config)
+	return router
+}
+
+// newRunningTestAPI is like newTestAPI, but also runs the Flow controller
+// until the test ends.
+func newRunningTestAPI(t *testing.T, config string) *mux.Router {
+	t.Helper()
+
+	router, ctrl := newTestController(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctrl.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return router
+}
+
+func newTestController(t *testing.T, config string) (*mux.Router, *flow.Flow) {
+	t.Helper()
+
+	l, err := logging.New(os.Stderr, logging.DefaultOptions)
+	require.NoError(t, err)
+
+	clusterService, err := cluster_service.New(cluster_service.Options{
+		Log:              l,
+		EnableClustering: false,
+		NodeName:         "test-node",
+		AdvertiseAddress: "127.0.0.1:80",
+	})
+	require.NoError(t, err)
+
+	ctrl := flow.New(flow.Options{
+		Logger:   l,
+		DataPath: t.TempDir(),
+		Services: []service.Service{
+			http_service.New(http_service.Options{}),
+			clusterService,
+			labelstore.New(nil),
+		},
+	})
+	f, err := flow.ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	r := mux.NewRouter()
+	api.NewFlowAPI(ctrl, clusterService.Data().(cluster_service.Cluster)).RegisterRoutes("/api/v0/web", r)
+	return r, ctrl
+}
+
+type seriesInventory struct {
+	TotalSeries int `json:"totalSeries"`
+	Metrics     []struct {
+		Name   string `json:"name"`
+		Series int    `json:"series"`
+	} `json:"metrics"`
+	Series     []map[string]string `json:"series"`
+	NextOffset int                 `json:"nextOffset"`
+}
+
+// metricSeries returns the number of series of the metric name.
+func (inv seriesInventory) metricSeries(name string) int {
+	for _, m := range inv.Metrics {
+		if m.Name == name {
+			return m.Series
+		}
+	}
+	return 0
+}
+
+// listSeries requests the active series with the given query parameters.
+func listSeries(t *testing.T, r http.Handler, query url.Values) seriesInventory {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/series?"+query.Encode(), nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var inventory seriesInventory
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &inventory))
+	return inventory
+}
+
+type metricCardinality struct {
+	TotalSeries int                `json:"totalSeries"`
+	Labels      []labelCardinality `json:"labels"`
+}
+
+type labelCardinality struct {
+	Name           string             `json:"name"`
+	DistinctValues int                `json:"distinctValues"`
+	TopValues      []labelValueSeries `json:"topValues"`
+}
+
+type labelValueSeries struct {
+	Value  string `json:"value"`
+	Series int    `json:"series"`
+}
+
+// label returns the cardinality of the label name.
+func (mc metricCardinality) label(name string) labelCardinality {
+	for _, l := range mc.Labels {
+		if l.Name == name {
+			return l
+		}
+	}
+	return labelCardinality{}
+}
+
+// getMetricCardinality requests the cardinality of a metric with the given
+// query parameters.
+func getMetricCardinality(t *testing.T, r http.Handler, query url.Values) metricCardinality {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/series/cardinality?"+query.Encode(), nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var cardinality metricCardinality
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cardinality))
+	return cardinality
+}
+
+type componentRefs struct {
+	ReferencesTo []string `json:"referencesTo"`
+	ReferencedBy []string `json:"referencedBy"`
+}
+
+// getComponentRefs requests the component references at the given path, and
+// returns them by component ID.
+func getComponentRefs(t *testing.T, r http.Handler, path string) map[string]componentRefs {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp []struct {
+		ModuleID string `json:"moduleID"`
+		LocalID  string `json:"localID"`
+		componentRefs
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	refs := make(map[string]componentRefs, len(resp))
+	for _, c := range resp {
+		id := c.LocalID
+		if c.ModuleID != "" {
+			id = c.ModuleID + "/" + id
+		}
+		refs[id] = c.componentRefs
+	}
+	return refs
+}
+
+// componentState holds the evaluated arguments and exports of a component,
+// encoded as River JSON bodies.
+type componentState struct {
+	Arguments []riverField `json:"arguments"`
+	Exports   []riverField `json:"exports"`
+}
+
+type riverField struct {
+	Name  string       `json:"name"`
+	Type  string       `json:"type"` // "attr" or "block"
+	Label string       `json:"label,omitempty"`
+	Value riverValue   `json:"value"`
+	Body  []riverField `json:"body"`
+}
+
+type riverValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// getComponentState requests the component with the given ID, and returns
+// its state along with the raw response.
+func getComponentState(t *testing.T, r http.Handler, id string) (componentState, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/web/components/"+id, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var state componentState
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	return state, rec.Body.String()
+}
+
+func findRiverField(t *testing.T, fields []riverField, name string) riverField {
+	t.Helper()
+
+	for _, f := range fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	require.FailNow(t, "field not found", name)
+	return riverField{}
+}
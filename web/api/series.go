@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// defaultSeriesLimit is the number of series returned per page unless the
+	// limit parameter is set.
+	defaultSeriesLimit = 100
+	// maxSeriesLimit is the maximum number of series returned per page.
+	maxSeriesLimit = 10000
+	// defaultTopValuesLimit is the number of top values returned for every
+	// label unless the limit parameter is set.
+	defaultTopValuesLimit = 10
+)
+
+// seriesInventory lists the active series tracked by the components.
+type seriesInventory struct {
+	// Total number of series matched, and number of series by metric name.
+	TotalSeries int                 `json:"totalSeries"`
+	Metrics     []metricSeriesCount `json:"metrics"`
+	// Page of the series matched, and offset of the next page if there is
+	// one.
+	Series     []map[string]string `json:"series"`
+	NextOffset int                 `json:"nextOffset,omitempty"`
+}
+
+type metricSeriesCount struct {
+	Name   string `json:"name"`
+	Series int    `json:"series"`
+}
+
+// listSeriesHandler lists the active series of the components implementing
+// [prometheus.ActiveSeriesComponent], such as prometheus.remote_write. Series
+// tracked by multiple components are only listed once.
+//
+// The series can be filtered with match[] selectors, and are paginated with
+// the limit and offset parameters.
+func (f *FlowAPI) listSeriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "error parsing form values: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Series are listed if they match any of the match[] selectors.
+		var selectors [][]*labels.Matcher
+		for _, s := range r.Form["match[]"] {
+			matchers, err := parser.ParseMetricSelector(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			selectors = append(selectors, matchers)
+		}
+		limit, err := intParam(r, "limit", defaultSeriesLimit)
+		if err == nil && limit == 0 {
+			err = fmt.Errorf("limit must be greater than 0")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		offset, err := intParam(r, "offset", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bb, err := json.Marshal(buildSeriesInventory(f.activeSeries(selectors), min(limit, maxSeriesLimit), offset))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// metricCardinality reports the labels of a metric driving its cardinality.
+type metricCardinality struct {
+	Metric      string             `json:"metric"`
+	TotalSeries int                `json:"totalSeries"`
+	Labels      []labelCardinality `json:"labels"`
+}
+
+// labelCardinality reports the number of distinct values of a label, and the
+// values with the most series.
+type labelCardinality struct {
+	Name           string             `json:"name"`
+	DistinctValues int                `json:"distinctValues"`
+	TopValues      []labelValueSeries `json:"topValues"`
+}
+
+type labelValueSeries struct {
+	Value  string `json:"value"`
+	Series int    `json:"series"`
+}
+
+// metricCardinalityHandler reports, for the metric given in the metric
+// parameter, the labels with the most distinct values among its active series
+// and the values of every label with the most series, up to limit values.
+func (f *FlowAPI) metricCardinalityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "error parsing form values: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		metric := r.Form.Get("metric")
+		if metric == "" {
+			http.Error(w, "metric parameter is required", http.StatusBadRequest)
+			return
+		}
+		limit, err := intParam(r, "limit", defaultTopValuesLimit)
+		if err == nil && limit == 0 {
+			err = fmt.Errorf("limit must be greater than 0")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		series := f.activeSeries([][]*labels.Matcher{{
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metric),
+		}})
+		bb, err := json.Marshal(buildMetricCardinality(metric, series, limit))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// buildMetricCardinality counts the series of every value of the labels of
+// series, which all belong to metric. Labels are sorted by decreasing number
+// of distinct values, and keep their limit values with the most series.
+func buildMetricCardinality(metric string, series []labels.Labels, limit int) metricCardinality {
+	counts := make(map[string]map[string]int)
+	for _, l := range series {
+		l.Range(func(l labels.Label) {
+			if l.Name == labels.MetricName {
+				return
+			}
+			if counts[l.Name] == nil {
+				counts[l.Name] = make(map[string]int)
+			}
+			counts[l.Name][l.Value]++
+		})
+	}
+
+	res := metricCardinality{
+		Metric:      metric,
+		TotalSeries: len(series),
+		Labels:      make([]labelCardinality, 0, len(counts)),
+	}
+	for name, values := range counts {
+		lc := labelCardinality{
+			Name:           name,
+			DistinctValues: len(values),
+			TopValues:      make([]labelValueSeries, 0, len(values)),
+		}
+		for v, n := range values {
+			lc.TopValues = append(lc.TopValues, labelValueSeries{Value: v, Series: n})
+		}
+		sort.Slice(lc.TopValues, func(i, j int) bool {
+			if a, b := lc.TopValues[i], lc.TopValues[j]; a.Series != b.Series {
+				return a.Series > b.Series
+			}
+			return lc.TopValues[i].Value < lc.TopValues[j].Value
+		})
+		if len(lc.TopValues) > limit {
+			lc.TopValues = lc.TopValues[:limit]
+		}
+		res.Labels = append(res.Labels, lc)
+	}
+	sort.Slice(res.Labels, func(i, j int) bool {
+		if a, b := res.Labels[i], res.Labels[j]; a.DistinctValues != b.DistinctValues {
+			return a.DistinctValues > b.DistinctValues
+		}
+		return res.Labels[i].Name < res.Labels[j].Name
+	})
+	return res
+}
+
+// activeSeries returns the distinct active series of all components which
+// match any of the selectors, or every series if there are no selectors.
+func (f *FlowAPI) activeSeries(selectors [][]*labels.Matcher) []labels.Labels {
+	match := func(l labels.Labels) bool {
+		return len(selectors) == 0 || prometheus.MatchesAny(selectors, l)
+	}
+
+	var (
+		res []labels.Labels
+		// The series seen by hash. Series with colliding hashes are told
+		// apart by their labels.
+		seen = make(map[uint64][]labels.Labels)
+	)
+	for _, info := range component.GetAllComponents(f.flow, component.InfoOptions{}) {
+		sc, ok := info.Component.(prometheus.ActiveSeriesComponent)
+		if !ok {
+			continue
+		}
+		for _, l := range sc.ActiveSeries(match) {
+			hash := l.Hash()
+			if containsLabels(seen[hash], l) {
+				continue
+			}
+			seen[hash] = append(seen[hash], l)
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func containsLabels(series []labels.Labels, l labels.Labels) bool {
+	for _, s := range series {
+		if labels.Equal(s, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSeriesInventory counts series by metric name and returns the page of
+// limit series starting at offset, sorted by metric name and labels.
+func buildSeriesInventory(series []labels.Labels, limit, offset int) seriesInventory {
+	sort.Slice(series, func(i, j int) bool {
+		if a, b := series[i].Get(labels.MetricName), series[j].Get(labels.MetricName); a != b {
+			return a < b
+		}
+		return labels.Compare(series[i], series[j]) < 0
+	})
+
+	res := seriesInventory{
+		TotalSeries: len(series),
+		Metrics:     []metricSeriesCount{},
+		Series:      []map[string]string{},
+	}
+	for _, l := range series {
+		name := l.Get(labels.MetricName)
+		if n := len(res.Metrics); n == 0 || res.Metrics[n-1].Name != name {
+			res.Metrics = append(res.Metrics, metricSeriesCount{Name: name})
+		}
+		res.Metrics[len(res.Metrics)-1].Series++
+	}
+
+	// The end of the page is computed from the number of series left so that
+	// large offsets and limits don't overflow.
+	offset = min(offset, len(series))
+	end := len(series)
+	if limit < end-offset {
+		end = offset + limit
+		res.NextOffset = end
+	}
+	for _, l := range series[offset:end] {
+		res.Series = append(res.Series, l.Map())
+	}
+	return res
+}
+
+// intParam returns the non-negative integer form value name of r, or def if
+// it isn't set.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.Form.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", name, v)
+	}
+	return n, nil
+}
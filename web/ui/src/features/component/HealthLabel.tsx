@@ -14,6 +14,7 @@ export const HealthLabel: FC<HealthLabelProps> = ({ health }) => {
     [ComponentHealthState.UNHEALTHY]: `${styles.health} ${styles['state-error']}`,
     [ComponentHealthState.UNKNOWN]: `${styles.health} ${styles['state-warn']}`,
     [ComponentHealthState.EXITED]: `${styles.health} ${styles['state-error']}`,
+    [ComponentHealthState.PAUSED]: `${styles.health} ${styles['state-warn']}`,
   };
   const healthClass = healthMappings[health];
 
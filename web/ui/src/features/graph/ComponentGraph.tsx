@@ -385,6 +385,7 @@ export const ComponentGraph: FC<ComponentGraphProps> = (props) => {
           case ComponentHealthState.EXITED:
             return '#d2476d';
           case ComponentHealthState.UNKNOWN:
+          case ComponentHealthState.PAUSED:
             return '#f5d65b';
         }
       })